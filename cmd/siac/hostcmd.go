@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"math/big"
+	"net/url"
 	"os"
 	"sort"
 	"strings"
@@ -17,6 +18,15 @@ import (
 )
 
 var (
+	hostAlertsCmd = &cobra.Command{
+		Use:   "alerts",
+		Short: "Show the alerts of the host",
+		Long: `Show the problems with the contracts of the host that need attention, such
+as storage proofs that failed or are likely to fail because sectors are missing
+or corrupt.`,
+		Run: wrap(hostalertscmd),
+	}
+
 	hostAnnounceCmd = &cobra.Command{
 		Use:   "announce",
 		Short: "Announce yourself as a host",
@@ -40,7 +50,8 @@ Doing so will override the standard connectivity checks.`,
 	hostConfigCmd = &cobra.Command{
 		Use:   "config [setting] [value]",
 		Short: "Modify host settings",
-		Long: `Modify host settings.
+		Long: `Modify host settings. Without arguments, the bandwidth limits of the host and
+the bandwidth used by each renter in the current quota period are shown.
 
 Available settings:
      acceptingcontracts:   boolean
@@ -59,24 +70,51 @@ Available settings:
      minstorageprice:           currency / TB / Month
      minuploadbandwidthprice:   currency / TB
 
+     maxdownloadspeed: bytes / second
+     maxuploadspeed:   bytes / second
+     renterquota:      bytes
+     renterquotas:     list of renter public keys and bytes
+
+     dynamicpricing:            boolean
+     maxdownloadbandwidthprice: currency / TB
+     maxstorageprice:           currency / TB / Month
+     maxuploadbandwidthprice:   currency / TB
+
 Currency units can be specified, e.g. 10SC; run 'siac help wallet' for details.
 
 Durations (maxduration and windowsize) must be specified in either blocks (b),
 hours (h), days (d), or weeks (w). A block is approximately 10 minutes, so one
 hour is six blocks, a day is 144 blocks, and a week is 1008 blocks.
 
+Speeds and quotas can be specified with units, e.g. 10MB. A speed or quota of
+0 means unlimited. maxdownloadspeed limits the data received from renters and
+maxuploadspeed the data sent to renters. renterquota limits the bytes that each
+renter can upload and download per month, and renterquotas sets the quotas of
+individual renters, overriding renterquota:
+	siac host config renterquotas ed25519:1234...=10GB,ed25519:abcd...=1TB
+An empty list removes the quotas of the individual renters.
+
+If dynamicpricing is enabled, the host periodically adjusts its storage and
+bandwidth prices to its utilization and to the prices of the other hosts. The
+prices never fall below the min prices and never rise above the max prices; a
+max price of 0 means no maximum. Run 'siac host pricing' to see the current
+prices and the log of the price changes.
+
 For a description of each parameter, see doc/API.md.
 
 To configure the host to accept new contracts, set acceptingcontracts to true:
 	siac host config acceptingcontracts true
 `,
-		Run: wrap(hostconfigcmd),
+		Run: hostconfigcmd,
 	}
 
 	hostContractCmd = &cobra.Command{
 		Use:   "contracts",
 		Short: "Show host contracts",
-		Long: `Show host contracts sorted by expiration height.
+		Long: `Show host contracts, by default sorted by expiration height. The contracts
+can be filtered by status and by height, sorted by other fields and paged with
+the flags, e.g.:
+	siac host contracts --status unresolved --maxexpiration 150000 --limit 20
 
 Available output types:
      value:  show financial information
@@ -85,6 +123,31 @@ Available output types:
 		Run: wrap(hostcontractcmd),
 	}
 
+	hostContractViewCmd = &cobra.Command{
+		Use:   "view [contract-id]",
+		Short: "View details of the specified contract",
+		Long:  "View all details available of the specified contract, including its revision history.",
+		Run:   wrap(hostcontractviewcmd),
+	}
+
+	hostMetricsCmd = &cobra.Command{
+		Use:   "metrics",
+		Short: "Show the history of the host's financial metrics",
+		Long: `Show the revenue, the lost revenue and collateral and the locked and risked
+collateral of the host over time. The blocks are grouped into buckets of a day
+by default and every bucket is drawn as a bar of a sparkline, e.g.:
+	siac host metrics --bucket 1w --from 130000`,
+		Run: wrap(hostmetricscmd),
+	}
+
+	hostPricingCmd = &cobra.Command{
+		Use:   "pricing",
+		Short: "Show the prices of the host",
+		Long: `Show the current prices of the host and, if dynamic pricing is enabled, the
+estimated prices of the network and the log of the price changes.`,
+		Run: wrap(hostpricingcmd),
+	}
+
 	hostFolderAddCmd = &cobra.Command{
 		Use:   "add [path] [size]",
 		Short: "Add a storage folder to the host",
@@ -157,7 +220,7 @@ func hostcmd() {
 	}
 
 	// convert price from bytes/block to TB/Month
-	price := currencyUnits(is.MinStoragePrice.Mul(modules.BlockBytesPerMonthTerabyte))
+	price := currencyUnits(es.StoragePrice.Mul(modules.BlockBytesPerMonthTerabyte))
 	// calculate total revenue
 	totalRevenue := fm.ContractCompensation.
 		Add(fm.StorageRevenue).
@@ -208,6 +271,15 @@ Host Internal Settings:
 	minstorageprice:           %v / TB / Month
 	minuploadbandwidthprice:   %v / TB
 
+	maxdownloadspeed: %v
+	maxuploadspeed:   %v
+	renterquota:      %v
+
+	dynamicpricing:            %v
+	maxdownloadbandwidthprice: %v
+	maxstorageprice:           %v
+	maxuploadbandwidthprice:   %v
+
 Host Financials:
 	Contract Count:               %v
 	Transaction Fee Compensation: %v
@@ -251,6 +323,14 @@ RPC Stats:
 			currencyUnits(is.MinStoragePrice.Mul(modules.BlockBytesPerMonthTerabyte)),
 			currencyUnits(is.MinUploadBandwidthPrice.Mul(modules.BytesPerTerabyte)),
 
+			speedUnits(is.MaxDownloadSpeed), speedUnits(is.MaxUploadSpeed),
+			quotaUnits(is.RenterQuota),
+
+			yesNo(is.DynamicPricing),
+			maxPriceUnits(is.MaxDownloadBandwidthPrice.Mul(modules.BytesPerTerabyte), "/ TB"),
+			maxPriceUnits(is.MaxStoragePrice.Mul(modules.BlockBytesPerMonthTerabyte), "/ TB / Month"),
+			maxPriceUnits(is.MaxUploadBandwidthPrice.Mul(modules.BytesPerTerabyte), "/ TB"),
+
 			fm.ContractCount, currencyUnits(fm.ContractCompensation),
 			currencyUnits(fm.PotentialContractCompensation),
 			currencyUnits(fm.TransactionFeeExpenses),
@@ -324,8 +404,18 @@ RPC Stats:
 }
 
 // hostconfigcmd is the handler for the command `siac host config [setting] [value]`.
-// Modifies host settings.
-func hostconfigcmd(param, value string) {
+// Modifies host settings, or shows the bandwidth limits and usage of the host
+// if no arguments are given.
+func hostconfigcmd(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		hostbandwidthcmd()
+		return
+	}
+	if len(args) != 2 {
+		cmd.UsageFunc()(cmd)
+		os.Exit(exitCodeUsage)
+	}
+	param, value := args[0], args[1]
 	var err error
 	switch param {
 	// currency (convert to hastings)
@@ -336,27 +426,31 @@ func hostconfigcmd(param, value string) {
 		}
 
 	// currency/TB (convert to hastings/byte)
-	case "mindownloadbandwidthprice", "minuploadbandwidthprice":
-		hastings, err := parseCurrency(value)
-		if err != nil {
-			die("Could not parse "+param+":", err)
+	case "mindownloadbandwidthprice", "minuploadbandwidthprice", "maxdownloadbandwidthprice", "maxuploadbandwidthprice":
+		if value != "0" {
+			hastings, err := parseCurrency(value)
+			if err != nil {
+				die("Could not parse "+param+":", err)
+			}
+			i, _ := new(big.Int).SetString(hastings, 10)
+			c := types.NewCurrency(i).Div(modules.BytesPerTerabyte)
+			value = c.String()
 		}
-		i, _ := new(big.Int).SetString(hastings, 10)
-		c := types.NewCurrency(i).Div(modules.BytesPerTerabyte)
-		value = c.String()
 
 	// currency/TB/month (convert to hastings/byte/block)
-	case "collateral", "minstorageprice":
-		hastings, err := parseCurrency(value)
-		if err != nil {
-			die("Could not parse "+param+":", err)
+	case "collateral", "minstorageprice", "maxstorageprice":
+		if value != "0" {
+			hastings, err := parseCurrency(value)
+			if err != nil {
+				die("Could not parse "+param+":", err)
+			}
+			i, _ := new(big.Int).SetString(hastings, 10)
+			c := types.NewCurrency(i).Div(modules.BlockBytesPerMonthTerabyte)
+			value = c.String()
 		}
-		i, _ := new(big.Int).SetString(hastings, 10)
-		c := types.NewCurrency(i).Div(modules.BlockBytesPerMonthTerabyte)
-		value = c.String()
 
 	// bool (allow "yes" and "no")
-	case "acceptingcontracts":
+	case "acceptingcontracts", "dynamicpricing":
 		switch strings.ToLower(value) {
 		case "yes":
 			value = "true"
@@ -371,6 +465,22 @@ func hostconfigcmd(param, value string) {
 			die("Could not parse "+param+":", err)
 		}
 
+	// size (convert to bytes)
+	case "maxdownloadspeed", "maxuploadspeed", "renterquota":
+		if value != "0" {
+			value, err = parseFilesize(value)
+			if err != nil {
+				die("Could not parse "+param+":", err)
+			}
+		}
+
+	// list of renter quotas (convert to bytes)
+	case "renterquotas":
+		value, err = parseRenterQuotas(value)
+		if err != nil {
+			die("Could not parse "+param+":", err)
+		}
+
 	// other valid settings
 	case "maxdownloadbatchsize", "maxrevisebatchsize", "netaddress":
 
@@ -396,13 +506,268 @@ func hostconfigcmd(param, value string) {
 	fmt.Printf("Estimated conversion rate: %v%%\n", eg.ConversionRate)
 }
 
+// parseRenterQuotas converts the sizes in a comma-separated list of renter
+// quotas of the form 'publickey=size' to bytes.
+func parseRenterQuotas(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	quotas := strings.Split(value, ",")
+	for i, quota := range quotas {
+		parts := strings.Split(quota, "=")
+		if len(parts) != 2 {
+			return "", fmt.Errorf("renter quota %q is not of the form 'publickey=size'", quota)
+		}
+		size := parts[1]
+		if size != "0" {
+			var err error
+			size, err = parseFilesize(size)
+			if err != nil {
+				return "", err
+			}
+		}
+		quotas[i] = parts[0] + "=" + size
+	}
+	return strings.Join(quotas, ","), nil
+}
+
+// speedUnits returns a string that displays a speed limit in human-readable
+// units.
+func speedUnits(bps int64) string {
+	if bps == 0 {
+		return "unlimited"
+	}
+	return filesizeUnits(bps) + "/s"
+}
+
+// quotaUnits returns a string that displays a bandwidth quota in
+// human-readable units.
+func quotaUnits(quota uint64) string {
+	if quota == 0 {
+		return "unlimited"
+	}
+	return filesizeUnits(int64(quota))
+}
+
+// hostbandwidthcmd is the handler for the command `siac host config` without
+// arguments. It shows the bandwidth limits of the host and the bandwidth used
+// by each renter.
+func hostbandwidthcmd() {
+	hg, err := httpClient.HostGet()
+	if err != nil {
+		die("Could not fetch host settings:", err)
+	}
+	is := hg.InternalSettings
+	nm := hg.NetworkMetrics
+	fmt.Printf(`Bandwidth Limits:
+	maxdownloadspeed: %v
+	maxuploadspeed:   %v
+	renterquota:      %v
+
+Bandwidth Usage:
+	Received: %v
+	Sent:     %v
+`, speedUnits(is.MaxDownloadSpeed), speedUnits(is.MaxUploadSpeed), quotaUnits(is.RenterQuota),
+		filesizeUnits(int64(nm.BytesReceived)), filesizeUnits(int64(nm.BytesSent)))
+
+	if len(is.RenterQuotas) > 0 {
+		keys := make([]string, 0, len(is.RenterQuotas))
+		for key := range is.RenterQuotas {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Println("\nRenter Quotas:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+		for _, key := range keys {
+			fmt.Fprintf(w, "\t%v\t%v\n", key, quotaUnits(is.RenterQuotas[key]))
+		}
+		w.Flush()
+	}
+
+	if len(nm.RenterUsage) == 0 {
+		fmt.Println("\nNo renter has used bandwidth in the current quota period.")
+		return
+	}
+	fmt.Printf("\nRenter Usage (since block %v):\n", nm.RenterUsage[0].PeriodStart)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "\tRenter\tDownloaded\tUploaded\tQuota")
+	for _, u := range nm.RenterUsage {
+		fmt.Fprintf(w, "\t%v\t%v\t%v\t%v\n", u.PublicKey.String(), filesizeUnits(int64(u.Downloaded)),
+			filesizeUnits(int64(u.Uploaded)), quotaUnits(u.Quota))
+	}
+	w.Flush()
+}
+
+// sparkline draws the values as bars whose heights are relative to the
+// largest value.
+func sparkline(values []types.Currency) string {
+	bars := []rune("▁▂▃▄▅▆▇█")
+	max := types.ZeroCurrency
+	for _, v := range values {
+		if v.Cmp(max) > 0 {
+			max = v
+		}
+	}
+	line := make([]rune, len(values))
+	for i, v := range values {
+		line[i] = bars[0]
+		if !max.IsZero() {
+			height, _ := new(big.Rat).SetFrac(v.Big(), max.Big()).Float64()
+			line[i] = bars[int(height*float64(len(bars)-1)+0.5)]
+		}
+	}
+	return string(line)
+}
+
+// hostmetricscmd is the handler for the command `siac host metrics`. It
+// charts the history of the financial metrics of the host.
+func hostmetricscmd() {
+	bucket, err := parsePeriod(hostMetricsBucket)
+	if err != nil {
+		die("Could not parse bucket:", err)
+	}
+	var bucketSize types.BlockHeight
+	if _, err := fmt.Sscan(bucket, &bucketSize); err != nil || bucketSize == 0 {
+		die("Buckets must contain at least one block")
+	}
+	from, to := types.BlockHeight(hostMetricsFrom), types.BlockHeight(hostMetricsTo)
+	if from == 0 {
+		if to == 0 {
+			cg, err := httpClient.ConsensusGet()
+			if err != nil {
+				die("Could not get current height:", err)
+			}
+			to = cg.Height
+		}
+		if span := 60 * bucketSize; to >= span {
+			from = to - span + 1
+		}
+	}
+	hmhg, err := httpClient.HostMetricsHistoryGet(from, to, bucket)
+	if err != nil {
+		die("Could not fetch host metrics:", err)
+	}
+	if len(hmhg.Buckets) == 0 {
+		fmt.Println("No host metrics.")
+		return
+	}
+
+	series := []struct {
+		name  string
+		level bool // the value at the end of a bucket instead of the amount within it
+		value func(modules.HostMetricsBucket) types.Currency
+	}{
+		{"Contract Compensation", false, func(b modules.HostMetricsBucket) types.Currency { return b.ContractCompensation }},
+		{"Storage Revenue", false, func(b modules.HostMetricsBucket) types.Currency { return b.StorageRevenue }},
+		{"Upload Revenue", false, func(b modules.HostMetricsBucket) types.Currency { return b.UploadBandwidthRevenue }},
+		{"Download Revenue", false, func(b modules.HostMetricsBucket) types.Currency { return b.DownloadBandwidthRevenue }},
+		{"Lost Revenue", false, func(b modules.HostMetricsBucket) types.Currency { return b.LostRevenue }},
+		{"Lost Collateral", false, func(b modules.HostMetricsBucket) types.Currency { return b.LostStorageCollateral }},
+		{"Locked Collateral", true, func(b modules.HostMetricsBucket) types.Currency { return b.LockedStorageCollateral }},
+		{"Risked Collateral", true, func(b modules.HostMetricsBucket) types.Currency { return b.RiskedStorageCollateral }},
+	}
+	last := hmhg.Buckets[len(hmhg.Buckets)-1]
+	fmt.Printf("Host metrics from block %v to %v, %v blocks per bucket:\n\n", hmhg.Buckets[0].StartHeight, last.EndHeight, hmhg.BucketSize)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, s := range series {
+		values := make([]types.Currency, len(hmhg.Buckets))
+		total := types.ZeroCurrency
+		for i, b := range hmhg.Buckets {
+			values[i] = s.value(b)
+			total = total.Add(values[i])
+		}
+		summary := currencyUnits(total) + " total"
+		if s.level {
+			summary = currencyUnits(values[len(values)-1]) + " now"
+		}
+		fmt.Fprintf(w, "  %v\t%v\t%v\n", s.name, sparkline(values), summary)
+	}
+	contracts := make([]types.Currency, len(hmhg.Buckets))
+	for i, b := range hmhg.Buckets {
+		contracts[i] = types.NewCurrency64(b.ContractCount)
+	}
+	fmt.Fprintf(w, "  Contracts\t%v\t%v now\n", sparkline(contracts), last.ContractCount)
+	w.Flush()
+}
+
+// maxPriceUnits returns a string that displays a maximum price of the dynamic
+// pricing in human-readable units.
+func maxPriceUnits(c types.Currency, unit string) string {
+	if c.IsZero() {
+		return "none"
+	}
+	return currencyUnits(c) + " " + unit
+}
+
+// hostpricingcmd is the handler for the command `siac host pricing`. It shows
+// the current prices of the host and the log of the dynamic pricing.
+func hostpricingcmd() {
+	hpg, err := httpClient.HostPricingGet()
+	if err != nil {
+		die("Could not fetch host pricing:", err)
+	}
+	fmt.Printf(`Dynamic Pricing: %v
+
+Current Prices:
+	Storage:  %v / TB / Month
+	Upload:   %v / TB
+	Download: %v / TB
+`, yesNo(hpg.Enabled),
+		currencyUnits(hpg.Prices.StoragePrice.Mul(modules.BlockBytesPerMonthTerabyte)),
+		currencyUnits(hpg.Prices.UploadBandwidthPrice.Mul(modules.BytesPerTerabyte)),
+		currencyUnits(hpg.Prices.DownloadBandwidthPrice.Mul(modules.BytesPerTerabyte)))
+	if hpg.NetworkSamples > 0 {
+		fmt.Printf(`
+Network Prices (median of %v hosts):
+	Storage:  %v / TB / Month
+	Upload:   %v / TB
+	Download: %v / TB
+`, hpg.NetworkSamples,
+			currencyUnits(hpg.NetworkPrices.StoragePrice.Mul(modules.BlockBytesPerMonthTerabyte)),
+			currencyUnits(hpg.NetworkPrices.UploadBandwidthPrice.Mul(modules.BytesPerTerabyte)),
+			currencyUnits(hpg.NetworkPrices.DownloadBandwidthPrice.Mul(modules.BytesPerTerabyte)))
+	}
+
+	if len(hpg.Changes) == 0 {
+		fmt.Println("\nNo price changes.")
+		return
+	}
+	fmt.Println("\nPrice Changes:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  Height\tTime\tStorage Used\tCollateral Used\tAcceptance\tStorage (/TB/Mo)\tUpload (/TB)\tDownload (/TB)")
+	for _, c := range hpg.Changes {
+		acceptance := "-"
+		if c.AcceptanceRate >= 0 {
+			acceptance = fmt.Sprintf("%.0f%%", 100*c.AcceptanceRate)
+		}
+		fmt.Fprintf(w, "  %v\t%v\t%.0f%%\t%.0f%%\t%v\t%v\t%v\t%v\n", c.BlockHeight, c.Timestamp.Format("2006-01-02 15:04"),
+			100*c.StorageUtilization, 100*c.CollateralUtilization, acceptance,
+			currencyUnits(c.NewPrices.StoragePrice.Mul(modules.BlockBytesPerMonthTerabyte)),
+			currencyUnits(c.NewPrices.UploadBandwidthPrice.Mul(modules.BytesPerTerabyte)),
+			currencyUnits(c.NewPrices.DownloadBandwidthPrice.Mul(modules.BytesPerTerabyte)))
+	}
+	w.Flush()
+}
+
 // hostcontractcmd is the handler for the command `siac host contracts [type]`.
 func hostcontractcmd() {
-	cg, err := httpClient.HostContractInfoGet()
+	values := url.Values{}
+	values.Set("status", hostContractStatus)
+	values.Set("minexpiration", fmt.Sprint(hostContractMinExpiration))
+	values.Set("maxexpiration", fmt.Sprint(hostContractMaxExpiration))
+	values.Set("minnegotiation", fmt.Sprint(hostContractMinNegotiation))
+	values.Set("maxnegotiation", fmt.Sprint(hostContractMaxNegotiation))
+	values.Set("sort", hostContractSort)
+	values.Set("desc", fmt.Sprint(hostContractDescending))
+	values.Set("offset", fmt.Sprint(hostContractOffset))
+	values.Set("limit", fmt.Sprint(hostContractLimit))
+	cg, err := httpClient.HostContractInfoQueryGet(values)
 	if err != nil {
 		die("Could not fetch host contract info:", err)
 	}
-	sort.Slice(cg.Contracts, func(i, j int) bool { return cg.Contracts[i].ExpirationHeight < cg.Contracts[j].ExpirationHeight })
+	if len(cg.Contracts) < cg.Total {
+		fmt.Printf("Showing contracts %v-%v of %v.\n\n", hostContractOffset+1, hostContractOffset+len(cg.Contracts), cg.Total)
+	}
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
 	switch hostContractOutputType {
 	case "value":
@@ -424,6 +789,90 @@ func hostcontractcmd() {
 	w.Flush()
 }
 
+// proofFailure returns a string that displays the reason why the storage
+// proof of a contract failed.
+func proofFailure(cause string) string {
+	if cause == "" {
+		return "none"
+	}
+	return cause
+}
+
+// hostalertscmd is the handler for the command `siac host alerts`. It shows
+// the problems with the contracts of the host.
+func hostalertscmd() {
+	hag, err := httpClient.HostAlertsGet()
+	if err != nil {
+		die("Could not fetch host alerts:", err)
+	}
+	if len(hag.Alerts) == 0 {
+		fmt.Println("No alerts.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Height\tSeverity\tCause\tContract\tMessage")
+	for _, a := range hag.Alerts {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", a.BlockHeight, a.Severity, a.Cause, a.ObligationID, a.Message)
+	}
+	w.Flush()
+}
+
+// hostcontractviewcmd is the handler for the command `siac host contracts view
+// [contract-id]`. It shows the details of a contract and its revision history.
+func hostcontractviewcmd(cid string) {
+	var id crypto.Hash
+	if err := id.LoadString(cid); err != nil {
+		die("Could not parse contract id:", err)
+	}
+	hcg, err := httpClient.HostContractGet(types.FileContractID(id))
+	if err != nil {
+		die("Could not get contract details:", err)
+	}
+	so := hcg.Contract
+	fmt.Printf(`Contract %v
+  Status: %v
+
+  Negotiation Height: %v
+  Expiration Height:  %v
+  Proof Deadline:     %v
+
+  Data Size: %v (%v sectors)
+
+  Contract Cost:     %v
+  Transaction Fees:  %v
+  Locked Collateral: %v
+  Risked Collateral: %v
+  Potential Revenue: %v (Storage: %v, Upload: %v, Download: %v)
+
+  Origin Confirmed:     %v
+  Revision Constructed: %v
+  Revision Confirmed:   %v
+  Proof Constructed:    %v
+  Proof Confirmed:      %v
+  Proof Failure:        %v
+`, so.ObligationId, strings.TrimPrefix(so.ObligationStatus, "obligation"),
+		so.NegotiationHeight, so.ExpirationHeight, so.ProofDeadLine,
+		filesizeUnits(int64(so.DataSize)), so.SectorRootsCount,
+		currencyUnits(so.ContractCost), currencyUnits(so.TransactionFeesAdded),
+		currencyUnits(so.LockedCollateral), currencyUnits(so.RiskedCollateral),
+		currencyUnits(so.PotentialStorageRevenue.Add(so.PotentialUploadRevenue).Add(so.PotentialDownloadRevenue)),
+		currencyUnits(so.PotentialStorageRevenue), currencyUnits(so.PotentialUploadRevenue),
+		currencyUnits(so.PotentialDownloadRevenue),
+		yesNo(so.OriginConfirmed), yesNo(so.RevisionConstructed), yesNo(so.RevisionConfirmed),
+		yesNo(so.ProofConstructed), yesNo(so.ProofConfirmed), proofFailure(so.ProofFailure))
+
+	fmt.Println("\nRevisions:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  Revision\tHeight\tFile Size\tWindow\tValid Host Payout\tMissed Host Payout\tRenter Funds")
+	for _, rev := range so.Revisions {
+		fmt.Fprintf(w, "  %v\t%v\t%v\t%v-%v\t%v\t%v\t%v\n", rev.RevisionNumber, rev.BlockHeight,
+			filesizeUnits(int64(rev.FileSize)), rev.WindowStart, rev.WindowEnd,
+			currencyUnits(rev.ValidHostPayout), currencyUnits(rev.MissedHostPayout),
+			currencyUnits(rev.ValidRenterPayout))
+	}
+	w.Flush()
+}
+
 // hostannouncecmd is the handler for the command `siac host announce`.
 // Announces yourself as a host to the network. Optionally takes an address to
 // announce as.
@@ -28,6 +28,19 @@ var (
 		Run:   wrap(hostdbcmd),
 	}
 
+	hostdbFilterCmd = &cobra.Command{
+		Use:   "filter [mode] [pubkey]...",
+		Short: "View or set the filter mode of the host database.",
+		Long: `View the filter mode of the host database, or set it if [mode] is given.
+
+[mode] is one of 'disable', 'blacklist' or 'whitelist'. In blacklist mode the
+listed hosts are never selected, in whitelist mode only the listed hosts are
+selected. Contracts with hosts that are excluded by the filter are not renewed,
+and their data is moved to other hosts. Setting a mode replaces the list of
+hosts.`,
+		Run: hostdbfiltercmd,
+	}
+
 	hostdbViewCmd = &cobra.Command{
 		Use:   "view [pubkey]",
 		Short: "View the full information for a host.",
@@ -44,6 +57,7 @@ func printScoreBreakdown(info *api.HostdbHostsGET) {
 	fmt.Fprintf(w, "\t\tBurn:\t %.3f\n", info.ScoreBreakdown.BurnAdjustment)
 	fmt.Fprintf(w, "\t\tCollateral:\t %.3f\n", info.ScoreBreakdown.CollateralAdjustment)
 	fmt.Fprintf(w, "\t\tInteraction:\t %.3f\n", info.ScoreBreakdown.InteractionAdjustment)
+	fmt.Fprintf(w, "\t\tPerformance:\t %.3f\n", info.ScoreBreakdown.PerformanceAdjustment)
 	fmt.Fprintf(w, "\t\tPrice:\t %.3f\n", info.ScoreBreakdown.PriceAdjustment*1e6)
 	fmt.Fprintf(w, "\t\tStorage:\t %.3f\n", info.ScoreBreakdown.StorageRemainingAdjustment)
 	fmt.Fprintf(w, "\t\tUptime:\t %.3f\n", info.ScoreBreakdown.UptimeAdjustment)
@@ -285,6 +299,7 @@ func hostdbviewcmd(pubkey string) {
 
 	fmt.Println("  Public Key:", info.Entry.PublicKeyString)
 	fmt.Println("  Block First Seen:", info.Entry.FirstSeen)
+	fmt.Println("  Filtered:", yesNo(info.Entry.Filtered))
 
 	fmt.Println("\n  Host Settings:")
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
@@ -299,6 +314,14 @@ func hostdbviewcmd(pubkey string) {
 	fmt.Fprintln(w, "\t\tVersion:\t", info.Entry.Version)
 	w.Flush()
 
+	// Throughput and latency are zero until they have been measured.
+	fmt.Println("\n  Measured Performance:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\t\tDownload Throughput:\t", filesizeUnits(int64(info.Entry.DownloadThroughput))+"/s")
+	fmt.Fprintln(w, "\t\tUpload Throughput:\t", filesizeUnits(int64(info.Entry.UploadThroughput))+"/s")
+	fmt.Fprintln(w, "\t\tLatency:\t", info.Entry.Latency)
+	w.Flush()
+
 	printScoreBreakdown(&info)
 
 	// Compute the total measured uptime and total measured downtime for this
@@ -328,3 +351,42 @@ func hostdbviewcmd(pubkey string) {
 
 	fmt.Println()
 }
+
+// hostdbfiltercmd is the handler for the command `siac hostdb filter [mode]
+// [pubkey]...`. It shows the filter mode of the hostdb if no mode is given,
+// and sets it otherwise.
+func hostdbfiltercmd(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		hdfg, err := httpClient.HostDbFilterModeGet()
+		if err != nil {
+			die("Could not get the filter mode:", err)
+		}
+		fmt.Println("Filter Mode:", hdfg.FilterMode)
+		if len(hdfg.Hosts) == 0 {
+			return
+		}
+		fmt.Println("Hosts:")
+		for _, host := range hdfg.Hosts {
+			fmt.Println("\t" + host)
+		}
+		return
+	}
+
+	fm, err := modules.ParseFilterMode(args[0])
+	if err != nil {
+		die("Could not parse the filter mode:", err)
+	}
+	var hosts []types.SiaPublicKey
+	for _, arg := range args[1:] {
+		var spk types.SiaPublicKey
+		spk.LoadString(arg)
+		if len(spk.Key) == 0 {
+			die("Could not parse host public key", arg)
+		}
+		hosts = append(hosts, spk)
+	}
+	if err := httpClient.HostDbFilterModePost(fm, hosts); err != nil {
+		die("Could not set the filter mode:", err)
+	}
+	fmt.Println("Filter mode set to", fm)
+}
@@ -13,12 +13,46 @@ import (
 
 var (
 	// Flags.
-	hostContractOutputType string // output type for host contracts
-	hostVerbose            bool   // display additional host info
-	initForce              bool   // destroy and reencrypt the wallet on init if it already exists
-	initPassword           bool   // supply a custom password when creating a wallet
-	renterListVerbose      bool   // Show additional info about uploaded files.
-	renterShowHistory      bool   // Show download history in addition to download queue.
+	hostContractDescending             bool   // sort the host contracts in descending order
+	hostContractLimit                  int    // maximum number of host contracts shown
+	hostContractMaxExpiration          uint64 // maximum expiration height of the host contracts
+	hostContractMaxNegotiation         uint64 // maximum negotiation height of the host contracts
+	hostContractMinExpiration          uint64 // minimum expiration height of the host contracts
+	hostContractMinNegotiation         uint64 // minimum negotiation height of the host contracts
+	hostContractOffset                 int    // number of host contracts skipped
+	hostContractOutputType             string // output type for host contracts
+	hostContractSort                   string // sort key of the host contracts
+	hostContractStatus                 string // statuses of the host contracts shown
+	hostMetricsBucket                  string // size of the buckets of the host metrics
+	hostMetricsFrom                    uint64 // first height of the host metrics
+	hostMetricsTo                      uint64 // last height of the host metrics
+	hostVerbose                        bool   // display additional host info
+	initForce                          bool   // destroy and reencrypt the wallet on init if it already exists
+	initPassword                       bool   // supply a custom password when creating a wallet
+	renterAllowanceExpectedDownload    string // expected download volume of the allowance
+	renterAllowanceExpectedStorage     string // expected storage volume of the allowance
+	renterAllowanceExpectedUpload      string // expected upload volume of the allowance
+	renterAllowanceMaxDownloadSpending string // cap on the download spending of the allowance
+	renterAllowanceMaxPricePerTB       string // cap on the storage price per TB per month
+	renterAllowanceMaxStorageSpending  string // cap on the storage spending of the allowance
+	renterAllowanceMaxUploadSpending   string // cap on the upload spending of the allowance
+	renterBackupRemote                 bool   // upload the backup to the renter's hosts
+	renterDiversityGeoIP               string // path of the GeoIP database for region diversity
+	renterDiversitySubnets             bool   // don't select hosts in the same subnet
+	renterDownloadPriority             string // priority class of downloads
+	renterListVerbose                  bool   // Show additional info about uploaded files.
+	renterMountCacheSize               uint64 // size of the stream cache of a mount
+	renterMountPrefetch                uint64 // prefetch window of the files of a mount
+	renterReencryptCipher              string // cipher of re-encrypted files
+	renterShowHistory                  bool   // Show download history in addition to download queue.
+	renterSpendingCSV                  bool   // export the spending ledger as CSV
+	renterSpendingCurrent              bool   // only show the spending of the current period
+	renterUploadCipher                 string // cipher used for uploads
+	renterUploadCoder                  string // erasure coder used for uploads
+	renterUploadDataPieces             uint64 // number of data pieces used for uploads
+	renterUploadDedup                  bool   // deduplicate the chunks of uploads
+	renterUploadParity                 uint64 // number of parity pieces used for uploads
+	renterUploadPriority               string // priority class of uploads
 )
 
 var (
@@ -88,14 +122,27 @@ func main() {
 	updateCmd.AddCommand(updateCheckCmd)
 
 	root.AddCommand(hostCmd)
-	hostCmd.AddCommand(hostConfigCmd, hostAnnounceCmd, hostFolderCmd, hostContractCmd, hostSectorCmd)
+	hostCmd.AddCommand(hostConfigCmd, hostAlertsCmd, hostAnnounceCmd, hostFolderCmd, hostContractCmd, hostMetricsCmd, hostPricingCmd, hostSectorCmd)
 	hostFolderCmd.AddCommand(hostFolderAddCmd, hostFolderRemoveCmd, hostFolderResizeCmd)
+	hostContractCmd.AddCommand(hostContractViewCmd)
 	hostSectorCmd.AddCommand(hostSectorDeleteCmd)
 	hostCmd.Flags().BoolVarP(&hostVerbose, "verbose", "v", false, "Display detailed host info")
+	hostMetricsCmd.Flags().StringVarP(&hostMetricsBucket, "bucket", "", "1d", "Size of the buckets, e.g. 1b, 6h, 1d or 1w")
+	hostMetricsCmd.Flags().Uint64VarP(&hostMetricsFrom, "from", "", 0, "First height of the metrics (0 for the last 60 buckets)")
+	hostMetricsCmd.Flags().Uint64VarP(&hostMetricsTo, "to", "", 0, "Last height of the metrics (0 for the current height)")
 	hostContractCmd.Flags().StringVarP(&hostContractOutputType, "type", "t", "value", "Select output type")
+	hostContractCmd.Flags().StringVarP(&hostContractStatus, "status", "", "", "Only show contracts with these comma-separated statuses (unresolved, succeeded, failed, rejected)")
+	hostContractCmd.Flags().Uint64VarP(&hostContractMinExpiration, "minexpiration", "", 0, "Only show contracts that expire at or after this height")
+	hostContractCmd.Flags().Uint64VarP(&hostContractMaxExpiration, "maxexpiration", "", 0, "Only show contracts that expire at or before this height (0 for no maximum)")
+	hostContractCmd.Flags().Uint64VarP(&hostContractMinNegotiation, "minnegotiation", "", 0, "Only show contracts negotiated at or after this height")
+	hostContractCmd.Flags().Uint64VarP(&hostContractMaxNegotiation, "maxnegotiation", "", 0, "Only show contracts negotiated at or before this height (0 for no maximum)")
+	hostContractCmd.Flags().StringVarP(&hostContractSort, "sort", "", "expirationheight", "Sort the contracts by expirationheight, negotiationheight, datasize, lockedcollateral, potentialrevenue or id")
+	hostContractCmd.Flags().BoolVarP(&hostContractDescending, "desc", "", false, "Sort the contracts in descending order")
+	hostContractCmd.Flags().IntVarP(&hostContractOffset, "offset", "", 0, "Number of contracts to skip")
+	hostContractCmd.Flags().IntVarP(&hostContractLimit, "limit", "", 0, "Maximum number of contracts to show (0 for no limit)")
 
 	root.AddCommand(hostdbCmd)
-	hostdbCmd.AddCommand(hostdbViewCmd)
+	hostdbCmd.AddCommand(hostdbViewCmd, hostdbFilterCmd)
 	hostdbCmd.Flags().IntVarP(&hostdbNumHosts, "numhosts", "n", 0, "Number of hosts to display from the hostdb")
 	hostdbCmd.Flags().BoolVarP(&hostdbVerbose, "verbose", "v", false, "Display full hostdb information")
 
@@ -118,16 +165,48 @@ func main() {
 		renterDownloadsCmd, renterAllowanceCmd, renterSetAllowanceCmd,
 		renterContractsCmd, renterFilesListCmd, renterFilesRenameCmd,
 		renterFilesUploadCmd, renterUploadsCmd, renterExportCmd,
-		renterPricesCmd)
+		renterPricesCmd, renterDirCmd, renterBackupCmd, renterRestoreCmd,
+		renterRecoverCmd, renterStreamsCmd, renterMountCmd, renterMountsCmd,
+		renterUnmountCmd, renterSpendingCmd, renterReencryptCmd,
+		renterReencryptionsCmd, renterShareCmd, renterLoadShareCmd,
+		renterDiversityCmd, renterChunkCacheCmd)
 
 	renterContractsCmd.AddCommand(renterContractsViewCmd)
+	renterDirCmd.AddCommand(renterDirCreateCmd, renterDirDeleteCmd, renterDirRenameCmd)
 	renterAllowanceCmd.AddCommand(renterAllowanceCancelCmd)
+	renterDownloadsCmd.AddCommand(renterDownloadsCancelCmd)
+	renterUploadsCmd.AddCommand(renterUploadsPauseCmd, renterUploadsResumeCmd, renterUploadsCancelCmd)
 
+	renterBackupCmd.Flags().BoolVarP(&renterBackupRemote, "remote", "", false, "Upload a backup of the files and settings to the renter's hosts")
 	renterCmd.Flags().BoolVarP(&renterListVerbose, "verbose", "v", false, "Show additional file info such as redundancy")
+	renterDiversityCmd.Flags().BoolVarP(&renterDiversitySubnets, "subnets", "", false, "Don't form contracts with two hosts in the same IP subnet")
+	renterDiversityCmd.Flags().StringVarP(&renterDiversityGeoIP, "geoip", "", "", "Path of a GeoIP database; no two hosts in the same region are used (empty to disable)")
 	renterDownloadsCmd.Flags().BoolVarP(&renterShowHistory, "history", "H", false, "Show download history in addition to the download queue")
+	renterFilesDownloadCmd.Flags().StringVarP(&renterDownloadPriority, "priority", "", "", "Priority of the download (low, normal or high)")
+	renterSetAllowanceCmd.Flags().StringVarP(&renterAllowanceExpectedStorage, "expectedstorage", "", "", "Amount of data expected to be stored, e.g. 500GB")
+	renterSetAllowanceCmd.Flags().StringVarP(&renterAllowanceExpectedUpload, "expectedupload", "", "", "Amount of data expected to be uploaded per period")
+	renterSetAllowanceCmd.Flags().StringVarP(&renterAllowanceExpectedDownload, "expecteddownload", "", "", "Amount of data expected to be downloaded per period")
+	renterSetAllowanceCmd.Flags().StringVarP(&renterAllowanceMaxStorageSpending, "maxstoragespending", "", "", "Cap on the money spent on storage per period (0 for no cap)")
+	renterSetAllowanceCmd.Flags().StringVarP(&renterAllowanceMaxUploadSpending, "maxuploadspending", "", "", "Cap on the money spent on uploads per period (0 for no cap)")
+	renterSetAllowanceCmd.Flags().StringVarP(&renterAllowanceMaxDownloadSpending, "maxdownloadspending", "", "", "Cap on the money spent on downloads per period (0 for no cap)")
+	renterSetAllowanceCmd.Flags().StringVarP(&renterAllowanceMaxPricePerTB, "maxpricepertb", "", "", "Cap on the storage price per TB per month (0 for no cap)")
+	renterSpendingCmd.Flags().BoolVarP(&renterSpendingCSV, "csv", "", false, "Export the spending as CSV, in hastings")
+	renterSpendingCmd.Flags().BoolVarP(&renterSpendingCurrent, "current", "", false, "Only show the spending of the current period")
+	renterMountCmd.Flags().Uint64VarP(&renterMountPrefetch, "prefetch", "", 0, "Number of chunks fetched ahead of the chunk being read (defaults to the renter's default)")
+	renterMountCmd.Flags().Uint64VarP(&renterMountCacheSize, "cachesize", "", 0, "Size of the mount's own stream cache in chunks (shares the renter's stream cache if 0)")
 	renterFilesListCmd.Flags().BoolVarP(&renterListVerbose, "verbose", "v", false, "Show additional file info such as redundancy")
+	renterFilesUploadCmd.Flags().StringVarP(&renterUploadCoder, "erasurecoder", "e", "", "Erasure coder to use (Reed-Solomon, Replication or Partial-RS)")
+	renterFilesUploadCmd.Flags().Uint64VarP(&renterUploadDataPieces, "datapieces", "", 0, "Number of data pieces (defaults to the renter's default redundancy)")
+	renterFilesUploadCmd.Flags().Uint64VarP(&renterUploadParity, "paritypieces", "", 0, "Number of parity pieces (defaults to the renter's default redundancy)")
+	renterFilesUploadCmd.Flags().BoolVarP(&renterUploadDedup, "dedup", "", false, "Deduplicate the uploaded files with identical data of other deduplicated files")
+	renterFilesUploadCmd.Flags().StringVarP(&renterUploadPriority, "priority", "", "", "Priority of the upload and of the repairs of the files (low, normal or high)")
+	renterFilesUploadCmd.Flags().StringVarP(&renterUploadCipher, "cipher", "", "", "Cipher used to encrypt the file (twofish-gcm or xchacha20-poly1305)")
+	renterReencryptCmd.Flags().StringVarP(&renterReencryptCipher, "cipher", "", "", "Cipher used to encrypt the new copy of the file (defaults to the cipher of the file)")
 	renterExportCmd.AddCommand(renterExportContractTxnsCmd)
 
+	root.AddCommand(s3Cmd)
+	s3Cmd.AddCommand(s3AddKeyCmd, s3RemoveKeyCmd)
+
 	root.AddCommand(gatewayCmd)
 	gatewayCmd.AddCommand(gatewayConnectCmd, gatewayDisconnectCmd, gatewayAddressCmd, gatewayListCmd)
 
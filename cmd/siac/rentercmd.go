@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -12,7 +14,9 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter"
 	"github.com/NebulousLabs/Sia/node/api"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -32,6 +36,19 @@ var (
 		Run:   wrap(renterallowancecmd),
 	}
 
+	renterBackupCmd = &cobra.Command{
+		Use:   "backup [destination]",
+		Short: "Back up the renter",
+		Long: `Write an encrypted backup of the renter's files, contracts and settings to
+[destination]. The backup is encrypted with a key derived from the wallet seed,
+so the wallet must be unlocked. Together with the seed, the backup is all that
+is needed to restore the renter on another machine.
+
+With --remote, a backup of the files and settings is uploaded to the renter's
+hosts instead, where 'siac renter recover' can find it using only the seed.`,
+		Run: renterbackupcmd,
+	}
+
 	renterCmd = &cobra.Command{
 		Use:   "renter",
 		Short: "Perform renter actions",
@@ -53,6 +70,61 @@ var (
 		Run:   wrap(rentercontractsviewcmd),
 	}
 
+	renterChunkCacheCmd = &cobra.Command{
+		Use:   "chunkcache [size]",
+		Short: "View or resize the chunk cache",
+		Long: `View the statistics of the renter's on-disk chunk cache. Downloaded chunks are
+cached in the renter's directory, and repeated downloads of the same chunks
+are served from disk. If [size] is given (e.g. 10GB), the capacity of the cache
+is changed; a size of 0 disables the cache.`,
+		Run: renterchunkcachecmd,
+	}
+
+	renterDiversityCmd = &cobra.Command{
+		Use:   "diversity",
+		Short: "View or change the host diversity policy",
+		Long: `View or change the renter's host diversity policy. With --subnets the renter
+doesn't form contracts with two hosts in the same /24 (IPv4) or /64 (IPv6)
+subnet. With --geoip the renter loads a local GeoIP database and doesn't form
+contracts with two hosts in the same region. The database is a CSV file with
+lines of the form 'network,region', e.g. '1.2.3.0/24,DE'.
+
+Without flags, the current policy and the contracts that violate it are shown.`,
+		Run: renterdiversitycmd,
+	}
+
+	renterDirCmd = &cobra.Command{
+		Use:   "dir [path]",
+		Short: "List a directory",
+		Long: `List the subdirectories and files of a directory. The root directory is
+listed if no path is given.`,
+		Run: renterdircmd,
+	}
+
+	renterDirCreateCmd = &cobra.Command{
+		Use:     "create [path]",
+		Aliases: []string{"mkdir"},
+		Short:   "Create a directory",
+		Long:    "Create a directory, including any missing parent directories.",
+		Run:     wrap(renterdircreatecmd),
+	}
+
+	renterDirDeleteCmd = &cobra.Command{
+		Use:     "delete [path]",
+		Aliases: []string{"rm"},
+		Short:   "Delete a directory",
+		Long:    "Delete a directory and all files and directories it contains. Does not delete any files on disk.",
+		Run:     wrap(renterdirdeletecmd),
+	}
+
+	renterDirRenameCmd = &cobra.Command{
+		Use:     "rename [path] [newpath]",
+		Aliases: []string{"mv"},
+		Short:   "Rename a directory",
+		Long:    "Rename a directory, moving all files and directories it contains.",
+		Run:     wrap(renterdirrenamecmd),
+	}
+
 	renterDownloadsCmd = &cobra.Command{
 		Use:   "downloads",
 		Short: "View the download queue",
@@ -60,6 +132,13 @@ var (
 		Run:   wrap(renterdownloadscmd),
 	}
 
+	renterDownloadsCancelCmd = &cobra.Command{
+		Use:   "cancel [id]",
+		Short: "Cancel a download",
+		Long:  "Cancel the download with the given ID. The IDs are listed by 'siac renter downloads'.",
+		Run:   wrap(renterdownloadscancelcmd),
+	}
+
 	renterFilesDeleteCmd = &cobra.Command{
 		Use:     "delete [path]",
 		Aliases: []string{"rm"},
@@ -94,8 +173,42 @@ var (
 	renterFilesUploadCmd = &cobra.Command{
 		Use:   "upload [source] [path]",
 		Short: "Upload a file",
-		Long:  "Upload a file to [path] on the Sia network.",
-		Run:   wrap(renterfilesuploadcmd),
+		Long: `Upload a file to [path] on the Sia network.
+The erasure coder and its parameters can be selected with the --erasurecoder,
+--datapieces and --paritypieces flags. Replication requires exactly one data
+piece and stores 1 + paritypieces full copies of the file. With --dedup, chunks
+that are identical to chunks of other deduplicated files are only stored once.
+The cipher used to encrypt the file can be selected with --cipher.`,
+		Run: wrap(renterfilesuploadcmd),
+	}
+
+	renterLoadShareCmd = &cobra.Command{
+		Use:   "loadshare [share]",
+		Short: "Load files shared by another renter",
+		Long: `Load the files of a share created with 'siac renter share' by another renter.
+The files are downloaded with the download tickets of the share, paying for the
+bandwidth with the renter's own contracts. Files can only be downloaded from the
+hosts that the renter has contracts with, and only until the tickets expire.
+Loaded files are read-only.`,
+		Run: wrap(renterloadsharecmd),
+	}
+
+	renterMountCmd = &cobra.Command{
+		Use:   "mount [path] [siapath]",
+		Short: "Mount the renter's files as a local filesystem",
+		Long: `Mount the directory [siapath] of the renter read-only at [path], which must be
+an existing, empty directory. If [siapath] is omitted, all files are mounted.
+The filesystem is served by siad until it is unmounted with 'siac renter
+unmount' or siad shuts down. Files are read through streams, so reading a file
+sequentially fetches the following chunks ahead of time. Mounting requires FUSE.`,
+		Run: rentermountcmd,
+	}
+
+	renterMountsCmd = &cobra.Command{
+		Use:   "mounts",
+		Short: "View the mounted filesystems",
+		Long:  "View the filesystems mounted with 'siac renter mount'.",
+		Run:   wrap(rentermountscmd),
 	}
 
 	renterPricesCmd = &cobra.Command{
@@ -105,6 +218,57 @@ var (
 		Run:   wrap(renterpricescmd),
 	}
 
+	renterReencryptCmd = &cobra.Command{
+		Use:   "reencrypt [path]",
+		Short: "Re-encrypt a file under a new key",
+		Long: `Re-upload the file at [path] in the background, encrypted under a new key and
+the cipher selected with --cipher (twofish-gcm or xchacha20-poly1305). Without
+--cipher, the cipher of the file is kept. The old copy of the file remains
+readable until the new copy has been uploaded.`,
+		Run: wrap(renterreencryptcmd),
+	}
+
+	renterReencryptionsCmd = &cobra.Command{
+		Use:   "reencryptions",
+		Short: "View the re-encryptions in progress",
+		Long:  "View the files that are being re-encrypted and the re-encryptions that failed.",
+		Run:   wrap(renterreencryptionscmd),
+	}
+
+	renterShareCmd = &cobra.Command{
+		Use:   "share [duration] [path]...",
+		Short: "Share files with other renters",
+		Long: `Create a share of the files at [path] that other renters can load with
+'siac renter loadshare'. The share contains signed download tickets that allow
+the hosts of the files to serve them to other renters for [duration], or until
+the contracts of the files end. The duration is specified in blocks (b), hours
+(h), days (d) or weeks (w), e.g. "1w". Anyone who obtains the share can
+download the files, so it should be treated like a password.`,
+		Run: rentersharecmd,
+	}
+
+	renterRecoverCmd = &cobra.Command{
+		Use:   "recover",
+		Short: "Recover the renter from the wallet seed",
+		Long: `Scan the blockchain for the contracts formed with the wallet seed, recover the
+unexpired ones from their hosts, and restore the newest backup that was
+uploaded to the hosts with 'siac renter backup --remote'. The wallet must be
+unlocked and the blockchain must be synced. Only contracts formed since
+contracts carry a seed-derived identifier can be recovered, and their hosts
+must support contract recovery.`,
+		Run: wrap(renterrecovercmd),
+	}
+
+	renterRestoreCmd = &cobra.Command{
+		Use:   "restore [source]",
+		Short: "Restore the renter from a backup",
+		Long: `Restore the files, contracts and settings contained in a backup created with
+'siac renter backup'. The wallet must have been initialized with the seed of
+the renter that created the backup and it must be unlocked. Files that already
+exist are skipped, and the settings are only restored if no allowance is set.`,
+		Run: wrap(renterrestorecmd),
+	}
+
 	renterSetAllowanceCmd = &cobra.Command{
 		Use:   "setallowance [amount] [period] [hosts] [renew window]",
 		Short: "Set the allowance",
@@ -124,18 +288,70 @@ Allowance can be automatically renewed periodically. If the current
 blockheight + the renew window >= the end height the contract,
 then the contract is renewed automatically.
 
+The expected storage, upload and download volumes can be set with flags. They
+are used to size the contracts. Hard caps on the money spent on storage, upload
+and download per period and on the storage price per TB per month can be set
+as well; hosts whose prices exceed the caps are refused. Values that aren't
+set are kept, and a cap of 0 removes it.
+
 Note that setting the allowance will cause siad to immediately begin forming
 contracts! You should only set the allowance once you are fully synced and you
 have a reasonable number (>30) of hosts in your hostdb.`,
 		Run: rentersetallowancecmd,
 	}
 
+	renterSpendingCmd = &cobra.Command{
+		Use:   "spending [prefix]",
+		Short: "View the spending per file",
+		Long: `View the money spent on uploading, storing and downloading each file, per
+allowance period. If [prefix] is given, only the files whose siapath starts with
+[prefix] are shown, along with their total. Use --csv to export the ledger.`,
+		Run: renterspendingcmd,
+	}
+
+	renterStreamsCmd = &cobra.Command{
+		Use:   "streams",
+		Short: "View the open streams",
+		Long:  "View the files currently being streamed through the /renter/stream endpoint.",
+		Run:   wrap(renterstreamscmd),
+	}
+
+	renterUnmountCmd = &cobra.Command{
+		Use:   "unmount [path]",
+		Short: "Unmount a filesystem",
+		Long:  "Unmount the filesystem mounted at [path] with 'siac renter mount'.",
+		Run:   wrap(renterunmountcmd),
+	}
+
 	renterUploadsCmd = &cobra.Command{
 		Use:   "uploads",
 		Short: "View the upload queue",
 		Long:  "View the list of files currently uploading.",
 		Run:   wrap(renteruploadscmd),
 	}
+
+	renterUploadsCancelCmd = &cobra.Command{
+		Use:   "cancel [id]",
+		Short: "Cancel an upload",
+		Long: `Cancel the upload with the given ID and delete the file. Uploads that have
+completed can't be cancelled. The IDs are listed by 'siac renter uploads'.`,
+		Run: wrap(renteruploadscancelcmd),
+	}
+
+	renterUploadsPauseCmd = &cobra.Command{
+		Use:   "pause [id]",
+		Short: "Pause an upload",
+		Long: `Pause the upload with the given ID. The file is neither uploaded nor repaired
+until the upload is resumed. The IDs are listed by 'siac renter uploads'.`,
+		Run: wrap(renteruploadspausecmd),
+	}
+
+	renterUploadsResumeCmd = &cobra.Command{
+		Use:   "resume [id]",
+		Short: "Resume a paused upload",
+		Long:  "Resume the paused upload with the given ID.",
+		Run:   wrap(renteruploadsresumecmd),
+	}
 )
 
 // abs returns the absolute representation of a path.
@@ -187,6 +403,19 @@ func rentercmd() {
 		currencyUnits(fm.Unspent), currencyUnits(unspentAllocated),
 		currencyUnits(unspentUnallocated))
 
+	// Show the dedup savings if there are any deduplicated files.
+	ds := rg.DedupStats
+	if ds.Files > 0 {
+		fmt.Printf(`Deduplication:
+	Files:             %v
+	Chunks:            %v (%v unique)
+	Stored:            %v
+	Saved:             %v
+
+`, ds.Files, ds.Chunks, ds.UniqueChunks, filesizeUnits(int64(ds.StoredBytes)),
+			filesizeUnits(int64(ds.SavedBytes)))
+	}
+
 	// also list files
 	renterfileslistcmd()
 }
@@ -217,10 +446,41 @@ func renteruploadscmd() {
 	}
 	fmt.Println("Uploading", len(filteredFiles), "files:")
 	for _, file := range filteredFiles {
-		fmt.Printf("%13s  %s (uploading, %0.2f%%)\n", filesizeUnits(int64(file.Filesize)), file.SiaPath, file.UploadProgress)
+		status := "uploading"
+		if file.UploadPaused {
+			status = "paused"
+		}
+		fmt.Printf("%13s  %s  %s (%s, %0.2f%%)\n", filesizeUnits(int64(file.Filesize)), file.UploadID, file.SiaPath, status, file.UploadProgress)
 	}
 }
 
+// renteruploadscancelcmd is the handler for the command `siac renter uploads
+// cancel [id]`.
+func renteruploadscancelcmd(id string) {
+	if err := httpClient.RenterUploadCancelPost(id); err != nil {
+		die("Could not cancel upload:", err)
+	}
+	fmt.Println("Cancelled upload", id)
+}
+
+// renteruploadspausecmd is the handler for the command `siac renter uploads
+// pause [id]`.
+func renteruploadspausecmd(id string) {
+	if err := httpClient.RenterUploadPausePost(id); err != nil {
+		die("Could not pause upload:", err)
+	}
+	fmt.Println("Paused upload", id)
+}
+
+// renteruploadsresumecmd is the handler for the command `siac renter uploads
+// resume [id]`.
+func renteruploadsresumecmd(id string) {
+	if err := httpClient.RenterUploadResumePost(id); err != nil {
+		die("Could not resume upload:", err)
+	}
+	fmt.Println("Resumed upload", id)
+}
+
 // renterdownloadscmd is the handler for the command `siac renter downloads`.
 // Lists files currently downloading, and optionally previously downloaded
 // files if the -H or --history flag is specified.
@@ -241,7 +501,7 @@ func renterdownloadscmd() {
 	} else {
 		fmt.Println("Downloading", len(downloading), "files:")
 		for _, file := range downloading {
-			fmt.Printf("%s: %5.1f%% %s -> %s\n", file.StartTime.Format("Jan 02 03:04 PM"), 100*float64(file.Received)/float64(file.Filesize), file.SiaPath, file.Destination)
+			fmt.Printf("%s: %s %5.1f%% %s -> %s\n", file.StartTime.Format("Jan 02 03:04 PM"), file.ID, 100*float64(file.Received)/float64(file.Filesize), file.SiaPath, file.Destination)
 		}
 	}
 	if !renterShowHistory {
@@ -265,6 +525,174 @@ func renterdownloadscmd() {
 	}
 }
 
+// renterdownloadscancelcmd is the handler for the command `siac renter
+// downloads cancel [id]`.
+func renterdownloadscancelcmd(id string) {
+	if err := httpClient.RenterDownloadCancelPost(id); err != nil {
+		die("Could not cancel download:", err)
+	}
+	fmt.Println("Cancelled download", id)
+}
+
+// renterreencryptcmd is the handler for the command `siac renter reencrypt
+// [path]`.
+func renterreencryptcmd(path string) {
+	if err := httpClient.RenterReencryptPost(path, crypto.CipherType(renterReencryptCipher)); err != nil {
+		die("Could not re-encrypt file:", err)
+	}
+	fmt.Printf("Re-encrypting %s.\n", path)
+}
+
+// renterreencryptionscmd is the handler for the command `siac renter
+// reencryptions`. It lists the re-encryptions along with their progress.
+func renterreencryptionscmd() {
+	rr, err := httpClient.RenterReencryptionsGet()
+	if err != nil {
+		die("Could not get re-encryptions:", err)
+	}
+	if len(rr.Reencryptions) == 0 {
+		fmt.Println("No files are being re-encrypted.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Path\tCipher\tProgress\tError")
+	for _, re := range rr.Reencryptions {
+		fmt.Fprintf(w, "%v\t%v\t%.2f%%\t%v\n", re.SiaPath, re.CipherType, re.Progress, re.Error)
+	}
+	w.Flush()
+}
+
+// rentersharecmd is the handler for the command `siac renter share
+// [duration] [path]...`. It prints a share of the files that contains download
+// tickets for other renters.
+func rentersharecmd(cmd *cobra.Command, args []string) {
+	if len(args) < 2 {
+		cmd.UsageFunc()(cmd)
+		os.Exit(exitCodeUsage)
+	}
+	blocks, err := parsePeriod(args[0])
+	if err != nil {
+		die("Could not parse duration:", err)
+	}
+	var duration types.BlockHeight
+	if _, err := fmt.Sscan(blocks, &duration); err != nil {
+		die("Could not parse duration:", err)
+	}
+	rts, err := httpClient.RenterShareTicketPost(args[1:], duration)
+	if err != nil {
+		die("Could not share files:", err)
+	}
+	fmt.Println(rts.Share)
+}
+
+// renterloadsharecmd is the handler for the command `siac renter loadshare
+// [share]`.
+func renterloadsharecmd(share string) {
+	rl, err := httpClient.RenterLoadTicketPost(share)
+	if err != nil {
+		die("Could not load share:", err)
+	}
+	fmt.Printf("Loaded %d file(s):\n", len(rl.FilesAdded))
+	for _, siaPath := range rl.FilesAdded {
+		fmt.Println(" ", siaPath)
+	}
+}
+
+// renterstreamscmd is the handler for the command `siac renter streams`.
+// It lists the open streams along with their statistics.
+func renterstreamscmd() {
+	rs, err := httpClient.RenterStreamsGet()
+	if err != nil {
+		die("Could not get streams:", err)
+	}
+	if len(rs.Streams) == 0 {
+		fmt.Println("No files are being streamed.")
+		return
+	}
+	fmt.Println("Streaming", len(rs.Streams), "files:")
+	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Opened\tPath\tOffset\tRead\tPrefetch\tChunks Fetched\tPrefetch Hits")
+	for _, s := range rs.Streams {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			s.Opened.Format("Jan 02 03:04 PM"),
+			s.SiaPath,
+			filesizeUnits(int64(s.Offset)),
+			filesizeUnits(int64(s.BytesRead)),
+			s.Prefetch,
+			s.ChunksFetched,
+			s.PrefetchHits)
+	}
+	w.Flush()
+}
+
+// renterspendingcmd is the handler for the command `siac renter spending
+// [prefix]`. Lists the spending per file and period, as a table or as CSV.
+func renterspendingcmd(cmd *cobra.Command, args []string) {
+	if len(args) > 1 {
+		cmd.UsageFunc()(cmd)
+		os.Exit(exitCodeUsage)
+	}
+	var prefix string
+	if len(args) == 1 {
+		prefix = args[0]
+	}
+	var since, until types.BlockHeight
+	if renterSpendingCurrent {
+		rg, err := httpClient.RenterGet()
+		if err != nil {
+			die("Could not get the current period:", err)
+		}
+		since, until = rg.CurrentPeriod, rg.CurrentPeriod
+	}
+	rs, err := httpClient.RenterSpendingGet(prefix, since, until)
+	if err != nil {
+		die("Could not get spending:", err)
+	}
+
+	// The CSV contains the exact amounts in hastings.
+	if renterSpendingCSV {
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"siapath", "periodstart", "uploadspending", "storagespending", "downloadspending", "totalspending"})
+		for _, fs := range rs.Files {
+			w.Write([]string{
+				fs.SiaPath,
+				fmt.Sprint(fs.PeriodStart),
+				fs.UploadSpending.String(),
+				fs.StorageSpending.String(),
+				fs.DownloadSpending.String(),
+				fs.Total().String(),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			die("Could not write CSV:", err)
+		}
+		return
+	}
+
+	if len(rs.Files) == 0 {
+		fmt.Println("No spending recorded.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Path\tPeriod\tUpload\tStorage\tDownload\tTotal")
+	for _, fs := range rs.Files {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n",
+			fs.SiaPath,
+			fs.PeriodStart,
+			currencyUnits(fs.UploadSpending),
+			currencyUnits(fs.StorageSpending),
+			currencyUnits(fs.DownloadSpending),
+			currencyUnits(fs.Total()))
+	}
+	fmt.Fprintf(w, "Total\t\t%v\t%v\t%v\t%v\n",
+		currencyUnits(rs.Total.UploadSpending),
+		currencyUnits(rs.Total.StorageSpending),
+		currencyUnits(rs.Total.DownloadSpending),
+		currencyUnits(rs.Total.Total()))
+	w.Flush()
+}
+
 // renterallowancecmd displays the current allowance.
 func renterallowancecmd() {
 	rg, err := httpClient.RenterGet()
@@ -278,6 +706,27 @@ func renterallowancecmd() {
 	Amount: %v
 	Period: %v blocks
 `, currencyUnits(allowance.Funds), allowance.Period)
+	if allowance.ExpectedStorage != 0 || allowance.ExpectedUpload != 0 || allowance.ExpectedDownload != 0 {
+		fmt.Printf(`Expected Usage:
+	Storage:  %v
+	Upload:   %v
+	Download: %v
+`, filesizeUnits(int64(allowance.ExpectedStorage)), filesizeUnits(int64(allowance.ExpectedUpload)),
+			filesizeUnits(int64(allowance.ExpectedDownload)))
+	}
+	capUnits := func(c types.Currency) string {
+		if c.IsZero() {
+			return "none"
+		}
+		return currencyUnits(c)
+	}
+	fmt.Printf(`Spending Caps:
+	Storage:      %v
+	Upload:       %v
+	Download:     %v
+	Price per TB: %v
+`, capUnits(allowance.MaxStorageSpending), capUnits(allowance.MaxUploadSpending),
+		capUnits(allowance.MaxDownloadSpending), capUnits(allowance.MaxPricePerTB))
 }
 
 // renterallowancecancelcmd cancels the current allowance.
@@ -304,6 +753,113 @@ again:
 	fmt.Println("Allowance canceled.")
 }
 
+// renterbackupcmd is the handler for the command `siac renter backup
+// [destination]`. Writes a backup of the renter to destination, or uploads it
+// to the renter's hosts if --remote is set.
+func renterbackupcmd(cmd *cobra.Command, args []string) {
+	if renterBackupRemote {
+		if len(args) != 0 {
+			cmd.UsageFunc()(cmd)
+			os.Exit(exitCodeUsage)
+		}
+		if err := httpClient.RenterBackupRemotePost(); err != nil {
+			die("Could not upload backup:", err)
+		}
+		fmt.Println("Uploaded backup to the renter's hosts")
+		return
+	}
+	if len(args) != 1 {
+		cmd.UsageFunc()(cmd)
+		os.Exit(exitCodeUsage)
+	}
+	destination := abs(args[0])
+	err := httpClient.RenterBackupPost(destination)
+	if err != nil {
+		die("Could not create backup:", err)
+	}
+	fmt.Println("Wrote backup to", destination)
+}
+
+// rentermountcmd is the handler for the command `siac renter mount [path]
+// [siapath]`. Mounts a directory of the renter as a local filesystem.
+func rentermountcmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 && len(args) != 2 {
+		cmd.UsageFunc()(cmd)
+		os.Exit(exitCodeUsage)
+	}
+	var siaPath string
+	if len(args) == 2 {
+		siaPath = args[1]
+	}
+	opts := modules.MountOptions{
+		SiaPath:   siaPath,
+		Prefetch:  renter.DefaultStreamPrefetch,
+		CacheSize: renterMountCacheSize,
+	}
+	if cmd.Flags().Changed("prefetch") {
+		opts.Prefetch = renterMountPrefetch
+	}
+	mountPoint := abs(args[0])
+	err := httpClient.RenterMountOptionsPost(mountPoint, opts)
+	if err != nil {
+		die("Could not mount filesystem:", err)
+	}
+	fmt.Println("Mounted", "/"+siaPath, "at", mountPoint)
+}
+
+// rentermountscmd is the handler for the command `siac renter mounts`. Lists
+// the mounted filesystems.
+func rentermountscmd() {
+	rm, err := httpClient.RenterMountsGet()
+	if err != nil {
+		die("Could not get mounted filesystems:", err)
+	}
+	if len(rm.Mounts) == 0 {
+		fmt.Println("No filesystems are mounted.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Mount Point\tPath\tPrefetch\tCache\tMounted")
+	for _, m := range rm.Mounts {
+		cache := "shared"
+		if m.CacheSize > 0 {
+			cache = fmt.Sprintf("%v chunks", m.CacheSize)
+		}
+		fmt.Fprintf(w, "%v\t/%v\t%v\t%v\t%v\n", m.MountPoint, m.SiaPath, m.Prefetch, cache, m.Mounted.Format("Jan 02 03:04 PM"))
+	}
+	w.Flush()
+}
+
+// renterunmountcmd is the handler for the command `siac renter unmount
+// [path]`. Unmounts a filesystem mounted with `siac renter mount`.
+func renterunmountcmd(path string) {
+	mountPoint := abs(path)
+	if err := httpClient.RenterUnmountPost(mountPoint); err != nil {
+		die("Could not unmount filesystem:", err)
+	}
+	fmt.Println("Unmounted", mountPoint)
+}
+
+// renterrecovercmd is the handler for the command `siac renter recover`.
+// Recovers the renter's contracts and files from the wallet seed.
+func renterrecovercmd() {
+	rr, err := httpClient.RenterRecoverPost()
+	if err != nil {
+		die("Could not recover renter:", err)
+	}
+	fmt.Printf("Recovered %v contracts and %v files.\n", rr.Contracts, len(rr.Files))
+}
+
+// renterrestorecmd is the handler for the command `siac renter restore
+// [source]`. Restores the renter from the backup at source.
+func renterrestorecmd(source string) {
+	rr, err := httpClient.RenterRestorePost(abs(source))
+	if err != nil {
+		die("Could not restore backup:", err)
+	}
+	fmt.Printf("Restored %v files and %v contracts.\n", len(rr.Files), rr.Contracts)
+}
+
 // rentersetallowancecmd allows the user to set the allowance.
 // the first two parameters, amount and period, are required.
 // the second two parameters are optional:
@@ -349,6 +905,64 @@ func rentersetallowancecmd(cmd *cobra.Command, args []string) {
 			die("Could not parse renew window:", err)
 		}
 	}
+
+	// The expected usage and the spending caps are kept unless they are set
+	// by a flag.
+	rg, err := httpClient.RenterGet()
+	if err != nil {
+		die("Could not get allowance:", err)
+	}
+	current := rg.Settings.Allowance
+	allowance.ExpectedStorage = current.ExpectedStorage
+	allowance.ExpectedUpload = current.ExpectedUpload
+	allowance.ExpectedDownload = current.ExpectedDownload
+	allowance.MaxStorageSpending = current.MaxStorageSpending
+	allowance.MaxUploadSpending = current.MaxUploadSpending
+	allowance.MaxDownloadSpending = current.MaxDownloadSpending
+	allowance.MaxPricePerTB = current.MaxPricePerTB
+	for _, f := range []struct {
+		flag  string
+		field *uint64
+	}{
+		{renterAllowanceExpectedStorage, &allowance.ExpectedStorage},
+		{renterAllowanceExpectedUpload, &allowance.ExpectedUpload},
+		{renterAllowanceExpectedDownload, &allowance.ExpectedDownload},
+	} {
+		if f.flag == "" {
+			continue
+		}
+		size, err := parseFilesize(f.flag)
+		if err != nil {
+			die("Could not parse expected usage:", err)
+		}
+		if _, err = fmt.Sscan(size, f.field); err != nil {
+			die("Could not parse expected usage:", err)
+		}
+	}
+	for _, f := range []struct {
+		flag  string
+		field *types.Currency
+	}{
+		{renterAllowanceMaxStorageSpending, &allowance.MaxStorageSpending},
+		{renterAllowanceMaxUploadSpending, &allowance.MaxUploadSpending},
+		{renterAllowanceMaxDownloadSpending, &allowance.MaxDownloadSpending},
+		{renterAllowanceMaxPricePerTB, &allowance.MaxPricePerTB},
+	} {
+		if f.flag == "" {
+			continue
+		}
+		hastings := f.flag
+		if hastings != "0" {
+			hastings, err = parseCurrency(f.flag)
+			if err != nil {
+				die("Could not parse spending cap:", err)
+			}
+		}
+		if _, err = fmt.Sscan(hastings, f.field); err != nil {
+			die("Could not parse spending cap:", err)
+		}
+	}
+
 	err = httpClient.RenterPostAllowance(allowance)
 	if err != nil {
 		die("Could not set allowance:", err)
@@ -398,6 +1012,111 @@ func rentercontractscmd() {
 			c.GoodForRenew)
 	}
 	w.Flush()
+	printDiversityViolations(rc.DiversityViolations)
+}
+
+// renterchunkcachecmd is the handler for the command `siac renter chunkcache
+// [size]`. It shows the statistics of the chunk cache and optionally changes
+// its capacity.
+func renterchunkcachecmd(cmd *cobra.Command, args []string) {
+	if len(args) > 1 {
+		cmd.UsageFunc()(cmd)
+		os.Exit(exitCodeUsage)
+	}
+	if len(args) == 1 {
+		size := "0"
+		if args[0] != "0" {
+			var err error
+			size, err = parseFilesize(args[0])
+			if err != nil {
+				die("Could not parse size:", err)
+			}
+		}
+		capacity, err := strconv.ParseUint(size, 10, 64)
+		if err != nil {
+			die("Could not parse size:", err)
+		}
+		if err := httpClient.RenterSetChunkCacheSizePost(capacity); err != nil {
+			die("Could not set the chunk cache size:", err)
+		}
+	}
+
+	rg, err := httpClient.RenterGet()
+	if err != nil {
+		die("Could not get the chunk cache:", err)
+	}
+	capacity := "disabled"
+	if rg.Settings.ChunkCacheSize > 0 {
+		capacity = filesizeUnits(int64(rg.Settings.ChunkCacheSize))
+	}
+	cc := rg.ChunkCache
+	fmt.Printf(`Chunk cache:
+  Capacity:  %v
+  Cached:    %v chunks, %v
+  Hits:      %v
+  Misses:    %v
+  Corrupted: %v
+`, capacity, cc.Chunks, filesizeUnits(int64(cc.Size)), cc.Hits, cc.Misses, cc.Corrupted)
+}
+
+// renterdiversitycmd is the handler for the command `siac renter diversity`.
+// It shows or changes the host diversity policy of the renter.
+func renterdiversitycmd(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.UsageFunc()(cmd)
+		os.Exit(exitCodeUsage)
+	}
+	rg, err := httpClient.RenterGet()
+	if err != nil {
+		die("Could not get renter settings:", err)
+	}
+	subnets, geoIP := rg.Settings.SubnetDiversity, rg.Settings.GeoIPDatabase
+	flags := cmd.Flags()
+	if flags.Changed("subnets") || flags.Changed("geoip") {
+		if flags.Changed("subnets") {
+			subnets = renterDiversitySubnets
+		}
+		if flags.Changed("geoip") {
+			geoIP = renterDiversityGeoIP
+			if geoIP != "" {
+				geoIP = abs(geoIP)
+			}
+		}
+		if err := httpClient.RenterSetDiversityPost(subnets, geoIP); err != nil {
+			die("Could not set the diversity policy:", err)
+		}
+		fmt.Println("Diversity policy updated.")
+	}
+
+	region := "disabled"
+	if geoIP != "" {
+		region = "enabled (" + geoIP + ")"
+	}
+	fmt.Printf(`Diversity policy:
+  Subnet diversity: %v
+  Region diversity: %v
+`, yesNo(subnets), region)
+
+	rc, err := httpClient.RenterContractsGet()
+	if err != nil {
+		die("Could not get contracts:", err)
+	}
+	printDiversityViolations(rc.DiversityViolations)
+}
+
+// printDiversityViolations prints a warning for each group of hosts that
+// violates the diversity policy.
+func printDiversityViolations(violations []modules.HostDiversityViolation) {
+	for _, v := range violations {
+		where := "subnet " + v.Subnet
+		if v.Region != "" {
+			where = "region " + v.Region
+		}
+		fmt.Printf("Warning: %v contract hosts are in the same %v:\n", len(v.Hosts), where)
+		for _, spk := range v.Hosts {
+			fmt.Println("  " + spk.String())
+		}
+	}
 }
 
 // rentercontractsviewcmd is the handler for the command `siac renter contracts <id>`.
@@ -457,14 +1176,86 @@ func renterfilesdeletecmd(path string) {
 	fmt.Println("Deleted", path)
 }
 
+// renterdircmd is the handler for the command `siac renter dir [path]`. Lists
+// the subdirectories and files of a directory.
+func renterdircmd(cmd *cobra.Command, args []string) {
+	var path string
+	switch len(args) {
+	case 0:
+	case 1:
+		path = args[0]
+	default:
+		cmd.UsageFunc()(cmd)
+		os.Exit(exitCodeUsage)
+	}
+	rd, err := httpClient.RenterDirGet(path)
+	if err != nil {
+		die("Could not get directory:", err)
+	}
+	dir := rd.Directories[0]
+	fmt.Printf("%v: %v files in %v subdirectories, %v total\n", "/"+dir.SiaPath,
+		dir.AggregateNumFiles, len(rd.Directories)-1, filesizeUnits(int64(dir.AggregateSize)))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Size\tFiles\tRedundancy\tHealth\tSia path")
+	for _, d := range rd.Directories[1:] {
+		redundancyStr := fmt.Sprintf("%.2f", d.MinRedundancy)
+		if d.MinRedundancy == -1 {
+			redundancyStr = "-"
+		}
+		fmt.Fprintf(w, "%9s\t%v\t%10s\t%6.2f\t%s/\n", filesizeUnits(int64(d.AggregateSize)), d.AggregateNumFiles, redundancyStr, d.Health, d.SiaPath)
+	}
+	for _, file := range rd.Files {
+		redundancyStr := fmt.Sprintf("%.2f", file.Redundancy)
+		if file.Redundancy == -1 {
+			redundancyStr = "-"
+		}
+		fmt.Fprintf(w, "%9s\t%v\t%10s\t%6.2f\t%s\n", filesizeUnits(int64(file.Filesize)), "-", redundancyStr, file.Health, file.SiaPath)
+	}
+	w.Flush()
+}
+
+// renterdircreatecmd is the handler for the command `siac renter dir create
+// [path]`. Creates a directory.
+func renterdircreatecmd(path string) {
+	err := httpClient.RenterDirCreatePost(path)
+	if err != nil {
+		die("Could not create directory:", err)
+	}
+	fmt.Println("Created", path)
+}
+
+// renterdirdeletecmd is the handler for the command `siac renter dir delete
+// [path]`. Deletes a directory and everything it contains.
+func renterdirdeletecmd(path string) {
+	err := httpClient.RenterDirDeletePost(path)
+	if err != nil {
+		die("Could not delete directory:", err)
+	}
+	fmt.Println("Deleted", path)
+}
+
+// renterdirrenamecmd is the handler for the command `siac renter dir rename
+// [path] [newpath]`. Renames a directory.
+func renterdirrenamecmd(path, newpath string) {
+	err := httpClient.RenterDirRenamePost(path, newpath)
+	if err != nil {
+		die("Could not rename directory:", err)
+	}
+	fmt.Printf("Renamed %s to %s\n", path, newpath)
+}
+
 // renterfilesdownloadcmd is the handler for the comand `siac renter download [path] [destination]`.
 // Downloads a path from the Sia network to the local specified destination.
 func renterfilesdownloadcmd(path, destination string) {
 	destination = abs(destination)
+	priority, err := modules.ParsePriority(renterDownloadPriority)
+	if err != nil {
+		die("Could not parse priority:", err)
+	}
 	done := make(chan struct{})
 	go downloadprogress(done, path)
 
-	err := httpClient.RenterDownloadFullGet(path, destination, false)
+	err = httpClient.RenterDownloadPriorityGet(path, destination, priority)
 	close(done)
 	if err != nil {
 		die("Could not download file:", err)
@@ -527,7 +1318,7 @@ func renterfileslistcmd() {
 	fmt.Println("Tracking", len(rf.Files), "files:")
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	if renterListVerbose {
-		fmt.Fprintln(w, "File size\tAvailable\tUploaded\tProgress\tRedundancy\tRenewing\tSia path")
+		fmt.Fprintln(w, "File size\tAvailable\tUploaded\tProgress\tRedundancy\tHealth\tStuck\tRenewing\tSia path")
 	}
 	sort.Sort(bySiaPath(rf.Files))
 	for _, file := range rf.Files {
@@ -543,7 +1334,8 @@ func renterfileslistcmd() {
 			if file.UploadProgress == -1 {
 				uploadProgressStr = "-"
 			}
-			fmt.Fprintf(w, "\t%s\t%9s\t%8s\t%10s\t%s", availableStr, filesizeUnits(int64(file.UploadedBytes)), uploadProgressStr, redundancyStr, renewingStr)
+			healthStr := fmt.Sprintf("%.2f", file.Health)
+			fmt.Fprintf(w, "\t%s\t%9s\t%8s\t%10s\t%6s\t%5v\t%s", availableStr, filesizeUnits(int64(file.UploadedBytes)), uploadProgressStr, redundancyStr, healthStr, file.StuckChunks, renewingStr)
 		}
 		fmt.Fprintf(w, "\t%s", file.SiaPath)
 		if !renterListVerbose && !file.Available {
@@ -597,7 +1389,7 @@ func renterfilesuploadcmd(source, path string) {
 			fpath, _ := filepath.Rel(source, file)
 			fpath = filepath.Join(path, fpath)
 			fpath = filepath.ToSlash(fpath)
-			err = httpClient.RenterUploadDefaultPost(abs(file), fpath)
+			err = renterUpload(abs(file), fpath)
 			if err != nil {
 				die("Could not upload file:", err)
 			}
@@ -605,7 +1397,7 @@ func renterfilesuploadcmd(source, path string) {
 		fmt.Printf("Uploaded %d files into '%s'.\n", len(files), path)
 	} else {
 		// single file
-		err = httpClient.RenterUploadDefaultPost(abs(source), path)
+		err = renterUpload(abs(source), path)
 		if err != nil {
 			die("Could not upload file:", err)
 		}
@@ -613,6 +1405,44 @@ func renterfilesuploadcmd(source, path string) {
 	}
 }
 
+// renterUpload uploads the file at source to path, using the erasure coder,
+// deduplication and cipher selected by the upload flags.
+func renterUpload(source, path string) error {
+	if renterUploadCipher != "" {
+		if renterUploadDedup || renterUploadPriority != "" {
+			return errors.New("--cipher can't be combined with --dedup or --priority")
+		}
+		var coder types.Specifier
+		copy(coder[:], renterUploadCoder)
+		_, err := httpClient.RenterUploadCipherPost(source, path, coder, renterUploadDataPieces, renterUploadParity, crypto.CipherType(renterUploadCipher))
+		return err
+	}
+	if renterUploadPriority != "" {
+		priority, err := modules.ParsePriority(renterUploadPriority)
+		if err != nil {
+			return err
+		}
+		var coder types.Specifier
+		copy(coder[:], renterUploadCoder)
+		_, err = httpClient.RenterUploadPriorityPost(source, path, coder, renterUploadDataPieces, renterUploadParity, renterUploadDedup, priority)
+		return err
+	}
+	if renterUploadDedup {
+		var coder types.Specifier
+		copy(coder[:], renterUploadCoder)
+		return httpClient.RenterUploadDedupPost(source, path, coder, renterUploadDataPieces, renterUploadParity)
+	}
+	if renterUploadCoder == "" && renterUploadDataPieces == 0 && renterUploadParity == 0 {
+		return httpClient.RenterUploadDefaultPost(source, path)
+	}
+	coder := modules.ErasureCoderReedSolomon
+	if renterUploadCoder != "" {
+		coder = types.Specifier{}
+		copy(coder[:], renterUploadCoder)
+	}
+	return httpClient.RenterUploadErasureCoderPost(source, path, coder, renterUploadDataPieces, renterUploadParity)
+}
+
 // renterpricescmd is the handler for the command `siac renter prices`, which
 // displays the prices of various storage operations.
 func renterpricescmd() {
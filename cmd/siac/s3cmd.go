@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	s3Cmd = &cobra.Command{
+		Use:   "s3",
+		Short: "Perform S3 gateway actions",
+		Long:  "View the address and the access keys of the S3 gateway.",
+		Run:   wrap(s3cmd),
+	}
+
+	s3AddKeyCmd = &cobra.Command{
+		Use:   "addkey",
+		Short: "Generate a new access key",
+		Long:  "Generate a new access key for the S3 gateway.",
+		Run:   wrap(s3addkeycmd),
+	}
+
+	s3RemoveKeyCmd = &cobra.Command{
+		Use:   "removekey [accesskeyid]",
+		Short: "Remove an access key",
+		Long:  "Remove an access key of the S3 gateway. The last access key can't be removed.",
+		Run:   wrap(s3removekeycmd),
+	}
+)
+
+// s3cmd is the handler for the command `siac s3`.
+// Prints the address and the access keys of the S3 gateway.
+func s3cmd() {
+	sg, err := httpClient.S3Get()
+	if err != nil {
+		die("Could not get S3 gateway info:", err)
+	}
+	fmt.Printf("S3 gateway listening on %v\n\n", sg.Address)
+	fmt.Println("Access Keys:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  Access Key ID\tSecret Access Key")
+	for _, key := range sg.Keys {
+		fmt.Fprintf(w, "  %v\t%v\n", key.AccessKeyID, key.SecretAccessKey)
+	}
+	w.Flush()
+}
+
+// s3addkeycmd is the handler for the command `siac s3 addkey`.
+// Generates a new access key for the S3 gateway.
+func s3addkeycmd() {
+	key, err := httpClient.S3KeysAddPost()
+	if err != nil {
+		die("Could not add access key:", err)
+	}
+	fmt.Printf("Access Key ID:     %v\nSecret Access Key: %v\n", key.AccessKeyID, key.SecretAccessKey)
+}
+
+// s3removekeycmd is the handler for the command `siac s3 removekey
+// [accesskeyid]`. Removes an access key of the S3 gateway.
+func s3removekeycmd(accessKeyID string) {
+	if err := httpClient.S3KeysRemovePost(accessKeyID); err != nil {
+		die("Could not remove access key:", err)
+	}
+	fmt.Println("Removed access key", accessKeyID)
+}
@@ -47,6 +47,9 @@ func TestUnitProcessModules(t *testing.T) {
 		{"T", "t"},
 		{"W", "w"},
 		{"E", "e"},
+		{"s", "s"},
+		{"S", "s"},
+		{"gctwrs", "gctwrs"},
 	}
 	for _, testVal := range testVals {
 		out, err := processModules(testVal.in)
@@ -59,7 +62,7 @@ func TestUnitProcessModules(t *testing.T) {
 	}
 
 	// Test invalid modules.
-	invalidModules := []string{"abdfijklnopqsuvxyz", "cghmrtwez", "cz", "z", "cc", "ccz", "ccm", "cmm", "ccmm"}
+	invalidModules := []string{"abdfijklnopquvxyz", "cghmrtwesz", "cz", "z", "cc", "ccz", "ccm", "cmm", "ccmm"}
 	for _, invalidModule := range invalidModules {
 		_, err := processModules(invalidModule)
 		if err == nil {
@@ -96,7 +99,7 @@ func TestUnitProcessProfile(t *testing.T) {
 	}
 
 	// Test invalid modules.
-	invalidProfiles := []string{"abdfijklnopqsuvxyz", "cghmrtwez", "cz", "z", "cc", "ccz", "ccm", "cmm", "ccmm", "g", "h", "cghmrtwe", "CGHMRTWE", "mts"}
+	invalidProfiles := []string{"abdfijklnopquvxyz", "cghmrtwesz", "cz", "z", "cc", "ccz", "ccm", "cmm", "ccmm", "g", "h", "cghmrtwe", "CGHMRTWE", "mts"}
 	for _, invalidProfiles := range invalidProfiles {
 		_, err := processProfileFlags(invalidProfiles)
 		if err == nil {
@@ -35,6 +35,7 @@ type Config struct {
 		APIaddr      string
 		RPCaddr      string
 		HostAddr     string
+		S3Addr       string
 		AllowAPIBind bool
 
 		Modules           string
@@ -127,7 +128,15 @@ Explorer (e):
 	the blockchain.
 	The explorer requires the consenus set.
 	Example:
-		siad -M gce`)
+		siad -M gce
+S3 Gateway (s):
+	The S3 gateway serves a subset of the Amazon S3 API in front of the
+	renter, so that S3 clients can store their data on Sia. Buckets are the
+	top-level directories of the renter. The access keys of the gateway are
+	stored in the renter directory.
+	The S3 gateway requires the renter.
+	Example:
+		siad -M gctwrs`)
 }
 
 // main establishes a set of commands and flags using the cobra package.
@@ -165,6 +174,7 @@ func main() {
 	root.Flags().BoolVarP(&globalConfig.Siad.NoBootstrap, "no-bootstrap", "", false, "disable bootstrapping on this run")
 	root.Flags().StringVarP(&globalConfig.Siad.Profile, "profile", "", "", "enable profiling with flags 'cmt' for CPU, memory, trace")
 	root.Flags().StringVarP(&globalConfig.Siad.RPCaddr, "rpc-addr", "", ":9981", "which port the gateway listens on")
+	root.Flags().StringVarP(&globalConfig.Siad.S3Addr, "s3-addr", "", "localhost:9983", "which host:port the S3 gateway listens on")
 	root.Flags().StringVarP(&globalConfig.Siad.Modules, "modules", "M", "cghrtw", "enabled modules, see 'siad modules' for more info")
 	root.Flags().BoolVarP(&globalConfig.Siad.AuthenticateAPI, "authenticate-api", "", false, "enable API password protection")
 	root.Flags().BoolVarP(&globalConfig.Siad.AllowAPIBind, "disable-api-security", "", false, "allow siad to listen on a non-localhost address (DANGEROUS)")
@@ -29,6 +29,7 @@ import (
 	"github.com/NebulousLabs/Sia/modules/host"
 	"github.com/NebulousLabs/Sia/modules/miner"
 	"github.com/NebulousLabs/Sia/modules/renter"
+	"github.com/NebulousLabs/Sia/modules/s3gateway"
 	"github.com/NebulousLabs/Sia/modules/transactionpool"
 	"github.com/NebulousLabs/Sia/modules/wallet"
 	"github.com/NebulousLabs/Sia/node/api"
@@ -567,6 +568,19 @@ func (srv *Server) loadModules() error {
 		}
 		srv.moduleClosers = append(srv.moduleClosers, moduleCloser{name: "renter", Closer: r})
 	}
+	var s3 modules.S3Gateway
+	if strings.Contains(srv.config.Siad.Modules, "s") {
+		i++
+		fmt.Printf("(%d/%d) Loading S3 gateway...\n", i, len(srv.config.Siad.Modules))
+		if r == nil {
+			return errors.New("the S3 gateway requires the renter")
+		}
+		s3, err = s3gateway.New(r, srv.config.Siad.S3Addr, filepath.Join(srv.config.Siad.SiaDir, modules.RenterDir))
+		if err != nil {
+			return err
+		}
+		srv.moduleClosers = append(srv.moduleClosers, moduleCloser{name: "s3 gateway", Closer: s3})
+	}
 
 	// Create the Sia API
 	a := api.New(
@@ -578,6 +592,7 @@ func (srv *Server) loadModules() error {
 		h,
 		m,
 		r,
+		s3,
 		tpool,
 		w,
 	)
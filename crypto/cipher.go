@@ -0,0 +1,162 @@
+package crypto
+
+// cipher.go contains the cipher suites that can be used to encrypt data with
+// a CipherKey.
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/fastrand"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	// TypeTwofish is the cipher type of Twofish in GCM mode.
+	TypeTwofish CipherType = "twofish-gcm"
+
+	// TypeXChaCha20 is the cipher type of XChaCha20-Poly1305.
+	TypeXChaCha20 CipherType = "xchacha20-poly1305"
+
+	// XChaCha20Overhead is the number of bytes added by
+	// XChaCha20Key.EncryptBytes.
+	XChaCha20Overhead = chacha20poly1305.NonceSizeX + chacha20poly1305.Overhead
+)
+
+var (
+	// ErrUnknownCipherType is returned when a cipher type is not recognized.
+	ErrUnknownCipherType = errors.New("unknown cipher type")
+)
+
+type (
+	// CipherType identifies a cipher suite.
+	CipherType string
+
+	// CipherKey is a key of a cipher suite that is used to encrypt and
+	// decrypt data. The ciphertexts of all cipher suites are authenticated.
+	CipherKey interface {
+		// EncryptBytes encrypts plaintext, prepending a random nonce to the
+		// ciphertext.
+		EncryptBytes(plaintext []byte) Ciphertext
+
+		// DecryptBytes decrypts and authenticates a ciphertext created by
+		// EncryptBytes.
+		DecryptBytes(ct Ciphertext) ([]byte, error)
+
+		// DecryptRange decrypts a part of a ciphertext created by
+		// EncryptBytes, which starts at byte offset of the plaintext. nonce
+		// is the nonce that EncryptBytes prepended to the ciphertext. The
+		// part is not authenticated, so the caller has to authenticate the
+		// ciphertext by other means, such as a Merkle proof.
+		DecryptRange(nonce, ct []byte, offset uint64) []byte
+
+		// Type returns the cipher type of the key.
+		Type() CipherType
+	}
+
+	// XChaCha20Key is a key used for encrypting and decrypting data with
+	// XChaCha20-Poly1305.
+	XChaCha20Key [EntropySize]byte
+)
+
+// NewCipherKey returns the key of the cipher suite ct that uses entropy as
+// key material.
+func NewCipherKey(ct CipherType, entropy [EntropySize]byte) (CipherKey, error) {
+	switch ct {
+	case TypeTwofish:
+		return TwofishKey(entropy), nil
+	case TypeXChaCha20:
+		return XChaCha20Key(entropy), nil
+	default:
+		return nil, ErrUnknownCipherType
+	}
+}
+
+// Overhead returns the number of bytes that encrypting data with the cipher
+// suite adds to the data, or 0 if the cipher type is unknown.
+func (ct CipherType) Overhead() uint64 {
+	switch ct {
+	case TypeTwofish:
+		return TwofishOverhead
+	case TypeXChaCha20:
+		return XChaCha20Overhead
+	default:
+		return 0
+	}
+}
+
+// NonceSize returns the size of the nonce that encrypting data with the cipher
+// suite prepends to the ciphertext, or 0 if the cipher type is unknown.
+func (ct CipherType) NonceSize() uint64 {
+	switch ct {
+	case TypeTwofish:
+		return twofishNonceSize
+	case TypeXChaCha20:
+		return chacha20poly1305.NonceSizeX
+	default:
+		return 0
+	}
+}
+
+// Valid returns an error if the cipher type is not recognized.
+func (ct CipherType) Valid() error {
+	if ct != TypeTwofish && ct != TypeXChaCha20 {
+		return ErrUnknownCipherType
+	}
+	return nil
+}
+
+// Type implements the CipherKey interface.
+func (key TwofishKey) Type() CipherType {
+	return TypeTwofish
+}
+
+// EncryptBytes encrypts a []byte using the key. EncryptBytes prepends the
+// nonce (24 bytes) to the ciphertext.
+func (key XChaCha20Key) EncryptBytes(plaintext []byte) Ciphertext {
+	// NOTE: NewX only returns an error if len(key) != 32.
+	aead, _ := chacha20poly1305.NewX(key[:])
+
+	// XChaCha20 nonces are large enough to be chosen at random.
+	nonce := fastrand.Bytes(aead.NonceSize())
+	return aead.Seal(nonce, nonce, plaintext, nil)
+}
+
+// DecryptBytes decrypts the ciphertext created by EncryptBytes. The nonce is
+// expected to be the first 24 bytes of the ciphertext.
+func (key XChaCha20Key) DecryptBytes(ct Ciphertext) ([]byte, error) {
+	// NOTE: NewX only returns an error if len(key) != 32.
+	aead, _ := chacha20poly1305.NewX(key[:])
+
+	// Check for a nonce.
+	if len(ct) < aead.NonceSize() {
+		return nil, ErrInsufficientLen
+	}
+	return aead.Open(nil, ct[:aead.NonceSize()], ct[aead.NonceSize():], nil)
+}
+
+// DecryptRange decrypts the part of a ciphertext created by EncryptBytes that
+// starts at byte offset of the plaintext, without authenticating it.
+func (key XChaCha20Key) DecryptRange(nonce, ct []byte, offset uint64) []byte {
+	// NOTE: NewUnauthenticatedCipher only returns an error if the key or the
+	// nonce have the wrong size.
+	c, err := chacha20.NewUnauthenticatedCipher(key[:], nonce)
+	if err != nil {
+		return nil
+	}
+	// The first block of the key stream is used for the Poly1305 key, the
+	// plaintext starts at the second block.
+	const blockSize = 64
+	c.SetCounter(uint32(1 + offset/blockSize))
+	skip := make([]byte, offset%blockSize)
+	c.XORKeyStream(skip, skip)
+	plaintext := make([]byte, len(ct))
+	c.XORKeyStream(plaintext, ct)
+	return plaintext
+}
+
+// Type implements the CipherKey interface.
+func (key XChaCha20Key) Type() CipherType {
+	return TypeXChaCha20
+}
@@ -0,0 +1,74 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestCipherKeys checks that the keys of all cipher suites can encrypt and
+// decrypt data, and that their overhead is correct.
+func TestCipherKeys(t *testing.T) {
+	for _, ct := range []CipherType{TypeTwofish, TypeXChaCha20} {
+		var entropy [EntropySize]byte
+		fastrand.Read(entropy[:])
+		key, err := NewCipherKey(ct, entropy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key.Type() != ct {
+			t.Fatalf("expected cipher type %v, got %v", ct, key.Type())
+		}
+
+		plaintext := fastrand.Bytes(600)
+		ciphertext := key.EncryptBytes(plaintext)
+		if uint64(len(ciphertext)) != uint64(len(plaintext))+ct.Overhead() {
+			t.Fatalf("%v: expected ciphertext of length %v, got %v", ct, uint64(len(plaintext))+ct.Overhead(), len(ciphertext))
+		}
+		decryptedPlaintext, err := key.DecryptBytes(ciphertext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(plaintext, decryptedPlaintext) {
+			t.Fatalf("%v: encrypted and decrypted plaintext do not match", ct)
+		}
+
+		// Ranges of the ciphertext can be decrypted on their own.
+		nonce := ciphertext[:ct.NonceSize()]
+		for _, r := range [][2]uint64{{0, 600}, {0, 1}, {17, 100}, {64, 64}, {599, 1}, {130, 333}} {
+			offset, length := r[0], r[1]
+			part := ciphertext[ct.NonceSize()+offset : ct.NonceSize()+offset+length]
+			if !bytes.Equal(key.DecryptRange(nonce, part, offset), plaintext[offset:offset+length]) {
+				t.Fatalf("%v: range [%v, %v) was decrypted incorrectly", ct, offset, offset+length)
+			}
+		}
+
+		// The same entropy must not decrypt the data with another cipher.
+		for _, other := range []CipherType{TypeTwofish, TypeXChaCha20} {
+			if other == ct {
+				continue
+			}
+			otherKey, _ := NewCipherKey(other, entropy)
+			if _, err := otherKey.DecryptBytes(ciphertext); err == nil {
+				t.Fatalf("%v ciphertext was decrypted by %v", ct, other)
+			}
+		}
+
+		// Try to decrypt using bad ciphertexts.
+		ciphertext[len(ciphertext)-1]++
+		if _, err := key.DecryptBytes(ciphertext); err == nil {
+			t.Fatalf("%v: expecting failed authentication err", ct)
+		}
+		if _, err := key.DecryptBytes(nil); err != ErrInsufficientLen {
+			t.Errorf("%v: expecting ErrInsufficientLen: %v", ct, err)
+		}
+	}
+
+	if _, err := NewCipherKey("rot13", [EntropySize]byte{}); err != ErrUnknownCipherType {
+		t.Fatal("expected ErrUnknownCipherType, got", err)
+	}
+	if CipherType("rot13").Valid() != ErrUnknownCipherType || TypeXChaCha20.Valid() != nil {
+		t.Fatal("wrong validity of cipher types")
+	}
+}
@@ -5,6 +5,7 @@ package crypto
 
 import (
 	"crypto/cipher"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"io"
@@ -17,6 +18,10 @@ import (
 const (
 	// TwofishOverhead is the number of bytes added by EncryptBytes
 	TwofishOverhead = 28
+
+	// twofishNonceSize is the size of the GCM nonce that EncryptBytes
+	// prepends to the ciphertext.
+	twofishNonceSize = 12
 )
 
 var (
@@ -77,6 +82,25 @@ func (key TwofishKey) DecryptBytes(ct Ciphertext) ([]byte, error) {
 	return aead.Open(nil, ct[:aead.NonceSize()], ct[aead.NonceSize():], nil)
 }
 
+// DecryptRange decrypts the part of a ciphertext created by EncryptBytes that
+// starts at byte offset of the plaintext, without authenticating it. GCM
+// encrypts the plaintext in counter mode, starting with the counter block
+// nonce || 2.
+func (key TwofishKey) DecryptRange(nonce, ct []byte, offset uint64) []byte {
+	if len(nonce) != twofishNonceSize {
+		return nil
+	}
+	iv := make([]byte, twofish.BlockSize)
+	copy(iv, nonce)
+	binary.BigEndian.PutUint32(iv[twofishNonceSize:], uint32(2+offset/twofish.BlockSize))
+	stream := cipher.NewCTR(key.NewCipher(), iv)
+	skip := make([]byte, offset%twofish.BlockSize)
+	stream.XORKeyStream(skip, skip)
+	plaintext := make([]byte, len(ct))
+	stream.XORKeyStream(plaintext, ct)
+	return plaintext
+}
+
 // NewWriter returns a writer that encrypts or decrypts its input stream.
 func (key TwofishKey) NewWriter(w io.Writer) io.Writer {
 	// OK to use a zero IV if the key is unique for each ciphertext.
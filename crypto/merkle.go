@@ -154,3 +154,89 @@ func VerifySegment(base []byte, hashSet []Hash, numSegments, proofIndex uint64,
 	}
 	return merkletree.VerifyProof(NewHash(), root[:], proofSet, proofIndex, numSegments)
 }
+
+// nodeHash returns the hash of the parent of two nodes of a Merkle tree.
+func nodeHash(left, right Hash) Hash {
+	return HashBytes(append(append([]byte{1}, left[:]...), right[:]...))
+}
+
+// splitLeaves returns the number of leaves of the left subtree of a Merkle
+// tree with n leaves, which is the largest power of two less than n.
+func splitLeaves(n uint64) uint64 {
+	split := uint64(1)
+	for split*2 < n {
+		split *= 2
+	}
+	return split
+}
+
+// MerkleRangeProof builds a Merkle proof that the segments
+// [proofStart, proofEnd) are a part of the Merkle root formed by 'b'. The
+// proof contains the roots of the subtrees outside of the range, from left to
+// right.
+func MerkleRangeProof(b []byte, proofStart, proofEnd uint64) []Hash {
+	var proof []Hash
+	var prove func(start, end uint64)
+	prove = func(start, end uint64) {
+		if end <= proofStart || start >= proofEnd {
+			// The subtree is outside of the range.
+			lo, hi := start*SegmentSize, end*SegmentSize
+			if hi > uint64(len(b)) {
+				hi = uint64(len(b))
+			}
+			proof = append(proof, MerkleRoot(b[lo:hi]))
+			return
+		}
+		if start >= proofStart && end <= proofEnd {
+			// The subtree is inside of the range.
+			return
+		}
+		split := start + splitLeaves(end-start)
+		prove(start, split)
+		prove(split, end)
+	}
+	prove(0, CalculateLeaves(uint64(len(b))))
+	return proof
+}
+
+// VerifyRangeProof verifies that the segments [proofStart, proofEnd), which
+// are contained in data, are a part of the Merkle root of numSegments
+// segments, given the proof built by MerkleRangeProof.
+func VerifyRangeProof(data []byte, proof []Hash, proofStart, proofEnd, numSegments uint64, root Hash) bool {
+	if proofStart >= proofEnd || proofEnd > numSegments || CalculateLeaves(uint64(len(data))) != proofEnd-proofStart {
+		return false
+	}
+	var verify func(start, end uint64) (Hash, bool)
+	verify = func(start, end uint64) (Hash, bool) {
+		if end <= proofStart || start >= proofEnd {
+			// The subtree is outside of the range, its root is in the proof.
+			if len(proof) == 0 {
+				return Hash{}, false
+			}
+			h := proof[0]
+			proof = proof[1:]
+			return h, true
+		}
+		if start >= proofStart && end <= proofEnd {
+			// The subtree is inside of the range, its root is computed from
+			// the data.
+			lo, hi := (start-proofStart)*SegmentSize, (end-proofStart)*SegmentSize
+			if hi > uint64(len(data)) {
+				hi = uint64(len(data))
+			}
+			return MerkleRoot(data[lo:hi]), true
+		}
+		split := start + splitLeaves(end-start)
+		left, ok := verify(start, split)
+		if !ok {
+			return Hash{}, false
+		}
+		right, ok := verify(split, end)
+		if !ok {
+			return Hash{}, false
+		}
+		return nodeHash(left, right), true
+	}
+	h, ok := verify(0, numSegments)
+	return ok && len(proof) == 0 && h == root
+}
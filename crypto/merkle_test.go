@@ -153,3 +153,39 @@ func TestOddDataSize(t *testing.T) {
 		}
 	}
 }
+
+// TestMerkleRangeProof checks that range proofs built by MerkleRangeProof are
+// verified by VerifyRangeProof, and that proofs of modified data or wrong
+// ranges are rejected.
+func TestMerkleRangeProof(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		numSegments := uint64(fastrand.Intn(70) + 1)
+		data := fastrand.Bytes(int(numSegments * SegmentSize))
+		root := MerkleRoot(data)
+		start := uint64(fastrand.Intn(int(numSegments)))
+		end := start + uint64(fastrand.Intn(int(numSegments-start))) + 1
+
+		proof := MerkleRangeProof(data, start, end)
+		rangeData := data[start*SegmentSize : end*SegmentSize]
+		if !VerifyRangeProof(rangeData, proof, start, end, numSegments, root) {
+			t.Fatalf("valid proof of segments [%v, %v) out of %v was rejected", start, end, numSegments)
+		}
+
+		// Modified data should be rejected.
+		badData := append([]byte(nil), rangeData...)
+		badData[fastrand.Intn(len(badData))]++
+		if VerifyRangeProof(badData, proof, start, end, numSegments, root) {
+			t.Fatal("proof of modified data was accepted")
+		}
+		// A proof of a shifted range should be rejected.
+		if end < numSegments && VerifyRangeProof(data[(start+1)*SegmentSize:(end+1)*SegmentSize], proof, start+1, end+1, numSegments, root) {
+			t.Fatal("proof of a shifted range was accepted")
+		}
+	}
+
+	// A proof of the whole data is empty.
+	data := fastrand.Bytes(8 * SegmentSize)
+	if proof := MerkleRangeProof(data, 0, 8); len(proof) != 0 || !VerifyRangeProof(data, proof, 0, 8, 8, MerkleRoot(data)) {
+		t.Fatal("proof of the whole data should be empty")
+	}
+}
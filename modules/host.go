@@ -1,6 +1,9 @@
 package modules
 
 import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -9,6 +12,52 @@ const (
 	HostDir = "host"
 )
 
+// The severities of the host alerts. A warning leaves time to fix the
+// problem, an error needs to be fixed right away, and a critical alert reports
+// a loss that has already happened.
+const (
+	HostAlertCritical = "critical"
+	HostAlertError    = "error"
+	HostAlertWarning  = "warning"
+)
+
+// The reasons why the host was unable to build or submit a storage proof.
+const (
+	// ProofFailureInsufficientFees means that the transaction fees exceeded
+	// the value of the storage obligation or that the wallet could not pay
+	// them.
+	ProofFailureInsufficientFees = "insufficientfees"
+
+	// ProofFailureInvalidProof means that the proof did not match the Merkle
+	// root of the file contract, so the stored data is corrupt.
+	ProofFailureInvalidProof = "invalidproof"
+
+	// ProofFailureNotConfirmed means that the proof was submitted but was not
+	// confirmed before the end of the proof window.
+	ProofFailureNotConfirmed = "notconfirmed"
+
+	// ProofFailureReadError means that a sector of the obligation could not
+	// be read.
+	ProofFailureReadError = "readerror"
+
+	// ProofFailureSectorMissing means that a sector of the obligation is not
+	// in the host's storage.
+	ProofFailureSectorMissing = "sectormissing"
+
+	// ProofFailureSegmentUnavailable means that the consensus set did not
+	// provide the segment that the proof has to cover.
+	ProofFailureSegmentUnavailable = "segmentunavailable"
+
+	// ProofFailureTpoolRejected means that the transaction pool rejected the
+	// proof transaction, including the resubmission of a proof that was not
+	// confirmed yet.
+	ProofFailureTpoolRejected = "tpoolrejected"
+
+	// ProofFailureWalletError means that the wallet could not build or sign
+	// the proof transaction.
+	ProofFailureWalletError = "walleterror"
+)
+
 var (
 	// BlockBytesPerMonthTerabyte is the conversion rate between block-bytes and month-TB.
 	BlockBytesPerMonthTerabyte = BytesPerTerabyte.Mul64(4320)
@@ -47,6 +96,17 @@ var (
 )
 
 type (
+	// HostAlert describes a problem with a storage obligation of the host
+	// that needs the attention of the operator. Cause is one of the
+	// ProofFailure reasons.
+	HostAlert struct {
+		ObligationID types.FileContractID `json:"obligationid"`
+		Severity     string               `json:"severity"`
+		Cause        string               `json:"cause"`
+		Message      string               `json:"message"`
+		BlockHeight  types.BlockHeight    `json:"blockheight"` // The height at which the alert was raised.
+	}
+
 	// HostFinancialMetrics provides financial statistics for the host,
 	// including money that is locked in contracts. Though verbose, these
 	// statistics should provide a clear picture of where the host's money is
@@ -77,6 +137,26 @@ type (
 		UploadBandwidthRevenue            types.Currency `json:"uploadbandwidthrevenue"`
 	}
 
+	// HostMetricsBucket contains the financial metrics of the host over a
+	// range of blocks. The revenue and the lost collateral are the amounts
+	// that were earned and lost within the range, the contract count and the
+	// locked and risked collateral are the amounts at the end of the range.
+	HostMetricsBucket struct {
+		StartHeight types.BlockHeight `json:"startheight"`
+		EndHeight   types.BlockHeight `json:"endheight"`
+
+		ContractCount           uint64         `json:"contractcount"`
+		LockedStorageCollateral types.Currency `json:"lockedstoragecollateral"`
+		RiskedStorageCollateral types.Currency `json:"riskedstoragecollateral"`
+
+		ContractCompensation     types.Currency `json:"contractcompensation"`
+		DownloadBandwidthRevenue types.Currency `json:"downloadbandwidthrevenue"`
+		LostRevenue              types.Currency `json:"lostrevenue"`
+		LostStorageCollateral    types.Currency `json:"loststoragecollateral"`
+		StorageRevenue           types.Currency `json:"storagerevenue"`
+		UploadBandwidthRevenue   types.Currency `json:"uploadbandwidthrevenue"`
+	}
+
 	// HostInternalSettings contains a list of settings that can be changed.
 	HostInternalSettings struct {
 		AcceptingContracts   bool              `json:"acceptingcontracts"`
@@ -94,6 +174,23 @@ type (
 		MinDownloadBandwidthPrice types.Currency `json:"mindownloadbandwidthprice"`
 		MinStoragePrice           types.Currency `json:"minstorageprice"`
 		MinUploadBandwidthPrice   types.Currency `json:"minuploadbandwidthprice"`
+
+		// Bandwidth limits. The speeds are in bytes per second and the quotas
+		// in bytes per quota period, zero means unlimited. RenterQuotas maps
+		// renter public keys to quotas that override RenterQuota.
+		MaxDownloadSpeed int64             `json:"maxdownloadspeed"`
+		MaxUploadSpeed   int64             `json:"maxuploadspeed"`
+		RenterQuota      uint64            `json:"renterquota"`
+		RenterQuotas     map[string]uint64 `json:"renterquotas"`
+
+		// Dynamic pricing. If enabled, the host adjusts its storage and
+		// bandwidth prices automatically. The minimum prices above are the
+		// floors of the adjusted prices and the maximum prices below are the
+		// ceilings, zero means no ceiling.
+		DynamicPricing            bool           `json:"dynamicpricing"`
+		MaxDownloadBandwidthPrice types.Currency `json:"maxdownloadbandwidthprice"`
+		MaxStoragePrice           types.Currency `json:"maxstorageprice"`
+		MaxUploadBandwidthPrice   types.Currency `json:"maxuploadbandwidthprice"`
 	}
 
 	// HostNetworkMetrics reports the quantity of each type of RPC call that
@@ -106,6 +203,64 @@ type (
 		ReviseCalls       uint64 `json:"revisecalls"`
 		SettingsCalls     uint64 `json:"settingscalls"`
 		UnrecognizedCalls uint64 `json:"unrecognizedcalls"`
+
+		BytesReceived uint64            `json:"bytesreceived"`
+		BytesSent     uint64            `json:"bytessent"`
+		RenterUsage   []HostRenterUsage `json:"renterusage"`
+	}
+
+	// HostRenterUsage is the bandwidth that a renter has used in the current
+	// quota period of the host. Downloaded and Uploaded are the number of
+	// bytes that the renter downloaded from and uploaded to the host. A Quota
+	// of zero means that the renter's bandwidth is not limited.
+	HostRenterUsage struct {
+		PublicKey   types.SiaPublicKey `json:"publickey"`
+		PeriodStart types.BlockHeight  `json:"periodstart"`
+		Downloaded  uint64             `json:"downloaded"`
+		Uploaded    uint64             `json:"uploaded"`
+		Quota       uint64             `json:"quota"`
+	}
+
+	// HostPrices are the prices of the host that are adjusted by dynamic
+	// pricing.
+	HostPrices struct {
+		DownloadBandwidthPrice types.Currency `json:"downloadbandwidthprice"`
+		StoragePrice           types.Currency `json:"storageprice"`
+		UploadBandwidthPrice   types.Currency `json:"uploadbandwidthprice"`
+	}
+
+	// HostPriceChange is an entry of the host's price log. It records the
+	// prices before and after a change made by dynamic pricing, along with the
+	// inputs that the new prices were computed from.
+	HostPriceChange struct {
+		BlockHeight types.BlockHeight `json:"blockheight"`
+		Timestamp   time.Time         `json:"timestamp"`
+
+		// StorageUtilization and CollateralUtilization are the fractions of
+		// the host's storage and collateral budget that are in use.
+		// AcceptanceRate is the fraction of contract negotiations that
+		// succeeded since the previous update, or -1 if there were none.
+		StorageUtilization    float64 `json:"storageutilization"`
+		CollateralUtilization float64 `json:"collateralutilization"`
+		AcceptanceRate        float64 `json:"acceptancerate"`
+
+		// NetworkPrices are the median prices of the hosts on the network,
+		// zero if unknown.
+		NetworkPrices HostPrices `json:"networkprices"`
+
+		OldPrices HostPrices `json:"oldprices"`
+		NewPrices HostPrices `json:"newprices"`
+	}
+
+	// HostPricing describes the state of the host's dynamic pricing.
+	HostPricing struct {
+		Enabled bool       `json:"enabled"`
+		Prices  HostPrices `json:"prices"`
+
+		// NetworkPrices are the median prices of the NetworkSamples hosts
+		// whose settings were last requested by the host.
+		NetworkPrices  HostPrices `json:"networkprices"`
+		NetworkSamples int        `json:"networksamples"`
 	}
 
 	// StorageObligation contains information about a storage obligation that
@@ -138,6 +293,69 @@ type (
 		ProofConstructed    bool   `json:"proofconstructed"`
 		RevisionConfirmed   bool   `json:"revisionconfirmed"`
 		RevisionConstructed bool   `json:"revisionconstructed"`
+
+		// ProofFailure is the reason why the host was last unable to build or
+		// submit the storage proof, empty if there was no problem.
+		ProofFailure string `json:"prooffailure"`
+	}
+
+	// StorageObligationDetails contains information about a storage
+	// obligation and the history of its file contract.
+	StorageObligationDetails struct {
+		StorageObligation
+
+		// Revisions contains a page of the file contract and the revisions
+		// of it that the host has accepted, oldest first. The host only keeps
+		// the most recent revisions. TotalRevisions is the number of
+		// revisions that are kept.
+		Revisions      []StorageObligationRevision `json:"revisions"`
+		TotalRevisions int                         `json:"totalrevisions"`
+	}
+
+	// StorageObligationRevision describes a revision of the file contract of
+	// a storage obligation. The file contract itself is the revision with
+	// revision number 0.
+	StorageObligationRevision struct {
+		RevisionNumber uint64            `json:"revisionnumber"`
+		BlockHeight    types.BlockHeight `json:"blockheight"` // The height at which the host accepted the revision.
+
+		FileMerkleRoot crypto.Hash       `json:"filemerkleroot"`
+		FileSize       uint64            `json:"filesize"`
+		WindowEnd      types.BlockHeight `json:"windowend"`
+		WindowStart    types.BlockHeight `json:"windowstart"`
+
+		MissedHostPayout  types.Currency `json:"missedhostpayout"`
+		ValidHostPayout   types.Currency `json:"validhostpayout"`
+		ValidRenterPayout types.Currency `json:"validrenterpayout"`
+	}
+
+	// StorageObligationQuery selects and orders a page of the storage
+	// obligations of the host.
+	StorageObligationQuery struct {
+		// Statuses limits the results to obligations with one of the
+		// statuses, which are "unresolved", "succeeded", "failed" and
+		// "rejected". An empty list selects all obligations.
+		Statuses []string
+
+		// The heights limit the results to obligations that were negotiated
+		// and expire within the ranges. A maximum of 0 means no maximum.
+		MinExpirationHeight  types.BlockHeight
+		MaxExpirationHeight  types.BlockHeight
+		MinNegotiationHeight types.BlockHeight
+		MaxNegotiationHeight types.BlockHeight
+
+		// SortBy is the field by which the results are ordered, one of
+		// "expirationheight" (the default), "negotiationheight", "datasize",
+		// "lockedcollateral", "potentialrevenue" and "id". Descending
+		// reverses the order.
+		SortBy     string
+		Descending bool
+
+		// Offset is the number of matching obligations that are skipped and
+		// Limit the maximum number of obligations that are returned. A limit
+		// of 0 means no limit.
+		Offset int
+		Limit  int
 	}
 
 	// HostWorkingStatus reports the working state of a host. Can be one of
@@ -152,6 +370,10 @@ type (
 	// things such as announcements, settings, and implementing all of the RPCs
 	// of the host protocol.
 	Host interface {
+		// Alerts returns the problems with the storage obligations of the
+		// host that need the attention of the operator.
+		Alerts() []HostAlert
+
 		// Announce submits a host announcement to the blockchain.
 		Announce() error
 
@@ -165,6 +387,11 @@ type (
 		// FinancialMetrics returns the financial statistics of the host.
 		FinancialMetrics() HostFinancialMetrics
 
+		// FinancialMetricsHistory splits the blocks between the two heights
+		// into buckets of the given number of blocks and returns the
+		// financial metrics of the host for each bucket.
+		FinancialMetricsHistory(from, to, bucketSize types.BlockHeight) ([]HostMetricsBucket, error)
+
 		// InternalSettings returns the host's internal settings, including
 		// potentially private or sensitive information.
 		InternalSettings() HostInternalSettings
@@ -173,6 +400,13 @@ type (
 		// have been made to the host.
 		NetworkMetrics() HostNetworkMetrics
 
+		// PriceChanges returns the price log of the host's dynamic pricing,
+		// oldest change first.
+		PriceChanges() ([]HostPriceChange, error)
+
+		// Pricing returns the state of the host's dynamic pricing.
+		Pricing() HostPricing
+
 		// PublicKey returns the public key of the host.
 		PublicKey() types.SiaPublicKey
 
@@ -183,6 +417,15 @@ type (
 		// the host.
 		StorageObligations() []StorageObligation
 
+		// QueryStorageObligations returns the storage obligations that match
+		// the query, along with the total number of matching obligations
+		// before paging.
+		QueryStorageObligations(StorageObligationQuery) ([]StorageObligation, int, error)
+
+		// StorageObligation returns the details of a storage obligation,
+		// including a page of the history of its file contract.
+		StorageObligation(id types.FileContractID, offset, limit int) (StorageObligationDetails, error)
+
 		// ConnectabilityStatus returns the connectability status of the host, that
 		// is, if it can connect to itself on the configured NetAddress.
 		ConnectabilityStatus() HostConnectabilityStatus
@@ -0,0 +1,161 @@
+package host
+
+// bandwidth.go limits the bandwidth of the host. The maximum upload and
+// download speeds apply to all connections of the host, and the renter quotas
+// limit the number of bytes that each renter can upload and download per quota
+// period. Only the data transferred in the download and revise loops counts
+// towards the quotas.
+
+import (
+	"net"
+	"sort"
+	"sync/atomic"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// errRenterQuotaExceeded is returned if a renter requests more data than
+	// its quota for the current period allows.
+	errRenterQuotaExceeded = ErrorCommunication("renter exceeded its bandwidth quota")
+)
+
+type (
+	// renterUsage is the bandwidth that a renter has used in the quota period
+	// starting at Period.
+	renterUsage struct {
+		PublicKey  types.SiaPublicKey `json:"publickey"`
+		Period     types.BlockHeight  `json:"period"`
+		Downloaded uint64             `json:"downloaded"`
+		Uploaded   uint64             `json:"uploaded"`
+	}
+
+	// meteredConn is a net.Conn that counts the bytes that are read from and
+	// written to it.
+	meteredConn struct {
+		net.Conn
+		h *Host
+	}
+)
+
+// Read implements net.Conn.
+func (mc meteredConn) Read(b []byte) (int, error) {
+	n, err := mc.Conn.Read(b)
+	atomic.AddUint64(&mc.h.atomicBytesReceived, uint64(n))
+	return n, err
+}
+
+// Write implements net.Conn.
+func (mc meteredConn) Write(b []byte) (int, error) {
+	n, err := mc.Conn.Write(b)
+	atomic.AddUint64(&mc.h.atomicBytesSent, uint64(n))
+	return n, err
+}
+
+// quotaPeriod returns the height at which the quota period that contains the
+// height began.
+func quotaPeriod(height types.BlockHeight) types.BlockHeight {
+	return height - height%renterQuotaPeriod
+}
+
+// copyRenterQuotas returns a copy of the renter quotas of the host's settings,
+// so that callers can't modify the settings of the host.
+func copyRenterQuotas(quotas map[string]uint64) map[string]uint64 {
+	if quotas == nil {
+		return nil
+	}
+	c := make(map[string]uint64, len(quotas))
+	for key, quota := range quotas {
+		c[key] = quota
+	}
+	return c
+}
+
+// renterQuota returns the quota of a renter, or zero if the renter's bandwidth
+// is not limited.
+func (h *Host) renterQuota(renter types.SiaPublicKey) uint64 {
+	if quota, exists := h.settings.RenterQuotas[renter.String()]; exists {
+		return quota
+	}
+	return h.settings.RenterQuota
+}
+
+// setRateLimits applies the speed limits of the host's settings to its
+// connections.
+func (h *Host) setRateLimits() {
+	if h.settings.MaxDownloadSpeed == 0 && h.settings.MaxUploadSpeed == 0 {
+		h.rl.SetLimits(0, 0, 0)
+	} else {
+		h.rl.SetLimits(h.settings.MaxDownloadSpeed, h.settings.MaxUploadSpeed, 4*4096)
+	}
+}
+
+// managedUseBandwidth adds the bytes that a renter wants to download and
+// upload to its usage in the current quota period. If this would exceed the
+// renter's quota, errRenterQuotaExceeded is returned and the usage is left
+// unchanged.
+func (h *Host) managedUseBandwidth(renter types.SiaPublicKey, downloaded, uploaded uint64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := renter.String()
+	usage, exists := h.renterUsage[key]
+	if period := quotaPeriod(h.blockHeight); !exists || usage.Period != period {
+		usage = renterUsage{PublicKey: renter, Period: period}
+	}
+	quota := h.renterQuota(renter)
+	if quota != 0 && usage.Downloaded+usage.Uploaded+downloaded+uploaded > quota {
+		return errRenterQuotaExceeded
+	}
+	usage.Downloaded += downloaded
+	usage.Uploaded += uploaded
+	h.renterUsage[key] = usage
+	return nil
+}
+
+// managedRefundBandwidth removes bytes that were added with
+// managedUseBandwidth from the renter's usage, for transfers that didn't
+// happen. Usage of a previous quota period is left unchanged.
+func (h *Host) managedRefundBandwidth(renter types.SiaPublicKey, downloaded, uploaded uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := renter.String()
+	usage, exists := h.renterUsage[key]
+	if !exists || usage.Period != quotaPeriod(h.blockHeight) {
+		return
+	}
+	if downloaded > usage.Downloaded {
+		downloaded = usage.Downloaded
+	}
+	if uploaded > usage.Uploaded {
+		uploaded = usage.Uploaded
+	}
+	usage.Downloaded -= downloaded
+	usage.Uploaded -= uploaded
+	h.renterUsage[key] = usage
+}
+
+// currentRenterUsage returns the usage of the renters that used bandwidth in
+// the current quota period, sorted by public key.
+func (h *Host) currentRenterUsage() []modules.HostRenterUsage {
+	period := quotaPeriod(h.blockHeight)
+	var usage []modules.HostRenterUsage
+	for _, u := range h.renterUsage {
+		if u.Period != period {
+			continue
+		}
+		usage = append(usage, modules.HostRenterUsage{
+			PublicKey:   u.PublicKey,
+			PeriodStart: u.Period,
+			Downloaded:  u.Downloaded,
+			Uploaded:    u.Uploaded,
+			Quota:       h.renterQuota(u.PublicKey),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].PublicKey.String() < usage[j].PublicKey.String()
+	})
+	return usage
+}
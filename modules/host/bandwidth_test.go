@@ -0,0 +1,113 @@
+package host
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestRenterQuotas checks that the host enforces the bandwidth quotas of the
+// renters, reports their usage and resets it in every quota period.
+func TestRenterQuotas(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	var pkA, pkB crypto.PublicKey
+	fastrand.Read(pkA[:])
+	fastrand.Read(pkB[:])
+	renterA, renterB := types.Ed25519PublicKey(pkA), types.Ed25519PublicKey(pkB)
+
+	// Invalid limits are rejected.
+	settings := ht.host.InternalSettings()
+	settings.MaxUploadSpeed = -1
+	if err := ht.host.SetInternalSettings(settings); err == nil {
+		t.Fatal("negative upload speed was accepted")
+	}
+	settings.MaxUploadSpeed = 0
+	settings.RenterQuotas = map[string]uint64{"ed25519:xyz": 100}
+	if err := ht.host.SetInternalSettings(settings); err == nil {
+		t.Fatal("invalid renter public key was accepted")
+	}
+
+	// renterB has no quota, renterA has the default quota.
+	settings.RenterQuota = 100
+	settings.RenterQuotas = map[string]uint64{renterB.String(): 0}
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.managedUseBandwidth(renterA, 60, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.managedUseBandwidth(renterA, 0, 50); err != errRenterQuotaExceeded {
+		t.Fatal("expected errRenterQuotaExceeded, got", err)
+	}
+	if err := ht.host.managedUseBandwidth(renterA, 0, 40); err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.managedUseBandwidth(renterB, 1000, 1000); err != nil {
+		t.Fatal(err)
+	}
+	usage := ht.host.NetworkMetrics().RenterUsage
+	if len(usage) != 2 {
+		t.Fatal("expected usage of 2 renters, got", len(usage))
+	}
+	for _, u := range usage {
+		switch u.PublicKey.String() {
+		case renterA.String():
+			if u.Downloaded != 60 || u.Uploaded != 40 || u.Quota != 100 {
+				t.Error("wrong usage of renterA:", u)
+			}
+		case renterB.String():
+			if u.Downloaded != 1000 || u.Uploaded != 1000 || u.Quota != 0 {
+				t.Error("wrong usage of renterB:", u)
+			}
+		default:
+			t.Error("usage of unknown renter:", u)
+		}
+	}
+
+	// The usage persists across restarts.
+	if err := ht.host.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.managedUseBandwidth(renterA, 1, 0); err != errRenterQuotaExceeded {
+		t.Fatal("usage wasn't persisted:", err)
+	}
+
+	// The usage is reset in the next quota period.
+	ht.host.mu.Lock()
+	ht.host.blockHeight += renterQuotaPeriod
+	ht.host.mu.Unlock()
+	if usage := ht.host.NetworkMetrics().RenterUsage; len(usage) != 0 {
+		t.Fatal("usage of the previous period was reported:", usage)
+	}
+	if err := ht.host.managedUseBandwidth(renterA, 100, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Refunded bandwidth can be used again, and refunds never exceed the
+	// usage.
+	ht.host.managedRefundBandwidth(renterA, 40, 0)
+	if err := ht.host.managedUseBandwidth(renterA, 40, 0); err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedRefundBandwidth(renterA, 1000, 1000)
+	if usage := ht.host.NetworkMetrics().RenterUsage; len(usage) != 1 || usage[0].Downloaded != 0 || usage[0].Uploaded != 0 {
+		t.Fatal("refund didn't reset the usage:", usage)
+	}
+}
@@ -26,6 +26,28 @@ const (
 	// connection.
 	iteratedConnectionTime = 1200 * time.Second
 
+	// maxMetricsBuckets is the largest number of buckets that the history of
+	// the financial metrics is split into by a single query.
+	maxMetricsBuckets = 10e3
+
+	// maxPriceChange is the largest fraction by which dynamic pricing changes
+	// a price in a single update.
+	maxPriceChange = 0.1
+
+	// networkPriceSamples is the number of announced hosts whose prices are
+	// requested to estimate the prices of the network.
+	networkPriceSamples = 10
+
+	// renterQuotaPeriod is the number of blocks after which the bandwidth
+	// that the renters used is reset, see HostInternalSettings.RenterQuota.
+	renterQuotaPeriod = 144 * 30 // 1 month.
+
+	// The following weights determine how strongly each input of the dynamic
+	// pricing influences the prices, see pricing.go.
+	acceptanceRateWeight        = 0.2
+	collateralUtilizationWeight = 0.4
+	storageUtilizationWeight    = 0.4
+
 	// resubmissionTimeout defines the number of blocks that a host will wait
 	// before attempting to resubmit a transaction to the blockchain.
 	// Typically, this transaction will contain either a file contract, a file
@@ -34,6 +56,14 @@ const (
 )
 
 var (
+	// alertRetention is the number of blocks after which an alert that has
+	// not been raised again is removed.
+	alertRetention = build.Select(build.Var{
+		Dev:      types.BlockHeight(144),  // About 1 day
+		Standard: types.BlockHeight(1008), // 1 week.
+		Testing:  types.BlockHeight(20),
+	}).(types.BlockHeight)
+
 	// connectablityCheckFirstWait defines how often the host's connectability
 	// check is run.
 	connectabilityCheckFirstWait = build.Select(build.Var{
@@ -160,6 +190,15 @@ var (
 		Testing:  uint64(500),
 	}).(uint64)
 
+	// maxObligationRevisions is the number of revisions of the file contract
+	// of a storage obligation that are kept in its history. Older revisions
+	// are pruned.
+	maxObligationRevisions = build.Select(build.Var{
+		Dev:      100,
+		Standard: 100,
+		Testing:  5,
+	}).(int)
+
 	// maximumLockedStorageObligations sets the maximum number of storage
 	// obligations that are allowed to be locked at a time. The map uses an
 	// in-memory lock, but also a locked storage obligation could be reading a
@@ -170,6 +209,14 @@ var (
 		Testing:  uint64(5),
 	}).(uint64)
 
+	// networkPriceTimeout defines how long the host waits for another host to
+	// respond when it requests its prices.
+	networkPriceTimeout = build.Select(build.Var{
+		Dev:      time.Second * 30,
+		Standard: time.Second * 30,
+		Testing:  time.Second * 5,
+	}).(time.Duration)
+
 	// obligationLockTimeout defines how long a thread will wait to get a lock
 	// on a storage obligation before timing out and reporting an error to the
 	// renter.
@@ -179,6 +226,14 @@ var (
 		Testing:  time.Second * 3,
 	}).(time.Duration)
 
+	// pricingInterval defines how often the host updates its prices when
+	// dynamic pricing is enabled.
+	pricingInterval = build.Select(build.Var{
+		Dev:      time.Minute * 5,
+		Standard: time.Hour,
+		Testing:  time.Second * 2,
+	}).(time.Duration)
+
 	// revisionSubmissionBuffer describes the number of blocks ahead of time
 	// that the host will submit a file contract revision. The host will not
 	// accept any more revisions once inside the submission buffer.
@@ -234,9 +289,37 @@ var (
 	// using the id.
 	bucketActionItems = []byte("BucketActionItems")
 
+	// bucketAnnouncedHosts contains the hosts that were announced on the
+	// blockchain, which are sampled by the dynamic pricing. The hosts are
+	// stored as JSON and keyed by their public key.
+	bucketAnnouncedHosts = []byte("BucketAnnouncedHosts")
+
+	// bucketFinancialMetricsHistory contains a snapshot of the financial
+	// metrics of the host for every block height. The snapshots are stored as
+	// JSON and keyed by the height as a big endian uint64.
+	bucketFinancialMetricsHistory = []byte("BucketFinancialMetricsHistory")
+
+	// bucketPriceChanges contains the price log of the dynamic pricing. The
+	// changes are stored as JSON and keyed by a big endian sequence number,
+	// which keeps them in chronological order.
+	bucketPriceChanges = []byte("BucketPriceChanges")
+
 	// bucketStorageObligations contains a set of serialized
 	// 'storageObligations' sorted by their file contract id.
 	bucketStorageObligations = []byte("BucketStorageObligations")
+
+	// bucketStorageObligationRevisions contains the history of the file
+	// contracts of the storage obligations. The revisions are stored as JSON
+	// and keyed by the file contract id followed by the revision number as a
+	// big endian uint64. Only the latest maxObligationRevisions revisions of
+	// an obligation are kept.
+	bucketStorageObligationRevisions = []byte("BucketStorageObligationRevisions")
+
+	// bucketStorageObligationSummaries contains the 'modules.StorageObligation'
+	// summaries of the storage obligations, sorted by their file contract id.
+	// The summaries leave out the sector roots and transaction sets, which
+	// keeps listing the obligations cheap.
+	bucketStorageObligationSummaries = []byte("BucketStorageObligationSummaries")
 )
 
 // init runs a series of sanity checks to verify that the constants have sane
@@ -77,6 +77,8 @@ import (
 	"github.com/NebulousLabs/Sia/persist"
 	siasync "github.com/NebulousLabs/Sia/sync"
 	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/ratelimit"
 )
 
 const (
@@ -124,6 +126,14 @@ type Host struct {
 	atomicSettingsCalls     uint64
 	atomicUnrecognizedCalls uint64
 
+	// The number of contracts that were formed or renewed, used by the
+	// dynamic pricing. This value is not persistent.
+	atomicContractsFormed uint64
+
+	// Bandwidth metrics. These values are not persistent.
+	atomicBytesReceived uint64
+	atomicBytesSent     uint64
+
 	// Error management. There are a few different types of errors returned by
 	// the host. These errors intentionally not persistent, so that the logging
 	// limits of each error type will be reset each time the host is reset.
@@ -160,6 +170,29 @@ type Host struct {
 	workingStatus        modules.HostWorkingStatus
 	connectabilityStatus modules.HostConnectabilityStatus
 
+	// The bandwidth limits of the host. rl limits the speed of all
+	// connections, and renterUsage tracks the bandwidth of each renter for
+	// the quotas. See bandwidth.go.
+	rl          *ratelimit.RateLimit
+	renterUsage map[string]renterUsage
+
+	// The state of the dynamic pricing, see pricing.go.
+	// announcementsScanned indicates that the host has recorded the hosts
+	// that were announced before it started recording them, networkPrices is
+	// the latest estimate of the prices of the announced hosts, and
+	// lastContractCalls and lastContractsFormed are the contract metrics at
+	// the previous update.
+	announcementsScanned bool
+	dynamicPrices        modules.HostPrices
+	lastContractCalls    uint64
+	lastContractsFormed  uint64
+	networkPrices        modules.HostPrices
+	networkSamples       int
+
+	// The alerts of the storage obligations that need the attention of the
+	// operator, see proof.go.
+	alerts map[types.FileContractID]modules.HostAlert
+
 	// A map of storage obligations that are currently being modified. Locks on
 	// storage obligations can be long-running, and each storage obligation can
 	// be locked separately.
@@ -233,8 +266,12 @@ func newHost(dependencies modules.Dependencies, cs modules.ConsensusSet, tpool m
 		wallet:       wallet,
 		dependencies: dependencies,
 
+		alerts:                   make(map[types.FileContractID]modules.HostAlert),
 		lockedStorageObligations: make(map[types.FileContractID]*siasync.TryMutex),
 
+		rl:          ratelimit.NewRateLimit(0, 0, 0),
+		renterUsage: make(map[string]renterUsage),
+
 		persistDir: persistDir,
 	}
 
@@ -384,6 +421,26 @@ func (h *Host) SetInternalSettings(settings modules.HostInternalSettings) error
 		}
 	}
 
+	if settings.MaxDownloadSpeed < 0 || settings.MaxUploadSpeed < 0 {
+		return errors.New("internal settings not updated, download and upload speeds cannot be negative")
+	}
+	if !settings.MaxStoragePrice.IsZero() && settings.MaxStoragePrice.Cmp(settings.MinStoragePrice) < 0 {
+		return errors.New("internal settings not updated, the maximum storage price cannot be below the minimum storage price")
+	}
+	if !settings.MaxUploadBandwidthPrice.IsZero() && settings.MaxUploadBandwidthPrice.Cmp(settings.MinUploadBandwidthPrice) < 0 {
+		return errors.New("internal settings not updated, the maximum upload bandwidth price cannot be below the minimum upload bandwidth price")
+	}
+	if !settings.MaxDownloadBandwidthPrice.IsZero() && settings.MaxDownloadBandwidthPrice.Cmp(settings.MinDownloadBandwidthPrice) < 0 {
+		return errors.New("internal settings not updated, the maximum download bandwidth price cannot be below the minimum download bandwidth price")
+	}
+	for key := range settings.RenterQuotas {
+		var spk types.SiaPublicKey
+		spk.LoadString(key)
+		if spk.String() != key {
+			return errors.New("internal settings not updated, invalid renter public key: " + key)
+		}
+	}
+
 	if settings.NetAddress != "" {
 		err := settings.NetAddress.IsValid()
 		if err != nil {
@@ -399,7 +456,9 @@ func (h *Host) SetInternalSettings(settings modules.HostInternalSettings) error
 	}
 
 	h.settings = settings
+	h.settings.RenterQuotas = copyRenterQuotas(settings.RenterQuotas)
 	h.revisionNumber++
+	h.setRateLimits()
 
 	err = h.saveSync()
 	if err != nil {
@@ -417,5 +476,7 @@ func (h *Host) InternalSettings() modules.HostInternalSettings {
 		return modules.HostInternalSettings{}
 	}
 	defer h.tg.Done()
-	return h.settings
+	settings := h.settings
+	settings.RenterQuotas = copyRenterQuotas(h.settings.RenterQuotas)
+	return settings
 }
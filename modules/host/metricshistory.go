@@ -0,0 +1,173 @@
+package host
+
+// metricshistory.go keeps the history of the financial metrics of the host.
+// Every time the host processes a change to the blockchain, a snapshot of the
+// financial metrics is stored in the database under the new block height. When
+// the history is queried, the snapshots are split into buckets of blocks.
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/coreos/bbolt"
+)
+
+var (
+	// errInvalidMetricsBucket is returned if the history of the financial
+	// metrics is split into buckets of zero blocks.
+	errInvalidMetricsBucket = errors.New("buckets must contain at least one block")
+
+	// errInvalidMetricsRange is returned if the history of the financial
+	// metrics is requested for a range that ends before it starts.
+	errInvalidMetricsRange = errors.New("the start height of the range is above the end height")
+
+	// errTooManyMetricsBuckets is returned if the history of the financial
+	// metrics would be split into more than maxMetricsBuckets buckets.
+	errTooManyMetricsBuckets = errors.New("too many buckets, use a larger bucket or a smaller range")
+)
+
+// metricsHistoryKey returns the database key of the snapshot of the financial
+// metrics at a height.
+func metricsHistoryKey(height types.BlockHeight) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(height))
+	return key
+}
+
+// metricsDelta returns the increase of a running total. A decrease, which
+// happens when the host rebuilds its financial metrics, counts as no increase.
+func metricsDelta(newTotal, oldTotal types.Currency) types.Currency {
+	if newTotal.Cmp(oldTotal) < 0 {
+		return types.ZeroCurrency
+	}
+	return newTotal.Sub(oldTotal)
+}
+
+// putMetricsSnapshot stores a snapshot of the financial metrics at a height.
+// The snapshots of any higher heights belong to blocks that have been reverted
+// and are removed.
+func putMetricsSnapshot(tx *bolt.Tx, height types.BlockHeight, fm modules.HostFinancialMetrics) error {
+	b := tx.Bucket(bucketFinancialMetricsHistory)
+	var reverted [][]byte
+	c := b.Cursor()
+	for k, _ := c.Seek(metricsHistoryKey(height + 1)); k != nil; k, _ = c.Next() {
+		reverted = append(reverted, k)
+	}
+	for _, k := range reverted {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	fmBytes, err := json.Marshal(fm)
+	if err != nil {
+		return err
+	}
+	return b.Put(metricsHistoryKey(height), fmBytes)
+}
+
+// FinancialMetricsHistory splits the blocks from height 'from' up to and
+// including height 'to' into buckets of 'bucketSize' blocks and returns the
+// financial metrics of the host for each bucket. A 'to' of zero or above the
+// current height means the current height.
+func (h *Host) FinancialMetricsHistory(from, to, bucketSize types.BlockHeight) ([]modules.HostMetricsBucket, error) {
+	if err := h.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer h.tg.Done()
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if bucketSize == 0 {
+		return nil, errInvalidMetricsBucket
+	}
+	if to == 0 || to > h.blockHeight {
+		to = h.blockHeight
+	}
+	if from > to {
+		return nil, errInvalidMetricsRange
+	}
+	if (to-from)/bucketSize >= maxMetricsBuckets {
+		return nil, errTooManyMetricsBuckets
+	}
+
+	var buckets []modules.HostMetricsBucket
+	err := h.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketFinancialMetricsHistory)
+
+		// The last snapshot before the range is the baseline of the amounts
+		// earned in the first bucket. If the history starts within the range,
+		// the first snapshot is the baseline instead.
+		var prev modules.HostFinancialMetrics
+		havePrev := false
+		c := b.Cursor()
+		k, v := c.Seek(metricsHistoryKey(from))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		if k != nil {
+			if err := json.Unmarshal(v, &prev); err != nil {
+				return build.ExtendErr("unable to unmarshal financial metrics", err)
+			}
+			havePrev = true
+		}
+
+		c = b.Cursor()
+		k, v = c.Seek(metricsHistoryKey(from))
+		for start := from; ; {
+			end := start + bucketSize - 1
+			if end > to || end < start {
+				end = to
+			}
+
+			// Blocks without a snapshot did not change the metrics, so the
+			// metrics of a bucket are those of its last snapshot, or those of
+			// the previous bucket if it has none.
+			cur := prev
+			for ; k != nil && types.BlockHeight(binary.BigEndian.Uint64(k)) <= end; k, v = c.Next() {
+				var fm modules.HostFinancialMetrics
+				if err := json.Unmarshal(v, &fm); err != nil {
+					return build.ExtendErr("unable to unmarshal financial metrics", err)
+				}
+				cur = fm
+				if !havePrev {
+					prev = fm
+					havePrev = true
+				}
+			}
+
+			buckets = append(buckets, modules.HostMetricsBucket{
+				StartHeight: start,
+				EndHeight:   end,
+
+				ContractCount:           cur.ContractCount,
+				LockedStorageCollateral: cur.LockedStorageCollateral,
+				RiskedStorageCollateral: cur.RiskedStorageCollateral,
+
+				ContractCompensation:     metricsDelta(cur.ContractCompensation, prev.ContractCompensation),
+				DownloadBandwidthRevenue: metricsDelta(cur.DownloadBandwidthRevenue, prev.DownloadBandwidthRevenue),
+				LostRevenue:              metricsDelta(cur.LostRevenue, prev.LostRevenue),
+				LostStorageCollateral:    metricsDelta(cur.LostStorageCollateral, prev.LostStorageCollateral),
+				StorageRevenue:           metricsDelta(cur.StorageRevenue, prev.StorageRevenue),
+				UploadBandwidthRevenue:   metricsDelta(cur.UploadBandwidthRevenue, prev.UploadBandwidthRevenue),
+			})
+			prev = cur
+			if end == to {
+				break
+			}
+			start = end + 1
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, build.ExtendErr("database failed to provide the financial metrics history", err)
+	}
+	return buckets, nil
+}
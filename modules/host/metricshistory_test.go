@@ -0,0 +1,90 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/coreos/bbolt"
+)
+
+// TestFinancialMetricsHistory checks that the host records its financial
+// metrics for every block and splits the history into buckets.
+func TestFinancialMetricsHistory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Earn revenue in the first and second of three blocks.
+	ht.host.mu.RLock()
+	start := ht.host.blockHeight + 1
+	ht.host.mu.RUnlock()
+	for _, revenue := range []uint64{100, 200, 0} {
+		ht.host.mu.Lock()
+		ht.host.financialMetrics.StorageRevenue = ht.host.financialMetrics.StorageRevenue.Add(types.NewCurrency64(revenue))
+		ht.host.financialMetrics.LockedStorageCollateral = types.NewCurrency64(revenue)
+		ht.host.mu.Unlock()
+		if _, err := ht.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buckets, err := ht.host.FinancialMetricsHistory(start, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 3 {
+		t.Fatal("expected 3 buckets, got", len(buckets))
+	}
+	for i, revenue := range []uint64{100, 200, 0} {
+		if buckets[i].StartHeight != start+types.BlockHeight(i) || buckets[i].EndHeight != buckets[i].StartHeight {
+			t.Error("wrong range of bucket", i, buckets[i].StartHeight, buckets[i].EndHeight)
+		}
+		if !buckets[i].StorageRevenue.Equals64(revenue) {
+			t.Error("wrong revenue in bucket", i, buckets[i].StorageRevenue)
+		}
+		if !buckets[i].LockedStorageCollateral.Equals64(revenue) {
+			t.Error("wrong locked collateral in bucket", i, buckets[i].LockedStorageCollateral)
+		}
+	}
+
+	// Larger buckets add up the revenue of their blocks.
+	buckets, err = ht.host.FinancialMetricsHistory(start, start+2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 2 || !buckets[0].StorageRevenue.Equals64(300) || !buckets[1].StorageRevenue.IsZero() || buckets[1].EndHeight != start+2 {
+		t.Fatal("wrong buckets:", buckets)
+	}
+
+	if _, err := ht.host.FinancialMetricsHistory(start, 0, 0); err != errInvalidMetricsBucket {
+		t.Error("expected errInvalidMetricsBucket, got", err)
+	}
+	if _, err := ht.host.FinancialMetricsHistory(start+1, start, 1); err != errInvalidMetricsRange {
+		t.Error("expected errInvalidMetricsRange, got", err)
+	}
+
+	// A snapshot at a lower height removes the snapshots of reverted blocks.
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		ht.host.mu.RLock()
+		defer ht.host.mu.RUnlock()
+		return putMetricsSnapshot(tx, start, ht.host.financialMetrics)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.host.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketFinancialMetricsHistory).Get(metricsHistoryKey(start+1)) != nil {
+			t.Error("snapshot of a reverted block was not removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
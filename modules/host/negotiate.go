@@ -211,7 +211,8 @@ func (h *Host) managedFinalizeContract(builder modules.TransactionBuilder, rente
 	// Create and add the storage obligation for this file contract.
 	fullTxn, _ := builder.View()
 	so := storageObligation{
-		SectorRoots: initialSectorRoots,
+		SectorRoots:       initialSectorRoots,
+		NegotiationHeight: blockHeight,
 
 		ContractCost:            settings.ContractPrice,
 		LockedCollateral:        hostCollateral,
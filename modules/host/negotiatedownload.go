@@ -6,6 +6,7 @@ import (
 	"net"
 	"time"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
@@ -20,11 +21,18 @@ var (
 	// errRequestOutOfBounds is returned when a download request is made which
 	// asks for elements of a sector which do not exist.
 	errRequestOutOfBounds = ErrorCommunication("download request has invalid sector bounds")
+
+	// errRequestUnaligned is returned when a download request asks for a
+	// Merkle range proof of a part of a sector that is not aligned to
+	// segments.
+	errRequestUnaligned = ErrorCommunication("download request is not aligned to segments")
 )
 
 // managedDownloadIteration is responsible for managing a single iteration of
-// the download loop for RPCDownload.
-func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) error {
+// the download loop for RPCDownload. If granted is not nil, only the sectors
+// in granted can be downloaded. If proofs is true, the data is followed by
+// Merkle range proofs.
+func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation, granted map[crypto.Hash]struct{}, proofs bool) error {
 	// Exchange settings with the renter.
 	err := h.managedRPCSettings(conn)
 	if err != nil {
@@ -66,6 +74,15 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 	// for the renter.
 	existingRevision := so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1].FileContractRevisions[0]
 	var payload [][]byte
+	var payloadProofs [][]crypto.Hash
+	// The bandwidth is refunded if the iteration fails before the storage
+	// obligation is updated.
+	var downloaded uint64
+	defer func() {
+		if downloaded > 0 {
+			h.managedRefundBandwidth(so.renterKey(), downloaded, 0)
+		}
+	}()
 	err = func() error {
 		// Check that the length of each file is in-bounds, and that the total
 		// size being requested is acceptable.
@@ -74,6 +91,12 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 			if request.Length > modules.SectorSize || request.Offset+request.Length > modules.SectorSize {
 				return extendErr("download iteration request failed: ", errRequestOutOfBounds)
 			}
+			if proofs && (request.Length == 0 || request.Offset%crypto.SegmentSize != 0 || request.Length%crypto.SegmentSize != 0) {
+				return extendErr("download iteration request failed: ", errRequestUnaligned)
+			}
+			if _, ok := granted[request.MerkleRoot]; granted != nil && !ok {
+				return extendErr("download iteration request failed: ", errTicketSector)
+			}
 			totalSize += request.Length
 		}
 		if totalSize > settings.MaxDownloadBatchSize {
@@ -88,6 +111,13 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 			return extendErr("payment verification failed: ", err)
 		}
 
+		// Check that the renter's bandwidth quota allows the download.
+		err = h.managedUseBandwidth(so.renterKey(), totalSize, 0)
+		if err != nil {
+			return extendErr("download iteration request failed: ", err)
+		}
+		downloaded = totalSize
+
 		// Load the sectors and build the data payload.
 		for _, request := range requests {
 			sectorData, err := h.ReadSector(request.MerkleRoot)
@@ -95,6 +125,11 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 				return extendErr("failed to load sector: ", ErrorInternal(err.Error()))
 			}
 			payload = append(payload, sectorData[request.Offset:request.Offset+request.Length])
+			if proofs {
+				proofStart := request.Offset / crypto.SegmentSize
+				proofEnd := (request.Offset + request.Length) / crypto.SegmentSize
+				payloadProofs = append(payloadProofs, crypto.MerkleRangeProof(sectorData, proofStart, proofEnd))
+			}
 		}
 		return nil
 	}()
@@ -129,6 +164,7 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 	if err != nil {
 		return extendErr("failed to modify storage obligation: ", ErrorInternal(modules.WriteNegotiationRejection(conn, err).Error()))
 	}
+	downloaded = 0
 
 	// Write acceptance to the renter - the data request can be fulfilled by
 	// the host, the payment is satisfactory, signature is correct. Then send
@@ -145,6 +181,12 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 	if err != nil {
 		return extendErr("failed to write payload: ", ErrorConnection(err.Error()))
 	}
+	if proofs {
+		err = encoding.WriteObject(conn, payloadProofs)
+		if err != nil {
+			return extendErr("failed to write proofs: ", ErrorConnection(err.Error()))
+		}
+	}
 	return nil
 }
 
@@ -247,6 +289,20 @@ func verifyPaymentRevision(existingRevision, paymentRevision types.FileContractR
 // managedRPCDownload is responsible for handling an RPC request from the
 // renter to download data.
 func (h *Host) managedRPCDownload(conn net.Conn) error {
+	return h.managedDownloadLoop(conn, nil, false)
+}
+
+// managedRPCProvenDownload is responsible for handling an RPC request from
+// the renter to download data along with Merkle range proofs.
+func (h *Host) managedRPCProvenDownload(conn net.Conn) error {
+	return h.managedDownloadLoop(conn, nil, true)
+}
+
+// managedDownloadLoop performs the file contract revision exchange with the
+// renter and then serves download iterations until the renter stops. If
+// granted is not nil, only the sectors in granted can be downloaded. If proofs
+// is true, the data is followed by Merkle range proofs.
+func (h *Host) managedDownloadLoop(conn net.Conn, granted map[crypto.Hash]struct{}, proofs bool) error {
 	// Get the start time to limit the length of the whole connection.
 	startTime := time.Now()
 	// Perform the file contract revision exchange, giving the renter the most
@@ -265,7 +321,7 @@ func (h *Host) managedRPCDownload(conn net.Conn) error {
 	// Perform a loop that will allow downloads to happen until the maximum
 	// time for a single connection has been reached.
 	for time.Now().Before(startTime.Add(iteratedConnectionTime)) {
-		err := h.managedDownloadIteration(conn, &so)
+		err := h.managedDownloadIteration(conn, &so, granted, proofs)
 		if err == modules.ErrStopResponse {
 			// The renter has indicated that it has finished downloading the
 			// data, therefore there is no error. Return nil.
@@ -61,11 +61,20 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 	// with the ability to reverse them. Then verify the file contract revision
 	// correctly accounts for the changes.
 	var bandwidthRevenue types.Currency // Upload bandwidth.
+	var uploaded uint64
 	var storageRevenue types.Currency
 	var newCollateral types.Currency
 	var sectorsRemoved []crypto.Hash
 	var sectorsGained []crypto.Hash
 	var gainedSectorData [][]byte
+	// The bandwidth is refunded if the iteration fails before the storage
+	// obligation is updated.
+	var charged bool
+	defer func() {
+		if charged {
+			h.managedRefundBandwidth(so.renterKey(), 0, uploaded)
+		}
+	}()
 	err = func() error {
 		for _, modification := range modifications {
 			// Check that the index points to an existing sector root. If the type
@@ -95,6 +104,7 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 				}
 
 				// Update finances.
+				uploaded += modules.SectorSize
 				blocksRemaining := so.proofDeadline() - blockHeight
 				blockBytesCurrency := types.NewCurrency64(uint64(blocksRemaining)).Mul64(modules.SectorSize)
 				bandwidthRevenue = bandwidthRevenue.Add(settings.UploadBandwidthPrice.Mul64(modules.SectorSize))
@@ -123,6 +133,7 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 				copy(sector[modification.Offset:], modification.Data)
 
 				// Update finances.
+				uploaded += uint64(len(modification.Data))
 				bandwidthRevenue = bandwidthRevenue.Add(settings.UploadBandwidthPrice.Mul64(uint64(len(modification.Data))))
 
 				// Update the sectors removed and gained to indicate that the old
@@ -137,7 +148,16 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 			}
 		}
 		newRevenue := storageRevenue.Add(bandwidthRevenue)
-		return extendErr("unable to verify updated contract: ", verifyRevision(*so, revision, blockHeight, newRevenue, newCollateral))
+		err := verifyRevision(*so, revision, blockHeight, newRevenue, newCollateral)
+		if err != nil {
+			return extendErr("unable to verify updated contract: ", err)
+		}
+		// Check that the renter's bandwidth quota allows the upload.
+		if err := h.managedUseBandwidth(so.renterKey(), 0, uploaded); err != nil {
+			return extendErr("unable to accept modifications: ", err)
+		}
+		charged = true
+		return nil
 	}()
 	if err != nil {
 		modules.WriteNegotiationRejection(conn, err) // Error is ignored so that the error type can be preserved in extendErr.
@@ -173,6 +193,7 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 		modules.WriteNegotiationRejection(conn, err) // Error is ignored so that the error type can be preserved in extendErr.
 		return extendErr("could not modify storage obligation: ", ErrorInternal(err.Error()))
 	}
+	charged = false
 
 	// Host will now send acceptance and its signature to the renter. This
 	// iteration is complete. If the finalIter flag is set, StopResponse will
@@ -0,0 +1,35 @@
+package host
+
+import (
+	"net"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// managedRPCSectorRoots sends the most recent revision of a file contract to
+// the renter, followed by the Merkle roots of all sectors covered by the
+// contract. This allows a renter that lost its contract metadata to recover
+// the contract from its seed.
+func (h *Host) managedRPCSectorRoots(conn net.Conn) error {
+	// Perform the file contract revision exchange, which verifies that the
+	// renter controls the contract.
+	_, so, err := h.managedRPCRecentRevision(conn)
+	if err != nil {
+		return extendErr("failed RPCRecentRevision during RPCSectorRoots: ", err)
+	}
+	// The storage obligation is returned with a lock on it. Defer a call to
+	// unlock the storage obligation.
+	defer func() {
+		h.managedUnlockStorageObligation(so.id())
+	}()
+
+	// Sending the roots of a large contract can take a while.
+	conn.SetDeadline(time.Now().Add(modules.NegotiateDownloadTime))
+	err = encoding.WriteObject(conn, so.SectorRoots)
+	if err != nil {
+		return extendErr("failed to write sector roots: ", ErrorConnection(err.Error()))
+	}
+	return nil
+}
@@ -43,6 +43,7 @@ func (h *Host) externalSettings() modules.HostExternalSettings {
 		contractPrice = h.settings.MinContractPrice
 	}
 
+	prices := h.prices()
 	return modules.HostExternalSettings{
 		AcceptingContracts:   h.settings.AcceptingContracts,
 		MaxDownloadBatchSize: h.settings.MaxDownloadBatchSize,
@@ -59,9 +60,9 @@ func (h *Host) externalSettings() modules.HostExternalSettings {
 		MaxCollateral: h.settings.MaxCollateral,
 
 		ContractPrice:          contractPrice,
-		DownloadBandwidthPrice: h.settings.MinDownloadBandwidthPrice,
-		StoragePrice:           h.settings.MinStoragePrice,
-		UploadBandwidthPrice:   h.settings.MinUploadBandwidthPrice,
+		DownloadBandwidthPrice: prices.DownloadBandwidthPrice,
+		StoragePrice:           prices.StoragePrice,
+		UploadBandwidthPrice:   prices.UploadBandwidthPrice,
 
 		RevisionNumber: h.revisionNumber,
 		Version:        build.Version,
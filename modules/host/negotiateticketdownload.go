@@ -0,0 +1,105 @@
+package host
+
+import (
+	"net"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/coreos/bbolt"
+)
+
+var (
+	// errTicketExpired is returned if a renter presents a download ticket
+	// that has expired.
+	errTicketExpired = ErrorCommunication("download ticket has expired")
+
+	// errTicketObligation is returned if a renter presents a download ticket
+	// for a storage obligation that has been resolved or has reached its
+	// proof window.
+	errTicketObligation = ErrorCommunication("download ticket is for a storage obligation that is no longer active")
+
+	// errTicketSector is returned if a renter requests a sector that is not
+	// covered by its download ticket.
+	errTicketSector = ErrorCommunication("download ticket does not grant access to the requested sector")
+
+	// errTicketSignature is returned if a download ticket was not signed by
+	// the renter of the contract it grants access to.
+	errTicketSignature = ErrorCommunication("download ticket has an invalid signature")
+)
+
+// managedVerifyTicket checks that a download ticket is valid and returns the
+// set of sectors it grants access to. Ranges of the ticket that extend past
+// the sectors of its contract are cut short.
+func (h *Host) managedVerifyTicket(ticket modules.DownloadTicket) (map[crypto.Hash]struct{}, error) {
+	h.mu.RLock()
+	blockHeight := h.blockHeight
+	var so storageObligation
+	err := h.db.View(func(tx *bolt.Tx) (err error) {
+		so, err = getStorageObligation(tx, ticket.ContractID)
+		return err
+	})
+	h.mu.RUnlock()
+	if err != nil {
+		return nil, extendErr("could not fetch "+ticket.ContractID.String()+": ", ErrorCommunication(err.Error()))
+	}
+	if ticket.Expiration <= blockHeight {
+		return nil, errTicketExpired
+	}
+	if so.ObligationStatus != obligationUnresolved || blockHeight >= so.expiration() {
+		return nil, errTicketObligation
+	}
+
+	// The ticket has to be signed by the renter of the contract.
+	revision := so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1].FileContractRevisions[0]
+	if len(revision.UnlockConditions.PublicKeys) != 2 {
+		return nil, extendErr("wrong public key count for "+ticket.ContractID.String()+": ", ErrorInternal(errRevisionWrongPublicKeyCount.Error()))
+	}
+	var renterPK crypto.PublicKey
+	copy(renterPK[:], revision.UnlockConditions.PublicKeys[0].Key)
+	if crypto.VerifyHash(ticket.SigHash(), renterPK, ticket.Signature) != nil {
+		return nil, errTicketSignature
+	}
+
+	// Only grant access to the sectors of the contract.
+	granted := make(map[crypto.Hash]struct{})
+	numSectors := uint64(len(so.SectorRoots))
+	for _, sr := range ticket.SectorRanges {
+		end := sr.End
+		if end > numSectors {
+			end = numSectors
+		}
+		for i := sr.Start; i < end; i++ {
+			granted[so.SectorRoots[i]] = struct{}{}
+		}
+	}
+	return granted, nil
+}
+
+// managedRPCTicketDownload is responsible for handling an RPC request from a
+// renter to download sectors of another renter's contract. The renter sends a
+// download ticket signed by the renter of that contract, followed by the
+// download loop of RPCProvenDownload, which is paid for with the renter's own
+// contract.
+func (h *Host) managedRPCTicketDownload(conn net.Conn) error {
+	conn.SetDeadline(time.Now().Add(modules.NegotiateDownloadTime))
+
+	// Read and verify the ticket.
+	var ticket modules.DownloadTicket
+	err := encoding.ReadObject(conn, &ticket, modules.NegotiateMaxDownloadTicketSize)
+	if err != nil {
+		return extendErr("failed to read download ticket: ", ErrorConnection(err.Error()))
+	}
+	granted, err := h.managedVerifyTicket(ticket)
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err) // Error not reported to preserve type in extendErr
+		return extendErr("download ticket rejected: ", err)
+	}
+	err = modules.WriteNegotiationAcceptance(conn)
+	if err != nil {
+		return extendErr("failed to write acceptance for download ticket: ", ErrorConnection(err.Error()))
+	}
+	return h.managedDownloadLoop(conn, granted, true)
+}
@@ -0,0 +1,119 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/coreos/bbolt"
+)
+
+// TestVerifyTicket checks that the host only accepts download tickets that
+// are signed by the renter of an active contract and haven't expired, and
+// that a ticket only grants access to the sectors of its contract.
+func TestVerifyTicket(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Store an obligation whose latest revision is signed by the renter key.
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renterSK, renterPK := crypto.GenerateKeyPair()
+	root, _ := randSector()
+	otherRoot, _ := randSector()
+	so.SectorRoots = []crypto.Hash{root, otherRoot}
+	so.RevisionTransactionSet = []types.Transaction{{
+		FileContractRevisions: []types.FileContractRevision{{
+			ParentID:       so.id(),
+			NewWindowStart: so.expiration(),
+			UnlockConditions: types.UnlockConditions{
+				PublicKeys: []types.SiaPublicKey{
+					types.Ed25519PublicKey(renterPK),
+					ht.host.publicKey,
+				},
+				SignaturesRequired: 2,
+			},
+		}},
+	}}
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligation(tx, so)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A valid ticket should only grant access to the sectors of the contract
+	// within its ranges.
+	ticket := modules.DownloadTicket{
+		ContractID:   so.id(),
+		Expiration:   ht.host.blockHeight + 10,
+		SectorRanges: []modules.SectorRange{{Start: 1, End: 5}},
+	}
+	ticket.Signature = crypto.SignHash(ticket.SigHash(), renterSK)
+	granted, err := ht.host.managedVerifyTicket(ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := granted[otherRoot]; !ok || len(granted) != 1 {
+		t.Fatal("wrong sectors were granted:", granted)
+	}
+
+	// Tickets that are expired or weren't signed by the renter should be
+	// rejected.
+	expired := ticket
+	expired.Expiration = ht.host.blockHeight
+	expired.Signature = crypto.SignHash(expired.SigHash(), renterSK)
+	if _, err := ht.host.managedVerifyTicket(expired); err != errTicketExpired {
+		t.Fatal("expected errTicketExpired, got", err)
+	}
+	forged := ticket
+	otherSK, _ := crypto.GenerateKeyPair()
+	forged.Signature = crypto.SignHash(forged.SigHash(), otherSK)
+	if _, err := ht.host.managedVerifyTicket(forged); err != errTicketSignature {
+		t.Fatal("expected errTicketSignature, got", err)
+	}
+	extended := ticket
+	extended.Expiration++
+	if _, err := ht.host.managedVerifyTicket(extended); err != errTicketSignature {
+		t.Fatal("expected errTicketSignature, got", err)
+	}
+	unknown := ticket
+	unknown.ContractID = types.FileContractID{1}
+	if _, err := ht.host.managedVerifyTicket(unknown); err == nil {
+		t.Fatal("ticket for unknown contract was accepted")
+	}
+
+	// Tickets for obligations that have been resolved or reached their proof
+	// window should be rejected.
+	so.ObligationStatus = obligationSucceeded
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligation(tx, so)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ht.host.managedVerifyTicket(ticket); err != errTicketObligation {
+		t.Fatal("expected errTicketObligation, got", err)
+	}
+	so.ObligationStatus = obligationUnresolved
+	so.RevisionTransactionSet[0].FileContractRevisions[0].NewWindowStart = ht.host.blockHeight
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligation(tx, so)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ht.host.managedVerifyTicket(ticket); err != errTicketObligation {
+		t.Fatal("expected errTicketObligation, got", err)
+	}
+}
@@ -23,6 +23,8 @@ import (
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/ratelimit"
 )
 
 // rpcSettingsDeprecated is a specifier for a deprecated settings request.
@@ -228,6 +230,12 @@ func (h *Host) initNetworking(address string) (err error) {
 			<-threadedTrackWorkingStatusClosedChan
 		})
 
+		threadedUpdatePricesClosedChan := make(chan struct{})
+		go h.threadedUpdatePrices(threadedUpdatePricesClosedChan)
+		h.tg.OnStop(func() {
+			<-threadedUpdatePricesClosedChan
+		})
+
 		threadedTrackConnectabilityStatusClosedChan := make(chan struct{})
 		go h.threadedTrackConnectabilityStatus(threadedTrackConnectabilityStatusClosedChan)
 		h.tg.OnStop(func() {
@@ -249,6 +257,10 @@ func (h *Host) threadedHandleConn(conn net.Conn) {
 	}
 	defer h.tg.Done()
 
+	// Apply the host's speed limits to the conn and count the bytes that are
+	// transferred.
+	conn = meteredConn{Conn: ratelimit.NewRLConn(conn, h.rl, h.tg.StopChan()), h: h}
+
 	// Close the conn on host.Close or when the method terminates, whichever comes
 	// first.
 	connCloseChan := make(chan struct{})
@@ -281,15 +293,29 @@ func (h *Host) threadedHandleConn(conn net.Conn) {
 	case modules.RPCDownload:
 		atomic.AddUint64(&h.atomicDownloadCalls, 1)
 		err = extendErr("incoming RPCDownload failed: ", h.managedRPCDownload(conn))
+	case modules.RPCProvenDownload:
+		atomic.AddUint64(&h.atomicDownloadCalls, 1)
+		err = extendErr("incoming RPCProvenDownload failed: ", h.managedRPCProvenDownload(conn))
 	case modules.RPCRenewContract:
 		atomic.AddUint64(&h.atomicRenewCalls, 1)
 		err = extendErr("incoming RPCRenewContract failed: ", h.managedRPCRenewContract(conn))
+		if err == nil {
+			atomic.AddUint64(&h.atomicContractsFormed, 1)
+		}
 	case modules.RPCFormContract:
 		atomic.AddUint64(&h.atomicFormContractCalls, 1)
 		err = extendErr("incoming RPCFormContract failed: ", h.managedRPCFormContract(conn))
+		if err == nil {
+			atomic.AddUint64(&h.atomicContractsFormed, 1)
+		}
 	case modules.RPCReviseContract:
 		atomic.AddUint64(&h.atomicReviseCalls, 1)
 		err = extendErr("incoming RPCReviseContract failed: ", h.managedRPCReviseContract(conn))
+	case modules.RPCSectorRoots:
+		err = extendErr("incoming RPCSectorRoots failed: ", h.managedRPCSectorRoots(conn))
+	case modules.RPCTicketDownload:
+		atomic.AddUint64(&h.atomicDownloadCalls, 1)
+		err = extendErr("incoming RPCTicketDownload failed: ", h.managedRPCTicketDownload(conn))
 	case modules.RPCSettings:
 		atomic.AddUint64(&h.atomicSettingsCalls, 1)
 		err = extendErr("incoming RPCSettings failed: ", h.managedRPCSettings(conn))
@@ -353,5 +379,9 @@ func (h *Host) NetworkMetrics() modules.HostNetworkMetrics {
 		ReviseCalls:       atomic.LoadUint64(&h.atomicReviseCalls),
 		SettingsCalls:     atomic.LoadUint64(&h.atomicSettingsCalls),
 		UnrecognizedCalls: atomic.LoadUint64(&h.atomicUnrecognizedCalls),
+
+		BytesReceived: atomic.LoadUint64(&h.atomicBytesReceived),
+		BytesSent:     atomic.LoadUint64(&h.atomicBytesSent),
+		RenterUsage:   h.currentRenterUsage(),
 	}
 }
@@ -29,10 +29,26 @@ type persistence struct {
 	SecretKey        crypto.SecretKey             `json:"secretkey"`
 	Settings         modules.HostInternalSettings `json:"settings"`
 	UnlockHash       types.UnlockHash             `json:"unlockhash"`
+
+	// Bandwidth usage of the renters in the current quota period.
+	RenterUsage []renterUsage `json:"renterusage"`
+
+	// Dynamic pricing.
+	AnnouncementsScanned bool               `json:"announcementsscanned"`
+	DynamicPrices        modules.HostPrices `json:"dynamicprices"`
+
+	// Alerts of the storage obligations.
+	Alerts []modules.HostAlert `json:"alerts"`
 }
 
 // persistData returns the data in the Host that will be saved to disk.
 func (h *Host) persistData() persistence {
+	var usage []renterUsage
+	for _, u := range h.renterUsage {
+		if u.Period == quotaPeriod(h.blockHeight) {
+			usage = append(usage, u)
+		}
+	}
 	return persistence{
 		// Consensus Tracking.
 		BlockHeight:  h.blockHeight,
@@ -47,6 +63,13 @@ func (h *Host) persistData() persistence {
 		SecretKey:        h.secretKey,
 		Settings:         h.settings,
 		UnlockHash:       h.unlockHash,
+
+		RenterUsage: usage,
+
+		AnnouncementsScanned: h.announcementsScanned,
+		DynamicPrices:        h.dynamicPrices,
+
+		Alerts: h.alertList(),
 	}
 }
 
@@ -75,6 +98,10 @@ func (h *Host) establishDefaults() error {
 	h.secretKey = sk
 	h.publicKey = types.Ed25519PublicKey(pk)
 
+	// A new host subscribes from the beginning of the blockchain, so it
+	// records all host announcements itself.
+	h.announcementsScanned = true
+
 	// Subscribe to the consensus set.
 	err := h.initConsensusSubscription()
 	if err != nil {
@@ -107,6 +134,16 @@ func (h *Host) loadPersistObject(p *persistence) {
 		h.settings.NetAddress = ""
 	}
 	h.unlockHash = p.UnlockHash
+	h.setRateLimits()
+
+	for _, u := range p.RenterUsage {
+		h.renterUsage[u.PublicKey.String()] = u
+	}
+	h.announcementsScanned = p.AnnouncementsScanned
+	h.dynamicPrices = p.DynamicPrices
+	for _, a := range p.Alerts {
+		h.alerts[a.ObligationID] = a
+	}
 }
 
 // initDB will check that the database has been initialized and if not, will
@@ -129,6 +166,11 @@ func (h *Host) initDB() (err error) {
 		// database needs to be initialized. Create the database buckets.
 		buckets := [][]byte{
 			bucketActionItems,
+			bucketAnnouncedHosts,
+			bucketFinancialMetricsHistory,
+			bucketPriceChanges,
+			bucketStorageObligationRevisions,
+			bucketStorageObligationSummaries,
 			bucketStorageObligations,
 		}
 		for _, bucket := range buckets {
@@ -137,7 +179,9 @@ func (h *Host) initDB() (err error) {
 				return err
 			}
 		}
-		return nil
+		// Databases of older hosts don't have the summaries of their
+		// storage obligations yet.
+		return initObligationSummaries(tx)
 	})
 }
 
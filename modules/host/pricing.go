@@ -0,0 +1,526 @@
+package host
+
+// pricing.go implements the dynamic pricing of the host. If dynamic pricing is
+// enabled, the host periodically recomputes its storage and bandwidth prices
+// from the following inputs:
+//
+//  - the fraction of its storage that is in use,
+//  - the fraction of its collateral budget that is locked in contracts,
+//  - the fraction of contract negotiations that succeeded since the previous
+//    update,
+//  - the median prices of the other hosts on the network.
+//
+// The median prices are estimated by requesting the settings of a random
+// sample of the hosts that were announced on the blockchain, which are stored
+// in the host's database along with the blocks that contain them, so that the
+// announcements of reverted blocks can be removed. Hosts that were synced
+// before they started recording announcements rescan the blockchain for them
+// once, when dynamic pricing is enabled. If the median prices are known, the
+// new prices are derived from them, otherwise from the current
+// prices. A full host, a host whose collateral budget is mostly locked and a
+// host that renters readily form contracts with raise their prices, while an
+// empty host and a host that renters turn away from lower them. No price
+// changes by more than maxPriceChange per update, and the prices are bounded
+// by the minimum and maximum prices of the host's settings.
+//
+// Every change of the prices is recorded in the price log, which is stored in
+// the host's database.
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"net"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+	"github.com/coreos/bbolt"
+)
+
+type (
+	// announcedHost is a host that was announced on the blockchain.
+	announcedHost struct {
+		NetAddress modules.NetAddress `json:"netaddress"`
+		PublicKey  types.SiaPublicKey `json:"publickey"`
+	}
+
+	// announcementRecord is the database entry of an announced host. It
+	// contains the announcements of the host in the order in which their
+	// blocks were applied, the most recent one being the current address of
+	// the host.
+	announcementRecord struct {
+		PublicKey     types.SiaPublicKey `json:"publickey"`
+		Announcements []announcement     `json:"announcements"`
+	}
+
+	// announcement is a single announcement of a host.
+	announcement struct {
+		BlockID    types.BlockID      `json:"blockid"`
+		NetAddress modules.NetAddress `json:"netaddress"`
+	}
+
+	// announcementScanner is a consensus set subscriber that records the
+	// hosts announced in the blocks it is sent. It is used to backfill the
+	// announced hosts of a host that was synced before it started recording
+	// them.
+	announcementScanner struct {
+		db        *persist.BoltDatabase
+		log       *persist.Logger
+		publicKey types.SiaPublicKey
+	}
+
+	// pricingInputs are the inputs that the prices are computed from.
+	pricingInputs struct {
+		storageUtilization    float64
+		collateralUtilization float64
+		acceptanceRate        float64
+		networkPrices         modules.HostPrices
+	}
+)
+
+// clampPrice bounds a price by a floor and a ceiling. A zero ceiling means
+// that the price has no ceiling.
+func clampPrice(price, floor, ceiling types.Currency) types.Currency {
+	if !ceiling.IsZero() && price.Cmp(ceiling) > 0 {
+		price = ceiling
+	}
+	if price.Cmp(floor) < 0 {
+		price = floor
+	}
+	return price
+}
+
+// adjustPrice computes a new price. The network price, or the current price
+// if the network price is unknown, is multiplied by the multiplier. The
+// result differs by at most maxPriceChange from the current price and is
+// bounded by the floor and the ceiling.
+func adjustPrice(current, network, floor, ceiling types.Currency, multiplier float64) types.Currency {
+	target := current
+	if !network.IsZero() {
+		target = network
+	}
+	target = target.MulFloat(multiplier)
+	if upper := current.MulFloat(1 + maxPriceChange); target.Cmp(upper) > 0 {
+		target = upper
+	}
+	if lower := current.MulFloat(1 - maxPriceChange); target.Cmp(lower) < 0 {
+		target = lower
+	}
+	return clampPrice(target, floor, ceiling)
+}
+
+// priceMultipliers returns the factors by which the storage price and the
+// bandwidth prices are adjusted.
+func priceMultipliers(in pricingInputs) (storage, bandwidth float64) {
+	storage, bandwidth = 1, 1
+	storage += (in.storageUtilization - 0.5) * storageUtilizationWeight
+	if in.collateralUtilization > 0.5 {
+		storage += (in.collateralUtilization - 0.5) * collateralUtilizationWeight
+	}
+	if in.acceptanceRate >= 0 {
+		storage += (in.acceptanceRate - 0.5) * acceptanceRateWeight
+		bandwidth += (in.acceptanceRate - 0.5) * acceptanceRateWeight
+	}
+	return storage, bandwidth
+}
+
+// medianPrice returns the median of the prices, or zero if there are none.
+func medianPrice(prices []types.Currency) types.Currency {
+	if len(prices) == 0 {
+		return types.ZeroCurrency
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].Cmp(prices[j]) < 0
+	})
+	return prices[len(prices)/2]
+}
+
+// pricesEqual returns whether two sets of prices are equal.
+func pricesEqual(a, b modules.HostPrices) bool {
+	return a.DownloadBandwidthPrice.Equals(b.DownloadBandwidthPrice) &&
+		a.StoragePrice.Equals(b.StoragePrice) &&
+		a.UploadBandwidthPrice.Equals(b.UploadBandwidthPrice)
+}
+
+// prices returns the current storage and bandwidth prices of the host. The
+// caller must hold the lock.
+func (h *Host) prices() modules.HostPrices {
+	if !h.settings.DynamicPricing {
+		return modules.HostPrices{
+			DownloadBandwidthPrice: h.settings.MinDownloadBandwidthPrice,
+			StoragePrice:           h.settings.MinStoragePrice,
+			UploadBandwidthPrice:   h.settings.MinUploadBandwidthPrice,
+		}
+	}
+	return modules.HostPrices{
+		DownloadBandwidthPrice: clampPrice(h.dynamicPrices.DownloadBandwidthPrice, h.settings.MinDownloadBandwidthPrice, h.settings.MaxDownloadBandwidthPrice),
+		StoragePrice:           clampPrice(h.dynamicPrices.StoragePrice, h.settings.MinStoragePrice, h.settings.MaxStoragePrice),
+		UploadBandwidthPrice:   clampPrice(h.dynamicPrices.UploadBandwidthPrice, h.settings.MinUploadBandwidthPrice, h.settings.MaxUploadBandwidthPrice),
+	}
+}
+
+// updateAnnouncements calls fn with the database entry and the address of
+// every host that is announced in a transaction, except for the host with the
+// public key self. The entry is removed if fn leaves it without announcements.
+func updateAnnouncements(tx *bolt.Tx, txn types.Transaction, self types.SiaPublicKey, fn func(*announcementRecord, modules.NetAddress)) error {
+	b := tx.Bucket(bucketAnnouncedHosts)
+	for _, arb := range txn.ArbitraryData {
+		addr, spk, err := modules.DecodeAnnouncement(arb)
+		if err != nil || spk.String() == self.String() {
+			continue
+		}
+		key := []byte(spk.String())
+		record := announcementRecord{PublicKey: spk}
+		if recordBytes := b.Get(key); recordBytes != nil {
+			if err := json.Unmarshal(recordBytes, &record); err != nil {
+				return err
+			}
+		}
+		fn(&record, addr)
+		if len(record.Announcements) == 0 {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+			continue
+		}
+		recordBytes, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(key, recordBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putAnnouncements records the hosts that are announced in a transaction of
+// the block with the provided ID, except for the host with the public key
+// self. Announcements that are already recorded are ignored, because the
+// blockchain scan and the host's subscription may both apply a block.
+func putAnnouncements(tx *bolt.Tx, id types.BlockID, txn types.Transaction, self types.SiaPublicKey) error {
+	return updateAnnouncements(tx, txn, self, func(record *announcementRecord, addr modules.NetAddress) {
+		for _, a := range record.Announcements {
+			if a.BlockID == id && a.NetAddress == addr {
+				return
+			}
+		}
+		record.Announcements = append(record.Announcements, announcement{
+			BlockID:    id,
+			NetAddress: addr,
+		})
+	})
+}
+
+// removeAnnouncements removes the announcements of a transaction of the
+// reverted block with the provided ID, except for the announcements of the
+// host with the public key self.
+func removeAnnouncements(tx *bolt.Tx, id types.BlockID, txn types.Transaction, self types.SiaPublicKey) error {
+	return updateAnnouncements(tx, txn, self, func(record *announcementRecord, addr modules.NetAddress) {
+		for i := len(record.Announcements) - 1; i >= 0; i-- {
+			if a := record.Announcements[i]; a.BlockID == id && a.NetAddress == addr {
+				record.Announcements = append(record.Announcements[:i], record.Announcements[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+// ProcessConsensusChange records the hosts that are announced in the applied
+// blocks of a consensus change and removes the announcements of the reverted
+// blocks.
+func (as *announcementScanner) ProcessConsensusChange(cc modules.ConsensusChange) {
+	err := as.db.Update(func(tx *bolt.Tx) error {
+		for _, block := range cc.RevertedBlocks {
+			for _, txn := range block.Transactions {
+				if err := removeAnnouncements(tx, block.ID(), txn, as.publicKey); err != nil {
+					return err
+				}
+			}
+		}
+		for _, block := range cc.AppliedBlocks {
+			for _, txn := range block.Transactions {
+				if err := putAnnouncements(tx, block.ID(), txn, as.publicKey); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		as.log.Println("ERROR: could not record the announced hosts:", err)
+	}
+}
+
+// managedScanAnnouncements rescans the blockchain for host announcements if
+// the host hasn't recorded all of them yet. The scan is stopped when the host
+// shuts down, and repeated on the next startup.
+func (h *Host) managedScanAnnouncements() error {
+	h.mu.RLock()
+	scanned := h.announcementsScanned
+	scanner := &announcementScanner{
+		db:        h.db,
+		log:       h.log,
+		publicKey: h.publicKey,
+	}
+	h.mu.RUnlock()
+	if scanned {
+		return nil
+	}
+
+	// Blocks that arrive during the scan are also processed by the host's own
+	// subscription, so the scanner can unsubscribe once it has caught up.
+	err := h.cs.ConsensusSetSubscribe(scanner, modules.ConsensusChangeBeginning, h.tg.StopChan())
+	if err != nil {
+		return err
+	}
+	h.cs.Unsubscribe(scanner)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.announcementsScanned = true
+	return h.saveSync()
+}
+
+// announcedHosts returns the hosts that were announced on the blockchain at
+// the address of their most recent announcement, sorted by their public key.
+func (h *Host) announcedHosts() (hosts []announcedHost, err error) {
+	err = h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketAnnouncedHosts).ForEach(func(_, v []byte) error {
+			var record announcementRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			hosts = append(hosts, announcedHost{
+				NetAddress: record.Announcements[len(record.Announcements)-1].NetAddress,
+				PublicKey:  record.PublicKey,
+			})
+			return nil
+		})
+	})
+	return hosts, err
+}
+
+// managedFetchPrices requests the settings of a host and returns its prices.
+func (h *Host) managedFetchPrices(host announcedHost) (modules.HostPrices, error) {
+	dialer := &net.Dialer{
+		Cancel:  h.tg.StopChan(),
+		Timeout: networkPriceTimeout,
+	}
+	conn, err := dialer.Dial("tcp", string(host.NetAddress))
+	if err != nil {
+		return modules.HostPrices{}, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(networkPriceTimeout))
+
+	if err := encoding.WriteObject(conn, modules.RPCSettings); err != nil {
+		return modules.HostPrices{}, err
+	}
+	var pk crypto.PublicKey
+	copy(pk[:], host.PublicKey.Key)
+	var settings modules.HostExternalSettings
+	if err := crypto.ReadSignedObject(conn, &settings, modules.NegotiateMaxHostExternalSettingsLen, pk); err != nil {
+		return modules.HostPrices{}, err
+	}
+	return modules.HostPrices{
+		DownloadBandwidthPrice: settings.DownloadBandwidthPrice,
+		StoragePrice:           settings.StoragePrice,
+		UploadBandwidthPrice:   settings.UploadBandwidthPrice,
+	}, nil
+}
+
+// managedSampleNetworkPrices requests the settings of a random sample of the
+// announced hosts and updates the estimate of the network prices with their
+// median prices. The previous estimate is kept if no host responds.
+func (h *Host) managedSampleNetworkPrices() {
+	hosts, err := h.announcedHosts()
+	if err != nil {
+		h.log.Println("ERROR: could not load the announced hosts:", err)
+		return
+	}
+
+	var download, storage, upload []types.Currency
+	for _, i := range fastrand.Perm(len(hosts)) {
+		if len(storage) == networkPriceSamples {
+			break
+		}
+		prices, err := h.managedFetchPrices(hosts[i])
+		if err != nil {
+			h.log.Debugf("Unable to fetch the prices of host %v: %v", hosts[i].NetAddress, err)
+			continue
+		}
+		download = append(download, prices.DownloadBandwidthPrice)
+		storage = append(storage, prices.StoragePrice)
+		upload = append(upload, prices.UploadBandwidthPrice)
+	}
+	if len(storage) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.networkPrices = modules.HostPrices{
+		DownloadBandwidthPrice: medianPrice(download),
+		StoragePrice:           medianPrice(storage),
+		UploadBandwidthPrice:   medianPrice(upload),
+	}
+	h.networkSamples = len(storage)
+}
+
+// managedPricingInputs collects the inputs of the pricing engine.
+func (h *Host) managedPricingInputs() pricingInputs {
+	// The acceptance rate is computed from the contract negotiations since the
+	// previous update.
+	calls := atomic.LoadUint64(&h.atomicFormContractCalls) + atomic.LoadUint64(&h.atomicRenewCalls)
+	formed := atomic.LoadUint64(&h.atomicContractsFormed)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	in := pricingInputs{
+		acceptanceRate: -1,
+		networkPrices:  h.networkPrices,
+	}
+	if calls > h.lastContractCalls {
+		in.acceptanceRate = float64(formed-h.lastContractsFormed) / float64(calls-h.lastContractCalls)
+	}
+	h.lastContractCalls, h.lastContractsFormed = calls, formed
+
+	total, remaining := h.capacity()
+	if total > 0 {
+		in.storageUtilization = float64(total-remaining) / float64(total)
+	}
+	if !h.settings.CollateralBudget.IsZero() {
+		in.collateralUtilization, _ = new(big.Rat).SetFrac(h.financialMetrics.LockedStorageCollateral.Big(), h.settings.CollateralBudget.Big()).Float64()
+	}
+	return in
+}
+
+// managedUpdatePrices recomputes the prices of the host and records the change
+// in the price log.
+func (h *Host) managedUpdatePrices() {
+	in := h.managedPricingInputs()
+	storageMultiplier, bandwidthMultiplier := priceMultipliers(in)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.settings.DynamicPricing {
+		return
+	}
+	old := h.prices()
+	h.dynamicPrices = modules.HostPrices{
+		DownloadBandwidthPrice: adjustPrice(old.DownloadBandwidthPrice, in.networkPrices.DownloadBandwidthPrice, h.settings.MinDownloadBandwidthPrice, h.settings.MaxDownloadBandwidthPrice, bandwidthMultiplier),
+		StoragePrice:           adjustPrice(old.StoragePrice, in.networkPrices.StoragePrice, h.settings.MinStoragePrice, h.settings.MaxStoragePrice, storageMultiplier),
+		UploadBandwidthPrice:   adjustPrice(old.UploadBandwidthPrice, in.networkPrices.UploadBandwidthPrice, h.settings.MinUploadBandwidthPrice, h.settings.MaxUploadBandwidthPrice, bandwidthMultiplier),
+	}
+	if pricesEqual(h.dynamicPrices, old) {
+		return
+	}
+	h.revisionNumber++
+
+	change := modules.HostPriceChange{
+		BlockHeight: h.blockHeight,
+		Timestamp:   time.Now(),
+
+		StorageUtilization:    in.storageUtilization,
+		CollateralUtilization: in.collateralUtilization,
+		AcceptanceRate:        in.acceptanceRate,
+		NetworkPrices:         in.networkPrices,
+
+		OldPrices: old,
+		NewPrices: h.dynamicPrices,
+	}
+	h.log.Printf("Dynamic pricing changed the prices from %v to %v", old, h.dynamicPrices)
+	err := h.db.Update(func(tx *bolt.Tx) error {
+		return putPriceChange(tx, change)
+	})
+	if err != nil {
+		h.log.Println("ERROR: could not add the price change to the price log:", err)
+	}
+	err = h.saveSync()
+	if err != nil {
+		h.log.Println("ERROR: could not save the host after a price change:", err)
+	}
+}
+
+// threadedUpdatePrices periodically updates the prices of the host while
+// dynamic pricing is enabled. The blockchain is scanned for host
+// announcements the first time the prices are updated.
+func (h *Host) threadedUpdatePrices(closeChan chan struct{}) {
+	defer close(closeChan)
+	for {
+		select {
+		case <-h.tg.StopChan():
+			return
+		case <-time.After(pricingInterval):
+		}
+
+		h.mu.RLock()
+		enabled := h.settings.DynamicPricing
+		h.mu.RUnlock()
+		if !enabled {
+			continue
+		}
+		if err := h.managedScanAnnouncements(); err != nil {
+			h.log.Println("WARN: could not scan the blockchain for host announcements:", err)
+		}
+		h.managedSampleNetworkPrices()
+		h.managedUpdatePrices()
+	}
+}
+
+// putPriceChange appends a change to the price log.
+func putPriceChange(tx *bolt.Tx, change modules.HostPriceChange) error {
+	b := tx.Bucket(bucketPriceChanges)
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	changeBytes, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq) // BigEndian used so bolt will keep the changes sorted.
+	return b.Put(key, changeBytes)
+}
+
+// PriceChanges returns the price log of the host's dynamic pricing, oldest
+// change first.
+func (h *Host) PriceChanges() (changes []modules.HostPriceChange, err error) {
+	if err := h.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer h.tg.Done()
+	err = h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketPriceChanges).ForEach(func(_, v []byte) error {
+			var change modules.HostPriceChange
+			if err := json.Unmarshal(v, &change); err != nil {
+				return err
+			}
+			changes = append(changes, change)
+			return nil
+		})
+	})
+	return changes, err
+}
+
+// Pricing returns the state of the host's dynamic pricing.
+func (h *Host) Pricing() modules.HostPricing {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return modules.HostPricing{
+		Enabled:        h.settings.DynamicPricing,
+		Prices:         h.prices(),
+		NetworkPrices:  h.networkPrices,
+		NetworkSamples: h.networkSamples,
+	}
+}
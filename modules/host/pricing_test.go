@@ -0,0 +1,289 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/coreos/bbolt"
+)
+
+// TestAdjustPrice probes the price computations of the dynamic pricing.
+func TestAdjustPrice(t *testing.T) {
+	current := types.NewCurrency64(1000)
+	floor := types.NewCurrency64(500)
+	ceiling := types.NewCurrency64(1050)
+
+	// The price follows the network price, but changes by at most
+	// maxPriceChange.
+	if p := adjustPrice(current, types.NewCurrency64(1020), floor, types.ZeroCurrency, 1); !p.Equals64(1020) {
+		t.Error("price did not follow the network price:", p)
+	}
+	if p := adjustPrice(current, types.NewCurrency64(5000), floor, types.ZeroCurrency, 1); !p.Equals64(1100) {
+		t.Error("price rose by more than maxPriceChange:", p)
+	}
+	if p := adjustPrice(current, types.ZeroCurrency, floor, types.ZeroCurrency, 0.5); !p.Equals64(900) {
+		t.Error("price fell by more than maxPriceChange:", p)
+	}
+
+	// The price is bounded by the floor and the ceiling.
+	if p := adjustPrice(current, types.NewCurrency64(5000), floor, ceiling, 1); !p.Equals(ceiling) {
+		t.Error("price exceeded the ceiling:", p)
+	}
+	if p := adjustPrice(types.NewCurrency64(520), types.ZeroCurrency, floor, ceiling, 0.5); !p.Equals(floor) {
+		t.Error("price fell below the floor:", p)
+	}
+
+	// A full host raises its storage price, an empty host lowers it.
+	if storage, _ := priceMultipliers(pricingInputs{storageUtilization: 1, acceptanceRate: -1}); storage <= 1 {
+		t.Error("full host does not raise its storage price:", storage)
+	}
+	if storage, _ := priceMultipliers(pricingInputs{storageUtilization: 0, acceptanceRate: -1}); storage >= 1 {
+		t.Error("empty host does not lower its storage price:", storage)
+	}
+	if _, bandwidth := priceMultipliers(pricingInputs{acceptanceRate: 0}); bandwidth >= 1 {
+		t.Error("host that is turned away does not lower its bandwidth price:", bandwidth)
+	}
+
+	prices := []types.Currency{types.NewCurrency64(3), types.NewCurrency64(1), types.NewCurrency64(2)}
+	if m := medianPrice(prices); !m.Equals64(2) {
+		t.Error("wrong median price:", m)
+	}
+	if m := medianPrice(nil); !m.IsZero() {
+		t.Error("median of no prices is not zero:", m)
+	}
+}
+
+// TestDynamicPricing checks that the host adjusts its prices within the
+// bounds of its settings and records the changes in the price log.
+func TestDynamicPricing(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// A ceiling below the floor is rejected.
+	settings := ht.host.InternalSettings()
+	minStoragePrice := settings.MinStoragePrice
+	settings.MaxStoragePrice = minStoragePrice.Div64(2)
+	if err := ht.host.SetInternalSettings(settings); err == nil {
+		t.Fatal("maximum storage price below the minimum storage price was accepted")
+	}
+
+	// Enable dynamic pricing with a network that is much more expensive than
+	// the host.
+	settings.DynamicPricing = true
+	settings.MaxStoragePrice = minStoragePrice.Mul64(2)
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	ht.host.mu.Lock()
+	ht.host.networkPrices = modules.HostPrices{StoragePrice: minStoragePrice.Mul64(10)}
+	ht.host.mu.Unlock()
+
+	ht.host.managedUpdatePrices()
+	pricing := ht.host.Pricing()
+	if pricing.Prices.StoragePrice.Cmp(minStoragePrice) <= 0 {
+		t.Fatal("storage price did not rise:", pricing.Prices.StoragePrice)
+	}
+	for i := 0; i < 20; i++ {
+		ht.host.managedUpdatePrices()
+	}
+	pricing = ht.host.Pricing()
+	if !pricing.Prices.StoragePrice.Equals(settings.MaxStoragePrice) {
+		t.Fatal("storage price did not reach the maximum storage price:", pricing.Prices.StoragePrice)
+	}
+	if es := ht.host.ExternalSettings(); !es.StoragePrice.Equals(pricing.Prices.StoragePrice) {
+		t.Fatal("external settings do not use the dynamic storage price:", es.StoragePrice)
+	}
+
+	// Every change was logged.
+	changes, err := ht.host.PriceChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) < 2 {
+		t.Fatal("expected at least 2 price changes, got", len(changes))
+	}
+	if !changes[0].OldPrices.StoragePrice.Equals(minStoragePrice) {
+		t.Error("wrong old price in the first change:", changes[0].OldPrices.StoragePrice)
+	}
+	if last := changes[len(changes)-1]; !last.NewPrices.StoragePrice.Equals(pricing.Prices.StoragePrice) {
+		t.Error("wrong new price in the last change:", last.NewPrices.StoragePrice)
+	}
+
+	// Disabling dynamic pricing restores the minimum prices.
+	settings.DynamicPricing = false
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if es := ht.host.ExternalSettings(); !es.StoragePrice.Equals(minStoragePrice) {
+		t.Fatal("external settings do not use the minimum storage price:", es.StoragePrice)
+	}
+}
+
+// TestScanAnnouncements checks that the host records the hosts that are
+// announced on the blockchain, and that a host that hasn't recorded them yet
+// finds them by rescanning the blockchain.
+func TestScanAnnouncements(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Announce another host on the blockchain.
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	ann, err := modules.CreateAnnouncement("foo.com:1234", spk, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder, err := ht.wallet.StartTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, fee := ht.tpool.FeeEstimation()
+	fee = fee.Mul64(600)
+	if err := txnBuilder.FundSiacoins(fee); err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddMinerFee(fee)
+	txnBuilder.AddArbitraryData(ann)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.tpool.AcceptTransactionSet(txnSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ht.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := ht.host.announcedHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].PublicKey.String() != spk.String() || hosts[0].NetAddress != "foo.com:1234" {
+		t.Fatal("announced host wasn't recorded:", hosts)
+	}
+
+	// A host that doesn't need to scan the blockchain leaves the announced
+	// hosts alone.
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketAnnouncedHosts).Delete([]byte(spk.String()))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.managedScanAnnouncements(); err != nil {
+		t.Fatal(err)
+	}
+	if hosts, err := ht.host.announcedHosts(); err != nil || len(hosts) != 0 {
+		t.Fatal("announced hosts were scanned again:", hosts, err)
+	}
+
+	// The blockchain isn't scanned while dynamic pricing is disabled.
+	ht.host.mu.Lock()
+	ht.host.announcementsScanned = false
+	ht.host.mu.Unlock()
+	time.Sleep(2 * pricingInterval)
+	ht.host.mu.RLock()
+	scanned := ht.host.announcementsScanned
+	ht.host.mu.RUnlock()
+	if scanned {
+		t.Fatal("blockchain was scanned although dynamic pricing is disabled")
+	}
+
+	// Otherwise the announced hosts are restored by the scan.
+	if err := ht.host.managedScanAnnouncements(); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err = ht.host.announcedHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].PublicKey.String() != spk.String() {
+		t.Fatal("announced host wasn't restored by the scan:", hosts)
+	}
+	ht.host.mu.RLock()
+	scanned = ht.host.announcementsScanned
+	ht.host.mu.RUnlock()
+	if !scanned {
+		t.Fatal("scan wasn't recorded")
+	}
+}
+
+// TestRevertAnnouncements checks that the announcements of reverted blocks
+// are removed from the announced hosts.
+func TestRevertAnnouncements(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Create two blocks that announce another host at different addresses.
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	announce := func(addr modules.NetAddress, nonce byte) types.Block {
+		ann, err := modules.CreateAnnouncement(addr, spk, sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return types.Block{
+			Nonce:        types.BlockNonce{nonce},
+			Transactions: []types.Transaction{{ArbitraryData: [][]byte{ann}}},
+		}
+	}
+	b1, b2 := announce("foo.com:1234", 1), announce("bar.com:1234", 2)
+	scanner := &announcementScanner{
+		db:        ht.host.db,
+		log:       ht.host.log,
+		publicKey: ht.host.publicKey,
+	}
+	addressOf := func() modules.NetAddress {
+		hosts, err := ht.host.announcedHosts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, host := range hosts {
+			if host.PublicKey.String() == spk.String() {
+				return host.NetAddress
+			}
+		}
+		return ""
+	}
+
+	// The most recent announcement is used, also if a block is applied
+	// twice.
+	scanner.ProcessConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{b1, b2}})
+	scanner.ProcessConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{b2}})
+	if addr := addressOf(); addr != "bar.com:1234" {
+		t.Fatal("wrong address of the announced host:", addr)
+	}
+
+	// Reverting a block restores the previous announcement, reverting all
+	// announcements removes the host.
+	scanner.ProcessConsensusChange(modules.ConsensusChange{RevertedBlocks: []types.Block{b2}})
+	if addr := addressOf(); addr != "foo.com:1234" {
+		t.Fatal("reverted announcement wasn't removed:", addr)
+	}
+	scanner.ProcessConsensusChange(modules.ConsensusChange{RevertedBlocks: []types.Block{b1}})
+	if addr := addressOf(); addr != "" {
+		t.Fatal("host of reverted announcements wasn't removed:", addr)
+	}
+}
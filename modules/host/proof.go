@@ -0,0 +1,201 @@
+package host
+
+// proof.go builds the storage proofs of the host and keeps track of the
+// reasons why they fail. Every proof is verified against the Merkle root of
+// the sectors, and by the consensus set before it is submitted. Ahead of the
+// proof window, the host also builds and verifies a proof for a random segment
+// of every storage obligation, which reveals missing or corrupt sectors while
+// there is still time to fix them. Failures are recorded in the storage
+// obligation and raised as alerts, which are removed once the problem is gone.
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/host/contractmanager"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+	"github.com/coreos/bbolt"
+)
+
+var (
+	// errInvalidStorageProof is returned if a storage proof built by the host
+	// does not match the Merkle root of the sectors of the storage obligation.
+	errInvalidStorageProof = errors.New("storage proof does not match the Merkle root of the sectors")
+
+	// errSegmentOutOfRange is returned if the segment of a storage proof is
+	// not covered by the sectors of the storage obligation.
+	errSegmentOutOfRange = errors.New("segment is outside of the sectors of the storage obligation")
+)
+
+// proofError is a failure to build or submit a storage proof, along with its
+// reason, which is one of the modules.ProofFailure constants.
+type proofError struct {
+	cause string
+	err   error
+}
+
+// Error implements the error interface.
+func (pe *proofError) Error() string {
+	return pe.cause + ": " + pe.err.Error()
+}
+
+// managedBuildStorageProof builds the storage proof of a segment of the file
+// of a storage obligation and verifies it against the Merkle root of the
+// sectors of the obligation.
+func (h *Host) managedBuildStorageProof(so storageObligation, segmentIndex uint64) (types.StorageProof, *proofError) {
+	// Pull the sector containing the segment into memory.
+	sectorIndex := segmentIndex / (modules.SectorSize / crypto.SegmentSize)
+	if sectorIndex >= uint64(len(so.SectorRoots)) {
+		return types.StorageProof{}, &proofError{modules.ProofFailureSectorMissing, errSegmentOutOfRange}
+	}
+	sectorBytes, err := h.ReadSector(so.SectorRoots[sectorIndex])
+	if err == contractmanager.ErrSectorNotFound {
+		return types.StorageProof{}, &proofError{modules.ProofFailureSectorMissing, err}
+	} else if err != nil {
+		return types.StorageProof{}, &proofError{modules.ProofFailureReadError, err}
+	}
+
+	// Build the storage proof for just the sector.
+	sectorSegment := segmentIndex % (modules.SectorSize / crypto.SegmentSize)
+	base, cachedHashSet := crypto.MerkleProof(sectorBytes, sectorSegment)
+
+	// Using the sector, build a cached root.
+	log2SectorSize := uint64(0)
+	for 1<<log2SectorSize < (modules.SectorSize / crypto.SegmentSize) {
+		log2SectorSize++
+	}
+	ct := crypto.NewCachedTree(log2SectorSize)
+	ct.SetIndex(segmentIndex)
+	for _, root := range so.SectorRoots {
+		ct.Push(root)
+	}
+	hashSet := ct.Prove(base, cachedHashSet)
+	sp := types.StorageProof{
+		ParentID: so.id(),
+		HashSet:  hashSet,
+	}
+	copy(sp.Segment[:], base)
+
+	// Verify the proof against the Merkle root of the file contract in the
+	// same way as the consensus set, which reveals sectors whose data is
+	// corrupt and sector roots that don't match the contract.
+	if !crypto.VerifySegment(sp.Segment[:], sp.HashSet, crypto.CalculateLeaves(so.fileSize()), segmentIndex, so.merkleRoot()) {
+		return types.StorageProof{}, &proofError{modules.ProofFailureInvalidProof, errInvalidStorageProof}
+	}
+	return sp, nil
+}
+
+// managedPreflightStorageProof builds and verifies a storage proof for a
+// random segment of the file of a storage obligation.
+func (h *Host) managedPreflightStorageProof(so storageObligation) *proofError {
+	segments := uint64(len(so.SectorRoots)) * (modules.SectorSize / crypto.SegmentSize)
+	_, pe := h.managedBuildStorageProof(so, fastrand.Uint64n(segments))
+	return pe
+}
+
+// managedRecordProofFailure records why a storage proof of the storage
+// obligation failed and raises an alert of the given severity.
+func (h *Host) managedRecordProofFailure(so *storageObligation, severity string, pe *proofError) {
+	h.log.Printf("Storage proof of %v failed: %v", so.id(), pe)
+	so.ProofFailure = pe.cause
+
+	h.mu.Lock()
+	message := "unable to submit the storage proof: " + pe.err.Error()
+	if severity == modules.HostAlertWarning {
+		message = fmt.Sprintf("storage proof preflight failed, the proof window opens at block %v: %v", so.expiration(), pe.err)
+	}
+	h.alerts[so.id()] = modules.HostAlert{
+		ObligationID: so.id(),
+		Severity:     severity,
+		Cause:        pe.cause,
+		Message:      message,
+		BlockHeight:  h.blockHeight,
+	}
+	h.mu.Unlock()
+	h.managedSaveProofFailure(so)
+}
+
+// managedClearProofFailure removes the failure and the alert of a storage
+// obligation after a storage proof succeeded.
+func (h *Host) managedClearProofFailure(so *storageObligation) {
+	so.ProofFailure = ""
+	h.mu.Lock()
+	delete(h.alerts, so.id())
+	h.mu.Unlock()
+	h.managedSaveProofFailure(so)
+}
+
+// managedSaveProofFailure persists the proof failure of a storage obligation
+// and the alerts of the host.
+func (h *Host) managedSaveProofFailure(so *storageObligation) {
+	err := h.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligation(tx, *so)
+	})
+	if err != nil {
+		h.log.Println("Error updating the storage obligation:", err)
+	}
+	h.mu.Lock()
+	err = h.saveSync()
+	h.mu.Unlock()
+	if err != nil {
+		h.log.Println("Error saving the host alerts:", err)
+	}
+}
+
+// resolveAlert updates the alert of a storage obligation that is being
+// removed. If the host missed the storage proof, a critical alert reports the
+// loss, otherwise the obligation no longer needs attention.
+func (h *Host) resolveAlert(so *storageObligation, sos storageObligationStatus) {
+	delete(h.alerts, so.id())
+	if sos != obligationFailed {
+		return
+	}
+	if so.ProofFailure == "" {
+		so.ProofFailure = modules.ProofFailureNotConfirmed
+	}
+	h.alerts[so.id()] = modules.HostAlert{
+		ObligationID: so.id(),
+		Severity:     modules.HostAlertCritical,
+		Cause:        so.ProofFailure,
+		Message:      fmt.Sprintf("missed the storage proof, lost %v hastings of revenue and %v hastings of collateral", potentialRevenue(so.info()), so.RiskedCollateral),
+		BlockHeight:  h.blockHeight,
+	}
+}
+
+// pruneAlerts removes the alerts that have not been raised again for
+// alertRetention blocks.
+func (h *Host) pruneAlerts() {
+	for id, a := range h.alerts {
+		if a.BlockHeight+alertRetention < h.blockHeight {
+			delete(h.alerts, id)
+		}
+	}
+}
+
+// alertList returns the alerts of the host, oldest first.
+func (h *Host) alertList() []modules.HostAlert {
+	alerts := make([]modules.HostAlert, 0, len(h.alerts))
+	for _, a := range h.alerts {
+		alerts = append(alerts, a)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].BlockHeight != alerts[j].BlockHeight {
+			return alerts[i].BlockHeight < alerts[j].BlockHeight
+		}
+		return alerts[i].ObligationID.String() < alerts[j].ObligationID.String()
+	})
+	return alerts
+}
+
+// Alerts returns the problems with the storage obligations of the host that
+// need the attention of the operator, oldest first.
+func (h *Host) Alerts() []modules.HostAlert {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.alertList()
+}
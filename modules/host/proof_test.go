@@ -0,0 +1,161 @@
+package host
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// withMerkleRoot returns a copy of the storage obligation whose most recent
+// revision commits to the given Merkle root.
+func withMerkleRoot(so storageObligation, root crypto.Hash) storageObligation {
+	rev := so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1].FileContractRevisions[0]
+	rev.NewFileMerkleRoot = root
+	so.RevisionTransactionSet = []types.Transaction{{
+		FileContractRevisions: []types.FileContractRevision{rev},
+	}}
+	return so
+}
+
+// TestStorageProofPreflight checks that the preflight of the storage proofs
+// detects missing and corrupt sectors and sectors that don't match the file
+// contract, and that the failures are recorded and raised as alerts.
+func TestStorageProofPreflight(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedLockStorageObligation(so.id())
+	defer ht.host.managedUnlockStorageObligation(so.id())
+	if err := ht.host.managedAddStorageObligation(so); err != nil {
+		t.Fatal(err)
+	}
+	sectorRoot, sectorData := randSector()
+	so.SectorRoots = []crypto.Hash{sectorRoot}
+	validPayouts, missedPayouts := so.payouts()
+	so.RevisionTransactionSet = []types.Transaction{{
+		FileContractRevisions: []types.FileContractRevision{{
+			ParentID:              so.id(),
+			NewRevisionNumber:     1,
+			NewFileSize:           modules.SectorSize,
+			NewFileMerkleRoot:     sectorRoot,
+			NewWindowStart:        so.expiration(),
+			NewWindowEnd:          so.proofDeadline(),
+			NewValidProofOutputs:  validPayouts,
+			NewMissedProofOutputs: missedPayouts,
+		}},
+	}}
+	ht.host.mu.Lock()
+	err = ht.host.modifyStorageObligation(so, nil, []crypto.Hash{sectorRoot}, [][]byte{sectorData})
+	ht.host.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sector is stored, so the preflight succeeds.
+	if pe := ht.host.managedPreflightStorageProof(so); pe != nil {
+		t.Fatal("preflight of a stored sector failed:", pe)
+	}
+
+	// A sector whose data does not match its root is detected.
+	corruptRoot, _ := randSector()
+	if err := ht.host.AddSector(corruptRoot, sectorData); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := withMerkleRoot(so, corruptRoot)
+	corrupt.SectorRoots = []crypto.Hash{corruptRoot}
+	if pe := ht.host.managedPreflightStorageProof(corrupt); pe == nil || pe.cause != modules.ProofFailureInvalidProof {
+		t.Fatal("expected an invalid proof, got", pe)
+	}
+
+	// A stored sector that doesn't match the Merkle root of the file
+	// contract is detected.
+	mismatched := withMerkleRoot(so, corruptRoot)
+	if pe := ht.host.managedPreflightStorageProof(mismatched); pe == nil || pe.cause != modules.ProofFailureInvalidProof {
+		t.Fatal("expected an invalid proof, got", pe)
+	}
+
+	// A missing sector is detected, recorded and raised as an alert.
+	if err := ht.host.RemoveSector(sectorRoot); err != nil {
+		t.Fatal(err)
+	}
+	pe := ht.host.managedPreflightStorageProof(so)
+	if pe == nil || pe.cause != modules.ProofFailureSectorMissing {
+		t.Fatal("expected a missing sector, got", pe)
+	}
+	ht.host.managedRecordProofFailure(&so, modules.HostAlertWarning, pe)
+	details, err := ht.host.StorageObligation(so.id(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.ProofFailure != modules.ProofFailureSectorMissing {
+		t.Fatal("proof failure was not recorded:", details.ProofFailure)
+	}
+	alerts := ht.host.Alerts()
+	if len(alerts) != 1 || alerts[0].ObligationID != so.id() || alerts[0].Severity != modules.HostAlertWarning || alerts[0].Cause != modules.ProofFailureSectorMissing {
+		t.Fatal("wrong alerts:", alerts)
+	}
+
+	// A missed storage proof replaces the alert with a critical one, which
+	// expires after alertRetention blocks.
+	ht.host.mu.Lock()
+	ht.host.resolveAlert(&so, obligationFailed)
+	ht.host.mu.Unlock()
+	alerts = ht.host.Alerts()
+	if len(alerts) != 1 || alerts[0].Severity != modules.HostAlertCritical || alerts[0].Cause != modules.ProofFailureSectorMissing {
+		t.Fatal("wrong alerts after a missed storage proof:", alerts)
+	}
+	ht.host.mu.Lock()
+	ht.host.blockHeight += alertRetention + 1
+	ht.host.pruneAlerts()
+	ht.host.blockHeight -= alertRetention + 1
+	ht.host.mu.Unlock()
+	if alerts := ht.host.Alerts(); len(alerts) != 0 {
+		t.Fatal("alert did not expire:", alerts)
+	}
+
+	// The failure and the alert persist across restarts.
+	restart := func() {
+		if err := ht.host.Close(); err != nil {
+			t.Fatal(err)
+		}
+		ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ht.host.managedRecordProofFailure(&so, modules.HostAlertError, pe)
+	restart()
+	if alerts := ht.host.Alerts(); len(alerts) != 1 || alerts[0].Cause != modules.ProofFailureSectorMissing {
+		t.Fatal("alert was not persisted:", alerts)
+	}
+
+	// A successful storage proof clears the failure, also after a restart.
+	ht.host.managedClearProofFailure(&so)
+	if so.ProofFailure != "" || len(ht.host.Alerts()) != 0 {
+		t.Fatal("proof failure was not cleared")
+	}
+	restart()
+	if alerts := ht.host.Alerts(); len(alerts) != 0 {
+		t.Fatal("cleared alert was restored:", alerts)
+	}
+	details, err = ht.host.StorageObligation(so.id(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.ProofFailure != "" {
+		t.Fatal("cleared proof failure was restored:", details.ProofFailure)
+	}
+}
@@ -27,9 +27,6 @@ package host
 
 // TODO: Make sure that not too many action items are being created.
 
-// TODO: The ProofConstructed and NegotiationHeight fields of storageObligation
-// are not set or used.
-
 import (
 	"encoding/binary"
 	"encoding/json"
@@ -149,6 +146,11 @@ type storageObligation struct {
 	ProofConstructed    bool
 	RevisionConfirmed   bool
 	RevisionConstructed bool
+
+	// ProofFailure is the reason why the host was last unable to build or
+	// submit the storage proof, see proof.go. It is empty if the last attempt
+	// succeeded.
+	ProofFailure string
 }
 
 func (i storageObligationStatus) String() string {
@@ -188,7 +190,11 @@ func putStorageObligation(tx *bolt.Tx, so storageObligation) error {
 		return err
 	}
 	soid := so.id()
-	return tx.Bucket(bucketStorageObligations).Put(soid[:], soBytes)
+	err = tx.Bucket(bucketStorageObligations).Put(soid[:], soBytes)
+	if err != nil {
+		return err
+	}
+	return putObligationSummary(tx, so)
 }
 
 // expiration returns the height at which the storage obligation expires.
@@ -213,6 +219,42 @@ func (so storageObligation) id() types.FileContractID {
 	return so.OriginTransactionSet[len(so.OriginTransactionSet)-1].FileContractID(0)
 }
 
+// info returns the information about the storage obligation that is exposed
+// by the host.
+func (so storageObligation) info() modules.StorageObligation {
+	return modules.StorageObligation{
+		ContractCost:             so.ContractCost,
+		DataSize:                 so.fileSize(),
+		LockedCollateral:         so.LockedCollateral,
+		ObligationId:             so.id(),
+		PotentialDownloadRevenue: so.PotentialDownloadRevenue,
+		PotentialStorageRevenue:  so.PotentialStorageRevenue,
+		PotentialUploadRevenue:   so.PotentialUploadRevenue,
+		RiskedCollateral:         so.RiskedCollateral,
+		SectorRootsCount:         uint64(len(so.SectorRoots)),
+		TransactionFeesAdded:     so.TransactionFeesAdded,
+
+		ExpirationHeight:  so.expiration(),
+		NegotiationHeight: so.NegotiationHeight,
+		ProofDeadLine:     so.proofDeadline(),
+
+		ObligationStatus:    so.ObligationStatus.String(),
+		OriginConfirmed:     so.OriginConfirmed,
+		ProofConfirmed:      so.ProofConfirmed,
+		ProofConstructed:    so.ProofConstructed,
+		RevisionConfirmed:   so.RevisionConfirmed,
+		RevisionConstructed: so.RevisionConstructed,
+
+		ProofFailure: so.ProofFailure,
+	}
+}
+
+// renterKey returns the public key of the renter of the storage obligation.
+// The storage obligation must have a revision.
+func (so storageObligation) renterKey() types.SiaPublicKey {
+	return so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1].FileContractRevisions[0].UnlockConditions.PublicKeys[0]
+}
+
 // isSane checks that required assumptions about the storage obligation are
 // correct.
 func (so storageObligation) isSane() error {
@@ -295,6 +337,29 @@ func (so storageObligation) proofDeadline() types.BlockHeight {
 	return so.OriginTransactionSet[len(so.OriginTransactionSet)-1].FileContracts[0].WindowEnd
 }
 
+// revision returns a description of the latest revision of the file contract
+// of the storage obligation, which the host accepted at the provided height.
+func (so storageObligation) revision(height types.BlockHeight) modules.StorageObligationRevision {
+	var revisionNumber uint64
+	if len(so.RevisionTransactionSet) > 0 {
+		revisionNumber = so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1].FileContractRevisions[0].NewRevisionNumber
+	}
+	valid, missed := so.payouts()
+	return modules.StorageObligationRevision{
+		RevisionNumber: revisionNumber,
+		BlockHeight:    height,
+
+		FileMerkleRoot: so.merkleRoot(),
+		FileSize:       so.fileSize(),
+		WindowEnd:      so.proofDeadline(),
+		WindowStart:    so.expiration(),
+
+		MissedHostPayout:  missed[1].Value,
+		ValidHostPayout:   valid[1].Value,
+		ValidRenterPayout: valid[0].Value,
+	}
+}
+
 // value returns the value of fulfilling the storage obligation to the host.
 func (so storageObligation) value() types.Currency {
 	return so.ContractCost.Add(so.PotentialDownloadRevenue).Add(so.PotentialStorageRevenue).Add(so.PotentialUploadRevenue).Add(so.RiskedCollateral)
@@ -383,7 +448,24 @@ func (h *Host) managedAddStorageObligation(so storageObligation) error {
 			if err != nil {
 				return err
 			}
-			return bso.Put(soid[:], soBytes)
+			err = bso.Put(soid[:], soBytes)
+			if err != nil {
+				return err
+			}
+			err = putObligationSummary(tx, so)
+			if err != nil {
+				return err
+			}
+
+			// Start the history of the obligation with the file contract and
+			// the initial revision, if there is one.
+			contract := so
+			contract.RevisionTransactionSet = nil
+			err = putObligationRevision(tx, soid, contract.revision(h.blockHeight))
+			if err != nil || len(so.RevisionTransactionSet) == 0 {
+				return err
+			}
+			return putObligationRevision(tx, soid, so.revision(h.blockHeight))
 		})
 		if err != nil {
 			return err
@@ -506,7 +588,11 @@ func (h *Host) modifyStorageObligation(so storageObligation, sectorsRemoved []cr
 		}
 
 		// Store the new storage obligation to replace the old one.
-		return putStorageObligation(tx, so)
+		err = putStorageObligation(tx, so)
+		if err != nil {
+			return err
+		}
+		return putObligationRevision(tx, soid, so.revision(h.blockHeight))
 	})
 	if err != nil {
 		// Because there was an error, all of the sectors that got added need
@@ -617,6 +703,7 @@ func (h *Host) removeStorageObligation(so storageObligation, sos storageObligati
 	// ended up, and the sector roots are removed because they are large
 	// objects with little purpose once storage proofs are no longer needed.
 	h.financialMetrics.ContractCount--
+	h.resolveAlert(&so, sos)
 	so.ObligationStatus = sos
 	so.SectorRoots = nil
 	return h.db.Update(func(tx *bolt.Tx) error {
@@ -699,6 +786,17 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 		}
 	}
 
+	// Ahead of the proof window, check that the host is able to build a valid
+	// storage proof, which leaves the operator time to fix missing or corrupt
+	// sectors.
+	if len(so.SectorRoots) > 0 && blockHeight >= so.expiration()-revisionSubmissionBuffer && blockHeight < so.expiration() {
+		if pe := h.managedPreflightStorageProof(so); pe != nil {
+			h.managedRecordProofFailure(&so, modules.HostAlertWarning, pe)
+		} else if so.ProofFailure != "" {
+			h.managedClearProofFailure(&so)
+		}
+	}
+
 	// Check if the file contract revision is ready for submission. Check for death.
 	if !so.RevisionConfirmed && len(so.RevisionTransactionSet) > 0 && blockHeight >= so.expiration()-revisionSubmissionBuffer {
 		// Sanity check - there should be a file contract revision.
@@ -809,50 +907,33 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 		// the segment.
 		segmentIndex, err := h.cs.StorageProofSegment(so.id())
 		if err != nil {
-			h.log.Debugln("Host got an error when fetching a storage proof segment:", err)
+			h.managedRecordProofFailure(&so, modules.HostAlertError, &proofError{modules.ProofFailureSegmentUnavailable, err})
 			return
 		}
-		sectorIndex := segmentIndex / (modules.SectorSize / crypto.SegmentSize)
-		// Pull the corresponding sector into memory.
-		sectorRoot := so.SectorRoots[sectorIndex]
-		sectorBytes, err := h.ReadSector(sectorRoot)
-		if err != nil {
-			h.log.Debugln(err)
+		sp, pe := h.managedBuildStorageProof(so, segmentIndex)
+		if pe != nil {
+			h.managedRecordProofFailure(&so, modules.HostAlertError, pe)
 			return
 		}
-
-		// Build the storage proof for just the sector.
-		sectorSegment := segmentIndex % (modules.SectorSize / crypto.SegmentSize)
-		base, cachedHashSet := crypto.MerkleProof(sectorBytes, sectorSegment)
-
-		// Using the sector, build a cached root.
-		log2SectorSize := uint64(0)
-		for 1<<log2SectorSize < (modules.SectorSize / crypto.SegmentSize) {
-			log2SectorSize++
-		}
-		ct := crypto.NewCachedTree(log2SectorSize)
-		ct.SetIndex(segmentIndex)
-		for _, root := range so.SectorRoots {
-			ct.Push(root)
-		}
-		hashSet := ct.Prove(base, cachedHashSet)
-		sp := types.StorageProof{
-			ParentID: so.id(),
-			HashSet:  hashSet,
+		// Check that the proof is valid for the file contract in the consensus
+		// set.
+		_, err = h.cs.TryTransactionSet([]types.Transaction{{StorageProofs: []types.StorageProof{sp}}})
+		if err != nil {
+			h.managedRecordProofFailure(&so, modules.HostAlertError, &proofError{modules.ProofFailureInvalidProof, err})
+			return
 		}
-		copy(sp.Segment[:], base)
 
 		// Create and build the transaction with the storage proof.
 		builder, err := h.wallet.StartTransaction()
 		if err != nil {
-			h.log.Println("Failed to start transaction:", err)
+			h.managedRecordProofFailure(&so, modules.HostAlertError, &proofError{modules.ProofFailureWalletError, err})
 			return
 		}
 		_, feeRecommendation := h.tpool.FeeEstimation()
 		if so.value().Cmp(feeRecommendation) < 0 {
 			// There's no sense submitting the storage proof if the fee is more
 			// than the anticipated revenue.
-			h.log.Debugln("Host not submitting storage proof due to a value that does not sufficiently exceed the fee cost")
+			h.managedRecordProofFailure(&so, modules.HostAlertError, &proofError{modules.ProofFailureInsufficientFees, errors.New("the value of the storage obligation does not exceed the transaction fee")})
 			builder.Drop()
 			return
 		}
@@ -860,7 +941,7 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 		requiredFee := feeRecommendation.Mul64(txnSize)
 		err = builder.FundSiacoins(requiredFee)
 		if err != nil {
-			h.log.Println("Host error when funding a storage proof transaction fee:", err)
+			h.managedRecordProofFailure(&so, modules.HostAlertError, &proofError{modules.ProofFailureInsufficientFees, err})
 			builder.Drop()
 			return
 		}
@@ -868,17 +949,27 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 		builder.AddStorageProof(sp)
 		storageProofSet, err := builder.Sign(true)
 		if err != nil {
-			h.log.Println("Host error when signing the storage proof transaction:", err)
+			h.managedRecordProofFailure(&so, modules.HostAlertError, &proofError{modules.ProofFailureWalletError, err})
 			builder.Drop()
 			return
 		}
 		err = h.tpool.AcceptTransactionSet(storageProofSet)
 		if err != nil {
-			h.log.Println("Host unable to submit storage proof transaction to transaction pool:", err)
 			builder.Drop()
+			// A proof that was submitted before may conflict with the new
+			// one, but it hasn't been confirmed yet either, so the operator
+			// is alerted in both cases.
+			if so.ProofConstructed {
+				err = build.ExtendErr("the storage proof that was submitted before is not confirmed yet and could not be resubmitted", err)
+			}
+			h.managedRecordProofFailure(&so, modules.HostAlertError, &proofError{modules.ProofFailureTpoolRejected, err})
 			return
 		}
+		so.ProofConstructed = true
 		so.TransactionFeesAdded = so.TransactionFeesAdded.Add(requiredFee)
+		if so.ProofFailure != "" {
+			h.managedClearProofFailure(&so)
+		}
 
 		// Queue another action item to check whether the storage proof
 		// got confirmed.
@@ -892,11 +983,7 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID) {
 
 	// Save the storage obligation to account for any fee changes.
 	err = h.db.Update(func(tx *bolt.Tx) error {
-		soBytes, err := json.Marshal(so)
-		if err != nil {
-			return err
-		}
-		return tx.Bucket(bucketStorageObligations).Put(soid[:], soBytes)
+		return putStorageObligation(tx, so)
 	})
 	if err != nil {
 		h.log.Println("Error updating the storage obligations", err)
@@ -919,37 +1006,14 @@ func (h *Host) StorageObligations() (sos []modules.StorageObligation) {
 	defer h.mu.RUnlock()
 
 	err := h.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucketStorageObligations)
+		b := tx.Bucket(bucketStorageObligationSummaries)
 		err := b.ForEach(func(idBytes, soBytes []byte) error {
-			var so storageObligation
+			var so modules.StorageObligation
 			err := json.Unmarshal(soBytes, &so)
 			if err != nil {
 				return build.ExtendErr("unable to unmarshal storage obligation:", err)
 			}
-			mso := modules.StorageObligation{
-				ContractCost:             so.ContractCost,
-				DataSize:                 so.fileSize(),
-				LockedCollateral:         so.LockedCollateral,
-				ObligationId:             so.id(),
-				PotentialDownloadRevenue: so.PotentialDownloadRevenue,
-				PotentialStorageRevenue:  so.PotentialStorageRevenue,
-				PotentialUploadRevenue:   so.PotentialUploadRevenue,
-				RiskedCollateral:         so.RiskedCollateral,
-				SectorRootsCount:         uint64(len(so.SectorRoots)),
-				TransactionFeesAdded:     so.TransactionFeesAdded,
-
-				ExpirationHeight:  so.expiration(),
-				NegotiationHeight: so.NegotiationHeight,
-				ProofDeadLine:     so.proofDeadline(),
-
-				ObligationStatus:    so.ObligationStatus.String(),
-				OriginConfirmed:     so.OriginConfirmed,
-				ProofConfirmed:      so.ProofConfirmed,
-				ProofConstructed:    so.ProofConstructed,
-				RevisionConfirmed:   so.RevisionConfirmed,
-				RevisionConstructed: so.RevisionConstructed,
-			}
-			sos = append(sos, mso)
+			sos = append(sos, so)
 			return nil
 		})
 		if err != nil {
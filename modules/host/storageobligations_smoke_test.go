@@ -244,6 +244,7 @@ func TestSingleSectorStorageObligationStack(t *testing.T) {
 			NewUnlockHash:         types.UnlockConditions{}.UnlockHash(),
 		}},
 	}}
+	so.RevisionTransactionSet = revisionSet
 	ht.host.managedLockStorageObligation(so.id())
 	err = ht.host.modifyStorageObligation(so, nil, []crypto.Hash{sectorRoot}, [][]byte{sectorData})
 	if err != nil {
@@ -481,6 +482,7 @@ func TestMultiSectorStorageObligationStack(t *testing.T) {
 			NewUnlockHash:         types.UnlockConditions{}.UnlockHash(),
 		}},
 	}}
+	so.RevisionTransactionSet = revisionSet2
 	ht.host.managedLockStorageObligation(so.id())
 	err = ht.host.modifyStorageObligation(so, nil, []crypto.Hash{sectorRoot2}, [][]byte{sectorData2})
 	if err != nil {
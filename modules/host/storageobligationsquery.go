@@ -0,0 +1,296 @@
+package host
+
+// storageobligationsquery.go lets the host operator explore the storage
+// obligations. The obligations can be filtered, sorted and paged, and the
+// details of a single obligation include the history of its file contract,
+// which is recorded in the database every time the host accepts a revision.
+//
+// Queries are served from the summaries of the obligations, which are stored
+// next to the obligations whenever they change. Unlike the obligations, the
+// summaries don't contain the sector roots, so their size doesn't grow with
+// the amount of data stored. Only the latest maxObligationRevisions revisions
+// of an obligation are kept in its history.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/coreos/bbolt"
+)
+
+var (
+	// errInvalidObligationSort is returned if a query orders the storage
+	// obligations by an unknown field.
+	errInvalidObligationSort = errors.New("unknown sort key for storage obligations")
+
+	// errInvalidObligationStatus is returned if a query filters the storage
+	// obligations by an unknown status.
+	errInvalidObligationStatus = errors.New("unknown storage obligation status")
+
+	// obligationStatuses maps the statuses that storage obligations can be
+	// filtered by to the obligation statuses.
+	obligationStatuses = map[string]storageObligationStatus{
+		"unresolved": obligationUnresolved,
+		"rejected":   obligationRejected,
+		"succeeded":  obligationSucceeded,
+		"failed":     obligationFailed,
+	}
+
+	// obligationSorts maps the sort keys of the storage obligations to
+	// functions that compare two obligations by that key.
+	obligationSorts = map[string]func(a, b modules.StorageObligation) int{
+		"expirationheight": func(a, b modules.StorageObligation) int {
+			return compareHeights(a.ExpirationHeight, b.ExpirationHeight)
+		},
+		"negotiationheight": func(a, b modules.StorageObligation) int {
+			return compareHeights(a.NegotiationHeight, b.NegotiationHeight)
+		},
+		"datasize": func(a, b modules.StorageObligation) int {
+			switch {
+			case a.DataSize < b.DataSize:
+				return -1
+			case a.DataSize > b.DataSize:
+				return 1
+			}
+			return 0
+		},
+		"lockedcollateral": func(a, b modules.StorageObligation) int {
+			return a.LockedCollateral.Cmp(b.LockedCollateral)
+		},
+		"potentialrevenue": func(a, b modules.StorageObligation) int {
+			return potentialRevenue(a).Cmp(potentialRevenue(b))
+		},
+		"id": func(a, b modules.StorageObligation) int {
+			return bytes.Compare(a.ObligationId[:], b.ObligationId[:])
+		},
+	}
+)
+
+// compareHeights returns -1, 0 or 1 if a is lower than, equal to or higher
+// than b.
+func compareHeights(a, b types.BlockHeight) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// potentialRevenue returns the revenue that the host expects from a storage
+// obligation.
+func potentialRevenue(so modules.StorageObligation) types.Currency {
+	return so.ContractCost.Add(so.PotentialStorageRevenue).Add(so.PotentialUploadRevenue).Add(so.PotentialDownloadRevenue)
+}
+
+// obligationRevisionKey returns the database key of a revision of a storage
+// obligation. The revision number is stored as a big endian uint64, which
+// keeps the revisions of an obligation sorted.
+func obligationRevisionKey(soid types.FileContractID, revisionNumber uint64) []byte {
+	key := make([]byte, len(soid)+8)
+	copy(key, soid[:])
+	binary.BigEndian.PutUint64(key[len(soid):], revisionNumber)
+	return key
+}
+
+// putObligationRevision adds a revision to the history of a storage
+// obligation, pruning the oldest revisions if the history grows beyond
+// maxObligationRevisions.
+func putObligationRevision(tx *bolt.Tx, soid types.FileContractID, rev modules.StorageObligationRevision) error {
+	revBytes, err := json.Marshal(rev)
+	if err != nil {
+		return err
+	}
+	b := tx.Bucket(bucketStorageObligationRevisions)
+	if err := b.Put(obligationRevisionKey(soid, rev.RevisionNumber), revBytes); err != nil {
+		return err
+	}
+
+	var keys [][]byte
+	c := b.Cursor()
+	for k, _ := c.Seek(soid[:]); k != nil && bytes.HasPrefix(k, soid[:]); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	for len(keys) > maxObligationRevisions {
+		if err := b.Delete(keys[0]); err != nil {
+			return err
+		}
+		keys = keys[1:]
+	}
+	return nil
+}
+
+// putObligationSummary places the summary of a storage obligation into the
+// database, overwriting the existing summary if there is one.
+func putObligationSummary(tx *bolt.Tx, so storageObligation) error {
+	summaryBytes, err := json.Marshal(so.info())
+	if err != nil {
+		return err
+	}
+	soid := so.id()
+	return tx.Bucket(bucketStorageObligationSummaries).Put(soid[:], summaryBytes)
+}
+
+// initObligationSummaries stores the summaries of all storage obligations if
+// there are none yet, which is the case for the databases of hosts that
+// didn't record them.
+func initObligationSummaries(tx *bolt.Tx) error {
+	if k, _ := tx.Bucket(bucketStorageObligationSummaries).Cursor().First(); k != nil {
+		return nil
+	}
+	return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+		var so storageObligation
+		if err := json.Unmarshal(soBytes, &so); err != nil {
+			return build.ExtendErr("unable to unmarshal storage obligation", err)
+		}
+		return putObligationSummary(tx, so)
+	})
+}
+
+// getObligationRevisions returns the history of a storage obligation, oldest
+// revision first.
+func getObligationRevisions(tx *bolt.Tx, soid types.FileContractID) ([]modules.StorageObligationRevision, error) {
+	var revs []modules.StorageObligationRevision
+	c := tx.Bucket(bucketStorageObligationRevisions).Cursor()
+	for k, v := c.Seek(soid[:]); k != nil && bytes.HasPrefix(k, soid[:]); k, v = c.Next() {
+		var rev modules.StorageObligationRevision
+		if err := json.Unmarshal(v, &rev); err != nil {
+			return nil, err
+		}
+		revs = append(revs, rev)
+	}
+	return revs, nil
+}
+
+// QueryStorageObligations returns the storage obligations that match the
+// query, along with the total number of matching obligations before paging.
+func (h *Host) QueryStorageObligations(q modules.StorageObligationQuery) ([]modules.StorageObligation, int, error) {
+	if err := h.tg.Add(); err != nil {
+		return nil, 0, err
+	}
+	defer h.tg.Done()
+
+	statuses := make(map[string]bool)
+	for _, status := range q.Statuses {
+		sos, ok := obligationStatuses[status]
+		if !ok {
+			return nil, 0, build.ExtendErr(status, errInvalidObligationStatus)
+		}
+		statuses[sos.String()] = true
+	}
+	sortBy := q.SortBy
+	if sortBy == "" {
+		sortBy = "expirationheight"
+	}
+	cmp, ok := obligationSorts[sortBy]
+	if !ok {
+		return nil, 0, build.ExtendErr(sortBy, errInvalidObligationSort)
+	}
+	if q.Offset < 0 || q.Limit < 0 {
+		return nil, 0, errors.New("offset and limit cannot be negative")
+	}
+
+	var sos []modules.StorageObligation
+	err := h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligationSummaries).ForEach(func(_, summaryBytes []byte) error {
+			var so modules.StorageObligation
+			if err := json.Unmarshal(summaryBytes, &so); err != nil {
+				return build.ExtendErr("unable to unmarshal storage obligation", err)
+			}
+			if len(statuses) > 0 && !statuses[so.ObligationStatus] {
+				return nil
+			}
+			if so.ExpirationHeight < q.MinExpirationHeight || (q.MaxExpirationHeight != 0 && so.ExpirationHeight > q.MaxExpirationHeight) {
+				return nil
+			}
+			if so.NegotiationHeight < q.MinNegotiationHeight || (q.MaxNegotiationHeight != 0 && so.NegotiationHeight > q.MaxNegotiationHeight) {
+				return nil
+			}
+			sos = append(sos, so)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, build.ExtendErr("database failed to provide storage obligations", err)
+	}
+
+	// Sort the obligations, using the id to break ties so that the pages are
+	// stable.
+	sort.Slice(sos, func(i, j int) bool {
+		c := cmp(sos[i], sos[j])
+		if c == 0 {
+			c = obligationSorts["id"](sos[i], sos[j])
+		}
+		if q.Descending {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	total := len(sos)
+	if q.Offset >= total {
+		return []modules.StorageObligation{}, total, nil
+	}
+	sos = sos[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(sos) {
+		sos = sos[:q.Limit]
+	}
+	return sos, total, nil
+}
+
+// StorageObligation returns the details of a storage obligation, including a
+// page of the history of its file contract. A limit of 0 returns all
+// revisions after the offset.
+func (h *Host) StorageObligation(soid types.FileContractID, offset, limit int) (modules.StorageObligationDetails, error) {
+	if err := h.tg.Add(); err != nil {
+		return modules.StorageObligationDetails{}, err
+	}
+	defer h.tg.Done()
+	if offset < 0 || limit < 0 {
+		return modules.StorageObligationDetails{}, errors.New("offset and limit cannot be negative")
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var details modules.StorageObligationDetails
+	err := h.db.View(func(tx *bolt.Tx) error {
+		so, err := getStorageObligation(tx, soid)
+		if err != nil {
+			return err
+		}
+		revs, err := getObligationRevisions(tx, soid)
+		if err != nil {
+			return err
+		}
+		// Obligations that were formed before the host recorded their history
+		// only have their latest revision, and the height at which it was
+		// accepted is unknown.
+		latest := so.revision(so.NegotiationHeight)
+		if len(revs) == 0 || revs[len(revs)-1].RevisionNumber != latest.RevisionNumber {
+			revs = append(revs, latest)
+		}
+		total := len(revs)
+		if offset > total {
+			offset = total
+		}
+		revs = revs[offset:]
+		if limit > 0 && limit < len(revs) {
+			revs = revs[:limit]
+		}
+		details = modules.StorageObligationDetails{
+			StorageObligation: so.info(),
+			Revisions:         revs,
+			TotalRevisions:    total,
+		}
+		return nil
+	})
+	return details, err
+}
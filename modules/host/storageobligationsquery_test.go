@@ -0,0 +1,185 @@
+package host
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/coreos/bbolt"
+)
+
+// TestQueryStorageObligations checks that the storage obligations can be
+// filtered, sorted and paged, and that the history of an obligation is
+// recorded and pruned.
+func TestQueryStorageObligations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Add three obligations that were negotiated at different heights.
+	var sos []storageObligation
+	for i := 0; i < 3; i++ {
+		so, err := ht.newTesterStorageObligation()
+		if err != nil {
+			t.Fatal(err)
+		}
+		so.NegotiationHeight = types.BlockHeight(10 * (i + 1))
+		ht.host.managedLockStorageObligation(so.id())
+		err = ht.host.managedAddStorageObligation(so)
+		ht.host.managedUnlockStorageObligation(so.id())
+		if err != nil {
+			t.Fatal(err)
+		}
+		sos = append(sos, so)
+	}
+
+	// Filter by status and height.
+	results, total, err := ht.host.QueryStorageObligations(modules.StorageObligationQuery{Statuses: []string{"unresolved"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(results) != 3 {
+		t.Fatal("expected 3 unresolved obligations, got", total, len(results))
+	}
+	_, total, err = ht.host.QueryStorageObligations(modules.StorageObligationQuery{Statuses: []string{"succeeded", "failed"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 0 {
+		t.Fatal("expected no resolved obligations, got", total)
+	}
+	results, total, err = ht.host.QueryStorageObligations(modules.StorageObligationQuery{MinNegotiationHeight: 15, MaxNegotiationHeight: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || results[0].ObligationId != sos[1].id() {
+		t.Fatal("height filter returned the wrong obligations:", results)
+	}
+	if _, _, err := ht.host.QueryStorageObligations(modules.StorageObligationQuery{Statuses: []string{"foo"}}); err == nil {
+		t.Fatal("unknown status was accepted")
+	}
+	if _, _, err := ht.host.QueryStorageObligations(modules.StorageObligationQuery{SortBy: "foo"}); err == nil {
+		t.Fatal("unknown sort key was accepted")
+	}
+
+	// Page through the obligations in descending order of negotiation height.
+	q := modules.StorageObligationQuery{SortBy: "negotiationheight", Descending: true, Limit: 2}
+	results, total, err = ht.host.QueryStorageObligations(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(results) != 2 || results[0].ObligationId != sos[2].id() || results[1].ObligationId != sos[1].id() {
+		t.Fatal("wrong first page:", total, results)
+	}
+	q.Offset = 2
+	results, _, err = ht.host.QueryStorageObligations(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ObligationId != sos[0].id() {
+		t.Fatal("wrong second page:", results)
+	}
+	q.Offset = 3
+	if results, _, err = ht.host.QueryStorageObligations(q); err != nil || len(results) != 0 {
+		t.Fatal("expected an empty page, got", results, err)
+	}
+	results, _, err = ht.host.QueryStorageObligations(modules.StorageObligationQuery{SortBy: "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(results); i++ {
+		if bytes.Compare(results[i-1].ObligationId[:], results[i].ObligationId[:]) >= 0 {
+			t.Fatal("obligations are not sorted by id")
+		}
+	}
+
+	// The history of an obligation starts with its file contract and grows
+	// with every revision.
+	so := sos[0]
+	details, err := ht.host.StorageObligation(so.id(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.ObligationId != so.id() || len(details.Revisions) != 1 || details.Revisions[0].RevisionNumber != 0 {
+		t.Fatal("wrong details of a new obligation:", details)
+	}
+	validPayouts, missedPayouts := so.payouts()
+	so.RevisionTransactionSet = []types.Transaction{{
+		FileContractRevisions: []types.FileContractRevision{{
+			ParentID:              so.id(),
+			NewRevisionNumber:     1,
+			NewWindowStart:        so.expiration(),
+			NewWindowEnd:          so.proofDeadline(),
+			NewValidProofOutputs:  validPayouts,
+			NewMissedProofOutputs: missedPayouts,
+		}},
+	}}
+	ht.host.managedLockStorageObligation(so.id())
+	ht.host.mu.Lock()
+	err = ht.host.modifyStorageObligation(so, nil, nil, nil)
+	ht.host.mu.Unlock()
+	ht.host.managedUnlockStorageObligation(so.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	details, err = ht.host.StorageObligation(so.id(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(details.Revisions) != 2 || details.Revisions[1].RevisionNumber != 1 {
+		t.Fatal("revision was not recorded:", details.Revisions)
+	}
+
+	// Only the latest revisions are kept, and they can be paged.
+	for rev := uint64(2); rev <= uint64(maxObligationRevisions)+2; rev++ {
+		so.RevisionTransactionSet[0].FileContractRevisions[0].NewRevisionNumber = rev
+		ht.host.managedLockStorageObligation(so.id())
+		ht.host.mu.Lock()
+		err = ht.host.modifyStorageObligation(so, nil, nil, nil)
+		ht.host.mu.Unlock()
+		ht.host.managedUnlockStorageObligation(so.id())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	details, err = ht.host.StorageObligation(so.id(), 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.TotalRevisions != maxObligationRevisions {
+		t.Fatal("history wasn't pruned:", details.TotalRevisions)
+	}
+	if len(details.Revisions) != 2 || details.Revisions[0].RevisionNumber != 4 || details.Revisions[1].RevisionNumber != 5 {
+		t.Fatal("wrong page of revisions:", details.Revisions)
+	}
+	if _, err := ht.host.StorageObligation(so.id(), -1, 0); err == nil {
+		t.Fatal("negative offset was accepted")
+	}
+
+	// Summaries that are missing from the database are restored.
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(bucketStorageObligationSummaries); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(bucketStorageObligationSummaries); err != nil {
+			return err
+		}
+		return initObligationSummaries(tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, total, err := ht.host.QueryStorageObligations(modules.StorageObligationQuery{}); err != nil || total != 3 {
+		t.Fatal("summaries weren't restored:", total, err)
+	}
+	if _, err := ht.host.StorageObligation(types.FileContractID{}, 0, 0); err != errNoStorageObligation {
+		t.Fatal("expected errNoStorageObligation, got", err)
+	}
+}
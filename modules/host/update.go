@@ -46,6 +46,10 @@ func (h *Host) initRescan() error {
 			if err != nil {
 				return err
 			}
+			err = putObligationSummary(tx, so)
+			if err != nil {
+				return err
+			}
 		}
 		return nil
 	})
@@ -133,6 +137,11 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 		for _, block := range cc.RevertedBlocks {
 			// Look for transactions relevant to open storage obligations.
 			for _, txn := range block.Transactions {
+				// Remove the reverted announcements of other hosts.
+				if err := removeAnnouncements(tx, block.ID(), txn, h.publicKey); err != nil {
+					h.log.Println("Unable to remove the reverted host announcements:", err)
+				}
+
 				// Check for file contracts.
 				if len(txn.FileContracts) > 0 {
 					for j := range txn.FileContracts {
@@ -204,6 +213,11 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 		for _, block := range cc.AppliedBlocks {
 			// Look for transactions relevant to open storage obligations.
 			for _, txn := range block.Transactions {
+				// Record the announced hosts for the dynamic pricing.
+				if err := putAnnouncements(tx, block.ID(), txn, h.publicKey); err != nil {
+					h.log.Println("Unable to record the announced hosts:", err)
+				}
+
 				// Check for file contracts.
 				if len(txn.FileContracts) > 0 {
 					for i := range txn.FileContracts {
@@ -291,6 +305,13 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 				}
 			}
 		}
+
+		// Record the financial metrics at the new height. A failure does not
+		// abort the processing of the blocks.
+		err := putMetricsSnapshot(tx, h.blockHeight, h.financialMetrics)
+		if err != nil {
+			h.log.Println("Unable to record the financial metrics:", err)
+		}
 		return nil
 	})
 	if err != nil {
@@ -304,6 +325,9 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 	// change.
 	h.recentChange = cc.ID
 
+	// Remove the alerts that have expired.
+	h.pruneAlerts()
+
 	// Save the host.
 	err = h.saveSync()
 	if err != nil {
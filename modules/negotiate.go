@@ -48,6 +48,12 @@ const (
 	// data being requested.
 	NegotiateMaxDownloadActionRequestSize = 50e3
 
+	// NegotiateMaxDownloadTicketSize defines the maximum size that a download
+	// ticket can be when being sent over the wire during negotiation. Tickets
+	// name ranges of sectors rather than single sectors, so the size only
+	// limits how fragmented the granted part of a contract can be.
+	NegotiateMaxDownloadTicketSize = 1e6
+
 	// NegotiateMaxErrorSize indicates the maximum number of bytes that can be
 	// used to encode an error being sent during negotiation.
 	NegotiateMaxErrorSize = 256
@@ -157,6 +163,14 @@ var (
 	// RPCFormContract is the specifier for forming a contract with a host.
 	RPCFormContract = types.Specifier{'F', 'o', 'r', 'm', 'C', 'o', 'n', 't', 'r', 'a', 'c', 't', 2}
 
+	// RPCProvenDownload is the specifier for downloading data from a host
+	// along with Merkle range proofs that prove that the data belongs to the
+	// requested sectors. This allows for downloading parts of sectors, whose
+	// offset and length have to be multiples of crypto.SegmentSize. After the
+	// data, the host sends a proof for every download action, which is empty
+	// for full sectors.
+	RPCProvenDownload = types.Specifier{'P', 'r', 'o', 'v', 'e', 'n', 'D', 'o', 'w', 'n', 'l', 'o', 'a', 'd'}
+
 	// RPCRenewContract is the specifier to renewing an existing contract.
 	RPCRenewContract = types.Specifier{'R', 'e', 'n', 'e', 'w', 'C', 'o', 'n', 't', 'r', 'a', 'c', 't', 2}
 
@@ -164,6 +178,16 @@ var (
 	// contract.
 	RPCReviseContract = types.Specifier{'R', 'e', 'v', 'i', 's', 'e', 'C', 'o', 'n', 't', 'r', 'a', 'c', 't', 2}
 
+	// RPCSectorRoots is the specifier for requesting the most recent revision
+	// of a file contract along with the Merkle roots of its sectors.
+	RPCSectorRoots = types.Specifier{'S', 'e', 'c', 't', 'o', 'r', 'R', 'o', 'o', 't', 's'}
+
+	// RPCTicketDownload is the specifier for downloading sectors of another
+	// renter's file contract with a download ticket, paying for the
+	// bandwidth with the downloader's own file contract. The data is proven
+	// like the data of RPCProvenDownload.
+	RPCTicketDownload = types.Specifier{'T', 'i', 'c', 'k', 'e', 't', 'D', 'o', 'w', 'n', 'l', 'o', 'a', 'd'}
+
 	// RPCSettings is the specifier for requesting settings from the host.
 	RPCSettings = types.Specifier{'S', 'e', 't', 't', 'i', 'n', 'g', 's', 2}
 
@@ -191,6 +215,26 @@ type (
 		Length     uint64
 	}
 
+	// A DownloadTicket grants the bearer read access to sectors of a file
+	// contract until the Expiration height. It is signed by the renter of the
+	// contract, with the key that the renter uses to revise the contract.
+	// Hosts only serve the sectors of the contract whose indices fall into
+	// one of the SectorRanges, and the bearer pays for the bandwidth with a
+	// contract of its own.
+	DownloadTicket struct {
+		ContractID   types.FileContractID `json:"contractid"`
+		Expiration   types.BlockHeight    `json:"expiration"`
+		SectorRanges []SectorRange        `json:"sectorranges"`
+		Signature    crypto.Signature     `json:"signature"`
+	}
+
+	// A SectorRange is the range of sector indices [Start, End) of a file
+	// contract.
+	SectorRange struct {
+		Start uint64 `json:"start"`
+		End   uint64 `json:"end"`
+	}
+
 	// HostAnnouncement is an announcement by the host that appears in the
 	// blockchain. 'Specifier' is always 'PrefixHostAnnouncement'. The
 	// announcement is always followed by a signature from the public key of
@@ -282,6 +326,12 @@ type (
 	}
 )
 
+// SigHash returns the hash of the ticket that is signed by the renter of the
+// contract.
+func (dt DownloadTicket) SigHash() crypto.Hash {
+	return crypto.HashAll(RPCTicketDownload, dt.ContractID, dt.Expiration, dt.SectorRanges)
+}
+
 // ReadNegotiationAcceptance reads an accept/reject response from r (usually a
 // net.Conn). If the response is not AcceptResponse, ReadNegotiationAcceptance
 // returns the response as an error. If the response is StopResponse,
@@ -2,6 +2,7 @@ package modules
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"time"
 
@@ -15,8 +16,95 @@ const (
 	RenterDir = "renter"
 )
 
+var (
+	// ErasureCoderReedSolomon identifies the default Reed-Solomon erasure
+	// coder.
+	ErasureCoderReedSolomon = types.Specifier{'R', 'e', 'e', 'd', '-', 'S', 'o', 'l', 'o', 'm', 'o', 'n'}
+
+	// ErasureCoderReplication identifies the erasure coder that stores full
+	// copies of the data in every piece.
+	ErasureCoderReplication = types.Specifier{'R', 'e', 'p', 'l', 'i', 'c', 'a', 't', 'i', 'o', 'n'}
+
+	// ErasureCoderPartialRS identifies the systematic Reed-Solomon erasure
+	// coder that interleaves its pieces segment by segment, allowing a byte
+	// range of a chunk to be recovered without decoding the whole chunk.
+	ErasureCoderPartialRS = types.Specifier{'P', 'a', 'r', 't', 'i', 'a', 'l', '-', 'R', 'S'}
+)
+
+const (
+	// PriorityLow is the priority class of uploads and downloads that should
+	// only use the resources that are left over by other jobs.
+	PriorityLow Priority = -1
+
+	// PriorityNormal is the default priority class of uploads and downloads.
+	PriorityNormal Priority = 0
+
+	// PriorityHigh is the priority class of uploads and downloads that should
+	// be scheduled before all other jobs.
+	PriorityHigh Priority = 1
+)
+
+// ErrUnknownPriority is returned when parsing a priority class that doesn't
+// exist.
+var ErrUnknownPriority = errors.New("unknown priority, must be 'low', 'normal' or 'high'")
+
+// Priority is the priority class of an upload or download of the renter.
+// Chunks of jobs with a higher priority are scheduled first, and their memory
+// requests are granted first.
+type Priority int
+
+// ParsePriority parses a priority class. The empty string is parsed as
+// PriorityNormal.
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "low":
+		return PriorityLow, nil
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+	return 0, ErrUnknownPriority
+}
+
+// String implements fmt.Stringer.
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return "unknown"
+}
+
+// MarshalJSON encodes a Priority as its name.
+func (p Priority) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON decodes a Priority from its name.
+func (p *Priority) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	priority, err := ParsePriority(s)
+	if err != nil {
+		return err
+	}
+	*p = priority
+	return nil
+}
+
 // An ErasureCoder is an error-correcting encoder and decoder.
 type ErasureCoder interface {
+	// Identifier returns the specifier of the erasure coder. It is persisted
+	// alongside every file so that the file can be decoded later.
+	Identifier() types.Specifier
+
 	// NumPieces is the number of pieces returned by Encode.
 	NumPieces() int
 
@@ -40,6 +128,22 @@ type ErasureCoder interface {
 	Recover(pieces [][]byte, n uint64, w io.Writer) error
 }
 
+// A PartialErasureCoder is an ErasureCoder that is able to recover a byte range
+// of the original data from the corresponding ranges of its pieces.
+type PartialErasureCoder interface {
+	ErasureCoder
+
+	// PieceRange returns the range of every piece that is required to recover
+	// the length bytes at offset of the original data. The range may extend
+	// beyond the end of a piece, in which case it should be truncated.
+	PieceRange(offset, length uint64) (pieceOffset, pieceLength uint64)
+
+	// RecoverRange recovers the length bytes at offset of the original data
+	// and writes them to w. pieces must contain the ranges returned by
+	// PieceRange, with missing pieces set to nil.
+	RecoverRange(pieces [][]byte, offset, length uint64, w io.Writer) error
+}
+
 // An Allowance dictates how much the Renter is allowed to spend in a given
 // period. Note that funds are spent on both storage and bandwidth.
 type Allowance struct {
@@ -47,6 +151,23 @@ type Allowance struct {
 	Hosts       uint64            `json:"hosts"`
 	Period      types.BlockHeight `json:"period"`
 	RenewWindow types.BlockHeight `json:"renewwindow"`
+
+	// The expected usage of the renter in bytes. ExpectedStorage is the
+	// amount of data stored, ExpectedUpload and ExpectedDownload are the
+	// amounts of data uploaded and downloaded during a period. All values
+	// exclude redundancy. They are used to size the contracts; if they are
+	// all zero, the funds are spread evenly across the hosts.
+	ExpectedStorage  uint64 `json:"expectedstorage"`
+	ExpectedUpload   uint64 `json:"expectedupload"`
+	ExpectedDownload uint64 `json:"expecteddownload"`
+
+	// Hard caps on the money spent on storage, upload and download during a
+	// period, and on the price of storing a terabyte for a month. Hosts whose
+	// prices exceed the caps are refused. A zero value means no cap.
+	MaxStorageSpending  types.Currency `json:"maxstoragespending"`
+	MaxUploadSpending   types.Currency `json:"maxuploadspending"`
+	MaxDownloadSpending types.Currency `json:"maxdownloadspending"`
+	MaxPricePerTB       types.Currency `json:"maxpricepertb"`
 }
 
 // ContractUtility contains metrics internal to the contractor that reflect the
@@ -59,11 +180,13 @@ type ContractUtility struct {
 // DownloadInfo provides information about a file that has been requested for
 // download.
 type DownloadInfo struct {
-	Destination     string `json:"destination"`     // The destination of the download.
-	DestinationType string `json:"destinationtype"` // Can be "file", "memory buffer", or "http stream".
-	Length          uint64 `json:"length"`          // The length requested for the download.
-	Offset          uint64 `json:"offset"`          // The offset within the siafile requested for the download.
-	SiaPath         string `json:"siapath"`         // The siapath of the file used for the download.
+	ID              string   `json:"id"`              // The ID of the download, used to cancel it.
+	Destination     string   `json:"destination"`     // The destination of the download.
+	DestinationType string   `json:"destinationtype"` // Can be "file", "memory buffer", or "http stream".
+	Length          uint64   `json:"length"`          // The length requested for the download.
+	Offset          uint64   `json:"offset"`          // The offset within the siafile requested for the download.
+	SiaPath         string   `json:"siapath"`         // The siapath of the file used for the download.
+	Priority        Priority `json:"priority"`        // The priority class of the download.
 
 	Completed            bool      `json:"completed"`            // Whether or not the download has completed.
 	EndTime              time.Time `json:"endtime"`              // The time when the download fully completed.
@@ -73,12 +196,69 @@ type DownloadInfo struct {
 	TotalDataTransferred uint64    `json:"totaldatatransferred"` // Total amount of data transferred, including negotiation, etc.
 }
 
+// StreamInfo provides information about an open stream of a file.
+type StreamInfo struct {
+	ID       string    `json:"id"`       // The ID of the stream.
+	SiaPath  string    `json:"siapath"`  // The siapath of the streamed file.
+	Opened   time.Time `json:"opened"`   // The time when the stream was opened.
+	Offset   uint64    `json:"offset"`   // The offset of the next read.
+	Prefetch uint64    `json:"prefetch"` // The number of chunks fetched ahead of the offset.
+
+	BytesRead     uint64 `json:"bytesread"`     // Amount of data read from the stream.
+	ChunksFetched uint64 `json:"chunksfetched"` // Number of chunks downloaded by the stream.
+	PrefetchHits  uint64 `json:"prefetchhits"`  // Number of chunks that were fetched before they were read.
+}
+
+// MountOptions contains the options of a filesystem mounted by the Renter.
+type MountOptions struct {
+	// SiaPath is the directory that is mounted. The empty siapath mounts all
+	// of the Renter's files.
+	SiaPath string
+
+	// Prefetch is the number of chunks that are fetched ahead of the chunk
+	// being read from an open file.
+	Prefetch uint64
+
+	// CacheSize is the number of chunks that are kept in the stream cache of
+	// the mount. Zero shares the Renter's stream cache.
+	CacheSize uint64
+}
+
+// MountInfo provides information about a filesystem mounted by the Renter.
+type MountInfo struct {
+	MountPoint string    `json:"mountpoint"` // The local directory the filesystem is mounted at.
+	SiaPath    string    `json:"siapath"`    // The mounted directory of the renter.
+	Prefetch   uint64    `json:"prefetch"`   // The prefetch window of open files in chunks.
+	CacheSize  uint64    `json:"cachesize"`  // The size of the mount's own stream cache in chunks, zero if it shares the renter's.
+	Mounted    time.Time `json:"mounted"`    // The time when the filesystem was mounted.
+	ReadOnly   bool      `json:"readonly"`   // Whether the filesystem is read-only.
+}
+
+// A Streamer is an io.ReadSeeker that streams a file from the Sia network. It
+// has to be closed once the stream is done.
+type Streamer interface {
+	io.ReadSeeker
+	io.Closer
+}
+
 // FileUploadParams contains the information used by the Renter to upload a
 // file.
 type FileUploadParams struct {
 	Source      string
 	SiaPath     string
 	ErasureCode ErasureCoder
+
+	// Dedup enables convergent encryption for the file, so that its chunks
+	// are only stored once along with identical chunks of other files.
+	Dedup bool
+
+	// Priority is the priority class of the upload and of the repairs of the
+	// file.
+	Priority Priority
+
+	// CipherType is the cipher that the pieces of the file are encrypted
+	// with. Twofish is used if it is empty.
+	CipherType crypto.CipherType
 }
 
 // FileInfo provides information about a file.
@@ -89,9 +269,90 @@ type FileInfo struct {
 	Available      bool              `json:"available"`
 	Renewing       bool              `json:"renewing"`
 	Redundancy     float64           `json:"redundancy"`
+	ErasureCoder   types.Specifier   `json:"erasurecoder"`
+	Packed         bool              `json:"packed"`
+	Deduplicated   bool              `json:"deduplicated"`
+	Health         float64           `json:"health"`
+	StuckChunks    uint64            `json:"stuckchunks"`
 	UploadedBytes  uint64            `json:"uploadedbytes"`
 	UploadProgress float64           `json:"uploadprogress"`
 	Expiration     types.BlockHeight `json:"expiration"`
+	UploadID       string            `json:"uploadid"`
+	Priority       Priority          `json:"priority"`
+	UploadPaused   bool              `json:"uploadpaused"`
+	CipherType     crypto.CipherType `json:"ciphertype"`
+	Reencrypting   bool              `json:"reencrypting"`
+	Shared         bool              `json:"shared"`
+}
+
+// PackInfo provides information about a pack, a group of sectors that is
+// shared by many small files. The sizes cover all sectors of the pack. Garbage
+// is data of files that were deleted or moved to another pack.
+type PackInfo struct {
+	ID           string `json:"id"`
+	Files        int    `json:"files"`
+	Sectors      uint64 `json:"sectors"`
+	UsedBytes    uint64 `json:"usedbytes"`
+	LiveBytes    uint64 `json:"livebytes"`
+	GarbageBytes uint64 `json:"garbagebytes"`
+}
+
+// ReencryptionInfo provides information about the re-encryption of a file
+// under a new master key and cipher. Progress is the percentage of the new
+// copy of the file that has been uploaded. Error is set if the re-encryption
+// failed, in which case the file is still encrypted the way it was before.
+type ReencryptionInfo struct {
+	SiaPath    string            `json:"siapath"`
+	CipherType crypto.CipherType `json:"ciphertype"`
+	Progress   float64           `json:"progress"`
+	Error      string            `json:"error"`
+}
+
+// BackupInfo provides information about a restored backup. Files contains the
+// siapaths of the restored files; files that already existed are skipped.
+// Contracts is the number of restored or recovered contracts that haven't
+// expired yet.
+type BackupInfo struct {
+	Files     []string `json:"files"`
+	Contracts uint64   `json:"contracts"`
+}
+
+// DedupStats provides information about the renter's deduplicated files. The
+// stored bytes count every sector stored for deduplicated files once, the saved
+// bytes are the bytes that would have been stored without deduplication in
+// addition to that.
+type DedupStats struct {
+	Files        uint64 `json:"files"`
+	Chunks       uint64 `json:"chunks"`
+	UniqueChunks uint64 `json:"uniquechunks"`
+	StoredBytes  uint64 `json:"storedbytes"`
+	SavedBytes   uint64 `json:"savedbytes"`
+}
+
+// ChunkCacheStats contains statistics about the renter's on-disk cache of
+// downloaded chunks. Chunks that failed the integrity check when they were read
+// back are counted as corrupted and as misses. The hits and misses are counted
+// since the renter started.
+type ChunkCacheStats struct {
+	Chunks    uint64 `json:"chunks"`
+	Size      uint64 `json:"size"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Corrupted uint64 `json:"corrupted"`
+}
+
+// DirectoryInfo provides information about a directory of the renter's
+// filesystem. The aggregate fields cover every file in the directory and all
+// of its subdirectories.
+type DirectoryInfo struct {
+	SiaPath           string  `json:"siapath"`
+	NumFiles          uint64  `json:"numfiles"`
+	NumSubDirs        uint64  `json:"numsubdirs"`
+	AggregateNumFiles uint64  `json:"aggregatenumfiles"`
+	AggregateSize     uint64  `json:"aggregatesize"`
+	Health            float64 `json:"health"`
+	MinRedundancy     float64 `json:"minredundancy"`
+	StuckChunks       uint64  `json:"stuckchunks"`
 }
 
 // A HostDBEntry represents one host entry in the Renter's host DB. It
@@ -116,11 +377,87 @@ type HostDBEntry struct {
 
 	LastHistoricUpdate types.BlockHeight
 
+	// Performance measurements of the renter's sessions with the host. The
+	// throughputs are in bytes per second. All values are moving averages
+	// that are zero until the first measurement.
+	DownloadThroughput float64       `json:"downloadthroughput"`
+	UploadThroughput   float64       `json:"uploadthroughput"`
+	Latency            time.Duration `json:"latency"`
+
+	// Filtered is true if the host is excluded by the filter mode of the
+	// hostdb. Contracts with filtered hosts are not renewed.
+	Filtered bool `json:"filtered"`
+
+	// IPNets are the IP subnets of the host's resolved addresses, /24 for IPv4
+	// and /64 for IPv6. They are updated whenever the host is scanned.
+	IPNets []string `json:"ipnets"`
+
 	// The public key of the host, stored separately to minimize risk of certain
 	// MitM based vulnerabilities.
 	PublicKey types.SiaPublicKey `json:"publickey"`
 }
 
+// ErrUnknownFilterMode is returned when parsing a filter mode that doesn't
+// exist.
+var ErrUnknownFilterMode = errors.New("unknown filter mode, must be 'disable', 'blacklist' or 'whitelist'")
+
+// A FilterMode determines which hosts are selected by the hostdb.
+type FilterMode int
+
+const (
+	// HostDBFilterDisabled selects all hosts.
+	HostDBFilterDisabled FilterMode = iota
+	// HostDBFilterBlacklist selects all hosts except for the listed ones.
+	HostDBFilterBlacklist
+	// HostDBFilterWhitelist selects only the listed hosts.
+	HostDBFilterWhitelist
+)
+
+// ParseFilterMode parses a filter mode.
+func ParseFilterMode(s string) (FilterMode, error) {
+	switch s {
+	case "disable":
+		return HostDBFilterDisabled, nil
+	case "blacklist":
+		return HostDBFilterBlacklist, nil
+	case "whitelist":
+		return HostDBFilterWhitelist, nil
+	}
+	return 0, ErrUnknownFilterMode
+}
+
+// String implements fmt.Stringer.
+func (fm FilterMode) String() string {
+	switch fm {
+	case HostDBFilterDisabled:
+		return "disable"
+	case HostDBFilterBlacklist:
+		return "blacklist"
+	case HostDBFilterWhitelist:
+		return "whitelist"
+	}
+	return "unknown"
+}
+
+// MarshalJSON encodes a FilterMode as its name.
+func (fm FilterMode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fm.String())
+}
+
+// UnmarshalJSON decodes a FilterMode from its name.
+func (fm *FilterMode) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	mode, err := ParseFilterMode(s)
+	if err != nil {
+		return err
+	}
+	*fm = mode
+	return nil
+}
+
 // HostDBScan represents a single scan event.
 type HostDBScan struct {
 	Timestamp time.Time `json:"timestamp"`
@@ -142,6 +479,7 @@ type HostScoreBreakdown struct {
 	BurnAdjustment             float64 `json:"burnadjustment"`
 	CollateralAdjustment       float64 `json:"collateraladjustment"`
 	InteractionAdjustment      float64 `json:"interactionadjustment"`
+	PerformanceAdjustment      float64 `json:"performanceadjustment"`
 	PriceAdjustment            float64 `json:"pricesmultiplier"`
 	StorageRemainingAdjustment float64 `json:"storageremainingadjustment"`
 	UptimeAdjustment           float64 `json:"uptimeadjustment"`
@@ -171,6 +509,26 @@ type RenterSettings struct {
 	MaxUploadSpeed   int64     `json:"maxuploadspeed"`
 	MaxDownloadSpeed int64     `json:"maxdownloadspeed"`
 	StreamCacheSize  uint64    `json:"streamcachesize"`
+
+	// ChunkCacheSize is the capacity in bytes of the on-disk cache of
+	// downloaded chunks. Zero disables the cache.
+	ChunkCacheSize uint64 `json:"chunkcachesize"`
+
+	// SubnetDiversity prevents the renter from selecting two hosts in the
+	// same IP subnet. GeoIPDatabase is the path to a local GeoIP database
+	// file; if it is set the renter also doesn't select two hosts in the same
+	// region.
+	SubnetDiversity bool   `json:"subnetdiversity"`
+	GeoIPDatabase   string `json:"geoipdatabase"`
+}
+
+// HostDiversityViolation is a group of hosts that the renter has contracts
+// with that share an IP subnet or a region. Exactly one of Subnet and Region
+// is set.
+type HostDiversityViolation struct {
+	Subnet string               `json:"subnet"`
+	Region string               `json:"region"`
+	Hosts  []types.SiaPublicKey `json:"hosts"`
 }
 
 // HostDBScans represents a sortable slice of scans.
@@ -286,6 +644,51 @@ type ContractorSpending struct {
 	ContractSpendingDeprecated types.Currency `json:"contractspending"`
 }
 
+// DataSpending contains the money that the renter spent on uploading, storing
+// and downloading data.
+type DataSpending struct {
+	// DownloadSpending is the money spent on downloads.
+	DownloadSpending types.Currency `json:"downloadspending"`
+	// StorageSpending is the money spent on storing the uploaded data until
+	// the end of the contracts.
+	StorageSpending types.Currency `json:"storagespending"`
+	// UploadSpending is the money spent on uploads.
+	UploadSpending types.Currency `json:"uploadspending"`
+}
+
+// Add returns the sum of ds and other.
+func (ds DataSpending) Add(other DataSpending) DataSpending {
+	return DataSpending{
+		DownloadSpending: ds.DownloadSpending.Add(other.DownloadSpending),
+		StorageSpending:  ds.StorageSpending.Add(other.StorageSpending),
+		UploadSpending:   ds.UploadSpending.Add(other.UploadSpending),
+	}
+}
+
+// Total returns the sum of the download, storage and upload spending.
+func (ds DataSpending) Total() types.Currency {
+	return ds.DownloadSpending.Add(ds.StorageSpending).Add(ds.UploadSpending)
+}
+
+// FileSpending is an entry of the renter's spending ledger. It contains the
+// money that the renter spent on a file during the allowance period that
+// started at PeriodStart.
+type FileSpending struct {
+	SiaPath     string            `json:"siapath"`
+	PeriodStart types.BlockHeight `json:"periodstart"`
+	DataSpending
+}
+
+// SpendingFilter selects entries of the renter's spending ledger. Only the
+// entries of files whose siapath starts with Prefix and of periods that
+// started between Since and Until are selected. An Until of 0 selects all
+// periods since Since.
+type SpendingFilter struct {
+	Prefix string
+	Since  types.BlockHeight
+	Until  types.BlockHeight
+}
+
 // A Renter uploads, tracks, repairs, and downloads a set of files for the
 // user.
 type Renter interface {
@@ -313,16 +716,53 @@ type Renter interface {
 	// billing period.
 	PeriodSpending() ContractorSpending
 
+	// CreateBackup writes an encrypted backup of the renter's files,
+	// contracts and settings to dst. The backup can only be restored by a
+	// renter whose wallet uses the same seed.
+	CreateBackup(dst string) error
+
+	// CreateDir creates a new directory, including any missing parent
+	// directories.
+	CreateDir(siaPath string) error
+
+	// DeleteDir deletes a directory and all of the files and directories it
+	// contains.
+	DeleteDir(siaPath string) error
+
 	// DeleteFile deletes a file entry from the renter.
 	DeleteFile(path string) error
 
+	// DirList lists the immediate subdirectories and files of a directory.
+	// The first returned DirectoryInfo belongs to the directory itself. The
+	// root directory is referred to by the empty siapath.
+	DirList(siaPath string) ([]DirectoryInfo, []FileInfo, error)
+
+	// ChunkCacheStats returns statistics about the renter's on-disk chunk
+	// cache.
+	ChunkCacheStats() ChunkCacheStats
+
+	// DedupStats returns information about the renter's deduplicated files.
+	DedupStats() DedupStats
+
+	// DiversityViolations returns the groups of hosts that the renter has
+	// contracts with which violate the subnet or region diversity policy.
+	DiversityViolations() []HostDiversityViolation
+
 	// Download performs a download according to the parameters passed, including
 	// downloads of `offset` and `length` type.
 	Download(params RenterDownloadParameters) error
 
 	// Download performs a download according to the parameters passed without
-	// blocking, including downloads of `offset` and `length` type.
-	DownloadAsync(params RenterDownloadParameters) error
+	// blocking, including downloads of `offset` and `length` type. The ID of
+	// the download is returned.
+	DownloadAsync(params RenterDownloadParameters) (string, error)
+
+	// CancelDownload cancels the download with the given ID.
+	CancelDownload(id string) error
+
+	// CancelUpload cancels the upload with the given ID and deletes the file
+	// from the renter. Uploads that have completed can't be cancelled.
+	CancelUpload(id string) error
 
 	// DownloadHistory lists all the files that have been scheduled for download.
 	DownloadHistory() []DownloadInfo
@@ -333,6 +773,10 @@ type Renter interface {
 	// FileList returns information on all of the files stored by the renter.
 	FileList() []FileInfo
 
+	// FilterMode returns the filter mode of the hostdb and the hosts it
+	// applies to.
+	FilterMode() (FilterMode, []types.SiaPublicKey)
+
 	// Host provides the DB entry and score breakdown for the requested host.
 	Host(pk types.SiaPublicKey) (HostDBEntry, bool)
 
@@ -344,10 +788,47 @@ type Renter interface {
 	// renter.
 	LoadSharedFilesASCII(asciiSia string) ([]string, error)
 
+	// LoadTicketShare loads the files of a share created by ShareTickets into
+	// the renter. The files are downloaded with the download tickets of the
+	// share. The paths of the added files are returned.
+	LoadTicketShare(share string) ([]string, error)
+
+	// Mount mounts a directory of the Renter read-only as a local filesystem
+	// at mountPoint.
+	Mount(mountPoint string, opts MountOptions) error
+
+	// Mounts returns information about the mounted filesystems.
+	Mounts() []MountInfo
+
+	// PauseUpload pauses the upload and the repairs of the file with the
+	// given upload ID until ResumeUpload is called.
+	PauseUpload(id string) error
+
+	// Packs returns information about the packs that store the renter's
+	// small files.
+	Packs() []PackInfo
+
 	// PriceEstimation estimates the cost in siacoins of performing various
 	// storage and data operations.
 	PriceEstimation() RenterPriceEstimation
 
+	// Recover recovers the renter's contracts from the blockchain using the
+	// wallet seed, and restores the files and settings of the newest backup
+	// that was uploaded to the hosts by UploadBackup.
+	Recover() (BackupInfo, error)
+
+	// ReencryptFile starts re-uploading a file under a new master key and
+	// the cipher ct, or its current cipher if ct is empty. The file stays
+	// readable under its old key until the new copy has been uploaded.
+	ReencryptFile(siaPath string, ct crypto.CipherType) error
+
+	// Reencryptions returns information about the re-encryptions that are
+	// in progress or have failed.
+	Reencryptions() []ReencryptionInfo
+
+	// RenameDir changes the path of a directory and everything it contains.
+	RenameDir(siaPath, newSiaPath string) error
+
 	// RenameFile changes the path of a file.
 	RenameFile(path, newPath string) error
 
@@ -355,6 +836,13 @@ type Renter interface {
 	// settings, assuming perfect age and uptime adjustments
 	EstimateHostScore(entry HostDBEntry) HostScoreBreakdown
 
+	// ResumeUpload resumes an upload that was paused by PauseUpload.
+	ResumeUpload(id string) error
+
+	// RestoreBackup restores the files, contracts and settings contained in
+	// a backup created by CreateBackup.
+	RestoreBackup(src string) (BackupInfo, error)
+
 	// ScoreBreakdown will return the score for a host db entry using the
 	// hostdb's weighting algorithm.
 	ScoreBreakdown(entry HostDBEntry) HostScoreBreakdown
@@ -362,6 +850,16 @@ type Renter interface {
 	// Settings returns the Renter's current settings.
 	Settings() RenterSettings
 
+	// SpendingLedger returns the entries of the spending ledger selected by
+	// the filter, sorted by siapath and period.
+	SpendingLedger(filter SpendingFilter) []FileSpending
+
+	// SetFilterMode sets the filter mode of the hostdb. In blacklist mode
+	// the hosts are never selected, in whitelist mode only the hosts are
+	// selected. Contracts with filtered hosts are not renewed, and their data
+	// is migrated to other hosts.
+	SetFilterMode(fm FilterMode, hosts []types.SiaPublicKey) error
+
 	// SetSettings sets the Renter's settings.
 	SetSettings(RenterSettings) error
 
@@ -371,13 +869,44 @@ type Renter interface {
 	// ShareFilesAscii creates an ASCII-encoded '.sia' file.
 	ShareFilesASCII(paths []string) (asciiSia string, err error)
 
-	// Streamer creates a io.ReadSeeker that can be used to stream downloads
-	// from the Sia network and also returns the fileName of the streamed
-	// resource.
-	Streamer(siaPath string) (string, io.ReadSeeker, error)
+	// ShareTickets creates an ASCII-encoded share of the files at paths that
+	// contains download tickets for their sectors. The tickets allow another
+	// renter to download the files for duration blocks, paying for the
+	// bandwidth with its own contracts.
+	ShareTickets(paths []string, duration types.BlockHeight) (share string, err error)
+
+	// Streamer creates a Streamer that can be used to stream downloads from
+	// the Sia network and also returns the fileName of the streamed resource.
+	// The streamer fetches up to prefetch chunks ahead of the chunk being
+	// read.
+	Streamer(siaPath string, prefetch uint64) (string, Streamer, error)
+
+	// Streams returns information about the open streams.
+	Streams() []StreamInfo
+
+	// Unmount unmounts the filesystem mounted at mountPoint.
+	Unmount(mountPoint string) error
+
+	// Upload uploads a file using the input parameters. The ID of the upload
+	// is returned.
+	Upload(FileUploadParams) (string, error)
+
+	// UploadBackup uploads an encrypted backup of the renter's files and
+	// settings to its hosts, where Recover can find it.
+	UploadBackup() error
+
+	// UploadStreamFromReader uploads the data read from reader to a new file,
+	// using the input parameters. The Source of the parameters is ignored.
+	UploadStreamFromReader(up FileUploadParams, reader io.Reader) error
+
+	// ResumeUploadStream resumes an interrupted stream upload of the file at
+	// siaPath. offset is the offset of the first byte of reader within the
+	// file; data before the offset returned by UploadStreamOffset is skipped.
+	ResumeUploadStream(siaPath string, offset uint64, reader io.Reader) error
 
-	// Upload uploads a file using the input parameters.
-	Upload(FileUploadParams) error
+	// UploadStreamOffset returns the offset at which an interrupted stream
+	// upload can be resumed.
+	UploadStreamOffset(siaPath string) (uint64, error)
 }
 
 // RenterDownloadParameters defines the parameters passed to the Renter's
@@ -389,4 +918,5 @@ type RenterDownloadParameters struct {
 	Offset      uint64
 	SiaPath     string
 	Destination string
+	Priority    Priority
 }
@@ -0,0 +1,384 @@
+package renter
+
+// backup.go creates and restores backups of the renter's metadata.
+//
+// A backup contains everything that is needed to access the renter's files
+// from another machine: the siafiles along with the pack slots, chunk keys,
+// ciphers, tickets and stuck chunks stored next to them, the directory tree, the contracts and the
+// renter settings. Backups are encrypted with a key derived from the wallet
+// seed, so that a renter can be rebuilt from its seed and a backup.
+//
+// A backup starts with a header and a version, followed by the encrypted and
+// gzipped JSON encoding of the backup object.
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/contractor"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	backupHeader  = types.Specifier{'R', 'e', 'n', 't', 'e', 'r', ' ', 'B', 'a', 'c', 'k', 'u', 'p'}
+	backupVersion = "1.0"
+
+	// backupKeySpecifier is mixed into the seed to derive the key of a
+	// backup.
+	backupKeySpecifier = types.Specifier{'b', 'a', 'c', 'k', 'u', 'p', ' ', 'k', 'e', 'y'}
+
+	// ErrBadBackup is returned when restoring a file that is not a renter
+	// backup.
+	ErrBadBackup = errors.New("not a renter backup")
+
+	// errBackupKey is returned when a backup can't be decrypted.
+	errBackupKey = errors.New("unable to decrypt backup, it may have been created with a different seed")
+)
+
+type (
+	// backup is the encrypted content of a backup. Files contains the .sia
+	// encoding of every file, Health contains the stuck chunks of the files.
+	backup struct {
+		Settings  modules.RenterSettings     `json:"settings"`
+		Tracking  map[string]trackedFile     `json:"tracking"`
+		Dirs      []string                   `json:"dirs"`
+		Files     [][]byte                   `json:"files"`
+		Packs     packsPersist               `json:"packs"`
+		Dedup     dedupPersist               `json:"dedup"`
+		Ciphers   ciphersPersist             `json:"ciphers"`
+		Tickets   ticketsPersist             `json:"tickets"`
+		Health    []fileHealth               `json:"health"`
+		Contracts contractor.ContractsBackup `json:"contracts"`
+	}
+)
+
+// backupKey returns the key used to encrypt the renter's backups. It is derived
+// from the primary seed of the wallet, which must be unlocked.
+func (r *Renter) backupKey() (crypto.TwofishKey, error) {
+	seed, _, err := r.wallet.PrimarySeed()
+	if err != nil {
+		return crypto.TwofishKey{}, err
+	}
+	return crypto.TwofishKey(crypto.HashAll(backupKeySpecifier, seed)), nil
+}
+
+// encryptBackup returns the encrypted and gzipped JSON encoding of b.
+func encryptBackup(b backup, key crypto.TwofishKey) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	zip, _ := gzip.NewWriterLevel(buf, gzip.BestCompression)
+	if err := json.NewEncoder(zip).Encode(b); err != nil {
+		return nil, err
+	}
+	if err := zip.Close(); err != nil {
+		return nil, err
+	}
+	return key.EncryptBytes(buf.Bytes()), nil
+}
+
+// decryptBackup decrypts a backup that was encrypted by encryptBackup.
+func decryptBackup(ciphertext []byte, key crypto.TwofishKey) (b backup, err error) {
+	plaintext, err := key.DecryptBytes(ciphertext)
+	if err != nil {
+		return backup{}, errBackupKey
+	}
+	unzip, err := gzip.NewReader(bytes.NewReader(plaintext))
+	if err != nil {
+		return backup{}, err
+	}
+	err = json.NewDecoder(unzip).Decode(&b)
+	return b, err
+}
+
+// writeBackup encrypts b with key and writes it to dst.
+func writeBackup(b backup, key crypto.TwofishKey, dst string) error {
+	ciphertext, err := encryptBackup(b, key)
+	if err != nil {
+		return err
+	}
+
+	handle, err := persist.NewSafeFile(dst)
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+	err = encoding.NewEncoder(handle).EncodeAll(backupHeader, backupVersion)
+	if err != nil {
+		return err
+	}
+	if _, err := handle.Write(ciphertext); err != nil {
+		return err
+	}
+	return handle.CommitSync()
+}
+
+// readBackup reads the backup at src and decrypts it with key.
+func readBackup(src string, key crypto.TwofishKey) (backup, error) {
+	file, err := os.Open(src)
+	if err != nil {
+		return backup{}, err
+	}
+	defer file.Close()
+
+	var header types.Specifier
+	var version string
+	err = encoding.NewDecoder(file).DecodeAll(&header, &version)
+	if err != nil || header != backupHeader {
+		return backup{}, ErrBadBackup
+	} else if version != backupVersion {
+		return backup{}, ErrIncompatible
+	}
+	ciphertext, err := ioutil.ReadAll(file)
+	if err != nil {
+		return backup{}, err
+	}
+	return decryptBackup(ciphertext, key)
+}
+
+// managedBackup returns a backup of the renter's files and settings. The
+// backup doesn't contain the contracts.
+func (r *Renter) managedBackup() backup {
+	b := backup{
+		Settings: r.Settings(),
+		Tracking: make(map[string]trackedFile),
+		Dirs:     make([]string, 0),
+		Files:    make([][]byte, 0),
+		Health:   make([]fileHealth, 0),
+	}
+
+	id := r.mu.RLock()
+	for siaPath, tf := range r.persist.Tracking {
+		b.Tracking[siaPath] = tf
+	}
+	for siaPath := range r.dirs {
+		if siaPath != "" {
+			b.Dirs = append(b.Dirs, siaPath)
+		}
+	}
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := r.files[name]
+		f.mu.RLock()
+		b.Files = append(b.Files, encoding.Marshal(f))
+		if f.numStuckChunks() > 0 {
+			fh := fileHealth{SiaPath: f.name}
+			for index := range f.stuckChunks {
+				fh.StuckChunks = append(fh.StuckChunks, index)
+			}
+			b.Health = append(b.Health, fh)
+		}
+		f.mu.RUnlock()
+	}
+	b.Packs = r.packsData()
+	b.Dedup = r.dedupData()
+	b.Ciphers = r.ciphersData()
+	b.Tickets = r.ticketsData()
+	r.mu.RUnlock(id)
+
+	// Parents are listed before their subdirectories.
+	sort.Strings(b.Dirs)
+	return b
+}
+
+// CreateBackup writes an encrypted backup of the renter's files, contracts and
+// settings to dst.
+func (r *Renter) CreateBackup(dst string) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	key, err := r.backupKey()
+	if err != nil {
+		return err
+	}
+	b := r.managedBackup()
+	b.Contracts = r.hostContractor.BackupContracts()
+	return writeBackup(b, key, dst)
+}
+
+// restoreFiles adds the directories and files of a backup to the renter,
+// along with their pack slots, chunk keys, ciphers, tickets and stuck chunks. Files that already
+// exist are skipped. The siapaths of the restored files are returned. The
+// caller must hold the renter lock.
+func (r *Renter) restoreFiles(b backup) ([]string, error) {
+	for _, siaPath := range b.Dirs {
+		if err := validateDirSiapath(siaPath); err != nil || siaPath == "" {
+			continue
+		}
+		if err := r.createDir(siaPath); err != nil {
+			return nil, err
+		}
+	}
+
+	// The dedup secret of the backup is only adopted if the renter doesn't
+	// have any deduplicated files of its own.
+	adoptSecret := b.Dedup.Secret != (crypto.Hash{})
+	for _, f := range r.files {
+		f.mu.RLock()
+		adoptSecret = adoptSecret && !f.dedup
+		f.mu.RUnlock()
+	}
+
+	names := make([]string, 0, len(b.Files))
+	files := make(map[string]*file)
+	for _, data := range b.Files {
+		f := new(file)
+		if err := encoding.Unmarshal(data, f); err != nil {
+			return names, err
+		}
+		if err := validateSiapath(f.name); err != nil {
+			r.log.Println("WARN: skipping restored file with invalid siapath:", err)
+			continue
+		}
+		if _, exists := r.files[f.name]; exists {
+			continue
+		}
+		if _, exists := r.dirs[f.name]; exists {
+			continue
+		}
+		r.files[f.name] = f
+		if err := r.linkFile(f); err != nil {
+			return names, err
+		}
+		if tf, exists := b.Tracking[f.name]; exists {
+			r.persist.Tracking[f.name] = tf
+		}
+		files[f.name] = f
+		names = append(names, f.name)
+	}
+
+	for i := range b.Packs.Packs {
+		if _, exists := r.packs[b.Packs.Packs[i].ID]; !exists {
+			r.packs[b.Packs.Packs[i].ID] = &b.Packs.Packs[i]
+		}
+	}
+	for _, pf := range b.Packs.Files {
+		f, exists := files[pf.SiaPath]
+		if !exists || !f.packed() {
+			continue
+		}
+		slot := pf.Slot
+		f.mu.Lock()
+		f.packSlot = &slot
+		f.mu.Unlock()
+	}
+	if adoptSecret {
+		r.dedupSecret = b.Dedup.Secret
+	}
+	for _, df := range b.Dedup.Files {
+		f, exists := files[df.SiaPath]
+		if !exists {
+			continue
+		}
+		f.mu.Lock()
+		f.dedup = true
+		f.chunkKeys = make(map[uint64]crypto.Hash)
+		for chunkIndex, key := range df.ChunkKeys {
+			r.addDedupRef(f, chunkIndex, key)
+		}
+		f.mu.Unlock()
+	}
+	r.restoreCiphers(b.Ciphers, files)
+	r.restoreTickets(b.Tickets, files)
+	for _, fh := range b.Health {
+		f, exists := files[fh.SiaPath]
+		if !exists {
+			continue
+		}
+		f.mu.Lock()
+		for _, index := range fh.StuckChunks {
+			if index < f.numChunks() {
+				f.markChunkStuck(index, true)
+			}
+		}
+		f.mu.Unlock()
+	}
+
+	// Save the restored files and the data stored next to them.
+	if err := r.saveDedup(); err != nil {
+		return names, err
+	}
+	if err := r.saveCiphers(); err != nil {
+		return names, err
+	}
+	if err := r.saveTickets(); err != nil {
+		return names, err
+	}
+	for _, name := range names {
+		if err := r.saveFile(files[name]); err != nil {
+			return names, err
+		}
+	}
+	if err := r.savePacks(); err != nil {
+		return names, err
+	}
+	return names, r.saveSync()
+}
+
+// RestoreBackup restores the files, contracts and settings contained in the
+// backup at src. The renter's settings are only restored if it doesn't have an
+// allowance yet.
+func (r *Renter) RestoreBackup(src string) (modules.BackupInfo, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.BackupInfo{}, err
+	}
+	defer r.tg.Done()
+
+	key, err := r.backupKey()
+	if err != nil {
+		return modules.BackupInfo{}, err
+	}
+	b, err := readBackup(src, key)
+	if err != nil {
+		return modules.BackupInfo{}, err
+	}
+
+	// Restore the contracts first, so that the restored files can be
+	// downloaded right away.
+	contracts, err := r.hostContractor.RestoreContracts(b.Contracts)
+	if err != nil {
+		return modules.BackupInfo{}, err
+	}
+	info := modules.BackupInfo{Contracts: uint64(contracts)}
+	info.Files, err = r.managedRestoreFiles(b)
+	return info, err
+}
+
+// managedRestoreFiles restores the files of a backup, and the renter's
+// settings if it doesn't have an allowance yet. The siapaths of the restored
+// files are returned.
+func (r *Renter) managedRestoreFiles(b backup) ([]string, error) {
+	id := r.mu.Lock()
+	names, err := r.restoreFiles(b)
+	r.mu.Unlock(id)
+	if err != nil {
+		return names, err
+	}
+
+	if reflect.DeepEqual(r.hostContractor.Allowance(), modules.Allowance{}) && !reflect.DeepEqual(b.Settings.Allowance, modules.Allowance{}) {
+		if err := r.SetSettings(b.Settings); err != nil {
+			return names, err
+		}
+	}
+
+	// Let the repair loop pick up the restored files.
+	select {
+	case r.uploadHeap.newUploads <- struct{}{}:
+	default:
+	}
+	return names, nil
+}
@@ -0,0 +1,116 @@
+package renter
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+)
+
+// TestRenterBackup checks that the files of a renter, along with the data
+// stored next to them, can be restored from a backup.
+func TestRenterBackup(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Add a packed file, a deduplicated file with a stuck chunk and an empty
+	// directory.
+	packed, err := rt.addTestingFile("foo/packed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dedup, err := rt.addTestingFile("dedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.CreateDir("empty"); err != nil {
+		t.Fatal(err)
+	}
+	p := &pack{ID: "pack", Files: 1, LiveSize: packed.slotLength(), Size: packed.slotLength()}
+	slot := packSlot{Pack: p.ID, Length: packed.slotLength()}
+	key := crypto.Hash{1}
+	id := rt.renter.mu.Lock()
+	packed.packSlot = &slot
+	rt.renter.packs[p.ID] = p
+	dedup.dedup = true
+	dedup.chunkKeys = make(map[uint64]crypto.Hash)
+	rt.renter.addDedupRef(dedup, 2, key)
+	dedup.markChunkStuck(3, true)
+	rt.renter.mu.Unlock(id)
+
+	dst := filepath.Join(rt.dir, "renter.backup")
+	if err := rt.renter.CreateBackup(dst); err != nil {
+		t.Fatal(err)
+	}
+
+	// Remove everything and restore it from the backup.
+	for _, siaPath := range []string{"foo", "empty"} {
+		if err := rt.renter.DeleteDir(siaPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rt.renter.DeleteFile("dedup"); err != nil {
+		t.Fatal(err)
+	}
+	delete(rt.renter.packs, p.ID)
+	info, err := rt.renter.RestoreBackup(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info.Files, []string{"dedup", "foo/packed"}) || info.Contracts != 0 {
+		t.Fatal("wrong files were restored:", info)
+	}
+	if _, exists := rt.renter.dirs["empty"]; !exists {
+		t.Fatal("empty directory was not restored")
+	}
+	f := rt.renter.files["foo/packed"]
+	if f.packSlot == nil || *f.packSlot != slot || rt.renter.packs[p.ID] == nil {
+		t.Fatal("pack slot was not restored:", f.packSlot)
+	}
+	f = rt.renter.files["dedup"]
+	if !f.dedup || f.chunkKeys[2] != key || len(rt.renter.dedupChunks) != 1 {
+		t.Fatal("chunk keys were not restored:", f.chunkKeys)
+	}
+	if !f.chunkStuck(3) || f.numStuckChunks() != 1 {
+		t.Fatal("stuck chunks were not restored")
+	}
+	if f.masterKey != dedup.masterKey || f.size != dedup.size {
+		t.Fatal("restored file doesn't match the original")
+	}
+
+	// Restoring the backup again should skip the existing files.
+	info, err = rt.renter.RestoreBackup(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Files) != 0 {
+		t.Fatal("existing files were restored again:", info.Files)
+	}
+
+	// Files that aren't backups should be rejected.
+	bad := filepath.Join(rt.dir, "bad.backup")
+	if err := ioutil.WriteFile(bad, []byte("not a backup"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.renter.RestoreBackup(bad); err != ErrBadBackup {
+		t.Fatal("expected ErrBadBackup, got", err)
+	}
+
+	// A renter with a different seed can't decrypt the backup.
+	rt2, err := newRenterTester(t.Name() + "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt2.Close()
+	if _, err := rt2.renter.RestoreBackup(dst); err != errBackupKey {
+		t.Fatal("expected errBackupKey, got", err)
+	}
+}
@@ -0,0 +1,429 @@
+package renter
+
+// chunkcache.go implements an on-disk cache of downloaded chunks.
+//
+// Whenever a download recovers a full chunk, the decrypted chunk is written to
+// the chunk cache directory in the renter's persist dir. Later downloads of
+// the same chunk are served from disk instead of fetching the pieces from the
+// hosts again. The cache is keyed by the hash of the master key of the file
+// and the index of the chunk, so renamed files keep their cached chunks and
+// re-encrypted files don't. When the total size of the cached chunks exceeds
+// the capacity of the cache, the least recently used chunks are evicted.
+//
+// Chunks are written to the cache in the background once they have been
+// delivered to the download destination. If the disk can't keep up, chunks
+// are dropped instead of holding on to their memory.
+//
+// The Merkle root and size of every cached chunk are kept in an index, which
+// is saved periodically and on shutdown together with the LRU order of the
+// chunks. The roots are checked when a chunk is read back. Chunks that were
+// modified or truncated on disk are evicted and downloaded from the hosts
+// instead. On startup, chunks that are missing from the index are removed.
+
+import (
+	"container/list"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+const (
+	// ChunkCacheDir is the name of the directory in the renter's persist dir
+	// that contains the chunk cache.
+	ChunkCacheDir = "chunkcache"
+
+	// chunkCacheIndexFilename is the name of the file in the chunk cache
+	// directory that contains the index of the cached chunks.
+	chunkCacheIndexFilename = "index.json"
+
+	// maxPendingChunkCacheAdds is the number of chunks that can be waiting to
+	// be written to the chunk cache at once.
+	maxPendingChunkCacheAdds = 4
+)
+
+var (
+	chunkCacheMetadata = persist.Metadata{
+		Header:  "Renter Chunk Cache",
+		Version: "1.0",
+	}
+)
+
+type (
+	// chunkCache is an LRU cache of decrypted chunks that is stored on disk.
+	chunkCache struct {
+		capacity uint64
+		size     uint64
+		dir      string
+
+		// entries maps the ids of the cached chunks to their elements in the
+		// lru list. The front of the list is the most recently used chunk.
+		entries map[string]*list.Element
+		lru     *list.List
+
+		hits      uint64
+		misses    uint64
+		corrupted uint64
+
+		// dirty indicates that the index changed since it was last saved.
+		// closed indicates that the cache was closed, after which no more
+		// chunks are added in the background.
+		dirty   bool
+		closed  bool
+		pending sync.WaitGroup
+		addSems chan struct{}
+
+		mu sync.Mutex
+	}
+
+	// cachedChunk is a chunk in the chunk cache.
+	cachedChunk struct {
+		id     string
+		fileID string
+		root   crypto.Hash
+		size   uint64
+	}
+
+	// chunkCachePersist is the object persisted in the index of the chunk
+	// cache. The chunks are ordered from most to least recently used.
+	chunkCachePersist struct {
+		Chunks []cachedChunkPersist `json:"chunks"`
+	}
+
+	// cachedChunkPersist is the persisted form of a cachedChunk.
+	cachedChunkPersist struct {
+		ID     string      `json:"id"`
+		FileID string      `json:"fileid"`
+		Root   crypto.Hash `json:"root"`
+		Size   uint64      `json:"size"`
+	}
+)
+
+// chunkCacheFileID returns the id of a file in the chunk cache. File UIDs are
+// assigned anew every time the renter starts, so the hash of the master key
+// of the file is used instead.
+func chunkCacheFileID(f *file) string {
+	return f.keyHash().String()
+}
+
+// chunkCacheID returns the id of a chunk in the chunk cache.
+func chunkCacheID(fileID string, chunkIndex uint64) string {
+	return fmt.Sprintf("%v-%v", fileID, chunkIndex)
+}
+
+// newChunkCache creates a chunk cache in dir, loading the chunks that were
+// cached by a previous session. If the index can't be loaded, the cache is
+// cleared.
+func newChunkCache(dir string, capacity uint64) (*chunkCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	cc := &chunkCache{
+		capacity: capacity,
+		dir:      dir,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		addSems:  make(chan struct{}, maxPendingChunkCacheAdds),
+	}
+	var ccp chunkCachePersist
+	err := persist.LoadJSON(chunkCacheMetadata, &ccp, cc.indexPath())
+	if err != nil && !os.IsNotExist(err) {
+		ccp.Chunks = nil
+		cc.dirty = true
+	}
+	if err := cc.load(ccp); err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+// indexPath returns the location of the index of the cache.
+func (cc *chunkCache) indexPath() string {
+	return filepath.Join(cc.dir, chunkCacheIndexFilename)
+}
+
+// load adds the chunks of a persisted index that are still on disk to the
+// cache and removes all other files from the cache directory.
+func (cc *chunkCache) load(ccp chunkCachePersist) error {
+	for _, c := range ccp.Chunks {
+		fi, err := os.Stat(cc.path(c.ID))
+		if _, exists := cc.entries[c.ID]; exists || err != nil || uint64(fi.Size()) != c.Size {
+			cc.dirty = true
+			continue
+		}
+		cc.entries[c.ID] = cc.lru.PushBack(&cachedChunk{
+			id:     c.ID,
+			fileID: c.FileID,
+			root:   c.Root,
+			size:   c.Size,
+		})
+		cc.size += c.Size
+	}
+	fis, err := ioutil.ReadDir(cc.dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		if _, exists := cc.entries[fi.Name()]; exists || fi.Name() == chunkCacheIndexFilename {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(cc.dir, fi.Name())); err != nil {
+			return err
+		}
+	}
+	cc.prune()
+	return nil
+}
+
+// path returns the location of a cached chunk on disk.
+func (cc *chunkCache) path(id string) string {
+	return filepath.Join(cc.dir, id)
+}
+
+// remove removes a chunk from the cache. The caller must hold the lock.
+func (cc *chunkCache) remove(e *list.Element) {
+	cached := cc.lru.Remove(e).(*cachedChunk)
+	delete(cc.entries, cached.id)
+	cc.size -= cached.size
+	cc.dirty = true
+	os.Remove(cc.path(cached.id))
+}
+
+// prune evicts the least recently used chunks until the cache fits within
+// its capacity. The caller must hold the lock.
+func (cc *chunkCache) prune() {
+	for cc.size > cc.capacity {
+		cc.remove(cc.lru.Back())
+	}
+}
+
+// Add adds a chunk to the cache. Chunks that are larger than the capacity of
+// the cache are not added.
+func (cc *chunkCache) Add(fileID string, chunkIndex uint64, data []byte) error {
+	id := chunkCacheID(fileID, chunkIndex)
+	cc.mu.Lock()
+	_, exists := cc.entries[id]
+	fits := uint64(len(data)) <= cc.capacity
+	cc.mu.Unlock()
+	if exists || !fits {
+		return nil
+	}
+
+	// Write the chunk to a temporary file first, so that a chunk is never
+	// read while it is being written.
+	tmp := cc.path(id + "_temp")
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, cc.path(id)); err != nil {
+		return err
+	}
+
+	root := crypto.MerkleRoot(data)
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if _, exists := cc.entries[id]; exists {
+		return nil
+	}
+	cc.entries[id] = cc.lru.PushFront(&cachedChunk{
+		id:     id,
+		fileID: fileID,
+		root:   root,
+		size:   uint64(len(data)),
+	})
+	cc.size += uint64(len(data))
+	cc.dirty = true
+	cc.prune()
+	return nil
+}
+
+// AddAsync adds a chunk to the cache in the background. The chunk is dropped
+// if too many chunks are waiting to be added already or if the cache has been
+// closed.
+func (cc *chunkCache) AddAsync(fileID string, chunkIndex uint64, data []byte, log *persist.Logger) {
+	select {
+	case cc.addSems <- struct{}{}:
+	default:
+		return
+	}
+	cc.mu.Lock()
+	if cc.closed {
+		cc.mu.Unlock()
+		<-cc.addSems
+		return
+	}
+	cc.pending.Add(1)
+	cc.mu.Unlock()
+
+	go func() {
+		defer cc.pending.Done()
+		defer func() { <-cc.addSems }()
+		if err := cc.Add(fileID, chunkIndex, data); err != nil {
+			log.Println("WARN: unable to add chunk to the chunk cache:", err)
+		}
+	}()
+}
+
+// Get returns a chunk from the cache. False is returned if the chunk isn't
+// cached or if it doesn't match its Merkle root anymore, in which case it is
+// evicted.
+func (cc *chunkCache) Get(fileID string, chunkIndex uint64) ([]byte, bool) {
+	id := chunkCacheID(fileID, chunkIndex)
+	cc.mu.Lock()
+	e, exists := cc.entries[id]
+	if !exists {
+		if cc.capacity > 0 {
+			cc.misses++
+		}
+		cc.mu.Unlock()
+		return nil, false
+	}
+	if cc.lru.Front() != e {
+		cc.lru.MoveToFront(e)
+		cc.dirty = true
+	}
+	cached := *e.Value.(*cachedChunk)
+	cc.mu.Unlock()
+
+	data, err := ioutil.ReadFile(cc.path(id))
+	valid := err == nil && uint64(len(data)) == cached.size && crypto.MerkleRoot(data) == cached.root
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	e, exists = cc.entries[id]
+	if !exists {
+		// The chunk was evicted while it was read.
+		cc.misses++
+		return nil, false
+	}
+	if !valid {
+		cc.corrupted++
+		cc.misses++
+		cc.remove(e)
+		return nil, false
+	}
+	cc.hits++
+	return data, true
+}
+
+// Retrieve tries to serve a download chunk from the cache. If successful it
+// writes the data to the destination of the download, in the same way as the
+// stream cache. The function returns true if the chunk was in the cache.
+func (cc *chunkCache) Retrieve(udc *unfinishedDownloadChunk) bool {
+	data, ok := cc.Get(udc.staticFileID, udc.staticChunkIndex)
+	if !ok {
+		return false
+	}
+	udc.mu.Lock()
+	defer udc.mu.Unlock()
+	udc.writeCachedChunk(data)
+	return true
+}
+
+// RemoveFile removes all cached chunks of a file.
+func (cc *chunkCache) RemoveFile(fileID string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for e := cc.lru.Front(); e != nil; {
+		next := e.Next()
+		if e.Value.(*cachedChunk).fileID == fileID {
+			cc.remove(e)
+		}
+		e = next
+	}
+}
+
+// SetCapacity sets the capacity of the cache in bytes, evicting chunks if the
+// cache is too large. A capacity of zero disables the cache.
+func (cc *chunkCache) SetCapacity(capacity uint64) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.capacity = capacity
+	cc.prune()
+}
+
+// Save saves the index of the cache if it changed.
+func (cc *chunkCache) Save() error {
+	cc.mu.Lock()
+	if !cc.dirty {
+		cc.mu.Unlock()
+		return nil
+	}
+	ccp := chunkCachePersist{
+		Chunks: make([]cachedChunkPersist, 0, len(cc.entries)),
+	}
+	for e := cc.lru.Front(); e != nil; e = e.Next() {
+		cached := e.Value.(*cachedChunk)
+		ccp.Chunks = append(ccp.Chunks, cachedChunkPersist{
+			ID:     cached.id,
+			FileID: cached.fileID,
+			Root:   cached.root,
+			Size:   cached.size,
+		})
+	}
+	cc.dirty = false
+	cc.mu.Unlock()
+
+	err := persist.SaveJSON(chunkCacheMetadata, ccp, cc.indexPath())
+	if err != nil {
+		// Try again next time.
+		cc.mu.Lock()
+		cc.dirty = true
+		cc.mu.Unlock()
+	}
+	return err
+}
+
+// Close waits for the chunks that are being added in the background and saves
+// the index of the cache.
+func (cc *chunkCache) Close() error {
+	cc.mu.Lock()
+	cc.closed = true
+	cc.mu.Unlock()
+	cc.pending.Wait()
+	return cc.Save()
+}
+
+// Stats returns the statistics of the cache.
+func (cc *chunkCache) Stats() modules.ChunkCacheStats {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return modules.ChunkCacheStats{
+		Chunks:    uint64(len(cc.entries)),
+		Size:      cc.size,
+		Hits:      cc.hits,
+		Misses:    cc.misses,
+		Corrupted: cc.corrupted,
+	}
+}
+
+// ChunkCacheStats returns the statistics of the renter's chunk cache.
+func (r *Renter) ChunkCacheStats() modules.ChunkCacheStats {
+	return r.staticChunkCache.Stats()
+}
+
+// threadedSaveChunkCache periodically saves the index of the chunk cache.
+func (r *Renter) threadedSaveChunkCache() {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	for {
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-time.After(chunkCacheSaveInterval):
+		}
+		if err := r.staticChunkCache.Save(); err != nil {
+			r.log.Println("WARN: unable to save the chunk cache index:", err)
+		}
+	}
+}
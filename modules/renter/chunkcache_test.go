@@ -0,0 +1,186 @@
+package renter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestChunkCache checks that the chunk cache evicts the least recently used
+// chunks, detects chunks that were modified on disk and keeps its statistics.
+func TestChunkCache(t *testing.T) {
+	dir := build.TempDir("renter", t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	// Files of a previous session are removed.
+	if err := ioutil.WriteFile(filepath.Join(dir, "stale"), []byte{1}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cc, err := newChunkCache(dir, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stale")); !os.IsNotExist(err) {
+		t.Fatal("stale chunk wasn't removed:", err)
+	}
+
+	chunks := [][]byte{fastrand.Bytes(100), fastrand.Bytes(100), fastrand.Bytes(100)}
+	for i, data := range chunks[:2] {
+		if err := cc.Add("a", uint64(i), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, ok := cc.Get("a", 0); !ok || !bytes.Equal(data, chunks[0]) {
+		t.Fatal("cached chunk wasn't returned")
+	}
+	if _, ok := cc.Get("b", 0); ok {
+		t.Fatal("chunk of another file was returned")
+	}
+
+	// Adding a third chunk evicts the least recently used one, which is chunk
+	// 1 since chunk 0 was just read.
+	if err := cc.Add("a", 2, chunks[2]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cc.Get("a", 1); ok {
+		t.Fatal("least recently used chunk wasn't evicted")
+	}
+	if _, err := os.Stat(cc.path(chunkCacheID("a", 1))); !os.IsNotExist(err) {
+		t.Fatal("evicted chunk wasn't removed from disk:", err)
+	}
+
+	// Chunks that are larger than the cache aren't added.
+	if err := cc.Add("a", 3, fastrand.Bytes(300)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cc.Get("a", 3); ok {
+		t.Fatal("oversized chunk was cached")
+	}
+
+	// A chunk that was modified on disk fails the integrity check and is
+	// evicted.
+	corrupted := append([]byte(nil), chunks[2]...)
+	corrupted[0]++
+	if err := ioutil.WriteFile(cc.path(chunkCacheID("a", 2)), corrupted, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cc.Get("a", 2); ok {
+		t.Fatal("corrupted chunk was returned")
+	}
+	expected := modules.ChunkCacheStats{Chunks: 1, Size: 100, Hits: 1, Misses: 4, Corrupted: 1}
+	if stats := cc.Stats(); stats != expected {
+		t.Fatalf("wrong stats: expected %v, got %v", expected, stats)
+	}
+
+	// Removing the file and disabling the cache removes all chunks.
+	if err := cc.Add("b", 0, chunks[1]); err != nil {
+		t.Fatal(err)
+	}
+	cc.RemoveFile("a")
+	if stats := cc.Stats(); stats.Chunks != 1 || stats.Size != 100 {
+		t.Fatal("chunks of removed file weren't evicted:", stats)
+	}
+	cc.SetCapacity(0)
+	if stats := cc.Stats(); stats.Chunks != 0 || stats.Size != 0 {
+		t.Fatal("chunks weren't evicted after disabling the cache:", stats)
+	}
+	if err := cc.Add("b", 0, chunks[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cc.Get("b", 0); ok {
+		t.Fatal("chunk was cached while the cache is disabled")
+	}
+	if stats := cc.Stats(); stats.Misses != 4 {
+		t.Fatal("misses were counted while the cache is disabled:", stats.Misses)
+	}
+}
+
+// TestChunkCachePersist checks that the chunk cache keeps its chunks and
+// their LRU order across restarts, and that it drops chunks that are missing
+// or have the wrong size on disk.
+func TestChunkCachePersist(t *testing.T) {
+	dir := build.TempDir("renter", t.Name())
+	cc, err := newChunkCache(dir, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := [][]byte{fastrand.Bytes(100), fastrand.Bytes(100), fastrand.Bytes(100), fastrand.Bytes(100)}
+	for i, data := range chunks {
+		if err := cc.Add("a", uint64(i), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Chunk 0 becomes the most recently used chunk.
+	if _, ok := cc.Get("a", 0); !ok {
+		t.Fatal("cached chunk wasn't returned")
+	}
+	if err := cc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Remove chunk 2, truncate chunk 3 and leave a chunk behind that isn't
+	// in the index.
+	if err := os.Remove(cc.path(chunkCacheID("a", 2))); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cc.path(chunkCacheID("a", 3)), chunks[3][:50], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cc.path(chunkCacheID("b", 0)), chunks[0], 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reload the cache with room for a single chunk, which should keep the
+	// most recently used chunk.
+	cc, err = newChunkCache(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := cc.Stats(); stats.Chunks != 1 || stats.Size != 100 {
+		t.Fatal("wrong chunks were loaded:", stats)
+	}
+	if data, ok := cc.Get("a", 0); !ok || !bytes.Equal(data, chunks[0]) {
+		t.Fatal("most recently used chunk wasn't kept")
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 2 {
+		t.Fatal("expected the index and a single chunk on disk, got", len(fis), "files")
+	}
+
+	// Chunks added in the background are saved when the cache is closed.
+	cc.SetCapacity(400)
+	cc.AddAsync("a", 1, chunks[1], nil)
+	if err := cc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cc, err = newChunkCache(dir, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := cc.Get("a", 1); !ok || !bytes.Equal(data, chunks[1]) {
+		t.Fatal("chunk added in the background wasn't kept")
+	}
+
+	// A corrupted index clears the cache.
+	if err := ioutil.WriteFile(filepath.Join(dir, chunkCacheIndexFilename), []byte("foo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cc, err = newChunkCache(dir, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := cc.Stats(); stats.Chunks != 0 {
+		t.Fatal("chunks were loaded from a corrupted index:", stats)
+	}
+}
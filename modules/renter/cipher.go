@@ -0,0 +1,436 @@
+package renter
+
+// cipher.go manages the ciphers of the renter's files and re-encrypts files
+// under new keys.
+//
+// The pieces of every file are encrypted with keys derived from the master key
+// of the file, using the cipher of the file. The .sia format predates the
+// cipher field and can only store files that are encrypted with Twofish, so
+// the ciphers of all other files are persisted in the ciphers file. Every
+// cipher is recorded along with the hash of the master key of its file, so a
+// record only applies to the .sia file with the matching key, no matter in
+// which order the two files are written.
+//
+// A file is re-encrypted by uploading a new copy of it under a new master key
+// and cipher. The data of the copy is downloaded from the original one chunk
+// at a time. The copy isn't visible to the user and isn't saved to disk until
+// all of its pieces have been uploaded, at which point it replaces the
+// original. Until then the original remains readable under its old key. The
+// cipher of the copy is persisted before the copy replaces the original on
+// disk. If the renter shuts down during a re-encryption, the partial copy is
+// discarded and the re-encryption has to be started again.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+const (
+	// CiphersFilename is the filename of the file that contains the ciphers
+	// of the renter's files that aren't encrypted with Twofish.
+	CiphersFilename = "ciphers.json"
+)
+
+var (
+	ciphersMetadata = persist.Metadata{
+		Header:  "Renter Ciphers",
+		Version: "1.0",
+	}
+
+	// errReencryptActive is returned when trying to re-encrypt a file that
+	// is being re-encrypted already.
+	errReencryptActive = errors.New("file is already being re-encrypted")
+
+	// errReencryptDedup is returned when trying to re-encrypt a deduplicated
+	// file, since the keys of its chunks are derived from their content.
+	errReencryptDedup = errors.New("deduplicated files can't be re-encrypted")
+
+	// errReencryptDeleted is returned if a file is deleted while it is being
+	// re-encrypted.
+	errReencryptDeleted = errors.New("file was deleted during the re-encryption")
+
+	// errReencryptInterrupted is returned if the renter shuts down during a
+	// re-encryption.
+	errReencryptInterrupted = errors.New("re-encryption interrupted by stop call")
+
+	// errReencryptPacked is returned when trying to re-encrypt a packed file,
+	// since only files that are encrypted with Twofish are packed.
+	errReencryptPacked = errors.New("packed files can't be re-encrypted")
+
+	// errReencryptStreaming is returned when trying to re-encrypt a file
+	// whose stream upload hasn't finished.
+	errReencryptStreaming = errors.New("file is still being uploaded from a stream")
+
+	// errShareCipher is returned when trying to share a file that isn't
+	// encrypted with Twofish, since the .sia format can't store its cipher.
+	errShareCipher = errors.New("only files encrypted with Twofish can be shared")
+)
+
+type (
+	// fileCipher is the persisted cipher of a single file. KeyHash is the
+	// hash of the master key of the file. Records that were written before
+	// the hash was added don't have one and are matched by siapath.
+	fileCipher struct {
+		SiaPath    string            `json:"siapath"`
+		CipherType crypto.CipherType `json:"ciphertype"`
+		KeyHash    crypto.Hash       `json:"keyhash"`
+	}
+
+	// ciphersPersist is the object persisted in the ciphers file. It only
+	// contains the files that aren't encrypted with Twofish.
+	ciphersPersist struct {
+		Files []fileCipher `json:"files"`
+	}
+
+	// reencryption is the re-encryption of a file. copy is the new copy of
+	// the file, err is set if the re-encryption failed.
+	reencryption struct {
+		copy *file
+		err  error
+	}
+)
+
+// cipherPieceSize returns the piece size of files that are encrypted with the
+// cipher ct and aren't packed.
+func cipherPieceSize(ct crypto.CipherType) uint64 {
+	return modules.SectorSize - ct.Overhead()
+}
+
+// keyHash returns the hash of the master key of the file, which binds the
+// persisted cipher of the file to its key.
+func (f *file) keyHash() crypto.Hash {
+	return crypto.HashObject(f.masterKey)
+}
+
+// ciphersData returns the ciphers of the files that aren't encrypted with
+// Twofish, including the files in pending that haven't been added to the
+// renter yet. The caller must hold the renter lock.
+func (r *Renter) ciphersData(pending ...*file) ciphersPersist {
+	cp := ciphersPersist{
+		Files: make([]fileCipher, 0),
+	}
+	files := pending
+	for _, f := range r.files {
+		files = append(files, f)
+	}
+	for _, f := range files {
+		f.mu.RLock()
+		if f.cipherType != crypto.TypeTwofish {
+			cp.Files = append(cp.Files, fileCipher{
+				SiaPath:    f.name,
+				CipherType: f.cipherType,
+				KeyHash:    f.keyHash(),
+			})
+		}
+		f.mu.RUnlock()
+	}
+	sort.Slice(cp.Files, func(i, j int) bool {
+		if cp.Files[i].SiaPath != cp.Files[j].SiaPath {
+			return cp.Files[i].SiaPath < cp.Files[j].SiaPath
+		}
+		return bytes.Compare(cp.Files[i].KeyHash[:], cp.Files[j].KeyHash[:]) < 0
+	})
+	return cp
+}
+
+// saveCiphers writes the ciphers of the files that aren't encrypted with
+// Twofish to disk, including the ciphers of the files in pending. The caller
+// must hold the renter lock.
+func (r *Renter) saveCiphers(pending ...*file) error {
+	return persist.SaveJSON(ciphersMetadata, r.ciphersData(pending...), filepath.Join(r.persistDir, CiphersFilename))
+}
+
+// restoreCipher sets the cipher of f to the cipher of the record fc. Files
+// with an unknown cipher are left alone, they can't be decrypted either way.
+func (r *Renter) restoreCipher(f *file, fc fileCipher) {
+	if err := fc.CipherType.Valid(); err != nil {
+		r.log.Printf("WARN: file %v has unknown cipher %v", fc.SiaPath, fc.CipherType)
+		return
+	}
+	f.mu.Lock()
+	f.cipherType = fc.CipherType
+	f.mu.Unlock()
+}
+
+// restoreCiphers sets the ciphers of the files in files. Records are matched
+// to the files by the hash of their master key, so records of keys that no
+// file uses anymore are ignored.
+func (r *Renter) restoreCiphers(cp ciphersPersist, files map[string]*file) {
+	byKey := make(map[crypto.Hash]fileCipher, len(cp.Files))
+	for _, fc := range cp.Files {
+		if fc.KeyHash == (crypto.Hash{}) {
+			if f, exists := files[fc.SiaPath]; exists {
+				r.restoreCipher(f, fc)
+			}
+			continue
+		}
+		byKey[fc.KeyHash] = fc
+	}
+	for _, f := range files {
+		if fc, exists := byKey[f.keyHash()]; exists {
+			r.restoreCipher(f, fc)
+		}
+	}
+}
+
+// loadCiphers restores the ciphers of the renter's files from disk. It has to
+// be called before the files are used.
+func (r *Renter) loadCiphers() error {
+	var cp ciphersPersist
+	err := persist.LoadJSON(ciphersMetadata, &cp, filepath.Join(r.persistDir, CiphersFilename))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	r.restoreCiphers(cp, r.files)
+	return nil
+}
+
+// ReencryptFile starts re-encrypting the file at siaPath under a new master
+// key and the cipher ct. If ct is empty, the cipher of the file is kept and
+// only the key is rotated. The file is re-uploaded in the background and stays
+// readable under its old key until the new copy has been uploaded.
+func (r *Renter) ReencryptFile(siaPath string, ct crypto.CipherType) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	f, exists := r.files[siaPath]
+	if !exists {
+		return ErrUnknownPath
+	}
+	if ct == "" {
+		ct = f.cipherType
+	}
+	if err := ct.Valid(); err != nil {
+		return err
+	}
+	if job, exists := r.reencryptions[f]; exists && job.err == nil {
+		return errReencryptActive
+	}
+	if r.persist.Tracking[siaPath].Streaming {
+		return errReencryptStreaming
+	}
+	f.mu.RLock()
+	dedup, packed, shared := f.dedup, f.packed(), f.shared()
+	f.mu.RUnlock()
+	if dedup {
+		return errReencryptDedup
+	} else if packed {
+		return errReencryptPacked
+	} else if shared {
+		return errReencryptShared
+	}
+
+	// The copy gets a new master key from newFile.
+	nf := newFile(siaPath, f.erasureCode, cipherPieceSize(ct), f.size)
+	nf.cipherType = ct
+	nf.copyOf = f
+	job := &reencryption{copy: nf}
+	r.reencryptions[f] = job
+	go r.threadedReencrypt(f, job)
+	return nil
+}
+
+// threadedReencrypt uploads the copy of a re-encryption and replaces the
+// original file with it.
+func (r *Renter) threadedReencrypt(f *file, job *reencryption) {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	err := r.managedUploadCopy(f, job.copy)
+	if err == nil {
+		err = r.managedReplaceFile(f, job.copy)
+	}
+	id := r.mu.Lock()
+	if err == nil || err == errReencryptDeleted {
+		delete(r.reencryptions, f)
+	} else {
+		job.err = err
+	}
+	r.mu.Unlock(id)
+	if err != nil {
+		r.log.Println("WARN: re-encryption failed:", err)
+	}
+}
+
+// managedDownloadCopyChunk downloads the logical data of a chunk of a copy
+// from the original file f.
+func (r *Renter) managedDownloadCopyChunk(f *file, uuc *unfinishedUploadChunk) error {
+	buf := NewDownloadDestinationBuffer(uuc.length, uuc.renterFile.pieceSize)
+	offset := uint64(uuc.offset)
+	length := uuc.length
+	if offset+length > f.size {
+		length = f.size - offset
+	}
+	if length > 0 {
+		d, err := r.managedNewDownload(downloadParams{
+			destination:     buf,
+			destinationType: "buffer",
+			file:            f,
+
+			latencyTarget: 200e3, // No need to rush latency on re-encryption downloads.
+			length:        length,
+			needsMemory:   false, // The memory of the chunk was requested already.
+			offset:        offset,
+			overdrive:     0,
+			priority:      0, // Re-encryption downloads are de-prioritized like repairs.
+		})
+		if err != nil {
+			return err
+		}
+		select {
+		case <-d.completeChan:
+		case <-r.tg.StopChan():
+			return errReencryptInterrupted
+		}
+		if d.Err() != nil {
+			return d.Err()
+		}
+	}
+	uuc.logicalChunkData = [][]byte(buf)
+	return nil
+}
+
+// managedUploadCopy uploads the copy nf of the file f. It returns once every
+// piece of the copy has been uploaded.
+func (r *Renter) managedUploadCopy(f, nf *file) error {
+	hosts := r.managedRefreshHostsAndWorkers()
+	id := r.mu.RLock()
+	numWorkers := len(r.workerPool)
+	r.mu.RUnlock(id)
+	if numWorkers < nf.erasureCode.NumPieces() {
+		return fmt.Errorf("not enough workers to re-encrypt the file: got %v, needed %v", numWorkers, nf.erasureCode.NumPieces())
+	}
+
+	// Upload the chunks of the copy. The memory manager limits the number of
+	// chunks that are uploaded at the same time.
+	chunks := make([]*unfinishedUploadChunk, 0, nf.numChunks())
+	for index := uint64(0); index < nf.numChunks(); index++ {
+		f.mu.RLock()
+		deleted := f.deleted
+		f.mu.RUnlock()
+		if deleted {
+			return errReencryptDeleted
+		}
+
+		uuc := newUnfinishedUploadChunk(nf, index, "", hosts)
+		uuc.completeChan = make(chan struct{})
+		uuc.priority = modules.PriorityLow
+		if !r.memoryManager.Request(uuc.memoryNeeded, newMemoryPriority(uuc.priority, memoryPriorityLow)) {
+			return errReencryptInterrupted
+		}
+		if err := r.managedDownloadCopyChunk(f, uuc); err != nil {
+			r.memoryManager.Return(uuc.memoryNeeded)
+			return fmt.Errorf("unable to download chunk %v: %v", index, err)
+		}
+		r.uploadHeap.mu.Lock()
+		r.uploadHeap.activeChunks[uuc.id] = struct{}{}
+		r.uploadHeap.mu.Unlock()
+		go r.managedFetchAndRepairChunk(uuc)
+		chunks = append(chunks, uuc)
+	}
+
+	// Wait until no further pieces are going to be uploaded.
+	for _, uuc := range chunks {
+		select {
+		case <-uuc.completeChan:
+		case <-r.tg.StopChan():
+			return errReencryptInterrupted
+		}
+		uuc.mu.Lock()
+		complete := uuc.piecesCompleted >= uuc.piecesNeeded
+		uuc.mu.Unlock()
+		if !complete {
+			return fmt.Errorf("unable to upload all pieces of chunk %v", uuc.index)
+		}
+	}
+	return nil
+}
+
+// managedReplaceFile replaces the file f with its copy nf, which takes over
+// the siapath, mode and tracking of f.
+func (r *Renter) managedReplaceFile(f, nf *file) error {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	f.mu.RLock()
+	siaPath, mode, deleted := f.name, f.mode, f.deleted
+	f.mu.RUnlock()
+	if deleted || r.files[siaPath] != f {
+		return errReencryptDeleted
+	}
+
+	nf.mu.Lock()
+	copyPath := nf.name
+	nf.name = siaPath
+	nf.mode = mode
+	nf.copyOf = nil
+	nf.mu.Unlock()
+
+	// Persist the cipher of the copy before the copy replaces the file on
+	// disk. Until the copy is saved, the record of the copy's key is ignored.
+	if err := r.saveCiphers(nf); err != nil {
+		return err
+	}
+	nf.mu.Lock()
+	err := r.saveFile(nf)
+	nf.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	r.files[siaPath] = nf
+	f.mu.Lock()
+	f.deleted = true
+	f.mu.Unlock()
+	r.staticChunkCache.RemoveFile(chunkCacheFileID(f))
+
+	// The spending of the copy was recorded under the siapath of the file at
+	// the time the re-encryption started.
+	if copyPath != siaPath {
+		r.managedRenameSpending(copyPath, siaPath)
+	}
+	return r.saveCiphers()
+}
+
+// Reencryptions returns information about the re-encryptions that are in
+// progress or have failed, sorted by siapath.
+func (r *Renter) Reencryptions() []modules.ReencryptionInfo {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	infos := make([]modules.ReencryptionInfo, 0, len(r.reencryptions))
+	for f, job := range r.reencryptions {
+		f.mu.RLock()
+		siaPath, deleted := f.name, f.deleted
+		f.mu.RUnlock()
+		if deleted {
+			continue
+		}
+		job.copy.mu.RLock()
+		info := modules.ReencryptionInfo{
+			SiaPath:    siaPath,
+			CipherType: job.copy.cipherType,
+			Progress:   job.copy.uploadProgress(),
+		}
+		job.copy.mu.RUnlock()
+		if job.err != nil {
+			info.Error = job.err.Error()
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].SiaPath < infos[j].SiaPath })
+	return infos
+}
@@ -0,0 +1,150 @@
+package renter
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestPieceKeyCiphers checks that the pieces of a file are encrypted with the
+// cipher of the file, and that files with the same master key but different
+// ciphers can't decrypt each other's pieces.
+func TestPieceKeyCiphers(t *testing.T) {
+	rsc, _ := NewRSCode(1, 1)
+	twofish := newFile("a", rsc, cipherPieceSize(crypto.TypeTwofish), 100)
+	xchacha := newFile("b", rsc, cipherPieceSize(crypto.TypeXChaCha20), 100)
+	xchacha.masterKey = twofish.masterKey
+	xchacha.cipherType = crypto.TypeXChaCha20
+
+	for _, f := range []*file{twofish, xchacha} {
+		key := f.pieceKey(1, 0)
+		if key.Type() != f.cipherType {
+			t.Fatalf("expected a %v key, got %v", f.cipherType, key.Type())
+		}
+		piece := fastrand.Bytes(int(f.pieceSize))
+		ciphertext := key.EncryptBytes(piece)
+		if uint64(len(ciphertext)) != modules.SectorSize {
+			t.Fatalf("%v: encrypted piece doesn't fill a sector: %v", f.cipherType, len(ciphertext))
+		}
+		if plaintext, err := key.DecryptBytes(ciphertext); err != nil || !bytes.Equal(plaintext, piece) {
+			t.Fatalf("%v: piece wasn't decrypted: %v", f.cipherType, err)
+		}
+	}
+	ciphertext := xchacha.pieceKey(0, 0).EncryptBytes(fastrand.Bytes(64))
+	if _, err := twofish.pieceKey(0, 0).DecryptBytes(ciphertext); err == nil {
+		t.Fatal("Twofish key decrypted an XChaCha20 piece")
+	}
+}
+
+// TestRenterCiphers checks that the ciphers of the renter's files are
+// persisted and that files that can't be re-encrypted are rejected.
+func TestRenterCiphers(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Add a file that is encrypted with XChaCha20.
+	rsc, _ := NewRSCode(1, 1)
+	up := modules.FileUploadParams{
+		SiaPath:     "dir/xchacha",
+		ErasureCode: rsc,
+		CipherType:  crypto.TypeXChaCha20,
+	}
+	f, err := rt.renter.managedAddUploadFile(up, 100, 0600, trackedFile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.cipherType != crypto.TypeXChaCha20 || f.pieceSize != modules.SectorSize-crypto.XChaCha20Overhead || f.packed() {
+		t.Fatal("file wasn't created for XChaCha20:", f.cipherType, f.pieceSize)
+	}
+	up.SiaPath, up.CipherType = "rot13", "rot13"
+	if _, err := rt.renter.managedAddUploadFile(up, 100, 0600, trackedFile{}); err != crypto.ErrUnknownCipherType {
+		t.Fatal("expected ErrUnknownCipherType, got", err)
+	}
+	if _, err := rt.renter.ShareFilesASCII([]string{"dir/xchacha"}); err != errShareCipher {
+		t.Fatal("expected errShareCipher, got", err)
+	}
+
+	// The cipher should follow renames and be restored after a restart.
+	if err := rt.renter.RenameDir("dir", "dir2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, filepath.Join(rt.dir, modules.RenterDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := rt.renter.File("dir2/xchacha")
+	if err != nil || fi.CipherType != crypto.TypeXChaCha20 {
+		t.Fatal("cipher wasn't restored:", fi.CipherType, err)
+	}
+
+	// Files that can't be re-encrypted should be rejected.
+	if _, err := rt.addTestingFile("small"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.ReencryptFile("small", crypto.TypeXChaCha20); err != errReencryptPacked {
+		t.Fatal("expected errReencryptPacked, got", err)
+	}
+	if err := rt.renter.ReencryptFile("dir2/xchacha", "rot13"); err != crypto.ErrUnknownCipherType {
+		t.Fatal("expected ErrUnknownCipherType, got", err)
+	}
+	if err := rt.renter.ReencryptFile("missing", crypto.TypeXChaCha20); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+
+	// Deleting the file should remove its cipher from the ciphers file.
+	if err := rt.renter.DeleteFile("dir2/xchacha"); err != nil {
+		t.Fatal(err)
+	}
+	id := rt.renter.mu.RLock()
+	cp := rt.renter.ciphersData()
+	rt.renter.mu.RUnlock(id)
+	if len(cp.Files) != 0 {
+		t.Fatal("cipher of deleted file wasn't removed:", cp.Files)
+	}
+}
+
+// TestRestoreCiphers checks that persisted ciphers are only restored for the
+// file whose master key they were recorded with, so that a record written
+// ahead of a re-encrypted copy doesn't apply to the original file.
+func TestRestoreCiphers(t *testing.T) {
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, cipherPieceSize(crypto.TypeTwofish), 100)
+	nf := newFile("foo", rsc, cipherPieceSize(crypto.TypeXChaCha20), 100)
+	legacy := newFile("bar", rsc, cipherPieceSize(crypto.TypeXChaCha20), 100)
+	cp := ciphersPersist{
+		Files: []fileCipher{
+			{SiaPath: "foo", CipherType: crypto.TypeXChaCha20, KeyHash: nf.keyHash()},
+			{SiaPath: "bar", CipherType: crypto.TypeXChaCha20},
+		},
+	}
+
+	// The record of the copy doesn't apply to the original.
+	r := new(Renter)
+	r.restoreCiphers(cp, map[string]*file{"foo": f, "bar": legacy})
+	if f.cipherType != crypto.TypeTwofish {
+		t.Fatal("cipher of the copy was restored for the original:", f.cipherType)
+	}
+	if legacy.cipherType != crypto.TypeXChaCha20 {
+		t.Fatal("cipher without a key hash wasn't restored by siapath:", legacy.cipherType)
+	}
+
+	// Once the copy has replaced the original, its cipher is restored.
+	r.restoreCiphers(cp, map[string]*file{"foo": nf})
+	if nf.cipherType != crypto.TypeXChaCha20 {
+		t.Fatal("cipher of the copy wasn't restored:", nf.cipherType)
+	}
+}
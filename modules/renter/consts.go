@@ -65,10 +65,12 @@ const (
 	// worker has experienced a download failure.
 	downloadFailureCooldown = time.Second * 3
 
-	// memoryPriorityLow is used to request low priority memory
+	// memoryPriorityLow is used to request memory for background work, such
+	// as repairs, within a priority class.
 	memoryPriorityLow = false
 
-	// memoryPriorityHigh is used to request high priority memory
+	// memoryPriorityHigh is used to request memory for work that a user is
+	// waiting on, such as downloads, within a priority class.
 	memoryPriorityHigh = true
 
 	// destinationTypeSeekStream is the destination type used for downloads
@@ -79,6 +81,15 @@ const (
 	// chunks, the user can set a custom cache size through the API
 	DefaultStreamCacheSize = 2
 
+	// DefaultStreamPrefetch is the default number of chunks that a stream
+	// fetches ahead of the chunk being read.
+	DefaultStreamPrefetch = 2
+
+	// maxStreamPrefetch is the maximum number of chunks that a stream can
+	// fetch ahead of the chunk being read. Every fetched chunk is kept in
+	// memory until the stream moves past it.
+	maxStreamPrefetch = 8
+
 	// DefaultMaxDownloadSpeed is set to zero to indicate no limit, the user
 	// can set a custom MaxDownloadSpeed through the API
 	DefaultMaxDownloadSpeed = 0
@@ -115,6 +126,15 @@ var (
 		Testing:  5,
 	}).(int)
 
+	// maxUploadHeapChunks is the number of chunks that the repair loop adds to
+	// the upload heap at most when it rebuilds the heap. The least healthy
+	// files are added first.
+	maxUploadHeapChunks = build.Select(build.Var{
+		Dev:      1000,
+		Standard: 5000,
+		Testing:  1000,
+	}).(int)
+
 	// offlineCheckFrequency is how long the renter will wait to check the
 	// online status if it is offline.
 	offlineCheckFrequency = build.Select(build.Var{
@@ -139,6 +159,22 @@ var (
 		Testing:  0.25,
 	}).(float64)
 
+	// spendingSaveInterval is how often the renter saves its spending ledger
+	// if it changed.
+	spendingSaveInterval = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 10 * time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
+
+	// chunkCacheSaveInterval is how often the renter saves the index of its
+	// chunk cache if it changed.
+	chunkCacheSaveInterval = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 10 * time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
+
 	// Prime to avoid intersecting with regular events.
 	uploadFailureCooldown = build.Select(build.Var{
 		Dev:      time.Second * 7,
@@ -5,6 +5,7 @@ import (
 	"reflect"
 
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 var (
@@ -12,6 +13,7 @@ var (
 	errAllowanceNotSynced  = errors.New("you must be synced to set an allowance")
 	errAllowanceWindowSize = errors.New("renew window must be less than period")
 	errAllowanceZeroPeriod = errors.New("period must be non-zero")
+	errSpendingCapReached  = errors.New("spending cap of the allowance has been reached")
 
 	// ErrAllowanceZeroWindow is returned when the caller requests a
 	// zero-length renewal window. This will happen if the caller sets the
@@ -141,3 +143,119 @@ func (c *Contractor) managedCancelAllowance() error {
 	}
 	return nil
 }
+
+// priceLimits are the highest prices that the caps of an allowance accept
+// from a host. A nil limit means that the price isn't capped.
+type priceLimits struct {
+	storage  *types.Currency // per byte per block
+	upload   *types.Currency // per byte
+	download *types.Currency // per byte
+}
+
+// allowancePriceLimits returns the price limits of an allowance. The storage
+// price is limited by the max price per TB, and the price of each category is
+// limited to the price at which its expected usage would exceed its spending
+// cap.
+func allowancePriceLimits(a modules.Allowance) (pl priceLimits) {
+	lower := func(limit **types.Currency, price types.Currency) {
+		if *limit == nil || price.Cmp(**limit) < 0 {
+			*limit = &price
+		}
+	}
+	if !a.MaxPricePerTB.IsZero() {
+		lower(&pl.storage, a.MaxPricePerTB.Div(modules.BlockBytesPerMonthTerabyte))
+	}
+	if !a.MaxStorageSpending.IsZero() && a.ExpectedStorage != 0 && a.Period != 0 {
+		blockBytes := types.NewCurrency64(a.ExpectedStorage).Mul64(expectedRedundancy).Mul64(uint64(a.Period))
+		lower(&pl.storage, a.MaxStorageSpending.Div(blockBytes))
+	}
+	if !a.MaxUploadSpending.IsZero() && a.ExpectedUpload != 0 {
+		bytes := types.NewCurrency64(a.ExpectedUpload).Mul64(expectedRedundancy)
+		lower(&pl.upload, a.MaxUploadSpending.Div(bytes))
+	}
+	if !a.MaxDownloadSpending.IsZero() && a.ExpectedDownload != 0 {
+		lower(&pl.download, a.MaxDownloadSpending.Div64(a.ExpectedDownload))
+	}
+	return pl
+}
+
+// exceedsLimit returns true if the price is higher than the limit.
+func exceedsLimit(price types.Currency, limit *types.Currency) bool {
+	return limit != nil && price.Cmp(*limit) > 0
+}
+
+// checkUpload returns errTooExpensive if the storage or upload price of the
+// host exceed the limits.
+func (pl priceLimits) checkUpload(host modules.HostDBEntry) error {
+	if exceedsLimit(host.StoragePrice, pl.storage) || exceedsLimit(host.UploadBandwidthPrice, pl.upload) {
+		return errTooExpensive
+	}
+	return nil
+}
+
+// checkDownload returns errTooExpensive if the download price of the host
+// exceeds the limit.
+func (pl priceLimits) checkDownload(host modules.HostDBEntry) error {
+	if exceedsLimit(host.DownloadBandwidthPrice, pl.download) {
+		return errTooExpensive
+	}
+	return nil
+}
+
+// check returns errTooExpensive if any of the prices of the host exceed the
+// limits.
+func (pl priceLimits) check(host modules.HostDBEntry) error {
+	if err := pl.checkUpload(host); err != nil {
+		return err
+	}
+	return pl.checkDownload(host)
+}
+
+// expectsUsage returns true if the allowance specifies an expected usage.
+func expectsUsage(a modules.Allowance) bool {
+	return a.ExpectedStorage != 0 || a.ExpectedUpload != 0 || a.ExpectedDownload != 0
+}
+
+// contractFunding returns the funds that a contract with the host should be
+// formed with. If the allowance specifies an expected usage, the funding
+// covers the host's share of that usage over the duration of the contract
+// with a 33% buffer, plus the fees, but never more than the host's share of
+// the funds. Otherwise a third of the host's share of the funds is used.
+func contractFunding(a modules.Allowance, host modules.HostDBEntry, duration types.BlockHeight, txnFee types.Currency) types.Currency {
+	fairShare := a.Funds.Div64(a.Hosts)
+	if !expectsUsage(a) {
+		return fairShare.Div64(3)
+	}
+	storage := types.NewCurrency64(a.ExpectedStorage).Mul64(expectedRedundancy).Div64(a.Hosts)
+	upload := types.NewCurrency64(a.ExpectedUpload).Mul64(expectedRedundancy).Div64(a.Hosts)
+	download := types.NewCurrency64(a.ExpectedDownload).Div64(a.Hosts)
+	usageCost := host.StoragePrice.Mul(storage).Mul64(uint64(duration))
+	usageCost = usageCost.Add(host.UploadBandwidthPrice.Mul(upload))
+	usageCost = usageCost.Add(host.DownloadBandwidthPrice.Mul(download))
+	funding := usageCost.Add(usageCost.Div64(3)).Add(host.ContractPrice).Add(txnFee)
+	if funding.Cmp(fairShare) > 0 {
+		return fairShare
+	}
+	return funding
+}
+
+// managedCheckSpendingCap returns errSpendingCapReached if the money spent
+// during the current period on any of the categories reached its cap.
+func (c *Contractor) managedCheckSpendingCap(storage, upload, download bool) error {
+	c.mu.RLock()
+	a := c.allowance
+	c.mu.RUnlock()
+	if a.MaxStorageSpending.IsZero() && a.MaxUploadSpending.IsZero() && a.MaxDownloadSpending.IsZero() {
+		return nil
+	}
+	spending := c.PeriodSpending()
+	reached := func(check bool, limit, spent types.Currency) bool {
+		return check && !limit.IsZero() && spent.Cmp(limit) >= 0
+	}
+	if reached(storage, a.MaxStorageSpending, spending.StorageSpending) ||
+		reached(upload, a.MaxUploadSpending, spending.UploadSpending) ||
+		reached(download, a.MaxDownloadSpending, spending.DownloadSpending) {
+		return errSpendingCapReached
+	}
+	return nil
+}
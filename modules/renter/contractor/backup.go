@@ -0,0 +1,92 @@
+package contractor
+
+import (
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/proto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// A ContractsBackup contains the contracts of a contractor, along with the
+// information needed to resolve the IDs of renewed contracts. It doesn't
+// contain the allowance, which is part of the renter's settings.
+type ContractsBackup struct {
+	Contracts    []proto.ContractBackup   `json:"contracts"`
+	OldContracts []modules.RenterContract `json:"oldcontracts"`
+	RenewedIDs   map[string]string        `json:"renewedids"`
+}
+
+// BackupContracts returns a backup of the contractor's contracts.
+func (c *Contractor) BackupContracts() ContractsBackup {
+	c.mu.RLock()
+	data := c.persistData()
+	c.mu.RUnlock()
+
+	backup := ContractsBackup{
+		OldContracts: data.OldContracts,
+		RenewedIDs:   data.RenewedIDs,
+	}
+	for _, id := range c.staticContracts.IDs() {
+		b, err := c.staticContracts.Backup(id)
+		if err != nil {
+			// The contract may have been archived in the meantime.
+			c.log.Println("WARN: unable to back up contract", id, err)
+			continue
+		}
+		backup.Contracts = append(backup.Contracts, b)
+	}
+	return backup
+}
+
+// RestoreContracts adds the contracts of a backup to the contractor. Contracts
+// that the contractor already knows about are left untouched, and contracts
+// that have expired are archived right away. It returns the number of
+// restored active contracts.
+func (c *Contractor) RestoreContracts(backup ContractsBackup) (int, error) {
+	if err := c.tg.Add(); err != nil {
+		return 0, err
+	}
+	defer c.tg.Done()
+
+	c.mu.RLock()
+	blockHeight := c.blockHeight
+	c.mu.RUnlock()
+
+	var restored int
+	var expired []modules.RenterContract
+	for _, b := range backup.Contracts {
+		contract, err := b.Metadata()
+		if err != nil {
+			return restored, err
+		}
+		if blockHeight > contract.EndHeight {
+			expired = append(expired, contract)
+			continue
+		}
+		if _, err := c.staticContracts.Restore(b); err != nil {
+			c.log.Println("WARN: unable to restore contract", contract.ID, err)
+			continue
+		}
+		c.log.Println("INFO: restored contract", contract.ID)
+		restored++
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, contract := range append(backup.OldContracts, expired...) {
+		if _, exists := c.oldContracts[contract.ID]; !exists {
+			c.oldContracts[contract.ID] = contract
+		}
+	}
+	for oldString, newString := range backup.RenewedIDs {
+		var oldHash, newHash crypto.Hash
+		if oldHash.LoadString(oldString) != nil || newHash.LoadString(newString) != nil {
+			continue
+		}
+		oldID := types.FileContractID(oldHash)
+		if _, exists := c.renewedIDs[oldID]; !exists {
+			c.renewedIDs[oldID] = types.FileContractID(newHash)
+		}
+	}
+	return restored, c.saveSync()
+}
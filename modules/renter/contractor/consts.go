@@ -38,6 +38,15 @@ var (
 	maxStoragePrice  = types.SiacoinPrecision.Mul64(30e3).Div(modules.BlockBytesPerMonthTerabyte) // 30k SC / TB / Month
 	maxUploadPrice   = maxStoragePrice.Mul64(3 * 4320)                                            // 3 months of storage
 
+	// expectedRedundancy is the redundancy that is assumed when the expected
+	// usage of the allowance is divided among the hosts. It matches the
+	// default erasure coding of the renter, 10 data and 20 parity pieces.
+	expectedRedundancy = uint64(3)
+
+	// estimatedFormationTxnSize is the size of the transaction set that is
+	// assumed when estimating the transaction fee of a new contract.
+	estimatedFormationTxnSize = uint64(2048)
+
 	// scoreLeeway defines the factor by which a host can miss the goal score
 	// for a set of hosts. To determine the goal score, a new set of hosts is
 	// queried from the hostdb and the lowest scoring among them is selected.
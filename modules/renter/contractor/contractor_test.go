@@ -26,6 +26,7 @@ func (newStub) Unsubscribe(modules.ConsensusSetSubscriber) { return }
 
 // wallet stubs
 func (newStub) NextAddress() (uc types.UnlockConditions, err error)          { return }
+func (newStub) PrimarySeed() (s modules.Seed, p uint64, err error)           { return }
 func (newStub) StartTransaction() (tb modules.TransactionBuilder, err error) { return }
 
 // transaction pool stubs
@@ -39,6 +40,9 @@ func (newStub) Host(types.SiaPublicKey) (settings modules.HostDBEntry, ok bool)
 func (newStub) IncrementSuccessfulInteractions(key types.SiaPublicKey)               { return }
 func (newStub) IncrementFailedInteractions(key types.SiaPublicKey)                   { return }
 func (newStub) RandomHosts(int, []types.SiaPublicKey) ([]modules.HostDBEntry, error) { return nil, nil }
+func (newStub) RecordDownload(types.SiaPublicKey, uint64, time.Duration)             { return }
+func (newStub) RecordLatency(types.SiaPublicKey, time.Duration)                      { return }
+func (newStub) RecordUpload(types.SiaPublicKey, uint64, time.Duration)               { return }
 func (newStub) ScoreBreakdown(modules.HostDBEntry) modules.HostScoreBreakdown {
 	return modules.HostScoreBreakdown{}
 }
@@ -129,6 +133,96 @@ func TestAllowance(t *testing.T) {
 	}
 }
 
+// TestAllowancePriceLimits checks that the price limits of an allowance are
+// derived from its spending caps and expected usage.
+func TestAllowancePriceLimits(t *testing.T) {
+	// Without caps, the prices aren't limited.
+	pl := allowancePriceLimits(modules.Allowance{})
+	if pl.storage != nil || pl.upload != nil || pl.download != nil {
+		t.Fatal("prices are limited without caps:", pl)
+	}
+
+	// Caps without an expected usage only limit the storage price per TB.
+	a := modules.Allowance{
+		Period:              100,
+		MaxStorageSpending:  types.NewCurrency64(3000),
+		MaxUploadSpending:   types.NewCurrency64(600),
+		MaxDownloadSpending: types.NewCurrency64(50),
+		MaxPricePerTB:       modules.BlockBytesPerMonthTerabyte.Mul64(20),
+	}
+	pl = allowancePriceLimits(a)
+	if pl.storage == nil || !pl.storage.Equals64(20) || pl.upload != nil || pl.download != nil {
+		t.Fatal("wrong limits without expected usage:", pl)
+	}
+
+	// With an expected usage, the caps are divided by the usage, including
+	// the redundancy for storage and uploads.
+	a.ExpectedStorage = 1
+	a.ExpectedUpload = 10
+	a.ExpectedDownload = 10
+	pl = allowancePriceLimits(a)
+	if !pl.storage.Equals64(10) || !pl.upload.Equals64(20) || !pl.download.Equals64(5) {
+		t.Fatal("wrong limits with expected usage:", pl)
+	}
+
+	host := modules.HostDBEntry{}
+	host.StoragePrice = types.NewCurrency64(10)
+	host.UploadBandwidthPrice = types.NewCurrency64(20)
+	host.DownloadBandwidthPrice = types.NewCurrency64(5)
+	if err := pl.check(host); err != nil {
+		t.Fatal(err)
+	}
+	host.DownloadBandwidthPrice = types.NewCurrency64(6)
+	if err := pl.checkUpload(host); err != nil {
+		t.Fatal(err)
+	} else if err := pl.check(host); err != errTooExpensive {
+		t.Fatal("expected errTooExpensive, got", err)
+	}
+	host.StoragePrice = types.NewCurrency64(11)
+	if err := pl.checkUpload(host); err != errTooExpensive {
+		t.Fatal("expected errTooExpensive, got", err)
+	}
+}
+
+// TestContractFunding checks that contracts are sized from the expected usage
+// of the allowance.
+func TestContractFunding(t *testing.T) {
+	a := modules.Allowance{
+		Funds: types.NewCurrency64(30e6),
+		Hosts: 10,
+	}
+	host := modules.HostDBEntry{}
+	host.ContractPrice = types.NewCurrency64(100)
+	host.StoragePrice = types.NewCurrency64(2)
+	host.UploadBandwidthPrice = types.NewCurrency64(3)
+	host.DownloadBandwidthPrice = types.NewCurrency64(4)
+
+	// Without an expected usage, a third of the host's share is used.
+	if f := contractFunding(a, host, 100, types.NewCurrency64(10)); !f.Equals64(1e6) {
+		t.Fatal("wrong funding without expected usage:", f)
+	}
+
+	// The funding covers the host's share of the usage with a 33% buffer,
+	// plus the fees.
+	a.ExpectedStorage = 1000
+	a.ExpectedUpload = 2000
+	a.ExpectedDownload = 3000
+	// storage: 1000*3/10 * 2 * 100 = 60000
+	// upload: 2000*3/10 * 3 = 1800
+	// download: 3000/10 * 4 = 1200
+	usage := uint64(60000 + 1800 + 1200)
+	expected := usage + usage/3 + 100 + 10
+	if f := contractFunding(a, host, 100, types.NewCurrency64(10)); !f.Equals64(expected) {
+		t.Fatal("wrong funding with expected usage:", f, expected)
+	}
+
+	// The funding never exceeds the host's share of the funds.
+	a.ExpectedStorage = 1e9
+	if f := contractFunding(a, host, 100, types.NewCurrency64(10)); !f.Equals64(3e6) {
+		t.Fatal("funding exceeds the host's share:", f)
+	}
+}
+
 // stubHostDB mocks the hostDB dependency using zero-valued implementations of
 // its methods.
 type stubHostDB struct{}
@@ -140,6 +234,9 @@ func (stubHostDB) IncrementSuccessfulInteractions(key types.SiaPublicKey)
 func (stubHostDB) IncrementFailedInteractions(key types.SiaPublicKey)                        { return }
 func (stubHostDB) PublicKey() (spk types.SiaPublicKey)                                       { return }
 func (stubHostDB) RandomHosts(int, []types.SiaPublicKey) (hs []modules.HostDBEntry, _ error) { return }
+func (stubHostDB) RecordDownload(types.SiaPublicKey, uint64, time.Duration)                  { return }
+func (stubHostDB) RecordLatency(types.SiaPublicKey, time.Duration)                           { return }
+func (stubHostDB) RecordUpload(types.SiaPublicKey, uint64, time.Duration)                    { return }
 func (stubHostDB) ScoreBreakdown(modules.HostDBEntry) modules.HostScoreBreakdown {
 	return modules.HostScoreBreakdown{}
 }
@@ -539,6 +636,9 @@ func (ws *testWalletShim) NextAddress() (types.UnlockConditions, error) {
 	ws.nextAddressCalled = true
 	return types.UnlockConditions{}, nil
 }
+func (ws *testWalletShim) PrimarySeed() (modules.Seed, uint64, error) {
+	return modules.Seed{}, 0, nil
+}
 func (ws *testWalletShim) StartTransaction() (modules.TransactionBuilder, error) {
 	ws.startTxnCalled = true
 	return nil, nil
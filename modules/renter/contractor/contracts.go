@@ -81,6 +81,10 @@ func (c *Contractor) managedMarkContractsUtility() error {
 		return err
 	}
 
+	c.mu.RLock()
+	limits := allowancePriceLimits(c.allowance)
+	c.mu.RUnlock()
+
 	// Find the minimum score that a host is allowed to have to be considered
 	// good for upload.
 	var minScore types.Currency
@@ -116,6 +120,20 @@ func (c *Contractor) managedMarkContractsUtility() error {
 				u.GoodForRenew = false
 				return
 			}
+			// Contract has no utility if the host is excluded by the filter
+			// mode of the hostdb.
+			if host.Filtered {
+				u.GoodForUpload = false
+				u.GoodForRenew = false
+				return
+			}
+			// Contract has no utility if the host's prices exceed the caps of
+			// the allowance.
+			if limits.check(host) != nil {
+				u.GoodForUpload = false
+				u.GoodForRenew = false
+				return
+			}
 			// Contract has no utility if the host is offline.
 			if isOffline(host) {
 				u.GoodForUpload = false
@@ -157,8 +175,13 @@ func (c *Contractor) managedMarkContractsUtility() error {
 // host, saves it, and returns it.
 func (c *Contractor) managedNewContract(host modules.HostDBEntry, contractFunding types.Currency, endHeight types.BlockHeight) (modules.RenterContract, error) {
 	// reject hosts that are too expensive
+	c.mu.RLock()
+	limits := allowancePriceLimits(c.allowance)
+	c.mu.RUnlock()
 	if host.StoragePrice.Cmp(maxStoragePrice) > 0 {
 		return modules.RenterContract{}, errTooExpensive
+	} else if err := limits.check(host); err != nil {
+		return modules.RenterContract{}, err
 	}
 	// cap host.MaxCollateral
 	if host.MaxCollateral.Cmp(maxCollateral) > 0 {
@@ -170,6 +193,10 @@ func (c *Contractor) managedNewContract(host modules.HostDBEntry, contractFundin
 	if err != nil {
 		return modules.RenterContract{}, err
 	}
+	renterSeed, err := c.renterSeed()
+	if err != nil {
+		return modules.RenterContract{}, err
+	}
 
 	// create contract params
 	c.mu.RLock()
@@ -179,6 +206,7 @@ func (c *Contractor) managedNewContract(host modules.HostDBEntry, contractFundin
 		StartHeight:   c.blockHeight,
 		EndHeight:     endHeight,
 		RefundAddress: uc.UnlockHash(),
+		RenterSeed:    renterSeed,
 	}
 	c.mu.RUnlock()
 
@@ -219,6 +247,12 @@ func (c *Contractor) managedRenew(sc *proto.SafeContract, contractFunding types.
 	} else if host.StoragePrice.Cmp(maxStoragePrice) > 0 {
 		return modules.RenterContract{}, errTooExpensive
 	}
+	c.mu.RLock()
+	limits := allowancePriceLimits(c.allowance)
+	c.mu.RUnlock()
+	if err := limits.check(host); err != nil {
+		return modules.RenterContract{}, err
+	}
 	// cap host.MaxCollateral
 	if host.MaxCollateral.Cmp(maxCollateral) > 0 {
 		host.MaxCollateral = maxCollateral
@@ -229,6 +263,10 @@ func (c *Contractor) managedRenew(sc *proto.SafeContract, contractFunding types.
 	if err != nil {
 		return modules.RenterContract{}, err
 	}
+	renterSeed, err := c.renterSeed()
+	if err != nil {
+		return modules.RenterContract{}, err
+	}
 
 	// create contract params
 	c.mu.RLock()
@@ -238,6 +276,7 @@ func (c *Contractor) managedRenew(sc *proto.SafeContract, contractFunding types.
 		StartHeight:   c.blockHeight,
 		EndHeight:     newEndHeight,
 		RefundAddress: uc.UnlockHash(),
+		RenterSeed:    renterSeed,
 	}
 	c.mu.RUnlock()
 
@@ -329,6 +368,16 @@ func (c *Contractor) threadedContractMaintenance() {
 	// Grab the end height that should be used for the contracts.
 	endHeight = currentPeriod + allowance.Period
 
+	// Grab the price limits and the estimated transaction fee that are used to
+	// size the contracts.
+	limits := allowancePriceLimits(allowance)
+	_, maxFee := c.tpool.FeeEstimation()
+	txnFee := maxFee.Mul64(estimatedFormationTxnSize)
+	var duration types.BlockHeight
+	if endHeight > blockHeight {
+		duration = endHeight - blockHeight
+	}
+
 	// Determine how many funds have been used already in this billing
 	// cycle, and how many funds are remaining. We have to calculate these
 	// numbers separately to avoid underflow, and then re-join them later to
@@ -400,6 +449,15 @@ func (c *Contractor) threadedContractMaintenance() {
 			estimatedFees := contract.ContractFee.Add(contract.TxnFee).Add(contract.SiafundFee)
 			renewAmount = renewAmount.Add(estimatedFees)
 
+			// If the allowance specifies an expected usage, renew with at
+			// least the funds needed to cover it.
+			if host, ok := c.hdb.Host(contract.HostPublicKey); ok && expectsUsage(allowance) {
+				expected := contractFunding(allowance, host, duration, txnFee)
+				if expected.Cmp(renewAmount) > 0 {
+					renewAmount = expected
+				}
+			}
+
 			// Determine if there is enough funds available to suppliement
 			// with a 33% bonus, and if there is, add a 33% bonus.
 			moneyBuffer := renewAmount.Div64(3)
@@ -426,6 +484,8 @@ func (c *Contractor) threadedContractMaintenance() {
 			// this is here for extra safety.
 			if host.StoragePrice.Cmp(maxStoragePrice) > 0 || host.UploadBandwidthPrice.Cmp(maxUploadPrice) > 0 {
 				continue
+			} else if limits.checkUpload(host) != nil {
+				continue
 			}
 
 			blockBytes := types.NewCurrency64(modules.SectorSize * uint64(contract.EndHeight-blockHeight))
@@ -629,7 +689,6 @@ func (c *Contractor) threadedContractMaintenance() {
 	for _, contract := range c.staticContracts.ViewAll() {
 		exclude = append(exclude, contract.HostPublicKey)
 	}
-	initialContractFunds := c.allowance.Funds.Div64(c.allowance.Hosts).Div64(3)
 	c.mu.RUnlock()
 	hosts, err := c.hdb.RandomHosts(neededContracts*2+randomHostsBufferForScore, exclude)
 	if err != nil {
@@ -640,7 +699,14 @@ func (c *Contractor) threadedContractMaintenance() {
 	// Form contracts with the hosts one at a time, until we have enough
 	// contracts.
 	for _, host := range hosts {
-		// Determine if we have enough money to form a new contract.
+		// Skip hosts whose prices exceed the caps of the allowance.
+		if limits.check(host) != nil {
+			continue
+		}
+
+		// Determine if we have enough money to form a new contract. The
+		// contract is sized from the expected usage of the allowance.
+		initialContractFunds := contractFunding(allowance, host, duration, txnFee)
 		if fundsAvailable.Cmp(initialContractFunds) < 0 {
 			c.log.Println("WARN: need to form new contracts, but unable to because of a low allowance")
 			break
@@ -2,6 +2,7 @@ package contractor
 
 import (
 	"path/filepath"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
@@ -21,10 +22,12 @@ type (
 	// transactionBuilder.
 	walletShim interface {
 		NextAddress() (types.UnlockConditions, error)
+		PrimarySeed() (modules.Seed, uint64, error)
 		StartTransaction() (modules.TransactionBuilder, error)
 	}
 	wallet interface {
 		NextAddress() (types.UnlockConditions, error)
+		PrimarySeed() (modules.Seed, uint64, error)
 		StartTransaction() (transactionBuilder, error)
 	}
 	transactionBuilder interface {
@@ -54,6 +57,9 @@ type (
 		IncrementSuccessfulInteractions(key types.SiaPublicKey)
 		IncrementFailedInteractions(key types.SiaPublicKey)
 		RandomHosts(n int, exclude []types.SiaPublicKey) ([]modules.HostDBEntry, error)
+		RecordDownload(key types.SiaPublicKey, size uint64, elapsed time.Duration)
+		RecordLatency(key types.SiaPublicKey, latency time.Duration)
+		RecordUpload(key types.SiaPublicKey, size uint64, elapsed time.Duration)
 		ScoreBreakdown(modules.HostDBEntry) modules.HostScoreBreakdown
 	}
 
@@ -73,6 +79,9 @@ type WalletBridge struct {
 // NextAddress computes and returns the next address of the wallet.
 func (ws *WalletBridge) NextAddress() (types.UnlockConditions, error) { return ws.W.NextAddress() }
 
+// PrimarySeed returns the primary seed of the wallet.
+func (ws *WalletBridge) PrimarySeed() (modules.Seed, uint64, error) { return ws.W.PrimarySeed() }
+
 // StartTransaction creates a new transactionBuilder that can be used to create
 // and sign a transaction.
 func (ws *WalletBridge) StartTransaction() (transactionBuilder, error) { return ws.W.StartTransaction() }
@@ -18,8 +18,19 @@ var errInvalidDownloader = errors.New("downloader has been invalidated because i
 type Downloader interface {
 	// Sector retrieves the sector with the specified Merkle root, and revises
 	// the underlying contract to pay the host proportionally to the data
-	// retrieve.
-	Sector(root crypto.Hash) ([]byte, error)
+	// retrieve. The money spent on the download is returned along with the
+	// sector.
+	Sector(root crypto.Hash) ([]byte, modules.DataSpending, error)
+
+	// PartialSector retrieves length bytes of the sector with the specified
+	// Merkle root, starting at offset. The data is verified against the
+	// Merkle root of the sector.
+	PartialSector(root crypto.Hash, offset, length uint64) ([]byte, modules.DataSpending, error)
+
+	// Download retrieves the requested ranges of sectors in a single round
+	// trip. The data is verified against the Merkle roots of the sectors.
+	// Hosts that can't prove parts of sectors are paid for the full sectors.
+	Download(actions []modules.DownloadAction) ([][]byte, modules.DataSpending, error)
 
 	// Close terminates the connection to the host.
 	Close() error
@@ -86,19 +97,57 @@ func (hd *hostDownloader) HostSettings() modules.HostExternalSettings {
 // Sector retrieves the sector with the specified Merkle root, and revises
 // the underlying contract to pay the host proportionally to the data
 // retrieve.
-func (hd *hostDownloader) Sector(root crypto.Hash) ([]byte, error) {
+func (hd *hostDownloader) Sector(root crypto.Hash) ([]byte, modules.DataSpending, error) {
 	hd.mu.Lock()
 	defer hd.mu.Unlock()
 	if hd.invalid {
-		return nil, errInvalidDownloader
+		return nil, modules.DataSpending{}, errInvalidDownloader
 	}
 
 	// Download the sector.
-	_, sector, err := hd.downloader.Sector(root)
+	before, _ := hd.contractor.staticContracts.View(hd.contractID)
+	after, sector, err := hd.downloader.Sector(root)
 	if err != nil {
-		return nil, err
+		return nil, modules.DataSpending{}, err
 	}
-	return sector, nil
+	return sector, revisionSpending(before, after), nil
+}
+
+// PartialSector retrieves length bytes of the sector with the specified Merkle
+// root, starting at offset, and revises the underlying contract to pay the
+// host for the retrieved data.
+func (hd *hostDownloader) PartialSector(root crypto.Hash, offset, length uint64) ([]byte, modules.DataSpending, error) {
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+	if hd.invalid {
+		return nil, modules.DataSpending{}, errInvalidDownloader
+	}
+
+	// Download the part of the sector.
+	before, _ := hd.contractor.staticContracts.View(hd.contractID)
+	after, data, err := hd.downloader.PartialSector(root, offset, length)
+	if err != nil {
+		return nil, modules.DataSpending{}, err
+	}
+	return data, revisionSpending(before, after), nil
+}
+
+// Download retrieves the requested ranges of sectors in a single round trip,
+// and revises the underlying contract to pay the host for the retrieved data.
+func (hd *hostDownloader) Download(actions []modules.DownloadAction) ([][]byte, modules.DataSpending, error) {
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+	if hd.invalid {
+		return nil, modules.DataSpending{}, errInvalidDownloader
+	}
+
+	// Download the ranges.
+	before, _ := hd.contractor.staticContracts.View(hd.contractID)
+	after, data, err := hd.downloader.Download(actions)
+	if err != nil {
+		return nil, modules.DataSpending{}, err
+	}
+	return data, revisionSpending(before, after), nil
 }
 
 // Downloader returns a Downloader object that can be used to download sectors
@@ -133,6 +182,14 @@ func (c *Contractor) Downloader(id types.FileContractID, cancel <-chan struct{})
 	} else if host.DownloadBandwidthPrice.Cmp(maxDownloadPrice) > 0 {
 		return nil, errTooExpensive
 	}
+	c.mu.RLock()
+	limits := allowancePriceLimits(c.allowance)
+	c.mu.RUnlock()
+	if err := limits.checkDownload(host); err != nil {
+		return nil, err
+	} else if err := c.managedCheckSpendingCap(false, false, true); err != nil {
+		return nil, err
+	}
 
 	// Acquire the revising lock for the contract, which excludes other threads
 	// from interacting with the contract.
@@ -176,3 +233,142 @@ func (c *Contractor) Downloader(id types.FileContractID, cancel <-chan struct{})
 
 	return hd, nil
 }
+
+// A ticketDownloader retrieves sectors of another renter's contract by
+// presenting a download ticket to a host. The downloads are paid for with one
+// of the contractor's own contracts. Unlike hostDownloaders, ticketDownloaders
+// are not shared and are not safe for use by multiple goroutines.
+type ticketDownloader struct {
+	contractID types.FileContractID
+	contractor *Contractor
+	downloader *proto.Downloader
+	once       sync.Once
+}
+
+// Close cleanly terminates the download loop with the host and releases the
+// contract.
+func (td *ticketDownloader) Close() (err error) {
+	td.once.Do(func() {
+		err = td.downloader.Close()
+		td.contractor.mu.Lock()
+		delete(td.contractor.revising, td.contractID)
+		td.contractor.mu.Unlock()
+	})
+	return err
+}
+
+// Sector retrieves the sector with the specified Merkle root, and revises
+// the contract of the downloader to pay the host proportionally to the data
+// retrieve.
+func (td *ticketDownloader) Sector(root crypto.Hash) ([]byte, modules.DataSpending, error) {
+	before, _ := td.contractor.staticContracts.View(td.contractID)
+	after, sector, err := td.downloader.Sector(root)
+	if err != nil {
+		return nil, modules.DataSpending{}, err
+	}
+	return sector, revisionSpending(before, after), nil
+}
+
+// PartialSector retrieves length bytes of the sector with the specified Merkle
+// root, starting at offset, and revises the contract of the downloader to pay
+// the host for the retrieved data.
+func (td *ticketDownloader) PartialSector(root crypto.Hash, offset, length uint64) ([]byte, modules.DataSpending, error) {
+	before, _ := td.contractor.staticContracts.View(td.contractID)
+	after, data, err := td.downloader.PartialSector(root, offset, length)
+	if err != nil {
+		return nil, modules.DataSpending{}, err
+	}
+	return data, revisionSpending(before, after), nil
+}
+
+// Download retrieves the requested ranges of sectors in a single round trip,
+// and revises the contract of the downloader to pay the host for the
+// retrieved data.
+func (td *ticketDownloader) Download(actions []modules.DownloadAction) ([][]byte, modules.DataSpending, error) {
+	before, _ := td.contractor.staticContracts.View(td.contractID)
+	after, data, err := td.downloader.Download(actions)
+	if err != nil {
+		return nil, modules.DataSpending{}, err
+	}
+	return data, revisionSpending(before, after), nil
+}
+
+// DownloadTicket creates a download ticket that allows other renters to
+// download the sectors with the given roots of a contract until the
+// expiration height.
+func (c *Contractor) DownloadTicket(id types.FileContractID, roots []crypto.Hash, expiration types.BlockHeight) (modules.DownloadTicket, error) {
+	id = c.ResolveID(id)
+	c.mu.RLock()
+	height := c.blockHeight
+	c.mu.RUnlock()
+	contract, haveContract := c.staticContracts.View(id)
+	if !haveContract {
+		return modules.DownloadTicket{}, errors.New("no record of that contract")
+	} else if height >= contract.EndHeight {
+		return modules.DownloadTicket{}, errors.New("contract has already ended")
+	} else if expiration <= height {
+		return modules.DownloadTicket{}, errors.New("download ticket would already be expired")
+	} else if expiration > contract.EndHeight {
+		// The host forgets the sectors of the contract once it ends.
+		expiration = contract.EndHeight
+	}
+	return c.staticContracts.SignTicket(id, roots, expiration)
+}
+
+// TicketDownloader returns a Downloader that presents a download ticket to the
+// host of the contract id, downloading the sectors granted by the ticket and
+// paying for them with the contract id.
+func (c *Contractor) TicketDownloader(id types.FileContractID, ticket modules.DownloadTicket, cancel <-chan struct{}) (_ Downloader, err error) {
+	id = c.ResolveID(id)
+	c.mu.RLock()
+	height := c.blockHeight
+	renewing := c.renewing[id]
+	c.mu.RUnlock()
+	if renewing {
+		return nil, errors.New("currently renewing that contract")
+	} else if ticket.Expiration <= height {
+		return nil, errors.New("download ticket has expired")
+	}
+
+	// Fetch the contract and host.
+	contract, haveContract := c.staticContracts.View(id)
+	if !haveContract {
+		return nil, errors.New("no record of that contract")
+	}
+	host, haveHost := c.hdb.Host(contract.HostPublicKey)
+	if height > contract.EndHeight {
+		return nil, errors.New("contract has already ended")
+	} else if !haveHost {
+		return nil, errors.New("no record of that host")
+	} else if host.DownloadBandwidthPrice.Cmp(maxDownloadPrice) > 0 {
+		return nil, errTooExpensive
+	}
+
+	// Acquire the revising lock for the contract.
+	c.mu.Lock()
+	alreadyRevising := c.revising[contract.ID]
+	if alreadyRevising {
+		c.mu.Unlock()
+		return nil, errors.New("already revising that contract")
+	}
+	c.revising[contract.ID] = true
+	c.mu.Unlock()
+	// release lock early if function returns an error
+	defer func() {
+		if err != nil {
+			c.mu.Lock()
+			delete(c.revising, contract.ID)
+			c.mu.Unlock()
+		}
+	}()
+
+	d, err := c.staticContracts.NewTicketDownloader(host, contract.ID, ticket, c.hdb, cancel)
+	if err != nil {
+		return nil, err
+	}
+	return &ticketDownloader{
+		contractID: contract.ID,
+		contractor: c,
+		downloader: d,
+	}, nil
+}
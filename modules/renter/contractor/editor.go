@@ -20,8 +20,8 @@ var maxUploadCollateral = types.SiacoinPrecision.Mul64(1e3).Div(modules.BlockByt
 // Editors are the means by which the renter uploads data to hosts.
 type Editor interface {
 	// Upload revises the underlying contract to store the new data. It
-	// returns the Merkle root of the data.
-	Upload(data []byte) (root crypto.Hash, err error)
+	// returns the Merkle root of the data and the money spent on the upload.
+	Upload(data []byte) (root crypto.Hash, spending modules.DataSpending, err error)
 
 	// Address returns the address of the host.
 	Address() modules.NetAddress
@@ -98,19 +98,32 @@ func (he *hostEditor) Close() error {
 }
 
 // Upload negotiates a revision that adds a sector to a file contract.
-func (he *hostEditor) Upload(data []byte) (_ crypto.Hash, err error) {
+func (he *hostEditor) Upload(data []byte) (_ crypto.Hash, _ modules.DataSpending, err error) {
 	he.mu.Lock()
 	defer he.mu.Unlock()
 	if he.invalid {
-		return crypto.Hash{}, errInvalidEditor
+		return crypto.Hash{}, modules.DataSpending{}, errInvalidEditor
 	}
 
-	// Perform the upload.
-	_, sectorRoot, err := he.editor.Upload(data)
+	// Perform the upload. The contract can't be revised by anyone else while
+	// the editor exists, so the spending of the revision is the difference
+	// between the contract before and after the upload.
+	before, _ := he.contractor.staticContracts.View(he.id)
+	after, sectorRoot, err := he.editor.Upload(data)
 	if err != nil {
-		return crypto.Hash{}, err
+		return crypto.Hash{}, modules.DataSpending{}, err
+	}
+	return sectorRoot, revisionSpending(before, after), nil
+}
+
+// revisionSpending returns the money that was spent on the revision of a
+// contract, given the contract before and after the revision.
+func revisionSpending(before, after modules.RenterContract) modules.DataSpending {
+	return modules.DataSpending{
+		DownloadSpending: after.DownloadSpending.Sub(before.DownloadSpending),
+		StorageSpending:  after.StorageSpending.Sub(before.StorageSpending),
+		UploadSpending:   after.UploadSpending.Sub(before.UploadSpending),
 	}
-	return sectorRoot, nil
 }
 
 // Editor returns a Editor object that can be used to upload, modify, and
@@ -151,6 +164,14 @@ func (c *Contractor) Editor(id types.FileContractID, cancel <-chan struct{}) (_
 	} else if host.UploadBandwidthPrice.Cmp(maxUploadPrice) > 0 {
 		return nil, errTooExpensive
 	}
+	c.mu.RLock()
+	limits := allowancePriceLimits(c.allowance)
+	c.mu.RUnlock()
+	if err := limits.checkUpload(host); err != nil {
+		return nil, err
+	} else if err := c.managedCheckSpendingCap(true, true, false); err != nil {
+		return nil, err
+	}
 
 	// Acquire the revising lock.
 	c.mu.Lock()
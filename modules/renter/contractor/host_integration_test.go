@@ -202,6 +202,19 @@ func TestIntegrationFormContract(t *testing.T) {
 		t.Fatal("no entry for host in db")
 	}
 
+	// the host should be refused if its storage price exceeds the cap of the
+	// allowance
+	c.mu.Lock()
+	c.allowance.MaxPricePerTB = hostEntry.StoragePrice.Mul(modules.BlockBytesPerMonthTerabyte).Div64(2)
+	c.mu.Unlock()
+	_, err = c.managedNewContract(hostEntry, types.SiacoinPrecision.Mul64(50), c.blockHeight+100)
+	if err != errTooExpensive {
+		t.Fatal("expected errTooExpensive, got", err)
+	}
+	c.mu.Lock()
+	c.allowance.MaxPricePerTB = types.ZeroCurrency
+	c.mu.Unlock()
+
 	// form a contract with the host
 	_, err = c.managedNewContract(hostEntry, types.SiacoinPrecision.Mul64(50), c.blockHeight+100)
 	if err != nil {
@@ -242,7 +255,7 @@ func TestIntegrationReviseContract(t *testing.T) {
 		t.Fatal(err)
 	}
 	data := fastrand.Bytes(int(modules.SectorSize))
-	_, err = editor.Upload(data)
+	_, _, err = editor.Upload(data)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -285,7 +298,7 @@ func TestIntegrationUploadDownload(t *testing.T) {
 		t.Fatal(err)
 	}
 	data := fastrand.Bytes(int(modules.SectorSize))
-	root, err := editor.Upload(data)
+	root, uploadSpending, err := editor.Upload(data)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -294,18 +307,28 @@ func TestIntegrationUploadDownload(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// the spending of the upload should match the spending of the contract
+	revised, _ := c.staticContracts.View(contract.ID)
+	if uploadSpending.UploadSpending.Cmp(revised.UploadSpending) != 0 || uploadSpending.StorageSpending.Cmp(revised.StorageSpending) != 0 || !uploadSpending.DownloadSpending.IsZero() {
+		t.Fatal("upload spending doesn't match the contract:", uploadSpending, revised.UploadSpending, revised.StorageSpending)
+	}
+
 	// download the data
 	downloader, err := c.Downloader(contract.ID, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	retrieved, err := downloader.Sector(root)
+	retrieved, downloadSpending, err := downloader.Sector(root)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if !bytes.Equal(data, retrieved) {
 		t.Fatal("downloaded data does not match original")
 	}
+	revised, _ = c.staticContracts.View(contract.ID)
+	if downloadSpending.DownloadSpending.Cmp(revised.DownloadSpending) != 0 || !downloadSpending.UploadSpending.IsZero() {
+		t.Fatal("download spending doesn't match the contract:", downloadSpending, revised.DownloadSpending)
+	}
 	err = downloader.Close()
 	if err != nil {
 		t.Fatal(err)
@@ -346,7 +369,7 @@ func TestIntegrationRenew(t *testing.T) {
 	}
 	data := fastrand.Bytes(int(modules.SectorSize))
 	// insert the sector
-	root, err := editor.Upload(data)
+	root, _, err := editor.Upload(data)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -377,7 +400,7 @@ func TestIntegrationRenew(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	retrieved, err := downloader.Sector(root)
+	retrieved, _, err := downloader.Sector(root)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -411,7 +434,7 @@ func TestIntegrationRenew(t *testing.T) {
 	}
 	data = fastrand.Bytes(int(modules.SectorSize))
 	// insert the sector
-	_, err = editor.Upload(data)
+	_, _, err = editor.Upload(data)
 	if err != nil {
 		t.Fatal(err)
 	}
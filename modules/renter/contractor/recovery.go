@@ -0,0 +1,135 @@
+package contractor
+
+import (
+	"sort"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/proto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// A recoveryScanner scans the blockchain for the contracts that were formed
+// with a renter seed.
+type recoveryScanner struct {
+	seed      proto.RenterSeed
+	height    types.BlockHeight
+	contracts map[types.FileContractID]proto.RecoverableContract
+}
+
+// ProcessConsensusChange adds the contracts formed with the scanner's seed to
+// the set of found contracts.
+func (rs *recoveryScanner) ProcessConsensusChange(cc modules.ConsensusChange) {
+	for _, block := range cc.RevertedBlocks {
+		for _, txn := range block.Transactions {
+			if rc, ok := rs.seed.FindContract(txn); ok {
+				delete(rs.contracts, rc.ID)
+			}
+		}
+		if block.ID() != types.GenesisID {
+			rs.height--
+		}
+	}
+	for _, block := range cc.AppliedBlocks {
+		if block.ID() != types.GenesisID {
+			rs.height++
+		}
+		for _, txn := range block.Transactions {
+			if rc, ok := rs.seed.FindContract(txn); ok {
+				rc.StartHeight = rs.height
+				rs.contracts[rc.ID] = rc
+			}
+		}
+	}
+}
+
+// renterSeed derives the renter seed from the primary seed of the wallet,
+// which must be unlocked.
+func (c *Contractor) renterSeed() (proto.RenterSeed, error) {
+	seed, _, err := c.wallet.PrimarySeed()
+	if err != nil {
+		return proto.RenterSeed{}, err
+	}
+	return proto.DeriveRenterSeed(seed), nil
+}
+
+// RecoverContracts scans the blockchain for the contracts that were formed
+// with the wallet seed, and recovers the unexpired ones from their hosts.
+// Contracts that the contractor already knows about are left untouched. If
+// several contracts with the same host are found, the older ones are treated
+// as renewed by the newest one. It returns the number of recovered contracts.
+func (c *Contractor) RecoverContracts() (int, error) {
+	if err := c.tg.Add(); err != nil {
+		return 0, err
+	}
+	defer c.tg.Done()
+
+	seed, err := c.renterSeed()
+	if err != nil {
+		return 0, err
+	}
+	scanner := &recoveryScanner{
+		seed:      seed,
+		contracts: make(map[types.FileContractID]proto.RecoverableContract),
+	}
+	err = c.cs.ConsensusSetSubscribe(scanner, modules.ConsensusChangeBeginning, c.tg.StopChan())
+	if err != nil {
+		return 0, err
+	}
+	c.cs.Unsubscribe(scanner)
+
+	// Group the unexpired contracts by host, oldest first.
+	byHost := make(map[string][]proto.RecoverableContract)
+	for _, rc := range scanner.contracts {
+		if scanner.height > rc.WindowStart {
+			continue
+		}
+		byHost[rc.HostPublicKey.String()] = append(byHost[rc.HostPublicKey.String()], rc)
+	}
+
+	var recovered int
+	renewedIDs := make(map[types.FileContractID]types.FileContractID)
+	for _, contracts := range byHost {
+		sort.Slice(contracts, func(i, j int) bool {
+			return contracts[i].StartHeight < contracts[j].StartHeight
+		})
+		for i := 0; i < len(contracts)-1; i++ {
+			renewedIDs[contracts[i].ID] = contracts[i+1].ID
+		}
+		rc := contracts[len(contracts)-1]
+
+		c.mu.RLock()
+		_, isOld := c.oldContracts[rc.ID]
+		c.mu.RUnlock()
+		if _, exists := c.staticContracts.View(rc.ID); exists || isOld {
+			continue
+		}
+		host, ok := c.hdb.Host(rc.HostPublicKey)
+		if !ok {
+			c.log.Println("WARN: unable to recover contract", rc.ID, "with unknown host", rc.HostPublicKey)
+			continue
+		}
+		_, err := c.staticContracts.RecoverContract(rc, seed, host, c.tg.StopChan())
+		if err != nil {
+			c.log.Println("WARN: unable to recover contract", rc.ID, err)
+			continue
+		}
+		c.log.Println("INFO: recovered contract", rc.ID)
+		recovered++
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for oldID, newID := range renewedIDs {
+		if _, exists := c.renewedIDs[oldID]; !exists {
+			c.renewedIDs[oldID] = newID
+		}
+	}
+	return recovered, c.saveSync()
+}
+
+// MerkleRoots returns the Merkle roots of the sectors covered by the contract
+// with the specified ID.
+func (c *Contractor) MerkleRoots(id types.FileContractID) ([]crypto.Hash, error) {
+	return c.staticContracts.MerkleRoots(c.ResolveID(id))
+}
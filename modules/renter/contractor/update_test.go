@@ -55,7 +55,7 @@ func TestIntegrationAutoRenew(t *testing.T) {
 	}
 	data := fastrand.Bytes(int(modules.SectorSize))
 	// insert the sector
-	_, err = editor.Upload(data)
+	_, _, err = editor.Upload(data)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -126,7 +126,7 @@ func TestIntegrationRenewInvalidate(t *testing.T) {
 	}
 	data := fastrand.Bytes(int(modules.SectorSize))
 	// insert the sector
-	_, err = editor.Upload(data)
+	_, _, err = editor.Upload(data)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -153,7 +153,7 @@ func TestIntegrationRenewInvalidate(t *testing.T) {
 	c.mu.Unlock()
 
 	// editor should have been invalidated
-	_, err = editor.Upload(make([]byte, modules.SectorSize))
+	_, _, err = editor.Upload(make([]byte, modules.SectorSize))
 	if err != errInvalidEditor {
 		t.Error("expected invalid editor error; got", err)
 	}
@@ -178,7 +178,7 @@ func TestIntegrationRenewInvalidate(t *testing.T) {
 		// wait for goroutine in ProcessConsensusChange to finish
 		c.maintenanceLock.Lock()
 		c.maintenanceLock.Unlock()
-		_, err2 := downloader.Sector(crypto.Hash{})
+		_, _, err2 := downloader.Sector(crypto.Hash{})
 		if err2 != errInvalidDownloader {
 			return errors.AddContext(err, "expected invalid downloader error")
 		}
@@ -0,0 +1,350 @@
+package renter
+
+// dedup.go deduplicates identical chunks across files.
+//
+// The pieces of regular files are encrypted with keys derived from the random
+// master key of each file, so identical data is stored again for every file
+// that contains it. Files that are uploaded with deduplication use convergent
+// encryption instead: the key of every chunk is derived from the content of the
+// chunk and a secret of the renter. Identical chunks that are erasure coded the
+// same way result in identical pieces, which only need to be stored once.
+//
+// The renter keeps an index of the chunks of all deduplicated files. Every
+// chunk in the index references the file chunks that contain it. When a chunk
+// of a deduplicated file is uploaded or repaired, the pieces that are already
+// stored for another file chunk with the same content are reused instead of
+// being uploaded again. A chunk is removed from the index once no file
+// references it anymore.
+//
+// The secret and the chunk keys of the deduplicated files are persisted in the
+// dedup file. The index itself is rebuilt from the chunk keys on startup.
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+const (
+	// DedupFilename is the filename of the file that contains the chunk keys
+	// of the renter's deduplicated files.
+	DedupFilename = "dedup.json"
+)
+
+var (
+	dedupMetadata = persist.Metadata{
+		Header:  "Renter Dedup",
+		Version: "1.0",
+	}
+
+	// errShareDedup is returned when trying to share a deduplicated file,
+	// since the .sia format can't store the keys of its chunks.
+	errShareDedup = errors.New("deduplicated files can't be shared")
+)
+
+type (
+	// dedupRef references a chunk of a deduplicated file.
+	dedupRef struct {
+		f     *file
+		chunk uint64
+	}
+
+	// dedupChunk is a chunk in the dedup index, along with the file chunks
+	// that contain it.
+	dedupChunk struct {
+		refs []dedupRef
+	}
+
+	// dedupFile contains the chunk keys of a single deduplicated file. Chunks
+	// that haven't been uploaded yet don't have a key.
+	dedupFile struct {
+		SiaPath   string                 `json:"siapath"`
+		ChunkKeys map[uint64]crypto.Hash `json:"chunkkeys"`
+	}
+
+	// dedupPersist is the object persisted in the dedup file.
+	dedupPersist struct {
+		Secret crypto.Hash `json:"secret"`
+		Files  []dedupFile `json:"files"`
+	}
+
+	// dedupPiece is a piece of a chunk in the dedup index that is stored on a
+	// host.
+	dedupPiece struct {
+		contract fileContract
+		piece    pieceData
+	}
+)
+
+// pieceKey returns the key used to encrypt and decrypt a piece of the file.
+// The caller must hold the file lock.
+func (f *file) pieceKey(chunkIndex, pieceIndex uint64) crypto.CipherKey {
+	if !f.dedup {
+		return deriveKey(f.cipherType, f.masterKey, chunkIndex, pieceIndex)
+	}
+	// The keys of deduplicated chunks don't depend on the position of the
+	// chunk, so that identical chunks result in identical pieces.
+	return deriveKey(f.cipherType, crypto.TwofishKey(f.chunkKeys[chunkIndex]), 0, pieceIndex)
+}
+
+// dedupID returns the identifier of a chunk of f with the given key in the
+// dedup index. Chunks can only share pieces if they are erasure coded the same
+// way.
+func (f *file) dedupID(key crypto.Hash) crypto.Hash {
+	return crypto.HashAll(key, f.erasureCode.Identifier(), f.erasureCode.MinPieces(), f.erasureCode.NumPieces(), f.pieceSize)
+}
+
+// convergentKey derives the key of a deduplicated chunk from its logical data.
+func (r *Renter) convergentKey(data [][]byte) (key crypto.Hash) {
+	h := crypto.NewHash()
+	h.Write(r.dedupSecret[:])
+	for _, b := range data {
+		h.Write(b)
+	}
+	h.Sum(key[:0])
+	return key
+}
+
+// addDedupRef sets the key of a chunk of f and adds the chunk to the dedup
+// index. The caller must hold the renter lock and the file lock.
+func (r *Renter) addDedupRef(f *file, chunkIndex uint64, key crypto.Hash) {
+	f.chunkKeys[chunkIndex] = key
+	id := f.dedupID(key)
+	dc, exists := r.dedupChunks[id]
+	if !exists {
+		dc = new(dedupChunk)
+		r.dedupChunks[id] = dc
+	}
+	dc.refs = append(dc.refs, dedupRef{f: f, chunk: chunkIndex})
+}
+
+// removeDedupRefs removes the chunks of f from the dedup index. The caller must
+// hold the renter lock and the file lock.
+func (r *Renter) removeDedupRefs(f *file) {
+	for chunkIndex, key := range f.chunkKeys {
+		id := f.dedupID(key)
+		dc, exists := r.dedupChunks[id]
+		if !exists {
+			continue
+		}
+		refs := dc.refs[:0]
+		for _, ref := range dc.refs {
+			if ref.f != f || ref.chunk != chunkIndex {
+				refs = append(refs, ref)
+			}
+		}
+		dc.refs = refs
+		if len(dc.refs) == 0 {
+			delete(r.dedupChunks, id)
+		}
+	}
+}
+
+// managedDedupChunk reuses the pieces of identical chunks for a chunk of a
+// deduplicated file whose logical data has been fetched. The key of the chunk
+// is derived from the data the first time the chunk is uploaded. The reused
+// pieces are marked as completed. It returns the number of reused pieces.
+func (r *Renter) managedDedupChunk(uc *unfinishedUploadChunk) int {
+	f := uc.renterFile
+
+	// Set the key of the chunk and add it to the index if necessary. The
+	// key needs to be persisted before any pieces are uploaded.
+	id := r.mu.Lock()
+	f.mu.Lock()
+	if f.deleted {
+		f.mu.Unlock()
+		r.mu.Unlock(id)
+		return 0
+	}
+	key, exists := f.chunkKeys[uc.index]
+	if !exists {
+		key = r.convergentKey(uc.logicalChunkData)
+		r.addDedupRef(f, uc.index, key)
+	}
+	f.mu.Unlock()
+	if !exists {
+		if err := r.saveDedup(); err != nil {
+			r.log.Println("WARN: unable to save the dedup file:", err)
+		}
+	}
+
+	// Collect the pieces of the identical chunks, which may also be part of
+	// the same file.
+	var candidates []dedupPiece
+	if dc, ok := r.dedupChunks[f.dedupID(key)]; ok {
+		for _, ref := range dc.refs {
+			if ref.f == f && ref.chunk == uc.index {
+				continue
+			}
+			ref.f.mu.RLock()
+			for _, fc := range ref.f.contracts {
+				for _, p := range fc.Pieces {
+					if p.Chunk != ref.chunk {
+						continue
+					}
+					p.Chunk = uc.index
+					candidates = append(candidates, dedupPiece{
+						contract: fileContract{
+							ID:          fc.ID,
+							IP:          fc.IP,
+							WindowStart: fc.WindowStart,
+						},
+						piece: p,
+					})
+				}
+			}
+			ref.f.mu.RUnlock()
+		}
+	}
+	r.mu.Unlock(id)
+
+	// Only reuse pieces that are stored on good hosts that don't store a
+	// piece of the chunk yet.
+	hosts := make([]string, len(candidates))
+	for i, c := range candidates {
+		contract, exists := r.hostContractor.ContractByID(c.contract.ID)
+		utility, exists2 := r.hostContractor.ContractUtility(c.contract.ID)
+		if !exists || !exists2 || !utility.GoodForRenew {
+			continue
+		}
+		hosts[i] = contract.HostPublicKey.String()
+	}
+	var reused []dedupPiece
+	uc.mu.Lock()
+	for i, c := range candidates {
+		if hosts[i] == "" {
+			continue
+		}
+		if _, unused := uc.unusedHosts[hosts[i]]; !unused || uc.pieceUsage[c.piece.Piece] {
+			continue
+		}
+		uc.pieceUsage[c.piece.Piece] = true
+		uc.piecesCompleted++
+		delete(uc.unusedHosts, hosts[i])
+		reused = append(reused, c)
+	}
+	uc.mu.Unlock()
+	if len(reused) == 0 {
+		return 0
+	}
+
+	// Add the reused pieces to the file.
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.deleted {
+		return len(reused)
+	}
+	for _, c := range reused {
+		fc, exists := f.contracts[c.contract.ID]
+		if !exists {
+			fc = c.contract
+		}
+		fc.Pieces = append(fc.Pieces, c.piece)
+		f.contracts[fc.ID] = fc
+	}
+	if err := r.saveFile(f); err != nil {
+		r.log.Println("WARN: unable to save a deduplicated file:", err)
+	}
+	return len(reused)
+}
+
+// dedupData returns the secret and the chunk keys of the deduplicated files.
+// The caller must hold the renter lock.
+func (r *Renter) dedupData() dedupPersist {
+	dp := dedupPersist{
+		Secret: r.dedupSecret,
+		Files:  make([]dedupFile, 0),
+	}
+	for _, f := range r.files {
+		f.mu.RLock()
+		if f.dedup {
+			keys := make(map[uint64]crypto.Hash, len(f.chunkKeys))
+			for chunkIndex, key := range f.chunkKeys {
+				keys[chunkIndex] = key
+			}
+			dp.Files = append(dp.Files, dedupFile{
+				SiaPath:   f.name,
+				ChunkKeys: keys,
+			})
+		}
+		f.mu.RUnlock()
+	}
+	sort.Slice(dp.Files, func(i, j int) bool { return dp.Files[i].SiaPath < dp.Files[j].SiaPath })
+	return dp
+}
+
+// saveDedup writes the secret and the chunk keys of the deduplicated files to
+// disk. The caller must hold the renter lock.
+func (r *Renter) saveDedup() error {
+	return persist.SaveJSON(dedupMetadata, r.dedupData(), filepath.Join(r.persistDir, DedupFilename))
+}
+
+// loadDedup restores the secret and the chunk keys of the deduplicated files
+// from disk and rebuilds the dedup index. A new secret is created if no dedup
+// file exists yet.
+func (r *Renter) loadDedup() error {
+	var dp dedupPersist
+	err := persist.LoadJSON(dedupMetadata, &dp, filepath.Join(r.persistDir, DedupFilename))
+	if os.IsNotExist(err) {
+		fastrand.Read(r.dedupSecret[:])
+		return nil
+	} else if err != nil {
+		return err
+	}
+	r.dedupSecret = dp.Secret
+	for _, df := range dp.Files {
+		f, exists := r.files[df.SiaPath]
+		if !exists {
+			continue
+		}
+		f.mu.Lock()
+		f.dedup = true
+		f.chunkKeys = make(map[uint64]crypto.Hash)
+		for chunkIndex, key := range df.ChunkKeys {
+			r.addDedupRef(f, chunkIndex, key)
+		}
+		f.mu.Unlock()
+	}
+	return nil
+}
+
+// DedupStats returns information about the renter's deduplicated files.
+func (r *Renter) DedupStats() modules.DedupStats {
+	type storedPiece struct {
+		id   types.FileContractID
+		root crypto.Hash
+	}
+	var stats modules.DedupStats
+	var pieces uint64
+	stored := make(map[storedPiece]struct{})
+
+	id := r.mu.RLock()
+	stats.UniqueChunks = uint64(len(r.dedupChunks))
+	for _, f := range r.files {
+		f.mu.RLock()
+		if f.dedup {
+			stats.Files++
+			stats.Chunks += uint64(len(f.chunkKeys))
+			for _, fc := range f.contracts {
+				for _, p := range fc.Pieces {
+					pieces++
+					stored[storedPiece{id: r.hostContractor.ResolveID(fc.ID), root: p.MerkleRoot}] = struct{}{}
+				}
+			}
+		}
+		f.mu.RUnlock()
+	}
+	r.mu.RUnlock(id)
+
+	stats.StoredBytes = uint64(len(stored)) * modules.SectorSize
+	stats.SavedBytes = (pieces - uint64(len(stored))) * modules.SectorSize
+	return stats
+}
@@ -0,0 +1,136 @@
+package renter
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestDedupKeys checks that identical chunks of deduplicated files are
+// encrypted with the same keys, independent of their position.
+func TestDedupKeys(t *testing.T) {
+	r := &Renter{}
+	fastrand.Read(r.dedupSecret[:])
+	data := [][]byte{fastrand.Bytes(64), fastrand.Bytes(64)}
+	key := r.convergentKey(data)
+	if r.convergentKey([][]byte{append(data[0], data[1]...)}) != key {
+		t.Fatal("key depends on how the data is split")
+	}
+	if r.convergentKey([][]byte{data[1], data[0]}) == key {
+		t.Fatal("different data resulted in the same key")
+	}
+	r2 := &Renter{}
+	fastrand.Read(r2.dedupSecret[:])
+	if r2.convergentKey(data) == key {
+		t.Fatal("renters with different secrets derived the same key")
+	}
+
+	rsc, _ := NewRSCode(2, 1)
+	f1 := newFile("a", rsc, 64, 1000)
+	f2 := newFile("b", rsc, 64, 1000)
+	for _, f := range []*file{f1, f2} {
+		f.dedup = true
+		f.chunkKeys = make(map[uint64]crypto.Hash)
+	}
+	f1.chunkKeys[0] = key
+	f2.chunkKeys[3] = key
+	if f1.pieceKey(0, 1) != f2.pieceKey(3, 1) {
+		t.Fatal("identical chunks have different piece keys")
+	}
+	if f1.pieceKey(0, 0) == f1.pieceKey(0, 1) {
+		t.Fatal("pieces of a chunk have the same key")
+	}
+	if f1.dedupID(key) != f2.dedupID(key) {
+		t.Fatal("identical chunks have different dedup IDs")
+	}
+	f3 := newFile("c", rsc, 128, 1000)
+	if f3.dedupID(key) == f1.dedupID(key) {
+		t.Fatal("chunks with different piece sizes have the same dedup ID")
+	}
+}
+
+// TestRenterDedupIndex checks that the dedup index counts the references to
+// each chunk and that it is rebuilt after a restart.
+func TestRenterDedupIndex(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Add two files that share a chunk. The first file also contains a chunk
+	// of its own.
+	shared, other := crypto.Hash{1}, crypto.Hash{2}
+	var files []*file
+	for _, siaPath := range []string{"a", "b"} {
+		f, err := rt.addTestingFile(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	id := rt.renter.mu.Lock()
+	for i, f := range files {
+		f.mu.Lock()
+		f.dedup = true
+		f.chunkKeys = make(map[uint64]crypto.Hash)
+		rt.renter.addDedupRef(f, uint64(i), shared)
+		if i == 0 {
+			rt.renter.addDedupRef(f, 5, other)
+		}
+		f.mu.Unlock()
+	}
+	err = rt.renter.saveDedup()
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.renter.dedupChunks) != 2 || len(rt.renter.dedupChunks[files[0].dedupID(shared)].refs) != 2 {
+		t.Fatal("wrong dedup index:", rt.renter.dedupChunks)
+	}
+	if stats := rt.renter.DedupStats(); stats.Files != 2 || stats.Chunks != 3 || stats.UniqueChunks != 2 {
+		t.Fatal("wrong dedup stats:", stats)
+	}
+
+	// Deleting the first file should only remove its references.
+	if err := rt.renter.DeleteFile("a"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.renter.dedupChunks) != 1 || len(rt.renter.dedupChunks[files[1].dedupID(shared)].refs) != 1 {
+		t.Fatal("references were not removed:", rt.renter.dedupChunks)
+	}
+
+	// The keys and the index should be restored after a restart.
+	secret := rt.renter.dedupSecret
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, filepath.Join(rt.dir, modules.RenterDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.renter.dedupSecret != secret {
+		t.Fatal("secret was not restored")
+	}
+	f := rt.renter.files["b"]
+	if !f.dedup || len(f.chunkKeys) != 1 || f.chunkKeys[1] != shared {
+		t.Fatal("chunk keys were not restored:", f.chunkKeys)
+	}
+	dc, exists := rt.renter.dedupChunks[f.dedupID(shared)]
+	if len(rt.renter.dedupChunks) != 1 || !exists || len(dc.refs) != 1 || dc.refs[0].f != f || dc.refs[0].chunk != 1 {
+		t.Fatal("index was not rebuilt:", rt.renter.dedupChunks)
+	}
+	if fi, err := rt.renter.File("b"); err != nil || !fi.Deduplicated {
+		t.Fatal("file should be reported as deduplicated:", err)
+	}
+	if err := rt.renter.ShareFiles([]string{"b"}, filepath.Join(rt.dir, "b.sia")); err != errShareDedup {
+		t.Fatal("expected errShareDedup, got", err)
+	}
+}
@@ -0,0 +1,527 @@
+package renter
+
+// dirs.go maintains the directory hierarchy of the renter's filesystem. The
+// renter still keeps a flat map of siapaths to files for fast lookups, but
+// every file is also linked into a tree of siaDirs so that a single level of
+// the filesystem can be listed, renamed or deleted without looking at every
+// file the renter knows about.
+//
+// Directories are persisted as a '.siadir' metadata file inside of the
+// matching folder of the renter's persist directory, next to the '.sia' files
+// of the directory.
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// SiaDirExtension is the name of the metadata file that marks a folder in
+	// the renter's persist directory as a siadir.
+	SiaDirExtension = ".siadir"
+)
+
+var (
+	// ErrDirExists is returned when a directory already exists at the
+	// specified location.
+	ErrDirExists = errors.New("a directory already exists at that location")
+	// ErrUnknownDir is returned when no directory can be found with the given
+	// path.
+	ErrUnknownDir = errors.New("no directory known with that path")
+
+	// errRootDir is returned if the user tries to delete or rename the root
+	// directory.
+	errRootDir = errors.New("operation not allowed on the root directory")
+	// errRenameIntoSelf is returned if the user tries to move a directory into
+	// one of its own subdirectories.
+	errRenameIntoSelf = errors.New("cannot move a directory into itself")
+
+	siaDirMetadata = persist.Metadata{
+		Header:  "Sia Directory Metadata",
+		Version: "1.0",
+	}
+)
+
+type (
+	// A siaDir is a single directory of the renter's filesystem. Files and
+	// subdirectories are referenced by their full siapath, which means that
+	// they can be looked up directly in the renter's maps.
+	siaDir struct {
+		siaPath string
+		subDirs map[string]struct{}
+		files   map[string]struct{}
+	}
+
+	// siaDirPersist is the object that gets persisted in the .siadir file of
+	// a directory.
+	siaDirPersist struct {
+		SiaPath string
+	}
+)
+
+// newSiaDir returns an empty siaDir for the provided path.
+func newSiaDir(siaPath string) *siaDir {
+	return &siaDir{
+		siaPath: siaPath,
+		subDirs: make(map[string]struct{}),
+		files:   make(map[string]struct{}),
+	}
+}
+
+// parentDir returns the siapath of the directory that contains the file or
+// directory at siaPath. The root directory is represented by the empty
+// string.
+func parentDir(siaPath string) string {
+	dir := path.Dir(siaPath)
+	if dir == "." || dir == "/" {
+		return ""
+	}
+	return dir
+}
+
+// validateDirSiapath checks that a siapath is a legal name for a directory.
+// In addition to the rules for file siapaths, directory siapaths must not
+// contain empty elements, since they are used as keys of the directory tree.
+func validateDirSiapath(siaPath string) error {
+	if err := validateSiapath(siaPath); err != nil {
+		return err
+	}
+	for _, pathElem := range strings.Split(siaPath, "/") {
+		if pathElem == "" {
+			return errors.New("directory siapath cannot contain empty elements")
+		}
+	}
+	return nil
+}
+
+// siaDirFilename returns the location of the metadata file of a directory.
+func (r *Renter) siaDirFilename(siaPath string) string {
+	return filepath.Join(r.persistDir, siaPath, SiaDirExtension)
+}
+
+// saveDir writes the metadata file of a directory to disk.
+func (r *Renter) saveDir(d *siaDir) error {
+	if d.siaPath == "" {
+		// The root directory is implied by the persist dir.
+		return nil
+	}
+	// Create the folder of the directory.
+	err := os.MkdirAll(filepath.Join(r.persistDir, d.siaPath), 0700)
+	if err != nil {
+		return err
+	}
+	return persist.SaveJSON(siaDirMetadata, siaDirPersist{SiaPath: d.siaPath}, r.siaDirFilename(d.siaPath))
+}
+
+// addDir adds a directory and any of its missing parents to the directory
+// tree. The newly created directories are returned so that the caller can
+// persist them.
+func (r *Renter) addDir(siaPath string) []*siaDir {
+	if _, exists := r.dirs[siaPath]; exists {
+		return nil
+	}
+	created := r.addDir(parentDir(siaPath))
+	d := newSiaDir(siaPath)
+	r.dirs[siaPath] = d
+	r.dirs[parentDir(siaPath)].subDirs[siaPath] = struct{}{}
+	return append(created, d)
+}
+
+// fileInPath returns whether siaPath or one of its parent directories is an
+// existing file.
+func (r *Renter) fileInPath(siaPath string) bool {
+	for dir := siaPath; dir != ""; dir = parentDir(dir) {
+		if _, exists := r.files[dir]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// createDir adds a directory and any of its missing parents to the directory
+// tree and persists the new directories. ErrPathOverload is returned if the
+// directory or one of its parents is an existing file.
+func (r *Renter) createDir(siaPath string) error {
+	if r.fileInPath(siaPath) {
+		return ErrPathOverload
+	}
+	for _, d := range r.addDir(siaPath) {
+		if err := r.saveDir(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkFile adds a file to the directory that contains it, creating the
+// directory if necessary.
+func (r *Renter) linkFile(f *file) error {
+	dir := parentDir(f.name)
+	if err := r.createDir(dir); err != nil {
+		return err
+	}
+	r.dirs[dir].files[f.name] = struct{}{}
+	return nil
+}
+
+// unlinkFile removes a file from the directory that contains it.
+func (r *Renter) unlinkFile(siaPath string) {
+	if d, exists := r.dirs[parentDir(siaPath)]; exists {
+		delete(d.files, siaPath)
+	}
+}
+
+// walkDir calls fn for every directory of the subtree rooted at d. Children
+// are visited before their parents, which allows fn to remove directories.
+func (r *Renter) walkDir(d *siaDir, fn func(*siaDir)) {
+	for subDir := range d.subDirs {
+		if sd, exists := r.dirs[subDir]; exists {
+			r.walkDir(sd, fn)
+		}
+	}
+	fn(d)
+}
+
+// removeDirFromDisk removes the metadata file of a directory and, if nothing
+// else is left in it, the folder itself. The folder is not removed
+// recursively because the renter's persist dir is shared with the other
+// renter modules.
+func (r *Renter) removeDirFromDisk(siaPath string) {
+	if err := persist.RemoveFile(r.siaDirFilename(siaPath)); err != nil {
+		r.log.Println("WARN: couldn't remove siadir metadata:", err)
+	}
+	// An error is expected if the folder is not empty.
+	_ = os.Remove(filepath.Join(r.persistDir, siaPath))
+}
+
+// contractStatus builds the maps containing the offline and goodForRenew
+// status of every contract in contractIDs. The contracts that have download
+// tickets are replaced by the renter's own contracts with the same hosts.
+func (r *Renter) contractStatus(contractIDs map[types.FileContractID]struct{}, tickets map[types.FileContractID]fileTicket) (offline map[types.FileContractID]bool, goodForRenew map[types.FileContractID]bool) {
+	var hostContracts map[string]types.FileContractID
+	var height types.BlockHeight
+	if len(tickets) > 0 {
+		hostContracts = r.hostContracts()
+		height = r.cs.Height()
+	}
+	goodForRenew = make(map[types.FileContractID]bool)
+	offline = make(map[types.FileContractID]bool)
+	for cid := range contractIDs {
+		resolvedID := r.hostContractor.ResolveID(cid)
+		if ft, ok := tickets[cid]; ok {
+			// Expired tickets are treated like missing contracts.
+			resolvedID = types.FileContractID{}
+			if ft.Ticket.Expiration > height {
+				resolvedID = hostContracts[ft.HostPublicKey.String()]
+			}
+		}
+		cu, ok := r.hostContractor.ContractUtility(resolvedID)
+		goodForRenew[cid] = ok && cu.GoodForRenew
+		offline[cid] = r.hostContractor.IsOffline(resolvedID)
+	}
+	return offline, goodForRenew
+}
+
+// dirInfo builds the DirectoryInfo of a directory, aggregating the metrics of
+// all files in the directory's subtree.
+func (r *Renter) dirInfo(d *siaDir, offline map[types.FileContractID]bool, goodForRenew map[types.FileContractID]bool) modules.DirectoryInfo {
+	di := modules.DirectoryInfo{
+		SiaPath:       d.siaPath,
+		NumFiles:      uint64(len(d.files)),
+		NumSubDirs:    uint64(len(d.subDirs)),
+		MinRedundancy: -1,
+	}
+	r.walkDir(d, func(sd *siaDir) {
+		for name := range sd.files {
+			f, exists := r.files[name]
+			if !exists {
+				continue
+			}
+			f.mu.RLock()
+			redundancy := f.redundancy(offline, goodForRenew)
+			health := f.health(offline, goodForRenew)
+			di.StuckChunks += f.numStuckChunks()
+			f.mu.RUnlock()
+			di.AggregateNumFiles++
+			di.AggregateSize += f.size
+			if health > di.Health {
+				di.Health = health
+			}
+			if redundancy != -1 && (di.MinRedundancy == -1 || redundancy < di.MinRedundancy) {
+				di.MinRedundancy = redundancy
+			}
+		}
+	})
+	return di
+}
+
+// CreateDir creates a new, empty directory. Missing parent directories are
+// created as well.
+func (r *Renter) CreateDir(siaPath string) error {
+	if err := validateDirSiapath(siaPath); err != nil {
+		return err
+	}
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	if _, exists := r.dirs[siaPath]; exists {
+		return ErrDirExists
+	}
+	if _, exists := r.files[siaPath]; exists {
+		return ErrPathOverload
+	}
+	return r.createDir(siaPath)
+}
+
+// DeleteDir removes a directory and everything it contains from the renter.
+func (r *Renter) DeleteDir(siaPath string) error {
+	if siaPath == "" {
+		return errRootDir
+	}
+	lockID := r.mu.Lock()
+	d, exists := r.dirs[siaPath]
+	if !exists {
+		r.mu.Unlock(lockID)
+		return ErrUnknownDir
+	}
+
+	// Remove every file and directory of the subtree from the renter.
+	var deleted []*file
+	var packed, dedup, ciphers, tickets bool
+	r.walkDir(d, func(sd *siaDir) {
+		for name := range sd.files {
+			f, exists := r.files[name]
+			if !exists {
+				continue
+			}
+			delete(r.files, name)
+			delete(r.persist.Tracking, name)
+			err := persist.RemoveFile(filepath.Join(r.persistDir, name+ShareExtension))
+			if err != nil {
+				r.log.Println("WARN: couldn't remove file :", err)
+			}
+			f.mu.Lock()
+			if f.packSlot != nil {
+				r.removeFromPack(f)
+				packed = true
+			}
+			if f.dedup {
+				r.removeDedupRefs(f)
+				dedup = true
+			}
+			f.mu.Unlock()
+			ciphers = ciphers || f.cipherType != crypto.TypeTwofish
+			tickets = tickets || f.shared()
+			deleted = append(deleted, f)
+		}
+		delete(r.dirs, sd.siaPath)
+		r.removeDirFromDisk(sd.siaPath)
+	})
+	delete(r.dirs[parentDir(siaPath)].subDirs, siaPath)
+	err := r.saveSync()
+	if err == nil && packed {
+		err = r.savePacks()
+	}
+	if err == nil && dedup {
+		err = r.saveDedup()
+	}
+	if err == nil && ciphers {
+		err = r.saveCiphers()
+	}
+	if err == nil && tickets {
+		err = r.saveTickets()
+	}
+	r.mu.Unlock(lockID)
+
+	// Mark the files as deleted.
+	for _, f := range deleted {
+		f.mu.Lock()
+		f.deleted = true
+		f.mu.Unlock()
+	}
+	return err
+}
+
+// DirList returns information about a directory and its subdirectories,
+// followed by information about the files contained in the directory. Only
+// the immediate children of the directory are listed. The first element of
+// the returned directories is the directory itself.
+func (r *Renter) DirList(siaPath string) ([]modules.DirectoryInfo, []modules.FileInfo, error) {
+	if siaPath != "" {
+		if err := validateDirSiapath(siaPath); err != nil {
+			return nil, nil, err
+		}
+	}
+	lockID := r.mu.RLock()
+	d, exists := r.dirs[siaPath]
+	if !exists {
+		r.mu.RUnlock(lockID)
+		return nil, nil, ErrUnknownDir
+	}
+
+	// Gather the contracts of every file in the subtree.
+	contractIDs := make(map[types.FileContractID]struct{})
+	tickets := make(map[types.FileContractID]fileTicket)
+	r.walkDir(d, func(sd *siaDir) {
+		for name := range sd.files {
+			f, exists := r.files[name]
+			if !exists {
+				continue
+			}
+			f.mu.RLock()
+			for cid := range f.contracts {
+				contractIDs[cid] = struct{}{}
+			}
+			for cid, ft := range f.tickets {
+				tickets[cid] = ft
+			}
+			f.mu.RUnlock()
+		}
+	})
+	r.mu.RUnlock(lockID)
+
+	// Look up the status of the contracts without holding the renter lock.
+	offline, goodForRenew := r.contractStatus(contractIDs, tickets)
+
+	// Build the directory infos. The directory may have been removed in the
+	// meantime.
+	lockID = r.mu.RLock()
+	defer r.mu.RUnlock(lockID)
+	d, exists = r.dirs[siaPath]
+	if !exists {
+		return nil, nil, ErrUnknownDir
+	}
+	dirs := []modules.DirectoryInfo{r.dirInfo(d, offline, goodForRenew)}
+	for subDir := range d.subDirs {
+		if sd, exists := r.dirs[subDir]; exists {
+			dirs = append(dirs, r.dirInfo(sd, offline, goodForRenew))
+		}
+	}
+	sort.Slice(dirs[1:], func(i, j int) bool { return dirs[i+1].SiaPath < dirs[j+1].SiaPath })
+
+	// Build the file infos.
+	var files []modules.FileInfo
+	for name := range d.files {
+		f, exists := r.files[name]
+		if !exists {
+			continue
+		}
+		f.mu.RLock()
+		files = append(files, r.fileInfo(f, offline, goodForRenew))
+		f.mu.RUnlock()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].SiaPath < files[j].SiaPath })
+	return dirs, files, nil
+}
+
+// RenameDir moves a directory and everything it contains to a new siapath.
+// The new siapath must not exist yet, missing parent directories are created.
+func (r *Renter) RenameDir(currentPath, newPath string) error {
+	if currentPath == "" {
+		return errRootDir
+	}
+	if err := validateDirSiapath(newPath); err != nil {
+		return err
+	}
+	if strings.HasPrefix(newPath+"/", currentPath+"/") {
+		return errRenameIntoSelf
+	}
+
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	d, exists := r.dirs[currentPath]
+	if !exists {
+		return ErrUnknownDir
+	}
+	if _, exists := r.dirs[newPath]; exists {
+		return ErrDirExists
+	}
+	if _, exists := r.files[newPath]; exists {
+		return ErrPathOverload
+	}
+	if err := r.createDir(parentDir(newPath)); err != nil {
+		return err
+	}
+
+	// Collect the subtree. The directories are moved parents first so that the
+	// new parent always exists when a directory is linked into the tree.
+	var oldDirs []*siaDir
+	r.walkDir(d, func(sd *siaDir) {
+		oldDirs = append([]*siaDir{sd}, oldDirs...)
+	})
+	rename := func(siaPath string) string {
+		return newPath + strings.TrimPrefix(siaPath, currentPath)
+	}
+
+	// Unlink the directory from its old parent and move every directory to
+	// its new location.
+	delete(r.dirs[parentDir(currentPath)].subDirs, currentPath)
+	for _, od := range oldDirs {
+		nd := newSiaDir(rename(od.siaPath))
+		for subDir := range od.subDirs {
+			nd.subDirs[rename(subDir)] = struct{}{}
+		}
+		delete(r.dirs, od.siaPath)
+		r.dirs[nd.siaPath] = nd
+		if err := r.saveDir(nd); err != nil {
+			return err
+		}
+	}
+	r.dirs[parentDir(newPath)].subDirs[newPath] = struct{}{}
+
+	// Move the files.
+	for _, od := range oldDirs {
+		for name := range od.files {
+			f, exists := r.files[name]
+			if !exists {
+				continue
+			}
+			newName := rename(name)
+			f.mu.Lock()
+			f.name = newName
+			err := r.saveFile(f)
+			f.mu.Unlock()
+			if err != nil {
+				return err
+			}
+			delete(r.files, name)
+			r.files[newName] = f
+			r.dirs[parentDir(newName)].files[newName] = struct{}{}
+			if t, ok := r.persist.Tracking[name]; ok {
+				delete(r.persist.Tracking, name)
+				r.persist.Tracking[newName] = t
+			}
+			r.managedRenameSpending(name, newName)
+			err = persist.RemoveFile(filepath.Join(r.persistDir, name+ShareExtension))
+			if err != nil {
+				r.log.Println("WARN: couldn't remove old .sia file:", err)
+			}
+		}
+	}
+
+	// Clean up the old directories on disk, children first.
+	for i := len(oldDirs) - 1; i >= 0; i-- {
+		r.removeDirFromDisk(oldDirs[i].siaPath)
+	}
+	if err := r.saveSync(); err != nil {
+		return err
+	}
+	if err := r.savePacks(); err != nil {
+		return err
+	}
+	if err := r.saveDedup(); err != nil {
+		return err
+	}
+	if err := r.saveCiphers(); err != nil {
+		return err
+	}
+	return r.saveTickets()
+}
@@ -0,0 +1,300 @@
+package renter
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// addTestingFile adds a testing file with the provided siapath to the renter
+// and links it into the directory tree. The file consists of 10 chunks.
+func (rt *renterTester) addTestingFile(siaPath string) (*file, error) {
+	f := newTestingFile()
+	f.name = siaPath
+	f.size = 100
+	f.pieceSize = 10
+	f.erasureCode, _ = NewRSCode(1, 1)
+	id := rt.renter.mu.Lock()
+	defer rt.renter.mu.Unlock(id)
+	rt.renter.files[siaPath] = f
+	if err := rt.renter.saveFile(f); err != nil {
+		return nil, err
+	}
+	return f, rt.renter.linkFile(f)
+}
+
+// TestParentDir probes the parentDir function.
+func TestParentDir(t *testing.T) {
+	tests := []struct {
+		siaPath string
+		parent  string
+	}{
+		{"foo", ""},
+		{"foo/bar", "foo"},
+		{"foo/bar/baz.txt", "foo/bar"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if parent := parentDir(test.siaPath); parent != test.parent {
+			t.Errorf("parentDir(%q): expected %q, got %q", test.siaPath, test.parent, parent)
+		}
+	}
+}
+
+// TestRenterCreateDir probes the CreateDir method of the renter.
+func TestRenterCreateDir(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create a nested directory. The parent should be created as well.
+	if err := rt.renter.CreateDir("foo/bar"); err != nil {
+		t.Fatal(err)
+	}
+	dirs, files, err := rt.renter.DirList("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 2 || len(files) != 0 {
+		t.Fatalf("expected 2 dirs and 0 files, got %v and %v", len(dirs), len(files))
+	}
+	if dirs[0].SiaPath != "" || dirs[0].NumSubDirs != 1 || dirs[1].SiaPath != "foo" {
+		t.Fatal("root directory listed incorrectly:", dirs)
+	}
+
+	// Creating the directories again should fail.
+	if err := rt.renter.CreateDir("foo"); err != ErrDirExists {
+		t.Error("expected ErrDirExists, got", err)
+	}
+	if err := rt.renter.CreateDir("foo/bar"); err != ErrDirExists {
+		t.Error("expected ErrDirExists, got", err)
+	}
+
+	// Creating a directory with the path of a file should fail.
+	if _, err := rt.addTestingFile("foo/file"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.CreateDir("foo/file"); err != ErrPathOverload {
+		t.Error("expected ErrPathOverload, got", err)
+	}
+	if err := rt.renter.CreateDir("foo/file/bar"); err != ErrPathOverload {
+		t.Error("expected ErrPathOverload, got", err)
+	}
+	if err := rt.renter.RenameFile("foo/file", "foo/file/file"); err != ErrPathOverload {
+		t.Error("expected ErrPathOverload, got", err)
+	}
+	if _, exists := rt.renter.files["foo/file"]; !exists {
+		t.Error("failed rename removed the file")
+	}
+
+	// Invalid siapaths should be rejected.
+	for _, siaPath := range []string{"", "/foo", "foo//bar", "foo/", "foo/../bar"} {
+		if err := rt.renter.CreateDir(siaPath); err == nil {
+			t.Errorf("CreateDir(%q) should have failed", siaPath)
+		}
+	}
+
+	// The directories should be loaded after a restart.
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, filepath.Join(rt.dir, modules.RenterDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirs, files, err = rt.renter.DirList("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 2 || dirs[1].SiaPath != "foo/bar" {
+		t.Fatal("subdirectory was not loaded:", dirs)
+	}
+	if len(files) != 1 || files[0].SiaPath != "foo/file" {
+		t.Fatal("file was not linked into its directory:", files)
+	}
+}
+
+// TestRenterDirList checks that DirList only lists the immediate children of a
+// directory while aggregating the metrics of the whole subtree.
+func TestRenterDirList(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	for _, siaPath := range []string{"root", "foo/a", "foo/b", "foo/bar/c"} {
+		if _, err := rt.addTestingFile(siaPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Unknown directories can't be listed.
+	if _, _, err := rt.renter.DirList("baz"); err != ErrUnknownDir {
+		t.Fatal("expected ErrUnknownDir, got", err)
+	}
+
+	dirs, files, err := rt.renter.DirList("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].SiaPath != "foo/a" || files[1].SiaPath != "foo/b" {
+		t.Fatal("wrong files listed:", files)
+	}
+	if len(dirs) != 2 {
+		t.Fatal("wrong number of dirs listed:", len(dirs))
+	}
+	if dirs[0].NumFiles != 2 || dirs[0].NumSubDirs != 1 || dirs[0].AggregateNumFiles != 3 || dirs[0].AggregateSize != 300 {
+		t.Error("wrong metrics for foo:", dirs[0])
+	}
+	if dirs[1].SiaPath != "foo/bar" || dirs[1].AggregateNumFiles != 1 || dirs[1].AggregateSize != 100 {
+		t.Error("wrong metrics for foo/bar:", dirs[1])
+	}
+
+	dirs, _, err = rt.renter.DirList("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirs[0].AggregateNumFiles != 4 || dirs[0].NumFiles != 1 {
+		t.Error("wrong metrics for the root directory:", dirs[0])
+	}
+}
+
+// TestRenterRenameDir probes the RenameDir method of the renter.
+func TestRenterRenameDir(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	for _, siaPath := range []string{"foo/a", "foo/bar/b"} {
+		if _, err := rt.addTestingFile(siaPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rt.renter.persist.Tracking["foo/a"] = trackedFile{RepairPath: "a"}
+	if err := rt.renter.CreateDir("baz"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid renames.
+	if err := rt.renter.RenameDir("", "qux"); err != errRootDir {
+		t.Error("expected errRootDir, got", err)
+	}
+	if err := rt.renter.RenameDir("qux", "quux"); err != ErrUnknownDir {
+		t.Error("expected ErrUnknownDir, got", err)
+	}
+	if err := rt.renter.RenameDir("foo", "baz"); err != ErrDirExists {
+		t.Error("expected ErrDirExists, got", err)
+	}
+	if err := rt.renter.RenameDir("foo", "foo/bar/qux"); err != errRenameIntoSelf {
+		t.Error("expected errRenameIntoSelf, got", err)
+	}
+
+	// Move foo into baz.
+	if err := rt.renter.RenameDir("foo", "baz/foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := rt.renter.DirList("foo"); err != ErrUnknownDir {
+		t.Error("old directory still exists:", err)
+	}
+	if _, exists := rt.renter.files["baz/foo/bar/b"]; !exists {
+		t.Error("file was not moved")
+	}
+	if _, exists := rt.renter.persist.Tracking["baz/foo/a"]; !exists {
+		t.Error("tracking set was not updated")
+	}
+
+	// The renamed tree should survive a restart.
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, filepath.Join(rt.dir, modules.RenterDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.renter.files) != 2 {
+		t.Fatal("expected 2 files after restart, got", len(rt.renter.files))
+	}
+	dirs, files, err := rt.renter.DirList("baz/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 2 || dirs[1].SiaPath != "baz/foo/bar" || len(files) != 1 || files[0].SiaPath != "baz/foo/a" {
+		t.Fatal("renamed directory was not loaded correctly:", dirs, files)
+	}
+	if _, _, err := rt.renter.DirList("foo"); err != ErrUnknownDir {
+		t.Error("old directory was loaded after restart:", err)
+	}
+}
+
+// TestRenterDeleteDir probes the DeleteDir method of the renter.
+func TestRenterDeleteDir(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	if err := rt.renter.DeleteDir(""); err != errRootDir {
+		t.Error("expected errRootDir, got", err)
+	}
+	if err := rt.renter.DeleteDir("foo"); err != ErrUnknownDir {
+		t.Error("expected ErrUnknownDir, got", err)
+	}
+
+	var deleted []*file
+	for _, siaPath := range []string{"foo/a", "foo/bar/b"} {
+		f, err := rt.addTestingFile(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deleted = append(deleted, f)
+	}
+	if _, err := rt.addTestingFile("keep"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.DeleteDir("foo"); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range deleted {
+		if !f.deleted {
+			t.Error("file was not marked as deleted:", f.name)
+		}
+	}
+	dirs, files, err := rt.renter.DirList("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 1 || len(files) != 1 || files[0].SiaPath != "keep" {
+		t.Fatal("directory was not deleted:", dirs, files)
+	}
+
+	// Nothing should be loaded after a restart.
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, filepath.Join(rt.dir, modules.RenterDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.renter.files) != 1 || len(rt.renter.dirs) != 1 {
+		t.Fatal("deleted directory was loaded after restart")
+	}
+}
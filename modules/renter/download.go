@@ -131,6 +131,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 	"github.com/NebulousLabs/Sia/types"
@@ -154,6 +155,8 @@ type (
 		endTime         time.Time // Set immediately before closing 'completeChan'.
 		staticStartTime time.Time // Set immediately when the download object is created.
 
+		staticID string // Used to identify the download when cancelling it.
+
 		// Basic information about the file.
 		destination           downloadDestination
 		destinationString     string // The string reported to the user to indicate the download's destination.
@@ -163,9 +166,10 @@ type (
 		staticSiaPath         string // The path of the siafile at the time the download started.
 
 		// Retrieval settings for the file.
-		staticLatencyTarget time.Duration // In milliseconds. Lower latency results in lower total system throughput.
-		staticOverdrive     int           // How many extra pieces to download to prevent slow hosts from being a bottleneck.
-		staticPriority      uint64        // Downloads with higher priority will complete first.
+		staticLatencyTarget time.Duration    // In milliseconds. Lower latency results in lower total system throughput.
+		staticOverdrive     int              // How many extra pieces to download to prevent slow hosts from being a bottleneck.
+		staticPriority      uint64           // Downloads with higher priority will complete first.
+		staticPriorityClass modules.Priority // The priority class of the download, used to request memory.
 
 		// Utilities.
 		log           *persist.Logger // Same log as the renter.
@@ -180,15 +184,43 @@ type (
 		destinationString string              // The string to report to the user for the destination.
 		file              *file               // The file to download.
 
-		latencyTarget time.Duration // Workers above this latency will be automatically put on standby initially.
-		length        uint64        // Length of download. Cannot be 0.
-		needsMemory   bool          // Whether new memory needs to be allocated to perform the download.
-		offset        uint64        // Offset within the file to start the download. Must be less than the total filesize.
-		overdrive     int           // How many extra pieces to download to prevent slow hosts from being a bottleneck.
-		priority      uint64        // Files with a higher priority will be downloaded first.
+		latencyTarget time.Duration    // Workers above this latency will be automatically put on standby initially.
+		length        uint64           // Length of download. Cannot be 0.
+		needsMemory   bool             // Whether new memory needs to be allocated to perform the download.
+		offset        uint64           // Offset within the file to start the download. Must be less than the total filesize.
+		overdrive     int              // How many extra pieces to download to prevent slow hosts from being a bottleneck.
+		priority      uint64           // Files with a higher priority will be downloaded first.
+		priorityClass modules.Priority // The priority class of the download.
+		streamCache   *streamCache     // The cache of recovered chunks. Defaults to the renter's stream cache.
 	}
 )
 
+var (
+	// errDownloadCancelled is the error of a download that was cancelled.
+	errDownloadCancelled = errors.New("download was cancelled")
+
+	// errDownloadComplete is returned when cancelling a download that has
+	// completed already.
+	errDownloadComplete = errors.New("download has already completed")
+
+	// errUnknownDownload is returned when cancelling a download that doesn't
+	// exist.
+	errUnknownDownload = errors.New("no download with that id")
+)
+
+// downloadPriority returns the priority in the download heap of a download
+// that was requested by the user with the priority class p. Streams are always
+// prioritized over these downloads, and repairs come last.
+func downloadPriority(p modules.Priority) uint64 {
+	switch p {
+	case modules.PriorityLow:
+		return 1
+	case modules.PriorityHigh:
+		return 10
+	}
+	return 5
+}
+
 // managedFail will mark the download as complete, but with the provided error.
 // If the download has already failed, the error will be updated to be a
 // concatenation of the previous error and the new error.
@@ -217,6 +249,25 @@ func (d *download) managedFail(err error) {
 	}
 }
 
+// managedCancel will mark the download as complete with errDownloadCancelled,
+// unless the download has completed already.
+func (d *download) managedCancel() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.staticComplete() {
+		return errDownloadComplete
+	}
+	d.err = errDownloadCancelled
+	close(d.completeChan)
+	if d.destination != nil {
+		if err := d.destination.Close(); err != nil {
+			d.log.Println("unable to close download destination:", err)
+		}
+		d.destination = nil
+	}
+	return nil
+}
+
 // staticComplete is a helper function to indicate whether or not the download
 // has completed.
 func (d *download) staticComplete() bool {
@@ -253,10 +304,32 @@ func (r *Renter) Download(p modules.RenterDownloadParameters) error {
 }
 
 // DownloadAsync performs a file download using the passed parameters without
-// blocking until the download is finished.
-func (r *Renter) DownloadAsync(p modules.RenterDownloadParameters) error {
-	_, err := r.managedDownload(p)
-	return err
+// blocking until the download is finished. The ID of the download is returned.
+func (r *Renter) DownloadAsync(p modules.RenterDownloadParameters) (string, error) {
+	d, err := r.managedDownload(p)
+	if err != nil {
+		return "", err
+	}
+	return d.staticID, nil
+}
+
+// CancelDownload cancels the download with the given ID. The download fails
+// with errDownloadCancelled, and its remaining chunks are skipped by the
+// download heap and dropped by the workers.
+func (r *Renter) CancelDownload(id string) error {
+	r.downloadHistoryMu.Lock()
+	var d *download
+	for _, hd := range r.downloadHistory {
+		if hd.staticID == id {
+			d = hd
+			break
+		}
+	}
+	r.downloadHistoryMu.Unlock()
+	if d == nil {
+		return errUnknownDownload
+	}
+	return d.managedCancel()
 }
 
 // managedDownload performs a file download using the passed parameters and
@@ -296,6 +369,9 @@ func (r *Renter) managedDownload(p modules.RenterDownloadParameters) (*download,
 	if p.Offset < 0 || p.Offset+p.Length > file.size {
 		return nil, fmt.Errorf("offset and length combination invalid, max byte is at index %d", file.size-1)
 	}
+	if p.Priority < modules.PriorityLow || p.Priority > modules.PriorityHigh {
+		return nil, modules.ErrUnknownPriority
+	}
 
 	// Instantiate the correct downloadWriter implementation.
 	var dw downloadDestination
@@ -324,7 +400,8 @@ func (r *Renter) managedDownload(p modules.RenterDownloadParameters) (*download,
 		needsMemory:   true,
 		offset:        p.Offset,
 		overdrive:     3, // TODO: moderate default until full overdrive support is added.
-		priority:      5, // TODO: moderate default until full priority support is added.
+		priority:      downloadPriority(p.Priority),
+		priorityClass: p.Priority,
 	})
 	if err != nil {
 		return nil, err
@@ -362,6 +439,8 @@ func (r *Renter) managedNewDownload(params downloadParams) (*download, error) {
 
 		staticStartTime: time.Now(),
 
+		staticID: persist.RandomSuffix(),
+
 		destination:           params.destination,
 		destinationString:     params.destinationString,
 		staticDestinationType: params.destinationType,
@@ -371,6 +450,7 @@ func (r *Renter) managedNewDownload(params downloadParams) (*download, error) {
 		staticOverdrive:       params.overdrive,
 		staticSiaPath:         params.file.name,
 		staticPriority:        params.priority,
+		staticPriorityClass:   params.priorityClass,
 
 		log:           r.log,
 		memoryManager: r.memoryManager,
@@ -387,8 +467,36 @@ func (r *Renter) managedNewDownload(params downloadParams) (*download, error) {
 		chunkMaps[i] = make(map[types.FileContractID]downloadPieceInfo)
 	}
 	params.file.mu.Lock()
+	var slot packSlot
+	if params.file.packSlot != nil {
+		slot = *params.file.packSlot
+	}
+	// Derive the keys of the pieces of each chunk.
+	pieceKeys := make([][]crypto.CipherKey, len(chunkMaps))
+	for i := range pieceKeys {
+		pieceKeys[i] = make([]crypto.CipherKey, params.file.erasureCode.NumPieces())
+		for j := range pieceKeys[i] {
+			pieceKeys[i][j] = params.file.pieceKey(minChunk+uint64(i), uint64(j))
+		}
+	}
+	// The pieces of files shared by other renters are downloaded through the
+	// renter's own contracts with the same hosts.
+	var hostContracts map[string]types.FileContractID
+	if params.file.shared() {
+		hostContracts = r.hostContracts()
+	}
 	for id, contract := range params.file.contracts {
 		resolvedID := r.hostContractor.ResolveID(id)
+		var ticket *modules.DownloadTicket
+		if params.file.shared() {
+			ft, exists := params.file.tickets[id]
+			ownID, haveContract := hostContracts[ft.HostPublicKey.String()]
+			if !exists || !haveContract {
+				continue
+			}
+			resolvedID = ownID
+			ticket = &ft.Ticket
+		}
 		for _, piece := range contract.Pieces {
 			if piece.Chunk >= minChunk && piece.Chunk <= maxChunk {
 				// Sanity check - the same worker should not have two pieces for
@@ -398,14 +506,22 @@ func (r *Renter) managedNewDownload(params downloadParams) (*download, error) {
 					r.log.Println("ERROR: Worker has multiple pieces uploaded for the same chunk.")
 				}
 				chunkMaps[piece.Chunk-minChunk][resolvedID] = downloadPieceInfo{
-					index: piece.Piece,
-					root:  piece.MerkleRoot,
+					index:  piece.Piece,
+					root:   piece.MerkleRoot,
+					offset: slot.Offset,
+					length: slot.Length,
+					ticket: ticket,
 				}
 			}
 		}
 	}
 	params.file.mu.Unlock()
 
+	streamCache := params.streamCache
+	if streamCache == nil {
+		streamCache = r.staticStreamCache
+	}
+
 	// Queue the downloads for each chunk.
 	writeOffset := int64(0) // where to write a chunk within the download destination.
 	d.chunksRemaining += maxChunk - minChunk + 1
@@ -413,10 +529,11 @@ func (r *Renter) managedNewDownload(params downloadParams) (*download, error) {
 		udc := &unfinishedDownloadChunk{
 			destination: params.destination,
 			erasureCode: params.file.erasureCode,
-			masterKey:   params.file.masterKey,
+			pieceKeys:   pieceKeys[i-minChunk],
 
 			staticChunkIndex: i,
 			staticCacheID:    fmt.Sprintf("%v:%v", d.staticSiaPath, i),
+			staticFileID:     chunkCacheFileID(params.file),
 			staticChunkMap:   chunkMaps[i-minChunk],
 			staticChunkSize:  params.file.staticChunkSize(),
 			staticPieceSize:  params.file.pieceSize,
@@ -438,7 +555,8 @@ func (r *Renter) managedNewDownload(params downloadParams) (*download, error) {
 			pieceUsage:        make([]bool, params.file.erasureCode.NumPieces()),
 
 			download:          d,
-			staticStreamCache: r.staticStreamCache,
+			staticChunkCache:  r.staticChunkCache,
+			staticStreamCache: streamCache,
 		}
 
 		// Set the fetchOffset - the offset within the chunk that we start
@@ -455,6 +573,21 @@ func (r *Renter) managedNewDownload(params downloadParams) (*download, error) {
 		} else {
 			udc.staticFetchLength = params.file.staticChunkSize() - udc.staticFetchOffset
 		}
+		// Erasure coders that support partial recovery only need the parts of
+		// the pieces that cover the fetched range, unless the whole chunk is
+		// needed for the stream cache.
+		pec, partial := params.file.erasureCode.(modules.PartialErasureCoder)
+		if partial && d.staticDestinationType != destinationTypeSeekStream {
+			pieceOffset, pieceLength := pec.PieceRange(udc.staticFetchOffset, udc.staticFetchLength)
+			if pieceOffset+pieceLength > params.file.pieceSize {
+				pieceLength = params.file.pieceSize - pieceOffset
+			}
+			if pieceLength < params.file.pieceSize {
+				udc.staticPieceOffset = pieceOffset
+				udc.staticPieceLength = pieceLength
+				udc.staticPieceSize = pieceLength
+			}
+		}
 		// Set the writeOffset within the destination for where the data should
 		// be written.
 		udc.staticWriteOffset = writeOffset
@@ -496,11 +629,13 @@ func (r *Renter) DownloadHistory() []modules.DownloadInfo {
 		d := r.downloadHistory[len(r.downloadHistory)-i-1]
 		d.mu.Lock() // Lock required for d.endTime only.
 		downloads[i] = modules.DownloadInfo{
+			ID:              d.staticID,
 			Destination:     d.destinationString,
 			DestinationType: d.staticDestinationType,
 			Length:          d.staticLength,
 			Offset:          d.staticOffset,
 			SiaPath:         d.staticSiaPath,
+			Priority:        d.staticPriorityClass,
 
 			Completed:            d.staticComplete(),
 			EndTime:              d.endTime,
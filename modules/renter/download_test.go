@@ -0,0 +1,126 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestCancelDownload checks that downloads can be cancelled by their ID.
+func TestCancelDownload(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Add a download that never makes progress to the download history.
+	d := &download{
+		completeChan:  make(chan struct{}),
+		staticID:      "foo",
+		destination:   NewDownloadDestinationBuffer(1, pieceSize),
+		log:           rt.renter.log,
+		memoryManager: rt.renter.memoryManager,
+	}
+	rt.renter.downloadHistoryMu.Lock()
+	rt.renter.downloadHistory = append(rt.renter.downloadHistory, d)
+	rt.renter.downloadHistoryMu.Unlock()
+
+	if err := rt.renter.CancelDownload("bar"); err != errUnknownDownload {
+		t.Fatal("expected errUnknownDownload, got", err)
+	}
+	if err := rt.renter.CancelDownload("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if !d.staticComplete() || d.Err() != errDownloadCancelled {
+		t.Fatal("download wasn't cancelled:", d.Err())
+	}
+	if dh := rt.renter.DownloadHistory(); len(dh) != 1 || dh[0].ID != "foo" || dh[0].Error != errDownloadCancelled.Error() {
+		t.Fatal("cancelled download isn't reported correctly:", dh)
+	}
+
+	// A download can't be cancelled twice.
+	if err := rt.renter.CancelDownload("foo"); err != errDownloadComplete {
+		t.Fatal("expected errDownloadComplete, got", err)
+	}
+
+	// Chunks that are served from a cache after the download was cancelled
+	// are ignored.
+	d.mu.Lock()
+	d.chunksRemaining = 1
+	d.mu.Unlock()
+	udc := &unfinishedDownloadChunk{
+		destination:       NewDownloadDestinationBuffer(1, pieceSize),
+		staticFetchLength: 1,
+		download:          d,
+	}
+	udc.writeCachedChunk([]byte{1})
+}
+
+// TestDownloadChunkWorkerPreferences checks that the fastest workers are
+// preferred for a download chunk and that the other workers are put on
+// standby until the preferred workers fail.
+func TestDownloadChunkWorkerPreferences(t *testing.T) {
+	rsc, _ := NewRSCode(1, 2)
+	slow, fast, unmeasured := types.FileContractID{1}, types.FileContractID{2}, types.FileContractID{3}
+	udc := &unfinishedDownloadChunk{
+		erasureCode: rsc,
+		staticChunkMap: map[types.FileContractID]downloadPieceInfo{
+			slow:       {index: 0},
+			fast:       {index: 1},
+			unmeasured: {index: 2},
+		},
+		staticPieceSize:  modules.SectorSize,
+		pieceUsage:       make([]bool, 3),
+		workersRemaining: 2,
+		download:         &download{completeChan: make(chan struct{})},
+	}
+
+	// Hosts that haven't been measured are always preferred.
+	hosts := map[types.FileContractID]modules.HostDBEntry{
+		slow:       {DownloadThroughput: 1e3},
+		fast:       {DownloadThroughput: 1e6, Latency: 10 * time.Millisecond},
+		unmeasured: {},
+	}
+	if preferred := udc.fastestWorkers(hosts); len(preferred) != 1 || !preferred[unmeasured] {
+		t.Fatal("unmeasured host wasn't preferred:", preferred)
+	}
+	hosts[unmeasured] = modules.HostDBEntry{DownloadThroughput: 1e6, Latency: time.Second}
+	if preferred := udc.fastestWorkers(hosts); len(preferred) != 1 || !preferred[fast] {
+		t.Fatal("fastest host wasn't preferred:", preferred)
+	}
+	delete(hosts, unmeasured)
+	delete(hosts, slow)
+	if preferred := udc.fastestWorkers(hosts); preferred != nil {
+		t.Fatal("workers were preferred although every worker is needed:", preferred)
+	}
+
+	// The slow worker should be put on standby until the fast worker fails.
+	udc.preferredWorkers = map[types.FileContractID]bool{fast: true}
+	udc.preferredPending = 1
+	slowWorker := &worker{contract: modules.RenterContract{ID: slow}, downloadChan: make(chan struct{}, 1)}
+	fastWorker := &worker{contract: modules.RenterContract{ID: fast}, downloadChan: make(chan struct{}, 1)}
+	if slowWorker.ownedProcessDownloadChunk(udc) != nil || len(udc.workersStandby) != 1 {
+		t.Fatal("slow worker wasn't put on standby")
+	}
+	udc.managedCleanUp()
+	if len(slowWorker.downloadChunks) != 0 {
+		t.Fatal("slow worker was taken off standby before the fast worker failed")
+	}
+	if fastWorker.ownedProcessDownloadChunk(udc) == nil {
+		t.Fatal("fast worker wasn't registered")
+	}
+	udc.managedUnregisterWorker(fastWorker)
+	udc.managedRemoveWorker(fastWorker)
+	if len(slowWorker.downloadChunks) != 1 || !udc.criteriaRelaxed {
+		t.Fatal("slow worker wasn't taken off standby")
+	}
+	if slowWorker.ownedProcessDownloadChunk(slowWorker.managedNextDownloadChunk()) == nil {
+		t.Fatal("slow worker wasn't registered after the fast worker failed")
+	}
+}
@@ -3,6 +3,7 @@ package renter
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,6 +21,15 @@ import (
 type downloadPieceInfo struct {
 	index uint64
 	root  crypto.Hash
+
+	// The part of the sector that contains the piece. length is zero if the
+	// piece fills the whole sector.
+	offset uint64
+	length uint64
+
+	// ticket is the download ticket that grants access to the piece if the
+	// piece is stored in another renter's contract.
+	ticket *modules.DownloadTicket
 }
 
 // unfinishedDownloadChunk contains a chunk for a download that is in progress.
@@ -39,15 +49,18 @@ type unfinishedDownloadChunk struct {
 	// Fetch + Write instructions - read only or otherwise thread safe.
 	destination downloadDestination // Where to write the recovered logical chunk.
 	erasureCode modules.ErasureCoder
-	masterKey   crypto.TwofishKey
+	pieceKeys   []crypto.CipherKey // Keys to decrypt each of the pieces.
 
 	// Fetch + Write instructions - read only or otherwise thread safe.
 	staticChunkIndex  uint64                                     // Required for deriving the encryption keys for each piece.
 	staticCacheID     string                                     // Used to uniquely identify a chunk in the chunk cache.
+	staticFileID      string                                     // Used to identify the chunk in the on-disk chunk cache.
 	staticChunkMap    map[types.FileContractID]downloadPieceInfo // Maps from file contract ids to the info for the piece associated with that contract
 	staticChunkSize   uint64
 	staticFetchLength uint64 // Length within the logical chunk to fetch.
 	staticFetchOffset uint64 // Offset within the logical chunk that is being downloaded.
+	staticPieceOffset uint64 // Offset within the pieces that is being downloaded.
+	staticPieceLength uint64 // Length within the pieces to fetch, zero if the whole pieces are fetched.
 	staticPieceSize   uint64
 	staticWriteOffset int64 // Offet within the writer to write the completed data.
 
@@ -67,6 +80,16 @@ type unfinishedDownloadChunk struct {
 	workersRemaining  int       // Number of workers still able to fetch the chunk.
 	workersStandby    []*worker // Set of workers that are able to work on this download, but are not needed unless other workers fail.
 
+	// Worker preferences - need mutex to access. The preferred workers are
+	// the workers that are expected to fetch their pieces fastest, mapped to
+	// whether they still have to process the chunk. Other workers are put on
+	// standby until the preferred workers prove insufficient, at which point
+	// the criteria are relaxed. If preferredWorkers is nil, every worker is
+	// preferred.
+	criteriaRelaxed  bool
+	preferredPending int
+	preferredWorkers map[types.FileContractID]bool
+
 	// Memory management variables.
 	memoryAllocated uint64
 
@@ -75,6 +98,7 @@ type unfinishedDownloadChunk struct {
 	mu       sync.Mutex
 
 	// Caching related fields
+	staticChunkCache  *chunkCache
 	staticStreamCache *streamCache
 }
 
@@ -112,12 +136,17 @@ func (udc *unfinishedDownloadChunk) managedCleanUp() {
 
 	// Check whether standby workers are required.
 	chunkComplete := udc.piecesCompleted >= udc.erasureCode.MinPieces()
+	// Preferred workers that haven't processed the chunk yet are expected to
+	// register their pieces.
 	desiredPiecesRegistered := udc.erasureCode.MinPieces() + udc.staticOverdrive - udc.piecesCompleted
-	standbyWorkersRequired := !chunkComplete && udc.piecesRegistered < desiredPiecesRegistered
+	standbyWorkersRequired := !chunkComplete && udc.piecesRegistered+udc.preferredPending < desiredPiecesRegistered
 	if !standbyWorkersRequired {
 		udc.mu.Unlock()
 		return
 	}
+	// The preferred workers are insufficient, so every worker is allowed to
+	// work on the chunk from now on.
+	udc.criteriaRelaxed = true
 
 	// Assemble a list of standby workers, release the udc lock, and then queue
 	// the chunk into the workers. The lock needs to be released early because
@@ -136,13 +165,23 @@ func (udc *unfinishedDownloadChunk) managedCleanUp() {
 
 // managedRemoveWorker will decrement a worker from the set of remaining workers
 // in the udc. After a worker has been removed, the udc needs to be cleaned up.
-func (udc *unfinishedDownloadChunk) managedRemoveWorker() {
+func (udc *unfinishedDownloadChunk) managedRemoveWorker(w *worker) {
 	udc.mu.Lock()
+	udc.markProcessed(w)
 	udc.workersRemaining--
 	udc.mu.Unlock()
 	udc.managedCleanUp()
 }
 
+// markProcessed marks that the worker has processed the chunk, so that it is
+// no longer expected to register a piece if it is a preferred worker.
+func (udc *unfinishedDownloadChunk) markProcessed(w *worker) {
+	if udc.preferredWorkers[w.contract.ID] {
+		udc.preferredWorkers[w.contract.ID] = false
+		udc.preferredPending--
+	}
+}
+
 // returnMemory will check on the status of all the workers and pieces, and
 // determine how much memory is safe to return to the renter. This should be
 // called each time a worker returns, and also after the chunk is recovered.
@@ -170,6 +209,36 @@ func (udc *unfinishedDownloadChunk) returnMemory() {
 	}
 }
 
+// writeCachedChunk writes the requested part of a cached chunk to the
+// destination of the download, and completes the download if it was the last
+// missing chunk. The caller must hold the lock of the chunk.
+func (udc *unfinishedDownloadChunk) writeCachedChunk(data []byte) {
+	// Nothing is written to a download that has completed or was cancelled
+	// already, since its destination has been closed.
+	if udc.download.staticComplete() {
+		return
+	}
+	start := udc.staticFetchOffset
+	end := start + udc.staticFetchLength
+	_, err := udc.destination.WriteAt(data[start:end], udc.staticWriteOffset)
+	if err != nil {
+		udc.fail(errors.AddContext(err, "failed to write cached chunk to destination"))
+		return
+	}
+
+	// Check if the download is complete now.
+	udc.download.mu.Lock()
+	defer udc.download.mu.Unlock()
+
+	udc.download.chunksRemaining--
+	if udc.download.chunksRemaining == 0 && !udc.download.staticComplete() {
+		udc.download.endTime = time.Now()
+		close(udc.download.completeChan)
+		udc.download.destination.Close()
+		udc.download.destination = nil
+	}
+}
+
 // threadedRecoverLogicalData will take all of the pieces that have been
 // downloaded and encode them into the logical data which is then written to the
 // underlying writer for the download.
@@ -180,15 +249,15 @@ func (udc *unfinishedDownloadChunk) threadedRecoverLogicalData() error {
 
 	// Decrypt the chunk pieces. This doesn't need to happen under a lock,
 	// because any thread potentially writing to the physicalChunkData array is
-	// going to be stopped by the fact that the chunk is complete.
+	// going to be stopped by the fact that the chunk is complete. Parts of
+	// pieces have been decrypted by the workers already.
 	for i := range udc.physicalChunkData {
 		// Skip empty pieces.
-		if udc.physicalChunkData[i] == nil {
+		if udc.physicalChunkData[i] == nil || udc.staticPieceLength > 0 {
 			continue
 		}
 
-		key := deriveKey(udc.masterKey, udc.staticChunkIndex, uint64(i))
-		decryptedPiece, err := key.DecryptBytes(udc.physicalChunkData[i])
+		decryptedPiece, err := udc.pieceKeys[i].DecryptBytes(udc.physicalChunkData[i])
 		if err != nil {
 			udc.mu.Lock()
 			udc.fail(err)
@@ -198,12 +267,26 @@ func (udc *unfinishedDownloadChunk) threadedRecoverLogicalData() error {
 		udc.physicalChunkData[i] = decryptedPiece
 	}
 
-	// Recover the pieces into the logical chunk data.
+	// Recover the pieces into the logical chunk data. Erasure coders that
+	// support partial recovery only need to decode the part of the chunk that
+	// was requested, unless the whole chunk is needed for the stream cache.
 	//
 	// TODO: Might be some way to recover into the downloadDestination instead
 	// of creating a buffer and then writing that.
 	recoverWriter := new(bytes.Buffer)
-	err := udc.erasureCode.Recover(udc.physicalChunkData, udc.staticChunkSize, recoverWriter)
+	start := udc.staticFetchOffset
+	var err error
+	pec, partial := udc.erasureCode.(modules.PartialErasureCoder)
+	partial = partial && udc.download.staticDestinationType != destinationTypeSeekStream
+	if partial && udc.staticPieceLength > 0 {
+		err = pec.RecoverRange(udc.physicalChunkData, udc.staticFetchOffset, udc.staticFetchLength, recoverWriter)
+		start = 0
+	} else if partial {
+		err = recoverRange(pec, udc.physicalChunkData, udc.staticFetchOffset, udc.staticFetchLength, recoverWriter)
+		start = 0
+	} else {
+		err = udc.erasureCode.Recover(udc.physicalChunkData, udc.staticChunkSize, recoverWriter)
+	}
 	if err != nil {
 		udc.mu.Lock()
 		udc.fail(err)
@@ -227,8 +310,7 @@ func (udc *unfinishedDownloadChunk) threadedRecoverLogicalData() error {
 	}
 
 	// Write the bytes to the requested output.
-	start := udc.staticFetchOffset
-	end := udc.staticFetchOffset + udc.staticFetchLength
+	end := start + udc.staticFetchLength
 	_, err = udc.destination.WriteAt(recoveredData[start:end], udc.staticWriteOffset)
 	if err != nil {
 		udc.mu.Lock()
@@ -236,6 +318,12 @@ func (udc *unfinishedDownloadChunk) threadedRecoverLogicalData() error {
 		udc.mu.Unlock()
 		return errors.AddContext(err, "unable to write to download destination")
 	}
+
+	// Full chunks are also added to the on-disk chunk cache once they have
+	// been delivered.
+	if !partial || udc.staticFetchLength == udc.staticChunkSize {
+		udc.staticChunkCache.AddAsync(udc.staticFileID, udc.staticChunkIndex, recoveredData, udc.download.log)
+	}
 	recoverWriter = nil
 
 	// Now that the download has completed and been flushed from memory, we can
@@ -251,9 +339,9 @@ func (udc *unfinishedDownloadChunk) threadedRecoverLogicalData() error {
 	defer udc.download.mu.Unlock()
 	udc.download.chunksRemaining--
 	atomic.AddUint64(&udc.download.atomicDataReceived, udc.staticFetchLength)
-	if udc.download.chunksRemaining == 0 {
+	if udc.download.chunksRemaining == 0 && !udc.download.staticComplete() {
 		// Download is complete, send out a notification and close the
-		// destination writer.
+		// destination writer. A cancelled download has been closed already.
 		udc.download.endTime = time.Now()
 		close(udc.download.completeChan)
 		err := udc.download.destination.Close()
@@ -262,3 +350,24 @@ func (udc *unfinishedDownloadChunk) threadedRecoverLogicalData() error {
 	}
 	return nil
 }
+
+// recoverRange recovers the length bytes at offset of a chunk from its full
+// pieces, only decoding the ranges of the pieces that pec requires.
+func recoverRange(pec modules.PartialErasureCoder, pieces [][]byte, offset, length uint64, w io.Writer) error {
+	pieceOffset, pieceLength := pec.PieceRange(offset, length)
+	pieceRanges := make([][]byte, len(pieces))
+	for i, piece := range pieces {
+		if piece == nil {
+			continue
+		}
+		if pieceOffset > uint64(len(piece)) {
+			return errors.New("piece is too short to contain the requested range")
+		}
+		end := pieceOffset + pieceLength
+		if end > uint64(len(piece)) {
+			end = uint64(len(piece))
+		}
+		pieceRanges[i] = piece[pieceOffset:end]
+	}
+	return pec.RecoverRange(pieceRanges, offset, length, w)
+}
@@ -39,10 +39,10 @@ type downloadDestination interface {
 // This buffer is primarily used when performing repairs on uploads.
 type downloadDestinationBuffer [][]byte
 
-// NewDownloadDestinationBuffer allocates the necessary number of shards for
-// the downloadDestinationBuffer and returns the new buffer.
-func NewDownloadDestinationBuffer(length uint64) downloadDestinationBuffer {
-	// Round length up to next multiple of SectorSize.
+// NewDownloadDestinationBuffer allocates the necessary number of shards of
+// size pieceSize for the downloadDestinationBuffer and returns the new buffer.
+func NewDownloadDestinationBuffer(length, pieceSize uint64) downloadDestinationBuffer {
+	// Round length up to next multiple of pieceSize.
 	if length%pieceSize != 0 {
 		length += pieceSize - length%pieceSize
 	}
@@ -64,24 +64,28 @@ func (dw downloadDestinationBuffer) ReadFrom(r io.Reader) (int64, error) {
 	var n int64
 	for len(dw) > 0 {
 		read, err := io.ReadFull(r, dw[0])
+		n += int64(read)
 		if err != nil {
 			return n, err
 		}
 		dw = dw[1:]
-		n += int64(read)
 	}
 	return n, nil
 }
 
 // WriteAt writes the provided data to the downloadDestinationBuffer.
 func (dw downloadDestinationBuffer) WriteAt(data []byte, offset int64) (int, error) {
-	if uint64(len(data))+uint64(offset) > uint64(len(dw))*pieceSize || offset < 0 {
+	var shardSize int64
+	if len(dw) > 0 {
+		shardSize = int64(len(dw[0]))
+	}
+	if int64(len(data))+offset > int64(len(dw))*shardSize || offset < 0 {
 		return 0, errors.New("write at specified offset exceeds buffer size")
 	}
 	written := len(data)
 	for len(data) > 0 {
-		shardIndex := offset / int64(pieceSize)
-		sliceIndex := offset % int64(pieceSize)
+		shardIndex := offset / shardSize
+		sliceIndex := offset % shardSize
 		n := copy(dw[shardIndex][sliceIndex:], data)
 		data = data[n:]
 		offset += int64(n)
@@ -71,7 +71,7 @@ func (r *Renter) managedAcquireMemoryForDownloadChunk(udc *unfinishedDownloadChu
 	// go over the memory limits when we decode pieces.
 	memoryRequired := uint64(udc.staticOverdrive+udc.erasureCode.MinPieces()) * udc.staticPieceSize
 	udc.memoryAllocated = memoryRequired
-	return r.memoryManager.Request(memoryRequired, memoryPriorityHigh)
+	return r.memoryManager.Request(memoryRequired, newMemoryPriority(udc.download.staticPriorityClass, memoryPriorityHigh))
 }
 
 // managedAddChunkToDownloadHeap will add a chunk to the download heap in a
@@ -120,10 +120,13 @@ func (r *Renter) managedBlockUntilOnline() bool {
 // all of the workers.
 func (r *Renter) managedDistributeDownloadChunkToWorkers(udc *unfinishedDownloadChunk) {
 	// Distribute the chunk to workers, marking the number of workers
-	// that have received the work.
+	// that have received the work and the workers that are preferred.
 	id := r.mu.Lock()
+	preferred := r.preferredDownloadWorkers(udc)
 	udc.mu.Lock()
 	udc.workersRemaining = len(r.workerPool)
+	udc.preferredWorkers = preferred
+	udc.preferredPending = len(preferred)
 	udc.mu.Unlock()
 	for _, worker := range r.workerPool {
 		worker.managedQueueDownloadChunk(udc)
@@ -196,7 +199,7 @@ LOOP:
 			}
 
 			// Check if we got the chunk cached already.
-			if r.staticStreamCache.Retrieve(nextChunk) {
+			if nextChunk.staticStreamCache.Retrieve(nextChunk) || r.staticChunkCache.Retrieve(nextChunk) {
 				continue
 			}
 
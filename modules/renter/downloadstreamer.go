@@ -5,18 +5,52 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+
 	"github.com/NebulousLabs/errors"
 )
 
 type (
-	// streamer is a io.ReadSeeker that can be used to stream downloads from
-	// the sia network.
+	// streamer is a modules.Streamer that can be used to stream downloads
+	// from the sia network. It downloads whole chunks, and fetches the chunks
+	// of its prefetch window in parallel to the chunk being read, so that
+	// sequential reads rarely have to wait for a download.
 	streamer struct {
-		file   *file
-		offset int64
-		r      *Renter
+		file *file
+		r    *Renter
+
+		staticCache    *streamCache
+		staticID       string
+		staticOpened   time.Time
+		staticPrefetch uint64
+		staticSiaPath  string
+
+		// chunks contains the chunks of the prefetch window that are being
+		// fetched or have been fetched, keyed by their index. Chunks outside
+		// of the window are dropped on the next Read.
+		chunks        map[uint64]*streamChunk
+		offset        int64
+		bytesRead     uint64
+		chunksFetched uint64
+		prefetchHits  uint64
+		mu            sync.Mutex
+	}
+
+	// streamChunk is a chunk that is fetched by a streamer. done is closed
+	// once the download of the chunk has finished, after which data or err is
+	// set. prefetched indicates whether the chunk was fetched before it was
+	// needed, read whether it has been read from.
+	streamChunk struct {
+		data       []byte
+		err        error
+		done       chan struct{}
+		prefetched bool
+		read       bool
 	}
 )
 
@@ -31,48 +65,131 @@ func min(values ...uint64) uint64 {
 	return min
 }
 
-// Streamer creates an io.ReadSeeker that can be used to stream downloads from
-// the sia network.
-func (r *Renter) Streamer(siaPath string) (string, io.ReadSeeker, error) {
+// Streamer creates a modules.Streamer that can be used to stream downloads
+// from the sia network. The streamer fetches up to prefetch chunks ahead of
+// the chunk being read, and is listed by Streams until it is closed.
+func (r *Renter) Streamer(siaPath string, prefetch uint64) (string, modules.Streamer, error) {
+	return r.managedStreamer(siaPath, prefetch, r.staticStreamCache)
+}
+
+// managedStreamer creates a streamer whose recovered chunks are kept in the
+// provided stream cache.
+func (r *Renter) managedStreamer(siaPath string, prefetch uint64, cache *streamCache) (string, modules.Streamer, error) {
+	if prefetch > maxStreamPrefetch {
+		return "", nil, fmt.Errorf("prefetch window can't be larger than %v chunks", maxStreamPrefetch)
+	}
+
 	// Lookup the file associated with the nickname.
-	lockID := r.mu.RLock()
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
 	file, exists := r.files[siaPath]
-	r.mu.RUnlock(lockID)
 	if !exists || file.deleted {
 		return "", nil, fmt.Errorf("no file with that path: %s", siaPath)
 	}
+
 	// Create the streamer
 	s := &streamer{
 		file: file,
 		r:    r,
+
+		staticCache:    cache,
+		staticID:       persist.RandomSuffix(),
+		staticOpened:   time.Now(),
+		staticPrefetch: prefetch,
+		staticSiaPath:  siaPath,
+
+		chunks: make(map[uint64]*streamChunk),
 	}
+	r.streams[s.staticID] = s
 	return file.name, s, nil
 }
 
-// Read implements the standard Read interface. It will download the requested
-// data from the sia network and block until the download is complete.  To
-// prevent http.ServeContent from requesting too much data at once, Read can
-// only request a single chunk at once.
-func (s *streamer) Read(p []byte) (n int, err error) {
-	// Get the file's size
+// Streams returns information about the open streams, oldest first.
+func (r *Renter) Streams() []modules.StreamInfo {
+	lockID := r.mu.RLock()
+	infos := make([]modules.StreamInfo, 0, len(r.streams))
+	for _, s := range r.streams {
+		infos = append(infos, s.managedInfo())
+	}
+	r.mu.RUnlock(lockID)
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Opened.Before(infos[j].Opened)
+	})
+	return infos
+}
+
+// managedInfo returns information about the stream.
+func (s *streamer) managedInfo() modules.StreamInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return modules.StreamInfo{
+		ID:       s.staticID,
+		SiaPath:  s.staticSiaPath,
+		Opened:   s.staticOpened,
+		Offset:   uint64(s.offset),
+		Prefetch: s.staticPrefetch,
+
+		BytesRead:     s.bytesRead,
+		ChunksFetched: s.chunksFetched,
+		PrefetchHits:  s.prefetchHits,
+	}
+}
+
+// managedFetchWindow starts fetching the chunk with the specified index along
+// with the chunks of its prefetch window, and drops the chunks outside of the
+// window. It returns the chunk with the specified index.
+func (s *streamer) managedFetchWindow(index uint64) *streamChunk {
 	s.file.mu.RLock()
-	fileSize := int64(s.file.size)
+	fileSize := s.file.size
+	numChunks := s.file.numChunks()
 	s.file.mu.RUnlock()
 
-	// Make sure we haven't reached the EOF yet.
-	if s.offset >= fileSize {
-		return 0, io.EOF
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.chunks {
+		if i < index || i > index+s.staticPrefetch {
+			delete(s.chunks, i)
+		}
 	}
+	for i := index; i <= index+s.staticPrefetch && i < numChunks; i++ {
+		if _, exists := s.chunks[i]; exists {
+			continue
+		}
+		chunk := &streamChunk{
+			done:       make(chan struct{}),
+			prefetched: i != index,
+		}
+		s.chunks[i] = chunk
+		go s.threadedFetchChunk(i, fileSize, chunk)
+	}
+
+	chunk := s.chunks[index]
+	if chunk.prefetched && !chunk.read {
+		s.prefetchHits++
+	}
+	chunk.read = true
+	return chunk
+}
+
+// threadedFetchChunk downloads the chunk with the specified index into
+// chunk.
+func (s *streamer) threadedFetchChunk(index, fileSize uint64, chunk *streamChunk) {
+	defer close(chunk.done)
+	if err := s.r.tg.Add(); err != nil {
+		chunk.err = err
+		return
+	}
+	defer s.r.tg.Done()
 
-	// Calculate how much we can download. We never download more than a single chunk.
+	// Calculate how much we have to download. The last chunk of the file
+	// might be shorter than the others.
 	chunkSize := s.file.staticChunkSize()
-	remainingData := uint64(fileSize - s.offset)
-	requestedData := uint64(len(p))
-	remainingChunk := chunkSize - uint64(s.offset)%chunkSize
-	length := min(remainingData, requestedData, remainingChunk)
+	offset := index * chunkSize
+	length := min(chunkSize, fileSize-offset)
 
 	// Download data
-	buffer := bytes.NewBuffer([]byte{})
+	buffer := bytes.NewBuffer(make([]byte, 0, length))
 	d, err := s.r.managedNewDownload(downloadParams{
 		destination:       newDownloadDestinationWriteCloserFromWriter(buffer),
 		destinationType:   destinationTypeSeekStream,
@@ -82,12 +199,15 @@ func (s *streamer) Read(p []byte) (n int, err error) {
 		latencyTarget: 50 * time.Millisecond, // TODO low default until full latency suport is added.
 		length:        length,
 		needsMemory:   true,
-		offset:        uint64(s.offset),
+		offset:        offset,
 		overdrive:     5,    // TODO: high default until full overdrive support is added.
 		priority:      1000, // TODO: high default until full priority support is added.
+		priorityClass: modules.PriorityHigh,
+		streamCache:   s.staticCache,
 	})
 	if err != nil {
-		return 0, errors.AddContext(err, "failed to create new download")
+		chunk.err = errors.AddContext(err, "failed to create new download")
+		return
 	}
 
 	// Set the in-memory buffer to nil just to be safe in case of a memory
@@ -100,18 +220,63 @@ func (s *streamer) Read(p []byte) (n int, err error) {
 	select {
 	case <-d.completeChan:
 		if d.Err() != nil {
-			return 0, errors.AddContext(d.Err(), "download failed")
+			chunk.err = errors.AddContext(d.Err(), "download failed")
+			return
 		}
 	case <-s.r.tg.StopChan():
-		return 0, errors.New("download interrupted by shutdown")
+		chunk.err = errors.New("download interrupted by shutdown")
+		return
+	}
+	chunk.data = buffer.Bytes()
+
+	s.mu.Lock()
+	s.chunksFetched++
+	s.mu.Unlock()
+}
+
+// Read implements the standard Read interface. It will download the requested
+// data from the sia network and block until the download is complete. Read
+// never returns data from more than a single chunk at once.
+func (s *streamer) Read(p []byte) (n int, err error) {
+	// Get the file's size
+	s.file.mu.RLock()
+	fileSize := int64(s.file.size)
+	s.file.mu.RUnlock()
+
+	// Make sure we haven't reached the EOF yet.
+	s.mu.Lock()
+	offset := s.offset
+	s.mu.Unlock()
+	if offset >= fileSize {
+		return 0, io.EOF
 	}
 
-	// Copy downloaded data into buffer.
-	copy(p, buffer.Bytes())
+	// Wait for the chunk containing the offset.
+	chunkSize := s.file.staticChunkSize()
+	index := uint64(offset) / chunkSize
+	chunk := s.managedFetchWindow(index)
+	select {
+	case <-chunk.done:
+	case <-s.r.tg.StopChan():
+		return 0, errors.New("download interrupted by shutdown")
+	}
+	if chunk.err != nil {
+		// Drop the chunk so that the next Read fetches it again.
+		s.mu.Lock()
+		if s.chunks[index] == chunk {
+			delete(s.chunks, index)
+		}
+		s.mu.Unlock()
+		return 0, chunk.err
+	}
 
-	// Adjust offset
-	s.offset += int64(length)
-	return int(length), nil
+	// Copy downloaded data into buffer and adjust offset.
+	n = copy(p, chunk.data[uint64(offset)-index*chunkSize:])
+	s.mu.Lock()
+	s.offset += int64(n)
+	s.bytesRead += uint64(n)
+	s.mu.Unlock()
+	return n, nil
 }
 
 // Seek sets the offset for the next Read to offset, interpreted
@@ -120,6 +285,9 @@ func (s *streamer) Read(p []byte) (n int, err error) {
 // to the end. Seek returns the new offset relative to the start of the file
 // and an error, if any.
 func (s *streamer) Seek(offset int64, whence int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	var newOffset int64
 	switch whence {
 	case io.SeekStart:
@@ -139,3 +307,16 @@ func (s *streamer) Seek(offset int64, whence int) (int64, error) {
 	s.offset = newOffset
 	return s.offset, nil
 }
+
+// Close removes the stream from the renter's open streams and drops its
+// fetched chunks.
+func (s *streamer) Close() error {
+	lockID := s.r.mu.Lock()
+	delete(s.r.streams, s.staticID)
+	s.r.mu.Unlock(lockID)
+
+	s.mu.Lock()
+	s.chunks = make(map[uint64]*streamChunk)
+	s.mu.Unlock()
+	return nil
+}
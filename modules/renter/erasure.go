@@ -1,14 +1,52 @@
 package renter
 
 import (
+	"errors"
 	"fmt"
 	"io"
 
 	"github.com/klauspost/reedsolomon"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 )
 
+var (
+	// ErrUnknownErasureCoder is returned when an erasure coder is requested
+	// that isn't registered with the renter.
+	ErrUnknownErasureCoder = errors.New("unknown erasure coder")
+
+	// erasureCoders contains the constructors of all erasure coders known to
+	// the renter, keyed by their identifier.
+	erasureCoders = map[types.Specifier]func(dataPieces, parityPieces int) (modules.ErasureCoder, error){
+		modules.ErasureCoderReedSolomon: NewRSCode,
+		modules.ErasureCoderReplication: NewReplicationCode,
+		modules.ErasureCoderPartialRS:   NewPartialRSCode,
+	}
+)
+
+// NewErasureCoder creates a new erasure coder of the type identified by id
+// using the supplied parameters.
+func NewErasureCoder(id types.Specifier, dataPieces, parityPieces int) (modules.ErasureCoder, error) {
+	newCoder, exists := erasureCoders[id]
+	if !exists {
+		return nil, ErrUnknownErasureCoder
+	}
+	return newCoder(dataPieces, parityPieces)
+}
+
+// NewDefaultErasureCoder creates a new erasure coder of the type identified by
+// id using the renter's default redundancy. Replication uses as many copies as
+// are needed to match the redundancy of the default Reed-Solomon parameters.
+func NewDefaultErasureCoder(id types.Specifier) (modules.ErasureCoder, error) {
+	if id == modules.ErasureCoderReplication {
+		copies := (defaultDataPieces + defaultParityPieces) / defaultDataPieces
+		return NewErasureCoder(id, 1, copies-1)
+	}
+	return NewErasureCoder(id, defaultDataPieces, defaultParityPieces)
+}
+
 // rsCode is a Reed-Solomon encoder/decoder. It implements the
 // modules.ErasureCoder interface.
 type rsCode struct {
@@ -18,6 +56,9 @@ type rsCode struct {
 	dataPieces int
 }
 
+// Identifier returns the specifier of the Reed-Solomon coder.
+func (rs *rsCode) Identifier() types.Specifier { return modules.ErasureCoderReedSolomon }
+
 // NumPieces returns the number of pieces returned by Encode.
 func (rs *rsCode) NumPieces() int { return rs.numPieces }
 
@@ -48,8 +89,9 @@ func (rs *rsCode) EncodeShards(pieces [][]byte) ([][]byte, error) {
 		return nil, fmt.Errorf("invalid number of pieces given %v %v", len(pieces), rs.MinPieces())
 	}
 	// Add the parity shards to pieces.
+	pieceLen := len(pieces[0])
 	for len(pieces) < rs.NumPieces() {
-		pieces = append(pieces, make([]byte, pieceSize))
+		pieces = append(pieces, make([]byte, pieceLen))
 	}
 	err := rs.enc.Encode(pieces)
 	if err != nil {
@@ -82,3 +124,226 @@ func NewRSCode(nData, nParity int) (modules.ErasureCoder, error) {
 		dataPieces: nData,
 	}, nil
 }
+
+// partialRSCode is a systematic Reed-Solomon encoder/decoder that stripes the
+// original data across the data pieces one segment at a time. Since
+// Reed-Solomon operates on every byte offset of the pieces independently, each
+// stripe of segments can be recovered on its own, which means that a byte
+// range of the original data only requires the matching segments of the
+// pieces. It implements the modules.PartialErasureCoder interface.
+type partialRSCode struct {
+	rsCode
+	segmentSize int
+}
+
+// Identifier returns the specifier of the partial Reed-Solomon coder.
+func (rs *partialRSCode) Identifier() types.Specifier { return modules.ErasureCoderPartialRS }
+
+// stripe copies data into the data pieces one segment at a time. The last
+// stripe is shorter than a full segment if the length of the pieces is not a
+// multiple of the segment size.
+func (rs *partialRSCode) stripe(data []byte, pieces [][]byte) {
+	pieceLen := len(pieces[0])
+	for off := 0; off < pieceLen; off += rs.segmentSize {
+		n := rs.segmentSize
+		if off+n > pieceLen {
+			n = pieceLen - off
+		}
+		for i := 0; i < rs.dataPieces; i++ {
+			data = data[copy(pieces[i][off:off+n], data):]
+		}
+	}
+}
+
+// Encode splits data into equal-length pieces, some containing the original
+// data and some containing parity data.
+func (rs *partialRSCode) Encode(data []byte) ([][]byte, error) {
+	if len(data) == 0 {
+		return nil, reedsolomon.ErrShortData
+	}
+	pieceLen := (len(data) + rs.dataPieces - 1) / rs.dataPieces
+	pieces := make([][]byte, rs.numPieces)
+	for i := range pieces {
+		pieces[i] = make([]byte, pieceLen)
+	}
+	rs.stripe(data, pieces)
+	if err := rs.enc.Encode(pieces); err != nil {
+		return nil, err
+	}
+	return pieces, nil
+}
+
+// EncodeShards restripes an already sharded input and creates the parity
+// shards for it.
+func (rs *partialRSCode) EncodeShards(pieces [][]byte) ([][]byte, error) {
+	// Check that the caller provided the minimum amount of pieces.
+	if len(pieces) != rs.MinPieces() {
+		return nil, fmt.Errorf("invalid number of pieces given %v %v", len(pieces), rs.MinPieces())
+	}
+	pieceLen := len(pieces[0])
+	data := make([]byte, 0, pieceLen*len(pieces))
+	for _, piece := range pieces {
+		if len(piece) != pieceLen {
+			return nil, reedsolomon.ErrShardSize
+		}
+		data = append(data, piece...)
+	}
+	rs.stripe(data, pieces)
+	// Add the parity shards to pieces.
+	for len(pieces) < rs.NumPieces() {
+		pieces = append(pieces, make([]byte, pieceLen))
+	}
+	if err := rs.enc.Encode(pieces); err != nil {
+		return nil, err
+	}
+	return pieces, nil
+}
+
+// PieceRange returns the range of every piece that is required to recover the
+// length bytes at offset of the original data.
+func (rs *partialRSCode) PieceRange(offset, length uint64) (pieceOffset, pieceLength uint64) {
+	stripeSize := uint64(rs.segmentSize * rs.dataPieces)
+	startStripe := offset / stripeSize
+	endStripe := (offset + length + stripeSize - 1) / stripeSize
+	return startStripe * uint64(rs.segmentSize), (endStripe - startStripe) * uint64(rs.segmentSize)
+}
+
+// RecoverRange recovers the length bytes at offset of the original data from
+// the piece ranges returned by PieceRange and writes them to w. Only the
+// stripes covering the range are reconstructed.
+func (rs *partialRSCode) RecoverRange(pieces [][]byte, offset, length uint64, w io.Writer) error {
+	if err := rs.enc.ReconstructData(pieces); err != nil {
+		return err
+	}
+	pieceOffset, _ := rs.PieceRange(offset, length)
+	skip := offset - pieceOffset*uint64(rs.dataPieces)
+	pieceLen := len(pieces[0])
+	for off := 0; off < pieceLen && length > 0; off += rs.segmentSize {
+		n := rs.segmentSize
+		if off+n > pieceLen {
+			n = pieceLen - off
+		}
+		for i := 0; i < rs.dataPieces && length > 0; i++ {
+			segment := pieces[i][off : off+n]
+			if skip >= uint64(len(segment)) {
+				skip -= uint64(len(segment))
+				continue
+			}
+			segment = segment[skip:]
+			skip = 0
+			if uint64(len(segment)) > length {
+				segment = segment[:length]
+			}
+			if _, err := w.Write(segment); err != nil {
+				return err
+			}
+			length -= uint64(len(segment))
+		}
+	}
+	if length > 0 {
+		return reedsolomon.ErrShortData
+	}
+	return nil
+}
+
+// Recover recovers the original data from pieces and writes it to w.
+// pieces should be identical to the slice returned by Encode (length and
+// order must be preserved), but with missing elements set to nil.
+func (rs *partialRSCode) Recover(pieces [][]byte, n uint64, w io.Writer) error {
+	return rs.RecoverRange(pieces, 0, n, w)
+}
+
+// NewPartialRSCode creates a new partial Reed-Solomon encoder/decoder using the
+// supplied parameters. The data is striped across the pieces in segments of
+// crypto.SegmentSize bytes.
+func NewPartialRSCode(nData, nParity int) (modules.ErasureCoder, error) {
+	enc, err := reedsolomon.New(nData, nParity)
+	if err != nil {
+		return nil, err
+	}
+	return &partialRSCode{
+		rsCode: rsCode{
+			enc:        enc,
+			numPieces:  nData + nParity,
+			dataPieces: nData,
+		},
+		segmentSize: crypto.SegmentSize,
+	}, nil
+}
+
+// replicationCode is an erasure coder that stores a full copy of the original
+// data in every piece. It trades storage efficiency for the ability to
+// recover the data from any single piece. It implements the
+// modules.PartialErasureCoder interface.
+type replicationCode struct {
+	numPieces int
+}
+
+// Identifier returns the specifier of the replication coder.
+func (rc *replicationCode) Identifier() types.Specifier { return modules.ErasureCoderReplication }
+
+// NumPieces returns the number of copies returned by Encode.
+func (rc *replicationCode) NumPieces() int { return rc.numPieces }
+
+// MinPieces returns 1, since every piece contains the original data.
+func (rc *replicationCode) MinPieces() int { return 1 }
+
+// Encode returns NumPieces copies of data.
+func (rc *replicationCode) Encode(data []byte) ([][]byte, error) {
+	if len(data) == 0 {
+		return nil, reedsolomon.ErrShortData
+	}
+	return rc.EncodeShards([][]byte{data})
+}
+
+// EncodeShards appends copies of the single data piece until there are
+// NumPieces pieces.
+func (rc *replicationCode) EncodeShards(pieces [][]byte) ([][]byte, error) {
+	if len(pieces) != rc.MinPieces() {
+		return nil, fmt.Errorf("invalid number of pieces given %v %v", len(pieces), rc.MinPieces())
+	}
+	for len(pieces) < rc.NumPieces() {
+		pieces = append(pieces, append([]byte(nil), pieces[0]...))
+	}
+	return pieces, nil
+}
+
+// PieceRange returns the requested range, since every piece contains the
+// original data.
+func (rc *replicationCode) PieceRange(offset, length uint64) (pieceOffset, pieceLength uint64) {
+	return offset, length
+}
+
+// RecoverRange writes length bytes of the first available piece range to w.
+func (rc *replicationCode) RecoverRange(pieces [][]byte, offset, length uint64, w io.Writer) error {
+	for _, piece := range pieces {
+		if piece == nil {
+			continue
+		}
+		if uint64(len(piece)) < length {
+			return reedsolomon.ErrShortData
+		}
+		_, err := w.Write(piece[:length])
+		return err
+	}
+	return reedsolomon.ErrTooFewShards
+}
+
+// Recover writes the first n bytes of the first available piece to w.
+func (rc *replicationCode) Recover(pieces [][]byte, n uint64, w io.Writer) error {
+	return rc.RecoverRange(pieces, 0, n, w)
+}
+
+// NewReplicationCode creates a new replication coder that stores
+// nData+nParity copies of the data. nData must be 1, since every piece
+// contains the full data.
+func NewReplicationCode(nData, nParity int) (modules.ErasureCoder, error) {
+	if nData != 1 {
+		return nil, errors.New("replication requires exactly one data piece")
+	} else if nParity < 0 {
+		return nil, errors.New("number of parity pieces must not be negative")
+	}
+	return &replicationCode{
+		numPieces: nData + nParity,
+	}, nil
+}
@@ -5,6 +5,9 @@ import (
 	"io/ioutil"
 	"testing"
 
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
 	"github.com/NebulousLabs/fastrand"
 )
 
@@ -57,6 +60,168 @@ func TestRSEncode(t *testing.T) {
 	}
 }
 
+// TestNewErasureCoder probes the erasure coder registry.
+func TestNewErasureCoder(t *testing.T) {
+	for _, id := range []types.Specifier{modules.ErasureCoderReedSolomon, modules.ErasureCoderReplication, modules.ErasureCoderPartialRS} {
+		ec, err := NewDefaultErasureCoder(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ec.Identifier() != id {
+			t.Errorf("expected identifier %v, got %v", id, ec.Identifier())
+		}
+	}
+	if _, err := NewErasureCoder(types.Specifier{'f', 'o', 'o'}, 1, 1); err != ErrUnknownErasureCoder {
+		t.Error("expected ErrUnknownErasureCoder, got", err)
+	}
+
+	// The default replication should match the redundancy of the default
+	// Reed-Solomon parameters.
+	ec, _ := NewDefaultErasureCoder(modules.ErasureCoderReplication)
+	if ec.MinPieces() != 1 || ec.NumPieces() != (defaultDataPieces+defaultParityPieces)/defaultDataPieces {
+		t.Errorf("unexpected default replication: %v of %v", ec.MinPieces(), ec.NumPieces())
+	}
+}
+
+// TestReplicationEncode tests the replicationCode type.
+func TestReplicationEncode(t *testing.T) {
+	if _, err := NewReplicationCode(2, 1); err == nil {
+		t.Error("expected error for multiple data pieces")
+	}
+	if _, err := NewReplicationCode(1, -1); err == nil {
+		t.Error("expected error for negative parity pieces")
+	}
+
+	rc, err := NewReplicationCode(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := fastrand.Bytes(777)
+	pieces, err := rc.Encode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pieces) != 3 {
+		t.Fatal("expected 3 pieces, got", len(pieces))
+	}
+	for _, piece := range pieces {
+		if !bytes.Equal(piece, data) {
+			t.Fatal("piece is not a copy of the data")
+		}
+	}
+
+	// Any single piece should be enough to recover the data.
+	pieces[0], pieces[1] = nil, nil
+	buf := new(bytes.Buffer)
+	if err := rc.Recover(pieces, 777, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("recovered data does not match original")
+	}
+	pieces[2] = nil
+	if err := rc.Recover(pieces, 777, buf); err == nil {
+		t.Fatal("expected error when recovering without pieces")
+	}
+}
+
+// TestPartialRSEncode tests the partialRSCode type, checking that arbitrary
+// ranges of the data can be recovered from the matching ranges of the pieces.
+func TestPartialRSEncode(t *testing.T) {
+	if _, err := NewPartialRSCode(0, 1); err == nil {
+		t.Error("expected bad parameter error, got nil")
+	}
+
+	rsc, err := NewPartialRSCode(3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pec := rsc.(modules.PartialErasureCoder)
+
+	// Use a piece length that isn't a multiple of the segment size so that
+	// the last stripe is short.
+	const pieceLen = 64*5 + 17
+	data := fastrand.Bytes(3 * pieceLen)
+	shards := make([][]byte, 3)
+	for i := range shards {
+		shards[i] = append([]byte(nil), data[i*pieceLen:(i+1)*pieceLen]...)
+	}
+	pieces, err := rsc.EncodeShards(shards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pieces) != 5 {
+		t.Fatal("expected 5 pieces, got", len(pieces))
+	}
+
+	// The first segment of every data piece should contain the beginning of
+	// the data.
+	if !bytes.Equal(pieces[1][:64], data[64:128]) {
+		t.Fatal("data was not striped across the pieces")
+	}
+
+	// Encode should produce the same pieces.
+	encoded, err := rsc.Encode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range pieces {
+		if !bytes.Equal(pieces[i], encoded[i]) {
+			t.Fatal("Encode and EncodeShards produced different pieces")
+		}
+	}
+
+	// Recover the whole chunk with missing pieces.
+	pieces[0], pieces[2] = nil, nil
+	buf := new(bytes.Buffer)
+	if err := rsc.Recover(pieces, uint64(len(data)), buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("recovered data does not match original")
+	}
+
+	// Recover a variety of ranges, each time only providing the required
+	// ranges of the pieces.
+	ranges := []struct {
+		offset, length uint64
+	}{
+		{0, 1},
+		{0, 192},
+		{10, 500},
+		{191, 2},
+		{300, uint64(len(data)) - 300},
+		{uint64(len(data)) - 1, 1},
+		{960, 51},
+	}
+	for _, r := range ranges {
+		pieceOffset, pieceLength := pec.PieceRange(r.offset, r.length)
+		if pieceOffset%64 != 0 {
+			t.Fatal("piece range is not segment aligned:", pieceOffset)
+		}
+		pieceRanges := make([][]byte, len(encoded))
+		for i := 1; i < len(encoded)-1; i++ {
+			end := pieceOffset + pieceLength
+			if end > pieceLen {
+				end = pieceLen
+			}
+			pieceRanges[i] = append([]byte(nil), encoded[i][pieceOffset:end]...)
+		}
+		buf.Reset()
+		if err := pec.RecoverRange(pieceRanges, r.offset, r.length, buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), data[r.offset:r.offset+r.length]) {
+			t.Fatalf("range %v-%v was not recovered correctly", r.offset, r.offset+r.length)
+		}
+	}
+
+	// Too few pieces should fail.
+	if err := rsc.Recover([][]byte{encoded[0], nil, nil, nil, encoded[4]}, 10, buf); err == nil {
+		t.Fatal("expected error when recovering with too few pieces")
+	}
+}
+
 func BenchmarkRSEncode(b *testing.B) {
 	rsc, err := NewRSCode(80, 20)
 	if err != nil {
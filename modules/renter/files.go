@@ -26,19 +26,47 @@ var (
 
 // A file is a single file that has been uploaded to the network. Files are
 // split into equal-length chunks, which are then erasure-coded into pieces.
-// Each piece is separately encrypted, using a key derived from the file's
-// master key. The pieces are uploaded to hosts in groups, such that one file
+// Each piece is separately encrypted with the file's cipher, using a key
+// derived from the file's master key. The pieces are uploaded to hosts in groups, such that one file
 // contract covers many pieces.
 type file struct {
 	name        string
 	size        uint64 // Static - can be accessed without lock.
 	contracts   map[types.FileContractID]fileContract
 	masterKey   crypto.TwofishKey    // Static - can be accessed without lock.
+	cipherType  crypto.CipherType    // Static - can be accessed without lock.
 	erasureCode modules.ErasureCoder // Static - can be accessed without lock.
 	pieceSize   uint64               // Static - can be accessed without lock.
 	mode        uint32               // actually an os.FileMode
 	deleted     bool                 // indicates if the file has been deleted.
 
+	// stuckChunks contains the indices of the chunks that the repair loop
+	// failed to repair. It is persisted in the renter's health file.
+	stuckChunks map[uint64]struct{}
+
+	// packSlot is the location of a small file within its pack. It is nil if
+	// the file is not packed or hasn't been packed yet. It is persisted in the
+	// renter's packs file.
+	packSlot *packSlot
+
+	// dedup indicates that the chunks of the file are deduplicated with
+	// identical chunks of other files, it can be accessed without lock.
+	// chunkKeys contains the convergent keys of the chunks that have been
+	// uploaded. Both are persisted in the renter's dedup file.
+	dedup     bool
+	chunkKeys map[uint64]crypto.Hash
+
+	// copyOf is the file that f replaces once f has been fully uploaded. It
+	// is set on the copies of files that are being re-encrypted. Copies are
+	// not saved to disk until they replace the original.
+	copyOf *file
+
+	// tickets contains the download tickets of the contracts of a file that
+	// was shared by another renter, indexed by the ids of the contracts. It
+	// is nil for the renter's own files and is persisted in the renter's
+	// tickets file.
+	tickets map[types.FileContractID]fileTicket
+
 	staticUID string // A UID assigned to the file when it gets created.
 
 	mu sync.RWMutex
@@ -65,9 +93,14 @@ type pieceData struct {
 	MerkleRoot crypto.Hash // the Merkle root of the piece
 }
 
-// deriveKey derives the key used to encrypt and decrypt a specific file piece.
-func deriveKey(masterKey crypto.TwofishKey, chunkIndex, pieceIndex uint64) crypto.TwofishKey {
-	return crypto.TwofishKey(crypto.HashAll(masterKey, chunkIndex, pieceIndex))
+// deriveKey derives the key used to encrypt and decrypt a specific file piece
+// with the cipher ct.
+func deriveKey(ct crypto.CipherType, masterKey crypto.TwofishKey, chunkIndex, pieceIndex uint64) crypto.CipherKey {
+	key, err := crypto.NewCipherKey(ct, crypto.HashAll(masterKey, chunkIndex, pieceIndex))
+	if err != nil {
+		build.Critical("unable to derive a key for cipher", ct, err)
+	}
+	return key
 }
 
 // staticChunkSize returns the size of one chunk.
@@ -114,10 +147,11 @@ func (f *file) available(offline map[types.FileContractID]bool) bool {
 func (f *file) uploadedBytes() uint64 {
 	var uploaded uint64
 	for _, fc := range f.contracts {
-		// Note: we need to multiply by SectorSize here instead of
+		// Note: we need to multiply by the slot length here instead of
 		// f.pieceSize because the actual bytes uploaded include overhead
-		// from TwoFish encryption
-		uploaded += uint64(len(fc.Pieces)) * modules.SectorSize
+		// from TwoFish encryption, and every piece of a file that isn't
+		// packed fills a whole sector.
+		uploaded += uint64(len(fc.Pieces)) * f.slotLength()
 	}
 	return uploaded
 }
@@ -127,7 +161,7 @@ func (f *file) uploadedBytes() uint64 {
 // reaches 100%, and UploadProgress may report a value greater than 100%.
 func (f *file) uploadProgress() float64 {
 	uploaded := f.uploadedBytes()
-	desired := modules.SectorSize * uint64(f.erasureCode.NumPieces()) * f.numChunks()
+	desired := f.slotLength() * uint64(f.erasureCode.NumPieces()) * f.numChunks()
 
 	return math.Min(100*(float64(uploaded)/float64(desired)), 100)
 }
@@ -206,6 +240,12 @@ func (f *file) expiration() types.BlockHeight {
 		return 0
 	}
 	lowest := ^types.BlockHeight(0)
+	// Shared files can't be downloaded once their tickets expire.
+	for _, ft := range f.tickets {
+		if ft.Ticket.Expiration < lowest {
+			lowest = ft.Ticket.Expiration
+		}
+	}
 	for _, fc := range f.contracts {
 		if fc.WindowStart < lowest {
 			lowest = fc.WindowStart
@@ -214,13 +254,14 @@ func (f *file) expiration() types.BlockHeight {
 	return lowest
 }
 
-// newFile creates a new file object.
+// newFile creates a new file object that is encrypted with Twofish.
 func newFile(name string, code modules.ErasureCoder, pieceSize, fileSize uint64) *file {
 	return &file{
 		name:        name,
 		size:        fileSize,
 		contracts:   make(map[types.FileContractID]fileContract),
 		masterKey:   crypto.GenerateTwofishKey(),
+		cipherType:  crypto.TypeTwofish,
 		erasureCode: code,
 		pieceSize:   pieceSize,
 
@@ -242,6 +283,32 @@ func (r *Renter) DeleteFile(nickname string) error {
 	}
 	delete(r.files, nickname)
 	delete(r.persist.Tracking, nickname)
+	r.unlinkFile(nickname)
+	r.staticChunkCache.RemoveFile(chunkCacheFileID(f))
+	f.mu.Lock()
+	if f.packSlot != nil {
+		r.removeFromPack(f)
+		if err := r.savePacks(); err != nil {
+			r.log.Println("WARN: couldn't save packs:", err)
+		}
+	}
+	if f.dedup {
+		r.removeDedupRefs(f)
+		if err := r.saveDedup(); err != nil {
+			r.log.Println("WARN: couldn't save dedup file:", err)
+		}
+	}
+	f.mu.Unlock()
+	if f.cipherType != crypto.TypeTwofish {
+		if err := r.saveCiphers(); err != nil {
+			r.log.Println("WARN: couldn't save ciphers file:", err)
+		}
+	}
+	if f.shared() {
+		if err := r.saveTickets(); err != nil {
+			r.log.Println("WARN: couldn't save tickets file:", err)
+		}
+	}
 
 	err := persist.RemoveFile(filepath.Join(r.persistDir, f.name+ShareExtension))
 	if err != nil {
@@ -263,11 +330,48 @@ func (r *Renter) DeleteFile(nickname string) error {
 	return nil
 }
 
+// fileInfo builds the FileInfo of a file. The caller must hold a read lock on
+// both the renter and the file.
+func (r *Renter) fileInfo(f *file, offline map[types.FileContractID]bool, goodForRenew map[types.FileContractID]bool) modules.FileInfo {
+	renewing := true
+	var localPath, uploadID string
+	tf, exists := r.persist.Tracking[f.name]
+	if exists {
+		localPath = tf.RepairPath
+		uploadID = f.staticUID
+	}
+	job, reencrypting := r.reencryptions[f]
+	reencrypting = reencrypting && job.err == nil
+	return modules.FileInfo{
+		SiaPath:        f.name,
+		LocalPath:      localPath,
+		Filesize:       f.size,
+		Renewing:       renewing,
+		Available:      f.available(offline),
+		Redundancy:     f.redundancy(offline, goodForRenew),
+		ErasureCoder:   f.erasureCode.Identifier(),
+		Packed:         f.packSlot != nil,
+		Deduplicated:   f.dedup,
+		Health:         f.health(offline, goodForRenew),
+		StuckChunks:    f.numStuckChunks(),
+		UploadedBytes:  f.uploadedBytes(),
+		UploadProgress: f.uploadProgress(),
+		Expiration:     f.expiration(),
+		UploadID:       uploadID,
+		Priority:       tf.Priority,
+		UploadPaused:   tf.Paused,
+		CipherType:     f.cipherType,
+		Reencrypting:   reencrypting,
+		Shared:         f.shared(),
+	}
+}
+
 // FileList returns all of the files that the renter has.
 func (r *Renter) FileList() []modules.FileInfo {
 	// Get all the files and their contracts
 	var files []*file
 	contractIDs := make(map[types.FileContractID]struct{})
+	tickets := make(map[types.FileContractID]fileTicket)
 	lockID := r.mu.RLock()
 	for _, f := range r.files {
 		files = append(files, f)
@@ -275,43 +379,23 @@ func (r *Renter) FileList() []modules.FileInfo {
 		for cid := range f.contracts {
 			contractIDs[cid] = struct{}{}
 		}
+		for cid, ft := range f.tickets {
+			tickets[cid] = ft
+		}
 		f.mu.RUnlock()
 	}
 	r.mu.RUnlock(lockID)
 
 	// Build 2 maps that map every contract id to its offline and goodForRenew
 	// status.
-	goodForRenew := make(map[types.FileContractID]bool)
-	offline := make(map[types.FileContractID]bool)
-	for cid := range contractIDs {
-		resolvedID := r.hostContractor.ResolveID(cid)
-		cu, ok := r.hostContractor.ContractUtility(resolvedID)
-		goodForRenew[cid] = ok && cu.GoodForRenew
-		offline[cid] = r.hostContractor.IsOffline(resolvedID)
-	}
+	offline, goodForRenew := r.contractStatus(contractIDs, tickets)
 
 	// Build the list of FileInfos.
 	var fileList []modules.FileInfo
 	for _, f := range files {
 		lockID := r.mu.RLock()
 		f.mu.RLock()
-		renewing := true
-		var localPath string
-		tf, exists := r.persist.Tracking[f.name]
-		if exists {
-			localPath = tf.RepairPath
-		}
-		fileList = append(fileList, modules.FileInfo{
-			SiaPath:        f.name,
-			LocalPath:      localPath,
-			Filesize:       f.size,
-			Renewing:       renewing,
-			Available:      f.available(offline),
-			Redundancy:     f.redundancy(offline, goodForRenew),
-			UploadedBytes:  f.uploadedBytes(),
-			UploadProgress: f.uploadProgress(),
-			Expiration:     f.expiration(),
-		})
+		fileList = append(fileList, r.fileInfo(f, offline, goodForRenew))
 		f.mu.RUnlock()
 		r.mu.RUnlock(lockID)
 	}
@@ -321,15 +405,13 @@ func (r *Renter) FileList() []modules.FileInfo {
 // File returns file from siaPath queried by user.
 // Update based on FileList
 func (r *Renter) File(siaPath string) (modules.FileInfo, error) {
-	var fileInfo modules.FileInfo
-
 	// Get the file and its contracs
 	contractIDs := make(map[types.FileContractID]struct{})
 	lockID := r.mu.RLock()
 	defer r.mu.RUnlock(lockID)
 	file, exists := r.files[siaPath]
 	if !exists {
-		return fileInfo, ErrUnknownPath
+		return modules.FileInfo{}, ErrUnknownPath
 	}
 	file.mu.RLock()
 	defer file.mu.RUnlock()
@@ -339,35 +421,10 @@ func (r *Renter) File(siaPath string) (modules.FileInfo, error) {
 
 	// Build 2 maps that map every contract id to its offline and goodForRenew
 	// status.
-	goodForRenew := make(map[types.FileContractID]bool)
-	offline := make(map[types.FileContractID]bool)
-	for cid := range contractIDs {
-		resolvedID := r.hostContractor.ResolveID(cid)
-		cu, ok := r.hostContractor.ContractUtility(resolvedID)
-		goodForRenew[cid] = ok && cu.GoodForRenew
-		offline[cid] = r.hostContractor.IsOffline(resolvedID)
-	}
+	offline, goodForRenew := r.contractStatus(contractIDs, file.tickets)
 
 	// Build the FileInfo
-	renewing := true
-	var localPath string
-	tf, exists := r.persist.Tracking[file.name]
-	if exists {
-		localPath = tf.RepairPath
-	}
-	fileInfo = modules.FileInfo{
-		SiaPath:        file.name,
-		LocalPath:      localPath,
-		Filesize:       file.size,
-		Renewing:       renewing,
-		Available:      file.available(offline),
-		Redundancy:     file.redundancy(offline, goodForRenew),
-		UploadedBytes:  file.uploadedBytes(),
-		UploadProgress: file.uploadProgress(),
-		Expiration:     file.expiration(),
-	}
-
-	return fileInfo, nil
+	return r.fileInfo(file, offline, goodForRenew), nil
 }
 
 // RenameFile takes an existing file and changes the nickname. The original
@@ -391,16 +448,43 @@ func (r *Renter) RenameFile(currentName, newName string) error {
 	if exists {
 		return ErrPathOverload
 	}
+	_, exists = r.dirs[newName]
+	if exists {
+		return ErrDirExists
+	}
+
+	// Make sure that the destination directory exists.
+	err = r.createDir(parentDir(newName))
+	if err != nil {
+		return err
+	}
 
 	// Modify the file and save it to disk.
 	file.mu.Lock()
 	file.name = newName
 	err = r.saveFile(file)
+	if err != nil {
+		file.name = currentName
+	}
 	file.mu.Unlock()
 	if err != nil {
 		return err
 	}
 
+	// Link the file into its new directory. If that fails, the rename is
+	// rolled back and the new .sia file is removed.
+	r.unlinkFile(currentName)
+	if err := r.linkFile(file); err != nil {
+		file.mu.Lock()
+		file.name = currentName
+		file.mu.Unlock()
+		r.dirs[parentDir(currentName)].files[currentName] = struct{}{}
+		if rmErr := persist.RemoveFile(filepath.Join(r.persistDir, newName+ShareExtension)); rmErr != nil {
+			r.log.Println("WARN: couldn't remove new .sia file:", rmErr)
+		}
+		return err
+	}
+
 	// Update the entries in the renter.
 	delete(r.files, currentName)
 	r.files[newName] = file
@@ -408,10 +492,31 @@ func (r *Renter) RenameFile(currentName, newName string) error {
 		delete(r.persist.Tracking, currentName)
 		r.persist.Tracking[newName] = t
 	}
+	r.managedRenameSpending(currentName, newName)
 	err = r.saveSync()
 	if err != nil {
 		return err
 	}
+	if file.packed() {
+		if err := r.savePacks(); err != nil {
+			return err
+		}
+	}
+	if file.dedup {
+		if err := r.saveDedup(); err != nil {
+			return err
+		}
+	}
+	if file.cipherType != crypto.TypeTwofish {
+		if err := r.saveCiphers(); err != nil {
+			return err
+		}
+	}
+	if file.shared() {
+		if err := r.saveTickets(); err != nil {
+			return err
+		}
+	}
 
 	// Delete the old .sia file.
 	oldPath := filepath.Join(r.persistDir, currentName+ShareExtension)
@@ -438,7 +438,7 @@ func TestRenterRenameFile(t *testing.T) {
 	}
 
 	// Renaming should also update the tracking set
-	rt.renter.persist.Tracking["1"] = trackedFile{"foo"}
+	rt.renter.persist.Tracking["1"] = trackedFile{RepairPath: "foo"}
 	err = rt.renter.RenameFile("1", "1b")
 	if err != nil {
 		t.Fatal(err)
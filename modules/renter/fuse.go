@@ -0,0 +1,141 @@
+package renter
+
+// fuse.go mounts the renter's files as a local filesystem, so that programs
+// that don't know about the API can read them. A mount serves a directory of
+// the renter and everything below it. Files are read through streamers, which
+// means that reads benefit from the prefetch window of the mount and from the
+// stream cache. A mount can have a stream cache of its own, so that reading
+// from it doesn't evict the chunks of other streams. Mounts are read-only for
+// now.
+//
+// The filesystem itself is served through FUSE by fuse_unix.go. On platforms
+// without FUSE support, mounting fails with errFUSEUnsupported.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	// ErrMounted is returned when a filesystem is already mounted at the
+	// requested mount point.
+	ErrMounted = errors.New("a filesystem is already mounted at that location")
+
+	// ErrNotMounted is returned when no filesystem is mounted at the
+	// requested mount point.
+	ErrNotMounted = errors.New("no filesystem is mounted at that location")
+)
+
+// A fuseMount is a filesystem mounted by the renter. Closing the server
+// unmounts the filesystem.
+type fuseMount struct {
+	info   modules.MountInfo
+	server io.Closer
+}
+
+// Mount mounts the directory opts.SiaPath of the renter read-only at the
+// mount point, which has to be an existing, empty directory.
+func (r *Renter) Mount(mountPoint string, opts modules.MountOptions) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	if opts.Prefetch > maxStreamPrefetch {
+		return fmt.Errorf("prefetch window can't be larger than %v chunks", maxStreamPrefetch)
+	}
+	if opts.SiaPath != "" {
+		if err := validateDirSiapath(opts.SiaPath); err != nil {
+			return err
+		}
+	}
+	mountPoint, err := filepath.Abs(mountPoint)
+	if err != nil {
+		return err
+	}
+
+	// The renter mutex can't be held while mounting, since the kernel might
+	// already query the filesystem.
+	lockID := r.mu.RLock()
+	_, mounted := r.mounts[mountPoint]
+	_, exists := r.dirs[opts.SiaPath]
+	r.mu.RUnlock(lockID)
+	if mounted {
+		return ErrMounted
+	} else if !exists {
+		return ErrUnknownDir
+	}
+	server, err := r.mountFUSE(mountPoint, opts)
+	if err != nil {
+		return err
+	}
+
+	lockID = r.mu.Lock()
+	r.mounts[mountPoint] = &fuseMount{
+		info: modules.MountInfo{
+			MountPoint: mountPoint,
+			SiaPath:    opts.SiaPath,
+			Prefetch:   opts.Prefetch,
+			CacheSize:  opts.CacheSize,
+			Mounted:    time.Now(),
+			ReadOnly:   true,
+		},
+		server: server,
+	}
+	r.mu.Unlock(lockID)
+	r.log.Println("Mounted", opts.SiaPath, "at", mountPoint)
+	return nil
+}
+
+// Mounts returns information about the mounted filesystems, sorted by mount
+// point.
+func (r *Renter) Mounts() []modules.MountInfo {
+	lockID := r.mu.RLock()
+	infos := make([]modules.MountInfo, 0, len(r.mounts))
+	for _, m := range r.mounts {
+		infos = append(infos, m.info)
+	}
+	r.mu.RUnlock(lockID)
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].MountPoint < infos[j].MountPoint
+	})
+	return infos
+}
+
+// Unmount unmounts the filesystem mounted at the mount point.
+func (r *Renter) Unmount(mountPoint string) error {
+	mountPoint, err := filepath.Abs(mountPoint)
+	if err != nil {
+		return err
+	}
+	lockID := r.mu.Lock()
+	m, exists := r.mounts[mountPoint]
+	delete(r.mounts, mountPoint)
+	r.mu.Unlock(lockID)
+	if !exists {
+		return ErrNotMounted
+	}
+	return m.server.Close()
+}
+
+// managedUnmountAll unmounts every mounted filesystem. It is called when the
+// renter shuts down.
+func (r *Renter) managedUnmountAll() {
+	lockID := r.mu.Lock()
+	mounts := r.mounts
+	r.mounts = make(map[string]*fuseMount)
+	r.mu.Unlock(lockID)
+
+	for mountPoint, m := range mounts {
+		if err := m.server.Close(); err != nil {
+			r.log.Println("WARN: unable to unmount", mountPoint, err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package renter
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestRenterMountValidation checks that invalid mounts are rejected before
+// anything is mounted.
+func TestRenterMountValidation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	mountPoint := rt.dir
+	if err := rt.renter.Mount(mountPoint, modules.MountOptions{SiaPath: "foo"}); err != ErrUnknownDir {
+		t.Fatal("expected ErrUnknownDir, got", err)
+	}
+	if err := rt.renter.Mount(mountPoint, modules.MountOptions{Prefetch: maxStreamPrefetch + 1}); err == nil {
+		t.Fatal("mount with a too large prefetch window should fail")
+	}
+	if err := rt.renter.Unmount(mountPoint); err != ErrNotMounted {
+		t.Fatal("expected ErrNotMounted, got", err)
+	}
+	if len(rt.renter.Mounts()) != 0 {
+		t.Fatal("expected no mounts, got", rt.renter.Mounts())
+	}
+}
@@ -0,0 +1,203 @@
+// +build linux darwin freebsd
+
+package renter
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"syscall"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/errors"
+)
+
+type (
+	// fuseFS is the filesystem of a mount. Its root is the directory with the
+	// siapath root.
+	fuseFS struct {
+		r        *Renter
+		cache    *streamCache
+		root     string
+		prefetch uint64
+	}
+
+	// fuseDir is a directory of a mounted filesystem.
+	fuseDir struct {
+		fs      *fuseFS
+		siaPath string
+	}
+
+	// fuseFile is a file of a mounted filesystem.
+	fuseFile struct {
+		fs      *fuseFS
+		siaPath string
+	}
+
+	// fuseHandle is an open file of a mounted filesystem. The kernel might
+	// read from a handle concurrently, so the streamer is protected by a
+	// mutex.
+	fuseHandle struct {
+		streamer modules.Streamer
+		mu       sync.Mutex
+	}
+
+	// fuseServer serves a fuseFS until the filesystem is unmounted. done is
+	// closed once serving has stopped.
+	fuseServer struct {
+		conn       *fuse.Conn
+		mountPoint string
+		done       chan struct{}
+	}
+)
+
+// mountFUSE mounts the filesystem described by opts at the mount point and
+// starts serving it.
+func (r *Renter) mountFUSE(mountPoint string, opts modules.MountOptions) (io.Closer, error) {
+	conn, err := fuse.Mount(mountPoint, fuse.FSName("sia"), fuse.Subtype("siafs"), fuse.ReadOnly())
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to mount filesystem")
+	}
+	srv := &fuseServer{
+		conn:       conn,
+		mountPoint: mountPoint,
+		done:       make(chan struct{}),
+	}
+	filesys := &fuseFS{
+		r:        r,
+		cache:    r.staticStreamCache,
+		root:     opts.SiaPath,
+		prefetch: opts.Prefetch,
+	}
+	if opts.CacheSize > 0 {
+		filesys.cache = newStreamCache(opts.CacheSize)
+	}
+	go srv.threadedServe(filesys)
+
+	// Wait for the kernel to finish mounting.
+	<-conn.Ready
+	if conn.MountError != nil {
+		return nil, errors.Compose(conn.MountError, srv.Close())
+	}
+	return srv, nil
+}
+
+// threadedServe serves the filesystem until it is unmounted.
+func (srv *fuseServer) threadedServe(filesys *fuseFS) {
+	defer close(srv.done)
+	if err := fs.Serve(srv.conn, filesys); err != nil {
+		filesys.r.log.Println("WARN: serving the filesystem mounted at", srv.mountPoint, "failed:", err)
+	}
+}
+
+// Close unmounts the filesystem and waits for serving to stop.
+func (srv *fuseServer) Close() error {
+	err := fuse.Unmount(srv.mountPoint)
+	if err == nil {
+		<-srv.done
+	}
+	return errors.Compose(err, srv.conn.Close())
+}
+
+// Root implements fs.FS.
+func (filesys *fuseFS) Root() (fs.Node, error) {
+	return &fuseDir{fs: filesys, siaPath: filesys.root}, nil
+}
+
+// Attr implements fs.Node.
+func (d *fuseDir) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Mode = os.ModeDir | 0555
+	return nil
+}
+
+// Lookup implements fs.NodeStringLookuper.
+func (d *fuseDir) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	siaPath := path.Join(d.siaPath, name)
+	lockID := d.fs.r.mu.RLock()
+	defer d.fs.r.mu.RUnlock(lockID)
+	if _, exists := d.fs.r.dirs[siaPath]; exists {
+		return &fuseDir{fs: d.fs, siaPath: siaPath}, nil
+	}
+	if f, exists := d.fs.r.files[siaPath]; exists && !f.deleted {
+		return &fuseFile{fs: d.fs, siaPath: siaPath}, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+// ReadDirAll implements fs.HandleReadDirAller.
+func (d *fuseDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	lockID := d.fs.r.mu.RLock()
+	defer d.fs.r.mu.RUnlock(lockID)
+	dir, exists := d.fs.r.dirs[d.siaPath]
+	if !exists {
+		return nil, fuse.ENOENT
+	}
+
+	var entries []fuse.Dirent
+	for subDir := range dir.subDirs {
+		entries = append(entries, fuse.Dirent{Name: path.Base(subDir), Type: fuse.DT_Dir})
+	}
+	for name := range dir.files {
+		if f, exists := d.fs.r.files[name]; exists && !f.deleted {
+			entries = append(entries, fuse.Dirent{Name: path.Base(name), Type: fuse.DT_File})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// Attr implements fs.Node.
+func (f *fuseFile) Attr(ctx context.Context, a *fuse.Attr) error {
+	lockID := f.fs.r.mu.RLock()
+	file, exists := f.fs.r.files[f.siaPath]
+	f.fs.r.mu.RUnlock(lockID)
+	if !exists {
+		return fuse.ENOENT
+	}
+	file.mu.RLock()
+	a.Size = file.size
+	file.mu.RUnlock()
+	a.Mode = 0444
+	return nil
+}
+
+// Open implements fs.NodeOpener. Files can only be opened for reading.
+func (f *fuseFile) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	if !req.Flags.IsReadOnly() {
+		return nil, fuse.Errno(syscall.EROFS)
+	}
+	_, streamer, err := f.fs.r.managedStreamer(f.siaPath, f.fs.prefetch, f.fs.cache)
+	if err != nil {
+		return nil, fuse.ENOENT
+	}
+	// The files of the renter don't change, so the kernel may cache them.
+	resp.Flags |= fuse.OpenKeepCache
+	return &fuseHandle{streamer: streamer}, nil
+}
+
+// Read implements fs.HandleReader.
+func (h *fuseHandle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, err := h.streamer.Seek(req.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	data := make([]byte, req.Size)
+	n, err := io.ReadFull(h.streamer, data)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	resp.Data = data[:n]
+	return nil
+}
+
+// Release implements fs.HandleReleaser.
+func (h *fuseHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	return h.streamer.Close()
+}
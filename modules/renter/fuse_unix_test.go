@@ -0,0 +1,90 @@
+// +build linux darwin freebsd
+
+package renter
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+
+	"bazil.org/fuse"
+)
+
+// TestFUSENodes checks that the nodes of a mounted filesystem reflect the
+// renter's directories and files, without mounting the filesystem.
+func TestFUSENodes(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	for _, siaPath := range []string{"root", "foo/a", "foo/b", "foo/bar/c"} {
+		if _, err := rt.addTestingFile(siaPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	filesys := &fuseFS{r: rt.renter, cache: newStreamCache(4), root: "foo", prefetch: 1}
+	root, err := filesys.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := root.(*fuseDir)
+
+	// Only the mounted directory is listed.
+	entries, err := dir.ReadDirAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].Name != "a" || entries[1].Name != "b" || entries[2].Name != "bar" {
+		t.Fatal("wrong entries listed:", entries)
+	}
+	if entries[0].Type != fuse.DT_File || entries[2].Type != fuse.DT_Dir {
+		t.Fatal("entries have the wrong types:", entries)
+	}
+
+	// Look up a directory and a file.
+	node, err := dir.Lookup(ctx, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var attr fuse.Attr
+	if err := node.Attr(ctx, &attr); err != nil || !attr.Mode.IsDir() {
+		t.Fatal("bar isn't a directory:", attr, err)
+	}
+	node, err = dir.Lookup(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attr = fuse.Attr{}
+	if err := node.Attr(ctx, &attr); err != nil || attr.Size != 100 || attr.Mode != 0444 {
+		t.Fatal("a has the wrong attributes:", attr, err)
+	}
+	if _, err := dir.Lookup(ctx, "root"); err != fuse.ENOENT {
+		t.Fatal("files outside of the mounted directory shouldn't be found:", err)
+	}
+
+	// Files can't be opened for writing.
+	req := &fuse.OpenRequest{Flags: fuse.OpenFlags(os.O_RDWR)}
+	if _, err := node.(*fuseFile).Open(ctx, req, &fuse.OpenResponse{}); err != fuse.Errno(syscall.EROFS) {
+		t.Fatal("expected EROFS, got", err)
+	}
+
+	// Open files are streamed through the stream cache of the mount.
+	req = &fuse.OpenRequest{Flags: fuse.OpenReadOnly}
+	handle, err := node.(*fuseFile).Open(ctx, req, &fuse.OpenResponse{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := handle.(*fuseHandle).streamer.(*streamer); s.staticCache != filesys.cache {
+		t.Fatal("file isn't streamed through the mount's stream cache")
+	}
+	if err := handle.(*fuseHandle).Release(ctx, &fuse.ReleaseRequest{}); err != nil {
+		t.Fatal(err)
+	}
+}
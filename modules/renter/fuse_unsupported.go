@@ -0,0 +1,19 @@
+// +build !linux,!darwin,!freebsd
+
+package renter
+
+import (
+	"errors"
+	"io"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// errFUSEUnsupported is returned when mounting on a platform without FUSE
+// support.
+var errFUSEUnsupported = errors.New("mounting is not supported on this platform")
+
+// mountFUSE always fails, since FUSE isn't supported on this platform.
+func (r *Renter) mountFUSE(string, modules.MountOptions) (io.Closer, error) {
+	return nil, errFUSEUnsupported
+}
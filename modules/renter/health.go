@@ -0,0 +1,226 @@
+package renter
+
+// health.go contains the logic for computing the health of files and chunks.
+//
+// The health of a chunk is a measure of how close the chunk is to becoming
+// unrecoverable. A health of 0 means that all pieces of the chunk are stored
+// on good hosts, a health of 1 means that only the minimum number of pieces
+// required to recover the chunk is left, and a health above 1 means that the
+// chunk can't be recovered from the network anymore. The health of a file is
+// the health of its least healthy chunk.
+//
+// A chunk is considered stuck if the repair loop tried to repair it but
+// couldn't restore all of its pieces, for example because there were not
+// enough hosts available. Stuck chunks are deprioritized by the repair loop.
+//
+// The health of all files is persisted worst-first in the health file, along
+// with the stuck chunks of every file.
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// HealthFilename is the filename of the file that contains the health of
+	// the renter's files.
+	HealthFilename = "health.json"
+)
+
+var (
+	healthMetadata = persist.Metadata{
+		Header:  "Renter Health",
+		Version: "1.0",
+	}
+)
+
+type (
+	// fileHealth is the persisted health information of a single file.
+	fileHealth struct {
+		SiaPath     string   `json:"siapath"`
+		Health      float64  `json:"health"`
+		StuckChunks []uint64 `json:"stuckchunks"`
+	}
+
+	// healthPersist is the object persisted in the health file. Files are
+	// sorted worst-first.
+	healthPersist struct {
+		Files []fileHealth `json:"files"`
+	}
+)
+
+// chunkHealth returns the health of a chunk with goodPieces pieces stored on
+// good hosts, given that minPieces out of numPieces pieces are required to
+// recover the chunk.
+func chunkHealth(goodPieces, minPieces, numPieces int) float64 {
+	if goodPieces >= numPieces {
+		return 0
+	}
+	parityPieces := numPieces - minPieces
+	if parityPieces < 1 {
+		parityPieces = 1
+	}
+	return 1 - float64(goodPieces-minPieces)/float64(parityPieces)
+}
+
+// healthPerChunk returns the health of every chunk of the file. Only unique
+// pieces stored with online hosts whose contracts are good for renew are
+// counted.
+func (f *file) healthPerChunk(offline map[types.FileContractID]bool, goodForRenew map[types.FileContractID]bool) []float64 {
+	goodPieces := make([]int, f.numChunks())
+	pieceMap := make(map[uint64]map[uint64]struct{})
+	for _, fc := range f.contracts {
+		if offline[fc.ID] || !goodForRenew[fc.ID] {
+			continue
+		}
+		for _, p := range fc.Pieces {
+			if pieceMap[p.Chunk] == nil {
+				pieceMap[p.Chunk] = make(map[uint64]struct{})
+			}
+			if _, redundant := pieceMap[p.Chunk][p.Piece]; redundant {
+				continue
+			}
+			pieceMap[p.Chunk][p.Piece] = struct{}{}
+			goodPieces[p.Chunk]++
+		}
+	}
+	health := make([]float64, len(goodPieces))
+	for i, n := range goodPieces {
+		health[i] = chunkHealth(n, f.erasureCode.MinPieces(), f.erasureCode.NumPieces())
+	}
+	return health
+}
+
+// health returns the health of the least healthy chunk of the file.
+func (f *file) health(offline map[types.FileContractID]bool, goodForRenew map[types.FileContractID]bool) float64 {
+	var worst float64
+	for _, h := range f.healthPerChunk(offline, goodForRenew) {
+		if h > worst {
+			worst = h
+		}
+	}
+	return worst
+}
+
+// markChunkStuck marks the chunk with the provided index as stuck or not
+// stuck.
+func (f *file) markChunkStuck(index uint64, stuck bool) {
+	if !stuck {
+		delete(f.stuckChunks, index)
+		return
+	}
+	if f.stuckChunks == nil {
+		f.stuckChunks = make(map[uint64]struct{})
+	}
+	f.stuckChunks[index] = struct{}{}
+}
+
+// chunkStuck indicates whether the chunk with the provided index is stuck.
+func (f *file) chunkStuck(index uint64) bool {
+	_, stuck := f.stuckChunks[index]
+	return stuck
+}
+
+// numStuckChunks returns the number of stuck chunks of the file.
+func (f *file) numStuckChunks() uint64 {
+	return uint64(len(f.stuckChunks))
+}
+
+// saveHealth writes the health of the provided files to disk, worst-first.
+func (r *Renter) saveHealth(files []fileHealth) error {
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Health > files[j].Health
+	})
+	return persist.SaveJSON(healthMetadata, healthPersist{Files: files}, filepath.Join(r.persistDir, HealthFilename))
+}
+
+// loadHealth restores the stuck chunks of the renter's files from disk. Files
+// that don't exist anymore are ignored.
+func (r *Renter) loadHealth() error {
+	var hp healthPersist
+	err := persist.LoadJSON(healthMetadata, &hp, filepath.Join(r.persistDir, HealthFilename))
+	if err != nil {
+		return err
+	}
+	for _, fh := range hp.Files {
+		f, exists := r.files[fh.SiaPath]
+		if !exists {
+			continue
+		}
+		r.health[fh.SiaPath] = fh
+		f.mu.Lock()
+		for _, index := range fh.StuckChunks {
+			if index < f.numChunks() {
+				f.markChunkStuck(index, true)
+			}
+		}
+		f.mu.Unlock()
+	}
+	return nil
+}
+
+// managedUpdateHealth computes the health of all of the renter's files,
+// persists it if it changed and returns the files ordered worst-first.
+func (r *Renter) managedUpdateHealth() []*file {
+	// Get all the files and their contracts.
+	id := r.mu.RLock()
+	files := make([]*file, 0, len(r.files))
+	contractIDs := make(map[types.FileContractID]struct{})
+	tickets := make(map[types.FileContractID]fileTicket)
+	for _, f := range r.files {
+		files = append(files, f)
+		f.mu.RLock()
+		for cid := range f.contracts {
+			contractIDs[cid] = struct{}{}
+		}
+		for cid, ft := range f.tickets {
+			tickets[cid] = ft
+		}
+		f.mu.RUnlock()
+	}
+	r.mu.RUnlock(id)
+	offline, goodForRenew := r.contractStatus(contractIDs, tickets)
+
+	// Compute the health of every file.
+	healths := make(map[string]fileHealth, len(files))
+	healthOf := make(map[*file]float64, len(files))
+	for _, f := range files {
+		f.mu.RLock()
+		fh := fileHealth{
+			SiaPath:     f.name,
+			Health:      f.health(offline, goodForRenew),
+			StuckChunks: make([]uint64, 0, len(f.stuckChunks)),
+		}
+		for index := range f.stuckChunks {
+			fh.StuckChunks = append(fh.StuckChunks, index)
+		}
+		f.mu.RUnlock()
+		sort.Slice(fh.StuckChunks, func(i, j int) bool { return fh.StuckChunks[i] < fh.StuckChunks[j] })
+		healths[fh.SiaPath] = fh
+		healthOf[f] = fh.Health
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return healthOf[files[i]] > healthOf[files[j]]
+	})
+
+	// Persist the health if it changed since it was last saved.
+	id = r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if reflect.DeepEqual(healths, r.health) {
+		return files
+	}
+	saved := make([]fileHealth, 0, len(healths))
+	for _, fh := range healths {
+		saved = append(saved, fh)
+	}
+	if err := r.saveHealth(saved); err != nil {
+		r.log.Println("WARN: unable to save file health:", err)
+		return files
+	}
+	r.health = healths
+	return files
+}
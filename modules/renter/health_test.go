@@ -0,0 +1,186 @@
+package renter
+
+import (
+	"container/heap"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// healthEqual compares two health values, allowing for rounding errors.
+func healthEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// TestChunkHealth probes the chunkHealth function.
+func TestChunkHealth(t *testing.T) {
+	tests := []struct {
+		goodPieces, minPieces, numPieces int
+		health                           float64
+	}{
+		{30, 10, 30, 0},    // fully uploaded
+		{35, 10, 30, 0},    // more pieces than needed
+		{20, 10, 30, 0.5},  // half of the parity is missing
+		{10, 10, 30, 1},    // only the data pieces are left
+		{0, 10, 30, 1.5},   // nothing is left
+		{1, 1, 1, 0},       // no parity, fully uploaded
+		{0, 1, 1, 2},       // no parity, nothing uploaded
+		{5, 1, 9, 0.5},     // default testing erasure code
+		{0, 1, 9, 1.125},   // default testing erasure code, nothing uploaded
+		{9, 1, 9, 0},       // default testing erasure code, fully uploaded
+		{2, 2, 4, 1},       // minimum number of pieces
+		{3, 2, 4, 0.5},     // one parity piece
+		{4, 2, 4, 0},       // all parity pieces
+		{1, 2, 4, 1.5},     // unrecoverable
+		{29, 10, 30, 0.05}, // one piece missing
+	}
+	for _, test := range tests {
+		health := chunkHealth(test.goodPieces, test.minPieces, test.numPieces)
+		if !healthEqual(health, test.health) {
+			t.Errorf("chunkHealth(%v, %v, %v): expected %v, got %v", test.goodPieces, test.minPieces, test.numPieces, test.health, health)
+		}
+	}
+}
+
+// TestFileHealth checks that the health of a file only counts the pieces of
+// online contracts that are good for renew, and that it is the health of the
+// least healthy chunk.
+func TestFileHealth(t *testing.T) {
+	rsc, _ := NewRSCode(1, 3)
+	f := &file{
+		size:        1000,
+		pieceSize:   100,
+		contracts:   make(map[types.FileContractID]fileContract),
+		erasureCode: rsc,
+	}
+	offline := make(map[types.FileContractID]bool)
+	goodForRenew := make(map[types.FileContractID]bool)
+
+	// A file without any pieces is unrecoverable.
+	if h := f.health(offline, goodForRenew); !healthEqual(h, 4.0/3) {
+		t.Fatal("expected health 4/3, got", h)
+	}
+
+	// Upload every piece of every chunk to a different contract.
+	for piece := uint64(0); piece < 4; piece++ {
+		fc := fileContract{ID: types.FileContractID{byte(piece)}}
+		for chunk := uint64(0); chunk < f.numChunks(); chunk++ {
+			fc.Pieces = append(fc.Pieces, pieceData{Chunk: chunk, Piece: piece})
+		}
+		f.contracts[fc.ID] = fc
+		goodForRenew[fc.ID] = true
+	}
+	if h := f.health(offline, goodForRenew); h != 0 {
+		t.Fatal("expected health 0, got", h)
+	}
+
+	// Take one host offline and mark another one as not good for renew.
+	offline[types.FileContractID{0}] = true
+	goodForRenew[types.FileContractID{1}] = false
+	if h := f.health(offline, goodForRenew); !healthEqual(h, 2.0/3) {
+		t.Fatal("expected health 2/3, got", h)
+	}
+
+	// Remove a piece of the last chunk from one of the remaining contracts.
+	fc := f.contracts[types.FileContractID{2}]
+	fc.Pieces = fc.Pieces[:len(fc.Pieces)-1]
+	f.contracts[fc.ID] = fc
+	chunkHealths := f.healthPerChunk(offline, goodForRenew)
+	if !healthEqual(chunkHealths[0], 2.0/3) || !healthEqual(chunkHealths[len(chunkHealths)-1], 1) {
+		t.Fatal("unexpected chunk health:", chunkHealths)
+	}
+	if h := f.health(offline, goodForRenew); h != 1 {
+		t.Fatal("expected health 1, got", h)
+	}
+}
+
+// TestUploadHeapHealthOrder checks that the upload heap pops the least healthy
+// chunks first and stuck chunks last.
+func TestUploadHeapHealthOrder(t *testing.T) {
+	var uch uploadChunkHeap
+	chunks := []*unfinishedUploadChunk{
+		{health: 0.2},
+		{health: 1.5, stuck: true},
+		{health: 1},
+		{health: 0.5},
+		{health: 0.3, stuck: true},
+	}
+	for _, uuc := range chunks {
+		heap.Push(&uch, uuc)
+	}
+	expected := []*unfinishedUploadChunk{chunks[2], chunks[3], chunks[0], chunks[1], chunks[4]}
+	for i, exp := range expected {
+		if uuc := heap.Pop(&uch).(*unfinishedUploadChunk); uuc != exp {
+			t.Fatalf("chunk %v: expected health %v (stuck %v), got %v (stuck %v)", i, exp.health, exp.stuck, uuc.health, uuc.stuck)
+		}
+	}
+}
+
+// TestRenterHealthPersist checks that the stuck chunks of files are persisted
+// across restarts.
+func TestRenterHealthPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	f, err := rt.addTestingFile("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.markChunkStuck(2, true)
+	f.markChunkStuck(5, true)
+	rt.renter.managedUpdateHealth()
+
+	// Check the stuck chunks are reported.
+	fi, err := rt.renter.File("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.StuckChunks != 2 {
+		t.Fatal("expected 2 stuck chunks, got", fi.StuckChunks)
+	}
+
+	// Restart the renter and check that the stuck chunks were restored.
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, filepath.Join(rt.dir, modules.RenterDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f = rt.renter.files["foo"]
+	if !f.chunkStuck(2) || !f.chunkStuck(5) || f.numStuckChunks() != 2 {
+		t.Fatal("stuck chunks were not restored:", f.stuckChunks)
+	}
+	dirs, _, err := rt.renter.DirList("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirs[0].StuckChunks != 2 {
+		t.Fatal("expected 2 stuck chunks in root directory, got", dirs[0].StuckChunks)
+	}
+
+	// The health file is only rewritten when the health changed.
+	healthPath := filepath.Join(rt.renter.persistDir, HealthFilename)
+	if err := os.Remove(healthPath); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter.managedUpdateHealth()
+	if _, err := os.Stat(healthPath); !os.IsNotExist(err) {
+		t.Fatal("health file was saved although the health didn't change:", err)
+	}
+	f.markChunkStuck(5, false)
+	rt.renter.managedUpdateHealth()
+	if _, err := os.Stat(healthPath); err != nil {
+		t.Fatal("health file was not saved after the health changed:", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
 )
 
 const (
@@ -45,6 +46,10 @@ const (
 	// minScansForSpeedup successful scans.
 	scanSpeedupMedianMultiplier = 5
 
+	// performanceDecay is the weight of the previous measurements of a host's
+	// throughput and latency when a new measurement is recorded.
+	performanceDecay = 0.9
+
 	// recentInteractionWeightLimit caps the number of recent interactions as a
 	// percentage of the historic interactions, to be certain that a large
 	// amount of activity in a short period of time does not overwhelm the
@@ -64,6 +69,11 @@ const (
 )
 
 var (
+	// minThroughputSample is the minimum number of bytes that need to be
+	// transferred for a throughput measurement to be recorded. Smaller
+	// transfers are dominated by the latency of the host.
+	minThroughputSample = modules.SectorSize / 4
+
 	// hostCheckupQuantity specifies the number of hosts that get scanned every
 	// time there is a regular scanning operation.
 	hostCheckupQuantity = build.Select(build.Var{
@@ -0,0 +1,227 @@
+package hostdb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/hostdb/hosttree"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// ipv4SubnetBits and ipv6SubnetBits are the prefix lengths of the subnets
+	// that are used for the subnet diversity checks.
+	ipv4SubnetBits = 24
+	ipv6SubnetBits = 64
+)
+
+type (
+	// geoIPDatabase maps IP ranges to regions. It is loaded from a local CSV
+	// file where every line has the form 'network,region', e.g.
+	// '1.2.3.0/24,DE'. Empty lines and lines starting with '#' are ignored.
+	// The networks must not overlap.
+	geoIPDatabase struct {
+		ranges []geoIPRange
+	}
+
+	// geoIPRange is a range of IP addresses in the geoIPDatabase. The start
+	// and end addresses are stored in their 16 byte form.
+	geoIPRange struct {
+		start  net.IP
+		end    net.IP
+		region string
+	}
+)
+
+// loadGeoIPDatabase loads a GeoIP database from a file.
+func loadGeoIPDatabase(path string) (*geoIPDatabase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	db := new(geoIPDatabase)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %v of GeoIP database is missing the region", line)
+		}
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %v of GeoIP database: %v", line, err)
+		}
+		end := make(net.IP, len(ipNet.IP))
+		for i := range end {
+			end[i] = ipNet.IP[i] | ^ipNet.Mask[i]
+		}
+		db.ranges = append(db.ranges, geoIPRange{
+			start:  ipNet.IP.To16(),
+			end:    end.To16(),
+			region: strings.TrimSpace(fields[1]),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].start, db.ranges[j].start) < 0
+	})
+	return db, nil
+}
+
+// lookup returns the region of an IP address, or an empty string if the
+// address isn't in the database.
+func (db *geoIPDatabase) lookup(ip net.IP) string {
+	ip = ip.To16()
+	if ip == nil {
+		return ""
+	}
+	// Find the last range that starts at or before the address.
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].start, ip) > 0
+	}) - 1
+	if i < 0 || bytes.Compare(ip, db.ranges[i].end) > 0 {
+		return ""
+	}
+	return db.ranges[i].region
+}
+
+// regionOf returns the region of an IP subnet as returned by ipNets.
+func (db *geoIPDatabase) regionOf(ipNet string) string {
+	ip, _, err := net.ParseCIDR(ipNet)
+	if err != nil {
+		return ""
+	}
+	return db.lookup(ip)
+}
+
+// ipNets returns the sorted, deduplicated subnets of the IP addresses.
+func ipNets(ips []net.IP) []string {
+	set := make(map[string]struct{})
+	for _, ip := range ips {
+		mask := net.CIDRMask(ipv6SubnetBits, 128)
+		if ip.To4() != nil {
+			ip = ip.To4()
+			mask = net.CIDRMask(ipv4SubnetBits, 32)
+		}
+		ipNet := net.IPNet{IP: ip.Mask(mask), Mask: mask}
+		set[ipNet.String()] = struct{}{}
+	}
+	nets := make([]string, 0, len(set))
+	for ipNet := range set {
+		nets = append(nets, ipNet)
+	}
+	sort.Strings(nets)
+	return nets
+}
+
+// lookupIPNets resolves the host of the address and returns the subnets of
+// its IP addresses.
+func lookupIPNets(addr modules.NetAddress) ([]string, error) {
+	ips, err := net.LookupIP(addr.Host())
+	if err != nil {
+		return nil, err
+	}
+	return ipNets(ips), nil
+}
+
+// diversityFilter returns a filter for the diversity policy of the hostdb, or
+// nil if the policy is disabled. The caller must hold the lock.
+func (hdb *HostDB) diversityFilter() *hosttree.Filter {
+	if !hdb.subnetDiversity && hdb.geoIP == nil {
+		return nil
+	}
+	var regionOf hosttree.RegionFunc
+	if hdb.geoIP != nil {
+		regionOf = hdb.geoIP.regionOf
+	}
+	return hosttree.NewFilter(hdb.subnetDiversity, regionOf)
+}
+
+// DiversityViolations returns the groups of hosts that share a subnet or a
+// region, according to the diversity policy of the hostdb.
+func (hdb *HostDB) DiversityViolations(hosts []types.SiaPublicKey) []modules.HostDiversityViolation {
+	hdb.mu.RLock()
+	subnetDiversity := hdb.subnetDiversity
+	geoIP := hdb.geoIP
+	hdb.mu.RUnlock()
+
+	// Group the hosts by subnet and region. A host is only added once to each
+	// group, even if several of its addresses are in it.
+	subnets := make(map[string][]types.SiaPublicKey)
+	regions := make(map[string][]types.SiaPublicKey)
+	for _, spk := range hosts {
+		entry, exists := hdb.hostTree.Select(spk)
+		if !exists {
+			continue
+		}
+		seen := make(map[string]struct{})
+		for _, ipNet := range entry.IPNets {
+			if subnetDiversity {
+				subnets[ipNet] = append(subnets[ipNet], spk)
+			}
+			if geoIP == nil {
+				continue
+			}
+			region := geoIP.regionOf(ipNet)
+			if _, ok := seen[region]; ok || region == "" {
+				continue
+			}
+			seen[region] = struct{}{}
+			regions[region] = append(regions[region], spk)
+		}
+	}
+
+	var violations []modules.HostDiversityViolation
+	for subnet, spks := range subnets {
+		if len(spks) > 1 {
+			violations = append(violations, modules.HostDiversityViolation{Subnet: subnet, Hosts: spks})
+		}
+	}
+	for region, spks := range regions {
+		if len(spks) > 1 {
+			violations = append(violations, modules.HostDiversityViolation{Region: region, Hosts: spks})
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		vi, vj := violations[i], violations[j]
+		if (vi.Subnet == "") != (vj.Subnet == "") {
+			return vi.Subnet != ""
+		}
+		return vi.Subnet+vi.Region < vj.Subnet+vj.Region
+	})
+	return violations
+}
+
+// SetDiversityPolicy sets the diversity policy of the hostdb. If subnets is
+// true, no two hosts in the same IP subnet are selected. If geoIPPath is not
+// empty, the GeoIP database is loaded from it and no two hosts in the same
+// region are selected.
+func (hdb *HostDB) SetDiversityPolicy(subnets bool, geoIPPath string) error {
+	var geoIP *geoIPDatabase
+	if geoIPPath != "" {
+		var err error
+		geoIP, err = loadGeoIPDatabase(geoIPPath)
+		if err != nil {
+			return fmt.Errorf("unable to load GeoIP database: %v", err)
+		}
+	}
+
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+	hdb.subnetDiversity = subnets
+	hdb.geoIP = geoIP
+	return nil
+}
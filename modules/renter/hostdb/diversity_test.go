@@ -0,0 +1,185 @@
+package hostdb
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// testGeoIPDatabase is the content of the GeoIP database used by the tests.
+const testGeoIPDatabase = `# network,region
+1.1.0.0/16,eu
+2.2.2.0/24,eu
+
+3.3.3.0/24,us
+2001:db8::/32,as
+`
+
+// writeTestGeoIPDatabase writes the test GeoIP database to the test dir and
+// returns its path.
+func writeTestGeoIPDatabase(t *testing.T) string {
+	dir := build.TempDir("HostDB", t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "geoip.csv")
+	if err := ioutil.WriteFile(path, []byte(testGeoIPDatabase), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestGeoIPDatabase checks that regions are looked up correctly and that
+// invalid databases are rejected.
+func TestGeoIPDatabase(t *testing.T) {
+	path := writeTestGeoIPDatabase(t)
+	db, err := loadGeoIPDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip     string
+		region string
+	}{
+		{"1.1.0.0", "eu"},
+		{"1.1.255.255", "eu"},
+		{"1.2.0.0", ""},
+		{"2.2.2.7", "eu"},
+		{"3.3.3.3", "us"},
+		{"0.0.0.1", ""},
+		{"255.255.255.255", ""},
+		{"2001:db8:1::1", "as"},
+		{"2001:db9::1", ""},
+	}
+	for _, test := range tests {
+		if region := db.lookup(net.ParseIP(test.ip)); region != test.region {
+			t.Errorf("wrong region for %v: expected %q, got %q", test.ip, test.region, region)
+		}
+	}
+	if region := db.regionOf("3.3.3.0/24"); region != "us" {
+		t.Error("wrong region for subnet:", region)
+	}
+
+	// Databases with invalid lines can't be loaded.
+	invalid := filepath.Join(filepath.Dir(path), "invalid.csv")
+	if err := ioutil.WriteFile(invalid, []byte("1.1.1.0/24\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadGeoIPDatabase(invalid); err == nil {
+		t.Error("database without region was loaded")
+	}
+	if err := ioutil.WriteFile(invalid, []byte("1.1.1.1,eu\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadGeoIPDatabase(invalid); err == nil {
+		t.Error("database with invalid network was loaded")
+	}
+}
+
+// TestIPNets checks that addresses are mapped to their /24 and /64 subnets.
+func TestIPNets(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("1.2.3.4"),
+		net.ParseIP("1.2.3.200"),
+		net.ParseIP("2001:db8:1:2:3:4:5:6"),
+		net.ParseIP("1.2.4.1"),
+	}
+	expected := []string{"1.2.3.0/24", "1.2.4.0/24", "2001:db8:1:2::/64"}
+	if nets := ipNets(ips); !reflect.DeepEqual(nets, expected) {
+		t.Fatal("wrong subnets:", nets)
+	}
+	nets, err := lookupIPNets("127.0.0.1:9982")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nets, []string{"127.0.0.0/24"}) {
+		t.Fatal("wrong subnets:", nets)
+	}
+}
+
+// TestDiversityPolicy checks that the hostdb doesn't select hosts that share a
+// subnet or region with each other or the excluded hosts, and that the
+// violations of the policy are reported.
+func TestDiversityPolicy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	hdbt, err := newHDBTesterDeps(t.Name(), &disableScanLoopDeps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []types.SiaPublicKey
+	for _, ipNet := range []string{"1.1.1.0/24", "1.1.1.0/24", "1.1.2.0/24", "3.3.3.0/24"} {
+		entry := makeHostDBEntry()
+		entry.IPNets = []string{ipNet}
+		if err := hdbt.hdb.hostTree.Insert(entry); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, entry.PublicKey)
+	}
+
+	// Without a policy all hosts are selected.
+	hosts, err := hdbt.hdb.RandomHosts(len(keys), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 4 {
+		t.Fatal("expected 4 hosts, got", len(hosts))
+	}
+	if v := hdbt.hdb.DiversityViolations(keys); len(v) != 0 {
+		t.Fatal("violations were reported without a policy:", v)
+	}
+
+	// With subnet diversity, only one of the first two hosts is selected.
+	if err := hdbt.hdb.SetDiversityPolicy(true, ""); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err = hdbt.hdb.RandomHosts(len(keys), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 3 {
+		t.Fatal("expected 3 hosts, got", len(hosts))
+	}
+	hosts, err = hdbt.hdb.RandomHosts(len(keys), keys[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 {
+		t.Fatal("host in the subnet of an excluded host was selected:", len(hosts))
+	}
+	expected := []modules.HostDiversityViolation{{Subnet: "1.1.1.0/24", Hosts: keys[:2]}}
+	if v := hdbt.hdb.DiversityViolations(keys); !reflect.DeepEqual(v, expected) {
+		t.Fatal("wrong violations:", v)
+	}
+
+	// With region diversity, the first three hosts share a region.
+	if err := hdbt.hdb.SetDiversityPolicy(false, filepath.Join(hdbt.persistDir, "missing.csv")); err == nil {
+		t.Fatal("missing GeoIP database was loaded")
+	}
+	if err := hdbt.hdb.SetDiversityPolicy(true, writeTestGeoIPDatabase(t)); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err = hdbt.hdb.RandomHosts(len(keys), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 {
+		t.Fatal("expected 2 hosts, got", len(hosts))
+	}
+	expected = []modules.HostDiversityViolation{
+		{Subnet: "1.1.1.0/24", Hosts: keys[:2]},
+		{Region: "eu", Hosts: keys[:3]},
+	}
+	if v := hdbt.hdb.DiversityViolations(keys); !reflect.DeepEqual(v, expected) {
+		t.Fatal("wrong violations:", v)
+	}
+}
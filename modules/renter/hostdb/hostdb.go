@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -23,6 +24,7 @@ var (
 	// ErrInitialScanIncomplete is returned whenever an operation is not
 	// allowed to be executed before the initial host scan has finished.
 	ErrInitialScanIncomplete = errors.New("initial hostdb scan is not yet completed")
+	errEmptyWhitelist        = errors.New("whitelist must contain at least one host")
 	errNilCS                 = errors.New("cannot create hostdb with nil consensus set")
 	errNilGateway            = errors.New("cannot create hostdb with nil gateway")
 )
@@ -56,6 +58,17 @@ type HostDB struct {
 	scanWait             bool
 	scanningThreads      int
 
+	// The filter mode determines whether the filtered hosts are excluded
+	// from or the only hosts of the random selection. The filtered hosts are
+	// indexed by their public key string.
+	filterMode    modules.FilterMode
+	filteredHosts map[string]types.SiaPublicKey
+
+	// The diversity policy prevents the selection of hosts that share an IP
+	// subnet or, if a GeoIP database is loaded, a region.
+	subnetDiversity bool
+	geoIP           *geoIPDatabase
+
 	blockHeight types.BlockHeight
 	lastChange  modules.ConsensusChangeID
 }
@@ -84,7 +97,8 @@ func NewCustomHostDB(g modules.Gateway, cs modules.ConsensusSet, persistDir stri
 		gateway:    g,
 		persistDir: persistDir,
 
-		scanMap: make(map[string]struct{}),
+		filteredHosts: make(map[string]types.SiaPublicKey),
+		scanMap:       make(map[string]struct{}),
 	}
 
 	// Create the persist directory if it does not yet exist.
@@ -174,9 +188,31 @@ func NewCustomHostDB(g modules.Gateway, cs modules.ConsensusSet, persistDir stri
 	return hdb, nil
 }
 
-// ActiveHosts returns a list of hosts that are currently online, sorted by
-// weight.
-func (hdb *HostDB) ActiveHosts() (activeHosts []modules.HostDBEntry) {
+// isFiltered returns true if the host is excluded by the filter mode. The
+// caller must hold the lock.
+func (hdb *HostDB) isFiltered(spk types.SiaPublicKey) bool {
+	_, listed := hdb.filteredHosts[spk.String()]
+	switch hdb.filterMode {
+	case modules.HostDBFilterBlacklist:
+		return listed
+	case modules.HostDBFilterWhitelist:
+		return !listed
+	}
+	return false
+}
+
+// markFiltered sets the Filtered field of the entries.
+func (hdb *HostDB) markFiltered(entries []modules.HostDBEntry) {
+	hdb.mu.RLock()
+	defer hdb.mu.RUnlock()
+	for i := range entries {
+		entries[i].Filtered = hdb.isFiltered(entries[i].PublicKey)
+	}
+}
+
+// activeHosts returns the hosts of the host tree that are currently online,
+// sorted by weight.
+func (hdb *HostDB) activeHosts() (activeHosts []modules.HostDBEntry) {
 	allHosts := hdb.hostTree.All()
 	for _, entry := range allHosts {
 		if len(entry.ScanHistory) == 0 {
@@ -193,16 +229,26 @@ func (hdb *HostDB) ActiveHosts() (activeHosts []modules.HostDBEntry) {
 	return activeHosts
 }
 
+// ActiveHosts returns a list of hosts that are currently online, sorted by
+// weight.
+func (hdb *HostDB) ActiveHosts() []modules.HostDBEntry {
+	activeHosts := hdb.activeHosts()
+	hdb.markFiltered(activeHosts)
+	return activeHosts
+}
+
 // AllHosts returns all of the hosts known to the hostdb, including the
 // inactive ones.
 func (hdb *HostDB) AllHosts() (allHosts []modules.HostDBEntry) {
-	return hdb.hostTree.All()
+	allHosts = hdb.hostTree.All()
+	hdb.markFiltered(allHosts)
+	return allHosts
 }
 
 // AverageContractPrice returns the average price of a host.
 func (hdb *HostDB) AverageContractPrice() (totalPrice types.Currency) {
 	sampleSize := 32
-	hosts := hdb.hostTree.SelectRandom(sampleSize, nil)
+	hosts := hdb.hostTree.SelectRandom(sampleSize, nil, nil)
 	if len(hosts) == 0 {
 		return totalPrice
 	}
@@ -212,6 +258,21 @@ func (hdb *HostDB) AverageContractPrice() (totalPrice types.Currency) {
 	return totalPrice.Div64(uint64(len(hosts)))
 }
 
+// FilterMode returns the filter mode of the hostdb and the hosts it applies
+// to.
+func (hdb *HostDB) FilterMode() (modules.FilterMode, []types.SiaPublicKey) {
+	hdb.mu.RLock()
+	defer hdb.mu.RUnlock()
+	hosts := make([]types.SiaPublicKey, 0, len(hdb.filteredHosts))
+	for _, spk := range hdb.filteredHosts {
+		hosts = append(hosts, spk)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].String() < hosts[j].String()
+	})
+	return hdb.filterMode, hosts
+}
+
 // Close closes the hostdb, terminating its scanning threads
 func (hdb *HostDB) Close() error {
 	return hdb.tg.Stop()
@@ -226,6 +287,7 @@ func (hdb *HostDB) Host(spk types.SiaPublicKey) (modules.HostDBEntry, bool) {
 	}
 	hdb.mu.RLock()
 	updateHostHistoricInteractions(&host, hdb.blockHeight)
+	host.Filtered = hdb.isFiltered(spk)
 	hdb.mu.RUnlock()
 	return host, exists
 }
@@ -236,9 +298,59 @@ func (hdb *HostDB) Host(spk types.SiaPublicKey) (modules.HostDBEntry, bool) {
 func (hdb *HostDB) RandomHosts(n int, excludeKeys []types.SiaPublicKey) ([]modules.HostDBEntry, error) {
 	hdb.mu.RLock()
 	initialScanComplete := hdb.initialScanComplete
+	filterMode := hdb.filterMode
+	filter := hdb.diversityFilter()
 	hdb.mu.RUnlock()
 	if !initialScanComplete {
 		return []modules.HostDBEntry{}, ErrInitialScanIncomplete
 	}
-	return hdb.hostTree.SelectRandom(n, excludeKeys), nil
+
+	// The selected hosts mustn't share a subnet or region with each other or
+	// with the excluded hosts.
+	if filter != nil {
+		for _, spk := range excludeKeys {
+			if entry, exists := hdb.hostTree.Select(spk); exists {
+				filter.Add(entry)
+			}
+		}
+	}
+
+	// Hosts that are excluded by the filter are ignored as well.
+	if filterMode != modules.HostDBFilterDisabled {
+		ignore := append([]types.SiaPublicKey(nil), excludeKeys...)
+		for _, entry := range hdb.AllHosts() {
+			if entry.Filtered {
+				ignore = append(ignore, entry.PublicKey)
+			}
+		}
+		excludeKeys = ignore
+	}
+	return hdb.hostTree.SelectRandom(n, excludeKeys, filter), nil
+}
+
+// SetFilterMode sets the filter mode of the hostdb. In blacklist mode the
+// hosts are never selected, in whitelist mode only the hosts are selected.
+// The hosts are ignored if the filter is disabled.
+func (hdb *HostDB) SetFilterMode(fm modules.FilterMode, hosts []types.SiaPublicKey) error {
+	switch fm {
+	case modules.HostDBFilterDisabled:
+		hosts = nil
+	case modules.HostDBFilterBlacklist:
+	case modules.HostDBFilterWhitelist:
+		if len(hosts) == 0 {
+			return errEmptyWhitelist
+		}
+	default:
+		return modules.ErrUnknownFilterMode
+	}
+
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+	hdb.filterMode = fm
+	hdb.filteredHosts = make(map[string]types.SiaPublicKey)
+	for _, spk := range hosts {
+		hdb.filteredHosts[spk.String()] = spk
+	}
+	hdb.log.Printf("INFO: set filter mode to %v with %v hosts", fm, len(hosts))
+	return hdb.saveSync()
 }
@@ -438,6 +438,46 @@ func TestRemoveNonexistingHostFromHostTree(t *testing.T) {
 	}
 }
 
+// TestRecordPerformance checks that the throughput and latency of a host are
+// recorded as moving averages, and that small transfers are ignored.
+func TestRecordPerformance(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	hdbt, err := newHDBTesterDeps(t.Name(), &disableScanLoopDeps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := makeHostDBEntry()
+	if err := hdbt.hdb.hostTree.Insert(host); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first measurement replaces the unmeasured values.
+	hdbt.hdb.RecordDownload(host.PublicKey, modules.SectorSize, time.Second)
+	hdbt.hdb.RecordUpload(host.PublicKey, modules.SectorSize, 2*time.Second)
+	hdbt.hdb.RecordLatency(host.PublicKey, 100*time.Millisecond)
+	host, _ = hdbt.hdb.Host(host.PublicKey)
+	if host.DownloadThroughput != float64(modules.SectorSize) || host.UploadThroughput != float64(modules.SectorSize)/2 || host.Latency != 100*time.Millisecond {
+		t.Fatal("performance wasn't recorded:", host.DownloadThroughput, host.UploadThroughput, host.Latency)
+	}
+
+	// Later measurements are averaged, small transfers are ignored.
+	hdbt.hdb.RecordDownload(host.PublicKey, modules.SectorSize, time.Second/2)
+	hdbt.hdb.RecordUpload(host.PublicKey, minThroughputSample-1, time.Nanosecond)
+	hdbt.hdb.RecordLatency(host.PublicKey, 200*time.Millisecond)
+	host, _ = hdbt.hdb.Host(host.PublicKey)
+	if host.DownloadThroughput <= float64(modules.SectorSize) || host.DownloadThroughput >= 2*float64(modules.SectorSize) {
+		t.Fatal("download throughput wasn't averaged:", host.DownloadThroughput)
+	}
+	if host.UploadThroughput != float64(modules.SectorSize)/2 {
+		t.Fatal("small upload was recorded:", host.UploadThroughput)
+	}
+	if host.Latency <= 100*time.Millisecond || host.Latency >= 200*time.Millisecond {
+		t.Fatal("latency wasn't averaged:", host.Latency)
+	}
+}
+
 // TestUpdateHistoricInteractions is a simple check to ensure that incrementing
 // the recent and historic host interactions works
 func TestUpdateHistoricInteractions(t *testing.T) {
@@ -583,3 +623,91 @@ func TestUpdateHistoricInteractions(t *testing.T) {
 			host.HistoricFailedInteractions, host.HistoricSuccessfulInteractions)
 	}
 }
+
+// TestFilterMode checks that the blacklist and whitelist modes of the hostdb
+// exclude the right hosts from selection and that the filter mode persists.
+func TestFilterMode(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	hdbt, err := newHDBTesterDeps(t.Name(), &disableScanLoopDeps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []types.SiaPublicKey
+	for i := 0; i < 5; i++ {
+		entry := makeHostDBEntry()
+		if err := hdbt.hdb.hostTree.Insert(entry); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, entry.PublicKey)
+	}
+
+	// Invalid modes and empty whitelists should be rejected.
+	if err := hdbt.hdb.SetFilterMode(modules.FilterMode(-1), nil); err != modules.ErrUnknownFilterMode {
+		t.Fatal("expected ErrUnknownFilterMode, got", err)
+	}
+	if err := hdbt.hdb.SetFilterMode(modules.HostDBFilterWhitelist, nil); err != errEmptyWhitelist {
+		t.Fatal("expected errEmptyWhitelist, got", err)
+	}
+
+	// Blacklisted hosts should never be selected.
+	if err := hdbt.hdb.SetFilterMode(modules.HostDBFilterBlacklist, keys[:2]); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := hdbt.hdb.RandomHosts(len(keys), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 3 {
+		t.Fatal("wrong number of hosts selected:", len(hosts))
+	}
+	for _, host := range hosts {
+		if host.PublicKey.String() == keys[0].String() || host.PublicKey.String() == keys[1].String() {
+			t.Fatal("blacklisted host was selected")
+		}
+	}
+	if host, ok := hdbt.hdb.Host(keys[0]); !ok || !host.Filtered {
+		t.Fatal("blacklisted host isn't marked as filtered")
+	}
+	if host, ok := hdbt.hdb.Host(keys[2]); !ok || host.Filtered {
+		t.Fatal("host isn't blacklisted but marked as filtered")
+	}
+
+	// Only whitelisted hosts should be selected.
+	if err := hdbt.hdb.SetFilterMode(modules.HostDBFilterWhitelist, keys[:1]); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err = hdbt.hdb.RandomHosts(len(keys), keys[1:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].PublicKey.String() != keys[0].String() {
+		t.Fatal("wrong hosts selected from the whitelist:", hosts)
+	}
+
+	// The filter mode should be restored after a restart.
+	if err := hdbt.hdb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	hdbt.hdb, err = NewCustomHostDB(hdbt.gateway, hdbt.cs, filepath.Join(hdbt.persistDir, modules.RenterDir), &quitAfterLoadDeps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm, filtered := hdbt.hdb.FilterMode()
+	if fm != modules.HostDBFilterWhitelist || len(filtered) != 1 || filtered[0].String() != keys[0].String() {
+		t.Fatal("filter mode wasn't restored:", fm, filtered)
+	}
+
+	// Disabling the filter should select all hosts again.
+	if err := hdbt.hdb.SetFilterMode(modules.HostDBFilterDisabled, keys); err != nil {
+		t.Fatal(err)
+	}
+	if fm, filtered := hdbt.hdb.FilterMode(); fm != modules.HostDBFilterDisabled || len(filtered) != 0 {
+		t.Fatal("filter wasn't disabled:", fm, filtered)
+	}
+	if host, ok := hdbt.hdb.Host(keys[1]); !ok || host.Filtered {
+		t.Fatal("host is still filtered after disabling the filter")
+	}
+}
@@ -2,6 +2,7 @@ package hostdb
 
 import (
 	"math"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
@@ -112,3 +113,61 @@ func (hdb *HostDB) IncrementFailedInteractions(key types.SiaPublicKey) {
 	host.RecentFailedInteractions++
 	hdb.hostTree.Modify(host)
 }
+
+// updatePerformance returns the moving average of a performance measurement
+// after a new sample was taken. The first sample replaces the average.
+func updatePerformance(average, sample float64) float64 {
+	if average == 0 {
+		return sample
+	}
+	return average*performanceDecay + sample*(1-performanceDecay)
+}
+
+// managedRecordPerformance applies fn to the host with the given key.
+func (hdb *HostDB) managedRecordPerformance(key types.SiaPublicKey, fn func(*modules.HostDBEntry)) {
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+
+	// Fetch the host.
+	host, haveHost := hdb.hostTree.Select(key)
+	if !haveHost {
+		return
+	}
+	fn(&host)
+	hdb.hostTree.Modify(host)
+}
+
+// RecordDownload records that size bytes were downloaded from the host with
+// the given key in the elapsed time.
+func (hdb *HostDB) RecordDownload(key types.SiaPublicKey, size uint64, elapsed time.Duration) {
+	if size < minThroughputSample || elapsed <= 0 {
+		return
+	}
+	throughput := float64(size) / elapsed.Seconds()
+	hdb.managedRecordPerformance(key, func(host *modules.HostDBEntry) {
+		host.DownloadThroughput = updatePerformance(host.DownloadThroughput, throughput)
+	})
+}
+
+// RecordUpload records that size bytes were uploaded to the host with the
+// given key in the elapsed time.
+func (hdb *HostDB) RecordUpload(key types.SiaPublicKey, size uint64, elapsed time.Duration) {
+	if size < minThroughputSample || elapsed <= 0 {
+		return
+	}
+	throughput := float64(size) / elapsed.Seconds()
+	hdb.managedRecordPerformance(key, func(host *modules.HostDBEntry) {
+		host.UploadThroughput = updatePerformance(host.UploadThroughput, throughput)
+	})
+}
+
+// RecordLatency records the duration of an RPC round trip with the host with
+// the given key.
+func (hdb *HostDB) RecordLatency(key types.SiaPublicKey, latency time.Duration) {
+	if latency <= 0 {
+		return
+	}
+	hdb.managedRecordPerformance(key, func(host *modules.HostDBEntry) {
+		host.Latency = time.Duration(updatePerformance(float64(host.Latency), float64(latency)))
+	})
+}
@@ -0,0 +1,66 @@
+package hosttree
+
+import (
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+type (
+	// RegionFunc returns the region of an IP subnet, or an empty string if the
+	// region is unknown.
+	RegionFunc func(ipNet string) string
+
+	// A Filter keeps track of the IP subnets and regions of the hosts that
+	// were added to it, and filters hosts that share a subnet or region with
+	// one of them. Hosts whose subnets or regions are unknown are never
+	// filtered.
+	Filter struct {
+		subnets map[string]struct{}
+		regions map[string]struct{}
+
+		checkSubnets bool
+		regionOf     RegionFunc
+	}
+)
+
+// NewFilter creates a new Filter. If checkSubnets is true, hosts that share an
+// IP subnet are filtered. If regionOf is not nil, hosts that share a region are
+// filtered.
+func NewFilter(checkSubnets bool, regionOf RegionFunc) *Filter {
+	return &Filter{
+		subnets: make(map[string]struct{}),
+		regions: make(map[string]struct{}),
+
+		checkSubnets: checkSubnets,
+		regionOf:     regionOf,
+	}
+}
+
+// Add adds the subnets and regions of the entry to the filter.
+func (f *Filter) Add(entry modules.HostDBEntry) {
+	for _, ipNet := range entry.IPNets {
+		if f.checkSubnets {
+			f.subnets[ipNet] = struct{}{}
+		}
+		if f.regionOf != nil {
+			if region := f.regionOf(ipNet); region != "" {
+				f.regions[region] = struct{}{}
+			}
+		}
+	}
+}
+
+// Filtered returns true if the entry shares a subnet or region with one of the
+// entries that were added to the filter.
+func (f *Filter) Filtered(entry modules.HostDBEntry) bool {
+	for _, ipNet := range entry.IPNets {
+		if _, exists := f.subnets[ipNet]; exists {
+			return true
+		}
+		if f.regionOf != nil {
+			if _, exists := f.regions[f.regionOf(ipNet)]; exists {
+				return true
+			}
+		}
+	}
+	return false
+}
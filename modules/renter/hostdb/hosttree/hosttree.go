@@ -279,7 +279,9 @@ func (ht *HostTree) Select(spk types.SiaPublicKey) (modules.HostDBEntry, bool) {
 // the length of the slice returned may be less than n, and may even be zero.
 // The hosts that are returned first have the higher priority. Hosts passed to
 // 'ignore' will not be considered; pass `nil` if no blacklist is desired.
-func (ht *HostTree) SelectRandom(n int, ignore []types.SiaPublicKey) []modules.HostDBEntry {
+// Hosts that are filtered by 'filter' are skipped as well, and every returned
+// host is added to the filter; pass `nil` if no diversity is desired.
+func (ht *HostTree) SelectRandom(n int, ignore []types.SiaPublicKey, filter *Filter) []modules.HostDBEntry {
 	ht.mu.Lock()
 	defer ht.mu.Unlock()
 
@@ -302,10 +304,16 @@ func (ht *HostTree) SelectRandom(n int, ignore []types.SiaPublicKey) []modules.H
 
 		if node.entry.AcceptingContracts &&
 			len(node.entry.ScanHistory) > 0 &&
-			node.entry.ScanHistory[len(node.entry.ScanHistory)-1].Success {
+			node.entry.ScanHistory[len(node.entry.ScanHistory)-1].Success &&
+			(filter == nil || !filter.Filtered(node.entry.HostDBEntry)) {
 			// The host must be online and accepting contracts to be returned
-			// by the random function.
+			// by the random function. If there is a filter, the host also
+			// mustn't share a subnet or region with a host that was already
+			// selected.
 			hosts = append(hosts, node.entry.HostDBEntry)
+			if filter != nil {
+				filter.Add(node.entry.HostDBEntry)
+			}
 		}
 
 		removedEntries = append(removedEntries, node.entry)
@@ -35,7 +35,7 @@ func verifyTree(tree *HostTree, nentries int) error {
 		selectionMap := make(map[string]int)
 		expected := 100
 		for i := 0; i < expected*nentries; i++ {
-			entries := tree.SelectRandom(1, nil)
+			entries := tree.SelectRandom(1, nil, nil)
 			if len(entries) == 0 {
 				return errors.New("no hosts")
 			}
@@ -222,7 +222,7 @@ func TestHostTreeParallel(t *testing.T) {
 
 					// FETCH
 					case 3:
-						tree.SelectRandom(3, nil)
+						tree.SelectRandom(3, nil, nil)
 					}
 				}
 			}
@@ -310,7 +310,7 @@ func TestVariedWeights(t *testing.T) {
 	// time.
 	selectionMap := make(map[string]int)
 	for i := 0; i < selections; i++ {
-		randEntry := tree.SelectRandom(1, nil)
+		randEntry := tree.SelectRandom(1, nil, nil)
 		if len(randEntry) == 0 {
 			t.Fatal("no hosts!")
 		}
@@ -389,7 +389,7 @@ func TestRandomHosts(t *testing.T) {
 	})
 
 	// Empty.
-	hosts := tree.SelectRandom(1, nil)
+	hosts := tree.SelectRandom(1, nil, nil)
 	if len(hosts) != 0 {
 		t.Errorf("empty hostdb returns %v hosts: %v", len(hosts), hosts)
 	}
@@ -418,13 +418,13 @@ func TestRandomHosts(t *testing.T) {
 	}
 
 	// Grab 1 random host.
-	randHosts := tree.SelectRandom(1, nil)
+	randHosts := tree.SelectRandom(1, nil, nil)
 	if len(randHosts) != 1 {
 		t.Error("didn't get 1 hosts")
 	}
 
 	// Grab 2 random hosts.
-	randHosts = tree.SelectRandom(2, nil)
+	randHosts = tree.SelectRandom(2, nil, nil)
 	if len(randHosts) != 2 {
 		t.Error("didn't get 2 hosts")
 	}
@@ -433,7 +433,7 @@ func TestRandomHosts(t *testing.T) {
 	}
 
 	// Grab 3 random hosts.
-	randHosts = tree.SelectRandom(3, nil)
+	randHosts = tree.SelectRandom(3, nil, nil)
 	if len(randHosts) != 3 {
 		t.Error("didn't get 3 hosts")
 	}
@@ -443,7 +443,7 @@ func TestRandomHosts(t *testing.T) {
 	}
 
 	// Grab 4 random hosts. 3 should be returned.
-	randHosts = tree.SelectRandom(4, nil)
+	randHosts = tree.SelectRandom(4, nil, nil)
 	if len(randHosts) != 3 {
 		t.Error("didn't get 3 hosts")
 	}
@@ -458,13 +458,13 @@ func TestRandomHosts(t *testing.T) {
 		randHosts[0].PublicKey,
 		randHosts[1].PublicKey,
 		randHosts[2].PublicKey,
-	})
+	}, nil)
 	if len(uniqueHosts) != 0 {
 		t.Error("didn't get 0 hosts")
 	}
 
 	// Ask for 3 hosts, blacklisting non-existent hosts. 3 should be returned.
-	randHosts = tree.SelectRandom(3, []types.SiaPublicKey{{}, {}, {}})
+	randHosts = tree.SelectRandom(3, []types.SiaPublicKey{{}, {}, {}}, nil)
 	if len(randHosts) != 3 {
 		t.Error("didn't get 3 hosts")
 	}
@@ -473,3 +473,59 @@ func TestRandomHosts(t *testing.T) {
 		t.Error("doubled up")
 	}
 }
+
+// TestSelectRandomFilter checks that SelectRandom never returns two hosts that
+// share a subnet or region when a filter is used.
+func TestSelectRandomFilter(t *testing.T) {
+	tree := New(func(dbe modules.HostDBEntry) types.Currency {
+		return types.NewCurrency64(1)
+	})
+	regions := map[string]string{
+		"1.1.1.0/24": "eu",
+		"2.2.2.0/24": "eu",
+		"3.3.3.0/24": "us",
+	}
+	for _, ipNet := range []string{"1.1.1.0/24", "1.1.1.0/24", "2.2.2.0/24", "3.3.3.0/24"} {
+		entry := makeHostDBEntry()
+		entry.IPNets = []string{ipNet}
+		if err := tree.Insert(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A host with an unknown subnet is never filtered.
+	if err := tree.Insert(makeHostDBEntry()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a filter all hosts are returned.
+	if hosts := tree.SelectRandom(5, nil, nil); len(hosts) != 5 {
+		t.Fatal("expected 5 hosts, got", len(hosts))
+	}
+
+	// With subnet diversity one of the hosts in 1.1.1.0/24 is skipped.
+	for i := 0; i < 10; i++ {
+		hosts := tree.SelectRandom(5, nil, NewFilter(true, nil))
+		if len(hosts) != 4 {
+			t.Fatal("expected 4 hosts, got", len(hosts))
+		}
+	}
+
+	// With region diversity only one host per region and the unknown host
+	// are returned.
+	regionOf := func(ipNet string) string { return regions[ipNet] }
+	for i := 0; i < 10; i++ {
+		hosts := tree.SelectRandom(5, nil, NewFilter(true, regionOf))
+		if len(hosts) != 3 {
+			t.Fatal("expected 3 hosts, got", len(hosts))
+		}
+	}
+
+	// Hosts that were added to the filter beforehand are respected.
+	filter := NewFilter(true, nil)
+	filter.Add(modules.HostDBEntry{IPNets: []string{"3.3.3.0/24", "2.2.2.0/24"}})
+	for _, host := range tree.SelectRandom(5, nil, filter) {
+		if len(host.IPNets) > 0 && host.IPNets[0] != "1.1.1.0/24" {
+			t.Fatal("filtered host was selected:", host.IPNets)
+		}
+	}
+}
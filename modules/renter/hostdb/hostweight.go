@@ -3,6 +3,7 @@ package hostdb
 import (
 	"math"
 	"math/big"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
@@ -18,6 +19,14 @@ var (
 	// during collateral adjustment.
 	collateralExponentiation = 0.75
 
+	// latencyTarget is the RPC latency below which hosts are not penalized
+	// for their latency.
+	latencyTarget = build.Select(build.Var{
+		Standard: 500 * time.Millisecond,
+		Dev:      500 * time.Millisecond,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+
 	// minCollateral is the amount of collateral we weight all hosts as having,
 	// even if they do not have any collateral. This is to temporarily prop up
 	// weak / cheap hosts on the network while the network is bootstrapping.
@@ -49,6 +58,14 @@ var (
 		Testing:  uint64(1e3),
 	}).(uint64)
 
+	// throughputTarget is the throughput in bytes per second above which
+	// hosts are not penalized for their upload and download throughput.
+	throughputTarget = build.Select(build.Var{
+		Standard: float64(1 << 20),
+		Dev:      float64(1 << 20),
+		Testing:  float64(1 << 10),
+	}).(float64)
+
 	// tbMonth is the number of bytes in a terabyte times the number of blocks
 	// in a month.
 	tbMonth = uint64(4032) * uint64(1e12)
@@ -127,6 +144,24 @@ func (hdb *HostDB) interactionAdjustments(entry modules.HostDBEntry) float64 {
 	return math.Pow(ratio, 15)
 }
 
+// performanceAdjustments penalizes the host for slow uploads and downloads and
+// for high RPC latency, as measured during the renter's sessions with the
+// host. Hosts that haven't been measured yet are not penalized.
+func performanceAdjustments(entry modules.HostDBEntry) float64 {
+	// The penalty grows with the square root of the distance to the target,
+	// so that a host that is 4x slower than the target gets half the weight.
+	weight := 1.0
+	for _, throughput := range []float64{entry.DownloadThroughput, entry.UploadThroughput} {
+		if throughput > 0 && throughput < throughputTarget {
+			weight *= math.Sqrt(throughput / throughputTarget)
+		}
+	}
+	if entry.Latency > latencyTarget {
+		weight *= math.Sqrt(float64(latencyTarget) / float64(entry.Latency))
+	}
+	return weight
+}
+
 // priceAdjustments will adjust the weight of the entry according to the prices
 // that it has set.
 func (hdb *HostDB) priceAdjustments(entry modules.HostDBEntry) float64 {
@@ -369,6 +404,7 @@ func (hdb *HostDB) calculateHostWeight(entry modules.HostDBEntry) types.Currency
 	collateralReward := hdb.collateralAdjustments(entry)
 	interactionPenalty := hdb.interactionAdjustments(entry)
 	lifetimePenalty := hdb.lifetimeAdjustments(entry)
+	performancePenalty := performanceAdjustments(entry)
 	pricePenalty := hdb.priceAdjustments(entry)
 	storageRemainingPenalty := storageRemainingAdjustments(entry)
 	uptimePenalty := hdb.uptimeAdjustments(entry)
@@ -376,7 +412,8 @@ func (hdb *HostDB) calculateHostWeight(entry modules.HostDBEntry) types.Currency
 
 	// Combine the adjustments.
 	fullPenalty := collateralReward * interactionPenalty * lifetimePenalty *
-		pricePenalty * storageRemainingPenalty * uptimePenalty * versionPenalty
+		performancePenalty * pricePenalty * storageRemainingPenalty *
+		uptimePenalty * versionPenalty
 
 	// Return a types.Currency.
 	weight := baseWeight.MulFloat(fullPenalty)
@@ -392,7 +429,7 @@ func (hdb *HostDB) calculateHostWeight(entry modules.HostDBEntry) types.Currency
 // percentage of contracts it is likely to participate in.
 func (hdb *HostDB) calculateConversionRate(score types.Currency) float64 {
 	var totalScore types.Currency
-	for _, h := range hdb.ActiveHosts() {
+	for _, h := range hdb.activeHosts() {
 		totalScore = totalScore.Add(hdb.calculateHostWeight(h))
 	}
 	if totalScore.IsZero() {
@@ -408,8 +445,8 @@ func (hdb *HostDB) calculateConversionRate(score types.Currency) float64 {
 // EstimateHostScore takes a HostExternalSettings and returns the estimated
 // score of that host in the hostdb, assuming no penalties for age or uptime.
 func (hdb *HostDB) EstimateHostScore(entry modules.HostDBEntry) modules.HostScoreBreakdown {
-	// Grab the adjustments. Age, and uptime penalties are set to '1', to
-	// assume best behavior from the host.
+	// Grab the adjustments. Age, performance and uptime penalties are set to
+	// '1', to assume best behavior from the host.
 	collateralReward := hdb.collateralAdjustments(entry)
 	pricePenalty := hdb.priceAdjustments(entry)
 	storageRemainingPenalty := storageRemainingAdjustments(entry)
@@ -431,6 +468,7 @@ func (hdb *HostDB) EstimateHostScore(entry modules.HostDBEntry) modules.HostScor
 		AgeAdjustment:              1,
 		BurnAdjustment:             1,
 		CollateralAdjustment:       collateralReward,
+		PerformanceAdjustment:      1,
 		PriceAdjustment:            pricePenalty,
 		StorageRemainingAdjustment: storageRemainingPenalty,
 		UptimeAdjustment:           1,
@@ -453,6 +491,7 @@ func (hdb *HostDB) ScoreBreakdown(entry modules.HostDBEntry) modules.HostScoreBr
 		BurnAdjustment:             1,
 		CollateralAdjustment:       hdb.collateralAdjustments(entry),
 		InteractionAdjustment:      hdb.interactionAdjustments(entry),
+		PerformanceAdjustment:      performanceAdjustments(entry),
 		PriceAdjustment:            hdb.priceAdjustments(entry),
 		StorageRemainingAdjustment: storageRemainingAdjustments(entry),
 		UptimeAdjustment:           hdb.uptimeAdjustments(entry),
@@ -286,3 +286,37 @@ func TestHostWeightUptimeDifferences4(t *testing.T) {
 		t.Error("Been around longer should have more weight")
 	}
 }
+
+func TestHostWeightPerformanceDifferences(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	hdb := bareHostDB()
+	var entry modules.HostDBEntry
+	entry.Version = build.Version
+	entry.RemainingStorage = 250e3
+	entry.StoragePrice = types.NewCurrency64(300).Mul(types.SiacoinPrecision).Div64(4032).Div64(1e9)
+
+	// Hosts that are faster than the targets aren't penalized.
+	fast := entry
+	fast.DownloadThroughput = throughputTarget * 2
+	fast.UploadThroughput = throughputTarget
+	fast.Latency = latencyTarget / 2
+	if hdb.calculateHostWeight(fast).Cmp(hdb.calculateHostWeight(entry)) != 0 {
+		t.Error("Fast host should have the same weight as an unmeasured host")
+	}
+
+	slow := fast
+	slow.DownloadThroughput = throughputTarget / 4
+	if adj := performanceAdjustments(slow); adj != 0.5 {
+		t.Error("4x slower host should have half the weight, got", adj)
+	}
+	laggy := fast
+	laggy.Latency = latencyTarget * 4
+	w1 := hdb.calculateHostWeight(fast)
+	w2 := hdb.calculateHostWeight(slow)
+	w3 := hdb.calculateHostWeight(laggy)
+	if w1.Cmp(w2) <= 0 || w1.Cmp(w3) <= 0 {
+		t.Error("Faster host should have more weight")
+	}
+}
@@ -24,15 +24,21 @@ var (
 
 // hdbPersist defines what HostDB data persists across sessions.
 type hdbPersist struct {
-	AllHosts    []modules.HostDBEntry
-	BlockHeight types.BlockHeight
-	LastChange  modules.ConsensusChangeID
+	AllHosts      []modules.HostDBEntry
+	BlockHeight   types.BlockHeight
+	FilterMode    modules.FilterMode
+	FilteredHosts []types.SiaPublicKey
+	LastChange    modules.ConsensusChangeID
 }
 
 // persistData returns the data in the hostdb that will be saved to disk.
 func (hdb *HostDB) persistData() (data hdbPersist) {
 	data.AllHosts = hdb.hostTree.All()
 	data.BlockHeight = hdb.blockHeight
+	data.FilterMode = hdb.filterMode
+	for _, spk := range hdb.filteredHosts {
+		data.FilteredHosts = append(data.FilteredHosts, spk)
+	}
 	data.LastChange = hdb.lastChange
 	return data
 }
@@ -53,6 +59,10 @@ func (hdb *HostDB) load() error {
 
 	// Set the hostdb internal values.
 	hdb.blockHeight = data.BlockHeight
+	hdb.filterMode = data.FilterMode
+	for _, spk := range data.FilteredHosts {
+		hdb.filteredHosts[spk.String()] = spk
+	}
 	hdb.lastChange = data.LastChange
 
 	// Load each of the hosts into the host tree.
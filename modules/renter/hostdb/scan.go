@@ -144,6 +144,7 @@ func (hdb *HostDB) updateEntry(entry modules.HostDBEntry, netErr error) {
 	newEntry, exists := hdb.hostTree.Select(entry.PublicKey)
 	if exists {
 		newEntry.HostExternalSettings = entry.HostExternalSettings
+		newEntry.IPNets = entry.IPNets
 	} else {
 		newEntry = entry
 	}
@@ -255,6 +256,14 @@ func (hdb *HostDB) managedScanHost(entry modules.HostDBEntry) {
 	updateHostHistoricInteractions(&entry, hdb.blockHeight)
 	hdb.mu.RUnlock()
 
+	// Resolve the subnets of the host for the diversity policy. If the lookup
+	// fails the previous subnets are kept.
+	if nets, err := lookupIPNets(netAddr); err != nil {
+		hdb.log.Debugf("Unable to resolve host at %v: %v", netAddr, err)
+	} else {
+		entry.IPNets = nets
+	}
+
 	var settings modules.HostExternalSettings
 	var latency time.Duration
 	err := func() error {
@@ -9,6 +9,7 @@ import (
 	"sync"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
 )
 
 // memoryManager can handle requests for memory and returns of memory. The
@@ -21,20 +22,37 @@ import (
 // block until all memory is available, and then grant the request, blocking all
 // future requests for memory until the memory is returned. This allows large
 // requests to go through even if there is not enough base memory.
+//
+// Blocked requests are granted in order of their priority, and requests of the
+// same priority are granted in the order in which they were made.
 type memoryManager struct {
-	available    uint64
-	base         uint64
-	fifo         []*memoryRequest
-	priorityFifo []*memoryRequest
-	mu           sync.Mutex
-	stop         <-chan struct{}
-	underflow    uint64
+	available uint64
+	base      uint64
+	fifo      []*memoryRequest // Sorted by priority, highest first.
+	mu        sync.Mutex
+	stop      <-chan struct{}
+	underflow uint64
 }
 
+// memoryPriority is the priority of a memory request.
+type memoryPriority int
+
 // memoryRequest is a single thread that is blocked while waiting for memory.
 type memoryRequest struct {
-	amount uint64
-	done   chan struct{}
+	amount   uint64
+	done     chan struct{}
+	priority memoryPriority
+}
+
+// newMemoryPriority returns the priority of a memory request for a job of the
+// priority class p. The priority class always takes precedence, interactive
+// requests are only preferred over background requests of the same class.
+func newMemoryPriority(p modules.Priority, interactive bool) memoryPriority {
+	mp := 2 * memoryPriority(p)
+	if interactive {
+		mp++
+	}
+	return mp
 }
 
 // try will try to get the amount of memory requested from the manger, returning
@@ -64,7 +82,7 @@ func (mm *memoryManager) try(amount uint64) bool {
 // Request is a blocking request for memory. The request will return when the
 // memory has been acquired. If 'false' is returned, it means that the renter
 // shut down before the memory could be allocated.
-func (mm *memoryManager) Request(amount uint64, priority bool) bool {
+func (mm *memoryManager) Request(amount uint64, priority memoryPriority) bool {
 	// Try to request the memory.
 	mm.mu.Lock()
 	if len(mm.fifo) == 0 && mm.try(amount) {
@@ -72,16 +90,20 @@ func (mm *memoryManager) Request(amount uint64, priority bool) bool {
 		return true
 	}
 
-	// There is not enough memory available for this request, join the fifo.
+	// There is not enough memory available for this request, join the fifo
+	// behind all requests of the same or a higher priority.
 	myRequest := &memoryRequest{
-		amount: amount,
-		done:   make(chan struct{}),
+		amount:   amount,
+		done:     make(chan struct{}),
+		priority: priority,
 	}
-	if priority {
-		mm.priorityFifo = append(mm.priorityFifo, myRequest)
-	} else {
-		mm.fifo = append(mm.fifo, myRequest)
+	i := len(mm.fifo)
+	for i > 0 && mm.fifo[i-1].priority < priority {
+		i--
 	}
+	mm.fifo = append(mm.fifo, nil)
+	copy(mm.fifo[i+1:], mm.fifo[i:])
+	mm.fifo[i] = myRequest
 	mm.mu.Unlock()
 
 	// Block until memory is available or until shutdown. The thread that closes
@@ -121,19 +143,6 @@ func (mm *memoryManager) Return(amount uint64) {
 		mm.available = mm.base
 	}
 
-	// Release as many of the priority threads blocking in the fifo as possible.
-	for len(mm.priorityFifo) > 0 {
-		if !mm.try(mm.priorityFifo[0].amount) {
-			// There is not enough memory to grant the next request, meaning no
-			// future requests should be checked either.
-			return
-		}
-		// There is enough memory to grant the next request. Unblock that
-		// request and continue checking the next requests.
-		close(mm.priorityFifo[0].done)
-		mm.priorityFifo = mm.priorityFifo[1:]
-	}
-
 	// Release as many of the threads blocking in the fifo as possible.
 	for len(mm.fifo) > 0 {
 		if !mm.try(mm.fifo[0].amount) {
@@ -0,0 +1,66 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestMemoryManagerPriority checks that blocked memory requests are granted in
+// order of their priority.
+func TestMemoryManagerPriority(t *testing.T) {
+	// The priority class takes precedence over interactivity.
+	low := newMemoryPriority(modules.PriorityLow, memoryPriorityHigh)
+	normalBackground := newMemoryPriority(modules.PriorityNormal, memoryPriorityLow)
+	normal := newMemoryPriority(modules.PriorityNormal, memoryPriorityHigh)
+	high := newMemoryPriority(modules.PriorityHigh, memoryPriorityLow)
+	if !(low < normalBackground && normalBackground < normal && normal < high) {
+		t.Fatal("memory priorities are not ordered correctly:", low, normalBackground, normal, high)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	mm := newMemoryManager(100, stop)
+	if !mm.Request(100, normal) {
+		t.Fatal("unable to request memory")
+	}
+
+	// Queue requests of different priorities while all memory is in use.
+	// Requests of the same priority are granted in the order in which they
+	// were made.
+	requests := []memoryPriority{low, normal, high, normalBackground, normal}
+	granted := make(chan int, len(requests))
+	for i, p := range requests {
+		go func(i int, p memoryPriority) {
+			if mm.Request(50, p) {
+				granted <- i
+			}
+		}(i, p)
+		// Wait until the request has joined the queue.
+		for start := time.Now(); ; time.Sleep(time.Millisecond) {
+			mm.mu.Lock()
+			queued := len(mm.fifo)
+			mm.mu.Unlock()
+			if queued == i+1 {
+				break
+			}
+			if time.Since(start) > 10*time.Second {
+				t.Fatal("request didn't join the queue")
+			}
+		}
+	}
+
+	// Release the memory one request at a time.
+	for _, expected := range []int{2, 1, 4, 3, 0} {
+		mm.Return(50)
+		select {
+		case i := <-granted:
+			if i != expected {
+				t.Fatalf("expected request %v to be granted, got %v", expected, i)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("request wasn't granted")
+		}
+	}
+}
@@ -0,0 +1,616 @@
+package renter
+
+// pack.go packs small files into shared sectors.
+//
+// Every piece of a regular file fills a whole sector, so a small file would
+// cost a full sector per piece. Instead, small files are erasure coded into
+// pieces that are only as large as necessary, and the pieces of many small
+// files are packed into a group of shared sectors called a pack. Sector i of a
+// pack contains piece i of every file in the pack, and every file occupies the
+// same slot, given by an offset and a length, in each of the sectors. Downloads
+// of packed files only fetch the slot of each piece from the hosts.
+//
+// Small files are packed by the repair loop. A pack is uploaded once enough
+// small files are waiting to fill it, or once the oldest waiting file has
+// waited for packDelay. A packed file that lost too many pieces is repaired by
+// packing it again, which turns its old slot into garbage. Garbage is also
+// created when a packed file is deleted. The sectors of a pack are paid for
+// until their contracts expire, so the renter keeps track of the garbage in
+// every pack.
+//
+// The packs and the slots of the packed files are persisted in the packs file.
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/errors"
+)
+
+const (
+	// PacksFilename is the filename of the file that contains the packs of
+	// the renter and the slots of the packed files.
+	PacksFilename = "packs.json"
+)
+
+var (
+	packsMetadata = persist.Metadata{
+		Header:  "Renter Packs",
+		Version: "1.0",
+	}
+
+	// maxPackedPieceSize is the largest piece size of a packed file. Files
+	// are packed if each of their encrypted pieces fits into a quarter of a
+	// sector.
+	maxPackedPieceSize = modules.SectorSize/4 - crypto.TwofishOverhead
+
+	// packDelay is how long a small file waits for other small files before
+	// its pack is uploaded, unless enough files are waiting to fill a pack.
+	packDelay = build.Select(build.Var{
+		Dev:      10 * time.Second,
+		Standard: time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
+)
+
+type (
+	// pack is a group of sectors shared by many small files. The sizes are
+	// the number of bytes used in each of the sectors.
+	pack struct {
+		ID        string                 `json:"id"`
+		Contracts []types.FileContractID `json:"contracts"` // contracts storing the sectors of the pack
+		Files     int                    `json:"files"`     // number of files stored in the pack
+		LiveSize  uint64                 `json:"livesize"`  // bytes used by the files stored in the pack
+		Size      uint64                 `json:"size"`      // bytes used by all files that were ever stored in the pack
+	}
+
+	// packSlot is the location of a packed file within its pack. Piece i of
+	// the file is stored at the same offset in sector i of the pack.
+	packSlot struct {
+		Pack   string `json:"pack"`
+		Offset uint64 `json:"offset"`
+		Length uint64 `json:"length"` // length of an encrypted piece
+	}
+
+	// packedFile is the persisted slot of a single packed file.
+	packedFile struct {
+		SiaPath string   `json:"siapath"`
+		Slot    packSlot `json:"slot"`
+	}
+
+	// packsPersist is the object persisted in the packs file.
+	packsPersist struct {
+		Packs []pack       `json:"packs"`
+		Files []packedFile `json:"files"`
+	}
+
+	// packMember is a file that is being added to a pack, along with its
+	// offset in the pack's sectors.
+	packMember struct {
+		f      *file
+		offset uint64
+	}
+
+	// packKey groups small files that can share a pack. The sectors of a
+	// pack hold one piece index each, so all files in a pack need to have the
+	// same number of pieces.
+	packKey struct {
+		id        types.Specifier
+		minPieces int
+		numPieces int
+	}
+)
+
+// packedPieceSize returns the size of the pieces of a small file of the given
+// size. Every piece is at least one byte large.
+func packedPieceSize(size uint64, ec modules.ErasureCoder) uint64 {
+	minPieces := uint64(ec.MinPieces())
+	ps := (size + minPieces - 1) / minPieces
+	if ps == 0 {
+		ps = 1
+	}
+	return ps
+}
+
+// packable returns whether a file of the given size should be packed.
+func packable(size uint64, ec modules.ErasureCoder) bool {
+	return packedPieceSize(size, ec) <= maxPackedPieceSize
+}
+
+// packed returns whether f is a small file that is stored in a pack instead of
+// its own sectors.
+func (f *file) packed() bool {
+	return f.pieceSize <= maxPackedPieceSize
+}
+
+// slotLength returns the number of bytes that a piece of f occupies on a host.
+// That is the length of an encrypted piece for packed files, and a full sector
+// otherwise.
+func (f *file) slotLength() uint64 {
+	if !f.packed() {
+		return modules.SectorSize
+	}
+	return f.pieceSize + f.cipherType.Overhead()
+}
+
+// encodePackedPieces erasure codes and encrypts the data of a small file. data
+// must be padded to the chunk size of the file.
+func encodePackedPieces(f *file, data []byte) ([][]byte, error) {
+	pieces, err := f.erasureCode.Encode(data)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pieces {
+		key := deriveKey(f.cipherType, f.masterKey, 0, uint64(i))
+		pieces[i] = key.EncryptBytes(pieces[i])
+	}
+	return pieces, nil
+}
+
+// removeFromPack releases the slot of a packed file, turning its data into
+// garbage. The caller must hold the renter lock and the file lock.
+func (r *Renter) removeFromPack(f *file) {
+	if f.packSlot == nil {
+		return
+	}
+	if p, exists := r.packs[f.packSlot.Pack]; exists {
+		p.Files--
+		p.LiveSize -= f.packSlot.Length
+	}
+	f.packSlot = nil
+}
+
+// needsPacking returns whether the small file f has to be packed. That is the
+// case if it hasn't been packed yet, or if it has lost too many pieces and
+// enough workers are available to restore all of its pieces. The caller must
+// hold the renter lock and the file lock.
+func (r *Renter) needsPacking(f *file, numWorkers int) bool {
+	trackedFile, exists := r.persist.Tracking[f.name]
+	if !exists || trackedFile.Streaming || !f.packed() {
+		return false
+	}
+	if f.packSlot == nil {
+		return numWorkers >= f.erasureCode.MinPieces()
+	}
+	if numWorkers < f.erasureCode.NumPieces() {
+		return false
+	}
+
+	// Count the pieces that are stored on hosts that are good for renew.
+	pieces := make(map[uint64]struct{})
+	for fcid, fc := range f.contracts {
+		utility, exists := r.hostContractor.ContractUtility(fcid)
+		if !exists || !utility.GoodForRenew {
+			continue
+		}
+		for _, p := range fc.Pieces {
+			pieces[p.Piece] = struct{}{}
+		}
+	}
+	// Like regular files, packed files that are not available locally are
+	// only repaired if a substantial part of their redundancy is missing.
+	missing := f.erasureCode.NumPieces() - len(pieces)
+	if _, err := os.Stat(trackedFile.RepairPath); err == nil {
+		return missing > 0
+	}
+	numParityPieces := float64(f.erasureCode.NumPieces() - f.erasureCode.MinPieces())
+	return missing > int(numParityPieces*RemoteRepairDownloadThreshold)
+}
+
+// managedQueuePackFile adds a small file that needs to be packed to the pack
+// queue.
+func (r *Renter) managedQueuePackFile(f *file) {
+	r.packMu.Lock()
+	defer r.packMu.Unlock()
+	if _, active := r.packsActive[f]; active {
+		return
+	}
+	if _, queued := r.packQueue[f]; !queued {
+		r.packQueue[f] = time.Now()
+	}
+}
+
+// managedPackFiles uploads the packs of the small files in the pack queue that
+// are ready to be packed. It returns a channel that fires once the next waiting
+// files are due, or nil if no files are waiting.
+func (r *Renter) managedPackFiles() <-chan time.Time {
+	r.managedPrunePacks()
+
+	// Group the waiting files by their erasure code.
+	r.packMu.Lock()
+	groups := make(map[packKey][]*file)
+	for f := range r.packQueue {
+		f.mu.RLock()
+		deleted := f.deleted
+		f.mu.RUnlock()
+		if deleted {
+			delete(r.packQueue, f)
+			continue
+		}
+		key := packKey{
+			id:        f.erasureCode.Identifier(),
+			minPieces: f.erasureCode.MinPieces(),
+			numPieces: f.erasureCode.NumPieces(),
+		}
+		groups[key] = append(groups[key], f)
+	}
+
+	// A group is packed once it fills a pack or once its oldest file has
+	// waited long enough.
+	var nextPack time.Duration
+	var ready [][]*file
+	for _, files := range groups {
+		sort.Slice(files, func(i, j int) bool {
+			return r.packQueue[files[i]].Before(r.packQueue[files[j]])
+		})
+		var size uint64
+		for _, f := range files {
+			size += f.slotLength()
+		}
+		wait := packDelay - time.Since(r.packQueue[files[0]])
+		if size < modules.SectorSize && wait > 0 {
+			if nextPack == 0 || wait < nextPack {
+				nextPack = wait
+			}
+			continue
+		}
+		for _, f := range files {
+			delete(r.packQueue, f)
+			r.packsActive[f] = struct{}{}
+		}
+		ready = append(ready, files)
+	}
+	r.packMu.Unlock()
+
+	// Split the groups into packs and upload them in the background.
+	for _, files := range ready {
+		var members []packMember
+		var size uint64
+		for _, f := range files {
+			if size+f.slotLength() > modules.SectorSize {
+				go r.threadedUploadPack(members)
+				members, size = nil, 0
+			}
+			members = append(members, packMember{f: f, offset: size})
+			size += f.slotLength()
+		}
+		go r.threadedUploadPack(members)
+	}
+	if nextPack == 0 {
+		return nil
+	}
+	return time.After(nextPack)
+}
+
+// managedPackData returns the data of a small file, padded to its chunk size.
+// The data is read from disk if possible and downloaded otherwise.
+func (r *Renter) managedPackData(f *file) ([]byte, error) {
+	id := r.mu.RLock()
+	localPath := r.persist.Tracking[f.name].RepairPath
+	r.mu.RUnlock(id)
+	data := make([]byte, f.staticChunkSize())
+	if f.size == 0 {
+		return data, nil
+	}
+
+	// Try to read the data from disk.
+	osFile, err := os.Open(localPath)
+	if err == nil {
+		_, err = io.ReadFull(osFile, data[:f.size])
+		osFile.Close()
+		if err == nil {
+			return data, nil
+		}
+	}
+
+	// Download the data instead.
+	buf := NewDownloadDestinationBuffer(f.size, f.pieceSize)
+	d, err := r.managedNewDownload(downloadParams{
+		destination:     buf,
+		destinationType: "buffer",
+		file:            f,
+
+		latencyTarget: 200e3, // No need to rush latency on repair downloads.
+		length:        f.size,
+		needsMemory:   true,
+		offset:        0,
+		overdrive:     0, // No need to rush the latency on repair downloads.
+		priority:      0, // Repair downloads are completely de-prioritized.
+	})
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-d.completeChan:
+	case <-r.tg.StopChan():
+		return nil, errors.New("repair download interrupted by stop call")
+	}
+	if d.Err() != nil {
+		return nil, d.Err()
+	}
+	off := data[:f.size]
+	for _, shard := range buf {
+		off = off[copy(off, shard):]
+	}
+	return data, nil
+}
+
+// threadedUploadPack builds the sectors of a pack from the small files in
+// members and uploads them to the hosts. Files that can't be packed stay in
+// need of packing and are queued again by the repair loop.
+func (r *Renter) threadedUploadPack(members []packMember) {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+	defer func() {
+		r.packMu.Lock()
+		for _, m := range members {
+			delete(r.packsActive, m.f)
+		}
+		r.packMu.Unlock()
+	}()
+	ec := members[0].f.erasureCode
+
+	// Pick a worker for every piece. Each worker uploads a sector to a
+	// different host.
+	id := r.mu.RLock()
+	workers := make([]*worker, 0, ec.NumPieces())
+	for _, w := range r.workerPool {
+		if len(workers) == ec.NumPieces() {
+			break
+		}
+		utility, exists := r.hostContractor.ContractUtility(w.contract.ID)
+		w.mu.Lock()
+		onCooldown := w.onUploadCooldown()
+		w.mu.Unlock()
+		if exists && utility.GoodForUpload && !onCooldown {
+			workers = append(workers, w)
+		}
+	}
+	r.mu.RUnlock(id)
+	if len(workers) < ec.MinPieces() {
+		r.log.Debugln("not enough workers to upload a pack:", len(workers))
+		return
+	}
+
+	// Encode the files. The pieces of a file are only as large as its slot,
+	// so all pieces of a pack are at most as large as its sectors.
+	var packed []packMember
+	var pieces [][][]byte
+	for _, m := range members {
+		data, err := r.managedPackData(m.f)
+		if err != nil {
+			r.log.Debugln("unable to fetch the data of a small file:", err)
+			continue
+		}
+		filePieces, err := encodePackedPieces(m.f, data)
+		if err != nil {
+			r.log.Debugln("unable to encode a small file:", err)
+			continue
+		}
+		packed = append(packed, m)
+		pieces = append(pieces, filePieces)
+	}
+	if len(packed) == 0 {
+		return
+	}
+
+	// Build the sectors.
+	memory := uint64(len(workers)) * modules.SectorSize
+	if !r.memoryManager.Request(memory, newMemoryPriority(modules.PriorityNormal, memoryPriorityLow)) {
+		return
+	}
+	defer r.memoryManager.Return(memory)
+	sectors := make([][]byte, len(workers))
+	for i := range sectors {
+		sectors[i] = make([]byte, modules.SectorSize)
+		for j, m := range packed {
+			copy(sectors[i][m.offset:], pieces[j][i])
+		}
+	}
+	last := packed[len(packed)-1]
+	size := last.offset + last.f.slotLength()
+
+	// Upload the sectors in parallel.
+	resultChans := make([]<-chan packSectorResult, len(workers))
+	for i, w := range workers {
+		resultChans[i] = w.managedQueuePackSector(sectors[i])
+	}
+	contracts := make([]fileContract, len(workers))
+	roots := make([]crypto.Hash, len(workers))
+	errs := make([]error, len(workers))
+	var spending modules.DataSpending
+	for i := range workers {
+		result := <-resultChans[i]
+		contracts[i], roots[i], errs[i] = result.contract, result.root, result.err
+		spending = spending.Add(result.spending)
+	}
+	r.managedRecordPackSpending(packed, spending)
+	var uploaded int
+	for _, err := range errs {
+		if err == nil {
+			uploaded++
+		} else {
+			r.log.Debugln("unable to upload a sector of a pack:", err)
+		}
+	}
+	if uploaded < ec.MinPieces() {
+		r.log.Println("WARN: unable to upload enough sectors of a pack:", uploaded)
+		return
+	}
+
+	// Store the files in the new pack. The old slots of repacked files become
+	// garbage.
+	p := &pack{
+		ID:   persist.RandomSuffix(),
+		Size: size,
+	}
+	for i := range workers {
+		if errs[i] == nil {
+			p.Contracts = append(p.Contracts, contracts[i].ID)
+		}
+	}
+	id = r.mu.Lock()
+	defer r.mu.Unlock(id)
+	var saved []*file
+	for _, m := range packed {
+		m.f.mu.Lock()
+		if m.f.deleted {
+			m.f.mu.Unlock()
+			continue
+		}
+		r.removeFromPack(m.f)
+		m.f.contracts = make(map[types.FileContractID]fileContract)
+		for i := range workers {
+			if errs[i] != nil {
+				continue
+			}
+			fc := contracts[i]
+			fc.Pieces = []pieceData{{
+				Chunk:      0,
+				Piece:      uint64(i),
+				MerkleRoot: roots[i],
+			}}
+			m.f.contracts[fc.ID] = fc
+		}
+		m.f.packSlot = &packSlot{
+			Pack:   p.ID,
+			Offset: m.offset,
+			Length: m.f.slotLength(),
+		}
+		m.f.markChunkStuck(0, uploaded < ec.NumPieces())
+		m.f.mu.Unlock()
+		p.Files++
+		p.LiveSize += m.f.slotLength()
+		saved = append(saved, m.f)
+	}
+	r.packs[p.ID] = p
+
+	// Save the slots before the files, so that no file refers to sectors of
+	// a pack without a slot.
+	if err := r.savePacks(); err != nil {
+		r.log.Println("WARN: unable to save the packs:", err)
+	}
+	for _, f := range saved {
+		f.mu.Lock()
+		if err := r.saveFile(f); err != nil {
+			r.log.Println("WARN: unable to save a packed file:", err)
+		}
+		f.mu.Unlock()
+	}
+}
+
+// managedPrunePacks removes the packs that don't store any files anymore once
+// all of their contracts have expired.
+func (r *Renter) managedPrunePacks() {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	pruned := false
+	for packID, p := range r.packs {
+		if p.Files > 0 {
+			continue
+		}
+		expired := true
+		for _, fcid := range p.Contracts {
+			if _, exists := r.hostContractor.ContractByID(r.hostContractor.ResolveID(fcid)); exists {
+				expired = false
+				break
+			}
+		}
+		if expired {
+			delete(r.packs, packID)
+			pruned = true
+		}
+	}
+	if pruned {
+		if err := r.savePacks(); err != nil {
+			r.log.Println("WARN: unable to save the packs:", err)
+		}
+	}
+}
+
+// packsData returns the packs and the slots of the packed files. The caller
+// must hold the renter lock.
+func (r *Renter) packsData() packsPersist {
+	pp := packsPersist{
+		Packs: make([]pack, 0, len(r.packs)),
+		Files: make([]packedFile, 0),
+	}
+	for _, p := range r.packs {
+		pp.Packs = append(pp.Packs, *p)
+	}
+	sort.Slice(pp.Packs, func(i, j int) bool { return pp.Packs[i].ID < pp.Packs[j].ID })
+	for _, f := range r.files {
+		f.mu.RLock()
+		if f.packSlot != nil {
+			pp.Files = append(pp.Files, packedFile{
+				SiaPath: f.name,
+				Slot:    *f.packSlot,
+			})
+		}
+		f.mu.RUnlock()
+	}
+	sort.Slice(pp.Files, func(i, j int) bool { return pp.Files[i].SiaPath < pp.Files[j].SiaPath })
+	return pp
+}
+
+// savePacks writes the packs and the slots of the packed files to disk. The
+// caller must hold the renter lock.
+func (r *Renter) savePacks() error {
+	return persist.SaveJSON(packsMetadata, r.packsData(), filepath.Join(r.persistDir, PacksFilename))
+}
+
+// loadPacks restores the packs and the slots of the packed files from disk.
+// Files that don't exist anymore are ignored.
+func (r *Renter) loadPacks() error {
+	var pp packsPersist
+	err := persist.LoadJSON(packsMetadata, &pp, filepath.Join(r.persistDir, PacksFilename))
+	if err != nil {
+		return err
+	}
+	for i := range pp.Packs {
+		r.packs[pp.Packs[i].ID] = &pp.Packs[i]
+	}
+	for _, pf := range pp.Files {
+		f, exists := r.files[pf.SiaPath]
+		if !exists || !f.packed() {
+			continue
+		}
+		slot := pf.Slot
+		f.mu.Lock()
+		f.packSlot = &slot
+		f.mu.Unlock()
+	}
+	return nil
+}
+
+// Packs returns information about the packs that store the renter's small
+// files.
+func (r *Renter) Packs() []modules.PackInfo {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	packs := make([]modules.PackInfo, 0, len(r.packs))
+	for _, p := range r.packs {
+		sectors := uint64(len(p.Contracts))
+		packs = append(packs, modules.PackInfo{
+			ID:           p.ID,
+			Files:        p.Files,
+			Sectors:      sectors,
+			UsedBytes:    p.Size * sectors,
+			LiveBytes:    p.LiveSize * sectors,
+			GarbageBytes: (p.Size - p.LiveSize) * sectors,
+		})
+	}
+	sort.Slice(packs, func(i, j int) bool { return packs[i].ID < packs[j].ID })
+	return packs
+}
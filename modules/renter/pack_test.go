@@ -0,0 +1,178 @@
+package renter
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestPackable probes the packedPieceSize and packable functions.
+func TestPackable(t *testing.T) {
+	rsc, _ := NewRSCode(4, 2)
+	tests := []struct {
+		size      uint64
+		pieceSize uint64
+		packable  bool
+	}{
+		{0, 1, true},
+		{1, 1, true},
+		{4, 1, true},
+		{5, 2, true},
+		{4 * maxPackedPieceSize, maxPackedPieceSize, true},
+		{4*maxPackedPieceSize + 1, maxPackedPieceSize + 1, false},
+		{4 * modules.SectorSize, modules.SectorSize, false},
+	}
+	for _, test := range tests {
+		if ps := packedPieceSize(test.size, rsc); ps != test.pieceSize {
+			t.Errorf("packedPieceSize(%v): expected %v, got %v", test.size, test.pieceSize, ps)
+		}
+		if p := packable(test.size, rsc); p != test.packable {
+			t.Errorf("packable(%v): expected %v, got %v", test.size, test.packable, p)
+		}
+	}
+}
+
+// TestEncodePackedPieces checks that small files can be recovered from the
+// slots of a pack's sectors.
+func TestEncodePackedPieces(t *testing.T) {
+	rsc, _ := NewRSCode(2, 1)
+	var files []*file
+	var datas [][]byte
+	for _, size := range []uint64{0, 1, 100, 777} {
+		f := &file{
+			size:        size,
+			masterKey:   crypto.GenerateTwofishKey(),
+			cipherType:  crypto.TypeTwofish,
+			erasureCode: rsc,
+			pieceSize:   packedPieceSize(size, rsc),
+		}
+		files = append(files, f)
+		datas = append(datas, fastrand.Bytes(int(size)))
+	}
+
+	// Build the sectors of a pack.
+	sectors := make([][]byte, rsc.NumPieces())
+	for i := range sectors {
+		sectors[i] = make([]byte, modules.SectorSize)
+	}
+	var offsets []uint64
+	var offset uint64
+	for i, f := range files {
+		data := make([]byte, f.staticChunkSize())
+		copy(data, datas[i])
+		pieces, err := encodePackedPieces(f, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, piece := range pieces {
+			if uint64(len(piece)) != f.slotLength() {
+				t.Fatalf("expected piece of length %v, got %v", f.slotLength(), len(piece))
+			}
+			copy(sectors[j][offset:], piece)
+		}
+		offsets = append(offsets, offset)
+		offset += f.slotLength()
+	}
+
+	// Recover every file from its slot, leaving out one of the pieces.
+	for i, f := range files {
+		pieces := make([][]byte, rsc.NumPieces())
+		for j := 1; j < len(pieces); j++ {
+			slot := sectors[j][offsets[i] : offsets[i]+f.slotLength()]
+			piece, err := deriveKey(f.cipherType, f.masterKey, 0, uint64(j)).DecryptBytes(slot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pieces[j] = piece
+		}
+		buf := new(bytes.Buffer)
+		if err := rsc.Recover(pieces, f.size, buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), datas[i]) {
+			t.Fatalf("file %v was not recovered correctly", i)
+		}
+	}
+}
+
+// TestRenterPacksPersist checks that deleting packed files is accounted as
+// garbage and that the packs are restored after a restart.
+func TestRenterPacksPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Store two files in a pack.
+	var files []*file
+	for _, siaPath := range []string{"a", "b"} {
+		f, err := rt.addTestingFile(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	p := &pack{
+		ID:        "pack",
+		Contracts: []types.FileContractID{{1}, {2}},
+		Size:      2 * files[0].slotLength(),
+	}
+	id := rt.renter.mu.Lock()
+	for i, f := range files {
+		f.packSlot = &packSlot{
+			Pack:   p.ID,
+			Offset: uint64(i) * f.slotLength(),
+			Length: f.slotLength(),
+		}
+		p.Files++
+		p.LiveSize += f.slotLength()
+	}
+	rt.renter.packs[p.ID] = p
+	err = rt.renter.savePacks()
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting a file should turn its slot into garbage.
+	if err := rt.renter.DeleteFile("a"); err != nil {
+		t.Fatal(err)
+	}
+	packs := rt.renter.Packs()
+	if len(packs) != 1 || packs[0].Files != 1 || packs[0].Sectors != 2 {
+		t.Fatal("wrong pack info:", packs)
+	}
+	if packs[0].GarbageBytes != 2*files[0].slotLength() || packs[0].LiveBytes != 2*files[1].slotLength() {
+		t.Fatal("deleted file was not accounted as garbage:", packs[0])
+	}
+
+	// The pack and the slot of the remaining file should survive a restart.
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, filepath.Join(rt.dir, modules.RenterDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, exists := rt.renter.packs[p.ID]
+	if !exists || len(loaded.Contracts) != 2 || loaded.Files != 1 || loaded.LiveSize != p.LiveSize || loaded.Size != p.Size {
+		t.Fatal("pack was not restored:", loaded)
+	}
+	f := rt.renter.files["b"]
+	if f.packSlot == nil || *f.packSlot != *files[1].packSlot {
+		t.Fatal("slot was not restored:", f.packSlot)
+	}
+	if fi, err := rt.renter.File("b"); err != nil || !fi.Packed {
+		t.Fatal("file should be reported as packed:", err)
+	}
+}
@@ -10,7 +10,7 @@ import (
 	"path/filepath"
 	"strconv"
 
-	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
@@ -34,6 +34,9 @@ var (
 	ErrNoNicknames = errors.New("at least one nickname must be supplied")
 	// ErrNonShareSuffix is an error when the suffix of a file does not match the defined share extension
 	ErrNonShareSuffix = errors.New("suffix of file must be " + ShareExtension)
+	// errSharePacked is returned when trying to share a packed file, since
+	// the .sia format can't store the location of a file within its pack.
+	errSharePacked = errors.New("packed files can't be shared")
 
 	settingsMetadata = persist.Metadata{
 		Header:  "Renter Persistence",
@@ -54,6 +57,9 @@ type (
 		MaxDownloadSpeed int64
 		MaxUploadSpeed   int64
 		StreamCacheSize  uint64
+		ChunkCacheSize   uint64
+		SubnetDiversity  bool
+		GeoIPDatabase    string
 		Tracking         map[string]trackedFile
 	}
 )
@@ -82,22 +88,15 @@ func (f *file) MarshalSia(w io.Writer) error {
 	}
 
 	// encode erasureCode
-	switch code := f.erasureCode.(type) {
-	case *rsCode:
-		err = enc.EncodeAll(
-			"Reed-Solomon",
-			uint64(code.dataPieces),
-			uint64(code.numPieces-code.dataPieces),
-		)
-		if err != nil {
-			return err
-		}
-	default:
-		if build.DEBUG {
-			panic("unknown erasure code")
-		}
-		return errors.New("unknown erasure code")
+	err = enc.EncodeAll(
+		f.erasureCode.Identifier().String(),
+		uint64(f.erasureCode.MinPieces()),
+		uint64(f.erasureCode.NumPieces()-f.erasureCode.MinPieces()),
+	)
+	if err != nil {
+		return err
 	}
+
 	// encode contracts
 	if err := enc.Encode(uint64(len(f.contracts))); err != nil {
 		return err
@@ -132,30 +131,34 @@ func (f *file) UnmarshalSia(r io.Reader) error {
 		return err
 	}
 	f.staticUID = persist.RandomSuffix()
+	// The .sia format only stores files that are encrypted with Twofish.
+	f.cipherType = crypto.TypeTwofish
 
 	// Decode erasure coder.
 	var codeType string
 	if err := dec.Decode(&codeType); err != nil {
 		return err
 	}
-	switch codeType {
-	case "Reed-Solomon":
-		var nData, nParity uint64
-		err = dec.DecodeAll(
-			&nData,
-			&nParity,
-		)
-		if err != nil {
-			return err
-		}
-		rsc, err := NewRSCode(int(nData), int(nParity))
-		if err != nil {
-			return err
-		}
-		f.erasureCode = rsc
-	default:
+	var nData, nParity uint64
+	err = dec.DecodeAll(
+		&nData,
+		&nParity,
+	)
+	if err != nil {
+		return err
+	}
+	if len(codeType) > types.SpecifierLen {
+		return errors.New("unrecognized erasure code type: " + codeType)
+	}
+	var id types.Specifier
+	copy(id[:], codeType)
+	ec, err := NewErasureCoder(id, int(nData), int(nParity))
+	if err == ErrUnknownErasureCoder {
 		return errors.New("unrecognized erasure code type: " + codeType)
+	} else if err != nil {
+		return err
 	}
+	f.erasureCode = ec
 
 	// Decode contracts.
 	var nContracts uint64
@@ -178,6 +181,11 @@ func (r *Renter) saveFile(f *file) error {
 	if f.deleted {
 		return errors.New("can't save deleted file")
 	}
+	// Copies of files that are being re-encrypted are saved once they
+	// replace the original.
+	if f.copyOf != nil {
+		return nil
+	}
 	// Create directory structure specified in nickname.
 	fullPath := filepath.Join(r.persistDir, f.name+ShareExtension)
 	err := os.MkdirAll(filepath.Dir(fullPath), 0700)
@@ -220,6 +228,22 @@ func (r *Renter) loadSiaFiles() error {
 			return nil
 		}
 
+		// Load the directory metadata.
+		if !info.IsDir() && info.Name() == SiaDirExtension {
+			var sdp siaDirPersist
+			err := persist.LoadJSON(siaDirMetadata, &sdp, path)
+			if err != nil {
+				r.log.Println("ERROR: could not load .siadir file:", err)
+				return nil
+			}
+			if err := validateDirSiapath(sdp.SiaPath); err != nil {
+				r.log.Println("ERROR: .siadir file contains invalid siapath:", err)
+				return nil
+			}
+			r.addDir(sdp.SiaPath)
+			return nil
+		}
+
 		// Skip folders and non-sia files.
 		if info.IsDir() || filepath.Ext(path) != ShareExtension {
 			return nil
@@ -327,6 +351,15 @@ func (r *Renter) ShareFiles(nicknames []string, shareDest string) error {
 		if !exists {
 			return ErrUnknownPath
 		}
+		if f.dedup {
+			return errShareDedup
+		}
+		if f.packed() {
+			return errSharePacked
+		}
+		if f.cipherType != crypto.TypeTwofish {
+			return errShareCipher
+		}
 		files[i] = f
 	}
 
@@ -351,6 +384,15 @@ func (r *Renter) ShareFilesASCII(nicknames []string) (string, error) {
 		if !exists {
 			return "", ErrUnknownPath
 		}
+		if f.dedup {
+			return "", errShareDedup
+		}
+		if f.packed() {
+			return "", errSharePacked
+		}
+		if f.cipherType != crypto.TypeTwofish {
+			return "", errShareCipher
+		}
 		files[i] = f
 	}
 
@@ -417,6 +459,9 @@ func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, error) {
 	for i, f := range files {
 		r.files[f.name] = f
 		names[i] = f.name
+		if err := r.linkFile(f); err != nil {
+			return nil, err
+		}
 	}
 	// Save the files.
 	for _, f := range files {
@@ -448,7 +493,47 @@ func (r *Renter) initPersist() error {
 	}
 
 	// Load the siafiles into memory.
-	return r.loadSiaFiles()
+	err = r.loadSiaFiles()
+	if err != nil {
+		return err
+	}
+
+	// Restore the ciphers of the siafiles.
+	err = r.loadCiphers()
+	if err != nil {
+		return err
+	}
+
+	// Restore the slots of the packed siafiles.
+	err = r.loadPacks()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Restore the chunk keys of the deduplicated siafiles.
+	err = r.loadDedup()
+	if err != nil {
+		return err
+	}
+
+	// Restore the spending ledger.
+	err = r.loadSpending()
+	if err != nil {
+		return err
+	}
+
+	// Restore the download tickets of the shared siafiles.
+	err = r.loadTickets()
+	if err != nil {
+		return err
+	}
+
+	// Restore the health of the siafiles.
+	err = r.loadHealth()
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
 }
 
 // LoadSharedFiles loads a .sia file into the renter. It returns the nicknames
@@ -13,6 +13,7 @@ import (
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/NebulousLabs/fastrand"
 )
@@ -28,6 +29,7 @@ func newTestingFile() *file {
 		name:        "testfile-" + strconv.Itoa(int(data[0])),
 		size:        encoding.DecUint64(data[1:5]),
 		masterKey:   crypto.GenerateTwofishKey(),
+		cipherType:  crypto.TypeTwofish,
 		erasureCode: rsc,
 		pieceSize:   encoding.DecUint64(data[6:8]),
 		staticUID:   persist.RandomSuffix(),
@@ -48,6 +50,9 @@ func equalFiles(f1, f2 *file) error {
 	if f1.masterKey != f2.masterKey {
 		return fmt.Errorf("keys do not match: %v %v", f1.masterKey, f2.masterKey)
 	}
+	if f1.cipherType != f2.cipherType {
+		return fmt.Errorf("cipher types do not match: %v %v", f1.cipherType, f2.cipherType)
+	}
 	if f1.pieceSize != f2.pieceSize {
 		return fmt.Errorf("pieceSizes do not match: %v %v", f1.pieceSize, f2.pieceSize)
 	}
@@ -73,6 +78,27 @@ func TestFileMarshalling(t *testing.T) {
 	}
 }
 
+// TestFileMarshallingErasureCoders checks that the erasure coder of a file
+// survives marshalling.
+func TestFileMarshallingErasureCoders(t *testing.T) {
+	for _, id := range []types.Specifier{modules.ErasureCoderReedSolomon, modules.ErasureCoderReplication, modules.ErasureCoderPartialRS} {
+		savedFile := newTestingFile()
+		savedFile.erasureCode, _ = NewDefaultErasureCoder(id)
+		buf := new(bytes.Buffer)
+		if err := savedFile.MarshalSia(buf); err != nil {
+			t.Fatal(err)
+		}
+		loadedFile := new(file)
+		if err := loadedFile.UnmarshalSia(buf); err != nil {
+			t.Fatal(err)
+		}
+		ec := loadedFile.erasureCode
+		if ec.Identifier() != id || ec.MinPieces() != savedFile.erasureCode.MinPieces() || ec.NumPieces() != savedFile.erasureCode.NumPieces() {
+			t.Fatalf("erasure coder was not loaded correctly: %v %v of %v", ec.Identifier(), ec.MinPieces(), ec.NumPieces())
+		}
+	}
+}
+
 // TestFileShareLoad tests the sharing/loading functions of the renter.
 func TestFileShareLoad(t *testing.T) {
 	if testing.Short() {
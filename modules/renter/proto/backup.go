@@ -0,0 +1,84 @@
+package proto
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// errContractExists is returned when restoring a contract that is
+	// already part of the set.
+	errContractExists = errors.New("contract is already part of the set")
+)
+
+// A ContractBackup contains the header and the Merkle roots of a contract,
+// which are sufficient to restore the contract in another ContractSet.
+type ContractBackup struct {
+	Header contractHeader `json:"header"`
+	Roots  []crypto.Hash  `json:"roots"`
+}
+
+// Metadata returns the metadata of the backed up contract. An error is
+// returned if the backup doesn't contain a valid contract.
+func (b ContractBackup) Metadata() (modules.RenterContract, error) {
+	if err := b.Header.validate(); err != nil {
+		return modules.RenterContract{}, err
+	}
+	sc := &SafeContract{header: b.Header}
+	return sc.Metadata(), nil
+}
+
+// Backup returns a backup of the contract with the specified ID. The contract
+// is locked while it is backed up.
+func (cs *ContractSet) Backup(id types.FileContractID) (ContractBackup, error) {
+	sc, ok := cs.Acquire(id)
+	if !ok {
+		return ContractBackup{}, errors.New("no contract with that id")
+	}
+	defer cs.Return(sc)
+	roots, err := sc.merkleRoots.merkleRoots()
+	if err != nil {
+		return ContractBackup{}, err
+	}
+	return ContractBackup{
+		Header: sc.header,
+		Roots:  roots,
+	}, nil
+}
+
+// Restore adds a backed up contract to the set. Contracts that are already
+// part of the set are not overwritten.
+func (cs *ContractSet) Restore(b ContractBackup) (modules.RenterContract, error) {
+	if err := b.Header.validate(); err != nil {
+		return modules.RenterContract{}, err
+	}
+	if cs.haveContract(b.Header.ID()) {
+		return modules.RenterContract{}, errContractExists
+	}
+	return cs.managedInsertContract(b.Header, b.Roots)
+}
+
+// haveContract returns true if the set contains the contract with the
+// specified ID, or if the contract's file exists.
+func (cs *ContractSet) haveContract(id types.FileContractID) bool {
+	cs.mu.Lock()
+	_, exists := cs.contracts[id]
+	cs.mu.Unlock()
+	if exists {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(cs.dir, id.String()+contractExtension))
+	return err == nil
+}
+
+// MerkleRoots returns the Merkle roots of the sectors covered by the contract
+// with the specified ID.
+func (cs *ContractSet) MerkleRoots(id types.FileContractID) ([]crypto.Hash, error) {
+	b, err := cs.Backup(id)
+	return b.Roots, err
+}
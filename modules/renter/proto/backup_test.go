@@ -0,0 +1,95 @@
+package proto
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestContractSetBackup checks that a contract can be restored in another
+// ContractSet from its backup.
+func TestContractSetBackup(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	testDir := build.TempDir(t.Name())
+	cs, err := NewContractSet(filepath.Join(testDir, "a"), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+	header := contractHeader{
+		Transaction: types.Transaction{
+			FileContractRevisions: []types.FileContractRevision{{
+				ParentID:             types.FileContractID{1},
+				NewValidProofOutputs: []types.SiacoinOutput{{}, {}},
+				UnlockConditions: types.UnlockConditions{
+					PublicKeys: []types.SiaPublicKey{{}, {}},
+				},
+			}},
+		},
+		UploadSpending: types.SiacoinPrecision,
+	}
+	roots := []crypto.Hash{{1}, {2}, {3}}
+	contract, err := cs.managedInsertContract(header, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := cs.Backup(contract.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b.Roots, roots) {
+		t.Fatal("backup contains the wrong roots:", b.Roots)
+	}
+	if _, err := cs.Backup(types.FileContractID{2}); err == nil {
+		t.Fatal("expected an error when backing up an unknown contract")
+	}
+	if _, err := cs.Restore(b); err != errContractExists {
+		t.Fatal("expected errContractExists, got", err)
+	}
+
+	// Restore the contract in a new set.
+	cs2, err := NewContractSet(filepath.Join(testDir, "b"), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := cs2.Restore(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, contract) {
+		t.Fatal("restored contract doesn't match the original")
+	}
+	if metadata, err := b.Metadata(); err != nil || !reflect.DeepEqual(metadata, contract) {
+		t.Fatal("backup metadata doesn't match the contract:", err)
+	}
+
+	// The roots should survive reloading the set.
+	if err := cs2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cs2, err = NewContractSet(filepath.Join(testDir, "b"), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs2.Close()
+	b2, err := cs2.Backup(contract.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b2.Roots, roots) {
+		t.Fatal("restored contract has the wrong roots:", b2.Roots)
+	}
+
+	// Invalid contracts can't be restored.
+	if _, err := cs2.Restore(ContractBackup{}); err == nil {
+		t.Fatal("expected an error when restoring an invalid contract")
+	}
+}
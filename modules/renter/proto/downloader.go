@@ -23,12 +23,90 @@ type Downloader struct {
 	hdb         hostDB
 	host        modules.HostDBEntry
 	once        sync.Once
+
+	// proofs is true if the host sends Merkle range proofs of the data, which
+	// allows for downloading parts of sectors.
+	proofs bool
 }
 
 // Sector retrieves the sector with the specified Merkle root, and revises
 // the underlying contract to pay the host proportionally to the data
 // retrieve.
 func (hd *Downloader) Sector(root crypto.Hash) (_ modules.RenterContract, _ []byte, err error) {
+	return hd.PartialSector(root, 0, modules.SectorSize)
+}
+
+// PartialSector retrieves length bytes of the sector with the specified Merkle
+// root, starting at offset, and revises the underlying contract to pay the
+// host for the retrieved data.
+func (hd *Downloader) PartialSector(root crypto.Hash, offset, length uint64) (_ modules.RenterContract, _ []byte, err error) {
+	contract, data, err := hd.Download([]modules.DownloadAction{{
+		MerkleRoot: root,
+		Offset:     offset,
+		Length:     length,
+	}})
+	if err != nil {
+		return modules.RenterContract{}, nil, err
+	}
+	return contract, data[0], nil
+}
+
+// Download retrieves the requested ranges of sectors in a single iteration of
+// the download loop, and revises the underlying contract to pay the host for
+// the retrieved data. All data is verified against the Merkle roots of the
+// sectors. If the host proves the data with Merkle range proofs, only the
+// segments covering the ranges are retrieved, otherwise the whole sectors are
+// retrieved.
+func (hd *Downloader) Download(actions []modules.DownloadAction) (_ modules.RenterContract, _ [][]byte, err error) {
+	// Translate the ranges into requests that the host can prove. Without
+	// proofs, every sector is requested only once.
+	var requests []modules.DownloadAction
+	requestIndex := make([]int, len(actions))
+	fullSectors := make(map[crypto.Hash]int)
+	for i, a := range actions {
+		if a.Length == 0 || a.Offset+a.Length > modules.SectorSize || a.Offset+a.Length < a.Offset {
+			return modules.RenterContract{}, nil, errors.New("requested range is outside of the sector")
+		}
+		if hd.proofs {
+			start := a.Offset / crypto.SegmentSize * crypto.SegmentSize
+			end := (a.Offset + a.Length + crypto.SegmentSize - 1) / crypto.SegmentSize * crypto.SegmentSize
+			requestIndex[i] = len(requests)
+			requests = append(requests, modules.DownloadAction{
+				MerkleRoot: a.MerkleRoot,
+				Offset:     start,
+				Length:     end - start,
+			})
+			continue
+		}
+		index, exists := fullSectors[a.MerkleRoot]
+		if !exists {
+			index = len(requests)
+			fullSectors[a.MerkleRoot] = index
+			requests = append(requests, modules.DownloadAction{
+				MerkleRoot: a.MerkleRoot,
+				Length:     modules.SectorSize,
+			})
+		}
+		requestIndex[i] = index
+	}
+
+	contract, data, err := hd.download(requests)
+	if err != nil {
+		return modules.RenterContract{}, nil, err
+	}
+	ranges := make([][]byte, len(actions))
+	for i, a := range actions {
+		r := requests[requestIndex[i]]
+		ranges[i] = data[requestIndex[i]][a.Offset-r.Offset:][:a.Length]
+	}
+	return contract, ranges, nil
+}
+
+// download performs a single iteration of the download loop, retrieving the
+// requested parts of sectors. The data is verified against the Merkle roots
+// of the sectors, using the Merkle range proofs of the host for partial
+// sectors.
+func (hd *Downloader) download(requests []modules.DownloadAction) (_ modules.RenterContract, _ [][]byte, err error) {
 	// Reset deadline when finished.
 	defer extendDeadline(hd.conn, time.Hour) // TODO: Constant.
 
@@ -42,7 +120,11 @@ func (hd *Downloader) Sector(root crypto.Hash) (_ modules.RenterContract, _ []by
 	contract := sc.header // for convenience
 
 	// calculate price
-	sectorPrice := hd.host.DownloadBandwidthPrice.Mul64(modules.SectorSize)
+	var length uint64
+	for _, r := range requests {
+		length += r.Length
+	}
+	sectorPrice := hd.host.DownloadBandwidthPrice.Mul64(length)
 	if contract.RenterFunds().Cmp(sectorPrice) < 0 {
 		return modules.RenterContract{}, nil, errors.New("contract has insufficient funds to support download")
 	}
@@ -53,11 +135,14 @@ func (hd *Downloader) Sector(root crypto.Hash) (_ modules.RenterContract, _ []by
 	// create the download revision
 	rev := newDownloadRevision(contract.LastRevision(), sectorPrice)
 
-	// initiate download by confirming host settings
+	// initiate download by confirming host settings. The settings exchange
+	// is a single round trip, so it is used to measure the host's latency.
 	extendDeadline(hd.conn, modules.NegotiateSettingsTime)
+	start := time.Now()
 	if err := startDownload(hd.conn, hd.host); err != nil {
 		return modules.RenterContract{}, nil, err
 	}
+	hd.hdb.RecordLatency(contract.HostPublicKey(), time.Since(start))
 
 	// record the change we are about to make to the contract. If we lose power
 	// mid-revision, this allows us to restore either the pre-revision or
@@ -67,13 +152,9 @@ func (hd *Downloader) Sector(root crypto.Hash) (_ modules.RenterContract, _ []by
 		return modules.RenterContract{}, nil, err
 	}
 
-	// send download action
+	// send download actions
 	extendDeadline(hd.conn, 2*time.Minute) // TODO: Constant.
-	err = encoding.WriteObject(hd.conn, []modules.DownloadAction{{
-		MerkleRoot: root,
-		Offset:     0,
-		Length:     modules.SectorSize,
-	}})
+	err = encoding.WriteObject(hd.conn, requests)
 	if err != nil {
 		return modules.RenterContract{}, nil, err
 	}
@@ -114,24 +195,47 @@ func (hd *Downloader) Sector(root crypto.Hash) (_ modules.RenterContract, _ []by
 	// read sector data, completing one iteration of the download loop
 	extendDeadline(hd.conn, modules.NegotiateDownloadTime)
 	var sectors [][]byte
-	if err := encoding.ReadObject(hd.conn, &sectors, modules.SectorSize+16); err != nil {
+	start = time.Now()
+	if err := encoding.ReadObject(hd.conn, &sectors, length+8*uint64(len(requests))+8); err != nil {
 		return modules.RenterContract{}, nil, err
-	} else if len(sectors) != 1 {
+	} else if len(sectors) != len(requests) {
 		return modules.RenterContract{}, nil, errors.New("host did not send enough sectors")
 	}
-	sector := sectors[0]
-	if uint64(len(sector)) != modules.SectorSize {
-		return modules.RenterContract{}, nil, errors.New("host did not send enough sector data")
-	} else if crypto.MerkleRoot(sector) != root {
-		return modules.RenterContract{}, nil, errors.New("host sent bad sector data")
+	var proofs [][]crypto.Hash
+	if hd.proofs {
+		// A range proof contains at most two hashes per level of the tree.
+		maxProofLen := uint64(len(requests))*(2*64*crypto.HashSize+8) + 8
+		if err := encoding.ReadObject(hd.conn, &proofs, maxProofLen); err != nil {
+			return modules.RenterContract{}, nil, err
+		} else if len(proofs) != len(requests) {
+			return modules.RenterContract{}, nil, errors.New("host did not send enough proofs")
+		}
+	}
+	elapsed := time.Since(start)
+	for i, r := range requests {
+		if uint64(len(sectors[i])) != r.Length {
+			return modules.RenterContract{}, nil, errors.New("host did not send enough sector data")
+		}
+		if r.Length == modules.SectorSize {
+			if crypto.MerkleRoot(sectors[i]) != r.MerkleRoot {
+				return modules.RenterContract{}, nil, errors.New("host sent bad sector data")
+			}
+			continue
+		}
+		proofStart := r.Offset / crypto.SegmentSize
+		proofEnd := (r.Offset + r.Length) / crypto.SegmentSize
+		if proofs == nil || !crypto.VerifyRangeProof(sectors[i], proofs[i], proofStart, proofEnd, modules.SectorSize/crypto.SegmentSize, r.MerkleRoot) {
+			return modules.RenterContract{}, nil, errors.New("host sent bad sector data")
+		}
 	}
 
 	// update contract and metrics
 	if err := sc.commitDownload(walTxn, signedTxn, sectorPrice); err != nil {
 		return modules.RenterContract{}, nil, err
 	}
+	hd.hdb.RecordDownload(contract.HostPublicKey(), length, elapsed)
 
-	return sc.Metadata(), sector, nil
+	return sc.Metadata(), sectors, nil
 }
 
 // shutdown terminates the revision loop and signals the goroutine spawned in
@@ -155,6 +259,63 @@ func (hd *Downloader) Close() error {
 // NewDownloader initiates the download request loop with a host, and returns a
 // Downloader.
 func (cs *ContractSet) NewDownloader(host modules.HostDBEntry, id types.FileContractID, hdb hostDB, cancel <-chan struct{}) (_ *Downloader, err error) {
+	return cs.newDownloader(host, id, nil, hdb, cancel)
+}
+
+// NewTicketDownloader initiates the download request loop with a host,
+// presenting a download ticket that grants access to the sectors of another
+// renter's contract. The downloads are paid for with the contract id.
+func (cs *ContractSet) NewTicketDownloader(host modules.HostDBEntry, id types.FileContractID, ticket modules.DownloadTicket, hdb hostDB, cancel <-chan struct{}) (_ *Downloader, err error) {
+	return cs.newDownloader(host, id, &ticket, hdb, cancel)
+}
+
+// SignTicket creates a download ticket that grants access to the sectors with
+// the given roots of the contract id until the expiration height.
+func (cs *ContractSet) SignTicket(id types.FileContractID, roots []crypto.Hash, expiration types.BlockHeight) (modules.DownloadTicket, error) {
+	sc, ok := cs.Acquire(id)
+	if !ok {
+		return modules.DownloadTicket{}, errors.New("invalid contract")
+	}
+	defer cs.Return(sc)
+	contractRoots, err := sc.merkleRoots.merkleRoots()
+	if err != nil {
+		return modules.DownloadTicket{}, err
+	}
+
+	ticket := modules.DownloadTicket{
+		ContractID:   id,
+		Expiration:   expiration,
+		SectorRanges: sectorRanges(contractRoots, roots),
+	}
+	ticket.Signature = crypto.SignHash(ticket.SigHash(), sc.header.SecretKey)
+	return ticket, nil
+}
+
+// sectorRanges returns the ranges of indices of contractRoots that hold one of
+// the roots, merging adjacent indices into a single range.
+func sectorRanges(contractRoots, roots []crypto.Hash) []modules.SectorRange {
+	wanted := make(map[crypto.Hash]struct{}, len(roots))
+	for _, root := range roots {
+		wanted[root] = struct{}{}
+	}
+	var ranges []modules.SectorRange
+	for i, root := range contractRoots {
+		if _, ok := wanted[root]; !ok {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].End == uint64(i) {
+			ranges[n-1].End++
+		} else {
+			ranges = append(ranges, modules.SectorRange{Start: uint64(i), End: uint64(i) + 1})
+		}
+	}
+	return ranges
+}
+
+// newDownloader initiates the download request loop with a host. If ticket is
+// not nil, it is presented to the host to download the sectors of another
+// contract.
+func (cs *ContractSet) newDownloader(host modules.HostDBEntry, id types.FileContractID, ticket *modules.DownloadTicket, hdb hostDB, cancel <-chan struct{}) (_ *Downloader, err error) {
 	sc, ok := cs.Acquire(id)
 	if !ok {
 		return nil, errors.New("invalid contract")
@@ -178,11 +339,24 @@ func (cs *ContractSet) NewDownloader(host modules.HostDBEntry, id types.FileCont
 		}
 	}()
 
-	conn, closeChan, err := initiateRevisionLoop(host, contract, modules.RPCDownload, cancel, cs.rl)
+	// Ticket downloads always include Merkle range proofs. Hosts that don't
+	// support RPCProvenDownload reject it by closing the connection, in which
+	// case the renter falls back to RPCDownload, which only serves full
+	// sectors. Any other error is returned, so that a misbehaving host can't
+	// downgrade the renter to downloads without proofs.
+	rpc, proofs := modules.RPCProvenDownload, true
+	if ticket != nil {
+		rpc = modules.RPCTicketDownload
+	}
+	conn, closeChan, err := initiateRevisionLoop(host, contract, rpc, ticket, cancel, cs.rl)
+	if err == errRPCRejected && ticket == nil {
+		rpc, proofs = modules.RPCDownload, false
+		conn, closeChan, err = initiateRevisionLoop(host, contract, rpc, ticket, cancel, cs.rl)
+	}
 	if IsRevisionMismatch(err) && len(sc.unappliedTxns) > 0 {
 		// we have desynced from the host. If we have unapplied updates from the
 		// WAL, try applying them.
-		conn, closeChan, err = initiateRevisionLoop(host, sc.unappliedHeader(), modules.RPCDownload, cancel, cs.rl)
+		conn, closeChan, err = initiateRevisionLoop(host, sc.unappliedHeader(), rpc, ticket, cancel, cs.rl)
 		if err != nil {
 			return nil, err
 		}
@@ -208,5 +382,6 @@ func (cs *ContractSet) NewDownloader(host modules.HostDBEntry, id types.FileCont
 		closeChan:   closeChan,
 		deps:        cs.deps,
 		hdb:         hdb,
+		proofs:      proofs,
 	}, nil
 }
@@ -0,0 +1,32 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestSectorRanges checks that sectorRanges merges adjacent sectors of a
+// contract into a single range and covers repeated roots.
+func TestSectorRanges(t *testing.T) {
+	a, b, c := crypto.Hash{1}, crypto.Hash{2}, crypto.Hash{3}
+	contractRoots := []crypto.Hash{a, b, c, a, c, b, b}
+	tests := []struct {
+		roots  []crypto.Hash
+		ranges []modules.SectorRange
+	}{
+		{nil, nil},
+		{[]crypto.Hash{{4}}, nil},
+		{[]crypto.Hash{a}, []modules.SectorRange{{Start: 0, End: 1}, {Start: 3, End: 4}}},
+		{[]crypto.Hash{b}, []modules.SectorRange{{Start: 1, End: 2}, {Start: 5, End: 7}}},
+		{[]crypto.Hash{a, b, c}, []modules.SectorRange{{Start: 0, End: 7}}},
+		{[]crypto.Hash{c, a}, []modules.SectorRange{{Start: 0, End: 1}, {Start: 2, End: 5}}},
+	}
+	for _, test := range tests {
+		if ranges := sectorRanges(contractRoots, test.roots); !reflect.DeepEqual(ranges, test.ranges) {
+			t.Errorf("sectorRanges(%v): expected %v, got %v", test.roots, test.ranges, ranges)
+		}
+	}
+}
@@ -115,11 +115,14 @@ func (he *Editor) Upload(data []byte) (_ modules.RenterContract, _ crypto.Hash,
 		extendDeadline(he.conn, time.Hour)
 	}()
 
-	// initiate revision
+	// initiate revision. The settings exchange is a single round trip, so it
+	// is used to measure the host's latency.
 	extendDeadline(he.conn, modules.NegotiateSettingsTime)
+	start := time.Now()
 	if err := startRevision(he.conn, he.host); err != nil {
 		return modules.RenterContract{}, crypto.Hash{}, err
 	}
+	he.hdb.RecordLatency(he.host.PublicKey, time.Since(start))
 
 	// record the change we are about to make to the contract. If we lose power
 	// mid-revision, this allows us to restore either the pre-revision or
@@ -129,8 +132,10 @@ func (he *Editor) Upload(data []byte) (_ modules.RenterContract, _ crypto.Hash,
 		return modules.RenterContract{}, crypto.Hash{}, err
 	}
 
-	// send actions
+	// send actions. The host has received the data once it signs the
+	// revision, so the upload is timed until the signatures are exchanged.
 	extendDeadline(he.conn, modules.NegotiateFileContractRevisionTime)
+	start = time.Now()
 	if err := encoding.WriteObject(he.conn, actions); err != nil {
 		return modules.RenterContract{}, crypto.Hash{}, err
 	}
@@ -151,6 +156,7 @@ func (he *Editor) Upload(data []byte) (_ modules.RenterContract, _ crypto.Hash,
 	} else if err != nil {
 		return modules.RenterContract{}, crypto.Hash{}, err
 	}
+	elapsed := time.Since(start)
 
 	// Disrupt here before updating the contract.
 	if he.deps.Disrupt("InterruptUploadAfterSendingRevision") {
@@ -163,6 +169,7 @@ func (he *Editor) Upload(data []byte) (_ modules.RenterContract, _ crypto.Hash,
 	if err != nil {
 		return modules.RenterContract{}, crypto.Hash{}, err
 	}
+	he.hdb.RecordUpload(he.host.PublicKey, uint64(len(data)), elapsed)
 
 	return sc.Metadata(), sectorRoot, nil
 }
@@ -187,11 +194,11 @@ func (cs *ContractSet) NewEditor(host modules.HostDBEntry, id types.FileContract
 		}
 	}()
 
-	conn, closeChan, err := initiateRevisionLoop(host, contract, modules.RPCReviseContract, cancel, cs.rl)
+	conn, closeChan, err := initiateRevisionLoop(host, contract, modules.RPCReviseContract, nil, cancel, cs.rl)
 	if IsRevisionMismatch(err) && len(sc.unappliedTxns) > 0 {
 		// we have desynced from the host. If we have unapplied updates from the
 		// WAL, try applying them.
-		conn, closeChan, err = initiateRevisionLoop(host, sc.unappliedHeader(), modules.RPCReviseContract, cancel, cs.rl)
+		conn, closeChan, err = initiateRevisionLoop(host, sc.unappliedHeader(), modules.RPCReviseContract, nil, cancel, cs.rl)
 		if err != nil {
 			return nil, err
 		}
@@ -222,8 +229,9 @@ func (cs *ContractSet) NewEditor(host modules.HostDBEntry, id types.FileContract
 }
 
 // initiateRevisionLoop initiates either the editor or downloader loop with
-// host, depending on which rpc was passed.
-func initiateRevisionLoop(host modules.HostDBEntry, contract contractHeader, rpc types.Specifier, cancel <-chan struct{}, rl *ratelimit.RateLimit) (net.Conn, chan struct{}, error) {
+// host, depending on which rpc was passed. If ticket is not nil, it is
+// presented to the host before the revision exchange.
+func initiateRevisionLoop(host modules.HostDBEntry, contract contractHeader, rpc types.Specifier, ticket *modules.DownloadTicket, cancel <-chan struct{}, rl *ratelimit.RateLimit) (net.Conn, chan struct{}, error) {
 	c, err := (&net.Dialer{
 		Cancel:  cancel,
 		Timeout: 45 * time.Second, // TODO: Constant
@@ -250,6 +258,18 @@ func initiateRevisionLoop(host modules.HostDBEntry, contract contractHeader, rpc
 		close(closeChan)
 		return nil, closeChan, errors.New("couldn't initiate RPC: " + err.Error())
 	}
+	if ticket != nil {
+		if err := encoding.WriteObject(conn, *ticket); err != nil {
+			conn.Close()
+			close(closeChan)
+			return nil, closeChan, errors.New("couldn't send download ticket: " + err.Error())
+		}
+		if err := modules.ReadNegotiationAcceptance(conn); err != nil {
+			conn.Close()
+			close(closeChan)
+			return nil, closeChan, errors.New("host did not accept download ticket: " + err.Error())
+		}
+	}
 	if err := verifyRecentRevision(conn, contract, host.Version); err != nil {
 		conn.Close() // TODO: close gracefully if host has entered revision loop
 		close(closeChan)
@@ -23,8 +23,9 @@ func (cs *ContractSet) FormContract(params ContractParams, txnBuilder transactio
 	// Extract vars from params, for convenience.
 	host, funding, startHeight, endHeight, refundAddress := params.Host, params.Funding, params.StartHeight, params.EndHeight, params.RefundAddress
 
-	// Create our key.
-	ourSK, ourPK := crypto.GenerateKeyPair()
+	// Derive our key from the renter seed, so that the contract can be
+	// recovered from the seed.
+	ourSK, ourPK := params.RenterSeed.contractKeys(host.PublicKey, startHeight)
 	// Create unlock conditions.
 	uc := types.UnlockConditions{
 		PublicKeys: []types.SiaPublicKey{
@@ -95,6 +96,9 @@ func (cs *ContractSet) FormContract(params ContractParams, txnBuilder transactio
 	txnBuilder.AddFileContract(fc)
 	// Add miner fee.
 	txnBuilder.AddMinerFee(txnFee)
+	// Identify the contract as ours.
+	txn, _ := txnBuilder.View()
+	txnBuilder.AddArbitraryData(params.RenterSeed.contractData(txn, host.PublicKey))
 
 	// Create initial transaction set.
 	txn, parentTxns := txnBuilder.View()
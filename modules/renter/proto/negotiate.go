@@ -2,7 +2,10 @@ package proto
 
 import (
 	"errors"
+	"io"
 	"net"
+	"os"
+	"syscall"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -12,6 +15,12 @@ import (
 	"github.com/NebulousLabs/Sia/types"
 )
 
+var (
+	// errRPCRejected is returned if the host closed the connection instead of
+	// responding to the RPC, which is how hosts reject RPCs they don't know.
+	errRPCRejected = errors.New("host closed the connection without responding to the RPC")
+)
+
 // extendDeadline is a helper function for extending the connection timeout.
 func extendDeadline(conn net.Conn, d time.Duration) { _ = conn.SetDeadline(time.Now().Add(d)) }
 
@@ -69,48 +78,61 @@ func verifySettings(conn net.Conn, host modules.HostDBEntry) (modules.HostDBEntr
 // verifyRecentRevision confirms that the host and contractor agree upon the current
 // state of the contract being revised.
 func verifyRecentRevision(conn net.Conn, contract contractHeader, hostVersion string) error {
+	lastRevision, hostSignatures, err := fetchRecentRevision(conn, contract.ID(), contract.SecretKey, hostVersion)
+	if err != nil {
+		return err
+	}
+	// Check that the unlock hashes match; if they do not, something is
+	// seriously wrong. Otherwise, check that the revision numbers match.
+	ourRev := contract.LastRevision()
+	if lastRevision.UnlockConditions.UnlockHash() != ourRev.UnlockConditions.UnlockHash() {
+		return errors.New("unlock conditions do not match")
+	} else if lastRevision.NewRevisionNumber != ourRev.NewRevisionNumber {
+		return &recentRevisionError{ourRev.NewRevisionNumber, lastRevision.NewRevisionNumber}
+	}
+	// NOTE: we can fake the blockheight here because it doesn't affect
+	// verification; it just needs to be above the fork height and below the
+	// contract expiration (which was checked earlier).
+	return modules.VerifyFileContractRevisionTransactionSignatures(lastRevision, hostSignatures, contract.EndHeight()-1)
+}
+
+// fetchRecentRevision proves to the host that we control the contract with
+// the specified id, and reads the most recent revision of the contract along
+// with the host's signatures. The revision is not verified.
+func fetchRecentRevision(conn net.Conn, id types.FileContractID, sk crypto.SecretKey, hostVersion string) (types.FileContractRevision, []types.TransactionSignature, error) {
 	// send contract ID
-	if err := encoding.WriteObject(conn, contract.ID()); err != nil {
-		return errors.New("couldn't send contract ID: " + err.Error())
+	if err := encoding.WriteObject(conn, id); err != nil {
+		return types.FileContractRevision{}, nil, errors.New("couldn't send contract ID: " + err.Error())
 	}
 	// read challenge
 	var challenge crypto.Hash
-	if err := encoding.ReadObject(conn, &challenge, 32); err != nil {
-		return errors.New("couldn't read challenge: " + err.Error())
+	if err := encoding.ReadObject(conn, &challenge, 32); isConnClosed(err) {
+		return types.FileContractRevision{}, nil, errRPCRejected
+	} else if err != nil {
+		return types.FileContractRevision{}, nil, errors.New("couldn't read challenge: " + err.Error())
 	}
 	if build.VersionCmp(hostVersion, "1.3.0") >= 0 {
 		crypto.SecureWipe(challenge[:16])
 	}
 	// sign and return
-	sig := crypto.SignHash(challenge, contract.SecretKey)
+	sig := crypto.SignHash(challenge, sk)
 	if err := encoding.WriteObject(conn, sig); err != nil {
-		return errors.New("couldn't send challenge response: " + err.Error())
+		return types.FileContractRevision{}, nil, errors.New("couldn't send challenge response: " + err.Error())
 	}
 	// read acceptance
 	if err := modules.ReadNegotiationAcceptance(conn); err != nil {
-		return errors.New("host did not accept revision request: " + err.Error())
+		return types.FileContractRevision{}, nil, errors.New("host did not accept revision request: " + err.Error())
 	}
 	// read last revision and signatures
 	var lastRevision types.FileContractRevision
 	var hostSignatures []types.TransactionSignature
 	if err := encoding.ReadObject(conn, &lastRevision, 2048); err != nil {
-		return errors.New("couldn't read last revision: " + err.Error())
+		return types.FileContractRevision{}, nil, errors.New("couldn't read last revision: " + err.Error())
 	}
 	if err := encoding.ReadObject(conn, &hostSignatures, 2048); err != nil {
-		return errors.New("couldn't read host signatures: " + err.Error())
-	}
-	// Check that the unlock hashes match; if they do not, something is
-	// seriously wrong. Otherwise, check that the revision numbers match.
-	ourRev := contract.LastRevision()
-	if lastRevision.UnlockConditions.UnlockHash() != ourRev.UnlockConditions.UnlockHash() {
-		return errors.New("unlock conditions do not match")
-	} else if lastRevision.NewRevisionNumber != ourRev.NewRevisionNumber {
-		return &recentRevisionError{ourRev.NewRevisionNumber, lastRevision.NewRevisionNumber}
+		return types.FileContractRevision{}, nil, errors.New("couldn't read host signatures: " + err.Error())
 	}
-	// NOTE: we can fake the blockheight here because it doesn't affect
-	// verification; it just needs to be above the fork height and below the
-	// contract expiration (which was checked earlier).
-	return modules.VerifyFileContractRevisionTransactionSignatures(lastRevision, hostSignatures, contract.EndHeight()-1)
+	return lastRevision, hostSignatures, nil
 }
 
 // negotiateRevision sends a revision and actions to the host for approval,
@@ -230,3 +252,18 @@ func newModifyRevision(current types.FileContractRevision, merkleRoot crypto.Has
 	rev.NewFileMerkleRoot = merkleRoot
 	return rev
 }
+
+// isConnClosed returns true if err indicates that the other end closed the
+// connection before sending any data. If the other end hasn't read all of the
+// data that was sent to it, the connection is reset instead of closed.
+func isConnClosed(err error) bool {
+	if err == io.EOF {
+		return true
+	}
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+	sysErr, ok := opErr.Err.(*os.SyscallError)
+	return ok && sysErr.Err == syscall.ECONNRESET
+}
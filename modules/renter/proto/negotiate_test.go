@@ -62,3 +62,33 @@ func TestNegotiateRevisionStopResponse(t *testing.T) {
 	}
 	rConn.Close()
 }
+
+// TestFetchRecentRevisionRejected checks that fetchRecentRevision only
+// reports that the host rejected the RPC if the host closed the connection
+// without responding.
+func TestFetchRecentRevisionRejected(t *testing.T) {
+	// The host closes the connection after reading the contract ID.
+	rConn, hConn := net.Pipe()
+	go func() {
+		defer hConn.Close()
+		encoding.ReadObject(hConn, new(types.FileContractID), 32)
+	}()
+	_, _, err := fetchRecentRevision(rConn, types.FileContractID{}, crypto.SecretKey{}, "1.3.0")
+	if err != errRPCRejected {
+		t.Fatal("expected errRPCRejected, got", err)
+	}
+	rConn.Close()
+
+	// The host sends garbage instead of a challenge.
+	rConn, hConn = net.Pipe()
+	go func() {
+		defer hConn.Close()
+		encoding.ReadObject(hConn, new(types.FileContractID), 32)
+		hConn.Write([]byte("garbage"))
+	}()
+	_, _, err = fetchRecentRevision(rConn, types.FileContractID{}, crypto.SecretKey{}, "1.3.0")
+	if err == nil || err == errRPCRejected {
+		t.Fatal("expected a read error, got", err)
+	}
+	rConn.Close()
+}
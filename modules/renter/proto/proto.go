@@ -2,6 +2,7 @@ package proto
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
@@ -11,6 +12,7 @@ import (
 // Dependencies.
 type (
 	transactionBuilder interface {
+		AddArbitraryData([]byte) uint64
 		AddFileContract(types.FileContract) uint64
 		AddMinerFee(types.Currency) uint64
 		AddParents([]types.Transaction)
@@ -32,6 +34,9 @@ type (
 	hostDB interface {
 		IncrementSuccessfulInteractions(key types.SiaPublicKey)
 		IncrementFailedInteractions(key types.SiaPublicKey)
+		RecordDownload(key types.SiaPublicKey, size uint64, elapsed time.Duration)
+		RecordLatency(key types.SiaPublicKey, latency time.Duration)
+		RecordUpload(key types.SiaPublicKey, size uint64, elapsed time.Duration)
 	}
 )
 
@@ -42,7 +47,7 @@ type ContractParams struct {
 	StartHeight   types.BlockHeight
 	EndHeight     types.BlockHeight
 	RefundAddress types.UnlockHash
-	// TODO: add optional keypair
+	RenterSeed    RenterSeed
 }
 
 // A revisionSaver is called just before we send our revision signature to the host; this
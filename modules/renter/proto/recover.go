@@ -0,0 +1,99 @@
+package proto
+
+import (
+	"errors"
+	"net"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// errBadRecoveredRevision is returned when the host sends a revision that
+	// doesn't belong to the contract being recovered.
+	errBadRecoveredRevision = errors.New("host sent a revision that doesn't match the contract")
+
+	// errBadSectorRoots is returned when the Merkle roots sent by the host
+	// don't match the most recent revision of the contract.
+	errBadSectorRoots = errors.New("host sent Merkle roots that don't match the contract")
+
+	// errUnknownContractKey is returned when the key of a contract can't be
+	// derived from the renter seed.
+	errUnknownContractKey = errors.New("contract key can't be derived from the renter seed")
+)
+
+// RecoverContract fetches the most recent revision and the Merkle roots of a
+// contract that was found on the blockchain from its host, and adds the
+// contract to the set. The host has to support RPCSectorRoots. The spending
+// of the contract can't be recovered, and its fees are estimated from the
+// current settings of the host.
+func (cs *ContractSet) RecoverContract(rc RecoverableContract, rs RenterSeed, host modules.HostDBEntry, cancel <-chan struct{}) (modules.RenterContract, error) {
+	if cs.haveContract(rc.ID) {
+		return modules.RenterContract{}, errContractExists
+	} else if host.PublicKey.String() != rc.HostPublicKey.String() {
+		return modules.RenterContract{}, errors.New("host doesn't match the contract")
+	} else if len(rc.ValidProofOutputs) == 0 {
+		return modules.RenterContract{}, errors.New("invalid contract")
+	}
+	sk, ok := rs.recoverContractKey(rc)
+	if !ok {
+		return modules.RenterContract{}, errUnknownContractKey
+	}
+
+	dialer := &net.Dialer{
+		Cancel:  cancel,
+		Timeout: connTimeout,
+	}
+	conn, err := dialer.Dial("tcp", string(host.NetAddress))
+	if err != nil {
+		return modules.RenterContract{}, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	extendDeadline(conn, modules.NegotiateRecentRevisionTime)
+	if err := encoding.WriteObject(conn, modules.RPCSectorRoots); err != nil {
+		return modules.RenterContract{}, errors.New("couldn't initiate RPC: " + err.Error())
+	}
+	rev, sigs, err := fetchRecentRevision(conn, rc.ID, sk, host.Version)
+	if err != nil {
+		return modules.RenterContract{}, err
+	}
+	if rev.ParentID != rc.ID || rev.UnlockConditions.UnlockHash() != rc.UnlockHash ||
+		len(rev.NewValidProofOutputs) == 0 || rev.NewFileSize%modules.SectorSize != 0 {
+		return modules.RenterContract{}, errBadRecoveredRevision
+	}
+	if err := modules.VerifyFileContractRevisionTransactionSignatures(rev, sigs, rc.WindowStart-1); err != nil {
+		return modules.RenterContract{}, err
+	}
+
+	extendDeadline(conn, modules.NegotiateDownloadTime)
+	numSectors := rev.NewFileSize / modules.SectorSize
+	var roots []crypto.Hash
+	if err := encoding.ReadObject(conn, &roots, 8+numSectors*crypto.HashSize); err != nil {
+		return modules.RenterContract{}, errors.New("couldn't read sector roots: " + err.Error())
+	}
+	if uint64(len(roots)) != numSectors || cachedMerkleRoot(roots) != rev.NewFileMerkleRoot {
+		return modules.RenterContract{}, errBadSectorRoots
+	}
+
+	siafundFee := types.Tax(rc.StartHeight, rc.Payout)
+	header := contractHeader{
+		Transaction: types.Transaction{
+			FileContractRevisions: []types.FileContractRevision{rev},
+			TransactionSignatures: sigs,
+		},
+		SecretKey:   sk,
+		StartHeight: rc.StartHeight,
+		TotalCost:   rc.ValidProofOutputs[0].Value.Add(siafundFee).Add(host.ContractPrice).Add(rc.TxnFee),
+		ContractFee: host.ContractPrice,
+		TxnFee:      rc.TxnFee,
+		SiafundFee:  siafundFee,
+		Utility: modules.ContractUtility{
+			GoodForUpload: true,
+			GoodForRenew:  true,
+		},
+	}
+	return cs.managedInsertContract(header, roots)
+}
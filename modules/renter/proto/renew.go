@@ -19,9 +19,19 @@ func (cs *ContractSet) Renew(oldContract *SafeContract, params ContractParams, t
 
 	// Extract vars from params, for convenience.
 	host, funding, startHeight, endHeight, refundAddress := params.Host, params.Funding, params.StartHeight, params.EndHeight, params.RefundAddress
-	ourSK := contract.SecretKey
 	lastRev := contract.LastRevision()
 
+	// Derive a new key from the renter seed, so that the renewed contract can
+	// be recovered from the seed.
+	ourSK, ourPK := params.RenterSeed.contractKeys(host.PublicKey, startHeight)
+	uc := types.UnlockConditions{
+		PublicKeys: []types.SiaPublicKey{
+			types.Ed25519PublicKey(ourPK),
+			host.PublicKey,
+		},
+		SignaturesRequired: 2,
+	}
+
 	// Calculate additional basePrice and baseCollateral. If the contract height
 	// did not increase, basePrice and baseCollateral are zero.
 	var basePrice, baseCollateral types.Currency
@@ -70,7 +80,7 @@ func (cs *ContractSet) Renew(oldContract *SafeContract, params ContractParams, t
 		WindowStart:    endHeight,
 		WindowEnd:      endHeight + host.WindowSize,
 		Payout:         totalPayout,
-		UnlockHash:     lastRev.NewUnlockHash,
+		UnlockHash:     uc.UnlockHash(),
 		RevisionNumber: 0,
 		ValidProofOutputs: []types.SiacoinOutput{
 			// renter
@@ -96,6 +106,9 @@ func (cs *ContractSet) Renew(oldContract *SafeContract, params ContractParams, t
 	txnBuilder.AddFileContract(fc)
 	// add miner fee
 	txnBuilder.AddMinerFee(txnFee)
+	// Identify the contract as ours.
+	txn, _ := txnBuilder.View()
+	txnBuilder.AddArbitraryData(params.RenterSeed.contractData(txn, host.PublicKey))
 
 	// Create initial transaction set.
 	txn, parentTxns := txnBuilder.View()
@@ -204,7 +217,7 @@ func (cs *ContractSet) Renew(oldContract *SafeContract, params ContractParams, t
 	// create initial (no-op) revision, transaction, and signature
 	initRevision := types.FileContractRevision{
 		ParentID:          signedTxnSet[len(signedTxnSet)-1].FileContractID(0),
-		UnlockConditions:  lastRev.UnlockConditions,
+		UnlockConditions:  uc,
 		NewRevisionNumber: 1,
 
 		NewFileSize:           fc.FileSize,
@@ -0,0 +1,139 @@
+package proto
+
+import (
+	"bytes"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// The contracts formed by the renter carry an identifier in the arbitrary data
+// of their transaction, which allows the renter to find them on the
+// blockchain given only its seed. The identifier is followed by the public key
+// of the host, encrypted with a key derived from the seed. The secret key of
+// a contract is derived from the seed, the public key of the host and the
+// height at which the contract was formed, which allows the renter to prove to
+// the host that it controls the contract. Since a contract is confirmed
+// shortly after it is formed, its key is recovered by scanning the heights
+// preceding the height at which it was found.
+const (
+	// keyScanRange is the number of heights preceding the height of a
+	// recovered contract that are scanned for its key. Contract transactions
+	// that aren't confirmed within this range have been dropped by the
+	// transaction pool.
+	keyScanRange = types.BlockHeight(144)
+)
+
+var (
+	// renterSeedSpecifier is mixed into the wallet seed to derive the renter
+	// seed.
+	renterSeedSpecifier = types.Specifier{'r', 'e', 'n', 't', 'e', 'r', ' ', 's', 'e', 'e', 'd'}
+
+	contractIdentifierSpecifier = types.Specifier{'c', 'o', 'n', 't', 'r', 'a', 'c', 't', ' ', 'i', 'd'}
+	contractKeySpecifier        = types.Specifier{'c', 'o', 'n', 't', 'r', 'a', 'c', 't', ' ', 'k', 'e', 'y'}
+	hostKeySpecifier            = types.Specifier{'h', 'o', 's', 't', ' ', 'k', 'e', 'y'}
+)
+
+type (
+	// A RenterSeed is derived from the primary seed of the wallet. The keys
+	// and identifiers of the renter's contracts are derived from it.
+	RenterSeed crypto.Hash
+
+	// A RecoverableContract is a contract of the renter that was found on the
+	// blockchain.
+	RecoverableContract struct {
+		types.FileContract
+		ID            types.FileContractID
+		HostPublicKey types.SiaPublicKey
+		StartHeight   types.BlockHeight
+		TxnFee        types.Currency
+	}
+)
+
+// DeriveRenterSeed derives the renter seed from the primary seed of the
+// wallet.
+func DeriveRenterSeed(walletSeed modules.Seed) RenterSeed {
+	return RenterSeed(crypto.HashAll(renterSeedSpecifier, walletSeed))
+}
+
+// contractKeys returns the key pair of the renter's contract with a host that
+// was formed at startHeight.
+func (rs RenterSeed) contractKeys(hostKey types.SiaPublicKey, startHeight types.BlockHeight) (crypto.SecretKey, crypto.PublicKey) {
+	return crypto.GenerateKeyPairDeterministic([crypto.EntropySize]byte(crypto.HashAll(rs, contractKeySpecifier, hostKey, startHeight)))
+}
+
+// recoverContractKey returns the secret key of a contract that was found on
+// the blockchain. The heights preceding the height at which the contract was
+// found are scanned for the key that matches the unlock hash of the contract.
+func (rs RenterSeed) recoverContractKey(rc RecoverableContract) (crypto.SecretKey, bool) {
+	for i := types.BlockHeight(0); i <= keyScanRange && i <= rc.StartHeight; i++ {
+		sk, pk := rs.contractKeys(rc.HostPublicKey, rc.StartHeight-i)
+		uc := types.UnlockConditions{
+			PublicKeys: []types.SiaPublicKey{
+				types.Ed25519PublicKey(pk),
+				rc.HostPublicKey,
+			},
+			SignaturesRequired: 2,
+		}
+		if uc.UnlockHash() == rc.UnlockHash {
+			return sk, true
+		}
+	}
+	return crypto.SecretKey{}, false
+}
+
+// identifier returns the identifier of a contract transaction, which is
+// derived from the first siacoin input of the transaction.
+func (rs RenterSeed) identifier(txn types.Transaction) crypto.Hash {
+	if len(txn.SiacoinInputs) == 0 {
+		return crypto.Hash{}
+	}
+	return crypto.HashAll(rs, contractIdentifierSpecifier, txn.SiacoinInputs[0].ParentID)
+}
+
+// contractData returns the arbitrary data that identifies txn as a contract
+// with the host. txn must already be funded.
+func (rs RenterSeed) contractData(txn types.Transaction, hostKey types.SiaPublicKey) []byte {
+	key := crypto.TwofishKey(crypto.HashAll(rs, hostKeySpecifier))
+	id := rs.identifier(txn)
+	data := append([]byte(nil), modules.PrefixNonSia[:]...)
+	data = append(data, id[:]...)
+	return append(data, key.EncryptBytes(encoding.Marshal(hostKey))...)
+}
+
+// FindContract returns the contract formed by txn if it was formed with the
+// renter seed. The StartHeight of the contract is not set.
+func (rs RenterSeed) FindContract(txn types.Transaction) (RecoverableContract, bool) {
+	if len(txn.FileContracts) == 0 {
+		return RecoverableContract{}, false
+	}
+	id := rs.identifier(txn)
+	prefixLen := len(modules.PrefixNonSia) + len(id)
+	for _, data := range txn.ArbitraryData {
+		if len(data) <= prefixLen || !bytes.Equal(data[:len(modules.PrefixNonSia)], modules.PrefixNonSia[:]) ||
+			!bytes.Equal(data[len(modules.PrefixNonSia):prefixLen], id[:]) {
+			continue
+		}
+		key := crypto.TwofishKey(crypto.HashAll(rs, hostKeySpecifier))
+		plaintext, err := key.DecryptBytes(data[prefixLen:])
+		if err != nil {
+			continue
+		}
+		var hostKey types.SiaPublicKey
+		if err := encoding.Unmarshal(plaintext, &hostKey); err != nil {
+			continue
+		}
+		rc := RecoverableContract{
+			FileContract:  txn.FileContracts[0],
+			ID:            txn.FileContractID(0),
+			HostPublicKey: hostKey,
+		}
+		for _, fee := range txn.MinerFees {
+			rc.TxnFee = rc.TxnFee.Add(fee)
+		}
+		return rc, true
+	}
+	return RecoverableContract{}, false
+}
@@ -0,0 +1,64 @@
+package proto
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestRenterSeedFindContract checks that only the renter seed that formed a
+// contract can find it on the blockchain.
+func TestRenterSeedFindContract(t *testing.T) {
+	rs := DeriveRenterSeed(modules.Seed{1})
+	hostKey := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{1, 2, 3}}
+	txn := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{ParentID: types.SiacoinOutputID{1}}},
+		FileContracts: []types.FileContract{{WindowStart: 10}},
+		MinerFees:     []types.Currency{types.NewCurrency64(5)},
+	}
+	txn.ArbitraryData = [][]byte{rs.contractData(txn, hostKey)}
+
+	rc, found := rs.FindContract(txn)
+	if !found {
+		t.Fatal("contract wasn't found")
+	}
+	if rc.ID != txn.FileContractID(0) || rc.WindowStart != 10 || !rc.TxnFee.Equals64(5) {
+		t.Fatal("found the wrong contract:", rc)
+	}
+	if rc.HostPublicKey.String() != hostKey.String() {
+		t.Fatal("wrong host key:", rc.HostPublicKey)
+	}
+
+	// Other seeds shouldn't find the contract.
+	if _, found := DeriveRenterSeed(modules.Seed{2}).FindContract(txn); found {
+		t.Fatal("contract was found with another seed")
+	}
+	// The identifier is bound to the inputs of the transaction.
+	txn.SiacoinInputs[0].ParentID = types.SiacoinOutputID{2}
+	if _, found := rs.FindContract(txn); found {
+		t.Fatal("contract was found in another transaction")
+	}
+
+	// Contract keys are derived from the seed, the host and the start height
+	// of the contract, and are recovered by scanning the preceding heights.
+	sk, pk := rs.contractKeys(hostKey, 100)
+	if otherSK, _ := rs.contractKeys(types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{4}}, 100); otherSK == sk {
+		t.Fatal("contract keys of different hosts are equal")
+	}
+	if otherSK, _ := rs.contractKeys(hostKey, 101); otherSK == sk {
+		t.Fatal("contract keys of different heights are equal")
+	}
+	rc.UnlockHash = types.UnlockConditions{
+		PublicKeys:         []types.SiaPublicKey{types.Ed25519PublicKey(pk), hostKey},
+		SignaturesRequired: 2,
+	}.UnlockHash()
+	rc.StartHeight = 100 + keyScanRange
+	if recovered, ok := rs.recoverContractKey(rc); !ok || recovered != sk {
+		t.Fatal("contract key wasn't recovered")
+	}
+	rc.StartHeight = 99
+	if _, ok := rs.recoverContractKey(rc); ok {
+		t.Fatal("contract key was recovered from a height before its formation")
+	}
+}
@@ -0,0 +1,253 @@
+package renter
+
+// recovery.go recovers the renter from the wallet seed alone.
+//
+// The contracts of the renter are recovered from the blockchain by the
+// contractor. To recover the files as well, the renter can upload its backups
+// to its hosts. A remote backup is split into sectors, each of which starts
+// with a metadataHeader. The tag of the header is derived from the backup key
+// and the public key of the host, so only the renter can recognize its
+// backups. When recovering, the renter searches the most recent sectors of
+// every contract for the headers of a backup, and downloads the newest one.
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// backupScanDepth is the number of sectors at the end of a contract that
+	// are searched for a remote backup.
+	backupScanDepth = 64
+
+	// metadataHeaderSize is the size of an encoded metadataHeader.
+	metadataHeaderSize = crypto.HashSize + 4*8
+)
+
+var (
+	// metadataTagSpecifier is mixed into the backup key to derive the tag of
+	// the sectors of a remote backup.
+	metadataTagSpecifier = types.Specifier{'m', 'e', 't', 'a', 'd', 'a', 't', 'a', ' ', 't', 'a', 'g'}
+
+	// errNoBackupHosts is returned when a remote backup couldn't be uploaded
+	// to any host.
+	errNoBackupHosts = errors.New("unable to upload the backup to any host")
+
+	// errIncompleteBackup is returned when a sector of a remote backup is
+	// missing.
+	errIncompleteBackup = errors.New("remote backup is incomplete")
+)
+
+// A metadataHeader precedes the data in every sector of a remote backup.
+// Created identifies the backup, Index is the index of the sector within the
+// backup and Length is the number of bytes of backup data in the sector.
+type metadataHeader struct {
+	Tag     crypto.Hash
+	Created uint64
+	Index   uint64
+	Count   uint64
+	Length  uint64
+}
+
+// metadataTag returns the tag of the remote backups stored on a host.
+func metadataTag(key crypto.TwofishKey, hostKey types.SiaPublicKey) crypto.Hash {
+	return crypto.HashAll(metadataTagSpecifier, key, hostKey)
+}
+
+// backupSectors splits the encrypted backup data into sectors for the host.
+func backupSectors(data []byte, tag crypto.Hash, created uint64) [][]byte {
+	capacity := modules.SectorSize - metadataHeaderSize
+	count := (uint64(len(data)) + capacity - 1) / capacity
+	sectors := make([][]byte, 0, count)
+	for i := uint64(0); i < count; i++ {
+		chunk := data[i*capacity:]
+		if uint64(len(chunk)) > capacity {
+			chunk = chunk[:capacity]
+		}
+		sector := make([]byte, modules.SectorSize)
+		copy(sector, encoding.Marshal(metadataHeader{
+			Tag:     tag,
+			Created: created,
+			Index:   i,
+			Count:   count,
+			Length:  uint64(len(chunk)),
+		}))
+		copy(sector[metadataHeaderSize:], chunk)
+		sectors = append(sectors, sector)
+	}
+	return sectors
+}
+
+// readMetadataHeader decodes the metadataHeader at the start of data. It
+// returns false if data doesn't start with a valid header with the tag.
+func readMetadataHeader(data []byte, tag crypto.Hash) (metadataHeader, bool) {
+	var h metadataHeader
+	if len(data) < metadataHeaderSize || encoding.Unmarshal(data[:metadataHeaderSize], &h) != nil {
+		return metadataHeader{}, false
+	}
+	valid := h.Tag == tag && h.Index < h.Count && h.Length <= modules.SectorSize-metadataHeaderSize
+	return h, valid
+}
+
+// UploadBackup uploads an encrypted backup of the renter's files and settings
+// to every host that the renter is uploading to. The backup can be recovered
+// with the wallet seed alone.
+func (r *Renter) UploadBackup() error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	key, err := r.backupKey()
+	if err != nil {
+		return err
+	}
+	data, err := encryptBackup(r.managedBackup(), key)
+	if err != nil {
+		return err
+	}
+	created := uint64(time.Now().UnixNano())
+
+	var uploaded int
+	for _, contract := range r.hostContractor.Contracts() {
+		utility, ok := r.hostContractor.ContractUtility(contract.ID)
+		if !ok || !utility.GoodForUpload {
+			continue
+		}
+		sectors := backupSectors(data, metadataTag(key, contract.HostPublicKey), created)
+		if err := r.managedUploadSectors(contract.ID, sectors); err != nil {
+			r.log.Println("WARN: unable to upload backup to contract", contract.ID, err)
+			continue
+		}
+		uploaded++
+	}
+	if uploaded == 0 {
+		return errNoBackupHosts
+	}
+	r.log.Printf("Uploaded backup to %v hosts", uploaded)
+	return nil
+}
+
+// managedUploadSectors uploads sectors to the host of a contract.
+func (r *Renter) managedUploadSectors(id types.FileContractID, sectors [][]byte) error {
+	editor, err := r.hostContractor.Editor(id, r.tg.StopChan())
+	if err != nil {
+		return err
+	}
+	defer editor.Close()
+	for _, sector := range sectors {
+		if _, _, err := editor.Upload(sector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// managedFindBackup searches the most recent sectors of a contract for the
+// newest remote backup. It returns the header of the found sector along with
+// the index of the first sector of the backup.
+func (r *Renter) managedFindBackup(contract modules.RenterContract, key crypto.TwofishKey) (metadataHeader, int, bool, error) {
+	roots, err := r.hostContractor.MerkleRoots(contract.ID)
+	if err != nil {
+		return metadataHeader{}, 0, false, err
+	}
+	downloader, err := r.hostContractor.Downloader(contract.ID, r.tg.StopChan())
+	if err != nil {
+		return metadataHeader{}, 0, false, err
+	}
+	defer downloader.Close()
+
+	tag := metadataTag(key, contract.HostPublicKey)
+	for i := len(roots) - 1; i >= 0 && i >= len(roots)-backupScanDepth; i-- {
+		data, _, err := downloader.PartialSector(roots[i], 0, metadataHeaderSize)
+		if err != nil {
+			return metadataHeader{}, 0, false, err
+		}
+		if h, ok := readMetadataHeader(data, tag); ok && uint64(i) >= h.Index && uint64(i)-h.Index+h.Count <= uint64(len(roots)) {
+			return h, i - int(h.Index), true, nil
+		}
+	}
+	return metadataHeader{}, 0, false, nil
+}
+
+// managedDownloadBackup downloads the sectors of a remote backup from the host
+// of a contract, starting at the sector with index first.
+func (r *Renter) managedDownloadBackup(contract modules.RenterContract, key crypto.TwofishKey, h metadataHeader, first int) (backup, error) {
+	roots, err := r.hostContractor.MerkleRoots(contract.ID)
+	if err != nil {
+		return backup{}, err
+	}
+	downloader, err := r.hostContractor.Downloader(contract.ID, r.tg.StopChan())
+	if err != nil {
+		return backup{}, err
+	}
+	defer downloader.Close()
+	if uint64(first)+h.Count > uint64(len(roots)) {
+		return backup{}, errIncompleteBackup
+	}
+
+	tag := metadataTag(key, contract.HostPublicKey)
+	var data []byte
+	for i := uint64(0); i < h.Count; i++ {
+		sector, _, err := downloader.Sector(roots[first+int(i)])
+		if err != nil {
+			return backup{}, err
+		}
+		sh, ok := readMetadataHeader(sector, tag)
+		if !ok || sh.Created != h.Created || sh.Index != i || sh.Count != h.Count {
+			return backup{}, errIncompleteBackup
+		}
+		data = append(data, sector[metadataHeaderSize:metadataHeaderSize+sh.Length]...)
+	}
+	return decryptBackup(data, key)
+}
+
+// Recover recovers the renter's contracts from the blockchain, and restores
+// the files and settings of the newest backup that was uploaded to the hosts
+// of the recovered contracts.
+func (r *Renter) Recover() (modules.BackupInfo, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.BackupInfo{}, err
+	}
+	defer r.tg.Done()
+
+	key, err := r.backupKey()
+	if err != nil {
+		return modules.BackupInfo{}, err
+	}
+	contracts, err := r.hostContractor.RecoverContracts()
+	if err != nil {
+		return modules.BackupInfo{}, err
+	}
+	info := modules.BackupInfo{Contracts: uint64(contracts)}
+
+	// Find the newest remote backup.
+	var newest metadataHeader
+	var newestContract modules.RenterContract
+	var newestFirst int
+	for _, contract := range r.hostContractor.Contracts() {
+		h, first, found, err := r.managedFindBackup(contract, key)
+		if err != nil {
+			r.log.Println("WARN: unable to search contract", contract.ID, "for backups:", err)
+			continue
+		}
+		if found && h.Created > newest.Created {
+			newest, newestContract, newestFirst = h, contract, first
+		}
+	}
+	if newest.Count == 0 {
+		return info, nil
+	}
+
+	b, err := r.managedDownloadBackup(newestContract, key, newest, newestFirst)
+	if err != nil {
+		return info, err
+	}
+	info.Files, err = r.managedRestoreFiles(b)
+	return info, err
+}
@@ -0,0 +1,45 @@
+package renter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestBackupSectors checks that a remote backup can be reassembled from its
+// sectors.
+func TestBackupSectors(t *testing.T) {
+	data := fastrand.Bytes(int(modules.SectorSize) + 10)
+	tag := crypto.Hash{1}
+	sectors := backupSectors(data, tag, 42)
+	if len(sectors) != 2 {
+		t.Fatal("expected 2 sectors, got", len(sectors))
+	}
+
+	var reassembled []byte
+	for i, sector := range sectors {
+		if uint64(len(sector)) != modules.SectorSize {
+			t.Fatal("sector has the wrong size:", len(sector))
+		}
+		h, ok := readMetadataHeader(sector, tag)
+		if !ok || h.Created != 42 || h.Index != uint64(i) || h.Count != 2 {
+			t.Fatal("sector has the wrong header:", h)
+		}
+		reassembled = append(reassembled, sector[metadataHeaderSize:metadataHeaderSize+h.Length]...)
+	}
+	if !bytes.Equal(reassembled, data) {
+		t.Fatal("reassembled backup doesn't match the original")
+	}
+
+	// Sectors with another tag, and sectors of regular files, aren't part of
+	// the backup.
+	if _, ok := readMetadataHeader(sectors[0], crypto.Hash{2}); ok {
+		t.Fatal("header with the wrong tag was accepted")
+	}
+	if _, ok := readMetadataHeader(fastrand.Bytes(int(modules.SectorSize)), tag); ok {
+		t.Fatal("random sector was accepted")
+	}
+}
@@ -22,11 +22,14 @@ package renter
 
 import (
 	"errors"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/renter/contractor"
 	"github.com/NebulousLabs/Sia/modules/renter/hostdb"
@@ -43,6 +46,7 @@ var (
 	errNilGateway    = errors.New("cannot create hostdb with nil gateway")
 	errNilHdb        = errors.New("cannot create renter with nil hostdb")
 	errNilTpool      = errors.New("cannot create renter with nil transaction pool")
+	errNilWallet     = errors.New("cannot create renter with nil wallet")
 )
 
 var (
@@ -74,6 +78,14 @@ type hostDB interface {
 	// Close closes the hostdb.
 	Close() error
 
+	// DiversityViolations returns the groups of hosts that share a subnet or
+	// a region according to the diversity policy of the hostdb.
+	DiversityViolations([]types.SiaPublicKey) []modules.HostDiversityViolation
+
+	// FilterMode returns the filter mode of the hostdb and the hosts it
+	// applies to.
+	FilterMode() (modules.FilterMode, []types.SiaPublicKey)
+
 	// Host returns the HostDBEntry for a given host.
 	Host(types.SiaPublicKey) (modules.HostDBEntry, bool)
 
@@ -86,6 +98,15 @@ type hostDB interface {
 	// of the host.
 	ScoreBreakdown(modules.HostDBEntry) modules.HostScoreBreakdown
 
+	// SetDiversityPolicy sets whether hosts that share a subnet are selected
+	// and the path of the GeoIP database that is used to avoid selecting
+	// hosts in the same region.
+	SetDiversityPolicy(subnets bool, geoIPPath string) error
+
+	// SetFilterMode sets the filter mode of the hostdb and the hosts it
+	// applies to.
+	SetFilterMode(modules.FilterMode, []types.SiaPublicKey) error
+
 	// EstimateHostScore returns the estimated score breakdown of a host with the
 	// provided settings.
 	EstimateHostScore(modules.HostDBEntry) modules.HostScoreBreakdown
@@ -106,6 +127,10 @@ type hostContractor interface {
 	// Close closes the hostContractor.
 	Close() error
 
+	// BackupContracts returns a backup of the contracts formed by the
+	// contractor.
+	BackupContracts() contractor.ContractsBackup
+
 	// Contracts returns the contracts formed by the contractor.
 	Contracts() []modules.RenterContract
 
@@ -135,9 +160,32 @@ type hostContractor interface {
 	// allowing the retrieval of sectors.
 	Downloader(types.FileContractID, <-chan struct{}) (contractor.Downloader, error)
 
+	// DownloadTicket creates a signed download ticket that allows other
+	// renters to download the specified sectors of a contract until the
+	// expiration height.
+	DownloadTicket(types.FileContractID, []crypto.Hash, types.BlockHeight) (modules.DownloadTicket, error)
+
+	// MerkleRoots returns the Merkle roots of the sectors covered by the
+	// specified contract.
+	MerkleRoots(types.FileContractID) ([]crypto.Hash, error)
+
+	// RecoverContracts scans the blockchain for the contracts formed with the
+	// wallet seed, recovers them from their hosts and returns the number of
+	// recovered contracts.
+	RecoverContracts() (int, error)
+
 	// ResolveID returns the most recent renewal of the specified ID.
 	ResolveID(types.FileContractID) types.FileContractID
 
+	// RestoreContracts adds the contracts of a backup to the contractor and
+	// returns the number of restored active contracts.
+	RestoreContracts(contractor.ContractsBackup) (int, error)
+
+	// TicketDownloader creates a Downloader from the specified contract ID
+	// that retrieves the sectors granted by a download ticket of another
+	// renter.
+	TicketDownloader(types.FileContractID, modules.DownloadTicket, <-chan struct{}) (contractor.Downloader, error)
+
 	// RateLimits Gets the bandwidth limits for connections created by the
 	// contractor and its submodules.
 	RateLimits() (readBPS int64, writeBPS int64, packetSize uint64)
@@ -154,6 +202,16 @@ type hostContractor interface {
 type trackedFile struct {
 	// location of original file on disk
 	RepairPath string
+
+	// Streaming is set while the file is being uploaded from a stream. The
+	// repair loop ignores such files until the stream has finished, and an
+	// interrupted stream can be resumed.
+	Streaming bool
+
+	// Priority is the priority class of the uploads and repairs of the file.
+	// The repair loop ignores the file while it is Paused.
+	Priority modules.Priority
+	Paused   bool
 }
 
 // A Renter is responsible for tracking all of the files that a user has
@@ -170,7 +228,11 @@ type Renter struct {
 	//
 	// tracking contains a list of files that the user intends to maintain. By
 	// default, files loaded through sharing are not maintained by the user.
+	//
+	// dirs contains the directory tree of the renter's files, keyed by the
+	// siapath of each directory. The root directory has the empty siapath.
 	files map[string]*file
+	dirs  map[string]*siaDir
 
 	// Download management. The heap has a separate mutex because it is always
 	// accessed in isolation.
@@ -186,8 +248,51 @@ type Renter struct {
 	downloadHistory   []*download
 	downloadHistoryMu sync.Mutex
 
-	// Upload management.
-	uploadHeap uploadHeap
+	// Streaming. streams contains the open streams of the /renter/stream
+	// endpoint, keyed by their ID, and is protected by the renter mutex.
+	streams map[string]*streamer
+
+	// Mounted filesystems, keyed by their mount point. Protected by the
+	// renter mutex.
+	mounts map[string]*fuseMount
+
+	// Upload management. streamUploads contains the files that are currently
+	// being uploaded from a stream.
+	uploadHeap    uploadHeap
+	streamUploads map[*file]struct{}
+
+	// Small file packing. packs contains the packs storing the renter's small
+	// files and is protected by the renter mutex. The pack queue contains the
+	// small files waiting to be packed along with the time they were queued,
+	// packsActive contains the files that are currently being packed. Both
+	// are protected by packMu.
+	packs       map[string]*pack
+	packMu      sync.Mutex
+	packQueue   map[*file]time.Time
+	packsActive map[*file]struct{}
+
+	// Deduplication. dedupChunks is the index of the chunks of deduplicated
+	// files, keyed by their dedup ID. dedupSecret is mixed into the
+	// convergent keys of the chunks. Both are protected by the renter mutex.
+	dedupChunks map[crypto.Hash]*dedupChunk
+	dedupSecret crypto.Hash
+
+	// Spending ledger. spending contains the money spent on every file per
+	// allowance period, spendingDirty is set when the ledger changed since it
+	// was last saved. Both are protected by spendingMu.
+	spending      map[spendingKey]modules.DataSpending
+	spendingDirty bool
+	spendingMu    sync.Mutex
+
+	// File health. health contains the health of every file as it was last
+	// saved to the health file, keyed by siapath. It is protected by the
+	// renter mutex.
+	health map[string]fileHealth
+
+	// Re-encryption. reencryptions contains the re-encryptions that are in
+	// progress or have failed, keyed by the file that is being re-encrypted.
+	// It is protected by the renter mutex.
+	reencryptions map[*file]*reencryption
 
 	// List of workers that can be used for uploading and/or downloading.
 	memoryManager *memoryManager
@@ -197,6 +302,7 @@ type Renter struct {
 	lastEstimation modules.RenterPriceEstimation
 
 	// Utilities.
+	staticChunkCache  *chunkCache
 	staticStreamCache *streamCache
 	cs                modules.ConsensusSet
 	deps              modules.Dependencies
@@ -209,6 +315,7 @@ type Renter struct {
 	mu                *siasync.RWMutex
 	tg                threadgroup.ThreadGroup
 	tpool             modules.TransactionPool
+	wallet            modules.Wallet
 }
 
 // Close closes the Renter and its dependencies
@@ -325,8 +432,17 @@ func (r *Renter) SetSettings(s modules.RenterSettings) error {
 		return errors.New("stream cache size needs to be 1 or larger")
 	}
 
+	// Set the diversity policy first, since loading the GeoIP database may
+	// fail.
+	err := r.hostDB.SetDiversityPolicy(s.SubnetDiversity, s.GeoIPDatabase)
+	if err != nil {
+		return err
+	}
+	r.persist.SubnetDiversity = s.SubnetDiversity
+	r.persist.GeoIPDatabase = s.GeoIPDatabase
+
 	// Set allowance.
-	err := r.hostContractor.SetAllowance(s.Allowance)
+	err = r.hostContractor.SetAllowance(s.Allowance)
 	if err != nil {
 		return err
 	}
@@ -346,6 +462,10 @@ func (r *Renter) SetSettings(s modules.RenterSettings) error {
 	}
 	r.persist.StreamCacheSize = s.StreamCacheSize
 
+	// Set the capacity of the chunk cache.
+	r.staticChunkCache.SetCapacity(s.ChunkCacheSize)
+	r.persist.ChunkCacheSize = s.ChunkCacheSize
+
 	// Save the changes.
 	err = r.saveSync()
 	if err != nil {
@@ -364,6 +484,12 @@ func (r *Renter) ActiveHosts() []modules.HostDBEntry { return r.hostDB.ActiveHos
 // AllHosts returns an array of all hosts
 func (r *Renter) AllHosts() []modules.HostDBEntry { return r.hostDB.AllHosts() }
 
+// FilterMode returns the filter mode of the hostdb and the hosts it applies
+// to.
+func (r *Renter) FilterMode() (modules.FilterMode, []types.SiaPublicKey) {
+	return r.hostDB.FilterMode()
+}
+
 // Host returns the host associated with the given public key
 func (r *Renter) Host(spk types.SiaPublicKey) (modules.HostDBEntry, bool) { return r.hostDB.Host(spk) }
 
@@ -377,9 +503,29 @@ func (r *Renter) EstimateHostScore(e modules.HostDBEntry) modules.HostScoreBreak
 	return r.hostDB.EstimateHostScore(e)
 }
 
+// SetFilterMode sets the filter mode of the hostdb. Contracts with hosts that
+// are excluded by the filter are marked !GoodForRenew by the contractor, which
+// causes their data to be migrated to other hosts.
+func (r *Renter) SetFilterMode(fm modules.FilterMode, hosts []types.SiaPublicKey) error {
+	if wanted := r.hostContractor.Allowance().Hosts; fm == modules.HostDBFilterWhitelist && uint64(len(hosts)) < wanted {
+		r.log.Printf("WARN: whitelisting %v hosts, but the allowance requires %v hosts", len(hosts), wanted)
+	}
+	return r.hostDB.SetFilterMode(fm, hosts)
+}
+
 // Contracts returns an array of host contractor's contracts
 func (r *Renter) Contracts() []modules.RenterContract { return r.hostContractor.Contracts() }
 
+// DiversityViolations returns the groups of hosts that the renter has
+// contracts with which share a subnet or a region.
+func (r *Renter) DiversityViolations() []modules.HostDiversityViolation {
+	var hosts []types.SiaPublicKey
+	for _, c := range r.hostContractor.Contracts() {
+		hosts = append(hosts, c.HostPublicKey)
+	}
+	return r.hostDB.DiversityViolations(hosts)
+}
+
 // CurrentPeriod returns the host contractor's current period
 func (r *Renter) CurrentPeriod() types.BlockHeight { return r.hostContractor.CurrentPeriod() }
 
@@ -400,6 +546,9 @@ func (r *Renter) Settings() modules.RenterSettings {
 		MaxDownloadSpeed: download,
 		MaxUploadSpeed:   upload,
 		StreamCacheSize:  r.staticStreamCache.cacheSize,
+		ChunkCacheSize:   r.persist.ChunkCacheSize,
+		SubnetDiversity:  r.persist.SubnetDiversity,
+		GeoIPDatabase:    r.persist.GeoIPDatabase,
 	}
 }
 
@@ -445,13 +594,16 @@ func validateSiapath(siapath string) error {
 var _ modules.Renter = (*Renter)(nil)
 
 // NewCustomRenter initializes a renter and returns it.
-func NewCustomRenter(g modules.Gateway, cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostDB, hc hostContractor, persistDir string, deps modules.Dependencies) (*Renter, error) {
+func NewCustomRenter(g modules.Gateway, cs modules.ConsensusSet, wallet modules.Wallet, tpool modules.TransactionPool, hdb hostDB, hc hostContractor, persistDir string, deps modules.Dependencies) (*Renter, error) {
 	if g == nil {
 		return nil, errNilGateway
 	}
 	if cs == nil {
 		return nil, errNilCS
 	}
+	if wallet == nil {
+		return nil, errNilWallet
+	}
 	if tpool == nil {
 		return nil, errNilTpool
 	}
@@ -464,6 +616,9 @@ func NewCustomRenter(g modules.Gateway, cs modules.ConsensusSet, tpool modules.T
 
 	r := &Renter{
 		files: make(map[string]*file),
+		dirs: map[string]*siaDir{
+			"": newSiaDir(""),
+		},
 
 		// Making newDownloads a buffered channel means that most of the time, a
 		// new download will trigger an unnecessary extra iteration of the
@@ -477,6 +632,21 @@ func NewCustomRenter(g modules.Gateway, cs modules.ConsensusSet, tpool modules.T
 			activeChunks: make(map[uploadChunkID]struct{}),
 			newUploads:   make(chan struct{}, 1),
 		},
+		streams:       make(map[string]*streamer),
+		mounts:        make(map[string]*fuseMount),
+		streamUploads: make(map[*file]struct{}),
+
+		packs:       make(map[string]*pack),
+		packQueue:   make(map[*file]time.Time),
+		packsActive: make(map[*file]struct{}),
+
+		dedupChunks: make(map[crypto.Hash]*dedupChunk),
+
+		spending: make(map[spendingKey]modules.DataSpending),
+
+		health: make(map[string]fileHealth),
+
+		reencryptions: make(map[*file]*reencryption),
 
 		workerPool: make(map[types.FileContractID]*worker),
 
@@ -488,6 +658,7 @@ func NewCustomRenter(g modules.Gateway, cs modules.ConsensusSet, tpool modules.T
 		persistDir:     persistDir,
 		mu:             siasync.New(modules.SafeMutexDelay, 1),
 		tpool:          tpool,
+		wallet:         wallet,
 	}
 	r.memoryManager = newMemoryManager(defaultMemory, r.tg.StopChan())
 
@@ -509,6 +680,22 @@ func NewCustomRenter(g modules.Gateway, cs modules.ConsensusSet, tpool modules.T
 	// Initialize the streaming cache.
 	r.staticStreamCache = newStreamCache(r.persist.StreamCacheSize)
 
+	// Initialize the chunk cache.
+	r.staticChunkCache, err = newChunkCache(filepath.Join(r.persistDir, ChunkCacheDir), r.persist.ChunkCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// Restore the diversity policy of the hostdb. If the GeoIP database can't
+	// be loaded anymore, only the subnet diversity is enforced.
+	err = r.hostDB.SetDiversityPolicy(r.persist.SubnetDiversity, r.persist.GeoIPDatabase)
+	if err != nil {
+		r.log.Println("WARN: region diversity disabled:", err)
+		if err := r.hostDB.SetDiversityPolicy(r.persist.SubnetDiversity, ""); err != nil {
+			return nil, err
+		}
+	}
+
 	// Subscribe to the consensus set.
 	err = cs.ConsensusSetSubscribe(r, modules.ConsensusChangeRecent, r.tg.StopChan())
 	if err != nil {
@@ -519,6 +706,8 @@ func NewCustomRenter(g modules.Gateway, cs modules.ConsensusSet, tpool modules.T
 	r.managedUpdateWorkerPool()
 	go r.threadedDownloadLoop()
 	go r.threadedUploadLoop()
+	go r.threadedSaveSpending()
+	go r.threadedSaveChunkCache()
 
 	// Kill workers on shutdown.
 	r.tg.OnStop(func() error {
@@ -530,6 +719,22 @@ func NewCustomRenter(g modules.Gateway, cs modules.ConsensusSet, tpool modules.T
 		return nil
 	})
 
+	// Unmount the mounted filesystems on shutdown.
+	r.tg.OnStop(func() error {
+		r.managedUnmountAll()
+		return nil
+	})
+
+	// Save the spending ledger once the workers have stopped.
+	r.tg.AfterStop(func() error {
+		return r.managedSaveSpending()
+	})
+
+	// Save the index of the chunk cache once the downloads have stopped.
+	r.tg.AfterStop(func() error {
+		return r.staticChunkCache.Close()
+	})
+
 	return r, nil
 }
 
@@ -544,5 +749,5 @@ func New(g modules.Gateway, cs modules.ConsensusSet, wallet modules.Wallet, tpoo
 		return nil, err
 	}
 
-	return NewCustomRenter(g, cs, tpool, hdb, hc, persistDir, modules.ProdDependencies)
+	return NewCustomRenter(g, cs, wallet, tpool, hdb, hc, persistDir, modules.ProdDependencies)
 }
@@ -121,6 +121,14 @@ func (stubHostDB) Host(types.SiaPublicKey) (modules.HostDBEntry, bool) {
 func (stubHostDB) ScoreBreakdown(modules.HostDBEntry) modules.HostScoreBreakdown {
 	return modules.HostScoreBreakdown{}
 }
+func (stubHostDB) FilterMode() (modules.FilterMode, []types.SiaPublicKey) {
+	return modules.HostDBFilterDisabled, nil
+}
+func (stubHostDB) SetFilterMode(modules.FilterMode, []types.SiaPublicKey) error { return nil }
+func (stubHostDB) DiversityViolations([]types.SiaPublicKey) []modules.HostDiversityViolation {
+	return nil
+}
+func (stubHostDB) SetDiversityPolicy(bool, string) error { return nil }
 
 // stubContractor is the minimal implementation of the hostContractor
 // interface.
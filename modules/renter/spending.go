@@ -0,0 +1,212 @@
+package renter
+
+// spending.go attributes the money that the renter spends on uploads, storage
+// and downloads to its files.
+//
+// Every time a worker uploads or downloads a sector, the contractor reports the
+// money that was spent on the revision of the contract. The renter adds it to
+// the entry of the file that the sector belongs to in the spending ledger. The
+// ledger has one entry per file and allowance period. The sectors of a pack are
+// shared by the packed files, so their cost is split between the files in
+// proportion to the size of their slots. Spending that doesn't belong to a
+// file, like the upload of remote backups, isn't recorded.
+//
+// Entries of deleted files are kept, since the money was spent nonetheless.
+// Entries of renamed files move along with the file. The ledger is saved to
+// the spending file periodically and on shutdown.
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// SpendingFilename is the filename of the file that contains the
+	// renter's spending ledger.
+	SpendingFilename = "spending.json"
+)
+
+var (
+	spendingMetadata = persist.Metadata{
+		Header:  "Renter Spending",
+		Version: "1.0",
+	}
+)
+
+type (
+	// spendingKey identifies an entry of the spending ledger.
+	spendingKey struct {
+		siaPath string
+		period  types.BlockHeight
+	}
+
+	// spendingPersist is the object persisted in the spending file.
+	spendingPersist struct {
+		Entries []modules.FileSpending `json:"entries"`
+	}
+)
+
+// managedRecordSpending adds the money spent on a sector of the file at
+// siaPath to the ledger entry of the file for the current period.
+func (r *Renter) managedRecordSpending(siaPath string, spending modules.DataSpending) {
+	key := spendingKey{
+		siaPath: siaPath,
+		period:  r.hostContractor.CurrentPeriod(),
+	}
+	r.spendingMu.Lock()
+	r.spending[key] = r.spending[key].Add(spending)
+	r.spendingDirty = true
+	r.spendingMu.Unlock()
+}
+
+// managedRecordPackSpending splits the money spent on the sectors of a pack
+// between the packed files in proportion to the size of their slots.
+func (r *Renter) managedRecordPackSpending(members []packMember, spending modules.DataSpending) {
+	var size uint64
+	for _, m := range members {
+		size += m.f.slotLength()
+	}
+	if size == 0 {
+		return
+	}
+	for _, m := range members {
+		m.f.mu.RLock()
+		siaPath := m.f.name
+		m.f.mu.RUnlock()
+		length := m.f.slotLength()
+		r.managedRecordSpending(siaPath, modules.DataSpending{
+			DownloadSpending: spending.DownloadSpending.Mul64(length).Div64(size),
+			StorageSpending:  spending.StorageSpending.Mul64(length).Div64(size),
+			UploadSpending:   spending.UploadSpending.Mul64(length).Div64(size),
+		})
+	}
+}
+
+// managedRenameSpending moves the ledger entries of the file at siaPath to
+// newSiaPath, merging them with existing entries of newSiaPath.
+func (r *Renter) managedRenameSpending(siaPath, newSiaPath string) {
+	r.spendingMu.Lock()
+	defer r.spendingMu.Unlock()
+	for key, spending := range r.spending {
+		if key.siaPath != siaPath {
+			continue
+		}
+		newKey := spendingKey{
+			siaPath: newSiaPath,
+			period:  key.period,
+		}
+		r.spending[newKey] = r.spending[newKey].Add(spending)
+		delete(r.spending, key)
+		r.spendingDirty = true
+	}
+}
+
+// SpendingLedger returns the entries of the spending ledger selected by the
+// filter, sorted by siapath and period.
+func (r *Renter) SpendingLedger(filter modules.SpendingFilter) []modules.FileSpending {
+	r.spendingMu.Lock()
+	entries := make([]modules.FileSpending, 0, len(r.spending))
+	for key, spending := range r.spending {
+		if !strings.HasPrefix(key.siaPath, filter.Prefix) || key.period < filter.Since {
+			continue
+		}
+		if filter.Until != 0 && key.period > filter.Until {
+			continue
+		}
+		entries = append(entries, modules.FileSpending{
+			SiaPath:      key.siaPath,
+			PeriodStart:  key.period,
+			DataSpending: spending,
+		})
+	}
+	r.spendingMu.Unlock()
+	sortSpending(entries)
+	return entries
+}
+
+// sortSpending sorts ledger entries by siapath and period.
+func sortSpending(entries []modules.FileSpending) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].SiaPath != entries[j].SiaPath {
+			return entries[i].SiaPath < entries[j].SiaPath
+		}
+		return entries[i].PeriodStart < entries[j].PeriodStart
+	})
+}
+
+// managedSaveSpending writes the spending ledger to disk if it changed since
+// it was last saved.
+func (r *Renter) managedSaveSpending() error {
+	r.spendingMu.Lock()
+	if !r.spendingDirty {
+		r.spendingMu.Unlock()
+		return nil
+	}
+	sp := spendingPersist{
+		Entries: make([]modules.FileSpending, 0, len(r.spending)),
+	}
+	for key, spending := range r.spending {
+		sp.Entries = append(sp.Entries, modules.FileSpending{
+			SiaPath:      key.siaPath,
+			PeriodStart:  key.period,
+			DataSpending: spending,
+		})
+	}
+	r.spendingDirty = false
+	r.spendingMu.Unlock()
+
+	sortSpending(sp.Entries)
+	err := persist.SaveJSON(spendingMetadata, sp, filepath.Join(r.persistDir, SpendingFilename))
+	if err != nil {
+		// Try again next time.
+		r.spendingMu.Lock()
+		r.spendingDirty = true
+		r.spendingMu.Unlock()
+	}
+	return err
+}
+
+// loadSpending restores the spending ledger from disk.
+func (r *Renter) loadSpending() error {
+	var sp spendingPersist
+	err := persist.LoadJSON(spendingMetadata, &sp, filepath.Join(r.persistDir, SpendingFilename))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range sp.Entries {
+		key := spendingKey{
+			siaPath: entry.SiaPath,
+			period:  entry.PeriodStart,
+		}
+		r.spending[key] = r.spending[key].Add(entry.DataSpending)
+	}
+	return nil
+}
+
+// threadedSaveSpending periodically saves the spending ledger.
+func (r *Renter) threadedSaveSpending() {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	for {
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-time.After(spendingSaveInterval):
+		}
+		if err := r.managedSaveSpending(); err != nil {
+			r.log.Println("WARN: unable to save the spending ledger:", err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package renter
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestRenterSpendingLedger checks that the spending ledger attributes spending
+// to files, follows renames, filters its entries and survives a restart.
+func TestRenterSpendingLedger(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	for _, siaPath := range []string{"dir/a", "dir/b", "c"} {
+		if _, err := rt.addTestingFile(siaPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+	upload := modules.DataSpending{
+		StorageSpending: types.NewCurrency64(30),
+		UploadSpending:  types.NewCurrency64(10),
+	}
+	download := modules.DataSpending{
+		DownloadSpending: types.NewCurrency64(5),
+	}
+	rt.renter.managedRecordSpending("dir/a", upload)
+	rt.renter.managedRecordSpending("dir/a", download)
+	rt.renter.managedRecordSpending("dir/b", upload)
+	rt.renter.managedRecordSpending("c", download)
+
+	// The spending of a file should add up.
+	entries := rt.renter.SpendingLedger(modules.SpendingFilter{Prefix: "dir/a"})
+	if len(entries) != 1 || entries[0].Total().Cmp64(45) != 0 || entries[0].DownloadSpending.Cmp64(5) != 0 {
+		t.Fatal("wrong spending of dir/a:", entries)
+	}
+	if entries[0].PeriodStart != rt.renter.CurrentPeriod() {
+		t.Fatal("spending was recorded for the wrong period:", entries[0].PeriodStart)
+	}
+
+	// The spending of a pack should be split between the packed files.
+	rsc, _ := NewRSCode(1, 1)
+	small := []packMember{
+		{f: newFile("p1", rsc, 64, 64)},
+		{f: newFile("p2", rsc, 64, 64)},
+	}
+	rt.renter.managedRecordPackSpending(small, modules.DataSpending{UploadSpending: types.NewCurrency64(100)})
+	for _, siaPath := range []string{"p1", "p2"} {
+		entries := rt.renter.SpendingLedger(modules.SpendingFilter{Prefix: siaPath})
+		if len(entries) != 1 || entries[0].UploadSpending.Cmp64(50) != 0 {
+			t.Fatal("pack spending wasn't split evenly:", entries)
+		}
+	}
+
+	// Renaming a directory should move the entries of its files.
+	if err := rt.renter.RenameDir("dir", "dir2"); err != nil {
+		t.Fatal(err)
+	}
+	if entries := rt.renter.SpendingLedger(modules.SpendingFilter{Prefix: "dir/"}); len(entries) != 0 {
+		t.Fatal("entries weren't moved:", entries)
+	}
+	entries = rt.renter.SpendingLedger(modules.SpendingFilter{Prefix: "dir2/"})
+	if len(entries) != 2 || entries[0].SiaPath != "dir2/a" || entries[1].SiaPath != "dir2/b" {
+		t.Fatal("wrong entries after rename:", entries)
+	}
+
+	// Filtering by period should only return the entries of that period.
+	period := rt.renter.CurrentPeriod()
+	if entries := rt.renter.SpendingLedger(modules.SpendingFilter{Since: period + 1}); len(entries) != 0 {
+		t.Fatal("entries of earlier periods were returned:", entries)
+	}
+	if entries := rt.renter.SpendingLedger(modules.SpendingFilter{Since: period, Until: period}); len(entries) != 5 {
+		t.Fatal("wrong number of entries in the current period:", entries)
+	}
+
+	// Deleting a file should keep its entries and the ledger should be
+	// restored after a restart.
+	if err := rt.renter.DeleteFile("c"); err != nil {
+		t.Fatal(err)
+	}
+	before := rt.renter.SpendingLedger(modules.SpendingFilter{})
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, filepath.Join(rt.dir, modules.RenterDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := rt.renter.SpendingLedger(modules.SpendingFilter{})
+	if len(after) != len(before) {
+		t.Fatalf("ledger wasn't restored: %v != %v", after, before)
+	}
+	for i := range before {
+		if after[i].SiaPath != before[i].SiaPath || after[i].PeriodStart != before[i].PeriodStart || after[i].Total().Cmp(before[i].Total()) != 0 {
+			t.Fatalf("ledger wasn't restored: %v != %v", after, before)
+		}
+	}
+}
@@ -76,6 +76,13 @@ func (sc *streamCache) Add(cacheID string, data []byte) {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 
+	// If the chunk is already cached, e.g. because several streams fetched it
+	// at the same time, only update it.
+	if cd, cached := sc.streamMap[cacheID]; cached {
+		sc.streamHeap.update(cd, cd.id, data, time.Now())
+		return
+	}
+
 	// pruning cache to cacheSize - 1 to make room to add the new chunk
 	sc.pruneCache(sc.cacheSize - 1)
 
@@ -134,25 +141,7 @@ func (sc *streamCache) Retrieve(udc *unfinishedDownloadChunk) bool {
 	sc.streamMap[udc.staticCacheID] = cd
 	sc.streamHeap.update(cd, cd.id, cd.data, cd.lastAccess)
 
-	start := udc.staticFetchOffset
-	end := start + udc.staticFetchLength
-	_, err := udc.destination.WriteAt(cd.data[start:end], udc.staticWriteOffset)
-	if err != nil {
-		udc.fail(errors.AddContext(err, "failed to write cached chunk to destination"))
-		return true
-	}
-
-	// Check if the download is complete now.
-	udc.download.mu.Lock()
-	defer udc.download.mu.Unlock()
-
-	udc.download.chunksRemaining--
-	if udc.download.chunksRemaining == 0 {
-		udc.download.endTime = time.Now()
-		close(udc.download.completeChan)
-		udc.download.destination.Close()
-		udc.download.destination = nil
-	}
+	udc.writeCachedChunk(cd.data)
 	return true
 }
 
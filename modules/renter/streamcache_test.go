@@ -165,3 +165,17 @@ func TestStreamCache(t *testing.T) {
 		t.Error("chunk1 wasn't removed from the heap")
 	}
 }
+
+// TestStreamCacheAddTwice tests that adding a chunk that is already cached
+// doesn't add it to the cache twice.
+func TestStreamCacheAddTwice(t *testing.T) {
+	sc := newStreamCache(2)
+	sc.Add("chunk", []byte{1})
+	sc.Add("chunk", []byte{2})
+	if len(sc.streamMap) != 1 || len(sc.streamHeap) != 1 {
+		t.Fatalf("expected 1 cached chunk, got %v in map and %v in heap", len(sc.streamMap), len(sc.streamHeap))
+	}
+	if !reflect.DeepEqual(sc.streamMap["chunk"].data, []byte{2}) {
+		t.Error("cached chunk wasn't updated")
+	}
+}
@@ -0,0 +1,322 @@
+package renter
+
+// ticket.go shares files with other renters through download tickets.
+//
+// A download ticket is signed with the renter key of a contract and allows
+// other renters to download a set of sectors of that contract from its host
+// until the ticket expires. The renter that presents a ticket pays for the
+// bandwidth with its own contract with the host, so a shared file can only be
+// downloaded from the hosts that the recipient has contracts with.
+//
+// A ticket share contains the .sia encoding of the shared files along with a
+// ticket for every contract of every file. The contracts of the shared files
+// are merged with their renewals, so that they match the contracts of the
+// tickets. Shared files are read-only: they aren't repaired, re-encrypted or
+// shared again, and they can't be downloaded once their tickets have expired.
+// The tickets of the shared files are persisted in the tickets file.
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// TicketsFilename is the filename of the file that contains the download
+	// tickets of the files that were shared by other renters.
+	TicketsFilename = "tickets.json"
+)
+
+var (
+	ticketsMetadata = persist.Metadata{
+		Header:  "Renter Tickets",
+		Version: "1.0",
+	}
+
+	// errReencryptShared is returned when trying to re-encrypt a file that
+	// was shared by another renter.
+	errReencryptShared = errors.New("files shared by another renter can't be re-encrypted")
+
+	// errShareShared is returned when trying to share a file that was shared
+	// by another renter, since the tickets of the file can't be renewed.
+	errShareShared = errors.New("files shared by another renter can't be shared again")
+
+	// errTicketDuration is returned when trying to share files with tickets
+	// that expire immediately.
+	errTicketDuration = errors.New("download tickets must be valid for at least one block")
+
+	// errTicketShareMismatch is returned when loading a ticket share that
+	// doesn't contain the tickets of every file.
+	errTicketShareMismatch = errors.New("ticket share doesn't contain tickets for every file")
+)
+
+type (
+	// fileTicket is the download ticket for a contract of a shared file,
+	// along with the public key of the contract's host.
+	fileTicket struct {
+		HostPublicKey types.SiaPublicKey     `json:"hostpublickey"`
+		Ticket        modules.DownloadTicket `json:"ticket"`
+	}
+
+	// fileTickets are the persisted tickets of a single file.
+	fileTickets struct {
+		SiaPath string       `json:"siapath"`
+		Tickets []fileTicket `json:"tickets"`
+	}
+
+	// ticketsPersist is the object persisted in the tickets file.
+	ticketsPersist struct {
+		Files []fileTickets `json:"files"`
+	}
+
+	// ticketShare is the content of a ticket share. Files contains the .sia
+	// encoding of the shared files, Tickets the tickets of each file.
+	ticketShare struct {
+		Files   []byte
+		Tickets [][]fileTicket
+	}
+)
+
+// shared returns whether the file was shared by another renter.
+func (f *file) shared() bool {
+	return f.tickets != nil
+}
+
+// hostContracts returns the ids of the renter's contracts, indexed by the
+// public keys of their hosts.
+func (r *Renter) hostContracts() map[string]types.FileContractID {
+	contracts := make(map[string]types.FileContractID)
+	for _, c := range r.hostContractor.Contracts() {
+		contracts[c.HostPublicKey.String()] = c.ID
+	}
+	return contracts
+}
+
+// ticketCopy returns a copy of the file f whose contracts are merged with
+// their renewals, along with the roots of the file's sectors in each of the
+// merged contracts. The caller must hold the file lock.
+func (r *Renter) ticketCopy(f *file) (*file, map[types.FileContractID][]crypto.Hash) {
+	sf := &file{
+		name:        f.name,
+		size:        f.size,
+		contracts:   make(map[types.FileContractID]fileContract),
+		masterKey:   f.masterKey,
+		cipherType:  f.cipherType,
+		erasureCode: f.erasureCode,
+		pieceSize:   f.pieceSize,
+		mode:        f.mode,
+	}
+	roots := make(map[types.FileContractID][]crypto.Hash)
+	for id, fc := range f.contracts {
+		resolvedID := r.hostContractor.ResolveID(id)
+		merged := sf.contracts[resolvedID]
+		merged.ID = resolvedID
+		merged.IP = fc.IP
+		merged.Pieces = append(merged.Pieces, fc.Pieces...)
+		if fc.WindowStart > merged.WindowStart {
+			merged.WindowStart = fc.WindowStart
+		}
+		sf.contracts[resolvedID] = merged
+		for _, piece := range fc.Pieces {
+			roots[resolvedID] = append(roots[resolvedID], piece.MerkleRoot)
+		}
+	}
+	return sf, roots
+}
+
+// ticketsData returns the tickets of the files that were shared by other
+// renters. The caller must hold the renter lock.
+func (r *Renter) ticketsData() ticketsPersist {
+	tp := ticketsPersist{
+		Files: make([]fileTickets, 0),
+	}
+	for _, f := range r.files {
+		f.mu.RLock()
+		if f.shared() {
+			ft := fileTickets{
+				SiaPath: f.name,
+				Tickets: make([]fileTicket, 0, len(f.tickets)),
+			}
+			for _, t := range f.tickets {
+				ft.Tickets = append(ft.Tickets, t)
+			}
+			sort.Slice(ft.Tickets, func(i, j int) bool {
+				return bytes.Compare(ft.Tickets[i].Ticket.ContractID[:], ft.Tickets[j].Ticket.ContractID[:]) < 0
+			})
+			tp.Files = append(tp.Files, ft)
+		}
+		f.mu.RUnlock()
+	}
+	sort.Slice(tp.Files, func(i, j int) bool { return tp.Files[i].SiaPath < tp.Files[j].SiaPath })
+	return tp
+}
+
+// saveTickets writes the tickets of the files that were shared by other
+// renters to disk. The caller must hold the renter lock.
+func (r *Renter) saveTickets() error {
+	return persist.SaveJSON(ticketsMetadata, r.ticketsData(), filepath.Join(r.persistDir, TicketsFilename))
+}
+
+// restoreTickets sets the tickets of the files in files. Tickets for
+// contracts that the file isn't stored in are dropped.
+func (r *Renter) restoreTickets(tp ticketsPersist, files map[string]*file) {
+	for _, ft := range tp.Files {
+		f, exists := files[ft.SiaPath]
+		if !exists {
+			continue
+		}
+		f.mu.Lock()
+		f.setTickets(ft.Tickets)
+		f.mu.Unlock()
+	}
+}
+
+// setTickets marks the file as shared by another renter and sets its
+// tickets. The caller must hold the file lock.
+func (f *file) setTickets(tickets []fileTicket) {
+	f.tickets = make(map[types.FileContractID]fileTicket)
+	for _, t := range tickets {
+		if _, exists := f.contracts[t.Ticket.ContractID]; exists {
+			f.tickets[t.Ticket.ContractID] = t
+		}
+	}
+}
+
+// loadTickets restores the tickets of the renter's files from disk. It has to
+// be called before the files are used.
+func (r *Renter) loadTickets() error {
+	var tp ticketsPersist
+	err := persist.LoadJSON(ticketsMetadata, &tp, filepath.Join(r.persistDir, TicketsFilename))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	r.restoreTickets(tp, r.files)
+	return nil
+}
+
+// ShareTickets creates an ASCII-encoded share of the files at siaPaths that
+// contains download tickets for their sectors. The tickets are valid for
+// duration blocks, or until the contracts of the files end. Contracts that no
+// tickets can be created for are left out of the share.
+func (r *Renter) ShareTickets(siaPaths []string, duration types.BlockHeight) (string, error) {
+	if err := r.tg.Add(); err != nil {
+		return "", err
+	}
+	defer r.tg.Done()
+	if duration == 0 {
+		return "", errTicketDuration
+	}
+
+	// Copy the files so that the contracts can be changed without holding
+	// the renter lock while signing the tickets.
+	files := make([]*file, len(siaPaths))
+	roots := make([]map[types.FileContractID][]crypto.Hash, len(siaPaths))
+	id := r.mu.RLock()
+	for i, siaPath := range siaPaths {
+		f, exists := r.files[siaPath]
+		if !exists {
+			r.mu.RUnlock(id)
+			return "", ErrUnknownPath
+		}
+		f.mu.RLock()
+		var err error
+		if f.dedup {
+			err = errShareDedup
+		} else if f.packed() {
+			err = errSharePacked
+		} else if f.cipherType != crypto.TypeTwofish {
+			err = errShareCipher
+		} else if f.shared() {
+			err = errShareShared
+		}
+		files[i], roots[i] = r.ticketCopy(f)
+		f.mu.RUnlock()
+		if err != nil {
+			r.mu.RUnlock(id)
+			return "", err
+		}
+	}
+	r.mu.RUnlock(id)
+
+	// Create a ticket for every contract of every file.
+	expiration := r.cs.Height() + duration
+	share := ticketShare{
+		Tickets: make([][]fileTicket, len(files)),
+	}
+	for i, f := range files {
+		share.Tickets[i] = make([]fileTicket, 0, len(f.contracts))
+		for fcid := range f.contracts {
+			contract, exists := r.hostContractor.ContractByID(fcid)
+			if !exists {
+				delete(f.contracts, fcid)
+				continue
+			}
+			ticket, err := r.hostContractor.DownloadTicket(fcid, roots[i][fcid], expiration)
+			if err != nil {
+				r.log.Printf("WARN: couldn't create a download ticket for %v of %v: %v", fcid, f.name, err)
+				delete(f.contracts, fcid)
+				continue
+			}
+			share.Tickets[i] = append(share.Tickets[i], fileTicket{
+				HostPublicKey: contract.HostPublicKey,
+				Ticket:        ticket,
+			})
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := shareFiles(files, buf); err != nil {
+		return "", err
+	}
+	share.Files = buf.Bytes()
+	return base64.URLEncoding.EncodeToString(encoding.Marshal(share)), nil
+}
+
+// LoadTicketShare loads the files of a share created by ShareTickets into the
+// renter. It returns the siapaths of the loaded files.
+func (r *Renter) LoadTicketShare(s string) ([]string, error) {
+	data, err := base64.URLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var share ticketShare
+	if err := encoding.Unmarshal(data, &share); err != nil {
+		return nil, err
+	}
+
+	// Check the number of files before loading them.
+	var header [15]byte
+	var version string
+	var numFiles uint64
+	err = encoding.NewDecoder(bytes.NewReader(share.Files)).DecodeAll(&header, &version, &numFiles)
+	if err != nil {
+		return nil, err
+	} else if numFiles != uint64(len(share.Tickets)) {
+		return nil, errTicketShareMismatch
+	}
+
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	names, err := r.loadSharedFiles(bytes.NewReader(share.Files))
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range names {
+		f := r.files[name]
+		f.mu.Lock()
+		f.setTickets(share.Tickets[i])
+		f.mu.Unlock()
+	}
+	return names, r.saveTickets()
+}
@@ -0,0 +1,102 @@
+package renter
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestRenterTicketShares checks that files can be loaded from a ticket share,
+// that the loaded files are read-only and that their tickets are persisted.
+func TestRenterTicketShares(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	f, err := rt.addTestingFile("dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	f.pieceSize = cipherPieceSize(crypto.TypeTwofish)
+	f.mu.Unlock()
+	if _, err := rt.renter.ShareTickets([]string{"dir/file"}, 0); err != errTicketDuration {
+		t.Fatal("expected errTicketDuration, got", err)
+	}
+	if _, err := rt.renter.ShareTickets([]string{"missing"}, 10); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+
+	// Without contracts the share doesn't contain any tickets, but the loaded
+	// file is still marked as shared.
+	share, err := rt.renter.ShareTickets([]string{"dir/file"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := rt.renter.LoadTicketShare(share)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "dir/file_1" {
+		t.Fatal("wrong files were loaded:", names)
+	}
+	fi, err := rt.renter.File("dir/file_1")
+	if err != nil || !fi.Shared || fi.Available {
+		t.Fatal("loaded file isn't shared:", fi.Shared, fi.Available, err)
+	}
+	if _, err := rt.renter.LoadTicketShare("AAAA"); err == nil {
+		t.Fatal("invalid share was loaded")
+	}
+
+	// Shared files can't be shared again or re-encrypted.
+	if _, err := rt.renter.ShareTickets([]string{"dir/file_1"}, 10); err != errShareShared {
+		t.Fatal("expected errShareShared, got", err)
+	}
+	if err := rt.renter.ReencryptFile("dir/file_1", ""); err != errReencryptShared {
+		t.Fatal("expected errReencryptShared, got", err)
+	}
+
+	// The tickets should follow renames and be restored after a restart.
+	shared := rt.renter.files["dir/file_1"]
+	id := types.FileContractID{1}
+	shared.mu.Lock()
+	shared.contracts[id] = fileContract{ID: id, WindowStart: 10}
+	shared.setTickets([]fileTicket{{Ticket: modules.DownloadTicket{ContractID: id, Expiration: 5}}})
+	shared.mu.Unlock()
+	if err := rt.renter.RenameDir("dir", "dir2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, filepath.Join(rt.dir, modules.RenterDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err = rt.renter.File("dir2/file_1")
+	if err != nil || !fi.Shared || fi.Expiration != 5 {
+		t.Fatal("tickets weren't restored:", fi.Shared, fi.Expiration, err)
+	}
+	if fi, err := rt.renter.File("dir2/file"); err != nil || fi.Shared {
+		t.Fatal("own file was marked as shared:", fi.Shared, err)
+	}
+
+	// Deleting the file should remove its tickets from the tickets file.
+	if err := rt.renter.DeleteFile("dir2/file_1"); err != nil {
+		t.Fatal(err)
+	}
+	lockID := rt.renter.mu.RLock()
+	tp := rt.renter.ticketsData()
+	rt.renter.mu.RUnlock(lockID)
+	if len(tp.Files) != 0 {
+		t.Fatal("tickets of deleted file weren't removed:", tp.Files)
+	}
+}
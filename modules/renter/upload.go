@@ -19,12 +19,24 @@ import (
 	"os"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 )
 
 var (
+	// errUploadComplete is returned when cancelling an upload that has
+	// completed already.
+	errUploadComplete = errors.New("upload has already completed")
+
 	// errUploadDirectory is returned if the user tries to upload a directory.
 	errUploadDirectory = errors.New("cannot upload directory")
+
+	// errUploadStreaming is returned when pausing or resuming a stream upload.
+	// Stream uploads are paused by not sending more data instead.
+	errUploadStreaming = errors.New("stream uploads can't be paused or resumed")
+
+	// errUnknownUpload is returned if there is no upload with the given ID.
+	errUnknownUpload = errors.New("no upload with that id")
 )
 
 // validateSource verifies that a sourcePath meets the
@@ -41,34 +53,41 @@ func validateSource(sourcePath string) error {
 	return nil
 }
 
-// Upload instructs the renter to start tracking a file. The renter will
-// automatically upload and repair tracked files using a background loop.
-func (r *Renter) Upload(up modules.FileUploadParams) error {
+// managedAddUploadFile creates a new file for the upload described by up and
+// adds it to the renter, tracking it with the provided trackedFile. Missing
+// upload parameters are filled in with sensible defaults.
+func (r *Renter) managedAddUploadFile(up modules.FileUploadParams, size uint64, mode uint32, tf trackedFile) (*file, error) {
 	// Enforce nickname rules.
 	if err := validateSiapath(up.SiaPath); err != nil {
-		return err
-	}
-	// Enforce source rules.
-	if err := validateSource(up.Source); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Check for a nickname conflict.
 	lockID := r.mu.RLock()
-	_, exists := r.files[up.SiaPath]
+	exists := r.fileInPath(up.SiaPath)
+	_, dirExists := r.dirs[up.SiaPath]
 	r.mu.RUnlock(lockID)
 	if exists {
-		return ErrPathOverload
+		return nil, ErrPathOverload
+	}
+	if dirExists {
+		return nil, ErrDirExists
 	}
 
-	// Fill in any missing upload params with sensible defaults.
-	fileInfo, err := os.Stat(up.Source)
-	if err != nil {
-		return err
+	if up.Priority < modules.PriorityLow || up.Priority > modules.PriorityHigh {
+		return nil, modules.ErrUnknownPriority
 	}
+
+	// Fill in any missing upload params with sensible defaults.
 	if up.ErasureCode == nil {
 		up.ErasureCode, _ = NewRSCode(defaultDataPieces, defaultParityPieces)
 	}
+	if up.CipherType == "" {
+		up.CipherType = crypto.TypeTwofish
+	}
+	if err := up.CipherType.Valid(); err != nil {
+		return nil, err
+	}
 
 	// Check that we have contracts to upload to. We need at least data +
 	// parity/2 contracts. NumPieces is equal to data+parity, and min pieces is
@@ -77,27 +96,83 @@ func (r *Renter) Upload(up modules.FileUploadParams) error {
 	numContracts := len(r.hostContractor.Contracts())
 	requiredContracts := (up.ErasureCode.NumPieces() + up.ErasureCode.MinPieces()) / 2
 	if numContracts < requiredContracts && build.Release != "testing" {
-		return fmt.Errorf("not enough contracts to upload file: got %v, needed %v", numContracts, (up.ErasureCode.NumPieces()+up.ErasureCode.MinPieces())/2)
+		return nil, fmt.Errorf("not enough contracts to upload file: got %v, needed %v", numContracts, (up.ErasureCode.NumPieces()+up.ErasureCode.MinPieces())/2)
 	}
 
-	// Create file object.
-	f := newFile(up.SiaPath, up.ErasureCode, pieceSize, uint64(fileInfo.Size()))
-	f.mode = uint32(fileInfo.Mode())
+	// Create file object. Small files get smaller pieces so that they can be
+	// packed into shared sectors. Stream uploads are never packed since their
+	// size is not known in advance, deduplicated files are never packed since
+	// their chunks are shared with other files instead, and only files that
+	// are encrypted with Twofish are packed since the size of packed pieces
+	// is based on its overhead.
+	ps := cipherPieceSize(up.CipherType)
+	if !tf.Streaming && !up.Dedup && up.CipherType == crypto.TypeTwofish && packable(size, up.ErasureCode) {
+		ps = packedPieceSize(size, up.ErasureCode)
+	}
+	f := newFile(up.SiaPath, up.ErasureCode, ps, size)
+	f.cipherType = up.CipherType
+	f.mode = mode
+	if up.Dedup {
+		f.dedup = true
+		f.chunkKeys = make(map[uint64]crypto.Hash)
+	}
 
 	// Add file to renter.
+	tf.Priority = up.Priority
 	lockID = r.mu.Lock()
 	r.files[up.SiaPath] = f
-	r.persist.Tracking[up.SiaPath] = trackedFile{
-		RepairPath: up.Source,
-	}
+	r.persist.Tracking[up.SiaPath] = tf
 	r.saveSync()
-	err = r.saveFile(f)
+	var err error
+	if f.dedup {
+		err = r.saveDedup()
+	}
+	if err == nil && f.cipherType != crypto.TypeTwofish {
+		err = r.saveCiphers()
+	}
+	if err == nil {
+		err = r.saveFile(f)
+	}
+	if err == nil {
+		err = r.linkFile(f)
+	}
 	r.mu.Unlock(lockID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return f, nil
+}
+
+// Upload instructs the renter to start tracking a file. The renter will
+// automatically upload and repair tracked files using a background loop. The
+// returned ID can be used to pause, resume or cancel the upload.
+func (r *Renter) Upload(up modules.FileUploadParams) (string, error) {
+	// Enforce nickname rules.
+	if err := validateSiapath(up.SiaPath); err != nil {
+		return "", err
+	}
+	// Enforce source rules.
+	if err := validateSource(up.Source); err != nil {
+		return "", err
+	}
+	fileInfo, err := os.Stat(up.Source)
+	if err != nil {
+		return "", err
 	}
 
-	// Send the upload to the repair loop.
+	// Create the file and add it to the renter.
+	f, err := r.managedAddUploadFile(up, uint64(fileInfo.Size()), uint32(fileInfo.Mode()), trackedFile{
+		RepairPath: up.Source,
+	})
+	if err != nil {
+		return "", err
+	}
+	r.managedQueueUpload(f)
+	return f.staticUID, nil
+}
+
+// managedQueueUpload sends the unfinished chunks of f to the repair loop.
+func (r *Renter) managedQueueUpload(f *file) {
 	hosts := r.managedRefreshHostsAndWorkers()
 	id := r.mu.Lock()
 	unfinishedChunks := r.buildUnfinishedChunks(f, hosts)
@@ -109,5 +184,77 @@ func (r *Renter) Upload(up modules.FileUploadParams) error {
 	case r.uploadHeap.newUploads <- struct{}{}:
 	default:
 	}
+}
+
+// fileByUploadID returns the tracked file with the given upload ID along with
+// its tracking information.
+func (r *Renter) fileByUploadID(id string) (*file, trackedFile, error) {
+	for _, f := range r.files {
+		if f.staticUID != id {
+			continue
+		}
+		tf, exists := r.persist.Tracking[f.name]
+		if !exists {
+			break
+		}
+		return f, tf, nil
+	}
+	return nil, trackedFile{}, errUnknownUpload
+}
+
+// managedSetUploadPaused pauses or resumes the upload with the given ID.
+func (r *Renter) managedSetUploadPaused(id string, paused bool) (*file, error) {
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	f, tf, err := r.fileByUploadID(id)
+	if err != nil {
+		return nil, err
+	}
+	if tf.Streaming {
+		return nil, errUploadStreaming
+	}
+	tf.Paused = paused
+	r.persist.Tracking[f.name] = tf
+	return f, r.saveSync()
+}
+
+// PauseUpload pauses the upload with the given ID. The chunks of the file are
+// no longer uploaded or repaired until the upload is resumed, though chunks
+// that are being uploaded already are finished. The pause persists across
+// restarts.
+func (r *Renter) PauseUpload(id string) error {
+	_, err := r.managedSetUploadPaused(id, true)
+	return err
+}
+
+// ResumeUpload resumes the upload with the given ID.
+func (r *Renter) ResumeUpload(id string) error {
+	f, err := r.managedSetUploadPaused(id, false)
+	if err != nil {
+		return err
+	}
+	r.managedQueueUpload(f)
 	return nil
 }
+
+// CancelUpload cancels the upload with the given ID and deletes the file from
+// the renter. Uploads that have completed can't be cancelled, since that would
+// delete a file that is fully available.
+func (r *Renter) CancelUpload(id string) error {
+	lockID := r.mu.RLock()
+	f, _, err := r.fileByUploadID(id)
+	var complete bool
+	if err == nil {
+		f.mu.RLock()
+		complete = f.uploadProgress() >= 100
+		f.mu.RUnlock()
+	}
+	r.mu.RUnlock(lockID)
+	if err != nil {
+		return err
+	}
+	if complete {
+		return errUploadComplete
+	}
+	return r.DeleteFile(f.name)
+}
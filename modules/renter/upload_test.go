@@ -3,9 +3,12 @@ package renter
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/fastrand"
 )
 
 // TestRenterUploadDirectory verifies that the renter returns an error if a
@@ -35,7 +38,7 @@ func TestRenterUploadInode(t *testing.T) {
 		SiaPath:     "test",
 		ErasureCode: ec,
 	}
-	err = rt.renter.Upload(params)
+	_, err = rt.renter.Upload(params)
 	if err == nil {
 		t.Fatal("expected Upload to fail with empty directory as source")
 	}
@@ -43,3 +46,70 @@ func TestRenterUploadInode(t *testing.T) {
 		t.Fatal("expected errUploadDirectory, got", err)
 	}
 }
+
+// TestUploadJobs checks that uploads can be paused, resumed and cancelled by
+// their ID.
+func TestUploadJobs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	source := filepath.Join(rt.dir, "source")
+	if err := ioutil.WriteFile(source, fastrand.Bytes(100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	id, err := rt.renter.Upload(modules.FileUploadParams{
+		Source:   source,
+		SiaPath:  "test",
+		Priority: modules.PriorityHigh,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := rt.renter.File("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.UploadID != id || fi.Priority != modules.PriorityHigh || fi.UploadPaused {
+		t.Fatal("upload isn't reported correctly:", fi)
+	}
+
+	// Paused uploads aren't repaired.
+	if err := rt.renter.PauseUpload(id); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := rt.renter.File("test"); err != nil || !fi.UploadPaused {
+		t.Fatal("upload wasn't paused:", err)
+	}
+	id2 := rt.renter.mu.Lock()
+	chunks := rt.renter.buildUnfinishedChunks(rt.renter.files["test"], nil)
+	rt.renter.mu.Unlock(id2)
+	if len(chunks) != 0 {
+		t.Fatal("paused upload returned chunks to repair")
+	}
+	if err := rt.renter.ResumeUpload(id); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := rt.renter.File("test"); err != nil || fi.UploadPaused {
+		t.Fatal("upload wasn't resumed:", err)
+	}
+
+	// Cancelling the upload deletes the file.
+	if err := rt.renter.CancelUpload("foo"); err != errUnknownUpload {
+		t.Fatal("expected errUnknownUpload, got", err)
+	}
+	if err := rt.renter.CancelUpload(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.renter.File("test"); err != ErrUnknownPath {
+		t.Fatal("file wasn't deleted:", err)
+	}
+	if err := rt.renter.PauseUpload(id); err != errUnknownUpload {
+		t.Fatal("expected errUnknownUpload, got", err)
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"os"
 	"sync"
 
-	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 
 	"github.com/NebulousLabs/errors"
 )
@@ -39,6 +39,14 @@ type unfinishedUploadChunk struct {
 	offset         int64  // Offset of the chunk within the file.
 	piecesNeeded   int    // number of pieces to achieve a 100% complete upload
 
+	// The health of the chunk when it was added to the upload heap, and
+	// whether a previous repair of the chunk failed. Unhealthy chunks are
+	// repaired first, stuck chunks are repaired last. Within those groups,
+	// chunks of files with a higher priority are repaired first.
+	health   float64
+	priority modules.Priority
+	stuck    bool
+
 	// The logical data is the data that is presented to the user when the user
 	// requests the chunk. The physical data is all of the pieces that get
 	// stored across the network.
@@ -73,6 +81,8 @@ type unfinishedUploadChunk struct {
 	//	+ the worker should decrement the number of pieces registered
 	//	+ the worker should release the memory for the completed piece
 	mu               sync.Mutex
+	availableChan    chan struct{}       // closed once the chunk is available or can't make progress anymore, only used by stream uploads.
+	completeChan     chan struct{}       // closed once no further pieces are going to be uploaded, only used by re-encryptions.
 	pieceUsage       []bool              // 'true' if a piece is either uploaded, or a worker is attempting to upload that piece.
 	piecesCompleted  int                 // number of pieces that have been fully uploaded.
 	piecesRegistered int                 // number of pieces that are being uploaded, but aren't finished yet (may fail).
@@ -134,7 +144,7 @@ func (r *Renter) managedDownloadLogicalChunkData(chunk *unfinishedUploadChunk) e
 	}
 
 	// Create the download.
-	buf := NewDownloadDestinationBuffer(chunk.length)
+	buf := NewDownloadDestinationBuffer(chunk.length, chunk.renterFile.pieceSize)
 	d, err := r.managedNewDownload(downloadParams{
 		destination:     buf,
 		destinationType: "buffer",
@@ -184,7 +194,7 @@ func (r *Renter) managedFetchAndRepairChunk(chunk *unfinishedUploadChunk) {
 	var pieceCompletedMemory uint64
 	for i := 0; i < len(chunk.pieceUsage); i++ {
 		if chunk.pieceUsage[i] {
-			pieceCompletedMemory += chunk.renterFile.pieceSize + crypto.TwofishOverhead
+			pieceCompletedMemory += chunk.renterFile.pieceSize + chunk.renterFile.cipherType.Overhead()
 		}
 	}
 
@@ -213,6 +223,24 @@ func (r *Renter) managedFetchAndRepairChunk(chunk *unfinishedUploadChunk) {
 		return
 	}
 
+	// Reuse the pieces of identical chunks for deduplicated files. The memory
+	// of the reused pieces is released like the memory of completed pieces.
+	if chunk.renterFile.dedup {
+		reused := r.managedDedupChunk(chunk)
+		pieceCompletedMemory += uint64(reused) * (chunk.renterFile.pieceSize + chunk.renterFile.cipherType.Overhead())
+		chunk.mu.Lock()
+		complete := chunk.piecesCompleted >= chunk.piecesNeeded
+		chunk.mu.Unlock()
+		if complete {
+			// All pieces have been reused, there is nothing left to upload.
+			chunk.logicalChunkData = nil
+			chunk.workersRemaining = 0
+			r.memoryManager.Return(erasureCodingMemory + pieceCompletedMemory)
+			chunk.memoryReleased += erasureCodingMemory + pieceCompletedMemory
+			return
+		}
+	}
+
 	// Create the physical pieces for the data. Immediately release the logical
 	// data.
 	//
@@ -251,7 +279,9 @@ func (r *Renter) managedFetchAndRepairChunk(chunk *unfinishedUploadChunk) {
 			chunk.physicalChunkData[i] = nil
 		} else {
 			// Encrypt the piece.
-			key := deriveKey(chunk.renterFile.masterKey, chunk.index, uint64(i))
+			chunk.renterFile.mu.RLock()
+			key := chunk.renterFile.pieceKey(chunk.index, uint64(i))
+			chunk.renterFile.mu.RUnlock()
 			chunk.physicalChunkData[i] = key.EncryptBytes(chunk.physicalChunkData[i])
 		}
 	}
@@ -271,6 +301,11 @@ func (r *Renter) managedFetchAndRepairChunk(chunk *unfinishedUploadChunk) {
 // chunk.data should be passed as 'nil' to the download, to keep memory usage as
 // light as possible.
 func (r *Renter) managedFetchLogicalChunkData(chunk *unfinishedUploadChunk) error {
+	// Chunks of stream uploads already contain their logical data.
+	if chunk.logicalChunkData != nil {
+		return nil
+	}
+
 	// Only download this file if more than 25% of the redundancy is missing.
 	numParityPieces := float64(chunk.piecesNeeded - chunk.minimumPieces)
 	minMissingPiecesToDownload := int(numParityPieces * RemoteRepairDownloadThreshold)
@@ -300,7 +335,7 @@ func (r *Renter) managedFetchLogicalChunkData(chunk *unfinishedUploadChunk) erro
 	// TODO: Once we have enabled support for small chunks, we should stop
 	// needing to ignore the EOF errors, because the chunk size should always
 	// match the tail end of the file. Until then, we ignore io.EOF.
-	buf := NewDownloadDestinationBuffer(chunk.length)
+	buf := NewDownloadDestinationBuffer(chunk.length, chunk.renterFile.pieceSize)
 	sr := io.NewSectionReader(osFile, chunk.offset, int64(chunk.length))
 	_, err = buf.ReadFrom(sr)
 	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF && download {
@@ -336,7 +371,7 @@ func (r *Renter) managedCleanUpUploadChunk(uc *unfinishedUploadChunk) {
 		// will prefer releasing later pieces, which improves computational
 		// complexity for erasure coding.
 		if piecesAvailable >= uc.workersRemaining {
-			memoryReleased += uc.renterFile.pieceSize + crypto.TwofishOverhead
+			memoryReleased += uc.renterFile.pieceSize + uc.renterFile.cipherType.Overhead()
 			uc.physicalChunkData[i] = nil
 			// Mark this piece as taken so that we don't double release memory.
 			uc.pieceUsage[i] = true
@@ -355,8 +390,29 @@ func (r *Renter) managedCleanUpUploadChunk(uc *unfinishedUploadChunk) {
 	}
 	uc.memoryReleased += uint64(memoryReleased)
 	totalMemoryReleased := uc.memoryReleased
+	repaired := uc.piecesCompleted >= uc.piecesNeeded
+	// Notify a waiting stream upload once the chunk can be recovered from the
+	// network, or once no further pieces are going to be uploaded.
+	if uc.availableChan != nil && (uc.piecesCompleted >= uc.minimumPieces || chunkComplete) {
+		select {
+		case <-uc.availableChan:
+		default:
+			close(uc.availableChan)
+		}
+	}
+	if uc.completeChan != nil && chunkComplete && !released {
+		close(uc.completeChan)
+	}
 	uc.mu.Unlock()
 
+	// If the chunk is done, update its stuck status. A chunk is stuck if the
+	// workers failed to upload all of its pieces.
+	if chunkComplete && !released {
+		uc.renterFile.mu.Lock()
+		uc.renterFile.markChunkStuck(uc.index, !repaired)
+		uc.renterFile.mu.Unlock()
+	}
+
 	// If there are pieces available, add the standby workers to collect them.
 	// Standby workers are only added to the chunk when piecesAvailable is equal
 	// to zero, meaning this code will only trigger if the number of pieces
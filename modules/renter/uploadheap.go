@@ -22,7 +22,6 @@ import (
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
-	"github.com/NebulousLabs/Sia/crypto"
 )
 
 // uploadHeap contains a priority-sorted heap of all the chunks being uploaded
@@ -47,10 +46,18 @@ type uploadHeap struct {
 // unnecessary. The repair loop might be moved to repair.go.
 type uploadChunkHeap []*unfinishedUploadChunk
 
-// Implementation of heap.Interface for uploadChunkHeap.
+// Implementation of heap.Interface for uploadChunkHeap. Chunks that are not
+// stuck come first, ordered by the priority of their file and then from least
+// to most healthy.
 func (uch uploadChunkHeap) Len() int { return len(uch) }
 func (uch uploadChunkHeap) Less(i, j int) bool {
-	return float64(uch[i].piecesCompleted)/float64(uch[i].piecesNeeded) < float64(uch[j].piecesCompleted)/float64(uch[j].piecesNeeded)
+	if uch[i].stuck != uch[j].stuck {
+		return !uch[i].stuck
+	}
+	if uch[i].priority != uch[j].priority {
+		return uch[i].priority > uch[j].priority
+	}
+	return uch[i].health > uch[j].health
 }
 func (uch uploadChunkHeap) Swap(i, j int)       { uch[i], uch[j] = uch[j], uch[i] }
 func (uch *uploadChunkHeap) Push(x interface{}) { *uch = append(*uch, x.(*unfinishedUploadChunk)) }
@@ -85,6 +92,15 @@ func (uh *uploadHeap) managedPush(uuc *unfinishedUploadChunk) {
 	uh.mu.Unlock()
 }
 
+// managedRemoveActive removes a chunk that was popped from the heap but won't
+// be worked on from the set of active chunks, so that it can be added again
+// later.
+func (uh *uploadHeap) managedRemoveActive(uuc *unfinishedUploadChunk) {
+	uh.mu.Lock()
+	delete(uh.activeChunks, uuc.id)
+	uh.mu.Unlock()
+}
+
 // managedPop will pull a chunk off of the upload heap and return it.
 func (uh *uploadHeap) managedPop() (uc *unfinishedUploadChunk) {
 	uh.mu.Lock()
@@ -95,6 +111,44 @@ func (uh *uploadHeap) managedPop() (uc *unfinishedUploadChunk) {
 	return uc
 }
 
+// newUnfinishedUploadChunk creates an unfinished upload chunk for the chunk of
+// f at index. Every host in hosts is considered unused.
+func newUnfinishedUploadChunk(f *file, index uint64, localPath string, hosts map[string]struct{}) *unfinishedUploadChunk {
+	uuc := &unfinishedUploadChunk{
+		renterFile: f,
+		localPath:  localPath,
+
+		id: uploadChunkID{
+			fileUID: f.staticUID,
+			index:   index,
+		},
+
+		index:  index,
+		length: f.staticChunkSize(),
+		offset: int64(index * f.staticChunkSize()),
+
+		// memoryNeeded has to also include the logical data, and also
+		// include the overhead for encryption.
+		//
+		// TODO: Currently we request memory for all of the pieces as well
+		// as the minimum pieces, but we perhaps don't need to request all
+		// of that.
+		memoryNeeded:  f.pieceSize*uint64(f.erasureCode.NumPieces()+f.erasureCode.MinPieces()) + uint64(f.erasureCode.NumPieces())*f.cipherType.Overhead(),
+		minimumPieces: f.erasureCode.MinPieces(),
+		piecesNeeded:  f.erasureCode.NumPieces(),
+
+		physicalChunkData: make([][]byte, f.erasureCode.NumPieces()),
+
+		pieceUsage:  make([]bool, f.erasureCode.NumPieces()),
+		unusedHosts: make(map[string]struct{}),
+	}
+	// Every chunk can have a different set of unused hosts.
+	for host := range hosts {
+		uuc.unusedHosts[host] = struct{}{}
+	}
+	return uuc
+}
+
 // buildUnfinishedChunks will pull all of the unfinished chunks out of a file.
 //
 // TODO / NOTE: This code can be substantially simplified once the files store
@@ -105,9 +159,12 @@ func (r *Renter) buildUnfinishedChunks(f *file, hosts map[string]struct{}) []*un
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	// If the file is not being tracked, don't repair it.
+	// If the file is not being tracked, don't repair it. Files that are
+	// being uploaded from a stream are uploaded by the stream itself, small
+	// files are uploaded in packs, and paused uploads wait until they are
+	// resumed.
 	trackedFile, exists := r.persist.Tracking[f.name]
-	if !exists {
+	if !exists || trackedFile.Streaming || trackedFile.Paused || f.packed() {
 		return nil
 	}
 
@@ -124,41 +181,7 @@ func (r *Renter) buildUnfinishedChunks(f *file, hosts map[string]struct{}) []*un
 	chunkCount := f.numChunks()
 	newUnfinishedChunks := make([]*unfinishedUploadChunk, chunkCount)
 	for i := uint64(0); i < chunkCount; i++ {
-		newUnfinishedChunks[i] = &unfinishedUploadChunk{
-			renterFile: f,
-			localPath:  trackedFile.RepairPath,
-
-			id: uploadChunkID{
-				fileUID: f.staticUID,
-				index:   i,
-			},
-
-			index:  i,
-			length: f.staticChunkSize(),
-			offset: int64(i * f.staticChunkSize()),
-
-			// memoryNeeded has to also include the logical data, and also
-			// include the overhead for encryption.
-			//
-			// TODO / NOTE: If we adjust the file to have a flexible encryption
-			// scheme, we'll need to adjust the overhead stuff too.
-			//
-			// TODO: Currently we request memory for all of the pieces as well
-			// as the minimum pieces, but we perhaps don't need to request all
-			// of that.
-			memoryNeeded:  f.pieceSize*uint64(f.erasureCode.NumPieces()+f.erasureCode.MinPieces()) + uint64(f.erasureCode.NumPieces()*crypto.TwofishOverhead),
-			minimumPieces: f.erasureCode.MinPieces(),
-			piecesNeeded:  f.erasureCode.NumPieces(),
-
-			physicalChunkData: make([][]byte, f.erasureCode.NumPieces()),
-
-			pieceUsage:  make([]bool, f.erasureCode.NumPieces()),
-			unusedHosts: make(map[string]struct{}),
-		}
-		// Every chunk can have a different set of unused hosts.
-		for host := range hosts {
-			newUnfinishedChunks[i].unusedHosts[host] = struct{}{}
-		}
+		newUnfinishedChunks[i] = newUnfinishedUploadChunk(f, i, trackedFile.RepairPath, hosts)
 	}
 
 	// Iterate through the contracts of the file and mark which hosts are
@@ -217,11 +240,19 @@ func (r *Renter) buildUnfinishedChunks(f *file, hosts map[string]struct{}) []*un
 	}
 
 	// Iterate through the set of newUnfinishedChunks and remove any that are
-	// completed.
+	// completed. The remaining chunks are marked with their health.
 	incompleteChunks := newUnfinishedChunks[:0]
 	for i := 0; i < len(newUnfinishedChunks); i++ {
-		if newUnfinishedChunks[i].piecesCompleted < newUnfinishedChunks[i].piecesNeeded {
-			incompleteChunks = append(incompleteChunks, newUnfinishedChunks[i])
+		uuc := newUnfinishedChunks[i]
+		if uuc.piecesCompleted < uuc.piecesNeeded {
+			uuc.health = chunkHealth(uuc.piecesCompleted, uuc.minimumPieces, uuc.piecesNeeded)
+			uuc.priority = trackedFile.Priority
+			uuc.stuck = f.chunkStuck(uuc.index)
+			incompleteChunks = append(incompleteChunks, uuc)
+		} else {
+			// The chunk might have been repaired by an upload that was
+			// started before the chunk got stuck.
+			f.markChunkStuck(uuc.index, false)
 		}
 	}
 	// TODO: Don't return chunks that can't be downloaded, uploaded or otherwise
@@ -229,13 +260,37 @@ func (r *Renter) buildUnfinishedChunks(f *file, hosts map[string]struct{}) []*un
 	return incompleteChunks
 }
 
-// managedBuildChunkHeap will iterate through all of the files in the renter and
-// construct a chunk heap.
+// managedBuildChunkHeap will update the health of all of the files in the
+// renter and construct a chunk heap from the least healthy files. Files are
+// added worst-first until the heap contains maxUploadHeapChunks chunks.
 func (r *Renter) managedBuildChunkHeap(hosts map[string]struct{}) {
-	// Loop through the whole set of files and get a list of chunks to add to
-	// the heap.
+	files := r.managedUpdateHealth()
+
+	// Loop through the files and get a list of chunks to add to the heap.
 	id := r.mu.Lock()
-	for _, file := range r.files {
+	for _, file := range files {
+		r.uploadHeap.mu.Lock()
+		heapLen := r.uploadHeap.heap.Len()
+		r.uploadHeap.mu.Unlock()
+		if heapLen >= maxUploadHeapChunks {
+			break
+		}
+		if _, exists := r.files[file.name]; !exists {
+			// The file was deleted in the meantime.
+			continue
+		}
+		if r.persist.Tracking[file.name].Paused {
+			// The upload of the file is paused, which includes packing.
+			continue
+		}
+		// Small files are packed instead of being added to the heap.
+		file.mu.RLock()
+		needsPacking := r.needsPacking(file, len(r.workerPool))
+		file.mu.RUnlock()
+		if needsPacking {
+			r.managedQueuePackFile(file)
+			continue
+		}
 		unfinishedUploadChunks := r.buildUnfinishedChunks(file, hosts)
 		for i := 0; i < len(unfinishedUploadChunks); i++ {
 			r.uploadHeap.managedPush(unfinishedUploadChunks[i])
@@ -253,7 +308,7 @@ func (r *Renter) managedPrepareNextChunk(uuc *unfinishedUploadChunk, hosts map[s
 	// Grab the next chunk, loop until we have enough memory, update the amount
 	// of memory available, and then spin up a thread to asynchronously handle
 	// the rest of the chunk tasks.
-	if !r.memoryManager.Request(uuc.memoryNeeded, memoryPriorityLow) {
+	if !r.memoryManager.Request(uuc.memoryNeeded, newMemoryPriority(uuc.priority, memoryPriorityLow)) {
 		return
 	}
 	// Fetch the chunk in a separate goroutine, as it can take a long time and
@@ -300,17 +355,16 @@ func (r *Renter) threadedUploadLoop() {
 		// useful for uploading.
 		hosts := r.managedRefreshHostsAndWorkers()
 
-		// Build a min-heap of chunks organized by upload progress.
-		//
-		// TODO: After replacing the filesystem to resemble a tree, we'll be
-		// able to go through the filesystem piecewise instead of doing
-		// everything all at once.
+		// Build a heap of chunks organized by health.
 		r.managedBuildChunkHeap(hosts)
 		r.uploadHeap.mu.Lock()
 		heapLen := r.uploadHeap.heap.Len()
 		r.uploadHeap.mu.Unlock()
 		r.log.Println("Repairing", heapLen, "chunks")
 
+		// Upload the packs of the small files that are ready to be packed.
+		packSignal := r.managedPackFiles()
+
 		// Work through the heap. Chunks will be processed one at a time until
 		// the heap is whittled down. When the heap is empty, we wait for new
 		// files in a loop and then process those. When the rebuild signal is
@@ -342,11 +396,19 @@ func (r *Renter) threadedUploadLoop() {
 			// the next time we rebuild the heap and refresh the workers.
 			id := r.mu.RLock()
 			availableWorkers := len(r.workerPool)
+			paused := r.persist.Tracking[nextChunk.renterFile.name].Paused
 			r.mu.RUnlock(id)
 			if availableWorkers < nextChunk.minimumPieces {
 				continue
 			}
 
+			// Skip the chunks of uploads that were paused after the heap was
+			// built. They are added again once the upload is resumed.
+			if paused {
+				r.uploadHeap.managedRemoveActive(nextChunk)
+				continue
+			}
+
 			// Perform the work. managedPrepareNextChunk will block until
 			// enough memory is available to perform the work, slowing this
 			// thread down to using only the resources that are available.
@@ -360,6 +422,8 @@ func (r *Renter) threadedUploadLoop() {
 			// User has uploaded a new file.
 		case <-rebuildHeapSignal:
 			// Time to check the filesystem health again.
+		case <-packSignal:
+			// Small files are ready to be packed.
 		case <-r.tg.StopChan():
 			// Thre renter has shut down.
 			return
@@ -0,0 +1,277 @@
+package renter
+
+// uploadstream.go uploads files from an io.Reader of unknown length. The data
+// is read one chunk at a time, and every chunk is handed to the workers
+// directly instead of going through the repair loop. The stream only proceeds
+// to the next chunk once the previous one can be recovered from the network,
+// which means that the chunks at the beginning of the file that have enough
+// pieces mark the progress of the stream. An interrupted stream can be resumed
+// from the end of those chunks, even after a restart.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	// errNotStreaming is returned when trying to resume a stream upload of a
+	// file that isn't being uploaded from a stream.
+	errNotStreaming = errors.New("file is not an unfinished stream upload")
+
+	// errStreamActive is returned when trying to resume a stream upload that
+	// is still in progress.
+	errStreamActive = errors.New("file is already being uploaded from a stream")
+
+	// errStreamFileDeleted is returned if a file is deleted while it is being
+	// uploaded from a stream.
+	errStreamFileDeleted = errors.New("file was deleted during the stream upload")
+
+	// errStreamInterrupted is returned if the renter shuts down during a
+	// stream upload.
+	errStreamInterrupted = errors.New("stream upload interrupted by stop call")
+)
+
+// streamReader wraps the reader of a stream upload, remembering the last error
+// returned by the underlying reader. This is necessary to tell apart a stream
+// that ended cleanly from one that was cut off, since io.ReadFull reports both
+// as io.ErrUnexpectedEOF.
+type streamReader struct {
+	r   io.Reader
+	err error
+}
+
+// Read implements the io.Reader interface.
+func (sr *streamReader) Read(b []byte) (int, error) {
+	n, err := sr.r.Read(b)
+	sr.err = err
+	return n, err
+}
+
+// streamOffset returns the offset at which an interrupted stream upload of the
+// file can be resumed. That is the end of the leading chunks of the file that
+// can be recovered from the network.
+func (f *file) streamOffset() uint64 {
+	chunkPieces := make([]map[uint64]struct{}, f.numChunks())
+	for _, fc := range f.contracts {
+		for _, p := range fc.Pieces {
+			if p.Chunk >= uint64(len(chunkPieces)) {
+				continue
+			}
+			if chunkPieces[p.Chunk] == nil {
+				chunkPieces[p.Chunk] = make(map[uint64]struct{})
+			}
+			chunkPieces[p.Chunk][p.Piece] = struct{}{}
+		}
+	}
+	offset := uint64(0)
+	for _, pieces := range chunkPieces {
+		if len(pieces) < f.erasureCode.MinPieces() {
+			break
+		}
+		offset += f.staticChunkSize()
+	}
+	if offset > f.size {
+		offset = f.size
+	}
+	return offset
+}
+
+// truncateStream truncates the file to offset, dropping the pieces of all
+// chunks that start at or after offset.
+func (f *file) truncateStream(offset uint64) {
+	chunkSize := f.staticChunkSize()
+	firstDropped := (offset + chunkSize - 1) / chunkSize
+	for id, fc := range f.contracts {
+		pieces := fc.Pieces[:0]
+		for _, p := range fc.Pieces {
+			if p.Chunk < firstDropped {
+				pieces = append(pieces, p)
+			}
+		}
+		fc.Pieces = pieces
+		f.contracts[id] = fc
+	}
+	for index := range f.stuckChunks {
+		if index >= firstDropped {
+			delete(f.stuckChunks, index)
+		}
+	}
+	f.size = offset
+}
+
+// managedUploadStream reads the data for f from reader one chunk at a time and
+// uploads every chunk until the stream ends.
+func (r *Renter) managedUploadStream(f *file, reader io.Reader) error {
+	defer func() {
+		id := r.mu.Lock()
+		delete(r.streamUploads, f)
+		r.mu.Unlock(id)
+	}()
+
+	// Make sure there are enough workers to upload the chunks.
+	hosts := r.managedRefreshHostsAndWorkers()
+	id := r.mu.RLock()
+	numWorkers := len(r.workerPool)
+	priority := r.persist.Tracking[f.name].Priority
+	r.mu.RUnlock(id)
+	if numWorkers < f.erasureCode.MinPieces() {
+		return fmt.Errorf("not enough workers to upload the stream: got %v, needed %v", numWorkers, f.erasureCode.MinPieces())
+	}
+
+	sr := &streamReader{r: reader}
+	chunkSize := f.staticChunkSize()
+	for {
+		f.mu.RLock()
+		offset, deleted := f.size, f.deleted
+		f.mu.RUnlock()
+		if deleted {
+			return errStreamFileDeleted
+		}
+
+		// Read the next chunk from the stream. The memory for the chunk is
+		// requested up front, which limits how fast the stream is consumed.
+		uuc := newUnfinishedUploadChunk(f, offset/chunkSize, "", hosts)
+		uuc.availableChan = make(chan struct{})
+		uuc.priority = priority
+		if !r.memoryManager.Request(uuc.memoryNeeded, newMemoryPriority(priority, memoryPriorityHigh)) {
+			return errStreamInterrupted
+		}
+		buf := NewDownloadDestinationBuffer(chunkSize, f.pieceSize)
+		n, err := buf.ReadFrom(sr)
+		if err != nil && sr.err != io.EOF {
+			r.memoryManager.Return(uuc.memoryNeeded)
+			return fmt.Errorf("unable to read chunk %v from stream: %v", uuc.index, err)
+		}
+		// Empty files still consist of a single chunk.
+		if n == 0 && offset > 0 {
+			r.memoryManager.Return(uuc.memoryNeeded)
+			break
+		}
+		uuc.logicalChunkData = buf
+
+		// Mark the chunk as active before growing the file so that the repair
+		// loop doesn't pick it up, then upload the chunk.
+		r.uploadHeap.mu.Lock()
+		r.uploadHeap.activeChunks[uuc.id] = struct{}{}
+		r.uploadHeap.mu.Unlock()
+		f.mu.Lock()
+		f.size = offset + uint64(n)
+		f.mu.Unlock()
+		go r.managedFetchAndRepairChunk(uuc)
+
+		// Wait until the chunk can be recovered from the network.
+		select {
+		case <-uuc.availableChan:
+		case <-r.tg.StopChan():
+			return errStreamInterrupted
+		}
+		uuc.mu.Lock()
+		available := uuc.piecesCompleted >= uuc.minimumPieces
+		uuc.mu.Unlock()
+		if !available {
+			return fmt.Errorf("unable to upload chunk %v to enough hosts", uuc.index)
+		}
+		if uint64(n) < chunkSize {
+			break
+		}
+	}
+
+	// The stream has finished. Hand the file over to the repair loop.
+	id = r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if tf, exists := r.persist.Tracking[f.name]; exists {
+		tf.Streaming = false
+		r.persist.Tracking[f.name] = tf
+		r.saveSync()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return r.saveFile(f)
+}
+
+// UploadStreamFromReader creates a new file at up.SiaPath and uploads the data
+// read from reader to it. up.Source is ignored. The method returns once the
+// whole stream can be recovered from the network; the remaining redundancy is
+// uploaded in the background.
+func (r *Renter) UploadStreamFromReader(up modules.FileUploadParams, reader io.Reader) error {
+	f, err := r.managedAddUploadFile(up, 0, 0644, trackedFile{
+		Streaming: true,
+	})
+	if err != nil {
+		return err
+	}
+	id := r.mu.Lock()
+	r.streamUploads[f] = struct{}{}
+	r.mu.Unlock(id)
+	return r.managedUploadStream(f, reader)
+}
+
+// ResumeUploadStream resumes an interrupted stream upload of the file at
+// siaPath. offset is the offset of the first byte of reader within the file,
+// and must not be greater than the offset returned by UploadStreamOffset. Any
+// data before that offset is skipped.
+func (r *Renter) ResumeUploadStream(siaPath string, offset uint64, reader io.Reader) error {
+	id := r.mu.Lock()
+	f, exists := r.files[siaPath]
+	if !exists {
+		r.mu.Unlock(id)
+		return ErrUnknownPath
+	}
+	if !r.persist.Tracking[siaPath].Streaming {
+		r.mu.Unlock(id)
+		return errNotStreaming
+	}
+	if _, active := r.streamUploads[f]; active {
+		r.mu.Unlock(id)
+		return errStreamActive
+	}
+	// Drop the chunks that were only partially uploaded before the stream
+	// was interrupted.
+	f.mu.Lock()
+	resumeOffset := f.streamOffset()
+	var err error
+	if offset > resumeOffset {
+		err = fmt.Errorf("stream can't be resumed at offset %v, it has to be resumed at or before offset %v", offset, resumeOffset)
+	} else {
+		f.truncateStream(resumeOffset)
+		err = r.saveFile(f)
+	}
+	if err == nil {
+		r.streamUploads[f] = struct{}{}
+	}
+	f.mu.Unlock()
+	r.mu.Unlock(id)
+	if err != nil {
+		return err
+	}
+
+	// Skip the data that has already been uploaded.
+	if _, err := io.CopyN(ioutil.Discard, reader, int64(resumeOffset-offset)); err != nil {
+		id = r.mu.Lock()
+		delete(r.streamUploads, f)
+		r.mu.Unlock(id)
+		return fmt.Errorf("unable to skip to offset %v of the stream: %v", resumeOffset, err)
+	}
+	return r.managedUploadStream(f, reader)
+}
+
+// UploadStreamOffset returns the offset at which the interrupted stream upload
+// of the file at siaPath can be resumed.
+func (r *Renter) UploadStreamOffset(siaPath string) (uint64, error) {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	f, exists := r.files[siaPath]
+	if !exists {
+		return 0, ErrUnknownPath
+	}
+	if !r.persist.Tracking[siaPath].Streaming {
+		return 0, errNotStreaming
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.streamOffset(), nil
+}
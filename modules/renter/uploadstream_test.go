@@ -0,0 +1,64 @@
+package renter
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestFileStreamOffset probes the streamOffset and truncateStream methods of
+// the file.
+func TestFileStreamOffset(t *testing.T) {
+	rsc, _ := NewRSCode(2, 1)
+	f := &file{
+		size:        1000,
+		pieceSize:   100,
+		contracts:   make(map[types.FileContractID]fileContract),
+		erasureCode: rsc,
+		stuckChunks: make(map[uint64]struct{}),
+	}
+	// 1000 bytes with a chunk size of 200 make up 5 chunks.
+	if offset := f.streamOffset(); offset != 0 {
+		t.Fatal("expected offset 0, got", offset)
+	}
+
+	// Upload enough pieces for chunks 0 and 1, one piece of chunk 2 and
+	// enough pieces for chunk 3.
+	fc1 := fileContract{ID: types.FileContractID{1}}
+	fc2 := fileContract{ID: types.FileContractID{2}}
+	for _, chunk := range []uint64{0, 1, 2, 3} {
+		fc1.Pieces = append(fc1.Pieces, pieceData{Chunk: chunk, Piece: 0})
+		if chunk != 2 {
+			fc2.Pieces = append(fc2.Pieces, pieceData{Chunk: chunk, Piece: 1})
+		}
+	}
+	f.contracts[fc1.ID] = fc1
+	f.contracts[fc2.ID] = fc2
+	f.markChunkStuck(3, true)
+	if offset := f.streamOffset(); offset != 400 {
+		t.Fatal("expected offset 400, got", offset)
+	}
+
+	// Truncating the stream should drop the pieces of chunks 2 and 3.
+	f.truncateStream(400)
+	if f.size != 400 || f.numChunks() != 2 {
+		t.Fatal("file was not truncated:", f.size, f.numChunks())
+	}
+	for _, fc := range f.contracts {
+		if len(fc.Pieces) != 2 {
+			t.Fatal("expected 2 pieces per contract, got", len(fc.Pieces))
+		}
+	}
+	if f.chunkStuck(3) {
+		t.Fatal("stuck chunk was not dropped")
+	}
+	if offset := f.streamOffset(); offset != 400 {
+		t.Fatal("expected offset 400, got", offset)
+	}
+
+	// The offset is capped at the size of the file.
+	f.size = 350
+	if offset := f.streamOffset(); offset != 350 {
+		t.Fatal("expected offset 350, got", offset)
+	}
+}
@@ -10,8 +10,8 @@ import (
 
 // A worker listens for work on a certain host.
 //
-// The mutex of the worker only protects the 'unprocessedChunks' and the
-// 'standbyChunks' fields of the worker. The rest of the fields are only
+// The mutex of the worker only protects the 'unprocessedChunks', the
+// 'packSectors' and the 'standbyChunks' fields of the worker. The rest of the fields are only
 // interacted with exclusively by the primary worker thread, and only one of
 // those ever exists at a time.
 //
@@ -47,6 +47,10 @@ type worker struct {
 	uploadRecentFailure       time.Time                // How recent was the last failure?
 	uploadTerminated          bool                     // Have we stopped uploading?
 
+	// Sectors of packs waiting to be uploaded. They are processed before the
+	// upload chunks.
+	packSectors []*packSectorJob
+
 	// Utilities.
 	//
 	// The mutex is only needed when interacting with 'downloadChunks' and
@@ -122,6 +126,14 @@ func (w *worker) threadedWorkLoop() {
 			continue
 		}
 
+		// Upload the sectors of packs. Packs are uploaded by the workers so
+		// that only one thread at a time revises the worker's contract.
+		packSector := w.managedNextPackSector()
+		if packSector != nil {
+			w.managedUploadPackSector(packSector)
+			continue
+		}
+
 		// Perform one step of processing upload work.
 		chunk, pieceIndex := w.managedNextUploadChunk()
 		if chunk != nil {
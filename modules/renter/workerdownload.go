@@ -5,8 +5,13 @@ package renter
 // coordinating resource management between the workers operating on a chunk.
 
 import (
+	"sort"
 	"sync/atomic"
 	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/contractor"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 // managedDownload will perform some download work.
@@ -23,23 +28,41 @@ func (w *worker) managedDownload(udc *unfinishedDownloadChunk) {
 	}
 	// Worker is being given a chance to work. After the work is complete,
 	// whether successful or failed, the worker needs to be removed.
-	defer udc.managedRemoveWorker()
+	defer udc.managedRemoveWorker(w)
 
 	// Fetch the sector. If fetching the sector fails, the worker needs to be
 	// unregistered with the chunk.
-	d, err := w.renter.hostContractor.Downloader(w.contract.ID, w.renter.tg.StopChan())
+	// Pieces of another renter's contract are downloaded with the ticket
+	// of the contract.
+	pieceInfo := udc.staticChunkMap[w.contract.ID]
+	var d contractor.Downloader
+	var err error
+	if pieceInfo.ticket != nil {
+		d, err = w.renter.hostContractor.TicketDownloader(w.contract.ID, *pieceInfo.ticket, w.renter.tg.StopChan())
+	} else {
+		d, err = w.renter.hostContractor.Downloader(w.contract.ID, w.renter.tg.StopChan())
+	}
 	if err != nil {
 		w.renter.log.Debugln("worker failed to create downloader:", err)
 		udc.managedUnregisterWorker(w)
 		return
 	}
 	defer d.Close()
-	data, err := d.Sector(udc.staticChunkMap[w.contract.ID].root)
+	var data []byte
+	var spending modules.DataSpending
+	if udc.staticPieceLength > 0 {
+		data, spending, err = udc.downloadPieceRange(d, pieceInfo)
+	} else if pieceInfo.length > 0 {
+		data, spending, err = d.PartialSector(pieceInfo.root, pieceInfo.offset, pieceInfo.length)
+	} else {
+		data, spending, err = d.Sector(pieceInfo.root)
+	}
 	if err != nil {
 		w.renter.log.Debugln("worker failed to download sector:", err)
 		udc.managedUnregisterWorker(w)
 		return
 	}
+	w.renter.managedRecordSpending(udc.download.staticSiaPath, spending)
 	// TODO: Instead of adding the whole sector after the download completes,
 	// have the 'd.Sector' call add to this value ongoing as the sector comes
 	// in. Perhaps even include the data from creating the downloader and other
@@ -62,6 +85,27 @@ func (w *worker) managedDownload(udc *unfinishedDownloadChunk) {
 	udc.mu.Unlock()
 }
 
+// downloadPieceRange downloads the part of a piece that is required to recover
+// the fetched range of the chunk and decrypts it. Only the nonce of the piece
+// and the segments covering the part are retrieved from the host.
+func (udc *unfinishedDownloadChunk) downloadPieceRange(d contractor.Downloader, pieceInfo downloadPieceInfo) ([]byte, modules.DataSpending, error) {
+	key := udc.pieceKeys[pieceInfo.index]
+	nonceSize := key.Type().NonceSize()
+	data, spending, err := d.Download([]modules.DownloadAction{{
+		MerkleRoot: pieceInfo.root,
+		Offset:     pieceInfo.offset,
+		Length:     nonceSize,
+	}, {
+		MerkleRoot: pieceInfo.root,
+		Offset:     pieceInfo.offset + nonceSize + udc.staticPieceOffset,
+		Length:     udc.staticPieceLength,
+	}})
+	if err != nil {
+		return nil, modules.DataSpending{}, err
+	}
+	return key.DecryptRange(data[0], data[1], udc.staticPieceOffset), spending, nil
+}
+
 // managedKillDownloading will drop all of the download work given to the
 // worker, and set a signal to prevent the worker from accepting more download
 // work.
@@ -78,7 +122,7 @@ func (w *worker) managedKillDownloading() {
 	w.downloadTerminated = true
 	w.downloadMu.Unlock()
 	for i := 0; i < len(removedChunks); i++ {
-		removedChunks[i].managedRemoveWorker()
+		removedChunks[i].managedRemoveWorker(w)
 	}
 }
 
@@ -117,7 +161,7 @@ func (w *worker) managedQueueDownloadChunk(udc *unfinishedDownloadChunk) {
 	// If the worker has terminated, remove it from the udc. This call needs to
 	// happen without holding the worker lock.
 	if terminated {
-		udc.managedRemoveWorker()
+		udc.managedRemoveWorker(w)
 	}
 }
 
@@ -150,24 +194,29 @@ func (w *worker) ownedOnDownloadCooldown() bool {
 func (w *worker) ownedProcessDownloadChunk(udc *unfinishedDownloadChunk) *unfinishedDownloadChunk {
 	// Determine whether the worker needs to drop the chunk. If so, remove the
 	// worker and return nil. Worker only needs to be removed if worker is being
-	// dropped.
+	// dropped. Chunks of downloads that have failed or were cancelled are
+	// dropped as well.
 	udc.mu.Lock()
 	chunkComplete := udc.piecesCompleted >= udc.erasureCode.MinPieces()
 	chunkFailed := udc.piecesCompleted+udc.workersRemaining < udc.erasureCode.MinPieces()
+	downloadComplete := udc.download.staticComplete()
 	pieceData, workerHasPiece := udc.staticChunkMap[w.contract.ID]
 	pieceTaken := udc.pieceUsage[pieceData.index]
-	if chunkComplete || chunkFailed || w.ownedOnDownloadCooldown() || !workerHasPiece || pieceTaken {
+	_, preferred := udc.preferredWorkers[w.contract.ID]
+	udc.markProcessed(w)
+	if chunkComplete || chunkFailed || downloadComplete || w.ownedOnDownloadCooldown() || !workerHasPiece || pieceTaken {
 		udc.mu.Unlock()
-		udc.managedRemoveWorker()
+		udc.managedRemoveWorker(w)
 		return nil
 	}
 	defer udc.mu.Unlock()
 
-	// TODO: This is where we would put filters based on worker latency, worker
-	// price, worker throughput, etc. There's a lot of fancy stuff we can do
-	// with filtering to make sure that for any given chunk we always use the
-	// optimal set of workers, and this is the spot where most of the filtering
-	// will happen.
+	// Workers that aren't among the fastest workers holding pieces of the
+	// chunk are put on standby until the preferred workers prove
+	// insufficient. The preferred workers are selected based on the
+	// throughput and latency of their hosts when the chunk is distributed.
+	//
+	// TODO: This is also where we would put filters based on worker price.
 	//
 	// One major thing that we will want to be careful about when we improve
 	// this section is total memory vs. worker bandwidth. If the renter is
@@ -188,13 +237,11 @@ func (w *worker) ownedProcessDownloadChunk(udc *unfinishedDownloadChunk) *unfini
 	// metrics, so that we can avoid holding the worker lock and the udc lock
 	// simultaneously (deadlock risk). The 'owned' variables of the worker are
 	// variables that are only accessed by the master worker thread.
-	meetsExtraCriteria := true
-
-	// TODO: There's going to need to be some method for relaxing criteria after
-	// the first wave of workers are sent off. If the first waves of workers
-	// fail, the next wave need to realize that they shouldn't immediately go on
-	// standby because for some reason there were failures in the first wave and
-	// now the second/etc. wave of workers is needed.
+	//
+	// The criteria are relaxed by managedCleanUp once standby workers are
+	// needed, so that the second/etc. wave of workers doesn't immediately go
+	// back on standby.
+	meetsExtraCriteria := udc.preferredWorkers == nil || preferred || udc.criteriaRelaxed
 
 	// Figure out if this chunk needs another worker actively downloading
 	// pieces. The number of workers that should be active simultaneously on
@@ -219,3 +266,50 @@ func (w *worker) ownedProcessDownloadChunk(udc *unfinishedDownloadChunk) *unfini
 	udc.workersStandby = append(udc.workersStandby, w)
 	return nil
 }
+
+// preferredDownloadWorkers returns the preferred workers for the chunk among
+// the workers of the renter. The caller must hold the renter lock.
+func (r *Renter) preferredDownloadWorkers(udc *unfinishedDownloadChunk) map[types.FileContractID]bool {
+	hosts := make(map[types.FileContractID]modules.HostDBEntry)
+	for id, w := range r.workerPool {
+		if _, exists := udc.staticChunkMap[id]; exists {
+			host, _ := r.hostDB.Host(w.hostPubKey)
+			hosts[id] = host
+		}
+	}
+	return udc.fastestWorkers(hosts)
+}
+
+// fastestWorkers returns the workers that are expected to fetch the pieces of
+// the chunk fastest, based on the throughput and latency that were measured
+// for the hosts of the workers. Workers of hosts that haven't been measured
+// yet are always included, so that they get measured. nil is returned if every
+// worker is needed.
+func (udc *unfinishedDownloadChunk) fastestWorkers(hosts map[types.FileContractID]modules.HostDBEntry) map[types.FileContractID]bool {
+	type candidate struct {
+		id       types.FileContractID
+		duration time.Duration
+	}
+	var measured []candidate
+	fastest := make(map[types.FileContractID]bool)
+	for id, host := range hosts {
+		if host.DownloadThroughput == 0 {
+			fastest[id] = true
+			continue
+		}
+		// Estimate how long it takes the host to send a piece.
+		transfer := time.Duration(float64(udc.staticPieceSize) / host.DownloadThroughput * float64(time.Second))
+		measured = append(measured, candidate{id, host.Latency + transfer})
+	}
+	desired := udc.erasureCode.MinPieces() + udc.staticOverdrive
+	if len(hosts) <= desired {
+		return nil
+	}
+	sort.Slice(measured, func(i, j int) bool {
+		return measured[i].duration < measured[j].duration
+	})
+	for i := 0; len(fastest) < desired; i++ {
+		fastest[measured[i].id] = true
+	}
+	return fastest
+}
@@ -1,9 +1,35 @@
 package renter
 
 import (
+	"errors"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	// errPackSectorDropped is returned if a worker drops a sector of a pack
+	// because it is on cooldown or has been terminated.
+	errPackSectorDropped = errors.New("worker dropped the pack sector")
+)
+
+type (
+	// packSectorJob is the upload of a sector of a pack by a worker.
+	packSectorJob struct {
+		sector     []byte
+		resultChan chan packSectorResult
+	}
+
+	// packSectorResult is the result of a packSectorJob. The contract
+	// doesn't contain any pieces.
+	packSectorResult struct {
+		contract fileContract
+		root     crypto.Hash
+		spending modules.DataSpending
+		err      error
+	}
 )
 
 // managedDropChunk will remove a worker from the responsibility of tracking a chunk.
@@ -34,6 +60,20 @@ func (w *worker) managedDropUploadChunks() {
 	for i := 0; i < len(chunksToDrop); i++ {
 		w.managedDropChunk(chunksToDrop[i])
 	}
+	w.managedDropPackSectors()
+}
+
+// managedDropPackSectors fails all of the pack sectors that the worker has
+// received.
+func (w *worker) managedDropPackSectors() {
+	w.mu.Lock()
+	jobs := w.packSectors
+	w.packSectors = nil
+	w.mu.Unlock()
+
+	for _, job := range jobs {
+		job.resultChan <- packSectorResult{err: errPackSectorDropped}
+	}
 }
 
 // managedKillUploading will disable all uploading for the worker.
@@ -108,7 +148,7 @@ func (w *worker) managedUpload(uc *unfinishedUploadChunk, pieceIndex uint64) {
 
 	// Perform the upload, and update the failure stats based on the success of
 	// the upload attempt.
-	root, err := e.Upload(uc.physicalChunkData[pieceIndex])
+	root, spending, err := e.Upload(uc.physicalChunkData[pieceIndex])
 	if err != nil {
 		w.renter.log.Debugln("Worker failed to upload via the editor:", err)
 		w.managedUploadFailed(uc, pieceIndex)
@@ -138,8 +178,10 @@ func (w *worker) managedUpload(uc *unfinishedUploadChunk, pieceIndex uint64) {
 	})
 	uc.renterFile.contracts[w.contract.ID] = contract
 	w.renter.saveFile(uc.renterFile)
+	siaPath := uc.renterFile.name
 	uc.renterFile.mu.Unlock()
 	w.renter.mu.Unlock(id)
+	w.renter.managedRecordSpending(siaPath, spending)
 
 	// Upload is complete. Update the state of the chunk and the renter's memory
 	// available to reflect the completed upload.
@@ -245,3 +287,84 @@ func (w *worker) managedUploadFailed(uc *unfinishedUploadChunk, pieceIndex uint6
 	// Because the worker is now on cooldown, drop all remaining chunks.
 	w.managedDropUploadChunks()
 }
+
+// managedQueuePackSector queues the upload of a sector of a pack. The result
+// of the upload is sent down the returned channel.
+func (w *worker) managedQueuePackSector(sector []byte) <-chan packSectorResult {
+	job := &packSectorJob{
+		sector:     sector,
+		resultChan: make(chan packSectorResult, 1),
+	}
+	w.mu.Lock()
+	if w.uploadTerminated || w.onUploadCooldown() {
+		w.mu.Unlock()
+		job.resultChan <- packSectorResult{err: errPackSectorDropped}
+		return job.resultChan
+	}
+	w.packSectors = append(w.packSectors, job)
+	w.mu.Unlock()
+
+	// Send a signal informing the work thread that there is work.
+	select {
+	case w.uploadChan <- struct{}{}:
+	default:
+	}
+	return job.resultChan
+}
+
+// managedNextPackSector pulls the next pack sector out of the worker's work
+// queue.
+func (w *worker) managedNextPackSector() *packSectorJob {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.packSectors) == 0 {
+		return nil
+	}
+	job := w.packSectors[0]
+	w.packSectors = w.packSectors[1:]
+	return job
+}
+
+// managedUploadPackSector uploads a sector of a pack and reports the result.
+func (w *worker) managedUploadPackSector(job *packSectorJob) {
+	var result packSectorResult
+	result.contract, result.root, result.spending, result.err = w.managedUploadSector(job.sector)
+	job.resultChan <- result
+}
+
+// managedUploadSector uploads a full sector to the worker's host. It returns
+// the contract storing the sector, without any pieces, the Merkle root of the
+// sector and the money spent on the upload.
+func (w *worker) managedUploadSector(sector []byte) (fileContract, crypto.Hash, modules.DataSpending, error) {
+	e, err := w.renter.hostContractor.Editor(w.contract.ID, w.renter.tg.StopChan())
+	if err != nil {
+		w.managedUploadSectorFailed()
+		return fileContract{}, crypto.Hash{}, modules.DataSpending{}, err
+	}
+	defer e.Close()
+	root, spending, err := e.Upload(sector)
+	if err != nil {
+		w.managedUploadSectorFailed()
+		return fileContract{}, crypto.Hash{}, modules.DataSpending{}, err
+	}
+	w.mu.Lock()
+	w.uploadConsecutiveFailures = 0
+	w.mu.Unlock()
+	return fileContract{
+		ID:          w.contract.ID,
+		IP:          e.Address(),
+		WindowStart: e.EndHeight(),
+	}, root, spending, nil
+}
+
+// managedUploadSectorFailed puts the worker on cooldown after it failed to
+// upload a sector, unless the renter is offline.
+func (w *worker) managedUploadSectorFailed() {
+	if !w.renter.g.Online() {
+		return
+	}
+	w.mu.Lock()
+	w.uploadRecentFailure = time.Now()
+	w.uploadConsecutiveFailures++
+	w.mu.Unlock()
+}
@@ -0,0 +1,36 @@
+package modules
+
+const (
+	// S3GatewayKeysFile is the name of the file in the renter directory that
+	// stores the access keys of the S3 gateway.
+	S3GatewayKeysFile = "s3keys.json"
+)
+
+type (
+	// S3Key is an access key of the S3 gateway. Requests to the gateway are
+	// signed with the secret access key using AWS Signature Version 4.
+	S3Key struct {
+		AccessKeyID     string `json:"accesskeyid"`
+		SecretAccessKey string `json:"secretaccesskey"`
+	}
+
+	// An S3Gateway serves a subset of the Amazon S3 REST API in front of the
+	// renter. Buckets are the top-level directories of the renter, and the
+	// key of an object is its siapath within the bucket.
+	S3Gateway interface {
+		// Address returns the address the gateway is listening on.
+		Address() string
+
+		// AddKey generates and stores a new access key.
+		AddKey() (S3Key, error)
+
+		// Close stops the gateway.
+		Close() error
+
+		// Keys returns the access keys that are accepted by the gateway.
+		Keys() []S3Key
+
+		// RemoveKey removes the access key with the specified ID.
+		RemoveKey(accessKeyID string) error
+	}
+)
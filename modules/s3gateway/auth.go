@@ -0,0 +1,292 @@
+package s3gateway
+
+// auth.go verifies that requests are signed with AWS Signature Version 4. The
+// client derives a signing key from its secret access key and the credential
+// scope of the request, and signs a canonical form of the request with it. The
+// gateway computes the same signature from the secret access key that it
+// stored for the access key ID, and rejects the request if they differ.
+//
+// The payload of a request is covered by the signature through the
+// x-amz-content-sha256 header. If the header contains a hash, the body is
+// verified while it is being read. Payloads that are signed chunk by chunk
+// aren't supported.
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// signatureAlgorithm is the only supported signature algorithm.
+	signatureAlgorithm = "AWS4-HMAC-SHA256"
+
+	// unsignedPayload is the x-amz-content-sha256 header of requests whose
+	// payload isn't signed.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// streamingPayloadPrefix is the prefix of the x-amz-content-sha256 header
+	// of requests whose payload is signed chunk by chunk.
+	streamingPayloadPrefix = "STREAMING-"
+
+	// amzDateFormat is the format of the x-amz-date header.
+	amzDateFormat = "20060102T150405Z"
+
+	// maxClockSkew is the largest difference between the time a request was
+	// signed and the time it is received that is accepted.
+	maxClockSkew = 15 * time.Minute
+)
+
+// A credential is the credential of a signed request. The date, region and
+// service make up the scope of the signing key.
+type credential struct {
+	accessKeyID string
+	date        string
+	region      string
+	service     string
+}
+
+// scope returns the credential scope of the signature.
+func (c credential) scope() string {
+	return c.date + "/" + c.region + "/" + c.service + "/aws4_request"
+}
+
+// payloadReader verifies that the data read from it has the expected SHA256
+// hash. It returns errContentSHA256Mismatch instead of io.EOF if the hash
+// doesn't match, and remembers the mismatch in case the error is wrapped by
+// the reader's consumer.
+type payloadReader struct {
+	r        io.ReadCloser
+	h        hash.Hash
+	expected []byte
+	mismatch bool
+}
+
+// Read implements io.Reader.
+func (pr *payloadReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.h.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(pr.h.Sum(nil), pr.expected) {
+		pr.mismatch = true
+		return n, errContentSHA256Mismatch
+	}
+	return n, err
+}
+
+// Close implements io.Closer.
+func (pr *payloadReader) Close() error {
+	return pr.r.Close()
+}
+
+// payloadMismatch returns whether reading the body of req failed because it
+// didn't match the signed payload hash.
+func payloadMismatch(req *http.Request) bool {
+	pr, ok := req.Body.(*payloadReader)
+	return ok && pr.mismatch
+}
+
+// parseAuthorization parses the Authorization header of a request signed with
+// Signature Version 4.
+func parseAuthorization(header string) (cred credential, signedHeaders []string, signature string, err error) {
+	if !strings.HasPrefix(header, signatureAlgorithm+" ") {
+		return credential{}, nil, "", errUnsupportedAuthorization
+	}
+	fields := make(map[string]string)
+	for _, field := range strings.Split(strings.TrimPrefix(header, signatureAlgorithm+" "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			return credential{}, nil, "", errAuthorizationMalformed
+		}
+		fields[kv[0]] = kv[1]
+	}
+
+	scope := strings.Split(fields["Credential"], "/")
+	if len(scope) != 5 || scope[4] != "aws4_request" || scope[0] == "" {
+		return credential{}, nil, "", errAuthorizationMalformed
+	}
+	cred = credential{
+		accessKeyID: scope[0],
+		date:        scope[1],
+		region:      scope[2],
+		service:     scope[3],
+	}
+	if fields["SignedHeaders"] == "" || fields["Signature"] == "" {
+		return credential{}, nil, "", errAuthorizationMalformed
+	}
+	return cred, strings.Split(fields["SignedHeaders"], ";"), fields["Signature"], nil
+}
+
+// uriEncode encodes s as required for canonical requests. Every byte except
+// the unreserved characters is percent-encoded, and slashes are encoded
+// unless s is a path.
+func uriEncode(s string, isPath bool) string {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			buf.WriteByte(c)
+		case c == '/' && isPath:
+			buf.WriteByte(c)
+		default:
+			buf.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+	return buf.String()
+}
+
+// canonicalRequest returns the canonical form of req that is signed by the
+// client.
+func canonicalRequest(req *http.Request, signedHeaders []string, payloadHash string) string {
+	uri := uriEncode(req.URL.Path, true)
+	if uri == "" {
+		uri = "/"
+	}
+
+	var params []string
+	for key, values := range req.URL.Query() {
+		for _, value := range values {
+			params = append(params, uriEncode(key, false)+"="+uriEncode(value, false))
+		}
+	}
+	sort.Strings(params)
+
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		var value string
+		switch name {
+		case "host":
+			value = req.Host
+		case "content-length":
+			value = strconv.FormatInt(req.ContentLength, 10)
+		default:
+			var values []string
+			for _, v := range req.Header[http.CanonicalHeaderKey(name)] {
+				values = append(values, strings.Join(strings.Fields(v), " "))
+			}
+			value = strings.Join(values, ",")
+		}
+		headers.WriteString(name + ":" + value + "\n")
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		uri,
+		strings.Join(params, "&"),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// signature returns the signature of a canonical request that was signed at
+// amzDate with the secret access key.
+func signature(secret string, cred credential, amzDate, canonical string) string {
+	key := hmacSHA256([]byte("AWS4"+secret), cred.date)
+	key = hmacSHA256(key, cred.region)
+	key = hmacSHA256(key, cred.service)
+	key = hmacSHA256(key, "aws4_request")
+
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{
+		signatureAlgorithm,
+		amzDate,
+		cred.scope(),
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// requestTime returns the time at which req was signed, formatted like the
+// x-amz-date header.
+func requestTime(req *http.Request) (string, time.Time, error) {
+	if amzDate := req.Header.Get("X-Amz-Date"); amzDate != "" {
+		t, err := time.Parse(amzDateFormat, amzDate)
+		if err != nil {
+			return "", time.Time{}, errMissingDate
+		}
+		return amzDate, t, nil
+	}
+	t, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return "", time.Time{}, errMissingDate
+	}
+	return t.UTC().Format(amzDateFormat), t, nil
+}
+
+// authenticate verifies the signature of req. If the signature covers the
+// hash of the payload, the body of req is replaced by a payloadReader that
+// verifies it.
+func (g *S3Gateway) authenticate(req *http.Request) error {
+	header := req.Header.Get("Authorization")
+	if header == "" {
+		return errAccessDenied
+	}
+	cred, signedHeaders, sig, err := parseAuthorization(header)
+	if err != nil {
+		return err
+	}
+	amzDate, t, err := requestTime(req)
+	if err != nil {
+		return err
+	}
+	if skew := g.now().Sub(t); skew > maxClockSkew || skew < -maxClockSkew {
+		return errRequestTimeTooSkewed
+	}
+	if cred.date != amzDate[:8] || cred.service != "s3" {
+		return errAuthorizationMalformed
+	}
+	signsHost := false
+	for _, name := range signedHeaders {
+		signsHost = signsHost || name == "host"
+	}
+	if !signsHost {
+		return errAuthorizationMalformed
+	}
+
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return errMissingContentSHA256
+	} else if strings.HasPrefix(payloadHash, streamingPayloadPrefix) {
+		return errStreamingPayload
+	}
+
+	g.mu.Lock()
+	secret, exists := g.keys[cred.accessKeyID]
+	g.mu.Unlock()
+	if !exists {
+		return errInvalidAccessKeyID
+	}
+	expected := signature(secret, cred, amzDate, canonicalRequest(req, signedHeaders, payloadHash))
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return errSignatureDoesNotMatch
+	}
+
+	if payloadHash != unsignedPayload {
+		hash, err := hex.DecodeString(payloadHash)
+		if err != nil || len(hash) != sha256.Size {
+			return errContentSHA256Mismatch
+		}
+		req.Body = &payloadReader{
+			r:        req.Body,
+			h:        sha256.New(),
+			expected: hash,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package s3gateway
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxListKeys is the largest number of keys returned by a single
+	// ListObjectsV2 request.
+	maxListKeys = 1000
+)
+
+// validateBucketName checks that the bucket name follows the naming rules of
+// S3, which also makes it a valid name for a top-level directory.
+func validateBucketName(bucket string) error {
+	if len(bucket) < 3 || len(bucket) > 63 {
+		return errInvalidBucketName
+	}
+	for i := 0; i < len(bucket); i++ {
+		c := bucket[i]
+		alnum := ('a' <= c && c <= 'z') || ('0' <= c && c <= '9')
+		if !alnum && (c != '.' && c != '-' || i == 0 || i == len(bucket)-1) {
+			return errInvalidBucketName
+		}
+	}
+	if strings.Contains(bucket, "..") {
+		return errInvalidBucketName
+	}
+	return nil
+}
+
+// validateKey checks that the key of an object can be mapped to a siapath
+// within its bucket.
+func validateKey(key string) error {
+	for _, elem := range strings.Split(key, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return errInvalidKey
+		}
+	}
+	return nil
+}
+
+// managedBucketExists returns errNoSuchBucket if the bucket doesn't exist.
+func (g *S3Gateway) managedBucketExists(bucket string) error {
+	if _, _, err := g.renter.DirList(bucket); err != nil {
+		return errNoSuchBucket
+	}
+	return nil
+}
+
+// listBuckets handles ListBuckets requests. Every top-level directory of the
+// renter is a bucket.
+func (g *S3Gateway) listBuckets(w http.ResponseWriter) error {
+	dirs, _, err := g.renter.DirList("")
+	if err != nil {
+		return err
+	}
+	result := listAllMyBucketsResult{
+		Xmlns:   s3Namespace,
+		Owner:   owner{ID: "sia", DisplayName: "sia"},
+		Buckets: []bucketInfo{},
+	}
+	// The first directory is the root itself.
+	for _, dir := range dirs[1:] {
+		if validateBucketName(dir.SiaPath) != nil {
+			continue
+		}
+		result.Buckets = append(result.Buckets, bucketInfo{
+			Name:         dir.SiaPath,
+			CreationDate: modTime,
+		})
+	}
+	writeXML(w, result)
+	return nil
+}
+
+// headBucket handles HeadBucket requests.
+func (g *S3Gateway) headBucket(w http.ResponseWriter, bucket string) error {
+	if err := g.managedBucketExists(bucket); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// getBucketLocation handles GetBucketLocation requests. All buckets are in
+// the default region.
+func (g *S3Gateway) getBucketLocation(w http.ResponseWriter, bucket string) error {
+	if err := g.managedBucketExists(bucket); err != nil {
+		return err
+	}
+	writeXML(w, locationConstraint{Xmlns: s3Namespace})
+	return nil
+}
+
+// createBucket handles CreateBucket requests by creating a top-level
+// directory.
+func (g *S3Gateway) createBucket(w http.ResponseWriter, bucket string) error {
+	if g.managedBucketExists(bucket) == nil {
+		return errBucketAlreadyOwnedByYou
+	}
+	if err := g.renter.CreateDir(bucket); err != nil {
+		return err
+	}
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// deleteBucket handles DeleteBucket requests. Like in S3, only buckets
+// without objects can be deleted.
+func (g *S3Gateway) deleteBucket(w http.ResponseWriter, bucket string) error {
+	if err := g.managedBucketExists(bucket); err != nil {
+		return err
+	}
+	for _, f := range g.renter.FileList() {
+		if strings.HasPrefix(f.SiaPath, bucket+"/") {
+			return errBucketNotEmpty
+		}
+	}
+	if err := g.renter.DeleteDir(bucket); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// listObjectsV2 handles ListObjectsV2 requests. The continuation token is the
+// last key or common prefix of the previous page.
+func (g *S3Gateway) listObjectsV2(w http.ResponseWriter, bucket string, query url.Values) error {
+	if err := g.managedBucketExists(bucket); err != nil {
+		return err
+	}
+	maxKeys := maxListKeys
+	if s := query.Get("max-keys"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return errInvalidMaxKeys
+		}
+		if n < maxKeys {
+			maxKeys = n
+		}
+	}
+	result := listBucketResult{
+		Xmlns:             s3Namespace,
+		Name:              bucket,
+		Prefix:            query.Get("prefix"),
+		Delimiter:         query.Get("delimiter"),
+		StartAfter:        query.Get("start-after"),
+		ContinuationToken: query.Get("continuation-token"),
+		MaxKeys:           maxKeys,
+	}
+	start := result.StartAfter
+	if result.ContinuationToken != "" {
+		token, err := base64.URLEncoding.DecodeString(result.ContinuationToken)
+		if err != nil {
+			return apiError{"InvalidArgument", "The continuation token provided is incorrect", http.StatusBadRequest}
+		}
+		start = string(token)
+	}
+
+	// Collect the matching objects of the bucket, sorted by key.
+	objects := make(map[string]uint64)
+	var keys []string
+	for _, f := range g.renter.FileList() {
+		key := strings.TrimPrefix(f.SiaPath, bucket+"/")
+		if key == f.SiaPath || !strings.HasPrefix(key, result.Prefix) {
+			continue
+		}
+		objects[key] = f.Filesize
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var last string
+	for _, key := range keys {
+		// Skip the keys up to the start, including the keys of a common
+		// prefix that was returned on the previous page.
+		if key <= start || (result.Delimiter != "" && strings.HasSuffix(start, result.Delimiter) && strings.HasPrefix(key, start)) {
+			continue
+		}
+		entry := key
+		if result.Delimiter != "" {
+			if i := strings.Index(key[len(result.Prefix):], result.Delimiter); i >= 0 {
+				entry = key[:len(result.Prefix)+i+len(result.Delimiter)]
+			}
+		}
+		if entry == last {
+			continue
+		}
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = base64.URLEncoding.EncodeToString([]byte(last))
+			break
+		}
+		if entry != key {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: entry})
+		} else {
+			result.Contents = append(result.Contents, objectInfo{
+				Key:          key,
+				LastModified: modTime,
+				Size:         objects[key],
+				StorageClass: "STANDARD",
+			})
+		}
+		result.KeyCount++
+		last = entry
+	}
+	writeXML(w, result)
+	return nil
+}
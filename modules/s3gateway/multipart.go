@@ -0,0 +1,192 @@
+package s3gateway
+
+// multipart.go implements multipart uploads. The parts of an upload are
+// stored in a temporary directory of the gateway until the upload is
+// completed, at which point they are uploaded to the renter as a single file
+// in the order given by the client. Uploads that are in progress are lost
+// when the gateway shuts down.
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/persist"
+
+	"github.com/NebulousLabs/errors"
+)
+
+const (
+	// uploadsDir is the directory in the persist directory that contains the
+	// parts of multipart uploads.
+	uploadsDir = "s3uploads"
+
+	// maxPartNumber is the largest part number of a multipart upload.
+	maxPartNumber = 10000
+)
+
+// A multipartUpload is a multipart upload in progress. parts maps the numbers
+// of the uploaded parts to their ETags.
+type multipartUpload struct {
+	bucket string
+	key    string
+	dir    string
+	parts  map[int]string
+}
+
+// partPath returns the path of the file that stores a part of the upload.
+func (upload *multipartUpload) partPath(partNumber int) string {
+	return filepath.Join(upload.dir, strconv.Itoa(partNumber))
+}
+
+// createMultipartUpload handles CreateMultipartUpload requests.
+func (g *S3Gateway) createMultipartUpload(w http.ResponseWriter, bucket, key string) error {
+	if err := g.managedBucketExists(bucket); err != nil {
+		return err
+	}
+	id := persist.RandomSuffix()
+	dir := filepath.Join(g.persistDir, uploadsDir, id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	g.uploads[id] = &multipartUpload{
+		bucket: bucket,
+		key:    key,
+		dir:    dir,
+		parts:  make(map[int]string),
+	}
+	g.mu.Unlock()
+
+	writeXML(w, initiateMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Bucket:   bucket,
+		Key:      key,
+		UploadID: id,
+	})
+	return nil
+}
+
+// uploadPart handles UploadPart requests. Uploading a part with the number of
+// an existing part replaces it.
+func (g *S3Gateway) uploadPart(w http.ResponseWriter, req *http.Request, uploadID, partNumberStr string) error {
+	partNumber, err := strconv.Atoi(partNumberStr)
+	if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+		return errInvalidPartNumber
+	}
+	g.mu.Lock()
+	upload, exists := g.uploads[uploadID]
+	g.mu.Unlock()
+	if !exists {
+		return errNoSuchUpload
+	}
+
+	// Write the part to a temporary file first, so that a failed upload
+	// doesn't corrupt an existing part.
+	tmp, err := os.Create(upload.partPath(partNumber) + "_" + persist.RandomSuffix())
+	if err != nil {
+		return err
+	}
+	h := md5.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), req.Body)
+	err = errors.Compose(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), upload.partPath(partNumber))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		if payloadMismatch(req) {
+			return errContentSHA256Mismatch
+		}
+		return err
+	}
+
+	etag := `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+	g.mu.Lock()
+	upload.parts[partNumber] = etag
+	g.mu.Unlock()
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// completeMultipartUpload handles CompleteMultipartUpload requests. The ETag
+// of the object is computed like in S3, from the MD5 hashes of its parts.
+func (g *S3Gateway) completeMultipartUpload(w http.ResponseWriter, req *http.Request, bucket, key, uploadID string) error {
+	var cmu completeMultipartUpload
+	if err := xml.NewDecoder(req.Body).Decode(&cmu); err != nil || len(cmu.Parts) == 0 {
+		return errMalformedXML
+	}
+
+	// Check the list of parts and remove the upload, so that it can't be
+	// completed twice.
+	g.mu.Lock()
+	upload, exists := g.uploads[uploadID]
+	if !exists || upload.bucket != bucket || upload.key != key {
+		g.mu.Unlock()
+		return errNoSuchUpload
+	}
+	etagHash := md5.New()
+	for i, part := range cmu.Parts {
+		if i > 0 && part.PartNumber <= cmu.Parts[i-1].PartNumber {
+			g.mu.Unlock()
+			return errInvalidPartOrder
+		}
+		etag, exists := upload.parts[part.PartNumber]
+		if !exists || strings.Trim(part.ETag, `"`) != strings.Trim(etag, `"`) {
+			g.mu.Unlock()
+			return errInvalidPart
+		}
+		sum, _ := hex.DecodeString(strings.Trim(etag, `"`))
+		etagHash.Write(sum)
+	}
+	delete(g.uploads, uploadID)
+	g.mu.Unlock()
+	defer os.RemoveAll(upload.dir)
+
+	// Upload the parts as a single file.
+	var readers []io.Reader
+	for _, part := range cmu.Parts {
+		f, err := os.Open(upload.partPath(part.PartNumber))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+	// The parts were verified when they were uploaded.
+	verify := func() error { return nil }
+	if err := g.managedUpload(bucket, key, io.MultiReader(readers...), verify); err != nil {
+		return err
+	}
+
+	writeXML(w, completeMultipartUploadResult{
+		Xmlns:  s3Namespace,
+		Bucket: bucket,
+		Key:    key,
+		ETag:   `"` + hex.EncodeToString(etagHash.Sum(nil)) + "-" + strconv.Itoa(len(cmu.Parts)) + `"`,
+	})
+	return nil
+}
+
+// abortMultipartUpload handles AbortMultipartUpload requests.
+func (g *S3Gateway) abortMultipartUpload(w http.ResponseWriter, uploadID string) error {
+	g.mu.Lock()
+	upload, exists := g.uploads[uploadID]
+	delete(g.uploads, uploadID)
+	g.mu.Unlock()
+	if !exists {
+		return errNoSuchUpload
+	}
+	if err := os.RemoveAll(upload.dir); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,168 @@
+package s3gateway
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+
+	"github.com/NebulousLabs/errors"
+)
+
+// contentType returns the content type of an object based on the extension
+// of its key. Setting it up front keeps http.ServeContent from downloading
+// the start of the object to sniff it.
+func contentType(key string) string {
+	if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// managedUpload uploads the data read from r to the object with the key in
+// the bucket. The data is uploaded to a temporary siapath first, and only
+// replaces an existing object once the upload has succeeded and verify, which
+// checks the digest of the data, returns nil. Otherwise the temporary file is
+// deleted again and the existing object is left untouched.
+func (g *S3Gateway) managedUpload(bucket, key string, r io.Reader, verify func() error) error {
+	sp := siaPath(bucket, key)
+	tmp := tmpDir + "/" + persist.RandomSuffix()
+	err := g.renter.UploadStreamFromReader(modules.FileUploadParams{SiaPath: tmp}, r)
+	if err == nil {
+		err = verify()
+	}
+	if err == nil {
+		err = g.managedReplace(tmp, sp)
+	}
+	if err != nil {
+		if _, statErr := g.renter.File(tmp); statErr == nil {
+			if delErr := g.renter.DeleteFile(tmp); delErr != nil {
+				g.log.Printf("WARN: unable to delete %v: %v", tmp, delErr)
+			}
+		}
+		g.log.Printf("WARN: upload of %v failed: %v", sp, err)
+	}
+	return err
+}
+
+// managedReplace renames the uploaded file tmp to the siapath of an object.
+// An existing object is moved out of the way first and only deleted once the
+// uploaded file has taken its place.
+func (g *S3Gateway) managedReplace(tmp, sp string) error {
+	g.replaceMu.Lock()
+	defer g.replaceMu.Unlock()
+	if _, err := g.renter.File(sp); err != nil {
+		return g.renter.RenameFile(tmp, sp)
+	}
+	old := tmpDir + "/" + persist.RandomSuffix()
+	if err := g.renter.RenameFile(sp, old); err != nil {
+		return errors.AddContext(err, "unable to replace existing object")
+	}
+	if err := g.renter.RenameFile(tmp, sp); err != nil {
+		return errors.Compose(err, g.renter.RenameFile(old, sp))
+	}
+	if err := g.renter.DeleteFile(old); err != nil {
+		g.log.Printf("WARN: unable to delete the replaced object %v: %v", sp, err)
+	}
+	return nil
+}
+
+// removeTmpFiles deletes the temporary files that were left behind by uploads
+// that were interrupted by a shutdown.
+func (g *S3Gateway) removeTmpFiles() {
+	_, files, err := g.renter.DirList(tmpDir)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		if err := g.renter.DeleteFile(f.SiaPath); err != nil {
+			g.log.Printf("WARN: unable to delete %v: %v", f.SiaPath, err)
+		}
+	}
+}
+
+// headObject handles HeadObject requests.
+func (g *S3Gateway) headObject(w http.ResponseWriter, bucket, key string) error {
+	f, err := g.renter.File(siaPath(bucket, key))
+	if err != nil {
+		return errNoSuchKey
+	}
+	w.Header().Set("Content-Type", contentType(key))
+	w.Header().Set("Content-Length", strconv.FormatUint(f.Filesize, 10))
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// getObject handles GetObject requests, including range requests, by
+// streaming the object from the renter.
+func (g *S3Gateway) getObject(w http.ResponseWriter, req *http.Request, bucket, key string) error {
+	sp := siaPath(bucket, key)
+	if _, err := g.renter.File(sp); err != nil {
+		return errNoSuchKey
+	}
+	_, streamer, err := g.renter.Streamer(sp, streamPrefetch)
+	if err != nil {
+		return err
+	}
+	defer streamer.Close()
+	w.Header().Set("Content-Type", contentType(key))
+	http.ServeContent(w, req, key, time.Time{}, streamer)
+	return nil
+}
+
+// putObject handles PutObject requests. The ETag of the object is the MD5
+// hash of its data, like in S3. The object is only replaced if the data
+// matches both its signed SHA256 hash and its Content-MD5.
+func (g *S3Gateway) putObject(w http.ResponseWriter, req *http.Request, bucket, key string) error {
+	if err := g.managedBucketExists(bucket); err != nil {
+		return err
+	}
+	var contentMD5 []byte
+	if s := req.Header.Get("Content-MD5"); s != "" {
+		var err error
+		contentMD5, err = base64.StdEncoding.DecodeString(s)
+		if err != nil || len(contentMD5) != md5.Size {
+			return errInvalidDigest
+		}
+	}
+
+	h := md5.New()
+	verify := func() error {
+		if contentMD5 != nil && string(contentMD5) != string(h.Sum(nil)) {
+			return errBadDigest
+		}
+		return nil
+	}
+	if err := g.managedUpload(bucket, key, io.TeeReader(req.Body, h), verify); err != nil {
+		if payloadMismatch(req) {
+			return errContentSHA256Mismatch
+		}
+		return err
+	}
+	sum := h.Sum(nil)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum)+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// deleteObject handles DeleteObject requests. Like in S3, deleting an object
+// that doesn't exist succeeds.
+func (g *S3Gateway) deleteObject(w http.ResponseWriter, bucket, key string) error {
+	sp := siaPath(bucket, key)
+	if _, err := g.renter.File(sp); err == nil {
+		if err := g.renter.DeleteFile(sp); err != nil {
+			return err
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
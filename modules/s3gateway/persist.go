@@ -0,0 +1,151 @@
+package s3gateway
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+
+	"github.com/NebulousLabs/errors"
+	"github.com/NebulousLabs/fastrand"
+)
+
+const (
+	// accessKeyIDLen is the length of a generated access key ID.
+	accessKeyIDLen = 20
+
+	// accessKeyIDPrefix is the prefix of the generated access key IDs.
+	accessKeyIDPrefix = "SIA"
+
+	// accessKeyIDAlphabet contains the characters of generated access key
+	// IDs.
+	accessKeyIDAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	// secretAccessKeySize is the number of random bytes of a generated secret
+	// access key.
+	secretAccessKeySize = 30
+)
+
+var (
+	// errUnknownKey is returned when removing an access key that doesn't
+	// exist.
+	errUnknownKey = errors.New("no access key with that ID")
+
+	// errLastKey is returned when removing the last access key, which would
+	// make the gateway unusable.
+	errLastKey = errors.New("the last access key can't be removed")
+
+	keysMetadata = persist.Metadata{
+		Header:  "S3 Gateway Keys",
+		Version: "1.3.3",
+	}
+)
+
+// persistence contains the persistent data of the gateway.
+type persistence struct {
+	Keys []modules.S3Key
+}
+
+// initPersist creates the log and loads the access keys. If there are no
+// access keys yet, one is generated.
+func (g *S3Gateway) initPersist() error {
+	if err := os.MkdirAll(g.persistDir, 0700); err != nil {
+		return err
+	}
+	// Multipart uploads don't survive a restart.
+	if err := os.RemoveAll(filepath.Join(g.persistDir, uploadsDir)); err != nil {
+		return err
+	}
+	var err error
+	g.log, err = persist.NewFileLogger(filepath.Join(g.persistDir, logFile))
+	if err != nil {
+		return err
+	}
+
+	var p persistence
+	err = persist.LoadJSON(keysMetadata, &p, filepath.Join(g.persistDir, modules.S3GatewayKeysFile))
+	if os.IsNotExist(err) {
+		if _, err = g.AddKey(); err == nil {
+			g.log.Println("Generated the first access key")
+		}
+	} else if err == nil {
+		for _, key := range p.Keys {
+			g.keys[key.AccessKeyID] = key.SecretAccessKey
+		}
+	}
+	if err != nil {
+		g.log.Close()
+		return err
+	}
+	return nil
+}
+
+// saveKeys saves the access keys of the gateway. The caller has to hold the
+// lock of the gateway.
+func (g *S3Gateway) saveKeys() error {
+	return persist.SaveJSON(keysMetadata, persistence{Keys: g.sortedKeys()}, filepath.Join(g.persistDir, modules.S3GatewayKeysFile))
+}
+
+// sortedKeys returns the access keys of the gateway, sorted by their ID. The
+// caller has to hold the lock of the gateway.
+func (g *S3Gateway) sortedKeys() []modules.S3Key {
+	keys := make([]modules.S3Key, 0, len(g.keys))
+	for id, secret := range g.keys {
+		keys = append(keys, modules.S3Key{AccessKeyID: id, SecretAccessKey: secret})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].AccessKeyID < keys[j].AccessKeyID
+	})
+	return keys
+}
+
+// AddKey generates and stores a new access key.
+func (g *S3Gateway) AddKey() (modules.S3Key, error) {
+	id := []byte(accessKeyIDPrefix)
+	for len(id) < accessKeyIDLen {
+		id = append(id, accessKeyIDAlphabet[fastrand.Intn(len(accessKeyIDAlphabet))])
+	}
+	key := modules.S3Key{
+		AccessKeyID:     string(id),
+		SecretAccessKey: base64.StdEncoding.EncodeToString(fastrand.Bytes(secretAccessKeySize)),
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.keys[key.AccessKeyID] = key.SecretAccessKey
+	if err := g.saveKeys(); err != nil {
+		delete(g.keys, key.AccessKeyID)
+		return modules.S3Key{}, err
+	}
+	return key, nil
+}
+
+// Keys returns the access keys that are accepted by the gateway, sorted by
+// their ID.
+func (g *S3Gateway) Keys() []modules.S3Key {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.sortedKeys()
+}
+
+// RemoveKey removes the access key with the specified ID. The last access key
+// can't be removed.
+func (g *S3Gateway) RemoveKey(accessKeyID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	secret, exists := g.keys[accessKeyID]
+	if !exists {
+		return errUnknownKey
+	} else if len(g.keys) == 1 {
+		return errLastKey
+	}
+	delete(g.keys, accessKeyID)
+	if err := g.saveKeys(); err != nil {
+		g.keys[accessKeyID] = secret
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package s3gateway
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+)
+
+const (
+	// s3Namespace is the XML namespace of the S3 API.
+	s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+	// timeFormat is the format of the timestamps in XML responses.
+	timeFormat = "2006-01-02T15:04:05.000Z"
+)
+
+// An apiError is an error of the S3 API. It is returned to the client as an
+// XML document with the code and message of the error.
+type apiError struct {
+	Code    string
+	Message string
+	Status  int
+}
+
+// Error implements the error interface.
+func (e apiError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+var (
+	errAccessDenied             = apiError{"AccessDenied", "Access Denied", http.StatusForbidden}
+	errAuthorizationMalformed   = apiError{"AuthorizationHeaderMalformed", "The authorization header is malformed", http.StatusBadRequest}
+	errBadDigest                = apiError{"BadDigest", "The Content-MD5 you specified did not match what was received", http.StatusBadRequest}
+	errBucketAlreadyOwnedByYou  = apiError{"BucketAlreadyOwnedByYou", "The bucket you tried to create already exists, and you own it", http.StatusConflict}
+	errBucketNotEmpty           = apiError{"BucketNotEmpty", "The bucket you tried to delete is not empty", http.StatusConflict}
+	errContentSHA256Mismatch    = apiError{"XAmzContentSHA256Mismatch", "The provided x-amz-content-sha256 header does not match what was computed", http.StatusBadRequest}
+	errInvalidAccessKeyID       = apiError{"InvalidAccessKeyId", "The access key ID you provided does not exist in our records", http.StatusForbidden}
+	errInvalidBucketName        = apiError{"InvalidBucketName", "The specified bucket is not valid", http.StatusBadRequest}
+	errInvalidDigest            = apiError{"InvalidDigest", "The Content-MD5 you specified is not valid", http.StatusBadRequest}
+	errInvalidKey               = apiError{"InvalidArgument", "The specified key can't be mapped to a siapath", http.StatusBadRequest}
+	errInvalidMaxKeys           = apiError{"InvalidArgument", "max-keys must be a non-negative integer", http.StatusBadRequest}
+	errInvalidPart              = apiError{"InvalidPart", "One or more of the specified parts could not be found", http.StatusBadRequest}
+	errInvalidPartNumber        = apiError{"InvalidArgument", "Part number must be an integer between 1 and 10000, inclusive", http.StatusBadRequest}
+	errInvalidPartOrder         = apiError{"InvalidPartOrder", "The list of parts was not in ascending order", http.StatusBadRequest}
+	errMalformedXML             = apiError{"MalformedXML", "The XML you provided was not well-formed", http.StatusBadRequest}
+	errMethodNotAllowed         = apiError{"MethodNotAllowed", "The specified method is not allowed against this resource", http.StatusMethodNotAllowed}
+	errMissingContentSHA256     = apiError{"InvalidRequest", "Missing required header for this request: x-amz-content-sha256", http.StatusBadRequest}
+	errMissingDate              = apiError{"AccessDenied", "AWS authentication requires a valid Date or x-amz-date header", http.StatusForbidden}
+	errNoSuchBucket             = apiError{"NoSuchBucket", "The specified bucket does not exist", http.StatusNotFound}
+	errNoSuchKey                = apiError{"NoSuchKey", "The specified key does not exist", http.StatusNotFound}
+	errNoSuchUpload             = apiError{"NoSuchUpload", "The specified multipart upload does not exist", http.StatusNotFound}
+	errNotImplemented           = apiError{"NotImplemented", "A header or query you provided implies functionality that is not implemented", http.StatusNotImplemented}
+	errRequestTimeTooSkewed     = apiError{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large", http.StatusForbidden}
+	errSignatureDoesNotMatch    = apiError{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided", http.StatusForbidden}
+	errStreamingPayload         = apiError{"NotImplemented", "Chunked payload signing is not supported, sign the whole payload or use UNSIGNED-PAYLOAD", http.StatusNotImplemented}
+	errUnsupportedAuthorization = apiError{"AccessDenied", "Only AWS Signature Version 4 in the Authorization header is supported", http.StatusForbidden}
+)
+
+type (
+	// errorResponse is the body of an error response.
+	errorResponse struct {
+		XMLName  xml.Name `xml:"Error"`
+		Code     string
+		Message  string
+		Resource string
+	}
+
+	// owner is the owner of buckets and objects. All of them are owned by the
+	// renter.
+	owner struct {
+		ID          string
+		DisplayName string
+	}
+
+	// bucketInfo describes a bucket in a listAllMyBucketsResult.
+	bucketInfo struct {
+		Name         string
+		CreationDate string
+	}
+
+	// listAllMyBucketsResult is the response to a ListBuckets request.
+	listAllMyBucketsResult struct {
+		XMLName xml.Name     `xml:"ListAllMyBucketsResult"`
+		Xmlns   string       `xml:"xmlns,attr"`
+		Owner   owner        `xml:"Owner"`
+		Buckets []bucketInfo `xml:"Buckets>Bucket"`
+	}
+
+	// locationConstraint is the response to a GetBucketLocation request. An
+	// empty location is the default region.
+	locationConstraint struct {
+		XMLName  xml.Name `xml:"LocationConstraint"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Location string   `xml:",chardata"`
+	}
+
+	// objectInfo describes an object in a listBucketResult.
+	objectInfo struct {
+		Key          string
+		LastModified string
+		Size         uint64
+		StorageClass string
+	}
+
+	// commonPrefix is a prefix of the keys in a listBucketResult that were
+	// grouped together by the delimiter.
+	commonPrefix struct {
+		Prefix string
+	}
+
+	// listBucketResult is the response to a ListObjectsV2 request.
+	listBucketResult struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Xmlns                 string   `xml:"xmlns,attr"`
+		Name                  string
+		Prefix                string
+		Delimiter             string `xml:",omitempty"`
+		StartAfter            string `xml:",omitempty"`
+		ContinuationToken     string `xml:",omitempty"`
+		NextContinuationToken string `xml:",omitempty"`
+		KeyCount              int
+		MaxKeys               int
+		IsTruncated           bool
+		Contents              []objectInfo
+		CommonPrefixes        []commonPrefix
+	}
+
+	// initiateMultipartUploadResult is the response to a
+	// CreateMultipartUpload request.
+	initiateMultipartUploadResult struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Bucket   string
+		Key      string
+		UploadID string `xml:"UploadId"`
+	}
+
+	// completeMultipartUpload is the body of a CompleteMultipartUpload
+	// request.
+	completeMultipartUpload struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+
+	// completeMultipartUploadResult is the response to a
+	// CompleteMultipartUpload request.
+	completeMultipartUploadResult struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Bucket  string
+		Key     string
+		ETag    string
+	}
+)
+
+// modTime is the modification time that is reported for buckets and objects,
+// since the renter doesn't track when files were created.
+var modTime = time.Unix(0, 0).UTC().Format(timeFormat)
+
+// writeXML writes v as the XML body of a successful response.
+func writeXML(w http.ResponseWriter, v interface{}) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		// Marshalling should only fail in the event of a developer error.
+		http.Error(w, "failed to encode response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+// writeError writes err to the client. Errors that aren't apiErrors are
+// reported as internal errors.
+func writeError(w http.ResponseWriter, req *http.Request, err error) {
+	ae, ok := err.(apiError)
+	if !ok {
+		ae = apiError{"InternalError", err.Error(), http.StatusInternalServerError}
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(ae.Status)
+	// Responses to HEAD requests don't have a body.
+	if req.Method == "HEAD" {
+		return
+	}
+	data, _ := xml.Marshal(errorResponse{
+		Code:     ae.Code,
+		Message:  ae.Message,
+		Resource: req.URL.Path,
+	})
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
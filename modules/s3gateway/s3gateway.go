@@ -0,0 +1,236 @@
+// Package s3gateway serves a subset of the Amazon S3 REST API in front of the
+// renter, so that applications that already speak S3 can store their data on
+// Sia without any changes.
+//
+// Buckets are the top-level directories of the renter, and the key of an
+// object is its siapath within the bucket, so the object "photos/cat.jpg" of
+// the bucket "backup" is the renter file "backup/photos/cat.jpg". Only
+// path-style requests are supported, and every request has to be signed with
+// one of the gateway's access keys using AWS Signature Version 4.
+package s3gateway
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+
+	"github.com/NebulousLabs/errors"
+)
+
+const (
+	// logFile is the name of the gateway's log file in the renter directory.
+	logFile = "s3gateway.log"
+
+	// streamPrefetch is the number of chunks that are fetched ahead of the
+	// chunk being read when an object is downloaded.
+	streamPrefetch = 2
+
+	// tmpDir is the directory of the renter that objects are uploaded to
+	// before they replace the object with their key. Its name isn't a valid
+	// bucket name, so it isn't listed as a bucket.
+	tmpDir = ".s3gateway"
+)
+
+var (
+	errNilRenter = errors.New("s3 gateway cannot use a nil renter")
+)
+
+type (
+	// renter is the subset of modules.Renter that is used by the gateway.
+	renter interface {
+		CreateDir(siaPath string) error
+		DeleteDir(siaPath string) error
+		DeleteFile(siaPath string) error
+		DirList(siaPath string) ([]modules.DirectoryInfo, []modules.FileInfo, error)
+		File(siaPath string) (modules.FileInfo, error)
+		FileList() []modules.FileInfo
+		RenameFile(currentName, newName string) error
+		Streamer(siaPath string, prefetch uint64) (string, modules.Streamer, error)
+		UploadStreamFromReader(up modules.FileUploadParams, reader io.Reader) error
+	}
+
+	// An S3Gateway translates S3 requests into calls to the renter.
+	S3Gateway struct {
+		listener   net.Listener
+		server     *http.Server
+		renter     renter
+		log        *persist.Logger
+		persistDir string
+
+		// keys maps the access key IDs of the gateway to their secret access
+		// keys. uploads contains the multipart uploads that are in progress,
+		// keyed by their upload ID.
+		keys    map[string]string
+		uploads map[string]*multipartUpload
+		mu      sync.Mutex
+
+		// replaceMu serializes replacing objects with uploaded files.
+		replaceMu sync.Mutex
+
+		// now returns the current time. It is replaced by tests that sign
+		// requests at a fixed time.
+		now func() time.Time
+	}
+)
+
+// New creates an S3 gateway that listens on addr and serves the files of the
+// renter. The access keys of the gateway are stored in persistDir, which
+// should be the renter's persist directory. If no access key exists yet, one
+// is generated.
+func New(r renter, addr string, persistDir string) (*S3Gateway, error) {
+	if r == nil {
+		return nil, errNilRenter
+	}
+	g := &S3Gateway{
+		renter:     r,
+		persistDir: persistDir,
+		keys:       make(map[string]string),
+		uploads:    make(map[string]*multipartUpload),
+		now:        time.Now,
+	}
+	if err := g.initPersist(); err != nil {
+		return nil, err
+	}
+	g.removeTmpFiles()
+
+	var err error
+	g.listener, err = net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Compose(errors.AddContext(err, "unable to listen on "+addr), g.log.Close())
+	}
+	g.server = &http.Server{Handler: g}
+	go g.threadedServe()
+	g.log.Println("S3 gateway listening on", g.listener.Addr())
+	return g, nil
+}
+
+// threadedServe serves requests until the gateway is closed.
+func (g *S3Gateway) threadedServe() {
+	if err := g.server.Serve(g.listener); err != nil && err != http.ErrServerClosed {
+		g.log.Println("WARN: S3 gateway stopped serving:", err)
+	}
+}
+
+// Address returns the address the gateway is listening on.
+func (g *S3Gateway) Address() string {
+	return g.listener.Addr().String()
+}
+
+// Close stops the gateway. Multipart uploads that are still in progress are
+// discarded.
+func (g *S3Gateway) Close() error {
+	return errors.Compose(
+		g.server.Close(),
+		os.RemoveAll(filepath.Join(g.persistDir, uploadsDir)),
+		g.log.Close(),
+	)
+}
+
+// ServeHTTP implements http.Handler. It authenticates the request and
+// dispatches it to the handler of the requested operation.
+func (g *S3Gateway) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := g.authenticate(req); err != nil {
+		writeError(w, req, err)
+		return
+	}
+	bucket, key := splitPath(req.URL.Path)
+	if err := g.route(w, req, bucket, key); err != nil {
+		writeError(w, req, err)
+	}
+}
+
+// route calls the handler of the operation that is requested by req. Handlers
+// only return an error if they haven't written a response yet.
+func (g *S3Gateway) route(w http.ResponseWriter, req *http.Request, bucket, key string) error {
+	query := req.URL.Query()
+	// Some SDKs name the operation in the query, which carries no additional
+	// information.
+	query.Del("x-id")
+	if bucket == "" {
+		if req.Method != "GET" {
+			return errMethodNotAllowed
+		}
+		return g.listBuckets(w)
+	}
+	if err := validateBucketName(bucket); err != nil {
+		return err
+	}
+
+	// Bucket operations.
+	if key == "" {
+		switch {
+		case req.Method == "HEAD":
+			return g.headBucket(w, bucket)
+		case req.Method == "GET" && hasParam(query, "location"):
+			return g.getBucketLocation(w, bucket)
+		case req.Method == "GET" && query.Get("list-type") == "2":
+			return g.listObjectsV2(w, bucket, query)
+		case req.Method == "PUT" && len(query) == 0:
+			return g.createBucket(w, bucket)
+		case req.Method == "DELETE" && len(query) == 0:
+			return g.deleteBucket(w, bucket)
+		case req.Method == "GET", req.Method == "PUT", req.Method == "DELETE", req.Method == "POST":
+			return errNotImplemented
+		}
+		return errMethodNotAllowed
+	}
+
+	// Object operations.
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	uploadID := query.Get("uploadId")
+	switch {
+	case req.Method == "HEAD" && len(query) == 0:
+		return g.headObject(w, bucket, key)
+	case req.Method == "GET" && len(query) == 0:
+		return g.getObject(w, req, bucket, key)
+	case req.Method == "PUT" && uploadID != "":
+		return g.uploadPart(w, req, uploadID, query.Get("partNumber"))
+	case req.Method == "PUT" && req.Header.Get("X-Amz-Copy-Source") != "":
+		return errNotImplemented
+	case req.Method == "PUT" && len(query) == 0:
+		return g.putObject(w, req, bucket, key)
+	case req.Method == "POST" && hasParam(query, "uploads"):
+		return g.createMultipartUpload(w, bucket, key)
+	case req.Method == "POST" && uploadID != "":
+		return g.completeMultipartUpload(w, req, bucket, key, uploadID)
+	case req.Method == "DELETE" && uploadID != "":
+		return g.abortMultipartUpload(w, uploadID)
+	case req.Method == "DELETE" && len(query) == 0:
+		return g.deleteObject(w, bucket, key)
+	case req.Method == "GET", req.Method == "HEAD", req.Method == "PUT", req.Method == "DELETE", req.Method == "POST":
+		return errNotImplemented
+	}
+	return errMethodNotAllowed
+}
+
+// splitPath splits the path of a path-style request into the bucket and the
+// key of the object.
+func splitPath(urlPath string) (bucket, key string) {
+	urlPath = strings.TrimPrefix(urlPath, "/")
+	if i := strings.IndexByte(urlPath, '/'); i >= 0 {
+		return urlPath[:i], urlPath[i+1:]
+	}
+	return urlPath, ""
+}
+
+// hasParam returns whether the query contains the parameter, even if it has
+// no value.
+func hasParam(query map[string][]string, param string) bool {
+	_, exists := query[param]
+	return exists
+}
+
+// siaPath returns the siapath of the object with the key in the bucket.
+func siaPath(bucket, key string) string {
+	return bucket + "/" + key
+}
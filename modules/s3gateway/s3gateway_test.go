@@ -0,0 +1,529 @@
+package s3gateway
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// testRenter is an in-memory renter for testing the gateway.
+type testRenter struct {
+	dirs  map[string]struct{}
+	files map[string][]byte
+	mu    sync.Mutex
+}
+
+// newTestRenter returns an empty testRenter.
+func newTestRenter() *testRenter {
+	return &testRenter{
+		dirs:  map[string]struct{}{"": {}},
+		files: make(map[string][]byte),
+	}
+}
+
+// createDirs creates siaPath and its parent directories.
+func (r *testRenter) createDirs(siaPath string) {
+	for siaPath != "." && siaPath != "" {
+		r.dirs[siaPath] = struct{}{}
+		siaPath = path.Dir(siaPath)
+	}
+}
+
+func (r *testRenter) CreateDir(siaPath string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.dirs[siaPath]; exists {
+		return errors.New("dir exists")
+	}
+	r.createDirs(siaPath)
+	return nil
+}
+
+func (r *testRenter) DeleteDir(siaPath string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.dirs[siaPath]; !exists {
+		return errors.New("unknown dir")
+	}
+	for dir := range r.dirs {
+		if dir == siaPath || strings.HasPrefix(dir, siaPath+"/") {
+			delete(r.dirs, dir)
+		}
+	}
+	for name := range r.files {
+		if strings.HasPrefix(name, siaPath+"/") {
+			delete(r.files, name)
+		}
+	}
+	return nil
+}
+
+func (r *testRenter) DeleteFile(siaPath string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.files[siaPath]; !exists {
+		return errors.New("unknown file")
+	}
+	delete(r.files, siaPath)
+	return nil
+}
+
+func (r *testRenter) DirList(siaPath string) ([]modules.DirectoryInfo, []modules.FileInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.dirs[siaPath]; !exists {
+		return nil, nil, errors.New("unknown dir")
+	}
+	parent := func(name string) string {
+		if dir := path.Dir(name); dir != "." {
+			return dir
+		}
+		return ""
+	}
+	dirs := []modules.DirectoryInfo{{SiaPath: siaPath}}
+	for dir := range r.dirs {
+		if dir != "" && dir != siaPath && parent(dir) == siaPath {
+			dirs = append(dirs, modules.DirectoryInfo{SiaPath: dir})
+		}
+	}
+	sort.Slice(dirs[1:], func(i, j int) bool { return dirs[i+1].SiaPath < dirs[j+1].SiaPath })
+	var files []modules.FileInfo
+	for name, data := range r.files {
+		if parent(name) == siaPath {
+			files = append(files, modules.FileInfo{SiaPath: name, Filesize: uint64(len(data))})
+		}
+	}
+	return dirs, files, nil
+}
+
+func (r *testRenter) File(siaPath string) (modules.FileInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, exists := r.files[siaPath]
+	if !exists {
+		return modules.FileInfo{}, errors.New("unknown file")
+	}
+	return modules.FileInfo{SiaPath: siaPath, Filesize: uint64(len(data))}, nil
+}
+
+func (r *testRenter) RenameFile(currentName, newName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, exists := r.files[currentName]
+	if !exists {
+		return errors.New("unknown file")
+	} else if _, exists := r.files[newName]; exists {
+		return errors.New("file exists")
+	}
+	delete(r.files, currentName)
+	r.files[newName] = data
+	r.createDirs(path.Dir(newName))
+	return nil
+}
+
+func (r *testRenter) FileList() []modules.FileInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var files []modules.FileInfo
+	for name, data := range r.files {
+		files = append(files, modules.FileInfo{SiaPath: name, Filesize: uint64(len(data))})
+	}
+	return files
+}
+
+// testStreamer streams a file of the testRenter.
+type testStreamer struct {
+	*bytes.Reader
+}
+
+func (testStreamer) Close() error { return nil }
+
+func (r *testRenter) Streamer(siaPath string, prefetch uint64) (string, modules.Streamer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, exists := r.files[siaPath]
+	if !exists {
+		return "", nil, errors.New("unknown file")
+	}
+	return siaPath, testStreamer{bytes.NewReader(data)}, nil
+}
+
+// UploadStreamFromReader adds the file before reading the stream, like the
+// renter does, so that a failed upload leaves a partial file behind.
+func (r *testRenter) UploadStreamFromReader(up modules.FileUploadParams, reader io.Reader) error {
+	r.mu.Lock()
+	if _, exists := r.files[up.SiaPath]; exists {
+		r.mu.Unlock()
+		return errors.New("file exists")
+	}
+	r.files[up.SiaPath] = nil
+	r.createDirs(path.Dir(up.SiaPath))
+	r.mu.Unlock()
+
+	data, err := ioutil.ReadAll(reader)
+	r.mu.Lock()
+	r.files[up.SiaPath] = data
+	r.mu.Unlock()
+	return err
+}
+
+// md5Hex returns the hex-encoded MD5 hash of data.
+func md5Hex(data []byte) string {
+	h := md5.Sum(data)
+	return hex.EncodeToString(h[:])
+}
+
+// gatewayTester is a gateway with an in-memory renter, along with a client
+// that signs its requests.
+type gatewayTester struct {
+	gateway *S3Gateway
+	renter  *testRenter
+	key     modules.S3Key
+	t       *testing.T
+}
+
+// newGatewayTester creates a gatewayTester with a fresh persist directory.
+func newGatewayTester(t *testing.T) *gatewayTester {
+	r := newTestRenter()
+	g, err := New(r, "localhost:0", build.TempDir("s3gateway", t.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &gatewayTester{
+		gateway: g,
+		renter:  r,
+		key:     g.Keys()[0],
+		t:       t,
+	}
+}
+
+// do sends a signed request to the gateway and returns the response and its
+// body.
+func (gt *gatewayTester) do(method, url string, body []byte, headers ...string) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, "http://"+gt.gateway.Address()+url, bytes.NewReader(body))
+	if err != nil {
+		gt.t.Fatal(err)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	signRequest(req, gt.key.AccessKeyID, gt.key.SecretAccessKey, time.Now(), false)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		gt.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		gt.t.Fatal(err)
+	}
+	return resp, data
+}
+
+// expect sends a signed request and checks the status code of the response.
+// It returns the body of the response.
+func (gt *gatewayTester) expect(status int, method, url string, body []byte, headers ...string) []byte {
+	resp, data := gt.do(method, url, body, headers...)
+	if resp.StatusCode != status {
+		gt.t.Fatalf("%v %v: expected status %v, got %v: %s", method, url, status, resp.StatusCode, data)
+	}
+	return data
+}
+
+// expectError sends a signed request and checks that it fails with the
+// apiError.
+func (gt *gatewayTester) expectError(ae apiError, method, url string, body []byte, headers ...string) {
+	data := gt.expect(ae.Status, method, url, body, headers...)
+	if method == "HEAD" {
+		return
+	}
+	var resp errorResponse
+	if err := xml.Unmarshal(data, &resp); err != nil {
+		gt.t.Fatal(err)
+	} else if resp.Code != ae.Code {
+		gt.t.Fatalf("%v %v: expected error %v, got %v", method, url, ae.Code, resp.Code)
+	}
+}
+
+// TestBucketsAndObjects tests creating buckets and uploading, downloading and
+// deleting objects.
+func TestBucketsAndObjects(t *testing.T) {
+	gt := newGatewayTester(t)
+	defer gt.gateway.Close()
+
+	// Requests that aren't signed are rejected.
+	resp, err := http.Get("http://" + gt.gateway.Address() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatal("expected unsigned request to be rejected, got", resp.Status)
+	}
+
+	// Create a bucket.
+	gt.expectError(errInvalidBucketName, "PUT", "/Bucket", nil)
+	gt.expectError(errNoSuchBucket, "PUT", "/bucket/object", []byte("foo"))
+	gt.expect(http.StatusOK, "PUT", "/bucket", nil)
+	gt.expectError(errBucketAlreadyOwnedByYou, "PUT", "/bucket", nil)
+	gt.expect(http.StatusOK, "HEAD", "/bucket", nil)
+	gt.expectError(errNoSuchBucket, "HEAD", "/other", nil)
+	var buckets listAllMyBucketsResult
+	if err := xml.Unmarshal(gt.expect(http.StatusOK, "GET", "/", nil), &buckets); err != nil {
+		t.Fatal(err)
+	} else if len(buckets.Buckets) != 1 || buckets.Buckets[0].Name != "bucket" {
+		t.Fatal("unexpected buckets:", buckets.Buckets)
+	}
+
+	// Upload an object and download it again.
+	data := fastrand.Bytes(1000)
+	resp, _ = gt.do("PUT", "/bucket/dir/object.bin", data)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("upload failed:", resp.Status)
+	} else if etag := resp.Header.Get("ETag"); etag != `"`+md5Hex(data)+`"` {
+		t.Fatal("wrong ETag:", etag)
+	}
+	if _, err := gt.renter.File("bucket/dir/object.bin"); err != nil {
+		t.Fatal("object wasn't uploaded to its siapath:", err)
+	}
+	if downloaded := gt.expect(http.StatusOK, "GET", "/bucket/dir/object.bin", nil); !bytes.Equal(downloaded, data) {
+		t.Fatal("downloaded data doesn't match")
+	}
+	if downloaded := gt.expect(http.StatusPartialContent, "GET", "/bucket/dir/object.bin", nil, "Range", "bytes=100-199"); !bytes.Equal(downloaded, data[100:200]) {
+		t.Fatal("downloaded range doesn't match")
+	}
+	resp, _ = gt.do("HEAD", "/bucket/dir/object.bin", nil)
+	if resp.StatusCode != http.StatusOK || resp.ContentLength != int64(len(data)) {
+		t.Fatal("unexpected HEAD response:", resp.Status, resp.ContentLength)
+	}
+	gt.expectError(errNoSuchKey, "GET", "/bucket/missing", nil)
+	gt.expectError(errInvalidKey, "PUT", "/bucket/dir//object", data)
+
+	// Uploading an object again replaces it.
+	data = fastrand.Bytes(500)
+	gt.expect(http.StatusOK, "PUT", "/bucket/dir/object.bin", data)
+	if downloaded := gt.expect(http.StatusOK, "GET", "/bucket/dir/object.bin", nil); !bytes.Equal(downloaded, data) {
+		t.Fatal("object wasn't replaced")
+	}
+
+	// Uploads with a wrong Content-MD5 are rejected and don't leave a file
+	// behind, nor do they replace the existing object.
+	gt.expectError(errBadDigest, "PUT", "/bucket/bad", data, "Content-MD5", "1B2M2Y8AsgTpgAEwAAAAAA==")
+	if _, err := gt.renter.File("bucket/bad"); err == nil {
+		t.Fatal("object with a bad digest was kept")
+	}
+	gt.expectError(errBadDigest, "PUT", "/bucket/dir/object.bin", fastrand.Bytes(500), "Content-MD5", "1B2M2Y8AsgTpgAEwAAAAAA==")
+	if downloaded := gt.expect(http.StatusOK, "GET", "/bucket/dir/object.bin", nil); !bytes.Equal(downloaded, data) {
+		t.Fatal("object was replaced by an upload with a bad digest")
+	}
+	if _, files, _ := gt.renter.DirList(tmpDir); len(files) != 0 {
+		t.Fatal("temporary files were left behind:", files)
+	}
+	buckets = listAllMyBucketsResult{}
+	if err := xml.Unmarshal(gt.expect(http.StatusOK, "GET", "/", nil), &buckets); err != nil {
+		t.Fatal(err)
+	} else if len(buckets.Buckets) != 1 {
+		t.Fatal("the directory of temporary files is listed as a bucket:", buckets.Buckets)
+	}
+
+	// A bucket can only be deleted once it's empty.
+	gt.expectError(errBucketNotEmpty, "DELETE", "/bucket", nil)
+	gt.expect(http.StatusNoContent, "DELETE", "/bucket/dir/object.bin", nil)
+	gt.expect(http.StatusNoContent, "DELETE", "/bucket/dir/object.bin", nil)
+	gt.expectError(errNoSuchKey, "HEAD", "/bucket/dir/object.bin", nil)
+	gt.expect(http.StatusNoContent, "DELETE", "/bucket", nil)
+	gt.expectError(errNoSuchBucket, "HEAD", "/bucket", nil)
+}
+
+// TestListObjectsV2 tests listing the objects of a bucket.
+func TestListObjectsV2(t *testing.T) {
+	gt := newGatewayTester(t)
+	defer gt.gateway.Close()
+
+	gt.expect(http.StatusOK, "PUT", "/bucket", nil)
+	keys := []string{"a", "b/1", "b/2", "b/3", "c/1", "d"}
+	for _, key := range keys {
+		gt.expect(http.StatusOK, "PUT", "/bucket/"+key, []byte(key))
+	}
+
+	list := func(query string) listBucketResult {
+		var result listBucketResult
+		if err := xml.Unmarshal(gt.expect(http.StatusOK, "GET", "/bucket?list-type=2"+query, nil), &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	names := func(result listBucketResult) (names []string) {
+		for _, obj := range result.Contents {
+			names = append(names, obj.Key)
+		}
+		for _, cp := range result.CommonPrefixes {
+			names = append(names, cp.Prefix+"*")
+		}
+		return names
+	}
+
+	tests := []struct {
+		query string
+		names string
+	}{
+		{"", "a b/1 b/2 b/3 c/1 d"},
+		{"&prefix=b/", "b/1 b/2 b/3"},
+		{"&delimiter=/", "a d b/* c/*"},
+		{"&start-after=b/2", "b/3 c/1 d"},
+		{"&prefix=b/&delimiter=/", "b/1 b/2 b/3"},
+	}
+	for _, test := range tests {
+		if got := strings.Join(names(list(test.query)), " "); got != test.names {
+			t.Errorf("%q: expected %q, got %q", test.query, test.names, got)
+		}
+	}
+
+	// Page through the keys with a delimiter.
+	var pages []string
+	token := ""
+	for {
+		result := list("&delimiter=/&max-keys=2" + token)
+		pages = append(pages, strings.Join(names(result), " "))
+		if !result.IsTruncated {
+			break
+		}
+		token = "&continuation-token=" + result.NextContinuationToken
+	}
+	if got := strings.Join(pages, "|"); got != "a b/*|d c/*" {
+		t.Fatal("unexpected pages:", got)
+	}
+
+	gt.expectError(errNoSuchBucket, "GET", "/other?list-type=2", nil)
+	gt.expectError(errInvalidMaxKeys, "GET", "/bucket?list-type=2&max-keys=-1", nil)
+}
+
+// TestMultipartUpload tests uploading an object in parts.
+func TestMultipartUpload(t *testing.T) {
+	gt := newGatewayTester(t)
+	defer gt.gateway.Close()
+	gt.expect(http.StatusOK, "PUT", "/bucket", nil)
+
+	var initiated initiateMultipartUploadResult
+	if err := xml.Unmarshal(gt.expect(http.StatusOK, "POST", "/bucket/object?uploads", nil), &initiated); err != nil {
+		t.Fatal(err)
+	}
+	id := initiated.UploadID
+	if id == "" {
+		t.Fatal("no upload ID returned")
+	}
+
+	// Upload three parts out of order, replacing the second one.
+	parts := [][]byte{fastrand.Bytes(300), fastrand.Bytes(200), fastrand.Bytes(100)}
+	etags := make([]string, len(parts))
+	for _, i := range []int{2, 1, 0} {
+		resp, _ := gt.do("PUT", "/bucket/object?partNumber="+strconv.Itoa(i+1)+"&uploadId="+id, parts[i])
+		etags[i] = resp.Header.Get("ETag")
+	}
+	parts[1] = fastrand.Bytes(250)
+	resp, _ := gt.do("PUT", "/bucket/object?partNumber=2&uploadId="+id, parts[1])
+	etags[1] = resp.Header.Get("ETag")
+	gt.expectError(errInvalidPartNumber, "PUT", "/bucket/object?partNumber=0&uploadId="+id, nil)
+	gt.expectError(errNoSuchUpload, "PUT", "/bucket/object?partNumber=1&uploadId=unknown", nil)
+
+	complete := func(order ...int) []byte {
+		var cmu strings.Builder
+		cmu.WriteString("<CompleteMultipartUpload>")
+		for _, i := range order {
+			cmu.WriteString("<Part><PartNumber>" + strconv.Itoa(i+1) + "</PartNumber><ETag>" + etags[i] + "</ETag></Part>")
+		}
+		cmu.WriteString("</CompleteMultipartUpload>")
+		return []byte(cmu.String())
+	}
+	gt.expectError(errInvalidPartOrder, "POST", "/bucket/object?uploadId="+id, complete(1, 0))
+	gt.expectError(errMalformedXML, "POST", "/bucket/object?uploadId="+id, []byte("<Complete"))
+	gt.expectError(errNoSuchUpload, "POST", "/bucket/other?uploadId="+id, complete(0, 1, 2))
+
+	// Complete the upload without the third part.
+	var completed completeMultipartUploadResult
+	if err := xml.Unmarshal(gt.expect(http.StatusOK, "POST", "/bucket/object?uploadId="+id, complete(0, 1)), &completed); err != nil {
+		t.Fatal(err)
+	} else if !strings.HasSuffix(completed.ETag, `-2"`) {
+		t.Fatal("unexpected ETag:", completed.ETag)
+	}
+	expected := append(append([]byte(nil), parts[0]...), parts[1]...)
+	if downloaded := gt.expect(http.StatusOK, "GET", "/bucket/object", nil); !bytes.Equal(downloaded, expected) {
+		t.Fatal("downloaded data doesn't match")
+	}
+	gt.expectError(errNoSuchUpload, "POST", "/bucket/object?uploadId="+id, complete(0, 1))
+	if _, err := os.Stat(filepath.Join(gt.gateway.persistDir, uploadsDir, id)); !os.IsNotExist(err) {
+		t.Fatal("parts weren't removed:", err)
+	}
+
+	// Abort an upload.
+	if err := xml.Unmarshal(gt.expect(http.StatusOK, "POST", "/bucket/object2?uploads", nil), &initiated); err != nil {
+		t.Fatal(err)
+	}
+	gt.expect(http.StatusOK, "PUT", "/bucket/object2?partNumber=1&uploadId="+initiated.UploadID, parts[0])
+	gt.expect(http.StatusNoContent, "DELETE", "/bucket/object2?uploadId="+initiated.UploadID, nil)
+	gt.expectError(errNoSuchUpload, "DELETE", "/bucket/object2?uploadId="+initiated.UploadID, nil)
+	if _, err := gt.renter.File("bucket/object2"); err == nil {
+		t.Fatal("aborted upload created an object")
+	}
+}
+
+// TestKeys tests managing the access keys of the gateway.
+func TestKeys(t *testing.T) {
+	gt := newGatewayTester(t)
+	g := gt.gateway
+	if len(g.Keys()) != 1 {
+		t.Fatal("expected one generated key, got", len(g.Keys()))
+	}
+	if err := g.RemoveKey(gt.key.AccessKeyID); err != errLastKey {
+		t.Fatal("expected errLastKey, got", err)
+	}
+
+	// Switch to a new key.
+	key, err := g.AddKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key.AccessKeyID) != accessKeyIDLen || !strings.HasPrefix(key.AccessKeyID, accessKeyIDPrefix) {
+		t.Fatal("unexpected access key ID:", key.AccessKeyID)
+	}
+	if err := g.RemoveKey(gt.key.AccessKeyID); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.RemoveKey(gt.key.AccessKeyID); err != errUnknownKey {
+		t.Fatal("expected errUnknownKey, got", err)
+	}
+	gt.expectError(errInvalidAccessKeyID, "GET", "/", nil)
+	gt.key = key
+	gt.expect(http.StatusOK, "GET", "/", nil)
+
+	// The keys should persist.
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	g, err = New(gt.renter, "localhost:0", g.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if keys := g.Keys(); len(keys) != 1 || keys[0] != key {
+		t.Fatal("keys weren't persisted:", keys)
+	}
+}
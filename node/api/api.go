@@ -88,14 +88,15 @@ func HttpPOSTAuthenticated(url string, data string, password string) (resp *http
 // API encapsulates a collection of modules and implements a http.Handler
 // to access their methods.
 type API struct {
-	cs       modules.ConsensusSet
-	explorer modules.Explorer
-	gateway  modules.Gateway
-	host     modules.Host
-	miner    modules.Miner
-	renter   modules.Renter
-	tpool    modules.TransactionPool
-	wallet   modules.Wallet
+	cs        modules.ConsensusSet
+	explorer  modules.Explorer
+	gateway   modules.Gateway
+	host      modules.Host
+	miner     modules.Miner
+	renter    modules.Renter
+	s3gateway modules.S3Gateway
+	tpool     modules.TransactionPool
+	wallet    modules.Wallet
 
 	router http.Handler
 }
@@ -108,16 +109,17 @@ func (api *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // New creates a new Sia API from the provided modules.  The API will require
 // authentication using HTTP basic auth for certain endpoints of the supplied
 // password is not the empty string.  Usernames are ignored for authentication.
-func New(requiredUserAgent string, requiredPassword string, cs modules.ConsensusSet, e modules.Explorer, g modules.Gateway, h modules.Host, m modules.Miner, r modules.Renter, tp modules.TransactionPool, w modules.Wallet) *API {
+func New(requiredUserAgent string, requiredPassword string, cs modules.ConsensusSet, e modules.Explorer, g modules.Gateway, h modules.Host, m modules.Miner, r modules.Renter, s3 modules.S3Gateway, tp modules.TransactionPool, w modules.Wallet) *API {
 	api := &API{
-		cs:       cs,
-		explorer: e,
-		gateway:  g,
-		host:     h,
-		miner:    m,
-		renter:   r,
-		tpool:    tp,
-		wallet:   w,
+		cs:        cs,
+		explorer:  e,
+		gateway:   g,
+		host:      h,
+		miner:     m,
+		renter:    r,
+		s3gateway: s3,
+		tpool:     tp,
+		wallet:    w,
 	}
 
 	// Register API handlers
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"strings"
 
@@ -131,6 +133,60 @@ func (c *Client) getRawPartialResponse(resource string, from, to uint64) ([]byte
 	return ioutil.ReadAll(res.Body)
 }
 
+// getRawMultipartResponse requests several parts of the specified resource
+// at once. The parts of the multipart response are returned in the order of
+// the ranges, which are inclusive.
+func (c *Client) getRawMultipartResponse(resource string, ranges [][2]uint64) ([][]byte, error) {
+	req, err := c.NewRequest("GET", resource, nil)
+	if err != nil {
+		return nil, err
+	}
+	specs := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		specs = append(specs, fmt.Sprintf("%d-%d", r[0], r[1]))
+	}
+	req.Header.Add("Range", "bytes="+strings.Join(specs, ","))
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.AddContext(err, "request failed")
+	}
+	defer drainAndClose(res.Body)
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, errors.New("API call not recognized: " + resource)
+	}
+
+	// If the status code is not 2xx, decode and return the accompanying
+	// api.Error.
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, readAPIError(res.Body)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, errors.AddContext(err, "could not read content type")
+	} else if mediaType != "multipart/byteranges" {
+		return nil, errors.New("response is not a multipart response: " + mediaType)
+	}
+	var parts [][]byte
+	mr := multipart.NewReader(res.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.AddContext(err, "could not read part")
+		}
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			return nil, errors.AddContext(err, "could not read part")
+		}
+		parts = append(parts, data)
+	}
+	return parts, nil
+}
+
 // get requests the specified resource. The response, if provided, will be
 // decoded into obj. The resource path must begin with /.
 func (c *Client) get(resource string, obj interface{}) error {
@@ -156,12 +212,19 @@ func (c *Client) get(resource string, obj interface{}) error {
 // postRawResponse requests the specified resource. The response, if provided,
 // will be returned in a byte slice
 func (c *Client) postRawResponse(resource string, data string) ([]byte, error) {
-	req, err := c.NewRequest("POST", resource, strings.NewReader(data))
+	// TODO: is the content type necessary?
+	return c.postRawResponseReader(resource, strings.NewReader(data), "application/x-www-form-urlencoded")
+}
+
+// postRawResponseReader requests the specified resource, sending the data read
+// from body as the request body. The response, if provided, will be returned
+// in a byte slice
+func (c *Client) postRawResponseReader(resource string, body io.Reader, contentType string) ([]byte, error) {
+	req, err := c.NewRequest("POST", resource, body)
 	if err != nil {
 		return nil, err
 	}
-	// TODO: is this necessary?
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Content-Type", contentType)
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, errors.AddContext(err, "request failed")
@@ -8,6 +8,7 @@ import (
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/node/api"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 // HostParam is a parameter in the host's settings that can be changed via the
@@ -47,6 +48,30 @@ const (
 	HostParamMaxReviseBatchSize = HostParam("maxrevisebatchsize")
 	// HostParamNetAddress is the announced netaddress of the host.
 	HostParamNetAddress = HostParam("netaddress")
+	// HostParamMaxDownloadSpeed is the max speed at which the host receives
+	// data in bytes per second.
+	HostParamMaxDownloadSpeed = HostParam("maxdownloadspeed")
+	// HostParamMaxUploadSpeed is the max speed at which the host sends data in
+	// bytes per second.
+	HostParamMaxUploadSpeed = HostParam("maxuploadspeed")
+	// HostParamRenterQuota is the default bandwidth quota of the renters in
+	// bytes per quota period.
+	HostParamRenterQuota = HostParam("renterquota")
+	// HostParamRenterQuotas is a comma-separated list of 'publickey=bytes'
+	// quotas of individual renters.
+	HostParamRenterQuotas = HostParam("renterquotas")
+	// HostParamDynamicPricing indicates if the host adjusts its prices
+	// automatically.
+	HostParamDynamicPricing = HostParam("dynamicpricing")
+	// HostParamMaxDownloadBandwidthPrice is the max download bandwidth price
+	// of the dynamic pricing in hastings/byte.
+	HostParamMaxDownloadBandwidthPrice = HostParam("maxdownloadbandwidthprice")
+	// HostParamMaxStoragePrice is the max storage price of the dynamic
+	// pricing in hastings/byte/block.
+	HostParamMaxStoragePrice = HostParam("maxstorageprice")
+	// HostParamMaxUploadBandwidthPrice is the max upload bandwidth price of
+	// the dynamic pricing in hastings/byte.
+	HostParamMaxUploadBandwidthPrice = HostParam("maxuploadbandwidthprice")
 )
 
 // HostAnnouncePost uses the /host/announce endpoint to announce the host to
@@ -70,6 +95,34 @@ func (c *Client) HostContractInfoGet() (cg api.ContractInfoGET, err error) {
 	return
 }
 
+// HostAlertsGet requests the /host/alerts endpoint.
+func (c *Client) HostAlertsGet() (hag api.HostAlertsGET, err error) {
+	err = c.get("/host/alerts", &hag)
+	return
+}
+
+// HostContractInfoQueryGet uses the /host/contracts endpoint to get a page of
+// the contracts on the host. The values may contain the filters, the sort
+// order and the page, see doc/API.md.
+func (c *Client) HostContractInfoQueryGet(values url.Values) (cg api.ContractInfoGET, err error) {
+	err = c.get("/host/contracts?"+values.Encode(), &cg)
+	return
+}
+
+// HostContractGet uses the /host/contracts/:id endpoint to get the details of
+// a contract on the host.
+func (c *Client) HostContractGet(id types.FileContractID) (hcg api.HostContractGET, err error) {
+	err = c.get("/host/contracts/"+id.String(), &hcg)
+	return
+}
+
+// HostContractRevisionsGet uses the /host/contracts/:id endpoint to get the
+// details of a contract on the host with a page of its revisions.
+func (c *Client) HostContractRevisionsGet(id types.FileContractID, offset, limit int) (hcg api.HostContractGET, err error) {
+	err = c.get(fmt.Sprintf("/host/contracts/%v?offset=%v&limit=%v", id, offset, limit), &hcg)
+	return
+}
+
 // HostEstimateScoreGet requests the /host/estimatescore endpoint.
 func (c *Client) HostEstimateScoreGet(param, value string) (eg api.HostEstimateScoreGET, err error) {
 	err = c.get(fmt.Sprintf("/host/estimatescore?%v=%v", param, value), &eg)
@@ -82,6 +135,24 @@ func (c *Client) HostGet() (hg api.HostGET, err error) {
 	return
 }
 
+// HostMetricsHistoryGet uses the /host/metrics/history endpoint to get the
+// financial metrics of the host between two heights, split into buckets. The
+// bucket is "block", "day", "week" or a number of blocks.
+func (c *Client) HostMetricsHistoryGet(from, to types.BlockHeight, bucket string) (hmhg api.HostMetricsHistoryGET, err error) {
+	values := url.Values{}
+	values.Set("from", fmt.Sprint(from))
+	values.Set("to", fmt.Sprint(to))
+	values.Set("bucket", bucket)
+	err = c.get("/host/metrics/history?"+values.Encode(), &hmhg)
+	return
+}
+
+// HostPricingGet requests the /host/pricing endpoint.
+func (c *Client) HostPricingGet() (hpg api.HostPricingGET, err error) {
+	err = c.get("/host/pricing", &hpg)
+	return
+}
+
 // HostModifySettingPost uses the /host endpoint to change a param of the host
 // settings to a certain value.
 func (c *Client) HostModifySettingPost(param HostParam, value interface{}) (err error) {
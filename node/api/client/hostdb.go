@@ -1,6 +1,10 @@
 package client
 
 import (
+	"net/url"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/node/api"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -22,3 +26,23 @@ func (c *Client) HostDbHostsGet(pk types.SiaPublicKey) (hhg api.HostdbHostsGET,
 	err = c.get("/hostdb/hosts/"+pk.String(), &hhg)
 	return
 }
+
+// HostDbFilterModeGet requests the /hostdb/filtermode endpoint's resources.
+func (c *Client) HostDbFilterModeGet() (hdfg api.HostdbFilterModeGET, err error) {
+	err = c.get("/hostdb/filtermode", &hdfg)
+	return
+}
+
+// HostDbFilterModePost uses the /hostdb/filtermode endpoint to set the filter
+// mode of the hostdb and the hosts it applies to.
+func (c *Client) HostDbFilterModePost(fm modules.FilterMode, hosts []types.SiaPublicKey) (err error) {
+	keys := make([]string, 0, len(hosts))
+	for _, spk := range hosts {
+		keys = append(keys, spk.String())
+	}
+	values := url.Values{}
+	values.Set("filtermode", fm.String())
+	values.Set("hosts", strings.Join(keys, ","))
+	err = c.post("/hostdb/filtermode", values.Encode(), nil)
+	return
+}
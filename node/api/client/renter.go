@@ -2,14 +2,33 @@ package client
 
 import (
 	"fmt"
+	"io"
 	"net/url"
 	"strconv"
 	"strings"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/node/api"
+	"github.com/NebulousLabs/Sia/types"
 )
 
+// RenterBackupPost uses the /renter/backup endpoint to write a backup of the
+// renter to destination.
+func (c *Client) RenterBackupPost(destination string) (err error) {
+	values := url.Values{}
+	values.Set("destination", destination)
+	err = c.post("/renter/backup", values.Encode(), nil)
+	return
+}
+
+// RenterBackupRemotePost uses the /renter/backup endpoint to upload a backup of
+// the renter to its hosts.
+func (c *Client) RenterBackupRemotePost() (err error) {
+	err = c.post("/renter/backup", "remote=true", nil)
+	return
+}
+
 // RenterContractsGet requests the /renter/contracts resource
 func (c *Client) RenterContractsGet() (rc api.RenterContracts, err error) {
 	err = c.get("/renter/contracts", &rc)
@@ -23,6 +42,41 @@ func (c *Client) RenterDeletePost(siaPath string) (err error) {
 	return err
 }
 
+// RenterDirGet uses the /renter/dir/:siapath endpoint to list a directory.
+// The root directory is listed if siaPath is empty.
+func (c *Client) RenterDirGet(siaPath string) (rd api.RenterDirectory, err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	err = c.get("/renter/dir/"+siaPath, &rd)
+	return
+}
+
+// RenterDirCreatePost uses the /renter/dir/:siapath endpoint to create a
+// directory.
+func (c *Client) RenterDirCreatePost(siaPath string) (err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	err = c.post("/renter/dir/"+siaPath, "action=create", nil)
+	return
+}
+
+// RenterDirDeletePost uses the /renter/dir/:siapath endpoint to delete a
+// directory and everything it contains.
+func (c *Client) RenterDirDeletePost(siaPath string) (err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	err = c.post("/renter/dir/"+siaPath, "action=delete", nil)
+	return
+}
+
+// RenterDirRenamePost uses the /renter/dir/:siapath endpoint to rename a
+// directory.
+func (c *Client) RenterDirRenamePost(siaPath, newSiaPath string) (err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	values := url.Values{}
+	values.Set("action", "rename")
+	values.Set("newsiapath", strings.TrimPrefix(newSiaPath, "/"))
+	err = c.post("/renter/dir/"+siaPath, values.Encode(), nil)
+	return
+}
+
 // RenterDownloadGet uses the /renter/download endpoint to download a file to a
 // destination on disk.
 func (c *Client) RenterDownloadGet(siaPath, destination string, offset, length uint64, async bool) (err error) {
@@ -43,6 +97,34 @@ func (c *Client) RenterDownloadFullGet(siaPath, destination string, async bool)
 	return
 }
 
+// RenterDownloadAsyncGet uses the /renter/download endpoint to start a
+// download with the given priority in the background. The ID of the download
+// is returned.
+func (c *Client) RenterDownloadAsyncGet(siaPath, destination string, offset, length uint64, priority modules.Priority) (rda api.RenterDownloadAsync, err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	query := fmt.Sprintf("%s?destination=%s&offset=%d&length=%d&httpresp=false&async=true&priority=%v",
+		siaPath, destination, offset, length, priority)
+	err = c.get("/renter/download/"+query, &rda)
+	return
+}
+
+// RenterDownloadPriorityGet uses the /renter/download endpoint to download a
+// full file with the given priority.
+func (c *Client) RenterDownloadPriorityGet(siaPath, destination string, priority modules.Priority) (err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	query := fmt.Sprintf("%s?destination=%s&httpresp=false&priority=%v",
+		siaPath, destination, priority)
+	err = c.get("/renter/download/"+query, nil)
+	return
+}
+
+// RenterDownloadCancelPost uses the /renter/downloads/:id/cancel endpoint to
+// cancel a download.
+func (c *Client) RenterDownloadCancelPost(id string) (err error) {
+	err = c.post(fmt.Sprintf("/renter/downloads/%v/cancel", id), "", nil)
+	return
+}
+
 // RenterDownloadsGet requests the /renter/downloads resource
 func (c *Client) RenterDownloadsGet() (rdq api.RenterDownloadQueue, err error) {
 	err = c.get("/renter/downloads", &rdq)
@@ -71,12 +153,51 @@ func (c *Client) RenterFilesGet() (rf api.RenterFiles, err error) {
 	return
 }
 
+// RenterLoadTicketPost uses the /renter/loadticket endpoint to load the files
+// of a ticket share.
+func (c *Client) RenterLoadTicketPost(share string) (rl api.RenterLoad, err error) {
+	values := url.Values{}
+	values.Set("share", share)
+	err = c.post("/renter/loadticket", values.Encode(), &rl)
+	return
+}
+
 // RenterGet requests the /renter resource.
 func (c *Client) RenterGet() (rg api.RenterGET, err error) {
 	err = c.get("/renter", &rg)
 	return
 }
 
+// RenterPacksGet requests the /renter/packs resource.
+func (c *Client) RenterPacksGet() (rp api.RenterPacks, err error) {
+	err = c.get("/renter/packs", &rp)
+	return
+}
+
+// RenterShareTicketPost uses the /renter/shareticket endpoint to share the
+// files at siaPaths with other renters through download tickets that are
+// valid for duration blocks.
+func (c *Client) RenterShareTicketPost(siaPaths []string, duration types.BlockHeight) (rts api.RenterTicketShare, err error) {
+	values := url.Values{}
+	values.Set("siapaths", strings.Join(siaPaths, ","))
+	values.Set("duration", fmt.Sprint(duration))
+	err = c.post("/renter/shareticket", values.Encode(), &rts)
+	return
+}
+
+// RenterSpendingGet requests the /renter/spending resource. Only the entries
+// of files whose siapath starts with prefix and of periods that started
+// between since and until are returned. An until of 0 returns all periods
+// since since.
+func (c *Client) RenterSpendingGet(prefix string, since, until types.BlockHeight) (rs api.RenterSpending, err error) {
+	values := url.Values{}
+	values.Set("prefix", prefix)
+	values.Set("since", fmt.Sprint(since))
+	values.Set("until", fmt.Sprint(until))
+	err = c.get("/renter/spending?"+values.Encode(), &rs)
+	return
+}
+
 // RenterPostAllowance uses the /renter endpoint to change the renter's allowance
 func (c *Client) RenterPostAllowance(allowance modules.Allowance) (err error) {
 	values := url.Values{}
@@ -84,6 +205,13 @@ func (c *Client) RenterPostAllowance(allowance modules.Allowance) (err error) {
 	values.Set("hosts", strconv.FormatUint(allowance.Hosts, 10))
 	values.Set("period", strconv.FormatUint(uint64(allowance.Period), 10))
 	values.Set("renewwindow", strconv.FormatUint(uint64(allowance.RenewWindow), 10))
+	values.Set("expectedstorage", strconv.FormatUint(allowance.ExpectedStorage, 10))
+	values.Set("expectedupload", strconv.FormatUint(allowance.ExpectedUpload, 10))
+	values.Set("expecteddownload", strconv.FormatUint(allowance.ExpectedDownload, 10))
+	values.Set("maxstoragespending", allowance.MaxStorageSpending.String())
+	values.Set("maxuploadspending", allowance.MaxUploadSpending.String())
+	values.Set("maxdownloadspending", allowance.MaxDownloadSpending.String())
+	values.Set("maxpricepertb", allowance.MaxPricePerTB.String())
 	err = c.post("/renter", values.Encode(), nil)
 	return
 }
@@ -118,6 +246,58 @@ func (c *Client) RenterRenamePost(siaPathOld, siaPathNew string) (err error) {
 	return
 }
 
+// RenterReencryptPost uses the /renter/reencrypt endpoint to re-encrypt a file
+// under a new key and the cipher ct. If ct is empty, the cipher of the file is
+// kept.
+func (c *Client) RenterReencryptPost(siaPath string, ct crypto.CipherType) (err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	values := url.Values{}
+	values.Set("cipher", string(ct))
+	err = c.post("/renter/reencrypt/"+siaPath, values.Encode(), nil)
+	return
+}
+
+// RenterReencryptionsGet requests the /renter/reencryptions resource.
+func (c *Client) RenterReencryptionsGet() (rr api.RenterReencryptions, err error) {
+	err = c.get("/renter/reencryptions", &rr)
+	return
+}
+
+// RenterRecoverPost uses the /renter/recover endpoint to recover the renter's
+// contracts and files from the wallet seed.
+func (c *Client) RenterRecoverPost() (rr api.RenterRestore, err error) {
+	err = c.post("/renter/recover", "", &rr)
+	return
+}
+
+// RenterRestorePost uses the /renter/restore endpoint to restore the backup
+// at source.
+func (c *Client) RenterRestorePost(source string) (rr api.RenterRestore, err error) {
+	values := url.Values{}
+	values.Set("source", source)
+	err = c.post("/renter/restore", values.Encode(), &rr)
+	return
+}
+
+// RenterSetChunkCacheSizePost uses the /renter endpoint to change the
+// capacity of the renter's on-disk chunk cache.
+func (c *Client) RenterSetChunkCacheSizePost(size uint64) (err error) {
+	values := url.Values{}
+	values.Set("chunkcachesize", strconv.FormatUint(size, 10))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSetDiversityPost uses the /renter endpoint to change the renter's
+// subnet diversity and the path of its GeoIP database.
+func (c *Client) RenterSetDiversityPost(subnetDiversity bool, geoIPDatabase string) (err error) {
+	values := url.Values{}
+	values.Set("subnetdiversity", strconv.FormatBool(subnetDiversity))
+	values.Set("geoipdatabase", geoIPDatabase)
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
 // RenterSetStreamCacheSizePost uses the /renter endpoint to change the renter's
 // streamCacheSize for streaming
 func (c *Client) RenterSetStreamCacheSizePost(cacheSize uint64) (err error) {
@@ -143,6 +323,80 @@ func (c *Client) RenterStreamPartialGet(siaPath string, start, end uint64) (resp
 	return
 }
 
+// RenterMountPost uses the /renter/mount endpoint to mount the directory
+// siaPath of the renter at mountPoint.
+func (c *Client) RenterMountPost(mountPoint, siaPath string) (err error) {
+	values := url.Values{}
+	values.Set("mountpoint", mountPoint)
+	values.Set("siapath", siaPath)
+	err = c.post("/renter/mount", values.Encode(), nil)
+	return
+}
+
+// RenterMountPrefetchPost uses the /renter/mount endpoint to mount the
+// directory siaPath of the renter at mountPoint. Reading a file of the mount
+// fetches prefetch chunks ahead.
+func (c *Client) RenterMountPrefetchPost(mountPoint, siaPath string, prefetch uint64) (err error) {
+	values := url.Values{}
+	values.Set("mountpoint", mountPoint)
+	values.Set("siapath", siaPath)
+	values.Set("prefetch", strconv.FormatUint(prefetch, 10))
+	err = c.post("/renter/mount", values.Encode(), nil)
+	return
+}
+
+// RenterMountOptionsPost uses the /renter/mount endpoint to mount the
+// directory opts.SiaPath of the renter at mountPoint with the provided
+// options.
+func (c *Client) RenterMountOptionsPost(mountPoint string, opts modules.MountOptions) (err error) {
+	values := url.Values{}
+	values.Set("mountpoint", mountPoint)
+	values.Set("siapath", opts.SiaPath)
+	values.Set("prefetch", strconv.FormatUint(opts.Prefetch, 10))
+	values.Set("cachesize", strconv.FormatUint(opts.CacheSize, 10))
+	err = c.post("/renter/mount", values.Encode(), nil)
+	return
+}
+
+// RenterMountsGet requests the /renter/mounts resource to list the mounted
+// filesystems.
+func (c *Client) RenterMountsGet() (rm api.RenterMounts, err error) {
+	err = c.get("/renter/mounts", &rm)
+	return
+}
+
+// RenterUnmountPost uses the /renter/unmount endpoint to unmount the
+// filesystem mounted at mountPoint.
+func (c *Client) RenterUnmountPost(mountPoint string) (err error) {
+	values := url.Values{}
+	values.Set("mountpoint", mountPoint)
+	err = c.post("/renter/unmount", values.Encode(), nil)
+	return
+}
+
+// RenterStreamPrefetchGet uses the /renter/stream endpoint to download data as
+// a stream that fetches prefetch chunks ahead.
+func (c *Client) RenterStreamPrefetchGet(siaPath string, prefetch uint64) (resp []byte, err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	resp, err = c.getRawResponse(fmt.Sprintf("/renter/stream/%v?prefetch=%v", siaPath, prefetch))
+	return
+}
+
+// RenterStreamMultipartGet uses the /renter/stream endpoint to download
+// several parts of a file at once. The ranges are inclusive.
+func (c *Client) RenterStreamMultipartGet(siaPath string, ranges [][2]uint64) (parts [][]byte, err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	parts, err = c.getRawMultipartResponse("/renter/stream/"+siaPath, ranges)
+	return
+}
+
+// RenterStreamsGet requests the /renter/streams resource to list the open
+// streams.
+func (c *Client) RenterStreamsGet() (rs api.RenterStreams, err error) {
+	err = c.get("/renter/streams", &rs)
+	return
+}
+
 // RenterUploadPost uses the /renter/upload endpoint to upload a file
 func (c *Client) RenterUploadPost(path, siaPath string, dataPieces, parityPieces uint64) (err error) {
 	siaPath = strings.TrimPrefix(siaPath, "/")
@@ -154,6 +408,133 @@ func (c *Client) RenterUploadPost(path, siaPath string, dataPieces, parityPieces
 	return
 }
 
+// RenterUploadErasureCoderPost uses the /renter/upload endpoint to upload a
+// file using the specified erasure coder. If both dataPieces and parityPieces
+// are 0, the default redundancy of the erasure coder is used.
+func (c *Client) RenterUploadErasureCoderPost(path, siaPath string, erasureCoder types.Specifier, dataPieces, parityPieces uint64) (err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	values := url.Values{}
+	values.Set("source", path)
+	values.Set("erasurecoder", erasureCoder.String())
+	if dataPieces != 0 || parityPieces != 0 {
+		values.Set("datapieces", strconv.FormatUint(dataPieces, 10))
+		values.Set("paritypieces", strconv.FormatUint(parityPieces, 10))
+	}
+	err = c.post(fmt.Sprintf("/renter/upload/%v", siaPath), values.Encode(), nil)
+	return
+}
+
+// RenterUploadDedupPost uses the /renter/upload endpoint to upload a file with
+// deduplication enabled. If erasureCoder is the zero value, the default erasure
+// coder is used. If both dataPieces and parityPieces are 0, the default
+// redundancy of the erasure coder is used.
+func (c *Client) RenterUploadDedupPost(path, siaPath string, erasureCoder types.Specifier, dataPieces, parityPieces uint64) (err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	values := url.Values{}
+	values.Set("source", path)
+	values.Set("dedup", "true")
+	if erasureCoder != (types.Specifier{}) {
+		values.Set("erasurecoder", erasureCoder.String())
+	}
+	if dataPieces != 0 || parityPieces != 0 {
+		values.Set("datapieces", strconv.FormatUint(dataPieces, 10))
+		values.Set("paritypieces", strconv.FormatUint(parityPieces, 10))
+	}
+	err = c.post(fmt.Sprintf("/renter/upload/%v", siaPath), values.Encode(), nil)
+	return
+}
+
+// RenterUploadPriorityPost uses the /renter/upload endpoint to upload a file
+// with the given priority and returns the ID of the upload. If erasureCoder is
+// the zero value, the default erasure coder is used. If both dataPieces and
+// parityPieces are 0, the default redundancy of the erasure coder is used.
+func (c *Client) RenterUploadPriorityPost(path, siaPath string, erasureCoder types.Specifier, dataPieces, parityPieces uint64, dedup bool, priority modules.Priority) (ru api.RenterUpload, err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	values := url.Values{}
+	values.Set("source", path)
+	values.Set("priority", priority.String())
+	values.Set("dedup", strconv.FormatBool(dedup))
+	if erasureCoder != (types.Specifier{}) {
+		values.Set("erasurecoder", erasureCoder.String())
+	}
+	if dataPieces != 0 || parityPieces != 0 {
+		values.Set("datapieces", strconv.FormatUint(dataPieces, 10))
+		values.Set("paritypieces", strconv.FormatUint(parityPieces, 10))
+	}
+	err = c.post(fmt.Sprintf("/renter/upload/%v", siaPath), values.Encode(), &ru)
+	return
+}
+
+// RenterUploadCipherPost uses the /renter/upload endpoint to upload a file that
+// is encrypted with the cipher ct and returns the ID of the upload. If
+// erasureCoder is the zero value, the default erasure coder is used. If both
+// dataPieces and parityPieces are 0, the default redundancy of the erasure
+// coder is used.
+func (c *Client) RenterUploadCipherPost(path, siaPath string, erasureCoder types.Specifier, dataPieces, parityPieces uint64, ct crypto.CipherType) (ru api.RenterUpload, err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	values := url.Values{}
+	values.Set("source", path)
+	values.Set("cipher", string(ct))
+	if erasureCoder != (types.Specifier{}) {
+		values.Set("erasurecoder", erasureCoder.String())
+	}
+	if dataPieces != 0 || parityPieces != 0 {
+		values.Set("datapieces", strconv.FormatUint(dataPieces, 10))
+		values.Set("paritypieces", strconv.FormatUint(parityPieces, 10))
+	}
+	err = c.post(fmt.Sprintf("/renter/upload/%v", siaPath), values.Encode(), &ru)
+	return
+}
+
+// RenterUploadPausePost uses the /renter/uploads/:id/pause endpoint to pause
+// an upload.
+func (c *Client) RenterUploadPausePost(id string) (err error) {
+	err = c.post(fmt.Sprintf("/renter/uploads/%v/pause", id), "", nil)
+	return
+}
+
+// RenterUploadResumePost uses the /renter/uploads/:id/resume endpoint to
+// resume a paused upload.
+func (c *Client) RenterUploadResumePost(id string) (err error) {
+	err = c.post(fmt.Sprintf("/renter/uploads/%v/resume", id), "", nil)
+	return
+}
+
+// RenterUploadCancelPost uses the /renter/uploads/:id/cancel endpoint to
+// cancel an upload and delete the file.
+func (c *Client) RenterUploadCancelPost(id string) (err error) {
+	err = c.post(fmt.Sprintf("/renter/uploads/%v/cancel", id), "", nil)
+	return
+}
+
+// RenterUploadStreamPost uses the /renter/uploadstream endpoint to upload the
+// data read from r using the default redundancy settings.
+func (c *Client) RenterUploadStreamPost(r io.Reader, siaPath string) (err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	_, err = c.postRawResponseReader(fmt.Sprintf("/renter/uploadstream/%v", siaPath), r, "application/octet-stream")
+	return
+}
+
+// RenterUploadStreamResumePost uses the /renter/uploadstream endpoint to
+// resume an interrupted stream upload. offset is the offset of the first byte
+// of r within the file.
+func (c *Client) RenterUploadStreamResumePost(r io.Reader, siaPath string, offset uint64) (err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	values := url.Values{}
+	values.Set("resume", "true")
+	values.Set("offset", strconv.FormatUint(offset, 10))
+	_, err = c.postRawResponseReader(fmt.Sprintf("/renter/uploadstream/%v?%v", siaPath, values.Encode()), r, "application/octet-stream")
+	return
+}
+
+// RenterUploadStreamGet requests the /renter/uploadstream resource to learn
+// the offset at which an interrupted stream upload can be resumed.
+func (c *Client) RenterUploadStreamGet(siaPath string) (rus api.RenterUploadStream, err error) {
+	siaPath = strings.TrimPrefix(siaPath, "/")
+	err = c.get(fmt.Sprintf("/renter/uploadstream/%v", siaPath), &rus)
+	return
+}
+
 // RenterUploadDefaultPost uses the /renter/upload endpoint with default
 // redundancy settings to upload a file.
 func (c *Client) RenterUploadDefaultPost(path, siaPath string) (err error) {
@@ -0,0 +1,30 @@
+package client
+
+import (
+	"net/url"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api"
+)
+
+// S3Get requests the /s3 endpoint's resources.
+func (c *Client) S3Get() (sg api.S3GET, err error) {
+	err = c.get("/s3", &sg)
+	return
+}
+
+// S3KeysAddPost uses the /s3/keys/add endpoint to generate a new access key
+// for the S3 gateway.
+func (c *Client) S3KeysAddPost() (key modules.S3Key, err error) {
+	err = c.post("/s3/keys/add", "", &key)
+	return
+}
+
+// S3KeysRemovePost uses the /s3/keys/remove endpoint to remove an access key
+// of the S3 gateway.
+func (c *Client) S3KeysRemovePost(accessKeyID string) (err error) {
+	values := url.Values{}
+	values.Set("accesskeyid", accessKeyID)
+	err = c.post("/s3/keys/remove", values.Encode(), nil)
+	return
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
@@ -21,6 +22,14 @@ var (
 	// storage folder which does not appear to exist within the storage
 	// manager.
 	errStorageFolderNotFound = errors.New("storage folder with the provided path could not be found")
+
+	// metricsBucketSizes maps the named bucket sizes of the financial metrics
+	// history to numbers of blocks.
+	metricsBucketSizes = map[string]types.BlockHeight{
+		"block": 1,
+		"day":   144,
+		"week":  1008,
+	}
 )
 
 type (
@@ -28,6 +37,20 @@ type (
 	// to /host/contracts - information for the host about stored obligations.
 	ContractInfoGET struct {
 		Contracts []modules.StorageObligation `json:"contracts"`
+		Total     int                         `json:"total"` // The number of matching obligations before paging.
+	}
+
+	// HostAlertsGET contains the information that is returned after a GET
+	// request to /host/alerts - the problems with the storage obligations of
+	// the host.
+	HostAlertsGET struct {
+		Alerts []modules.HostAlert `json:"alerts"`
+	}
+
+	// HostContractGET contains the information that is returned after a GET
+	// request to /host/contracts/:id - the details of a storage obligation.
+	HostContractGET struct {
+		Contract modules.StorageObligationDetails `json:"contract"`
 	}
 
 	// HostGET contains the information that is returned after a GET request to
@@ -48,6 +71,22 @@ type (
 		ConversionRate float64        `json:"conversionrate"`
 	}
 
+	// HostMetricsHistoryGET contains the information that is returned after
+	// a GET request to /host/metrics/history - the financial metrics of the
+	// host split into buckets of blocks.
+	HostMetricsHistoryGET struct {
+		BucketSize types.BlockHeight           `json:"bucketsize"`
+		Buckets    []modules.HostMetricsBucket `json:"buckets"`
+	}
+
+	// HostPricingGET contains the information that is returned after a GET
+	// request to /host/pricing - the state of the host's dynamic pricing and
+	// the log of its price changes.
+	HostPricingGET struct {
+		modules.HostPricing
+		Changes []modules.HostPriceChange `json:"changes"`
+	}
+
 	// StorageGET contains the information that is returned after a GET request
 	// to /host/storage - a bunch of information about the status of storage
 	// management on the host.
@@ -70,10 +109,92 @@ func folderIndex(folderPath string, storageFolders []modules.StorageFolderMetada
 // hostContractInfoHandler handles the API call to get the contract information of the host.
 // Information is retrieved via the storage obligations from the host database.
 func (api *API) hostContractInfoHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	cg := ContractInfoGET{
-		Contracts: api.host.StorageObligations(),
+	q, err := parseObligationQuery(req)
+	if err != nil {
+		WriteError(w, Error{"error parsing query: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	contracts, total, err := api.host.QueryStorageObligations(q)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if contracts == nil {
+		contracts = []modules.StorageObligation{}
+	}
+	WriteJSON(w, ContractInfoGET{
+		Contracts: contracts,
+		Total:     total,
+	})
+}
+
+// parseObligationQuery parses the filters, the sort order and the page of a
+// request to /host/contracts.
+func parseObligationQuery(req *http.Request) (q modules.StorageObligationQuery, err error) {
+	if status := req.FormValue("status"); status != "" {
+		q.Statuses = strings.Split(status, ",")
+	}
+	heights := []struct {
+		param  string
+		height *types.BlockHeight
+	}{
+		{"minexpiration", &q.MinExpirationHeight},
+		{"maxexpiration", &q.MaxExpirationHeight},
+		{"minnegotiation", &q.MinNegotiationHeight},
+		{"maxnegotiation", &q.MaxNegotiationHeight},
+	}
+	for _, h := range heights {
+		if req.FormValue(h.param) == "" {
+			continue
+		}
+		if _, err := fmt.Sscan(req.FormValue(h.param), h.height); err != nil {
+			return modules.StorageObligationQuery{}, fmt.Errorf("invalid %v: %v", h.param, err)
+		}
+	}
+	q.SortBy = req.FormValue("sort")
+	if q.Descending, err = scanBool(req.FormValue("desc")); err != nil {
+		return modules.StorageObligationQuery{}, fmt.Errorf("invalid desc: %v", err)
+	}
+	if req.FormValue("offset") != "" {
+		if _, err := fmt.Sscan(req.FormValue("offset"), &q.Offset); err != nil {
+			return modules.StorageObligationQuery{}, fmt.Errorf("invalid offset: %v", err)
+		}
+	}
+	if req.FormValue("limit") != "" {
+		if _, err := fmt.Sscan(req.FormValue("limit"), &q.Limit); err != nil {
+			return modules.StorageObligationQuery{}, fmt.Errorf("invalid limit: %v", err)
+		}
 	}
-	WriteJSON(w, cg)
+	return q, nil
+}
+
+// hostContractHandlerGET handles the API call to get the details of a storage
+// obligation of the host, including a page of its revisions.
+func (api *API) hostContractHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	h, err := scanHash(ps.ByName("id"))
+	if err != nil {
+		WriteError(w, Error{"invalid contract id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var offset, limit int
+	if req.FormValue("offset") != "" {
+		if _, err := fmt.Sscan(req.FormValue("offset"), &offset); err != nil {
+			WriteError(w, Error{"invalid offset: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FormValue("limit") != "" {
+		if _, err := fmt.Sscan(req.FormValue("limit"), &limit); err != nil {
+			WriteError(w, Error{"invalid limit: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	details, err := api.host.StorageObligation(types.FileContractID(h), offset, limit)
+	if err != nil {
+		WriteError(w, Error{"unable to get the contract: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, HostContractGET{Contract: details})
 }
 
 // hostHandlerGET handles GET requests to the /host API endpoint, returning key
@@ -209,9 +330,102 @@ func (api *API) parseHostSettings(req *http.Request) (modules.HostInternalSettin
 		settings.MinUploadBandwidthPrice = x
 	}
 
+	if req.FormValue("dynamicpricing") != "" {
+		var x bool
+		_, err := fmt.Sscan(req.FormValue("dynamicpricing"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.DynamicPricing = x
+	}
+	if req.FormValue("maxdownloadbandwidthprice") != "" {
+		var x types.Currency
+		_, err := fmt.Sscan(req.FormValue("maxdownloadbandwidthprice"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.MaxDownloadBandwidthPrice = x
+	}
+	if req.FormValue("maxstorageprice") != "" {
+		var x types.Currency
+		_, err := fmt.Sscan(req.FormValue("maxstorageprice"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.MaxStoragePrice = x
+	}
+	if req.FormValue("maxuploadbandwidthprice") != "" {
+		var x types.Currency
+		_, err := fmt.Sscan(req.FormValue("maxuploadbandwidthprice"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.MaxUploadBandwidthPrice = x
+	}
+
+	if req.FormValue("maxdownloadspeed") != "" {
+		var x int64
+		_, err := fmt.Sscan(req.FormValue("maxdownloadspeed"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.MaxDownloadSpeed = x
+	}
+	if req.FormValue("maxuploadspeed") != "" {
+		var x int64
+		_, err := fmt.Sscan(req.FormValue("maxuploadspeed"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.MaxUploadSpeed = x
+	}
+	if req.FormValue("renterquota") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("renterquota"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.RenterQuota = x
+	}
+	// The renter quotas replace the existing ones. An empty value removes all
+	// renter quotas.
+	if _, ok := req.Form["renterquotas"]; ok {
+		quotas, err := parseRenterQuotas(req.FormValue("renterquotas"))
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.RenterQuotas = quotas
+	}
+
 	return settings, nil
 }
 
+// parseRenterQuotas parses a comma-separated list of renter quotas of the form
+// 'publickey=bytes'.
+func parseRenterQuotas(s string) (map[string]uint64, error) {
+	quotas := make(map[string]uint64)
+	if s == "" {
+		return quotas, nil
+	}
+	for _, quota := range strings.Split(s, ",") {
+		parts := strings.Split(quota, "=")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("renter quota %q is not of the form 'publickey=bytes'", quota)
+		}
+		var spk types.SiaPublicKey
+		spk.LoadString(parts[0])
+		if spk.String() != parts[0] {
+			return nil, fmt.Errorf("invalid renter public key %q", parts[0])
+		}
+		var x uint64
+		if _, err := fmt.Sscan(parts[1], &x); err != nil {
+			return nil, err
+		}
+		quotas[parts[0]] = x
+	}
+	return quotas, nil
+}
+
 // hostEstimateScoreGET handles the POST request to /host/estimatescore and
 // computes an estimated HostDB score for the provided settings.
 func (api *API) hostEstimateScoreGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -262,6 +476,68 @@ func (api *API) hostEstimateScoreGET(w http.ResponseWriter, req *http.Request, _
 	WriteJSON(w, e)
 }
 
+// hostAlertsHandlerGET handles GET requests to the /host/alerts API endpoint,
+// returning the problems with the storage obligations of the host.
+func (api *API) hostAlertsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostAlertsGET{
+		Alerts: api.host.Alerts(),
+	})
+}
+
+// hostMetricsHistoryHandlerGET handles GET requests to the
+// /host/metrics/history API endpoint, returning the history of the financial
+// metrics of the host.
+func (api *API) hostMetricsHistoryHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var from, to types.BlockHeight
+	if req.FormValue("from") != "" {
+		if _, err := fmt.Sscan(req.FormValue("from"), &from); err != nil {
+			WriteError(w, Error{"invalid from: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FormValue("to") != "" {
+		if _, err := fmt.Sscan(req.FormValue("to"), &to); err != nil {
+			WriteError(w, Error{"invalid to: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	bucket := req.FormValue("bucket")
+	if bucket == "" {
+		bucket = "day"
+	}
+	bucketSize, ok := metricsBucketSizes[bucket]
+	if !ok {
+		if _, err := fmt.Sscan(bucket, &bucketSize); err != nil {
+			WriteError(w, Error{"invalid bucket: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	buckets, err := api.host.FinancialMetricsHistory(from, to, bucketSize)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, HostMetricsHistoryGET{
+		BucketSize: bucketSize,
+		Buckets:    buckets,
+	})
+}
+
+// hostPricingHandlerGET handles GET requests to the /host/pricing API
+// endpoint, returning the state of the host's dynamic pricing.
+func (api *API) hostPricingHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	changes, err := api.host.PriceChanges()
+	if err != nil {
+		WriteError(w, Error{"unable to get the price changes: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, HostPricingGET{
+		HostPricing: api.host.Pricing(),
+		Changes:     changes,
+	})
+}
+
 // hostHandlerPOST handles POST request to the /host API endpoint, which sets
 // the internal settings of the host.
 func (api *API) hostHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
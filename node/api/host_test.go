@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -181,6 +182,178 @@ func TestHostSettingsHandlerParsing(t *testing.T) {
 	}
 }
 
+// TestHostBandwidthSettings checks that the bandwidth limits and renter quotas
+// of the host can be set through the API.
+func TestHostBandwidthSettings(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	renterKey := "ed25519:" + strings.Repeat("ab", 32)
+	settingsValues := url.Values{}
+	settingsValues.Set("maxdownloadspeed", "1000")
+	settingsValues.Set("maxuploadspeed", "2000")
+	settingsValues.Set("renterquota", "3000")
+	settingsValues.Set("renterquotas", renterKey+"=4000")
+	if err := st.stdPostAPI("/host", settingsValues); err != nil {
+		t.Fatal(err)
+	}
+	var hg HostGET
+	if err := st.getAPI("/host", &hg); err != nil {
+		t.Fatal(err)
+	}
+	is := hg.InternalSettings
+	if is.MaxDownloadSpeed != 1000 || is.MaxUploadSpeed != 2000 || is.RenterQuota != 3000 {
+		t.Fatal("bandwidth limits weren't set:", is)
+	}
+	if !reflect.DeepEqual(is.RenterQuotas, map[string]uint64{renterKey: 4000}) {
+		t.Fatal("renter quotas weren't set:", is.RenterQuotas)
+	}
+
+	// Invalid renter quotas are rejected.
+	for _, quotas := range []string{"foo", "ed25519:xyz=1", renterKey + "=foo"} {
+		settingsValues = url.Values{}
+		settingsValues.Set("renterquotas", quotas)
+		if err := st.stdPostAPI("/host", settingsValues); err == nil {
+			t.Error("invalid renter quotas were accepted:", quotas)
+		}
+	}
+
+	// An empty list removes the renter quotas.
+	settingsValues = url.Values{}
+	settingsValues.Set("renterquotas", "")
+	if err := st.stdPostAPI("/host", settingsValues); err != nil {
+		t.Fatal(err)
+	}
+	if quotas := st.host.InternalSettings().RenterQuotas; len(quotas) != 0 {
+		t.Fatal("renter quotas weren't removed:", quotas)
+	}
+}
+
+// TestHostPricing checks that the dynamic pricing can be configured through
+// the API and that /host/pricing reports its state.
+func TestHostPricing(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	var hpg HostPricingGET
+	if err := st.getAPI("/host/pricing", &hpg); err != nil {
+		t.Fatal(err)
+	}
+	if hpg.Enabled || len(hpg.Changes) != 0 {
+		t.Fatal("dynamic pricing is enabled by default:", hpg)
+	}
+
+	minStoragePrice := st.host.InternalSettings().MinStoragePrice
+	settingsValues := url.Values{}
+	settingsValues.Set("dynamicpricing", "true")
+	settingsValues.Set("maxstorageprice", minStoragePrice.Mul64(2).String())
+	settingsValues.Set("maxuploadbandwidthprice", "0")
+	settingsValues.Set("maxdownloadbandwidthprice", "0")
+	if err := st.stdPostAPI("/host", settingsValues); err != nil {
+		t.Fatal(err)
+	}
+	is := st.host.InternalSettings()
+	if !is.DynamicPricing || !is.MaxStoragePrice.Equals(minStoragePrice.Mul64(2)) {
+		t.Fatal("dynamic pricing settings weren't set:", is)
+	}
+	if err := st.getAPI("/host/pricing", &hpg); err != nil {
+		t.Fatal(err)
+	}
+	if !hpg.Enabled {
+		t.Fatal("dynamic pricing isn't reported as enabled")
+	}
+	if hpg.Prices.StoragePrice.Cmp(minStoragePrice) < 0 || hpg.Prices.StoragePrice.Cmp(is.MaxStoragePrice) > 0 {
+		t.Fatal("storage price is outside of the bounds:", hpg.Prices.StoragePrice)
+	}
+
+	// A ceiling below the floor is rejected.
+	settingsValues = url.Values{}
+	settingsValues.Set("maxstorageprice", "1")
+	if err := st.stdPostAPI("/host", settingsValues); err == nil {
+		t.Fatal("maximum storage price below the minimum storage price was accepted")
+	}
+}
+
+// TestHostAlerts checks that a host without problems reports no alerts.
+func TestHostAlerts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	var hag HostAlertsGET
+	if err := st.getAPI("/host/alerts", &hag); err != nil {
+		t.Fatal(err)
+	}
+	if hag.Alerts == nil || len(hag.Alerts) != 0 {
+		t.Fatal("expected an empty list of alerts, got", hag.Alerts)
+	}
+}
+
+// TestHostMetricsHistory checks that the /host/metrics/history endpoint splits
+// the history of the financial metrics into buckets.
+func TestHostMetricsHistory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	height := st.cs.Height()
+	var hmhg HostMetricsHistoryGET
+	if err := st.getAPI(fmt.Sprintf("/host/metrics/history?from=%v&bucket=block", height-4), &hmhg); err != nil {
+		t.Fatal(err)
+	}
+	if hmhg.BucketSize != 1 || len(hmhg.Buckets) != 5 || hmhg.Buckets[4].EndHeight != height {
+		t.Fatal("wrong buckets:", hmhg)
+	}
+	if err := st.getAPI("/host/metrics/history?bucket=3", &hmhg); err != nil {
+		t.Fatal(err)
+	}
+	if hmhg.BucketSize != 3 || hmhg.Buckets[0].StartHeight != 0 || hmhg.Buckets[0].EndHeight != 2 {
+		t.Fatal("wrong buckets:", hmhg)
+	}
+	if err := st.getAPI("/host/metrics/history", &hmhg); err != nil {
+		t.Fatal(err)
+	}
+	if hmhg.BucketSize != 144 {
+		t.Fatal("buckets are not a day by default:", hmhg.BucketSize)
+	}
+	if err := st.getAPI("/host/metrics/history?bucket=month", &hmhg); err == nil {
+		t.Fatal("unknown bucket was accepted")
+	}
+	if err := st.getAPI("/host/metrics/history?bucket=0", &hmhg); err == nil {
+		t.Fatal("empty bucket was accepted")
+	}
+}
+
 // TestWorkingStatus tests that the host's WorkingStatus field is set
 // correctly.
 func TestWorkingStatus(t *testing.T) {
@@ -3,6 +3,7 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
@@ -35,6 +36,13 @@ type (
 		Entry          ExtendedHostDBEntry        `json:"entry"`
 		ScoreBreakdown modules.HostScoreBreakdown `json:"scorebreakdown"`
 	}
+
+	// HostdbFilterModeGET contains the filter mode of the hostdb and the
+	// public keys of the hosts it applies to.
+	HostdbFilterModeGET struct {
+		FilterMode modules.FilterMode `json:"filtermode"`
+		Hosts      []string           `json:"hosts"`
+	}
 )
 
 // hostdbActiveHandler handles the API call asking for the list of active
@@ -114,3 +122,44 @@ func (api *API) hostdbHostsHandler(w http.ResponseWriter, req *http.Request, ps
 		ScoreBreakdown: breakdown,
 	})
 }
+
+// hostdbFilterModeHandlerGET handles the API call asking for the filter mode
+// of the hostdb.
+func (api *API) hostdbFilterModeHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	fm, hosts := api.renter.FilterMode()
+	hdfg := HostdbFilterModeGET{
+		FilterMode: fm,
+		Hosts:      make([]string, 0, len(hosts)),
+	}
+	for _, spk := range hosts {
+		hdfg.Hosts = append(hdfg.Hosts, spk.String())
+	}
+	WriteJSON(w, hdfg)
+}
+
+// hostdbFilterModeHandlerPOST handles the API call to set the filter mode of
+// the hostdb.
+func (api *API) hostdbFilterModeHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	fm, err := modules.ParseFilterMode(req.FormValue("filtermode"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var hosts []types.SiaPublicKey
+	if h := req.FormValue("hosts"); h != "" {
+		for _, s := range strings.Split(h, ",") {
+			var spk types.SiaPublicKey
+			spk.LoadString(s)
+			if len(spk.Key) == 0 {
+				WriteError(w, Error{"unable to parse host public key " + s}, http.StatusBadRequest)
+				return
+			}
+			hosts = append(hosts, spk)
+		}
+	}
+	if err := api.renter.SetFilterMode(fm, hosts); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
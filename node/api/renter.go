@@ -1,14 +1,17 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/renter"
 	"github.com/NebulousLabs/Sia/types"
@@ -69,6 +72,8 @@ type (
 		Settings         modules.RenterSettings     `json:"settings"`
 		FinancialMetrics modules.ContractorSpending `json:"financialmetrics"`
 		CurrentPeriod    types.BlockHeight          `json:"currentperiod"`
+		DedupStats       modules.DedupStats         `json:"dedupstats"`
+		ChunkCache       modules.ChunkCacheStats    `json:"chunkcache"`
 	}
 
 	// RenterContract represents a contract formed by the renter.
@@ -116,6 +121,23 @@ type (
 	// RenterContracts contains the renter's contracts.
 	RenterContracts struct {
 		Contracts []RenterContract `json:"contracts"`
+
+		// DiversityViolations are the groups of contract hosts that share a
+		// subnet or a region, violating the renter's diversity policy.
+		DiversityViolations []modules.HostDiversityViolation `json:"diversityviolations"`
+	}
+
+	// RenterDirectory lists the subdirectories and files of a directory. The
+	// first element of Directories is the queried directory itself.
+	RenterDirectory struct {
+		Directories []modules.DirectoryInfo `json:"directories"`
+		Files       []modules.FileInfo      `json:"files"`
+	}
+
+	// RenterDownloadAsync contains the ID of a download that was started
+	// asynchronously.
+	RenterDownloadAsync struct {
+		ID string `json:"id"`
 	}
 
 	// RenterDownloadQueue contains the renter's download queue.
@@ -138,25 +160,79 @@ type (
 		FilesAdded []string `json:"filesadded"`
 	}
 
+	// RenterPacks lists the packs that store the renter's small files.
+	RenterPacks struct {
+		Packs []modules.PackInfo `json:"packs"`
+	}
+
 	// RenterPricesGET lists the data that is returned when a GET call is made
 	// to /renter/prices.
 	RenterPricesGET struct {
 		modules.RenterPriceEstimation
 	}
 
+	// RenterReencryptions lists the re-encryptions that are in progress or
+	// have failed.
+	RenterReencryptions struct {
+		Reencryptions []modules.ReencryptionInfo `json:"reencryptions"`
+	}
+
+	// RenterRestore contains the files and the number of contracts that
+	// were restored from a backup.
+	RenterRestore struct {
+		modules.BackupInfo
+	}
+
 	// RenterShareASCII contains an ASCII-encoded .sia file.
 	RenterShareASCII struct {
 		ASCIIsia string `json:"asciisia"`
 	}
 
+	// RenterMounts lists the filesystems mounted by the renter.
+	RenterMounts struct {
+		Mounts []modules.MountInfo `json:"mounts"`
+	}
+
+	// RenterSpending contains the selected entries of the renter's spending
+	// ledger along with their total.
+	RenterSpending struct {
+		Files []modules.FileSpending `json:"files"`
+		Total modules.DataSpending   `json:"total"`
+	}
+
+	// RenterTicketShare contains a share of files that can be downloaded
+	// with download tickets.
+	RenterTicketShare struct {
+		Share string `json:"share"`
+	}
+
+	// RenterStreams lists the open streams of the /renter/stream endpoint.
+	RenterStreams struct {
+		Streams []modules.StreamInfo `json:"streams"`
+	}
+
+	// RenterUpload contains the ID of a new upload, which is used to pause,
+	// resume or cancel it.
+	RenterUpload struct {
+		ID string `json:"id"`
+	}
+
+	// RenterUploadStream contains the offset at which an interrupted stream
+	// upload can be resumed.
+	RenterUploadStream struct {
+		Offset uint64 `json:"offset"`
+	}
+
 	// DownloadInfo contains all client-facing information of a file.
 	DownloadInfo struct {
-		Destination     string `json:"destination"`     // The destination of the download.
-		DestinationType string `json:"destinationtype"` // Can be "file", "memory buffer", or "http stream".
-		Filesize        uint64 `json:"filesize"`        // DEPRECATED. Same as 'Length'.
-		Length          uint64 `json:"length"`          // The length requested for the download.
-		Offset          uint64 `json:"offset"`          // The offset within the siafile requested for the download.
-		SiaPath         string `json:"siapath"`         // The siapath of the file used for the download.
+		ID              string           `json:"id"`              // The ID of the download.
+		Destination     string           `json:"destination"`     // The destination of the download.
+		DestinationType string           `json:"destinationtype"` // Can be "file", "memory buffer", or "http stream".
+		Filesize        uint64           `json:"filesize"`        // DEPRECATED. Same as 'Length'.
+		Length          uint64           `json:"length"`          // The length requested for the download.
+		Offset          uint64           `json:"offset"`          // The offset within the siafile requested for the download.
+		SiaPath         string           `json:"siapath"`         // The siapath of the file used for the download.
+		Priority        modules.Priority `json:"priority"`        // The priority class of the download.
 
 		Completed            bool      `json:"completed"`            // Whether or not the download has completed.
 		EndTime              time.Time `json:"endtime"`              // The time when the download fully completed.
@@ -175,6 +251,8 @@ func (api *API) renterHandlerGET(w http.ResponseWriter, req *http.Request, _ htt
 		Settings:         settings,
 		FinancialMetrics: api.renter.PeriodSpending(),
 		CurrentPeriod:    periodStart,
+		DedupStats:       api.renter.DedupStats(),
+		ChunkCache:       api.renter.ChunkCacheStats(),
 	})
 }
 
@@ -235,6 +313,41 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 		// Sane defaults if renew window hasn't been set before.
 		settings.Allowance.RenewWindow = settings.Allowance.Period / 2
 	}
+	// Scan the expected usage. (optional parameters)
+	for _, p := range []struct {
+		name  string
+		field *uint64
+	}{
+		{"expectedstorage", &settings.Allowance.ExpectedStorage},
+		{"expectedupload", &settings.Allowance.ExpectedUpload},
+		{"expecteddownload", &settings.Allowance.ExpectedDownload},
+	} {
+		if v := req.FormValue(p.name); v != "" {
+			if _, err := fmt.Sscan(v, p.field); err != nil {
+				WriteError(w, Error{"unable to parse " + p.name + ": " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	// Scan the spending caps. (optional parameters)
+	for _, p := range []struct {
+		name  string
+		field *types.Currency
+	}{
+		{"maxstoragespending", &settings.Allowance.MaxStorageSpending},
+		{"maxuploadspending", &settings.Allowance.MaxUploadSpending},
+		{"maxdownloadspending", &settings.Allowance.MaxDownloadSpending},
+		{"maxpricepertb", &settings.Allowance.MaxPricePerTB},
+	} {
+		if v := req.FormValue(p.name); v != "" {
+			amount, ok := scanAmount(v)
+			if !ok {
+				WriteError(w, Error{"unable to parse " + p.name}, http.StatusBadRequest)
+				return
+			}
+			*p.field = amount
+		}
+	}
 	// Scan the download speed limit. (optional parameter)
 	if d := req.FormValue("maxdownloadspeed"); d != "" {
 		var downloadSpeed int64
@@ -262,6 +375,29 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 		}
 		settings.StreamCacheSize = streamCacheSize
 	}
+	// Scan the chunk cache size. (optional parameter)
+	if ccs := req.FormValue("chunkcachesize"); ccs != "" {
+		var chunkCacheSize uint64
+		if _, err := fmt.Sscan(ccs, &chunkCacheSize); err != nil {
+			WriteError(w, Error{"unable to parse chunkcachesize: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.ChunkCacheSize = chunkCacheSize
+	}
+	// Scan the subnet diversity. (optional parameter)
+	if sd := req.FormValue("subnetdiversity"); sd != "" {
+		subnetDiversity, err := scanBool(sd)
+		if err != nil {
+			WriteError(w, Error{"unable to parse subnetdiversity: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.SubnetDiversity = subnetDiversity
+	}
+	// Scan the path of the GeoIP database. An empty path disables the region
+	// diversity. (optional parameter)
+	if _, ok := req.Form["geoipdatabase"]; ok {
+		settings.GeoIPDatabase = req.FormValue("geoipdatabase")
+	}
 	// Set the settings in the renter.
 	err := api.renter.SetSettings(settings)
 	if err != nil {
@@ -271,6 +407,109 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 	WriteSuccess(w)
 }
 
+// renterBackupHandlerPOST handles the API call to create a backup of the
+// renter's files, contracts and settings, or to upload a backup of the files
+// and settings to the renter's hosts.
+func (api *API) renterBackupHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	remote, err := scanBool(req.FormValue("remote"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse remote: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if remote {
+		if err := api.renter.UploadBackup(); err != nil {
+			WriteError(w, Error{"failed to upload backup: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		WriteSuccess(w)
+		return
+	}
+	destination := req.FormValue("destination")
+	if !filepath.IsAbs(destination) {
+		WriteError(w, Error{"destination must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	if err := api.renter.CreateBackup(destination); err != nil {
+		WriteError(w, Error{"failed to create backup: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterRestoreHandlerPOST handles the API call to restore a backup created
+// by /renter/backup.
+func (api *API) renterRestoreHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	source := req.FormValue("source")
+	if !filepath.IsAbs(source) {
+		WriteError(w, Error{"source must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	info, err := api.renter.RestoreBackup(source)
+	if err != nil {
+		WriteError(w, Error{"failed to restore backup: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterRestore{info})
+}
+
+// renterRecoverHandlerPOST handles the API call to recover the renter's
+// contracts and files from the wallet seed.
+func (api *API) renterRecoverHandlerPOST(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	info, err := api.renter.Recover()
+	if err != nil {
+		WriteError(w, Error{"failed to recover renter: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterRestore{info})
+}
+
+// renterMountHandlerPOST handles the API call to mount a directory of the
+// renter as a local filesystem.
+func (api *API) renterMountHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	mountPoint := req.FormValue("mountpoint")
+	if !filepath.IsAbs(mountPoint) {
+		WriteError(w, Error{"mountpoint must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	opts := modules.MountOptions{
+		SiaPath:  strings.TrimPrefix(req.FormValue("siapath"), "/"),
+		Prefetch: renter.DefaultStreamPrefetch,
+	}
+	if p := req.FormValue("prefetch"); p != "" {
+		if _, err := fmt.Sscan(p, &opts.Prefetch); err != nil {
+			WriteError(w, Error{"unable to parse prefetch: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if cs := req.FormValue("cachesize"); cs != "" {
+		if _, err := fmt.Sscan(cs, &opts.CacheSize); err != nil {
+			WriteError(w, Error{"unable to parse cachesize: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := api.renter.Mount(mountPoint, opts); err != nil {
+		WriteError(w, Error{"failed to mount filesystem: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterMountsHandler handles the API call to list the mounted filesystems.
+func (api *API) renterMountsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterMounts{
+		Mounts: api.renter.Mounts(),
+	})
+}
+
+// renterUnmountHandlerPOST handles the API call to unmount a filesystem.
+func (api *API) renterUnmountHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if err := api.renter.Unmount(req.FormValue("mountpoint")); err != nil {
+		WriteError(w, Error{"failed to unmount filesystem: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // renterContractsHandler handles the API call to request the Renter's contracts.
 func (api *API) renterContractsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
 	contracts := []RenterContract{}
@@ -315,7 +554,8 @@ func (api *API) renterContractsHandler(w http.ResponseWriter, _ *http.Request, _
 		})
 	}
 	WriteJSON(w, RenterContracts{
-		Contracts: contracts,
+		Contracts:           contracts,
+		DiversityViolations: api.renter.DiversityViolations(),
 	})
 }
 
@@ -324,12 +564,14 @@ func (api *API) renterDownloadsHandler(w http.ResponseWriter, _ *http.Request, _
 	var downloads []DownloadInfo
 	for _, di := range api.renter.DownloadHistory() {
 		downloads = append(downloads, DownloadInfo{
+			ID:              di.ID,
 			Destination:     di.Destination,
 			DestinationType: di.DestinationType,
 			Filesize:        di.Length,
 			Length:          di.Length,
 			Offset:          di.Offset,
 			SiaPath:         di.SiaPath,
+			Priority:        di.Priority,
 
 			Completed:            di.Completed,
 			EndTime:              di.EndTime,
@@ -375,6 +617,18 @@ func (api *API) renterLoadASCIIHandler(w http.ResponseWriter, req *http.Request,
 	WriteJSON(w, RenterLoad{FilesAdded: files})
 }
 
+// renterLoadTicketHandler handles the API call to load the files of a ticket
+// share.
+func (api *API) renterLoadTicketHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	files, err := api.renter.LoadTicketShare(req.FormValue("share"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteJSON(w, RenterLoad{FilesAdded: files})
+}
+
 // renterRenameHandler handles the API call to rename a file entry in the
 // renter.
 func (api *API) renterRenameHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -387,6 +641,63 @@ func (api *API) renterRenameHandler(w http.ResponseWriter, req *http.Request, ps
 	WriteSuccess(w)
 }
 
+// renterReencryptHandler handles the API call to re-encrypt a file under a new
+// key and cipher.
+func (api *API) renterReencryptHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	err := api.renter.ReencryptFile(strings.TrimPrefix(ps.ByName("siapath"), "/"), crypto.CipherType(req.FormValue("cipher")))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterReencryptionsHandler handles the API call to list the renter's
+// re-encryptions.
+func (api *API) renterReencryptionsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterReencryptions{
+		Reencryptions: api.renter.Reencryptions(),
+	})
+}
+
+// renterDirHandlerGET handles the API call to list a directory.
+func (api *API) renterDirHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	dirs, files, err := api.renter.DirList(strings.TrimPrefix(ps.ByName("siapath"), "/"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterDirectory{
+		Directories: dirs,
+		Files:       files,
+	})
+}
+
+// renterDirHandlerPOST handles the API calls to create, delete and rename
+// directories.
+func (api *API) renterDirHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	siaPath := strings.TrimPrefix(ps.ByName("siapath"), "/")
+	var err error
+	switch action := req.FormValue("action"); action {
+	case "create":
+		err = api.renter.CreateDir(siaPath)
+	case "delete":
+		err = api.renter.DeleteDir(siaPath)
+	case "rename":
+		err = api.renter.RenameDir(siaPath, strings.TrimPrefix(req.FormValue("newsiapath"), "/"))
+	case "":
+		err = errors.New("you must set the action you wish to execute")
+	default:
+		err = fmt.Errorf("unknown action %v", action)
+	}
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
+
 // renterFileHandler handles the API call to return specific file.
 func (api *API) renterFileHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	file, err := api.renter.File(strings.TrimPrefix(ps.ByName("siapath"), "/"))
@@ -406,6 +717,40 @@ func (api *API) renterFilesHandler(w http.ResponseWriter, req *http.Request, _ h
 	})
 }
 
+// renterPacksHandler handles the API call to list the packs of the renter.
+func (api *API) renterPacksHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterPacks{
+		Packs: api.renter.Packs(),
+	})
+}
+
+// renterSpendingHandler handles the API call to list the entries of the
+// renter's spending ledger.
+func (api *API) renterSpendingHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	filter := modules.SpendingFilter{
+		Prefix: req.FormValue("prefix"),
+	}
+	if s := req.FormValue("since"); s != "" {
+		if _, err := fmt.Sscan(s, &filter.Since); err != nil {
+			WriteError(w, Error{"unable to parse since: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if u := req.FormValue("until"); u != "" {
+		if _, err := fmt.Sscan(u, &filter.Until); err != nil {
+			WriteError(w, Error{"unable to parse until: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	rs := RenterSpending{
+		Files: api.renter.SpendingLedger(filter),
+	}
+	for _, fs := range rs.Files {
+		rs.Total = rs.Total.Add(fs.DataSpending)
+	}
+	WriteJSON(w, rs)
+}
+
 // renterPricesHandler reports the expected costs of various actions given the
 // renter settings and the set of available hosts.
 func (api *API) renterPricesHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -434,10 +779,17 @@ func (api *API) renterDownloadHandler(w http.ResponseWriter, req *http.Request,
 		return
 	}
 	if params.Async {
-		err = api.renter.DownloadAsync(params)
-	} else {
-		err = api.renter.Download(params)
+		id, err := api.renter.DownloadAsync(params)
+		if err != nil {
+			WriteError(w, Error{"download failed: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, RenterDownloadAsync{
+			ID: id,
+		})
+		return
 	}
+	err = api.renter.Download(params)
 	if err != nil {
 		WriteError(w, Error{"download failed: " + err.Error()}, http.StatusInternalServerError)
 		return
@@ -458,6 +810,15 @@ func (api *API) renterDownloadAsyncHandler(w http.ResponseWriter, req *http.Requ
 	api.renterDownloadHandler(w, req, ps)
 }
 
+// renterDownloadCancelHandler handles the API call to cancel a download.
+func (api *API) renterDownloadCancelHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	if err := api.renter.CancelDownload(ps.ByName("id")); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // parseDownloadParameters parses the download parameters passed to the
 // /renter/download endpoint. Validation of these parameters is done by the
 // renter.
@@ -502,6 +863,12 @@ func parseDownloadParameters(w http.ResponseWriter, req *http.Request, ps httpro
 		return modules.RenterDownloadParameters{}, build.ExtendErr("async parameter could not be parsed", err)
 	}
 
+	// Parse the priority parameter.
+	priority, err := modules.ParsePriority(req.FormValue("priority"))
+	if err != nil {
+		return modules.RenterDownloadParameters{}, build.ExtendErr("priority parameter could not be parsed", err)
+	}
+
 	siapath := strings.TrimPrefix(ps.ByName("siapath"), "/") // Sia file name.
 
 	dp := modules.RenterDownloadParameters{
@@ -510,6 +877,7 @@ func parseDownloadParameters(w http.ResponseWriter, req *http.Request, ps httpro
 		Length:      length,
 		Offset:      offset,
 		SiaPath:     siapath,
+		Priority:    priority,
 	}
 	if httpresp {
 		dp.Httpwriter = w
@@ -550,18 +918,138 @@ func (api *API) renterShareASCIIHandler(w http.ResponseWriter, req *http.Request
 	})
 }
 
-// renterStreamHandler handles downloads from the /renter/stream endpoint
+// renterShareTicketHandler handles the API call to share files with other
+// renters through download tickets.
+func (api *API) renterShareTicketHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var duration types.BlockHeight
+	if _, err := fmt.Sscan(req.FormValue("duration"), &duration); err != nil {
+		WriteError(w, Error{"unable to parse duration: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	share, err := api.renter.ShareTickets(strings.Split(req.FormValue("siapaths"), ","), duration)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterTicketShare{
+		Share: share,
+	})
+}
+
+// renterStreamHandler handles downloads from the /renter/stream endpoint.
+// http.ServeContent takes care of single and multipart range requests.
 func (api *API) renterStreamHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	prefetch := uint64(renter.DefaultStreamPrefetch)
+	if p := req.FormValue("prefetch"); p != "" {
+		if _, err := fmt.Sscan(p, &prefetch); err != nil {
+			WriteError(w, Error{"unable to parse prefetch: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
 	siaPath := strings.TrimPrefix(ps.ByName("siapath"), "/")
-	fileName, streamer, err := api.renter.Streamer(siaPath)
+	fileName, streamer, err := api.renter.Streamer(siaPath, prefetch)
 	if err != nil {
 		WriteError(w, Error{fmt.Sprintf("failed to create download streamer: %v", err)},
 			http.StatusInternalServerError)
 		return
 	}
+	defer streamer.Close()
 	http.ServeContent(w, req, fileName, time.Time{}, streamer)
 }
 
+// renterStreamsHandler handles the API call to list the open streams.
+func (api *API) renterStreamsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterStreams{
+		Streams: api.renter.Streams(),
+	})
+}
+
+// parseDedup parses the dedup parameter of an upload. Deduplication is
+// disabled by default.
+func parseDedup(values url.Values) (bool, error) {
+	if values.Get("dedup") == "" {
+		return false, nil
+	}
+	dedup, err := scanBool(values.Get("dedup"))
+	if err != nil {
+		return false, errors.New("unable to read parameter 'dedup': " + err.Error())
+	}
+	return dedup, nil
+}
+
+// parseCipher parses the cipher parameter of an upload. An empty cipher type is
+// returned if no cipher is supplied, in which case the renter uses Twofish.
+func parseCipher(values url.Values) (crypto.CipherType, error) {
+	ct := crypto.CipherType(values.Get("cipher"))
+	if ct == "" {
+		return "", nil
+	}
+	if err := ct.Valid(); err != nil {
+		return "", errors.New("unable to read parameter 'cipher': " + err.Error())
+	}
+	return ct, nil
+}
+
+// parseErasureCoder parses the erasure coding parameters of an upload. A nil
+// erasure coder is returned if no parameters are supplied, in which case the
+// renter uses its defaults.
+func parseErasureCoder(values url.Values) (modules.ErasureCoder, error) {
+	// Check whether an erasure coder has been selected. If no erasure coding
+	// parameters are supplied, the default redundancy of the coder is used.
+	var ec modules.ErasureCoder
+	ecType := modules.ErasureCoderReedSolomon
+	if values.Get("erasurecoder") != "" {
+		var err error
+		if len(values.Get("erasurecoder")) > types.SpecifierLen {
+			err = renter.ErrUnknownErasureCoder
+		} else {
+			ecType = types.Specifier{}
+			copy(ecType[:], values.Get("erasurecoder"))
+			ec, err = renter.NewDefaultErasureCoder(ecType)
+		}
+		if err != nil {
+			return nil, errors.New("unable to read parameter 'erasurecoder': " + err.Error())
+		}
+	}
+
+	// Check whether the erasure coding parameters have been supplied.
+	if values.Get("datapieces") == "" && values.Get("paritypieces") == "" {
+		return ec, nil
+	}
+	// Check that both values have been supplied.
+	if values.Get("datapieces") == "" || values.Get("paritypieces") == "" {
+		return nil, errors.New("must provide both the datapieces paramaeter and the paritypieces parameter if specifying erasure coding parameters")
+	}
+
+	// Parse the erasure coding parameters.
+	var dataPieces, parityPieces int
+	_, err := fmt.Sscan(values.Get("datapieces"), &dataPieces)
+	if err != nil {
+		return nil, errors.New("unable to read parameter 'datapieces': " + err.Error())
+	}
+	_, err = fmt.Sscan(values.Get("paritypieces"), &parityPieces)
+	if err != nil {
+		return nil, errors.New("unable to read parameter 'paritypieces': " + err.Error())
+	}
+
+	// Verify that sane values for parityPieces and redundancy are being
+	// supplied.
+	if parityPieces < requiredParityPieces {
+		return nil, fmt.Errorf("a minimum of %v parity pieces is required, but %v parity pieces requested", parityPieces, requiredParityPieces)
+	}
+	redundancy := float64(dataPieces+parityPieces) / float64(dataPieces)
+	if float64(dataPieces+parityPieces)/float64(dataPieces) < requiredRedundancy {
+		return nil, fmt.Errorf("a redundancy of %.2f is required, but redundancy of %.2f supplied", redundancy, requiredRedundancy)
+	}
+
+	// Create the erasure coder.
+	ec, err = renter.NewErasureCoder(ecType, dataPieces, parityPieces)
+	if err != nil {
+		return nil, errors.New("unable to encode file using the provided parameters: " + err.Error())
+	}
+	return ec, nil
+}
+
 // renterUploadHandler handles the API call to upload a file.
 func (api *API) renterUploadHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	source := req.FormValue("source")
@@ -570,54 +1058,151 @@ func (api *API) renterUploadHandler(w http.ResponseWriter, req *http.Request, ps
 		return
 	}
 
-	// Check whether the erasure coding parameters have been supplied.
-	var ec modules.ErasureCoder
-	if req.FormValue("datapieces") != "" || req.FormValue("paritypieces") != "" {
-		// Check that both values have been supplied.
-		if req.FormValue("datapieces") == "" || req.FormValue("paritypieces") == "" {
-			WriteError(w, Error{"must provide both the datapieces paramaeter and the paritypieces parameter if specifying erasure coding parameters"}, http.StatusBadRequest)
-			return
-		}
+	// Parse the erasure coding parameters.
+	if err := req.ParseForm(); err != nil {
+		WriteError(w, Error{"unable to parse form: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	ec, err := parseErasureCoder(req.Form)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	dedup, err := parseDedup(req.Form)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	priority, err := modules.ParsePriority(req.Form.Get("priority"))
+	if err != nil {
+		WriteError(w, Error{"unable to read parameter 'priority': " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	ct, err := parseCipher(req.Form)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
 
-		// Parse the erasure coding parameters.
-		var dataPieces, parityPieces int
-		_, err := fmt.Sscan(req.FormValue("datapieces"), &dataPieces)
-		if err != nil {
-			WriteError(w, Error{"unable to read parameter 'datapieces': " + err.Error()}, http.StatusBadRequest)
-			return
-		}
-		_, err = fmt.Sscan(req.FormValue("paritypieces"), &parityPieces)
-		if err != nil {
-			WriteError(w, Error{"unable to read parameter 'paritypieces': " + err.Error()}, http.StatusBadRequest)
-			return
-		}
+	// Call the renter to upload the file.
+	id, err := api.renter.Upload(modules.FileUploadParams{
+		Source:      source,
+		SiaPath:     strings.TrimPrefix(ps.ByName("siapath"), "/"),
+		ErasureCode: ec,
+		Dedup:       dedup,
+		Priority:    priority,
+		CipherType:  ct,
+	})
+	if err != nil {
+		WriteError(w, Error{"upload failed: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, RenterUpload{
+		ID: id,
+	})
+}
+
+// renterUploadPauseHandler handles the API call to pause an upload.
+func (api *API) renterUploadPauseHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	if err := api.renter.PauseUpload(ps.ByName("id")); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterUploadResumeHandler handles the API call to resume a paused upload.
+func (api *API) renterUploadResumeHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	if err := api.renter.ResumeUpload(ps.ByName("id")); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterUploadCancelHandler handles the API call to cancel an upload.
+func (api *API) renterUploadCancelHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	if err := api.renter.CancelUpload(ps.ByName("id")); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterUploadStreamHandlerGET handles the API call to query the offset at
+// which an interrupted stream upload can be resumed.
+func (api *API) renterUploadStreamHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	offset, err := api.renter.UploadStreamOffset(strings.TrimPrefix(ps.ByName("siapath"), "/"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterUploadStream{
+		Offset: offset,
+	})
+}
+
+// renterUploadStreamHandlerPOST handles the API call to upload a file from the
+// request body. The parameters are read from the query string since the body
+// contains the data of the file.
+func (api *API) renterUploadStreamHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	siaPath := strings.TrimPrefix(ps.ByName("siapath"), "/")
+	values := req.URL.Query()
 
-		// Verify that sane values for parityPieces and redundancy are being
-		// supplied.
-		if parityPieces < requiredParityPieces {
-			WriteError(w, Error{fmt.Sprintf("a minimum of %v parity pieces is required, but %v parity pieces requested", parityPieces, requiredParityPieces)}, http.StatusBadRequest)
+	// Check whether an interrupted upload is resumed.
+	var resume bool
+	if values.Get("resume") != "" {
+		var err error
+		resume, err = scanBool(values.Get("resume"))
+		if err != nil {
+			WriteError(w, Error{"unable to read parameter 'resume': " + err.Error()}, http.StatusBadRequest)
 			return
 		}
-		redundancy := float64(dataPieces+parityPieces) / float64(dataPieces)
-		if float64(dataPieces+parityPieces)/float64(dataPieces) < requiredRedundancy {
-			WriteError(w, Error{fmt.Sprintf("a redundancy of %.2f is required, but redundancy of %.2f supplied", redundancy, requiredRedundancy)}, http.StatusBadRequest)
-			return
+	}
+	if resume {
+		var offset uint64
+		if values.Get("offset") != "" {
+			if _, err := fmt.Sscan(values.Get("offset"), &offset); err != nil {
+				WriteError(w, Error{"unable to read parameter 'offset': " + err.Error()}, http.StatusBadRequest)
+				return
+			}
 		}
-
-		// Create the erasure coder.
-		ec, err = renter.NewRSCode(dataPieces, parityPieces)
-		if err != nil {
-			WriteError(w, Error{"unable to encode file using the provided parameters: " + err.Error()}, http.StatusBadRequest)
+		if err := api.renter.ResumeUploadStream(siaPath, offset, req.Body); err != nil {
+			WriteError(w, Error{"upload failed: " + err.Error()}, http.StatusInternalServerError)
 			return
 		}
+		WriteSuccess(w)
+		return
 	}
 
-	// Call the renter to upload the file.
-	err := api.renter.Upload(modules.FileUploadParams{
-		Source:      source,
-		SiaPath:     strings.TrimPrefix(ps.ByName("siapath"), "/"),
+	// Start a new upload.
+	ec, err := parseErasureCoder(values)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	dedup, err := parseDedup(values)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	priority, err := modules.ParsePriority(values.Get("priority"))
+	if err != nil {
+		WriteError(w, Error{"unable to read parameter 'priority': " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	ct, err := parseCipher(values)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.renter.UploadStreamFromReader(modules.FileUploadParams{
+		SiaPath:     siaPath,
 		ErasureCode: ec,
-	})
+		Dedup:       dedup,
+		Priority:    priority,
+		CipherType:  ct,
+	}, req.Body)
 	if err != nil {
 		WriteError(w, Error{"upload failed: " + err.Error()}, http.StatusInternalServerError)
 		return
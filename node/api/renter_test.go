@@ -451,10 +451,14 @@ func TestRenterAsyncDownload(t *testing.T) {
 
 	// Download the file asynchronously.
 	downpath := filepath.Join(st.dir, "asyncdown.dat")
-	err := st.getAPI("/renter/downloadasync/test.dat?destination="+downpath, nil)
+	var rda RenterDownloadAsync
+	err := st.getAPI("/renter/downloadasync/test.dat?destination="+downpath, &rda)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if rda.ID == "" {
+		t.Fatal("/renter/downloadasync didn't return the id of the download")
+	}
 
 	// download should eventually complete
 	var rdq RenterDownloadQueue
@@ -730,6 +734,55 @@ func TestRenterHandlerGetAndPost(t *testing.T) {
 	if got := get.Settings.Allowance.RenewWindow; got != expectedRenewWindow {
 		t.Fatalf("expected renew window to be %v; got %v", expectedRenewWindow, got)
 	}
+	// Set the expected usage and the spending caps.
+	allowanceValues.Set("expectedstorage", "1000")
+	allowanceValues.Set("expecteddownload", "3000")
+	allowanceValues.Set("maxuploadspending", "500")
+	allowanceValues.Set("maxpricepertb", testFunds)
+	if err = st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.getAPI("/renter", &get); err != nil {
+		t.Fatal(err)
+	}
+	a := get.Settings.Allowance
+	if a.ExpectedStorage != 1000 || a.ExpectedUpload != 0 || a.ExpectedDownload != 3000 {
+		t.Fatal("wrong expected usage:", a.ExpectedStorage, a.ExpectedUpload, a.ExpectedDownload)
+	}
+	if !a.MaxUploadSpending.Equals64(500) || a.MaxPricePerTB.Cmp(expectedFunds) != 0 || !a.MaxStorageSpending.IsZero() {
+		t.Fatal("wrong spending caps:", a.MaxUploadSpending, a.MaxPricePerTB, a.MaxStorageSpending)
+	}
+	// Try an invalid spending cap.
+	allowanceValues.Set("maxdownloadspending", "-")
+	err = st.stdPostAPI("/renter", allowanceValues)
+	if err == nil || !strings.Contains(err.Error(), "unable to parse maxdownloadspending") {
+		t.Errorf("expected error to begin with 'unable to parse maxdownloadspending'; got %v", err)
+	}
+	allowanceValues.Del("maxdownloadspending")
+	// Enable the subnet diversity, and try to load a missing GeoIP database.
+	allowanceValues.Set("subnetdiversity", "true")
+	if err = st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.getAPI("/renter", &get); err != nil {
+		t.Fatal(err)
+	}
+	if !get.Settings.SubnetDiversity || get.Settings.GeoIPDatabase != "" {
+		t.Fatal("wrong diversity policy:", get.Settings.SubnetDiversity, get.Settings.GeoIPDatabase)
+	}
+	allowanceValues.Set("geoipdatabase", filepath.Join(st.dir, "missing.csv"))
+	err = st.stdPostAPI("/renter", allowanceValues)
+	if err == nil || !strings.Contains(err.Error(), "unable to load GeoIP database") {
+		t.Errorf("expected error to begin with 'unable to load GeoIP database'; got %v", err)
+	}
+	allowanceValues.Del("geoipdatabase")
+	var rc RenterContracts
+	if err = st.getAPI("/renter/contracts", &rc); err != nil {
+		t.Fatal(err)
+	}
+	if len(rc.DiversityViolations) != 0 {
+		t.Fatal("diversity violations without contracts:", rc.DiversityViolations)
+	}
 	// Try an invalid period string.
 	allowanceValues.Set("period", "-1")
 	err = st.stdPostAPI("/renter", allowanceValues)
@@ -118,6 +118,78 @@ func TestHostObligationAcceptingContracts(t *testing.T) {
 		t.Fatal("Number of contracts returned by API call and host method don't match.")
 	}
 
+	// Filter and page the contracts.
+	var page ContractInfoGET
+	err = st.getAPI("/host/contracts?status=unresolved&sort=negotiationheight&desc=true&limit=1", &page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != len(cts.Contracts) || len(page.Contracts) != 1 {
+		t.Fatalf("expected 1 of %v contracts, got %v of %v", len(cts.Contracts), len(page.Contracts), page.Total)
+	}
+	err = st.getAPI("/host/contracts?status=succeeded", &page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 0 || len(page.Contracts) != 0 {
+		t.Fatal("expected no succeeded contracts, got", page.Total)
+	}
+	if err := st.getAPI("/host/contracts?status=foo", &page); err == nil {
+		t.Fatal("unknown status was accepted")
+	}
+
+	// The contracts record the height at which they were negotiated.
+	height := cts.Contracts[0].NegotiationHeight
+	if height == 0 || height > st.cs.Height() {
+		t.Fatal("wrong negotiation height:", height)
+	}
+	err = st.getAPI(fmt.Sprintf("/host/contracts?minnegotiation=%v&maxnegotiation=%v", height, height), &page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total == 0 || page.Contracts[0].NegotiationHeight != height {
+		t.Fatal("height filter didn't return the contract:", page.Contracts)
+	}
+	err = st.getAPI(fmt.Sprintf("/host/contracts?maxnegotiation=%v", height-1), &page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range page.Contracts {
+		if c.NegotiationHeight >= height {
+			t.Fatal("height filter returned a later contract:", c.NegotiationHeight)
+		}
+	}
+
+	// The details of the contract include the revision that added the file.
+	var hcg HostContractGET
+	err = st.getAPI("/host/contracts/"+cts.Contracts[0].ObligationId.String(), &hcg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hcg.Contract.ObligationId != cts.Contracts[0].ObligationId || hcg.Contract.SectorRootsCount == 0 {
+		t.Fatal("wrong contract details:", hcg.Contract.StorageObligation)
+	}
+	if revs := hcg.Contract.Revisions; len(revs) < 2 || revs[0].RevisionNumber != 0 || revs[len(revs)-1].FileSize == 0 {
+		t.Fatal("contract history is incomplete:", revs)
+	}
+	if revs := hcg.Contract.Revisions; revs[len(revs)-1].BlockHeight < hcg.Contract.NegotiationHeight {
+		t.Fatal("latest revision predates the negotiation:", revs[len(revs)-1].BlockHeight)
+	}
+	total := hcg.Contract.TotalRevisions
+	if total != len(hcg.Contract.Revisions) {
+		t.Fatal("wrong number of revisions:", total)
+	}
+	err = st.getAPI("/host/contracts/"+cts.Contracts[0].ObligationId.String()+"?offset=1&limit=1", &hcg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revs := hcg.Contract.Revisions; len(revs) != 1 || revs[0].RevisionNumber == 0 || hcg.Contract.TotalRevisions != total {
+		t.Fatal("wrong page of revisions:", revs)
+	}
+	if err := st.getAPI("/host/contracts/foo", &hcg); err == nil {
+		t.Fatal("invalid contract id was accepted")
+	}
+
 	// set acceptingcontracts = false, mine some blocks, verify we can download
 	settings := st.host.InternalSettings()
 	settings.AcceptingContracts = false
@@ -44,9 +44,13 @@ func (api *API) buildHTTPRoutes(requiredUserAgent string, requiredPassword strin
 		// Calls directly pertaining to the host.
 		router.GET("/host", api.hostHandlerGET)                                                   // Get the host status.
 		router.POST("/host", RequirePassword(api.hostHandlerPOST, requiredPassword))              // Change the settings of the host.
+		router.GET("/host/alerts", api.hostAlertsHandlerGET)                                      // Get the alerts of the contracts.
 		router.POST("/host/announce", RequirePassword(api.hostAnnounceHandler, requiredPassword)) // Announce the host to the network.
 		router.GET("/host/contracts", api.hostContractInfoHandler)                                // Get info about contracts.
+		router.GET("/host/contracts/:id", api.hostContractHandlerGET)                             // Get the details of a contract.
 		router.GET("/host/estimatescore", api.hostEstimateScoreGET)
+		router.GET("/host/metrics/history", api.hostMetricsHistoryHandlerGET) // Get the history of the financial metrics.
+		router.GET("/host/pricing", api.hostPricingHandlerGET)                // Get the state of the dynamic pricing.
 
 		// Calls pertaining to the storage manager that the host uses.
 		router.GET("/host/storage", api.storageHandler)
@@ -70,10 +74,23 @@ func (api *API) buildHTTPRoutes(requiredUserAgent string, requiredPassword strin
 		router.GET("/renter", api.renterHandlerGET)
 		router.POST("/renter", RequirePassword(api.renterHandlerPOST, requiredPassword))
 		router.GET("/renter/contracts", api.renterContractsHandler)
+		router.GET("/renter/dir/*siapath", api.renterDirHandlerGET)
+		router.POST("/renter/dir/*siapath", RequirePassword(api.renterDirHandlerPOST, requiredPassword))
 		router.GET("/renter/downloads", api.renterDownloadsHandler)
+		router.POST("/renter/downloads/:id/cancel", RequirePassword(api.renterDownloadCancelHandler, requiredPassword))
 		router.GET("/renter/files", api.renterFilesHandler)
 		router.GET("/renter/file/*siapath", api.renterFileHandler)
+		router.GET("/renter/packs", api.renterPacksHandler)
 		router.GET("/renter/prices", api.renterPricesHandler)
+		router.GET("/renter/reencryptions", api.renterReencryptionsHandler)
+		router.GET("/renter/spending", api.renterSpendingHandler)
+
+		router.POST("/renter/backup", RequirePassword(api.renterBackupHandlerPOST, requiredPassword))
+		router.POST("/renter/restore", RequirePassword(api.renterRestoreHandlerPOST, requiredPassword))
+		router.POST("/renter/recover", RequirePassword(api.renterRecoverHandlerPOST, requiredPassword))
+		router.POST("/renter/mount", RequirePassword(api.renterMountHandlerPOST, requiredPassword))
+		router.GET("/renter/mounts", api.renterMountsHandler)
+		router.POST("/renter/unmount", RequirePassword(api.renterUnmountHandlerPOST, requiredPassword))
 
 		// TODO: re-enable these routes once the new .sia format has been
 		// standardized and implemented.
@@ -85,14 +102,32 @@ func (api *API) buildHTTPRoutes(requiredUserAgent string, requiredPassword strin
 		router.POST("/renter/delete/*siapath", RequirePassword(api.renterDeleteHandler, requiredPassword))
 		router.GET("/renter/download/*siapath", RequirePassword(api.renterDownloadHandler, requiredPassword))
 		router.GET("/renter/downloadasync/*siapath", RequirePassword(api.renterDownloadAsyncHandler, requiredPassword))
+		router.POST("/renter/reencrypt/*siapath", RequirePassword(api.renterReencryptHandler, requiredPassword))
 		router.POST("/renter/rename/*siapath", RequirePassword(api.renterRenameHandler, requiredPassword))
+		router.POST("/renter/shareticket", RequirePassword(api.renterShareTicketHandler, requiredPassword))
+		router.POST("/renter/loadticket", RequirePassword(api.renterLoadTicketHandler, requiredPassword))
 		router.GET("/renter/stream/*siapath", api.renterStreamHandler)
+		router.GET("/renter/streams", api.renterStreamsHandler)
 		router.POST("/renter/upload/*siapath", RequirePassword(api.renterUploadHandler, requiredPassword))
+		router.POST("/renter/uploads/:id/pause", RequirePassword(api.renterUploadPauseHandler, requiredPassword))
+		router.POST("/renter/uploads/:id/resume", RequirePassword(api.renterUploadResumeHandler, requiredPassword))
+		router.POST("/renter/uploads/:id/cancel", RequirePassword(api.renterUploadCancelHandler, requiredPassword))
+		router.GET("/renter/uploadstream/*siapath", api.renterUploadStreamHandlerGET)
+		router.POST("/renter/uploadstream/*siapath", RequirePassword(api.renterUploadStreamHandlerPOST, requiredPassword))
 
 		// HostDB endpoints.
 		router.GET("/hostdb/active", api.hostdbActiveHandler)
 		router.GET("/hostdb/all", api.hostdbAllHandler)
 		router.GET("/hostdb/hosts/:pubkey", api.hostdbHostsHandler)
+		router.GET("/hostdb/filtermode", api.hostdbFilterModeHandlerGET)
+		router.POST("/hostdb/filtermode", RequirePassword(api.hostdbFilterModeHandlerPOST, requiredPassword))
+	}
+
+	// S3 gateway API Calls
+	if api.s3gateway != nil {
+		router.GET("/s3", RequirePassword(api.s3Handler, requiredPassword))
+		router.POST("/s3/keys/add", RequirePassword(api.s3KeysAddHandler, requiredPassword))
+		router.POST("/s3/keys/remove", RequirePassword(api.s3KeysRemoveHandler, requiredPassword))
 	}
 
 	// Transaction pool API Calls
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type (
+	// S3GET contains the address and the access keys of the S3 gateway.
+	S3GET struct {
+		Address string          `json:"address"`
+		Keys    []modules.S3Key `json:"keys"`
+	}
+)
+
+// s3Handler handles the API call that returns the address and the access keys
+// of the S3 gateway.
+func (api *API) s3Handler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, S3GET{
+		Address: api.s3gateway.Address(),
+		Keys:    api.s3gateway.Keys(),
+	})
+}
+
+// s3KeysAddHandler handles the API call that generates a new access key for
+// the S3 gateway.
+func (api *API) s3KeysAddHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	key, err := api.s3gateway.AddKey()
+	if err != nil {
+		WriteError(w, Error{"unable to add access key: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, key)
+}
+
+// s3KeysRemoveHandler handles the API call that removes an access key of the
+// S3 gateway.
+func (api *API) s3KeysRemoveHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	id := req.FormValue("accesskeyid")
+	if id == "" {
+		WriteError(w, Error{"accesskeyid has to be specified"}, http.StatusBadRequest)
+		return
+	}
+	if err := api.s3gateway.RemoveKey(id); err != nil {
+		WriteError(w, Error{"unable to remove access key: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
@@ -109,7 +109,7 @@ func NewServer(APIaddr string, requiredUserAgent string, requiredPassword string
 		return nil, err
 	}
 
-	api := New(requiredUserAgent, requiredPassword, cs, e, g, h, m, r, tp, w)
+	api := New(requiredUserAgent, requiredPassword, cs, e, g, h, m, r, nil, tp, w)
 	srv := &Server{
 		api: api,
 		apiServer: &http.Server{
@@ -21,6 +21,7 @@ import (
 	"github.com/NebulousLabs/Sia/modules/renter/contractor"
 	"github.com/NebulousLabs/Sia/modules/renter/hostdb"
 	"github.com/NebulousLabs/Sia/modules/renter/proto"
+	"github.com/NebulousLabs/Sia/modules/s3gateway"
 	"github.com/NebulousLabs/Sia/modules/transactionpool"
 	"github.com/NebulousLabs/Sia/modules/wallet"
 	"github.com/NebulousLabs/Sia/persist"
@@ -59,6 +60,7 @@ type NodeParams struct {
 	CreateHost            bool
 	CreateMiner           bool
 	CreateRenter          bool
+	CreateS3Gateway       bool
 	CreateTransactionPool bool
 	CreateWallet          bool
 
@@ -72,6 +74,7 @@ type NodeParams struct {
 	Host            modules.Host
 	Miner           modules.TestMiner
 	Renter          modules.Renter
+	S3Gateway       modules.S3Gateway
 	TransactionPool modules.TransactionPool
 	Wallet          modules.Wallet
 
@@ -85,6 +88,10 @@ type NodeParams struct {
 	// Custom settings for modules
 	Allowance modules.Allowance
 
+	// S3GatewayAddr is the address the S3 gateway listens on. If it is empty,
+	// the gateway listens on a random local port.
+	S3GatewayAddr string
+
 	// The following fields are used to skip parts of the node set up
 	SkipSetAllowance bool
 
@@ -102,6 +109,7 @@ type Node struct {
 	Host            modules.Host
 	Miner           modules.TestMiner
 	Renter          modules.Renter
+	S3Gateway       modules.S3Gateway
 	TransactionPool modules.TransactionPool
 	Wallet          modules.Wallet
 
@@ -113,6 +121,9 @@ type Node struct {
 // Close will call close on every module within the node, combining and
 // returning the errors.
 func (n *Node) Close() (err error) {
+	if n.S3Gateway != nil {
+		err = errors.Compose(n.S3Gateway.Close())
+	}
 	if n.Explorer != nil {
 		err = errors.Compose(n.Explorer.Close())
 	}
@@ -297,12 +308,33 @@ func New(params NodeParams) (*Node, error) {
 		if err != nil {
 			return nil, err
 		}
-		return renter.NewCustomRenter(g, cs, tp, hdb, hc, persistDir, renterDeps)
+		return renter.NewCustomRenter(g, cs, w, tp, hdb, hc, persistDir, renterDeps)
 	}()
 	if err != nil {
 		return nil, errors.Extend(err, errors.New("unable to create renter"))
 	}
 
+	// S3 Gateway.
+	s3, err := func() (modules.S3Gateway, error) {
+		if params.CreateS3Gateway && params.S3Gateway != nil {
+			return nil, errors.New("cannot create s3 gateway and also use custom s3 gateway")
+		}
+		if params.S3Gateway != nil {
+			return params.S3Gateway, nil
+		}
+		if !params.CreateS3Gateway {
+			return nil, nil
+		}
+		addr := params.S3GatewayAddr
+		if addr == "" {
+			addr = "localhost:0"
+		}
+		return s3gateway.New(r, addr, filepath.Join(dir, modules.RenterDir))
+	}()
+	if err != nil {
+		return nil, errors.Extend(err, errors.New("unable to create s3 gateway"))
+	}
+
 	// Miner.
 	m, err := func() (modules.TestMiner, error) {
 		if params.CreateMiner && params.Miner != nil {
@@ -349,6 +381,7 @@ func New(params NodeParams) (*Node, error) {
 		Host:            h,
 		Miner:           m,
 		Renter:          r,
+		S3Gateway:       s3,
 		TransactionPool: tp,
 		Wallet:          w,
 
@@ -11,6 +11,7 @@ import (
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/node/api"
+	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/NebulousLabs/errors"
 	"github.com/NebulousLabs/fastrand"
@@ -67,6 +68,42 @@ func (tn *TestNode) Stream(rf *RemoteFile) (data []byte, err error) {
 	return
 }
 
+// StreamPrefetch uses the streaming endpoint to download a file with a stream
+// that fetches prefetch chunks ahead.
+func (tn *TestNode) StreamPrefetch(rf *RemoteFile, prefetch uint64) (data []byte, err error) {
+	data, err = tn.RenterStreamPrefetchGet(rf.siaPath, prefetch)
+	if err == nil && rf.checksum != crypto.HashBytes(data) {
+		err = errors.New("downloaded bytes don't match requested data")
+	}
+	return
+}
+
+// StreamMultipart uses the streaming endpoint to download several parts of a
+// file at once. Every range [from;to] is checked against the local file.
+func (tn *TestNode) StreamMultipart(rf *RemoteFile, lf *LocalFile, ranges [][2]uint64) (parts [][]byte, err error) {
+	parts, err = tn.RenterStreamMultipartGet(rf.siaPath, ranges)
+	if err != nil {
+		return
+	}
+	if len(parts) != len(ranges) {
+		err = fmt.Errorf("expected %v parts but got %v", len(ranges), len(parts))
+		return
+	}
+	for i, r := range ranges {
+		var checksum crypto.Hash
+		checksum, err = lf.partialChecksum(r[0], r[1]+1)
+		if err != nil {
+			err = errors.AddContext(err, "failed to get partial checksum")
+			return
+		}
+		if checksum != crypto.HashBytes(parts[i]) {
+			err = fmt.Errorf("downloaded bytes don't match requested data %v-%v", r[0], r[1])
+			return
+		}
+	}
+	return
+}
+
 // StreamPartial uses the streaming endpoint to download a partial file in
 // range [from;to]. A local file can be provided optionally to implicitly check
 // the checksum of the downloaded data.
@@ -183,6 +220,89 @@ func (tn *TestNode) Upload(lf *LocalFile, dataPieces, parityPieces uint64) (*Rem
 	return rf, nil
 }
 
+// UploadErasureCoder uses the node to upload the file using the specified
+// erasure coder.
+func (tn *TestNode) UploadErasureCoder(lf *LocalFile, erasureCoder types.Specifier, dataPieces, parityPieces uint64) (*RemoteFile, error) {
+	// Upload file
+	err := tn.RenterUploadErasureCoderPost(lf.path, "/"+lf.fileName(), erasureCoder, dataPieces, parityPieces)
+	if err != nil {
+		return nil, err
+	}
+	// Create remote file object
+	rf := &RemoteFile{
+		siaPath:  lf.fileName(),
+		checksum: lf.checksum,
+	}
+	// Make sure renter tracks file
+	_, err = tn.FileInfo(rf)
+	if err != nil {
+		return rf, errors.AddContext(err, "uploaded file is not tracked by the renter")
+	}
+	return rf, nil
+}
+
+// UploadDedup uses the node to upload the file to siaPath with deduplication
+// enabled.
+func (tn *TestNode) UploadDedup(lf *LocalFile, siaPath string, dataPieces, parityPieces uint64) (*RemoteFile, error) {
+	// Upload file
+	err := tn.RenterUploadDedupPost(lf.path, siaPath, types.Specifier{}, dataPieces, parityPieces)
+	if err != nil {
+		return nil, err
+	}
+	// Create remote file object
+	rf := &RemoteFile{
+		siaPath:  siaPath,
+		checksum: lf.checksum,
+	}
+	// Make sure renter tracks file
+	_, err = tn.FileInfo(rf)
+	if err != nil {
+		return rf, errors.AddContext(err, "uploaded file is not tracked by the renter")
+	}
+	return rf, nil
+}
+
+// UploadPriority uses the node to upload the file with the given priority.
+// The ID of the upload is returned along with the remote file.
+func (tn *TestNode) UploadPriority(lf *LocalFile, dataPieces, parityPieces uint64, priority modules.Priority) (*RemoteFile, string, error) {
+	// Upload file
+	ru, err := tn.RenterUploadPriorityPost(lf.path, "/"+lf.fileName(), types.Specifier{}, dataPieces, parityPieces, false, priority)
+	if err != nil {
+		return nil, "", err
+	}
+	// Create remote file object
+	rf := &RemoteFile{
+		siaPath:  lf.fileName(),
+		checksum: lf.checksum,
+	}
+	// Make sure renter tracks file
+	_, err = tn.FileInfo(rf)
+	if err != nil {
+		return rf, ru.ID, errors.AddContext(err, "uploaded file is not tracked by the renter")
+	}
+	return rf, ru.ID, nil
+}
+
+// UploadCipher uses the node to upload the file encrypted with the cipher ct.
+func (tn *TestNode) UploadCipher(lf *LocalFile, dataPieces, parityPieces uint64, ct crypto.CipherType) (*RemoteFile, error) {
+	// Upload file
+	_, err := tn.RenterUploadCipherPost(lf.path, "/"+lf.fileName(), types.Specifier{}, dataPieces, parityPieces, ct)
+	if err != nil {
+		return nil, err
+	}
+	// Create remote file object
+	rf := &RemoteFile{
+		siaPath:  lf.fileName(),
+		checksum: lf.checksum,
+	}
+	// Make sure renter tracks file
+	_, err = tn.FileInfo(rf)
+	if err != nil {
+		return rf, errors.AddContext(err, "uploaded file is not tracked by the renter")
+	}
+	return rf, nil
+}
+
 // UploadNewFile initiates the upload of a filesize bytes large file.
 func (tn *TestNode) UploadNewFile(filesize int, dataPieces uint64, parityPieces uint64) (*LocalFile, *RemoteFile, error) {
 	// Create file for upload
@@ -282,6 +402,27 @@ func (tn *TestNode) WaitForUploadRedundancy(rf *RemoteFile, redundancy float64)
 	})
 }
 
+// WaitForReencryption waits for the re-encryption of a file to complete. An
+// error is returned if the re-encryption failed.
+func (tn *TestNode) WaitForReencryption(rf *RemoteFile) error {
+	return Retry(600, 100*time.Millisecond, func() error {
+		rr, err := tn.RenterReencryptionsGet()
+		if err != nil {
+			return errors.AddContext(err, "couldn't retrieve re-encryptions")
+		}
+		for _, re := range rr.Reencryptions {
+			if re.SiaPath != rf.siaPath {
+				continue
+			}
+			if re.Error != "" {
+				return errors.New("re-encryption failed: " + re.Error)
+			}
+			return fmt.Errorf("re-encryption is at %v%%", re.Progress)
+		}
+		return nil
+	})
+}
+
 // WaitForDecreasingRedundancy waits until the redundancy decreases to a
 // certain point.
 func (tn *TestNode) WaitForDecreasingRedundancy(rf *RemoteFile, redundancy float64) error {
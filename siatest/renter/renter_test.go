@@ -1,12 +1,16 @@
 package renter
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -14,6 +18,7 @@ import (
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/renter"
 	"github.com/NebulousLabs/Sia/node"
+	"github.com/NebulousLabs/Sia/persist"
 	"github.com/NebulousLabs/Sia/siatest"
 	"github.com/NebulousLabs/Sia/types"
 
@@ -56,6 +61,19 @@ func TestRenter(t *testing.T) {
 		{"TestRenterDownloadAfterRenew", testRenterDownloadAfterRenew},
 		{"TestRenterLocalRepair", testRenterLocalRepair},
 		{"TestRenterRemoteRepair", testRenterRemoteRepair},
+		{"TestRenterDirectories", testRenterDirectories},
+		{"TestRenterErasureCoders", testRenterErasureCoders},
+		{"TestRenterPartialDownload", testRenterPartialDownload},
+		{"TestRenterUploadStream", testRenterUploadStream},
+		{"TestRenterStreamPrefetch", testRenterStreamPrefetch},
+		{"TestRenterPackSmallFiles", testRenterPackSmallFiles},
+		{"TestRenterDedup", testRenterDedup},
+		{"TestRenterBackup", testRenterBackup},
+		{"TestRenterPriorityJobs", testRenterPriorityJobs},
+		{"TestRenterSpending", testRenterSpending},
+		{"TestRenterReencrypt", testRenterReencrypt},
+		{"TestRenterHostPerformance", testRenterHostPerformance},
+		{"TestRenterChunkCache", testRenterChunkCache},
 	}
 	// Run subtests
 	for _, subtest := range subTests {
@@ -112,6 +130,119 @@ func testUploadDownload(t *testing.T, tg *siatest.TestGroup) {
 	}
 }
 
+// testRenterHostPerformance is a subtest that uses an existing TestGroup to
+// test that the renter measures the throughput and latency of its hosts.
+func testRenterHostPerformance(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	renter := tg.Renters()[0]
+	// Upload and download a file that fills whole sectors.
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+	_, rf, err := renter.UploadNewFileBlocking(int(modules.SectorSize), dataPieces, parityPieces)
+	if err != nil {
+		t.Fatal("Failed to upload a file for testing: ", err)
+	}
+	if _, err := renter.DownloadByStream(rf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every host should have a measured upload throughput and latency, and
+	// at least one host should have a measured download throughput.
+	rc, err := renter.RenterContractsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var downloads int
+	for _, c := range rc.Contracts {
+		hhg, err := renter.HostDbHostsGet(c.HostPublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hhg.Entry.UploadThroughput == 0 || hhg.Entry.Latency == 0 {
+			t.Fatal("host performance wasn't measured:", hhg.Entry.UploadThroughput, hhg.Entry.Latency)
+		}
+		if hhg.ScoreBreakdown.PerformanceAdjustment <= 0 || hhg.ScoreBreakdown.PerformanceAdjustment > 1 {
+			t.Fatal("invalid performance adjustment:", hhg.ScoreBreakdown.PerformanceAdjustment)
+		}
+		if hhg.Entry.DownloadThroughput > 0 {
+			downloads++
+		}
+	}
+	if downloads == 0 {
+		t.Fatal("download throughput wasn't measured")
+	}
+}
+
+// testRenterChunkCache is a subtest that uses an existing TestGroup to test
+// that repeated downloads are served from the renter's chunk cache.
+func testRenterChunkCache(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	renter := tg.Renters()[0]
+	if err := renter.RenterSetChunkCacheSizePost(1 << 30); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := renter.RenterSetChunkCacheSizePost(0); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+	_, rf, err := renter.UploadNewFileBlocking(100+siatest.Fuzz(), dataPieces, parityPieces)
+	if err != nil {
+		t.Fatal("Failed to upload a file for testing: ", err)
+	}
+
+	// The first download fetches the chunk from the hosts and caches it in
+	// the background.
+	if _, err := renter.DownloadToDisk(rf, false); err != nil {
+		t.Fatal(err)
+	}
+	var before modules.ChunkCacheStats
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		rg, err := renter.RenterGet()
+		if err != nil {
+			return err
+		}
+		before = rg.ChunkCache
+		if before.Chunks == 0 || before.Size == 0 {
+			return fmt.Errorf("downloaded chunk wasn't cached: %v", before)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second download should be served from the cache.
+	if _, err := renter.DownloadToDisk(rf, false); err != nil {
+		t.Fatal(err)
+	}
+	rg, err := renter.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rg.ChunkCache.Hits != before.Hits+1 || rg.ChunkCache.Misses != before.Misses {
+		t.Fatal("download wasn't served from the cache:", before, rg.ChunkCache)
+	}
+
+	// Deleting the file removes its chunks from the cache.
+	fi, err := renter.FileInfo(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := renter.RenterDeletePost(fi.SiaPath); err != nil {
+		t.Fatal(err)
+	}
+	rg, err = renter.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rg.ChunkCache.Chunks != before.Chunks-1 {
+		t.Fatal("chunks of deleted file weren't evicted:", rg.ChunkCache)
+	}
+}
+
 // testSingleFileGet is a subtest that uses an existing TestGroup to test if
 // using the signle file API endpoint works
 func testSingleFileGet(t *testing.T, tg *siatest.TestGroup) {
@@ -143,6 +274,75 @@ func testSingleFileGet(t *testing.T, tg *siatest.TestGroup) {
 	}
 }
 
+// testRenterDirectories is a subtest that uses an existing TestGroup to test
+// creating, listing, renaming and deleting directories.
+func testRenterDirectories(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	renter := tg.Renters()[0]
+	// Upload a file to the root directory.
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+	_, rf, err := renter.UploadNewFileBlocking(100+siatest.Fuzz(), dataPieces, parityPieces)
+	if err != nil {
+		t.Fatal("Failed to upload a file for testing: ", err)
+	}
+	fi, err := renter.FileInfo(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a nested directory.
+	if err := renter.RenterDirCreatePost("dir1/dir2"); err != nil {
+		t.Fatal("Failed to create directory: ", err)
+	}
+	rd, err := renter.RenterDirGet("dir1")
+	if err != nil {
+		t.Fatal("Failed to list directory: ", err)
+	}
+	if len(rd.Directories) != 2 || rd.Directories[1].SiaPath != "dir1/dir2" {
+		t.Fatal("Nested directory wasn't created: ", rd.Directories)
+	}
+
+	// The root directory should contain the uploaded file.
+	rd, err = renter.RenterDirGet("")
+	if err != nil {
+		t.Fatal("Failed to list root directory: ", err)
+	}
+	var found bool
+	for _, f := range rd.Files {
+		found = found || f.SiaPath == fi.SiaPath
+	}
+	if !found {
+		t.Fatal("Uploaded file isn't listed in the root directory")
+	}
+	if rd.Directories[0].AggregateSize < fi.Filesize {
+		t.Fatal("Root directory size doesn't include the uploaded file")
+	}
+
+	// Rename the directory.
+	if err := renter.RenterDirRenamePost("dir1", "dir3"); err != nil {
+		t.Fatal("Failed to rename directory: ", err)
+	}
+	if _, err := renter.RenterDirGet("dir1"); err == nil {
+		t.Fatal("Old directory still exists after rename")
+	}
+	rd, err = renter.RenterDirGet("dir3")
+	if err != nil {
+		t.Fatal("Failed to list renamed directory: ", err)
+	}
+	if len(rd.Directories) != 2 || rd.Directories[1].SiaPath != "dir3/dir2" {
+		t.Fatal("Subdirectory wasn't moved: ", rd.Directories)
+	}
+
+	// Delete the directory.
+	if err := renter.RenterDirDeletePost("dir3"); err != nil {
+		t.Fatal("Failed to delete directory: ", err)
+	}
+	if _, err := renter.RenterDirGet("dir3"); err == nil {
+		t.Fatal("Directory still exists after delete")
+	}
+}
+
 // testDownloadMultipleLargeSectors downloads multiple large files (>5 Sectors)
 // in parallel and makes sure that the downloads are blocking each other.
 func testDownloadMultipleLargeSectors(t *testing.T, tg *siatest.TestGroup) {
@@ -574,6 +774,69 @@ func testRenterStreamingCache(t *testing.T, tg *siatest.TestGroup) {
 	}
 }
 
+// testRenterStreamPrefetch checks that files can be streamed with different
+// prefetch windows, in parallel and with multipart range requests, and that
+// the streams are closed once their requests have been served.
+func testRenterStreamPrefetch(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a file that spans a few chunks.
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+	chunkSize := int(modules.SectorSize - crypto.TwofishOverhead)
+	fileSize := 3*chunkSize + siatest.Fuzz()
+	localFile, remoteFile, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stream the file with different prefetch windows.
+	for _, prefetch := range []uint64{0, 1, 8} {
+		if _, err := r.StreamPrefetch(remoteFile, prefetch); err != nil {
+			t.Fatalf("streaming with prefetch %v failed: %v", prefetch, err)
+		}
+	}
+	if _, err := r.StreamPrefetch(remoteFile, 9); err == nil {
+		t.Fatal("streaming with a prefetch window of 9 chunks should fail")
+	}
+
+	// Stream the file several times in parallel.
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = r.Stream(remoteFile)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Request ranges in different chunks at once.
+	ranges := [][2]uint64{
+		{0, 9},
+		{uint64(chunkSize) - 5, uint64(chunkSize) + 5},
+		{uint64(fileSize) - 10, uint64(fileSize) - 1},
+	}
+	if _, err := r.StreamMultipart(remoteFile, localFile, ranges); err != nil {
+		t.Fatal(err)
+	}
+
+	// The streams are closed once their requests have been served.
+	rs, err := r.RenterStreamsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.Streams) != 0 {
+		t.Fatal("expected no open streams, got", len(rs.Streams))
+	}
+}
+
 // TestRenewFailing checks if a contract gets marked as !goodForRenew after
 // failing multiple times in a row.
 func TestRenewFailing(t *testing.T) {
@@ -718,6 +981,113 @@ func TestRenewFailing(t *testing.T) {
 	}
 }
 
+// TestRenterRecover checks that a renter that lost its directory can recover
+// its contracts and files using only its wallet seed.
+func TestRenterRecover(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	testDir, err := siatest.TestDir(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a group with a renter whose directory is known.
+	groupParams := siatest.GroupParams{
+		Hosts:  2,
+		Miners: 1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(groupParams)
+	if err != nil {
+		t.Fatal("Failed to create group: ", err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := tg.AddNodes(node.Renter(testDir)); err != nil {
+		t.Fatal(err)
+	}
+	renter := tg.Renters()[0]
+
+	// Upload a file and a remote backup.
+	_, rf, err := renter.UploadNewFileBlocking(100+siatest.Fuzz(), 1, uint64(len(tg.Hosts())-1))
+	if err != nil {
+		t.Fatal("Failed to upload file: ", err)
+	}
+	fi, err := renter.FileInfo(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := renter.RenterBackupRemotePost(); err != nil {
+		t.Fatal("Failed to upload backup: ", err)
+	}
+	rcg, err := renter.RenterContractsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The contracts have to be on the blockchain to be recovered.
+	if err := tg.Miners()[0].MineBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tg.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Lose the renter directory.
+	if err := renter.StopNode(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(testDir, modules.RenterDir)); err != nil {
+		t.Fatal(err)
+	}
+	if err := renter.StartNode(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := renter.File(fi.SiaPath); err == nil {
+		t.Fatal("File should have been lost")
+	}
+
+	// Recover the contracts and the file from the seed.
+	rr, err := renter.RenterRecoverPost()
+	if err != nil {
+		t.Fatal("Failed to recover renter: ", err)
+	}
+	if rr.Contracts != uint64(len(rcg.Contracts)) {
+		t.Fatalf("Expected %v recovered contracts, got %v", len(rcg.Contracts), rr.Contracts)
+	}
+	if len(rr.Files) != 1 || rr.Files[0] != fi.SiaPath {
+		t.Fatal("File was not recovered:", rr.Files)
+	}
+	recovered, err := renter.RenterContractsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[types.FileContractID]struct{})
+	for _, c := range recovered.Contracts {
+		ids[c.ID] = struct{}{}
+	}
+	for _, c := range rcg.Contracts {
+		if _, exists := ids[c.ID]; !exists {
+			t.Fatal("Contract was not recovered:", c.ID)
+		}
+	}
+	if _, err := renter.DownloadByStream(rf); err != nil {
+		t.Fatal("Failed to download recovered file: ", err)
+	}
+
+	// Recovering again shouldn't find anything new.
+	rr, err = renter.RenterRecoverPost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Contracts != 0 || len(rr.Files) != 0 {
+		t.Fatal("Recovered the renter twice:", rr)
+	}
+}
+
 // TestRenterPersistData checks if the RenterSettings are persisted
 func TestRenterPersistData(t *testing.T) {
 	if testing.Short() {
@@ -1108,3 +1478,832 @@ func TestRenterSpendingReporting(t *testing.T) {
 			balanceAfterRenewal, wg.ConfirmedSiacoinBalance)
 	}
 }
+
+// testRenterPartialDownload checks that downloading a small range of a file
+// whose erasure coder supports partial recovery only fetches the parts of the
+// pieces that cover the range.
+func testRenterPartialDownload(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	renter := tg.Renters()[0]
+	numHosts := uint64(len(tg.Hosts()))
+	lf, err := siatest.NewFile(int(2 * modules.SectorSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rf, err := renter.UploadErasureCoder(lf, modules.ErasureCoderPartialRS, 2, numHosts-2)
+	if err != nil {
+		t.Fatal("Failed to upload file: ", err)
+	}
+	if err := renter.WaitForUploadRedundancy(rf, float64(numHosts)/2); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := renter.FileInfo(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rg, err := renter.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// downloadSpending returns the money spent on downloading the file.
+	downloadSpending := func() types.Currency {
+		rs, err := renter.RenterSpendingGet(fi.SiaPath, rg.CurrentPeriod, rg.CurrentPeriod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rs.Files) == 0 {
+			return types.ZeroCurrency
+		}
+		return rs.Files[0].DownloadSpending
+	}
+
+	// Download a small range and then the whole file.
+	offset, length := uint64(1000), uint64(200)
+	before := downloadSpending()
+	rangeData, err := renter.RenterDownloadHTTPResponseGet(fi.SiaPath, offset, length)
+	if err != nil {
+		t.Fatal("Failed to download range: ", err)
+	}
+	rangeSpending := downloadSpending().Sub(before)
+	before = downloadSpending()
+	data, err := renter.RenterDownloadHTTPResponseGet(fi.SiaPath, 0, fi.Filesize)
+	if err != nil {
+		t.Fatal("Failed to download file: ", err)
+	}
+	fullSpending := downloadSpending().Sub(before)
+	if !bytes.Equal(rangeData, data[offset:offset+length]) {
+		t.Fatal("Downloaded range doesn't match the file's data")
+	}
+
+	// Only the segments covering the range should have been paid for.
+	if rangeSpending.IsZero() || rangeSpending.Mul64(4).Cmp(fullSpending) >= 0 {
+		t.Fatalf("Downloading %v bytes cost %v, downloading the whole file cost %v", length, rangeSpending, fullSpending)
+	}
+}
+
+// testRenterErasureCoders checks that files can be uploaded and downloaded
+// using every erasure coder of the renter.
+func testRenterErasureCoders(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	renter := tg.Renters()[0]
+	numHosts := uint64(len(tg.Hosts()))
+	tests := []struct {
+		erasureCoder types.Specifier
+		dataPieces   uint64
+		parityPieces uint64
+	}{
+		{modules.ErasureCoderReedSolomon, 2, numHosts - 2},
+		{modules.ErasureCoderReplication, 1, numHosts - 1},
+		{modules.ErasureCoderPartialRS, 2, numHosts - 2},
+	}
+	for _, test := range tests {
+		lf, err := siatest.NewFile(int(2*modules.SectorSize) + siatest.Fuzz())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rf, err := renter.UploadErasureCoder(lf, test.erasureCoder, test.dataPieces, test.parityPieces)
+		if err != nil {
+			t.Fatal("Failed to upload file: ", err)
+		}
+		redundancy := float64(test.dataPieces+test.parityPieces) / float64(test.dataPieces)
+		if err := renter.WaitForUploadRedundancy(rf, redundancy); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := renter.FileInfo(rf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.ErasureCoder != test.erasureCoder {
+			t.Fatalf("Expected erasure coder %v, got %v", test.erasureCoder, fi.ErasureCoder)
+		}
+		if _, err := renter.DownloadToDisk(rf, false); err != nil {
+			t.Fatal("Failed to download file: ", err)
+		}
+		data, err := renter.Stream(rf)
+		if err != nil {
+			t.Fatal("Failed to stream file: ", err)
+		}
+		// Download a range from the middle of the file.
+		offset := modules.SectorSize - 100
+		length := uint64(200)
+		rangeData, err := renter.RenterDownloadHTTPResponseGet(fi.SiaPath, offset, length)
+		if err != nil {
+			t.Fatal("Failed to download range: ", err)
+		}
+		if !bytes.Equal(rangeData, data[offset:offset+length]) {
+			t.Fatal("Downloaded range doesn't match the file's data")
+		}
+	}
+
+	// Unknown erasure coders and invalid replication parameters should be
+	// rejected.
+	lf, err := siatest.NewFile(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := renter.UploadErasureCoder(lf, types.Specifier{'f', 'o', 'o'}, 0, 0); err == nil {
+		t.Fatal("Expected upload with unknown erasure coder to fail")
+	}
+	if _, err := renter.UploadErasureCoder(lf, modules.ErasureCoderReplication, 2, 2); err == nil {
+		t.Fatal("Expected replication with multiple data pieces to fail")
+	}
+}
+
+// testRenterUploadStream checks that files can be uploaded from a stream and
+// that interrupted stream uploads can be resumed after a restart.
+func testRenterUploadStream(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	renter := tg.Renters()[0]
+	chunkSize := int(modules.SectorSize - crypto.TwofishOverhead)
+	data := fastrand.Bytes(2*chunkSize + chunkSize/2)
+
+	// Upload a stream and download it again.
+	if err := renter.RenterUploadStreamPost(bytes.NewReader(data), "stream"); err != nil {
+		t.Fatal("Failed to upload stream: ", err)
+	}
+	fi, err := renter.File("stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Filesize != uint64(len(data)) {
+		t.Fatalf("Expected filesize %v, got %v", len(data), fi.Filesize)
+	}
+	downloaded, err := renter.RenterDownloadHTTPResponseGet("stream", 0, uint64(len(data)))
+	if err != nil {
+		t.Fatal("Failed to download stream: ", err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatal("Downloaded data doesn't match the uploaded stream")
+	}
+	// A finished stream can't be resumed.
+	if _, err := renter.RenterUploadStreamGet("stream"); err == nil {
+		t.Fatal("Expected finished stream to have no resume offset")
+	}
+	if err := renter.RenterUploadStreamResumePost(bytes.NewReader(data), "stream", 0); err == nil {
+		t.Fatal("Expected resuming a finished stream to fail")
+	}
+
+	// Interrupt a stream after two chunks.
+	failingReader := io.MultiReader(bytes.NewReader(data[:2*chunkSize+10]), iotest.TimeoutReader(bytes.NewReader(data)))
+	if err := renter.RenterUploadStreamPost(failingReader, "interrupted"); err == nil {
+		t.Fatal("Expected interrupted stream upload to fail")
+	}
+
+	// Restart the renter and resume the upload. The renter might not have
+	// started processing the stream when the client gave up.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		_, err := renter.File("interrupted")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := renter.RestartNode(); err != nil {
+		t.Fatal(err)
+	}
+	var offset uint64
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		rus, err := renter.RenterUploadStreamGet("interrupted")
+		offset = rus.Offset
+		return err
+	})
+	if err != nil {
+		t.Fatal("Failed to get resume offset: ", err)
+	}
+	if offset > uint64(2*chunkSize) || offset%uint64(chunkSize) != 0 {
+		t.Fatal("Unexpected resume offset: ", offset)
+	}
+	if err := renter.RenterUploadStreamResumePost(bytes.NewReader(data), "interrupted", offset+1); err == nil {
+		t.Fatal("Expected resuming beyond the resume offset to fail")
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		return renter.RenterUploadStreamResumePost(bytes.NewReader(data[offset:]), "interrupted", offset)
+	})
+	if err != nil {
+		t.Fatal("Failed to resume stream: ", err)
+	}
+	downloaded, err = renter.RenterDownloadHTTPResponseGet("interrupted", 0, uint64(len(data)))
+	if err != nil {
+		t.Fatal("Failed to download resumed stream: ", err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatal("Downloaded data doesn't match the resumed stream")
+	}
+}
+
+// testRenterPackSmallFiles checks that small files are packed into shared
+// sectors, that they can be downloaded from their packs and that deleting them
+// is accounted as garbage.
+func testRenterPackSmallFiles(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	renter := tg.Renters()[0]
+	numHosts := uint64(len(tg.Hosts()))
+
+	// packTotals returns the number of packed files and the garbage of all
+	// packs.
+	packTotals := func() (files int, garbage uint64) {
+		rp, err := renter.RenterPacksGet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range rp.Packs {
+			files += p.Files
+			garbage += p.GarbageBytes
+		}
+		return
+	}
+	filesBefore, garbageBefore := packTotals()
+
+	// Upload a few small files. They should end up in a pack.
+	var lfs []*siatest.LocalFile
+	var rfs []*siatest.RemoteFile
+	for i := 0; i < 3; i++ {
+		lf, rf, err := renter.UploadNewFile(100+siatest.Fuzz(), 1, numHosts-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lfs = append(lfs, lf)
+		rfs = append(rfs, rf)
+	}
+	for _, rf := range rfs {
+		if err := renter.WaitForUploadRedundancy(rf, float64(numHosts)); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := renter.FileInfo(rf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fi.Packed {
+			t.Fatal("Small file was not packed")
+		}
+	}
+	if files, _ := packTotals(); files != filesBefore+3 {
+		t.Fatalf("Expected %v packed files, got %v", filesBefore+3, files)
+	}
+
+	// Download the files from their packs.
+	for i, rf := range rfs {
+		if _, err := renter.DownloadToDisk(rf, false); err != nil {
+			t.Fatal("Failed to download packed file: ", err)
+		}
+		data, err := renter.Stream(rf)
+		if err != nil {
+			t.Fatal("Failed to stream packed file: ", err)
+		}
+		fi, err := renter.FileInfo(rf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rangeData, err := renter.RenterDownloadHTTPResponseGet(fi.SiaPath, 10, 50)
+		if err != nil {
+			t.Fatal("Failed to download range of packed file: ", err)
+		}
+		if !bytes.Equal(rangeData, data[10:60]) {
+			t.Fatal("Downloaded range doesn't match the file's data")
+		}
+		if err := lfs[i].Delete(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Deleting a packed file turns its slot into garbage.
+	fi, err := renter.FileInfo(rfs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := renter.RenterDeletePost(fi.SiaPath); err != nil {
+		t.Fatal(err)
+	}
+	files, garbage := packTotals()
+	if files != filesBefore+2 {
+		t.Fatalf("Expected %v packed files, got %v", filesBefore+2, files)
+	}
+	if garbage <= garbageBefore {
+		t.Fatal("Deleted file was not accounted as garbage")
+	}
+}
+
+// testRenterDedup checks that identical files uploaded with deduplication
+// share their pieces.
+func testRenterDedup(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	renter := tg.Renters()[0]
+	numHosts := uint64(len(tg.Hosts()))
+	rg, err := renter.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statsBefore := rg.DedupStats
+
+	// Upload the same file twice. The second upload should reuse all pieces
+	// of the first one.
+	lf, err := siatest.NewFile(int(2*modules.SectorSize) + siatest.Fuzz())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rfs []*siatest.RemoteFile
+	for _, siaPath := range []string{"dedup/a", "dedup/b"} {
+		rf, err := renter.UploadDedup(lf, siaPath, 2, numHosts-2)
+		if err != nil {
+			t.Fatal("Failed to upload file: ", err)
+		}
+		if err := renter.WaitForUploadRedundancy(rf, float64(numHosts)/2); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := renter.FileInfo(rf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fi.Deduplicated {
+			t.Fatal("File should be deduplicated")
+		}
+		rfs = append(rfs, rf)
+	}
+	rg, err = renter.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := rg.DedupStats
+	if stats.Files != statsBefore.Files+2 {
+		t.Fatalf("Expected %v deduplicated files, got %v", statsBefore.Files+2, stats.Files)
+	}
+	if saved := stats.SavedBytes - statsBefore.SavedBytes; saved != stats.StoredBytes-statsBefore.StoredBytes {
+		t.Fatalf("Expected the second file to reuse all %v stored bytes, but %v bytes were saved", stats.StoredBytes-statsBefore.StoredBytes, saved)
+	}
+
+	// The second file should still be downloadable after deleting the first
+	// one.
+	if err := renter.RenterDeletePost("dedup/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := renter.DownloadToDisk(rfs[1], false); err != nil {
+		t.Fatal("Failed to download deduplicated file: ", err)
+	}
+	if _, err := renter.Stream(rfs[1]); err != nil {
+		t.Fatal("Failed to stream deduplicated file: ", err)
+	}
+}
+
+// testRenterSpending checks that the spending on uploads, storage and
+// downloads is attributed to the files in the spending ledger.
+func testRenterSpending(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	renter := tg.Renters()[0]
+
+	// Upload and download a file that isn't packed.
+	_, rf, err := renter.UploadNewFileBlocking(int(2*modules.SectorSize), 1, uint64(len(tg.Hosts())-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := renter.DownloadToDisk(rf, false); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := renter.FileInfo(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file should have a single entry in the ledger for the current
+	// period.
+	rg, err := renter.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := renter.RenterSpendingGet(fi.SiaPath, rg.CurrentPeriod, rg.CurrentPeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.Files) != 1 || rs.Files[0].SiaPath != fi.SiaPath {
+		t.Fatal("wrong spending entries:", rs.Files)
+	}
+	fs := rs.Files[0]
+	if fs.UploadSpending.IsZero() || fs.StorageSpending.IsZero() || fs.DownloadSpending.IsZero() {
+		t.Fatal("spending wasn't recorded:", fs)
+	}
+	if rs.Total.Total().Cmp(fs.Total()) != 0 {
+		t.Fatal("total doesn't match the entry:", rs.Total, fs)
+	}
+
+	// The spending of the file can't exceed the spending of the renter.
+	if fs.UploadSpending.Cmp(rg.FinancialMetrics.UploadSpending) > 0 || fs.StorageSpending.Cmp(rg.FinancialMetrics.StorageSpending) > 0 || fs.DownloadSpending.Cmp(rg.FinancialMetrics.DownloadSpending) > 0 {
+		t.Fatal("file spending exceeds the renter's spending:", fs, rg.FinancialMetrics)
+	}
+
+	// Entries of later periods don't exist yet.
+	rs, err = renter.RenterSpendingGet("", rg.CurrentPeriod+1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.Files) != 0 {
+		t.Fatal("unexpected entries of future periods:", rs.Files)
+	}
+}
+
+// testRenterReencrypt checks that files can be uploaded with XChaCha20 and
+// that files can be re-encrypted with another cipher without becoming
+// unreadable.
+func testRenterReencrypt(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	renter := tg.Renters()[0]
+	numHosts := uint64(len(tg.Hosts()))
+
+	// Upload a file that is encrypted with XChaCha20.
+	lf, err := siatest.NewFile(int(2*modules.SectorSize) + siatest.Fuzz())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rf, err := renter.UploadCipher(lf, 2, numHosts-2, crypto.TypeXChaCha20)
+	if err != nil {
+		t.Fatal("Failed to upload file: ", err)
+	}
+	if err := renter.WaitForUploadRedundancy(rf, float64(numHosts)/2); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := renter.FileInfo(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.CipherType != crypto.TypeXChaCha20 {
+		t.Fatal("Expected an XChaCha20 file, got", fi.CipherType)
+	}
+	if _, err := renter.DownloadToDisk(rf, false); err != nil {
+		t.Fatal("Failed to download file: ", err)
+	}
+
+	// Re-encrypt a Twofish file with XChaCha20. The file should remain
+	// readable while it is being re-encrypted.
+	_, rf, err = renter.UploadNewFileBlocking(int(2*modules.SectorSize)+siatest.Fuzz(), 2, numHosts-2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err = renter.FileInfo(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := renter.RenterReencryptPost(fi.SiaPath, crypto.TypeXChaCha20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := renter.DownloadByStream(rf); err != nil {
+		t.Fatal("Failed to download file during re-encryption: ", err)
+	}
+	if err := renter.WaitForReencryption(rf); err != nil {
+		t.Fatal(err)
+	}
+	fi, err = renter.FileInfo(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.CipherType != crypto.TypeXChaCha20 || fi.Reencrypting {
+		t.Fatal("File wasn't re-encrypted:", fi.CipherType, fi.Reencrypting)
+	}
+	if err := renter.WaitForUploadRedundancy(rf, float64(numHosts)/2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := renter.DownloadToDisk(rf, false); err != nil {
+		t.Fatal("Failed to download re-encrypted file: ", err)
+	}
+
+	// Re-encrypting a file with its own cipher rotates its key.
+	if err := renter.RenterReencryptPost(fi.SiaPath, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := renter.WaitForReencryption(rf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := renter.DownloadToDisk(rf, false); err != nil {
+		t.Fatal("Failed to download re-encrypted file: ", err)
+	}
+}
+
+// testRenterPriorityJobs checks that uploads and downloads can be started
+// with a priority and paused, resumed or cancelled by their ID.
+func testRenterPriorityJobs(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	renter := tg.Renters()[0]
+	numHosts := uint64(len(tg.Hosts()))
+
+	// Uploads with an unknown priority are rejected.
+	lf, err := siatest.NewFile(int(modules.SectorSize) + siatest.Fuzz())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := renter.UploadPriority(lf, 1, numHosts-1, modules.Priority(5)); err == nil {
+		t.Fatal("upload with unknown priority succeeded")
+	}
+
+	// Upload a file with a high priority and pause it.
+	rf, id, err := renter.UploadPriority(lf, 1, numHosts-1, modules.PriorityHigh)
+	if err != nil {
+		t.Fatal("Failed to upload file: ", err)
+	}
+	if err := renter.RenterUploadPausePost(id); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := renter.FileInfo(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.UploadID != id || fi.Priority != modules.PriorityHigh || !fi.UploadPaused {
+		t.Fatalf("upload isn't reported correctly: %+v", fi)
+	}
+
+	// Resume the upload and wait for it to finish. A finished upload can't
+	// be cancelled.
+	if err := renter.RenterUploadResumePost(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := renter.WaitForUploadRedundancy(rf, float64(numHosts)); err != nil {
+		t.Fatal(err)
+	}
+	if err := renter.RenterUploadCancelPost(id); err == nil {
+		t.Fatal("finished upload was cancelled")
+	}
+
+	// Download the file in the background with a low priority and cancel
+	// the download. Depending on timing the download might have completed
+	// already.
+	dest := filepath.Join(siatest.SiaTestingDir, persist.RandomSuffix())
+	rda, err := renter.RenterDownloadAsyncGet(fi.SiaPath, dest, 0, fi.Filesize, modules.PriorityLow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdq, err := renter.RenterDownloadsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rdq.Downloads) == 0 || rdq.Downloads[0].ID != rda.ID || rdq.Downloads[0].Priority != modules.PriorityLow {
+		t.Fatal("download isn't reported correctly")
+	}
+	if err := renter.RenterDownloadCancelPost(rda.ID); err != nil && !strings.Contains(err.Error(), "already completed") {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		rdq, err := renter.RenterDownloadsGet()
+		if err != nil {
+			return err
+		}
+		if !rdq.Downloads[0].Completed {
+			return errors.New("download hasn't completed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := renter.RenterDownloadCancelPost(rda.ID); err == nil {
+		t.Fatal("completed download was cancelled")
+	}
+	if err := renter.RenterDownloadCancelPost("foo"); err == nil {
+		t.Fatal("unknown download was cancelled")
+	}
+
+	// Cancelling an upload deletes the file. Depending on timing the upload
+	// might have completed already.
+	_, rf, err = renter.UploadNewFile(int(modules.SectorSize)+siatest.Fuzz(), 1, numHosts-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err = renter.FileInfo(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = renter.RenterUploadCancelPost(fi.UploadID)
+	if err != nil && !strings.Contains(err.Error(), "already completed") {
+		t.Fatal(err)
+	}
+	if _, fileErr := renter.FileInfo(rf); (err == nil) != (fileErr != nil) {
+		t.Fatal("file should only be deleted if the upload was cancelled:", err, fileErr)
+	}
+}
+
+// testRenterBackup checks that deleted files can be restored from a backup of
+// the renter.
+func testRenterBackup(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	renter := tg.Renters()[0]
+	numHosts := uint64(len(tg.Hosts()))
+
+	// Upload a file and back up the renter.
+	_, rf, err := renter.UploadNewFileBlocking(int(modules.SectorSize)+siatest.Fuzz(), 1, numHosts-1)
+	if err != nil {
+		t.Fatal("Failed to upload file: ", err)
+	}
+	fi, err := renter.FileInfo(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDir, err := siatest.TestDir(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(testDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	backup := filepath.Join(testDir, "renter.backup")
+	if err := renter.RenterBackupPost(backup); err != nil {
+		t.Fatal("Failed to create backup: ", err)
+	}
+
+	// Delete the file and restore it from the backup.
+	if err := renter.RenterDeletePost(fi.SiaPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := renter.File(fi.SiaPath); err == nil {
+		t.Fatal("File should have been deleted")
+	}
+	rr, err := renter.RenterRestorePost(backup)
+	if err != nil {
+		t.Fatal("Failed to restore backup: ", err)
+	}
+	var restored bool
+	for _, siaPath := range rr.Files {
+		restored = restored || siaPath == fi.SiaPath
+	}
+	if !restored {
+		t.Fatal("File was not restored:", rr.Files)
+	}
+
+	// The restored file should be complete and downloadable.
+	fi, err = renter.File(fi.SiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Redundancy < float64(numHosts) {
+		t.Fatalf("Expected redundancy %v, got %v", numHosts, fi.Redundancy)
+	}
+	if _, err := renter.DownloadByStream(rf); err != nil {
+		t.Fatal("Failed to download restored file: ", err)
+	}
+}
+
+// TestRenterS3Gateway tests managing the access keys of a renter's S3 gateway
+// through the API.
+func TestRenterS3Gateway(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	testDir, err := siatest.TestDir(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a group with a renter that runs an S3 gateway.
+	groupParams := siatest.GroupParams{
+		Hosts:  2,
+		Miners: 1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(groupParams)
+	if err != nil {
+		t.Fatal("Failed to create group: ", err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	renterParams := node.Renter(testDir)
+	renterParams.CreateS3Gateway = true
+	if err := tg.AddNodes(renterParams); err != nil {
+		t.Fatal(err)
+	}
+	renter := tg.Renters()[0]
+
+	// The gateway should have generated an access key.
+	sg, err := renter.S3Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sg.Keys) != 1 {
+		t.Fatal("expected one access key, got", len(sg.Keys))
+	}
+	if _, err := os.Stat(filepath.Join(testDir, modules.RenterDir, modules.S3GatewayKeysFile)); err != nil {
+		t.Fatal("access keys weren't stored in the renter dir:", err)
+	}
+
+	// Unsigned requests should be rejected by the gateway.
+	resp, err := http.Get("http://" + sg.Address + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatal("expected unsigned request to be rejected, got", resp.Status)
+	}
+
+	// Replace the access key.
+	key, err := renter.S3KeysAddPost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := renter.S3KeysRemovePost(sg.Keys[0].AccessKeyID); err != nil {
+		t.Fatal(err)
+	}
+	if err := renter.S3KeysRemovePost(key.AccessKeyID); err == nil {
+		t.Fatal("removing the last access key should fail")
+	}
+	if sg, err = renter.S3Get(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sg.Keys) != 1 || sg.Keys[0] != key {
+		t.Fatal("unexpected access keys:", sg.Keys)
+	}
+}
+
+// TestRenterTicketShare tests sharing a file with another renter through
+// download tickets.
+func TestRenterTicketShare(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	// Create a group with two renters that have contracts with the same
+	// hosts.
+	groupParams := siatest.GroupParams{
+		Hosts:   3,
+		Renters: 2,
+		Miners:  1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(groupParams)
+	if err != nil {
+		t.Fatal("Failed to create group: ", err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	owner, recipient := tg.Renters()[0], tg.Renters()[1]
+	numHosts := uint64(len(tg.Hosts()))
+
+	// Upload a file and share it for a few blocks.
+	_, rf, err := owner.UploadNewFileBlocking(int(2*modules.SectorSize)+siatest.Fuzz(), 1, numHosts-1)
+	if err != nil {
+		t.Fatal("Failed to upload file: ", err)
+	}
+	fi, err := owner.FileInfo(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := owner.RenterShareTicketPost([]string{fi.SiaPath}, 0); err == nil {
+		t.Fatal("Tickets without a duration shouldn't be created")
+	}
+	rts, err := owner.RenterShareTicketPost([]string{fi.SiaPath}, 5)
+	if err != nil {
+		t.Fatal("Failed to share file: ", err)
+	}
+
+	// The recipient should be able to download the file with its own
+	// contracts.
+	rl, err := recipient.RenterLoadTicketPost(rts.Share)
+	if err != nil {
+		t.Fatal("Failed to load share: ", err)
+	}
+	if len(rl.FilesAdded) != 1 || rl.FilesAdded[0] != fi.SiaPath {
+		t.Fatal("Wrong files were loaded:", rl.FilesAdded)
+	}
+	shared, err := recipient.File(fi.SiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !shared.Shared || !shared.Available || shared.Filesize != fi.Filesize {
+		t.Fatal("Shared file isn't available:", shared.Shared, shared.Available, shared.Filesize)
+	}
+	if _, err := recipient.DownloadByStream(rf); err != nil {
+		t.Fatal("Failed to download shared file: ", err)
+	}
+	rs, err := recipient.RenterSpendingGet(fi.SiaPath, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.Total.DownloadSpending.IsZero() {
+		t.Fatal("Recipient didn't pay for the download")
+	}
+	if _, err := recipient.RenterShareTicketPost([]string{fi.SiaPath}, 5); err == nil {
+		t.Fatal("Shared files shouldn't be shared again")
+	}
+	if _, err := owner.DownloadByStream(rf); err != nil {
+		t.Fatal("Owner failed to download file: ", err)
+	}
+
+	// Once the tickets have expired, the hosts should refuse to serve the
+	// file to the recipient.
+	for i := 0; i < 5; i++ {
+		if err := tg.Miners()[0].MineBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tg.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	shared, err = recipient.File(fi.SiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared.Available {
+		t.Fatal("File with expired tickets is still available")
+	}
+	if _, err := recipient.DownloadByStream(rf); err == nil {
+		t.Fatal("File with expired tickets was downloaded")
+	}
+}
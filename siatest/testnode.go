@@ -42,8 +42,10 @@ func (tn *TestNode) StartNode() error {
 		return err
 	}
 	tn.Server = *s
+	tn.Client.Address = s.APIAddress()
 
-	return nil
+	// Unlock the wallet
+	return tn.WalletUnlockPost(tn.primarySeed)
 }
 
 // StopNode stops a TestNode